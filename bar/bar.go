@@ -183,3 +183,12 @@ type RefresherModule interface {
 	Module
 	Refresh()
 }
+
+// RemovableModule extends module with a Remove() method that is called when
+// the module is removed from the bar, e.g. on reload. Modules cannot be
+// stopped, so a removed module is blocked the next time it updates its output,
+// but it can use Remove to release any shared resources it holds.
+type RemovableModule interface {
+	Module
+	Remove()
+}
@@ -14,7 +14,11 @@
 
 package bar
 
-import "image/color"
+import (
+	"image/color"
+
+	"barista.run/i18n"
+)
 
 // TextSegment creates a new output segment with text content.
 func TextSegment(text string) *Segment {
@@ -29,11 +33,11 @@ func PangoSegment(text string) *Segment {
 }
 
 // ErrorSegment creates a new output segment that displays an error.
-// On the bar itself, it's an urgent segment showing 'Error' or '!'
-// based on available space, but the full error will be shown using
-// i3-nagbar when the segment is right-clicked.
+// On the bar itself, it's an urgent segment showing 'Error' (in the current
+// language, see i18n) or '!' based on available space, but the full error
+// will be shown using i3-nagbar when the segment is right-clicked.
 func ErrorSegment(e error) *Segment {
-	return TextSegment("Error").Error(e).ShortText("!").Urgent(true)
+	return TextSegment(i18n.Sprintf("Error")).Error(e).ShortText("!").Urgent(true)
 }
 
 // Text sets the text content of this segment. It clears any previous
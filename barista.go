@@ -26,12 +26,12 @@ import (
 	"sync"
 
 	"barista.run/bar"
+	"barista.run/base/actions"
 	"barista.run/core"
 	l "barista.run/logging"
 	"barista.run/oauth"
 	"barista.run/timing"
 
-	"github.com/lucasb-eyer/go-colorful"
 	"golang.org/x/sys/unix"
 )
 
@@ -55,20 +55,36 @@ type i3Bar struct {
 	// The list of modules that make up this bar.
 	modules   []bar.Module
 	moduleSet *core.ModuleSet
-	// A map of previously set click handlers for each segment.
-	clickHandlers map[string]func(bar.Event)
+	// The click handlers of the last print, indexed by segment name.
+	clickHandlers []func(bar.Event)
 	// The function to call when an error segment is right-clicked.
 	errorHandler func(bar.ErrorEvent)
 	// The channel that receives a signal on module updates.
 	update chan struct{}
 	// The channel that aggregates all events from i3.
 	events chan i3Event
+	// Keyboard commands from the window manager, see EnableKeyboard.
+	keys         chan []string
+	keyboardMode string
+	// The index of the module focused from the keyboard, or -1 if none,
+	// and the colour used to highlight it.
+	focus      int
+	focusColor color.Color
+	// The click handler of the first clickable segment of the focused module.
+	focusClick func(bar.Event)
+	// Creates the new list of modules when the bar is reloaded, see OnReload.
+	reloadFn func() ([]bar.Module, error)
+	reload   chan struct{}
 	// The Reader to read events from (e.g. stdin)
 	reader io.Reader
 	// The Writer to write bar output to (e.g. stdout)
 	writer io.Writer
-	// A json encoder set to write to the output stream.
-	encoder *json.Encoder
+	// The buffer used to encode the bar, reused for each print.
+	buf []byte
+	// The last printed output of each module and the focus at the time, to
+	// avoid writing the bar again if nothing has changed.
+	printed      []printedOutput
+	printedFocus int
 	// Flipped when Run() is called, to prevent issues with modules
 	// being added after the bar has been started.
 	started bool
@@ -93,6 +109,7 @@ const (
 	dEvtPaused debugEventKind = 1 << iota
 	dEvtResumed
 	dEvtModuleStopped
+	dEvtUnchanged
 )
 
 // debugEvent is used for tests to synchronise on some events that
@@ -110,8 +127,13 @@ func construct() {
 		instance = &i3Bar{
 			update: make(chan struct{}, 1),
 			events: make(chan i3Event),
-			reader: os.Stdin,
-			writer: os.Stdout,
+			keys:   make(chan []string, 10),
+			reload: make(chan struct{}, 1),
+			focus:  -1,
+			// Default to a white border around the focused module.
+			focusColor: color.White,
+			reader:     os.Stdin,
+			writer:     os.Stdout,
 			// bar starts paused, will be resumed on Run().
 			paused: true,
 			// Default to i3-nagbar when right-clicking errors.
@@ -152,6 +174,14 @@ func SetErrorHandler(handler func(bar.ErrorEvent)) {
 	instance.errorHandler = handler
 }
 
+// SetRefreshButton sets the buttons that refresh modules supporting refresh
+// (bar.RefresherModule), e.g. to fetch the latest weather or mail on demand.
+// The default is a middle click. Calling it with no buttons disables refresh
+// on click, other than clicking on errors to retry.
+func SetRefreshButton(btns ...bar.Button) {
+	core.SetRefreshButtons(btns...)
+}
+
 // Run sets up all the streams and enters the main loop.
 // If any modules are provided, they are added to the bar now.
 // This allows both styles of bar construction:
@@ -162,6 +192,9 @@ func Run(modules ...bar.Module) error {
 	// So if the 'setup-oauth' arg was given, enter interactive setup instead.
 	// (InteractiveSetup calls os.Exit, so the rest of the bar will not run).
 	oauth.InteractiveSetup()
+	// Similarly, modules have registered checks for their prerequisites, so
+	// check them instead of running the bar if the 'doctor' arg was given.
+	runDoctor()
 	construct()
 	// To allow TestMode to work, we need to avoid any references
 	// to instance in the run loop.
@@ -171,6 +204,9 @@ func Run(modules ...bar.Module) error {
 		// Set up signal handlers for USR1/2 to pause/resume supported modules.
 		signalChan = make(chan os.Signal, 2)
 		signal.Notify(signalChan, unix.SIGUSR1, unix.SIGUSR2)
+		if b.reloadFn != nil {
+			signal.Notify(signalChan, unix.SIGHUP)
+		}
 	}
 
 	b.modules = append(b.modules, modules...)
@@ -179,6 +215,9 @@ func Run(modules ...bar.Module) error {
 	// Mark the bar as started.
 	b.started = true
 	l.Log("Bar started")
+	// Report errors from click actions (e.g. opening URLs) in the same way
+	// as errors from modules.
+	actions.SetErrorHandler(b.errorHandler)
 
 	go func(i <-chan int) {
 		for range i {
@@ -204,10 +243,7 @@ func Run(modules ...bar.Module) error {
 		header.StopSignal = int(unix.SIGUSR1)
 		header.ContSignal = int(unix.SIGUSR2)
 	}
-	// Set up the encoder for the output stream,
-	// so that module outputs can be written directly.
-	b.encoder = json.NewEncoder(b.writer)
-	if err := b.encoder.Encode(&header); err != nil {
+	if err := json.NewEncoder(b.writer).Encode(&header); err != nil {
 		return err
 	}
 	// Start the infinite array.
@@ -227,16 +263,28 @@ func Run(modules ...bar.Module) error {
 				return err
 			}
 		case event := <-b.events:
-			if onClick, ok := b.clickHandlers[event.Name]; ok {
+			if onClick := b.clickHandler(event.Name); onClick != nil {
 				go onClick(event.Event)
 			}
+		case args := <-b.keys:
+			// Print immediately, so that the click handler for the newly
+			// focused module is known before the next command.
+			if b.keyCommand(args) {
+				if err := b.print(); err != nil {
+					return err
+				}
+			}
 		case sig := <-signalChan:
 			switch sig {
 			case unix.SIGUSR1:
 				b.pause()
 			case unix.SIGUSR2:
 				b.resume()
+			case unix.SIGHUP:
+				b.reloadModules()
 			}
+		case <-b.reload:
+			b.reloadModules()
 		case err := <-errChan:
 			return err
 		}
@@ -248,64 +296,52 @@ func DefaultErrorHandler(e bar.ErrorEvent) {
 	exec.Command("i3-nagbar", "-m", e.Error.Error()).Run()
 }
 
-func colorString(c color.Color) string {
-	cful, _ := colorful.MakeColor(c)
-	return cful.Hex()
-}
-
-// i3map serialises the attributes of the Segment in
-// the format used by i3bar.
-func i3map(s *bar.Segment) map[string]interface{} {
-	i3map := make(map[string]interface{})
-	txt, pango := s.Content()
-	i3map["full_text"] = txt
-	if shortText, ok := s.GetShortText(); ok {
-		i3map["short_text"] = shortText
-	}
-	if color, ok := s.GetColor(); ok {
-		i3map["color"] = colorString(color)
-	}
-	if background, ok := s.GetBackground(); ok {
-		i3map["background"] = colorString(background)
-	}
-	if border, ok := s.GetBorder(); ok {
-		i3map["border"] = colorString(border)
-	}
-	if minWidth, ok := s.GetMinWidth(); ok {
-		i3map["min_width"] = minWidth
-	}
-	if align, ok := s.GetAlignment(); ok {
-		i3map["align"] = align
-	}
-	if urgent, ok := s.IsUrgent(); ok {
-		i3map["urgent"] = urgent
-	}
-	if separator, ok := s.HasSeparator(); ok {
-		i3map["separator"] = separator
-	}
-	if padding, ok := s.GetPadding(); ok {
-		i3map["separator_block_width"] = padding
+// print outputs the entire bar, using the last output for each module.
+// If no module's output has changed since the last print, only the click
+// handlers are updated, and nothing is written to i3bar.
+func (b *i3Bar) print() error {
+	// i3bar requires the entire bar to be printed at once, so we just take the
+	// last cached value for each module and construct the current bar.
+	outputs := b.moduleSet.LastOutputs()
+	reuse := b.printed != nil && len(outputs) == len(b.printed) &&
+		b.focus == b.printedFocus
+	if len(outputs) != len(b.printed) {
+		b.printed = make([]printedOutput, len(outputs))
 	}
-	if pango {
-		i3map["markup"] = "pango"
-	} else {
-		i3map["markup"] = "none"
+	changed := !reuse
+	for idx, segments := range outputs {
+		p := &b.printed[idx]
+		if reuse && segmentsEqual(segments, p.segments) {
+			// Keep the latest segments for their click handlers.
+			p.segments = segments
+			continue
+		}
+		changed = true
+		var border color.Color
+		if idx == b.focus {
+			border = b.focusColor
+		}
+		p.segments = segments
+		p.encoded, p.ends = p.encoded[:0], p.ends[:0]
+		for _, segment := range segments {
+			p.encoded = appendSegment(p.encoded, segment, border)
+			p.ends = append(p.ends, len(p.encoded))
+		}
 	}
-	return i3map
-}
+	b.printedFocus = b.focus
 
-// print outputs the entire bar, using the last output for each module.
-func (b *i3Bar) print() error {
 	// Store the set of click handlers for any segments that can handle clicks.
 	// When i3bar sends us the click event, it will include an identifier that
 	// we can use to look up the function to call.
-	b.clickHandlers = map[string]func(bar.Event){}
-	// i3bar requires the entire bar to be printed at once, so we just take the
-	// last cached value for each module and construct the current bar.
-	output := make([]map[string]interface{}, 0)
-	for _, segments := range b.moduleSet.LastOutputs() {
-		for _, segment := range segments {
-			out := i3map(segment)
+	for i := range b.clickHandlers {
+		b.clickHandlers[i] = nil
+	}
+	b.clickHandlers = b.clickHandlers[:0]
+	b.focusClick = nil
+	buf := append(b.buf[:0], '[')
+	for idx, p := range b.printed {
+		start := 0
+		for i, segment := range p.segments {
 			var clickHandler func(bar.Event)
 			if err := segment.GetError(); err != nil {
 				// because go.
@@ -320,21 +356,137 @@ func (b *i3Bar) print() error {
 			} else if segment.HasClick() {
 				clickHandler = segment.Click
 			}
+			if clickHandler != nil && idx == b.focus && b.focusClick == nil {
+				b.focusClick = clickHandler
+			}
+			if !changed {
+				if clickHandler != nil {
+					b.clickHandlers = append(b.clickHandlers, clickHandler)
+				}
+				continue
+			}
+			if len(buf) > 1 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, '{')
 			if clickHandler != nil {
-				name := strconv.Itoa(len(b.clickHandlers))
-				out["name"] = name
-				b.clickHandlers[name] = clickHandler
+				buf = append(buf, `"name":"`...)
+				buf = strconv.AppendInt(buf, int64(len(b.clickHandlers)), 10)
+				buf = append(buf, `",`...)
+				b.clickHandlers = append(b.clickHandlers, clickHandler)
 			}
-			output = append(output, out)
+			buf = append(buf, p.encoded[start:p.ends[i]]...)
+			buf = append(buf, '}')
+			start = p.ends[i]
 		}
 	}
-	if err := b.encoder.Encode(output); err != nil {
-		return err
+	if !changed {
+		b.emitDebugEvent(dEvtUnchanged, "")
+		return nil
 	}
-	_, err := io.WriteString(b.writer, ",\n")
+	buf = append(buf, "],\n"...)
+	b.buf = buf
+	_, err := b.writer.Write(buf)
 	return err
 }
 
+// clickHandler returns the click handler for the given segment name, or nil
+// if the name is not from the last print.
+func (b *i3Bar) clickHandler(name string) func(bar.Event) {
+	idx, err := strconv.Atoi(name)
+	if err != nil || idx < 0 || idx >= len(b.clickHandlers) {
+		return nil
+	}
+	return b.clickHandlers[idx]
+}
+
+// printedOutput is the output of a module the last time the bar was printed.
+type printedOutput struct {
+	segments bar.Segments
+	// The i3bar attributes of all segments, without names since those depend
+	// on the click handlers of other modules. ends has the end offset in
+	// encoded of each segment.
+	encoded []byte
+	ends    []int
+}
+
+// segmentsEqual returns true if two outputs would be printed identically.
+// Click handlers cannot be compared, so they are always taken from the
+// latest output.
+func segmentsEqual(s, o bar.Segments) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for i := range s {
+		if !segmentEqual(s[i], o[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func segmentEqual(s, o *bar.Segment) bool {
+	sText, sPango := s.Content()
+	oText, oPango := o.Content()
+	if sText != oText || sPango != oPango {
+		return false
+	}
+	sShort, sOk := s.GetShortText()
+	oShort, oOk := o.GetShortText()
+	if sShort != oShort || sOk != oOk {
+		return false
+	}
+	for _, c := range [][2]func() (color.Color, bool){
+		{s.GetColor, o.GetColor},
+		{s.GetBackground, o.GetBackground},
+		{s.GetBorder, o.GetBorder},
+	} {
+		if !colorEqual(c[0], c[1]) {
+			return false
+		}
+	}
+	sMinWidth, _ := s.GetMinWidth()
+	oMinWidth, _ := o.GetMinWidth()
+	sAlign, _ := s.GetAlignment()
+	oAlign, _ := o.GetAlignment()
+	if sMinWidth != oMinWidth || sAlign != oAlign {
+		return false
+	}
+	sUrgent, sOk := s.IsUrgent()
+	oUrgent, oOk := o.IsUrgent()
+	if sUrgent != oUrgent || sOk != oOk {
+		return false
+	}
+	sSep, sOk := s.HasSeparator()
+	oSep, oOk := o.HasSeparator()
+	if sSep != oSep || sOk != oOk {
+		return false
+	}
+	sPadding, sOk := s.GetPadding()
+	oPadding, oOk := o.GetPadding()
+	if sPadding != oPadding || sOk != oOk {
+		return false
+	}
+	// Only whether there is a click handler affects the output, since the
+	// handlers themselves are updated on every print.
+	return s.HasClick() == o.HasClick() &&
+		(s.GetError() == nil) == (o.GetError() == nil)
+}
+
+func colorEqual(s, o func() (color.Color, bool)) bool {
+	sColor, sOk := s()
+	oColor, oOk := o()
+	if sOk != oOk {
+		return false
+	}
+	if !sOk {
+		return true
+	}
+	sr, sg, sb, sa := sColor.RGBA()
+	or, og, ob, oa := oColor.RGBA()
+	return sr == or && sg == og && sb == ob && sa == oa
+}
+
 // readEvents parses the infinite stream of events received from i3.
 func (b *i3Bar) readEvents() error {
 	decoder := json.NewDecoder(b.reader)
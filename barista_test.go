@@ -143,8 +143,62 @@ func TestEmptyOutputs(t *testing.T) {
 
 	module2.AssertStarted()
 	module2.Output(nil)
-	out = readOutputTexts(t, mockStdout)
-	require.Empty(t, out, "all modules are empty")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"no output when still empty, got %s", mockStdout.ReadNow())
+}
+
+func TestUnchangedOutput(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	module1 := testModule.New(t).SkipClickHandlers()
+	module2 := testModule.New(t)
+	go Run(module1, module2)
+
+	_, err := mockStdout.ReadUntil('[', time.Second)
+	require.Nil(t, err, "output array started without any errors")
+	mockStdin.WriteString("[")
+
+	module1.AssertStarted()
+	module2.AssertStarted()
+
+	clicks := make(chan string, 10)
+	clickable := func(text, name string) bar.Output {
+		return bar.TextSegment(text).OnClick(func(bar.Event) { clicks <- name })
+	}
+
+	module1.Output(clickable("a", "first"))
+	out := readOutput(t, mockStdout)
+	require.Equal(t, "a", out[0]["full_text"])
+	name := out[0]["name"].(string)
+
+	module2.OutputText("b")
+	readOutput(t, mockStdout)
+
+	module1.Output(clickable("a", "second"))
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"no output when nothing changed, got %s", mockStdout.ReadNow())
+
+	mockStdin.WriteString(fmt.Sprintf("{\"name\": \"%s\"},", name))
+	select {
+	case c := <-clicks:
+		require.Equal(t, "second", c, "click handler is updated")
+	case <-time.After(time.Second):
+		require.Fail(t, "click handler not called")
+	}
+
+	module1.Output(outputs.Text("a").Color(color.RGBA{0xff, 0, 0, 0xff}))
+	out = readOutput(t, mockStdout)
+	require.Equal(t, "#ff0000", out[0]["color"], "output when attributes change")
+
+	module2.OutputText("b")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"no output when nothing changed, got %s", mockStdout.ReadNow())
+
+	module2.OutputText("c")
+	require.Equal(t, []string{"a", "c"}, readOutputTexts(t, mockStdout),
+		"output when text changes")
 }
 
 func TestMultipleModules(t *testing.T) {
@@ -333,6 +387,7 @@ func TestClickEvents(t *testing.T) {
 	mockStdin := mockio.Stdin()
 	mockStdout := mockio.Stdout()
 	TestMode(mockStdin, mockStdout)
+	unchanged := debugEvents(dEvtUnchanged)
 
 	module1 := testModule.New(t)
 	module2 := testModule.New(t)
@@ -401,7 +456,8 @@ func TestClickEvents(t *testing.T) {
 	module2.AssertClicked("events are received after the weird name")
 
 	module1.Close()
-	readOutput(t, mockStdout)
+	require.Equal(t, dEvtUnchanged, (<-unchanged).kind,
+		"click handlers updated on close")
 
 	mockStdin.WriteString(fmt.Sprintf("{\"name\": \"%s\"},", module2Name))
 	module2.AssertClicked()
@@ -481,6 +537,7 @@ func TestErrorHandling(t *testing.T) {
 	TestMode(mockStdin, mockStdout)
 	errChan := make(chan bar.ErrorEvent)
 	SetErrorHandler(func(e bar.ErrorEvent) { errChan <- e })
+	unchanged := debugEvents(dEvtUnchanged)
 
 	module := testModule.New(t)
 	go Run(module)
@@ -521,8 +578,10 @@ func TestErrorHandling(t *testing.T) {
 		"click events do not cause any updates")
 
 	module.Close()
-	require.Equal(t, []string{"Error", "regular", "other"}, readOutputTexts(t, mockStdout),
-		"new outputs on module close (for updated click handling)")
+	require.Equal(t, dEvtUnchanged, (<-unchanged).kind,
+		"click handlers updated on module close")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"unchanged output is not printed again")
 
 	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "button": 3},`, errorSegmentName))
 	module.AssertNotClicked("on right click of error segment")
@@ -543,8 +602,8 @@ func TestErrorHandling(t *testing.T) {
 	require.Equal(t, 3, len(out), "All segments in output")
 
 	module.Close()
-	out = readOutput(t, mockStdout)
-	require.Equal(t, 3, len(out), "All segments in output")
+	require.Equal(t, dEvtUnchanged, (<-unchanged).kind,
+		"click handlers updated on module close")
 
 	regularSegmentName = out[1]["name"].(string)
 	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "button": 1},`, regularSegmentName))
@@ -645,14 +704,17 @@ type segmentAssertions struct {
 }
 
 func (s segmentAssertions) AssertEqual(message string) {
+	encoded := append(appendSegment([]byte{'{'}, s.actual, nil), '}')
+	var decoded map[string]interface{}
+	require.NoError(s.T, json.Unmarshal(encoded, &decoded), "%s: %s", message, encoded)
 	actualMap := make(map[string]string)
-	for k, v := range i3map(s.actual) {
+	for k, v := range decoded {
 		actualMap[k] = fmt.Sprintf("%v", v)
 	}
 	require.Equal(s.T, s.Expected, actualMap, message)
 }
 
-func TestI3Map(t *testing.T) {
+func TestEncodeSegment(t *testing.T) {
 	segment := bar.TextSegment("test")
 	a := segmentAssertions{t, segment, make(map[string]string)}
 
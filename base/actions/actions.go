@@ -0,0 +1,97 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package actions provides common actions for click handlers, such as opening
+// a URL in the user's browser, with errors reported back to the bar.
+package actions // import "barista.run/base/actions"
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+)
+
+var (
+	errorHandler   = logError
+	errorHandlerMu sync.Mutex
+)
+
+func logError(e bar.ErrorEvent) {
+	l.Log("Action failed: %v", e.Error)
+}
+
+// SetErrorHandler sets the function called when an action triggered by a click
+// fails. barista.Run sets this to the bar's error handler, so that failures
+// are shown in the same way as errors from modules.
+func SetErrorHandler(handler func(bar.ErrorEvent)) {
+	if handler == nil {
+		handler = logError
+	}
+	errorHandlerMu.Lock()
+	defer errorHandlerMu.Unlock()
+	errorHandler = handler
+}
+
+// Report sends an error from an action triggered by the given click event to
+// the error handler, and records it for diagnostics.
+func Report(err error, e bar.Event) {
+	l.Error("action", "%v", err)
+	errorHandlerMu.Lock()
+	handler := errorHandler
+	errorHandlerMu.Unlock()
+	handler(bar.ErrorEvent{Error: err, Event: e})
+}
+
+// The command used to open URLs, for tests.
+var xdgOpen = "xdg-open"
+
+// OpenURL opens the given URL using xdg-open, which uses the user's preferred
+// application (usually a web browser). The command is started in a new session,
+// so that the application it launches is not tied to the bar's process and
+// continues running if the bar is restarted.
+func OpenURL(url string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(xdgOpen, url)
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("could not open %s: %v: %s", url, err, msg)
+	}
+	return fmt.Errorf("could not open %s: %v", url, err)
+}
+
+// OnClickOpen creates a click handler that opens the given URL on left click,
+// reporting any errors to the bar. For example:
+//
+//	outputs.Text("GitHub").OnClick(actions.OnClickOpen("https://github.com"))
+func OnClickOpen(url string) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button != bar.ButtonLeft {
+			return
+		}
+		if err := OpenURL(url); err != nil {
+			Report(err, e)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"barista.run/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeXdgOpen replaces xdg-open with a script that records the URL it was
+// called with, and returns the path to the recorded URL.
+func fakeXdgOpen(t *testing.T, dir, script string) string {
+	out := filepath.Join(dir, "opened")
+	xdgOpen = filepath.Join(dir, "xdg-open")
+	err := ioutil.WriteFile(xdgOpen,
+		[]byte("#!/bin/sh\necho \"$1\" > "+out+"\n"+script), 0700)
+	require.NoError(t, err)
+	return out
+}
+
+func opened(t *testing.T, file string) string {
+	contents, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	require.NoError(t, err)
+	os.Remove(file)
+	return string(contents)
+}
+
+func TestOpenURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "actions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := fakeXdgOpen(t, dir, "exit 0")
+	require.NoError(t, OpenURL("https://example.com"))
+	require.Equal(t, "https://example.com\n", opened(t, out))
+
+	out = fakeXdgOpen(t, dir, "echo 'no method available' >&2; exit 3")
+	err = OpenURL("https://example.org")
+	require.EqualError(t, err,
+		"could not open https://example.org: exit status 3: no method available")
+	require.Equal(t, "https://example.org\n", opened(t, out))
+
+	xdgOpen = filepath.Join(dir, "does-not-exist")
+	require.Error(t, OpenURL("https://example.net"))
+}
+
+func TestOnClickOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "actions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	errs := make(chan bar.ErrorEvent, 10)
+	SetErrorHandler(func(e bar.ErrorEvent) { errs <- e })
+	defer SetErrorHandler(nil)
+
+	out := fakeXdgOpen(t, dir, "exit 0")
+	handler := OnClickOpen("https://example.com")
+	for _, btn := range []bar.Button{
+		bar.ButtonRight, bar.ButtonMiddle, bar.ScrollUp, bar.ButtonBack,
+	} {
+		handler(bar.Event{Button: btn})
+	}
+	require.Equal(t, "", opened(t, out), "only opens on left click")
+
+	handler(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, "https://example.com\n", opened(t, out))
+	require.Empty(t, errs, "no errors on success")
+
+	fakeXdgOpen(t, dir, "exit 1")
+	handler(bar.Event{Button: bar.ButtonLeft, X: 42})
+	e := <-errs
+	require.EqualError(t, e.Error, "could not open https://example.com: exit status 1")
+	require.Equal(t, 42, e.Event.X, "error includes click event")
+
+	SetErrorHandler(nil)
+	require.NotPanics(t, func() { handler(bar.Event{Button: bar.ButtonLeft}) },
+		"with default error handler")
+}
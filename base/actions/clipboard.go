@@ -0,0 +1,73 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"barista.run/bar"
+)
+
+// clipboardCommands returns the commands that can be used to copy text to the
+// clipboard, in order of preference.
+func clipboardCommands() [][]string {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return [][]string{{"wl-copy"}}
+	}
+	return [][]string{
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	}
+}
+
+// CopyToClipboard copies the given text to the clipboard, using wl-copy on
+// Wayland, and xclip or xsel (the CLIPBOARD selection) on X11.
+func CopyToClipboard(text string) error {
+	for _, c := range clipboardCommands() {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		// The clipboard tools fork a process that keeps running to serve the
+		// selection, so its output cannot be captured without waiting for it.
+		// It is started in a new session so that the clipboard contents are
+		// not lost if the bar is restarted.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("could not copy to clipboard: %s: %v", c[0], err)
+		}
+		return nil
+	}
+	return errors.New("could not copy to clipboard: install wl-copy, xclip, or xsel")
+}
+
+// OnClickCopy creates a click handler that copies the given text to the
+// clipboard on left click, reporting any errors to the bar.
+func OnClickCopy(text string) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button != bar.ButtonLeft {
+			return
+		}
+		if err := CopyToClipboard(text); err != nil {
+			Report(err, e)
+		}
+	}
+}
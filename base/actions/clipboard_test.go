@@ -0,0 +1,103 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"barista.run/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClipboard sets up PATH with a fake clipboard command that saves its
+// arguments and input, and returns the file containing them.
+func fakeClipboard(t *testing.T, dir, name, script string) string {
+	out := filepath.Join(dir, name+".out")
+	err := ioutil.WriteFile(filepath.Join(dir, name), []byte(
+		"#!/bin/sh\necho \"$@\" > "+out+"\n/bin/cat >> "+out+"\n"+script), 0700)
+	require.NoError(t, err)
+	return out
+}
+
+func withEnv(key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestCopyToClipboard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer withEnv("PATH", dir)()
+	defer withEnv("WAYLAND_DISPLAY", "")()
+
+	require.EqualError(t, CopyToClipboard("foo"),
+		"could not copy to clipboard: install wl-copy, xclip, or xsel")
+
+	xsel := fakeClipboard(t, dir, "xsel", "")
+	require.NoError(t, CopyToClipboard("foo"))
+	require.Equal(t, "--clipboard --input\nfoo", opened(t, xsel))
+
+	xclip := fakeClipboard(t, dir, "xclip", "")
+	require.NoError(t, CopyToClipboard("bar"))
+	require.Equal(t, "-selection clipboard\nbar", opened(t, xclip))
+	require.Equal(t, "", opened(t, xsel), "prefers xclip")
+
+	os.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	require.Error(t, CopyToClipboard("baz"), "wl-copy not installed")
+	wlCopy := fakeClipboard(t, dir, "wl-copy", "exit 0")
+	require.NoError(t, CopyToClipboard("baz"))
+	require.Equal(t, "\nbaz", opened(t, wlCopy))
+
+	fakeClipboard(t, dir, "wl-copy", "exit 1")
+	require.EqualError(t, CopyToClipboard("baz"),
+		"could not copy to clipboard: wl-copy: exit status 1")
+}
+
+func TestOnClickCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer withEnv("PATH", dir)()
+	defer withEnv("WAYLAND_DISPLAY", "wayland-0")()
+
+	errs := make(chan bar.ErrorEvent, 10)
+	SetErrorHandler(func(e bar.ErrorEvent) { errs <- e })
+	defer SetErrorHandler(nil)
+
+	out := fakeClipboard(t, dir, "wl-copy", "")
+	handler := OnClickCopy("2018-10-05T12:00:00Z")
+	handler(bar.Event{Button: bar.ButtonRight})
+	require.Equal(t, "", opened(t, out), "only copies on left click")
+
+	handler(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, "\n2018-10-05T12:00:00Z", opened(t, out))
+	require.Empty(t, errs)
+
+	os.Remove(filepath.Join(dir, "wl-copy"))
+	handler(bar.Event{Button: bar.ButtonLeft})
+	require.Error(t, (<-errs).Error)
+}
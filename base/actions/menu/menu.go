@@ -0,0 +1,159 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package menu shows a list of labelled actions using a launcher such as rofi,
+wofi, or dmenu, and runs the action chosen by the user. This allows modules to
+offer several actions on click without needing their own UI. For example:
+
+	systemd.Service("foo").Output(func(i systemd.ServiceInfo) bar.Output {
+		return outputs.Text(string(i.State)).OnClick(menu.Click("foo",
+			menu.Item{Label: "Start", Action: i.Start},
+			menu.Item{Label: "Stop", Action: i.Stop},
+			menu.Item{Label: "Restart", Action: i.Restart},
+		))
+	})
+*/
+package menu // import "barista.run/base/actions/menu"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"barista.run/bar"
+	"barista.run/base/actions"
+)
+
+// Item is a labelled action in a menu.
+type Item struct {
+	Label  string
+	Action func()
+}
+
+// Launcher creates the command used to show a menu with the given prompt.
+// The command must read the menu items from stdin, one per line, and print
+// the selected item to stdout.
+type Launcher func(prompt string) *exec.Cmd
+
+// Rofi shows menus using rofi in dmenu mode.
+func Rofi(prompt string) *exec.Cmd {
+	return exec.Command("rofi", "-dmenu", "-i", "-p", prompt)
+}
+
+// Wofi shows menus using wofi in dmenu mode.
+func Wofi(prompt string) *exec.Cmd {
+	return exec.Command("wofi", "--dmenu", "--insensitive", "--prompt", prompt)
+}
+
+// Dmenu shows menus using dmenu.
+func Dmenu(prompt string) *exec.Cmd {
+	return exec.Command("dmenu", "-i", "-p", prompt)
+}
+
+var (
+	launcher   Launcher
+	launcherMu sync.Mutex
+)
+
+// For tests.
+var lookPath = exec.LookPath
+
+// SetLauncher sets the launcher used to show menus. By default, the first of
+// rofi, wofi, and dmenu that is installed is used.
+func SetLauncher(l Launcher) {
+	launcherMu.Lock()
+	defer launcherMu.Unlock()
+	launcher = l
+}
+
+func getLauncher() (Launcher, error) {
+	launcherMu.Lock()
+	defer launcherMu.Unlock()
+	if launcher != nil {
+		return launcher, nil
+	}
+	for _, l := range []struct {
+		name string
+		Launcher
+	}{{"rofi", Rofi}, {"wofi", Wofi}, {"dmenu", Dmenu}} {
+		if _, err := lookPath(l.name); err == nil {
+			return l.Launcher, nil
+		}
+	}
+	return nil, errors.New("no menu launcher found, install rofi, wofi, or dmenu")
+}
+
+// Show shows a menu with the given items, and runs the action for the item
+// selected by the user. Nothing is run if the menu is dismissed.
+func Show(prompt string, items ...Item) error {
+	l, err := getLauncher()
+	if err != nil {
+		return err
+	}
+	var labels bytes.Buffer
+	for _, i := range items {
+		labels.WriteString(i.Label)
+		labels.WriteByte('\n')
+	}
+	var stderr bytes.Buffer
+	cmd := l(prompt)
+	cmd.Stdin = &labels
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	selected := strings.TrimRight(string(out), "\n")
+	if selected == "" {
+		// Launchers exit with an error when the menu is dismissed, which is
+		// only a real error if there was a message.
+		if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		if _, ok := err.(*exec.ExitError); err != nil && !ok {
+			return err
+		}
+		return nil
+	}
+	for _, i := range items {
+		if i.Label == selected {
+			if i.Action != nil {
+				i.Action()
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown menu item %q", selected)
+}
+
+// Click creates a click handler that shows a menu with the given items on
+// left click. Any errors are reported to the bar.
+func Click(prompt string, items ...Item) func(bar.Event) {
+	return ClickFunc(prompt, func() []Item { return items })
+}
+
+// ClickFunc creates a click handler that shows a menu with the items returned
+// by the given function on left click, for menus that depend on the current
+// state. Any errors are reported to the bar.
+func ClickFunc(prompt string, items func() []Item) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button != bar.ButtonLeft {
+			return
+		}
+		if err := Show(prompt, items()...); err != nil {
+			actions.Report(err, e)
+		}
+	}
+}
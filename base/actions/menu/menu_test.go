@@ -0,0 +1,131 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package menu
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/base/actions"
+
+	"github.com/stretchr/testify/require"
+)
+
+// script returns a launcher that runs the given shell script, with the
+// prompt available as $1.
+func script(s string) Launcher {
+	return func(prompt string) *exec.Cmd {
+		return exec.Command("sh", "-c", s, "sh", prompt)
+	}
+}
+
+type recorder []string
+
+func (r *recorder) item(label string) Item {
+	return Item{label, func() { *r = append(*r, label) }}
+}
+
+func TestShow(t *testing.T) {
+	var r recorder
+	items := []Item{r.item("Start"), r.item("Stop"), r.item("Restart")}
+
+	SetLauncher(script(`test "$1" = "unit" && sed -n 2p`))
+	require.NoError(t, Show("unit", items...))
+	require.Equal(t, recorder{"Stop"}, r, "runs selected action")
+
+	SetLauncher(script(`tail -n 1`))
+	require.NoError(t, Show("unit", items...))
+	require.Equal(t, recorder{"Stop", "Restart"}, r)
+
+	SetLauncher(script(`exit 1`))
+	require.NoError(t, Show("unit", items...), "when dismissed")
+	require.Len(t, r, 2, "nothing run when dismissed")
+
+	SetLauncher(script(`echo "cannot open display" >&2; exit 1`))
+	require.EqualError(t, Show("unit", items...),
+		"exit status 1: cannot open display")
+
+	SetLauncher(script(`echo "Other"`))
+	require.EqualError(t, Show("unit", items...), `unknown menu item "Other"`)
+	require.Len(t, r, 2)
+
+	SetLauncher(func(string) *exec.Cmd { return exec.Command("/does/not/exist") })
+	require.Error(t, Show("unit", items...), "when launcher is not installed")
+
+	SetLauncher(script(`echo "Nothing"`))
+	require.NoError(t, Show("unit", Item{Label: "Nothing"}),
+		"item without an action")
+}
+
+func TestDefaultLauncher(t *testing.T) {
+	defer func() { lookPath = exec.LookPath }()
+	SetLauncher(nil)
+
+	installed := map[string]bool{}
+	lookPath = func(name string) (string, error) {
+		if installed[name] {
+			return "/usr/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	_, err := getLauncher()
+	require.Error(t, err, "with no launchers installed")
+
+	installed["dmenu"] = true
+	l, err := getLauncher()
+	require.NoError(t, err)
+	require.Equal(t, []string{"dmenu", "-i", "-p", "x"}, l("x").Args)
+
+	installed["wofi"] = true
+	l, _ = getLauncher()
+	require.Equal(t, "wofi", l("x").Args[0])
+
+	installed["rofi"] = true
+	l, _ = getLauncher()
+	require.Equal(t, []string{"rofi", "-dmenu", "-i", "-p", "x"}, l("x").Args)
+}
+
+func TestClick(t *testing.T) {
+	errs := make(chan bar.ErrorEvent, 10)
+	actions.SetErrorHandler(func(e bar.ErrorEvent) { errs <- e })
+	defer actions.SetErrorHandler(nil)
+
+	var r recorder
+	SetLauncher(script(`head -n 1`))
+	handler := Click("prompt", r.item("A"), r.item("B"))
+	handler(bar.Event{Button: bar.ButtonRight})
+	handler(bar.Event{Button: bar.ScrollUp})
+	require.Empty(t, r, "only shown on left click")
+
+	handler(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, recorder{"A"}, r)
+
+	current := []Item{r.item("C")}
+	dynamic := ClickFunc("prompt", func() []Item { return current })
+	dynamic(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, recorder{"A", "C"}, r)
+	current = []Item{r.item("D")}
+	dynamic(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, recorder{"A", "C", "D"}, r, "uses current items")
+	require.Empty(t, errs)
+
+	SetLauncher(script(`echo "failed" >&2; exit 2`))
+	handler(bar.Event{Button: bar.ButtonLeft})
+	e := <-errs
+	require.EqualError(t, e.Error, "exit status 2: failed")
+}
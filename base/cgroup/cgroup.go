@@ -0,0 +1,198 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cgroup reads the resource limits of the cgroup (v2) that barista is
+// running in, e.g. a container or a systemd slice with limits, so that
+// modules can show usage relative to the limits instead of the whole host.
+package cgroup // import "barista.run/base/cgroup"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+)
+
+// Limits holds the effective limits and current usage of a cgroup.
+// The effective limit is the lowest limit set on the cgroup or any of its
+// ancestors.
+type Limits struct {
+	// CPUs is the number of CPUs worth of time that the cgroup can use, from
+	// cpu.max, or 0 if CPU time is not limited.
+	CPUs float64
+	// MemoryMax is the memory limit from memory.max, or 0 if memory is not
+	// limited.
+	MemoryMax unit.Datasize
+	// MemoryCurrent is the memory currently used by the cgroup, including
+	// the page cache.
+	MemoryCurrent unit.Datasize
+	// MemoryReclaimable is the part of MemoryCurrent that can be reclaimed
+	// without swapping (inactive_file in memory.stat).
+	MemoryReclaimable unit.Datasize
+}
+
+// MemoryAvailable returns the memory that can still be used before reaching
+// the memory limit, counting reclaimable memory as available.
+// Returns 0 if memory is not limited.
+func (l Limits) MemoryAvailable() unit.Datasize {
+	if l.MemoryMax == 0 {
+		return 0
+	}
+	avail := l.MemoryMax - l.MemoryCurrent + l.MemoryReclaimable
+	if avail < 0 {
+		return 0
+	}
+	if avail > l.MemoryMax {
+		return l.MemoryMax
+	}
+	return avail
+}
+
+const (
+	procSelf = "/proc/self/cgroup"
+	root     = "/sys/fs/cgroup"
+)
+
+var fs = afero.NewOsFs()
+
+// Read returns the limits of the cgroup of the current process. If the
+// process is not in a cgroup v2 hierarchy, it returns empty limits.
+func Read() (Limits, error) {
+	var lim Limits
+	dir, err := selfCgroup()
+	if dir == "" || err != nil {
+		return lim, err
+	}
+	if lim.MemoryCurrent, err = readBytes(path.Join(dir, "memory.current")); err != nil {
+		return lim, err
+	}
+	if lim.MemoryReclaimable, err = readInactiveFile(path.Join(dir, "memory.stat")); err != nil {
+		return lim, err
+	}
+	for ; strings.HasPrefix(dir, root); dir = path.Dir(dir) {
+		mem, err := readBytes(path.Join(dir, "memory.max"))
+		if err != nil {
+			return lim, err
+		}
+		if mem > 0 && (lim.MemoryMax == 0 || mem < lim.MemoryMax) {
+			lim.MemoryMax = mem
+		}
+		cpus, err := readCPUMax(path.Join(dir, "cpu.max"))
+		if err != nil {
+			return lim, err
+		}
+		if cpus > 0 && (lim.CPUs == 0 || cpus < lim.CPUs) {
+			lim.CPUs = cpus
+		}
+	}
+	return lim, nil
+}
+
+// selfCgroup returns the cgroup directory of the current process, using the
+// unified (v2) entry of /proc/self/cgroup, which has the form "0::/path".
+func selfCgroup() (string, error) {
+	f, err := fs.Open(procSelf)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "0::") {
+			return path.Join(root, line[len("0::"):]), nil
+		}
+	}
+	return "", s.Err()
+}
+
+// readFile reads a cgroup interface file, returning nil if it does not
+// exist, which is the case for controllers not enabled for the cgroup.
+func readFile(file string) ([]byte, error) {
+	contents, err := afero.ReadFile(fs, file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return bytes.TrimSpace(contents), err
+}
+
+// readBytes reads a file containing a number of bytes or "max", returning 0
+// for "max" or if the file does not exist.
+func readBytes(file string) (unit.Datasize, error) {
+	contents, err := readFile(file)
+	if len(contents) == 0 || string(contents) == "max" || err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseUint(string(contents), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", file, err)
+	}
+	return unit.Datasize(val) * unit.Byte, nil
+}
+
+// readCPUMax reads a cpu.max file, which has the form "$MAX $PERIOD", and
+// returns the number of CPUs it allows, or 0 if the file does not exist or
+// $MAX is "max".
+func readCPUMax(file string) (float64, error) {
+	contents, err := readFile(file)
+	if len(contents) == 0 || err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(contents))
+	if fields[0] == "max" {
+		return 0, nil
+	}
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("%s: unexpected contents %q", file, contents)
+	}
+	quota, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", file, err)
+	}
+	period, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil || period == 0 {
+		return 0, fmt.Errorf("%s: invalid period %q", file, fields[1])
+	}
+	return float64(quota) / float64(period), nil
+}
+
+// readInactiveFile reads the inactive_file entry from a memory.stat file.
+func readInactiveFile(file string) (unit.Datasize, error) {
+	contents, err := readFile(file)
+	if err != nil {
+		return 0, err
+	}
+	s := bufio.NewScanner(bytes.NewReader(contents))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 || fields[0] != "inactive_file" {
+			continue
+		}
+		val, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", file, err)
+		}
+		return unit.Datasize(val) * unit.Byte, nil
+	}
+	return 0, nil
+}
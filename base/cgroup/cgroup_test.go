@@ -0,0 +1,115 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroup
+
+import (
+	"testing"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(files map[string]string) {
+	fs = afero.NewMemMapFs()
+	for name, contents := range files {
+		afero.WriteFile(fs, name, []byte(contents), 0644)
+	}
+}
+
+func TestNoCgroup(t *testing.T) {
+	writeFiles(nil)
+	lim, err := Read()
+	require.NoError(t, err)
+	require.Equal(t, Limits{}, lim, "without /proc/self/cgroup")
+
+	writeFiles(map[string]string{
+		procSelf: "12:memory:/user.slice\n1:name=systemd:/user.slice\n",
+	})
+	lim, err = Read()
+	require.NoError(t, err)
+	require.Equal(t, Limits{}, lim, "with only cgroup v1")
+}
+
+func TestUnlimited(t *testing.T) {
+	writeFiles(map[string]string{
+		procSelf:                        "0::/user.slice/session-2.scope\n",
+		root + "/user.slice/memory.max": "max\n",
+		root + "/user.slice/cpu.max":    "max 100000\n",
+		root + "/user.slice/session-2.scope/memory.current": "1048576\n",
+		root + "/user.slice/session-2.scope/memory.max":     "max\n",
+	})
+	lim, err := Read()
+	require.NoError(t, err)
+	require.Equal(t, Limits{MemoryCurrent: unit.Mebibyte}, lim)
+	require.Equal(t, unit.Datasize(0), lim.MemoryAvailable())
+}
+
+func TestEffectiveLimits(t *testing.T) {
+	writeFiles(map[string]string{
+		procSelf:                     "1:name=systemd:/\n0::/a/b\n",
+		root + "/a/memory.max":       "1073741824\n",
+		root + "/a/cpu.max":          "150000 100000\n",
+		root + "/a/b/memory.max":     "2147483648\n",
+		root + "/a/b/cpu.max":        "200000 100000\n",
+		root + "/a/b/memory.current": "805306368\n",
+		root + "/a/b/memory.stat":    "anon 1234\nfile 5678\ninactive_file 134217728\n",
+	})
+	lim, err := Read()
+	require.NoError(t, err)
+	require.Equal(t, Limits{
+		CPUs:              1.5,
+		MemoryMax:         unit.Gibibyte,
+		MemoryCurrent:     768 * unit.Mebibyte,
+		MemoryReclaimable: 128 * unit.Mebibyte,
+	}, lim, "lowest limit of the cgroup and its ancestors")
+	require.Equal(t, 384*unit.Mebibyte, lim.MemoryAvailable())
+
+	lim.MemoryCurrent = 2 * unit.Gibibyte
+	require.Equal(t, unit.Datasize(0), lim.MemoryAvailable(),
+		"when usage is over the limit")
+}
+
+func TestContainer(t *testing.T) {
+	writeFiles(map[string]string{
+		procSelf:                 "0::/\n",
+		root + "/memory.max":     "536870912",
+		root + "/memory.current": "268435456",
+		root + "/cpu.max":        "50000 100000",
+	})
+	lim, err := Read()
+	require.NoError(t, err)
+	require.Equal(t, Limits{
+		CPUs:          0.5,
+		MemoryMax:     512 * unit.Mebibyte,
+		MemoryCurrent: 256 * unit.Mebibyte,
+	}, lim)
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []map[string]string{
+		{root + "/memory.current": "lots"},
+		{root + "/memory.max": "-1"},
+		{root + "/cpu.max": "100000"},
+		{root + "/cpu.max": "x 100000"},
+		{root + "/cpu.max": "100000 0"},
+		{root + "/memory.stat": "inactive_file many"},
+	} {
+		tc[procSelf] = "0::/\n"
+		writeFiles(tc)
+		_, err := Read()
+		require.Error(t, err, "with %v", tc)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import (
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBind(t *testing.T) {
+	m := testModule.New(t)
+	left, leftClicked := makeHandler()
+	b := Bind(m, Map{}.LeftE(left))
+
+	outs := make(chan bar.Output, 10)
+	go b.Stream(func(o bar.Output) { outs <- o })
+	m.AssertStarted()
+
+	m.Output(outputs.Group(
+		outputs.Text("a"),
+		outputs.Text("b").OnClick(func(bar.Event) {}),
+	))
+	out := (<-outs).Segments()
+	require.Len(t, out, 2)
+
+	out[1].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, bar.ButtonLeft, leftClicked(), "uses bound handler")
+	m.AssertNotClicked("bound buttons not sent to module")
+
+	out[0].Click(bar.Event{Button: bar.ScrollUp})
+	require.Equal(t, notClicked, leftClicked())
+	e := m.AssertClicked("unbound buttons sent to module")
+	require.Equal(t, bar.ScrollUp, e.Button)
+
+	m.Output(outputs.Errorf("something went wrong"))
+	out = (<-outs).Segments()
+	require.Error(t, out[0].GetError())
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, notClicked, leftClicked(), "error segments unchanged")
+}
+
+func TestBindElse(t *testing.T) {
+	m := testModule.New(t)
+	other, otherClicked := makeHandler()
+	b := Bind(m, Map{}.Else(other))
+
+	outs := make(chan bar.Output, 10)
+	go b.Stream(func(o bar.Output) { outs <- o })
+	m.AssertStarted()
+	m.Output(outputs.Text("a"))
+	out := (<-outs).Segments()
+
+	out[0].Click(bar.Event{Button: bar.ScrollDown})
+	require.Equal(t, bar.ScrollDown, otherClicked())
+	m.AssertNotClicked("all buttons handled by map")
+}
+
+type refreshableModule struct {
+	*testModule.TestModule
+	refreshed chan bool
+}
+
+func (r refreshableModule) Refresh() { r.refreshed <- true }
+
+func TestBindRefresh(t *testing.T) {
+	m := refreshableModule{testModule.New(t), make(chan bool, 1)}
+	b := Bind(m, Map{})
+	r, ok := b.(bar.RefresherModule)
+	require.True(t, ok, "bound module is refreshable")
+	r.Refresh()
+	require.True(t, <-m.refreshed, "refresh is forwarded")
+
+	_, ok = Bind(testModule.New(t), Map{}).(bar.RefresherModule)
+	require.False(t, ok, "when the original module is not refreshable")
+}
+
+type removableModule struct {
+	*testModule.TestModule
+	removed chan bool
+}
+
+func (r removableModule) Remove() { r.removed <- true }
+
+func TestBindRemove(t *testing.T) {
+	m := removableModule{testModule.New(t), make(chan bool, 1)}
+	b := Bind(m, Map{})
+	r, ok := b.(bar.RemovableModule)
+	require.True(t, ok, "bound module is removable")
+	r.Remove()
+	require.True(t, <-m.removed, "remove is forwarded")
+
+	Bind(testModule.New(t), Map{}).(bar.RemovableModule).Remove()
+}
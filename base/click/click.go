@@ -67,11 +67,41 @@ func ButtonE(handler func(bar.Event), btns ...bar.Button) func(bar.Event) {
 	}
 }
 
+// RunCommand executes the given command when the specified button is clicked.
+// The command is run synchronously, but each click is handled separately, so
+// long-running commands (e.g. GUI applications) do not block other clicks.
+func RunCommand(btn bar.Button, cmd string, args ...string) func(bar.Event) {
+	return Button(func(bar.Button) {
+		exec.Command(cmd, args...).Run()
+	}, btn)
+}
+
 // RunLeft executes the given command on a left-click. This is a shortcut for
 // click.Left(func(){exec.Command(cmd).Run()}).
 func RunLeft(cmd string, args ...string) func(bar.Event) {
-	return Left(func() {
-		exec.Command(cmd, args...).Run()
+	return RunCommand(bar.ButtonLeft, cmd, args...)
+}
+
+// RunRight executes the given command on a right-click.
+func RunRight(cmd string, args ...string) func(bar.Event) {
+	return RunCommand(bar.ButtonRight, cmd, args...)
+}
+
+// RunMiddle executes the given command on a middle-click.
+func RunMiddle(cmd string, args ...string) func(bar.Event) {
+	return RunCommand(bar.ButtonMiddle, cmd, args...)
+}
+
+// RunScroll executes the up command on scrolling up, and the down command on
+// scrolling down. Each command is given as the name followed by arguments,
+// e.g. RunScroll([]string{"light", "-A", "5"}, []string{"light", "-U", "5"}).
+// An empty command is ignored.
+func RunScroll(up, down []string) func(bar.Event) {
+	cmds := map[bar.Button][]string{bar.ScrollUp: up, bar.ScrollDown: down}
+	return Scroll(func(btn bar.Button) {
+		if cmd := cmds[btn]; len(cmd) > 0 {
+			exec.Command(cmd[0], cmd[1:]...).Run()
+		}
 	})
 }
 
@@ -103,3 +133,82 @@ func (m Map) Else(handler func(bar.Event)) Map {
 
 // Generate methods for each button, both at the package level and on Map.
 //go:generate ruby buttons.rb
+
+// Run sets the handler for the given button to execute a command, and returns
+// the map for chaining.
+func (m Map) Run(btn bar.Button, cmd string, args ...string) Map {
+	return m.Set(btn, RunCommand(btn, cmd, args...))
+}
+
+// Bind wraps a module so that clicks on any of its segments are handled by the
+// given map, e.g.
+//
+//	click.Bind(volume.New(...), click.Map{}.
+//	  Run(bar.ButtonLeft, "pavucontrol").
+//	  Run(bar.ButtonRight, "audio-switcher"))
+//
+// Buttons not in the map (if it does not have an Else handler) are still sent
+// to the module's own click handlers. Error segments are not changed, modules
+// that can be refreshed still can be once bound, and bound modules forward
+// Remove (see bar.RemovableModule).
+func Bind(m bar.Module, handlers Map) bar.Module {
+	b := &bound{m, handlers}
+	if r, ok := m.(bar.RefresherModule); ok {
+		return &refreshableBound{b, r}
+	}
+	return b
+}
+
+type bound struct {
+	bar.Module
+	handlers Map
+}
+
+// refreshableBound is a bound module that forwards Refresh to the original
+// module, since embedding bar.Module hides it.
+type refreshableBound struct {
+	*bound
+	refresher bar.RefresherModule
+}
+
+func (r *refreshableBound) Refresh() {
+	r.refresher.Refresh()
+}
+
+// Remove forwards removal from the bar to the original module, if it needs to
+// know. Unlike Refresh, this does not change how the bar treats the module.
+func (b *bound) Remove() {
+	if r, ok := b.Module.(bar.RemovableModule); ok {
+		r.Remove()
+	}
+}
+
+func (b *bound) Stream(s bar.Sink) {
+	b.Module.Stream(func(o bar.Output) {
+		if o == nil {
+			s(nil)
+			return
+		}
+		var out bar.Segments
+		for _, seg := range o.Segments() {
+			if seg.GetError() == nil {
+				seg = b.bind(seg)
+			}
+			out = append(out, seg)
+		}
+		s(out)
+	})
+}
+
+func (b *bound) bind(seg *bar.Segment) *bar.Segment {
+	original := seg
+	return seg.Clone().OnClick(func(e bar.Event) {
+		_, ok := b.handlers[e.Button]
+		_, hasElse := b.handlers[fallbackButton]
+		if ok || hasElse {
+			b.handlers.Handle(e)
+			return
+		}
+		original.Click(e)
+	})
+}
@@ -131,6 +131,51 @@ func TestRunLeft(t *testing.T) {
 	require.NoError(t, err, "file created when left-clicked")
 }
 
+func TestRunCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scratch")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	exists := func(name string) bool {
+		_, err := os.Stat(path.Join(dir, name))
+		return err == nil
+	}
+
+	handler := Map{}.
+		RightE(RunRight("touch", path.Join(dir, "right"))).
+		MiddleE(RunMiddle("touch", path.Join(dir, "middle"))).
+		Run(bar.ButtonBack, "touch", path.Join(dir, "back")).
+		Handle
+	triggerHandler(handler, bar.ButtonLeft, bar.ButtonForward, bar.ScrollUp)
+	require.False(t, exists("right"))
+	require.False(t, exists("middle"))
+	require.False(t, exists("back"))
+
+	triggerHandler(handler, bar.ButtonRight)
+	require.True(t, exists("right"), "on right click")
+	triggerHandler(handler, bar.ButtonMiddle)
+	require.True(t, exists("middle"), "on middle click")
+	triggerHandler(handler, bar.ButtonBack)
+	require.True(t, exists("back"), "on back click")
+
+	scroll := RunScroll(
+		[]string{"touch", path.Join(dir, "up")},
+		[]string{"touch", path.Join(dir, "down")})
+	triggerHandler(scroll, bar.ButtonLeft, bar.ScrollLeft, bar.ScrollRight)
+	require.False(t, exists("up"))
+	require.False(t, exists("down"))
+	triggerHandler(scroll, bar.ScrollUp)
+	require.True(t, exists("up"), "on scroll up")
+	require.False(t, exists("down"))
+	triggerHandler(scroll, bar.ScrollDown)
+	require.True(t, exists("down"), "on scroll down")
+
+	require.NotPanics(t, func() {
+		triggerHandler(RunScroll(nil, []string{}), bar.ScrollUp, bar.ScrollDown)
+	}, "with empty commands")
+}
+
 func TestClickAndScroll(t *testing.T) {
 	do, check := makeFunc()
 	handler := Click(do)
@@ -0,0 +1,198 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/i18n"
+	"barista.run/timing"
+)
+
+// Confirmation requires a second click within a timeout to run an action,
+// for destructive actions such as killing a process or suspending the machine.
+type Confirmation struct {
+	action    func()
+	timeout   time.Duration
+	scheduler *timing.Scheduler
+	changed   notifier.Source
+
+	mu      sync.Mutex
+	pending bool
+	prompt  bar.Segments
+}
+
+// Confirm creates a confirmation for the given action. The first left click
+// arms the confirmation, and a second left click within the timeout runs the
+// action. Any other click cancels it.
+//
+// Modules wrapped using Wrap show a "click again to confirm" prompt while the
+// confirmation is pending. Modules can also use Click as a click handler
+// directly, and Pending to change their own output.
+func Confirm(action func(), timeout time.Duration) *Confirmation {
+	c := &Confirmation{
+		action:    action,
+		timeout:   timeout,
+		scheduler: timing.NewScheduler(),
+		prompt:    bar.Segments{bar.TextSegment(i18n.Sprintf("Click again to confirm"))},
+	}
+	go c.cancelOnTimeout()
+	return c
+}
+
+// Prompt sets the output shown by wrapped modules while confirmation is
+// pending.
+func (c *Confirmation) Prompt(out bar.Output) *Confirmation {
+	var prompt bar.Segments
+	if out != nil {
+		prompt = out.Segments()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompt = prompt
+	if c.pending {
+		c.changed.Notify()
+	}
+	return c
+}
+
+// Click handles a click event, arming the confirmation on the first left click
+// and running the action on the second.
+func (c *Confirmation) Click(e bar.Event) {
+	c.mu.Lock()
+	if e.Button != bar.ButtonLeft {
+		c.setPending(false)
+		c.mu.Unlock()
+		return
+	}
+	confirmed := c.pending
+	c.setPending(!confirmed)
+	c.mu.Unlock()
+	if confirmed {
+		c.action()
+	}
+}
+
+// Pending returns true if the next left click will run the action.
+func (c *Confirmation) Pending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending
+}
+
+// Cancel cancels a pending confirmation.
+func (c *Confirmation) Cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setPending(false)
+}
+
+// Subscribe returns a channel that is notified whenever the confirmation is
+// armed or cancelled, for modules that show their own prompt.
+func (c *Confirmation) Subscribe() (<-chan struct{}, func()) {
+	return c.changed.Subscribe()
+}
+
+// setPending updates the pending state, notifying any wrapped modules. It must
+// be called with the lock held.
+func (c *Confirmation) setPending(pending bool) {
+	if c.pending == pending {
+		return
+	}
+	c.pending = pending
+	if pending {
+		c.scheduler.After(c.timeout)
+	} else {
+		c.scheduler.Stop()
+	}
+	c.changed.Notify()
+}
+
+func (c *Confirmation) cancelOnTimeout() {
+	for range c.scheduler.C {
+		c.Cancel()
+	}
+}
+
+// Wrap wraps a module so that a left click on any of its segments arms the
+// confirmation, replacing the module's output with the prompt until the action
+// is confirmed or the confirmation is cancelled. Other clicks are still sent
+// to the module's own click handlers, and error segments are not changed.
+func (c *Confirmation) Wrap(m bar.Module) bar.Module {
+	return &confirmModule{m, c}
+}
+
+type confirmModule struct {
+	bar.Module
+	*Confirmation
+}
+
+func (m *confirmModule) Stream(s bar.Sink) {
+	outs := make(chan bar.Output)
+	done := make(chan struct{})
+	go func() {
+		m.Module.Stream(func(o bar.Output) { outs <- o })
+		close(done)
+	}()
+	changed, unsubscribe := m.changed.Subscribe()
+	defer unsubscribe()
+	var last bar.Output
+	for {
+		select {
+		case last = <-outs:
+		case <-changed:
+		case <-done:
+			return
+		}
+		s(m.output(last))
+	}
+}
+
+func (m *confirmModule) output(o bar.Output) bar.Output {
+	m.mu.Lock()
+	pending, prompt := m.pending, m.prompt
+	m.mu.Unlock()
+	if pending {
+		var out bar.Segments
+		for _, seg := range prompt {
+			out = append(out, seg.Clone().OnClick(m.Click))
+		}
+		return out
+	}
+	if o == nil {
+		return nil
+	}
+	var out bar.Segments
+	for _, seg := range o.Segments() {
+		if seg.GetError() == nil {
+			seg = seg.Clone().OnClick(m.armOrForward(seg))
+		}
+		out = append(out, seg)
+	}
+	return out
+}
+
+func (m *confirmModule) armOrForward(seg *bar.Segment) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			m.Click(e)
+		} else {
+			seg.Click(e)
+		}
+	}
+}
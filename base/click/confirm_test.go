@@ -0,0 +1,141 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testModule "barista.run/testing/module"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirm(t *testing.T) {
+	timing.TestMode()
+	do, check := makeFunc()
+	c := Confirm(do, 5*time.Second)
+	require.False(t, c.Pending())
+
+	c.Click(bar.Event{Button: bar.ButtonLeft})
+	require.True(t, c.Pending(), "after first click")
+	require.False(t, check(), "action not run on first click")
+
+	c.Click(bar.Event{Button: bar.ButtonLeft})
+	require.True(t, check(), "action run on second click")
+	require.False(t, c.Pending())
+
+	c.Click(bar.Event{Button: bar.ButtonLeft})
+	c.Click(bar.Event{Button: bar.ScrollUp})
+	require.False(t, c.Pending(), "other buttons cancel")
+	c.Click(bar.Event{Button: bar.ButtonLeft})
+	require.False(t, check(), "action not run after cancel")
+
+	c.Cancel()
+	require.False(t, c.Pending())
+
+	c.Click(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, timing.Now().Add(5*time.Second), timing.NextTick())
+	for start := time.Now(); c.Pending() && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	require.False(t, c.Pending(), "cancelled on timeout")
+	c.Click(bar.Event{Button: bar.ButtonLeft})
+	require.False(t, check(), "action not run after timeout")
+}
+
+func TestConfirmSubscribe(t *testing.T) {
+	timing.TestMode()
+	do, _ := makeFunc()
+	c := Confirm(do, time.Minute)
+	sub, done := c.Subscribe()
+	defer done()
+
+	c.Click(bar.Event{Button: bar.ButtonLeft})
+	assertNotified(t, sub, "when armed")
+	c.Cancel()
+	assertNotified(t, sub, "when cancelled")
+	c.Cancel()
+	select {
+	case <-sub:
+		require.Fail(t, "notified without a change")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func assertNotified(t *testing.T, sub <-chan struct{}, msg string) {
+	select {
+	case <-sub:
+	case <-time.After(time.Second):
+		require.Fail(t, "not notified", msg)
+	}
+}
+
+func TestConfirmWrap(t *testing.T) {
+	timing.TestMode()
+	do, check := makeFunc()
+	c := Confirm(do, time.Minute)
+	m := testModule.New(t)
+	outs := make(chan bar.Output, 10)
+	go c.Wrap(m).Stream(func(o bar.Output) { outs <- o })
+	m.AssertStarted()
+
+	m.Output(outputs.Text("suspend"))
+	out := (<-outs).Segments()
+	txt, _ := out[0].Content()
+	require.Equal(t, "suspend", txt)
+
+	out[0].Click(bar.Event{Button: bar.ButtonRight})
+	m.AssertClicked("other buttons sent to module")
+	require.False(t, c.Pending())
+
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	m.AssertNotClicked("left click arms confirmation")
+	out = (<-outs).Segments()
+	txt, _ = out[0].Content()
+	require.Equal(t, "Click again to confirm", txt)
+
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	require.True(t, check(), "action run on confirm")
+	out = (<-outs).Segments()
+	txt, _ = out[0].Content()
+	require.Equal(t, "suspend", txt, "original output restored")
+
+	c.Prompt(outputs.Text("Really?"))
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	out = (<-outs).Segments()
+	txt, _ = out[0].Content()
+	require.Equal(t, "Really?", txt, "custom prompt")
+
+	m.Output(outputs.Text("hibernate"))
+	out = (<-outs).Segments()
+	txt, _ = out[0].Content()
+	require.Equal(t, "Really?", txt, "prompt shown while pending")
+
+	out[0].Click(bar.Event{Button: bar.ButtonMiddle})
+	out = (<-outs).Segments()
+	txt, _ = out[0].Content()
+	require.Equal(t, "hibernate", txt, "latest output shown on cancel")
+	require.False(t, check())
+	m.AssertNotClicked("prompt clicks not sent to module")
+
+	m.Output(outputs.Errorf("oops"))
+	out = (<-outs).Segments()
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	require.False(t, c.Pending(), "error segments unchanged")
+}
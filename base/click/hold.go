@@ -0,0 +1,84 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/timing"
+)
+
+// Number of consecutive events after which the number of repeats increases.
+const holdRampEvery = 3
+
+// Hold detects a button being held down, to allow adjustments (e.g. volume or
+// brightness) to ramp up instead of requiring many separate clicks or scrolls.
+//
+// The bar only sends an event when a button is pressed, with no corresponding
+// release event, so holds are detected using timing: events from the same
+// button that arrive within the window of the previous one are treated as
+// part of the same hold. This covers continuous scrolling, and buttons that
+// repeat while held, e.g. keyboard keys bound to mouse buttons.
+type Hold struct {
+	window time.Duration
+	max    int
+
+	mu     sync.Mutex
+	button bar.Button
+	last   time.Time
+	count  int
+}
+
+// NewHold creates a hold detector that treats events within the given window
+// as a single hold, with up to max repeats for each event.
+func NewHold(window time.Duration, max int) *Hold {
+	if max < 1 {
+		max = 1
+	}
+	return &Hold{window: window, max: max}
+}
+
+// Repeats records the event, and returns the number of times it should be
+// applied. This starts at 1, and increases by one every few consecutive
+// events in the same hold, up to the maximum.
+func (h *Hold) Repeats(e bar.Event) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := timing.Now()
+	if e.Button == h.button && h.count > 0 && now.Sub(h.last) <= h.window {
+		h.count++
+	} else {
+		h.count = 1
+	}
+	h.button = e.Button
+	h.last = now
+	repeats := 1 + (h.count-1)/holdRampEvery
+	if repeats > h.max {
+		repeats = h.max
+	}
+	return repeats
+}
+
+// Handler wraps a click handler so that it is invoked repeatedly while a
+// button is held, based on the number of repeats for each event.
+func (h *Hold) Handler(handler func(bar.Event)) func(bar.Event) {
+	return func(e bar.Event) {
+		for i := h.Repeats(e); i > 0; i-- {
+			handler(e)
+		}
+	}
+}
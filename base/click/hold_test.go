@@ -0,0 +1,75 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func repeatsFor(h *Hold, btn bar.Button, count int) []int {
+	var r []int
+	for i := 0; i < count; i++ {
+		r = append(r, h.Repeats(bar.Event{Button: btn}))
+		timing.AdvanceBy(50 * time.Millisecond)
+	}
+	return r
+}
+
+func TestHoldRepeats(t *testing.T) {
+	timing.TestMode()
+	h := NewHold(100*time.Millisecond, 3)
+
+	require.Equal(t, []int{1, 1, 1, 2, 2, 2, 3, 3, 3, 3},
+		repeatsFor(h, bar.ScrollUp, 10), "ramps up to max while held")
+
+	require.Equal(t, []int{1, 1, 1, 2},
+		repeatsFor(h, bar.ScrollDown, 4), "resets on button change")
+
+	timing.AdvanceBy(time.Second)
+	require.Equal(t, []int{1, 1},
+		repeatsFor(h, bar.ScrollDown, 2), "resets after window")
+
+	timing.AdvanceBy(50 * time.Millisecond)
+	require.Equal(t, 1, h.Repeats(bar.Event{Button: bar.ScrollDown}),
+		"event exactly at window is part of the hold")
+
+	h = NewHold(time.Second, 0)
+	require.Equal(t, []int{1, 1, 1, 1, 1},
+		repeatsFor(h, bar.ButtonLeft, 5), "at least one repeat")
+}
+
+func TestHoldHandler(t *testing.T) {
+	timing.TestMode()
+	do, check := makeHandler()
+	handler := NewHold(time.Second, 5).Handler(do)
+
+	counts := []int{}
+	for i := 0; i < 7; i++ {
+		handler(bar.Event{Button: bar.ScrollDown})
+		count := 0
+		for check() == bar.ScrollDown {
+			count++
+		}
+		counts = append(counts, count)
+	}
+	require.Equal(t, []int{1, 1, 1, 2, 2, 2, 3}, counts,
+		"handler invoked repeatedly while held")
+}
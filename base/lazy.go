@@ -0,0 +1,149 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package base provides building blocks for modules.
+package base // import "barista.run/base"
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+	"barista.run/timing"
+)
+
+// LazyModule wraps a module so that it is only started the first time it is
+// shown, e.g. for modules in a collapsing group that make network requests.
+// Optionally, it can also stop the module after it has been hidden for some
+// time, until it is shown again.
+//
+// Visibility is controlled by groups (see group.VisibilityListener), so
+// modules that are not in a group are always started immediately.
+type LazyModule struct {
+	module    bar.Module
+	scheduler *timing.Scheduler
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	visible   bool
+	active    bool // Whether the module is allowed to run.
+	started   bool
+	stopAfter time.Duration
+}
+
+// Lazy wraps a module so that it only starts once it becomes visible.
+func Lazy(m bar.Module) *LazyModule {
+	lazy := &LazyModule{
+		module:    m,
+		scheduler: timing.NewScheduler(),
+		visible:   true,
+		active:    true,
+	}
+	lazy.cond = sync.NewCond(&lazy.mu)
+	l.Register(lazy, "module", "scheduler")
+	go lazy.stopOnTimeout()
+	return lazy
+}
+
+// StopAfter stops the module once it has been hidden for the given duration.
+// A stopped module is suspended the next time it updates its output, and is
+// refreshed when shown again if it supports refreshing. A zero duration (the
+// default) keeps the module running once it has started.
+func (m *LazyModule) StopAfter(timeout time.Duration) *LazyModule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopAfter = timeout
+	m.restartTimeout()
+	return m
+}
+
+// SetVisible starts or resumes the module when it is shown, and (if
+// configured) schedules stopping it when it is hidden.
+func (m *LazyModule) SetVisible(visible bool) {
+	m.mu.Lock()
+	if m.visible == visible {
+		m.mu.Unlock()
+		return
+	}
+	m.visible = visible
+	resumed := false
+	switch {
+	case visible:
+		resumed = m.started && !m.active
+		m.active = true
+		m.cond.Broadcast()
+	case !m.started:
+		// Modules that have never been shown are not started at all.
+		m.active = false
+	}
+	m.restartTimeout()
+	m.mu.Unlock()
+	if resumed {
+		l.Fine("%s resumed", l.ID(m))
+		m.Refresh()
+	}
+}
+
+// Stream starts the wrapped module once it is visible.
+func (m *LazyModule) Stream(s bar.Sink) {
+	m.mu.Lock()
+	for !m.active {
+		m.cond.Wait()
+	}
+	m.started = true
+	m.restartTimeout()
+	m.mu.Unlock()
+	l.Fine("%s started", l.ID(m))
+	m.module.Stream(func(o bar.Output) {
+		m.waitUntilActive()
+		s.Output(o)
+	})
+}
+
+// Refresh refreshes the wrapped module, if it supports refreshing.
+func (m *LazyModule) Refresh() {
+	if r, ok := m.module.(bar.RefresherModule); ok {
+		r.Refresh()
+	}
+}
+
+func (m *LazyModule) waitUntilActive() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for !m.active {
+		m.cond.Wait()
+	}
+}
+
+// restartTimeout starts the timeout for stopping the module if it is hidden,
+// or cancels it otherwise. Must be called with the lock held.
+func (m *LazyModule) restartTimeout() {
+	if m.stopAfter > 0 && m.started && !m.visible {
+		m.scheduler.After(m.stopAfter)
+	} else {
+		m.scheduler.Stop()
+	}
+}
+
+func (m *LazyModule) stopOnTimeout() {
+	for range m.scheduler.C {
+		m.mu.Lock()
+		if !m.visible {
+			l.Fine("%s stopped", l.ID(m))
+			m.active = false
+		}
+		m.mu.Unlock()
+	}
+}
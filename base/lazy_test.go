@@ -0,0 +1,122 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/group/collapsing"
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazyNotInGroup(t *testing.T) {
+	testBar.New(t)
+	m := testModule.New(t)
+	testBar.Run(Lazy(m))
+	m.AssertStarted("when not in a group")
+
+	m.OutputText("foo")
+	testBar.NextOutput().AssertText([]string{"foo"})
+}
+
+func TestLazyStart(t *testing.T) {
+	testBar.New(t)
+	m := testModule.New(t)
+	lazy := Lazy(m)
+	lazy.SetVisible(false)
+	testBar.Run(lazy)
+	m.AssertNotStarted("while hidden")
+
+	lazy.SetVisible(true)
+	m.AssertStarted("when shown")
+	m.OutputText("foo")
+	testBar.NextOutput().AssertText([]string{"foo"})
+
+	lazy.SetVisible(false)
+	m.OutputText("bar")
+	testBar.NextOutput().AssertText([]string{"bar"},
+		"keeps running when hidden without StopAfter")
+}
+
+func TestLazyInGroup(t *testing.T) {
+	testBar.New(t)
+	m0 := testModule.New(t)
+	m1 := testModule.New(t)
+	grp, ctrl := collapsing.Group(Lazy(m0), m1)
+	testBar.Run(grp)
+	m1.AssertStarted("regular module in collapsed group")
+	m0.AssertNotStarted("lazy module in collapsed group")
+
+	ctrl.Expand()
+	m0.AssertStarted("on expanding group")
+}
+
+type refreshableModule struct {
+	*testModule.TestModule
+	refreshed chan bool
+}
+
+func (r refreshableModule) Refresh() { r.refreshed <- true }
+
+func (m *LazyModule) isActive() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active
+}
+
+func TestLazyStopAfter(t *testing.T) {
+	testBar.New(t)
+	m := refreshableModule{testModule.New(t), make(chan bool, 10)}
+	lazy := Lazy(m).StopAfter(time.Minute)
+	testBar.Run(lazy)
+	m.AssertStarted()
+	m.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"a"})
+
+	lazy.SetVisible(false)
+	timing.AdvanceBy(30 * time.Second)
+	m.OutputText("b")
+	testBar.NextOutput().AssertText([]string{"b"}, "before timeout")
+
+	lazy.SetVisible(true)
+	lazy.SetVisible(false)
+	timing.AdvanceBy(30 * time.Second)
+	require.True(t, lazy.isActive(), "timeout restarted when shown")
+
+	timing.AdvanceBy(30 * time.Second)
+	for lazy.isActive() {
+		time.Sleep(time.Millisecond)
+	}
+	m.OutputText("c")
+	testBar.AssertNoOutput("while stopped")
+	select {
+	case <-m.refreshed:
+		require.Fail(t, "refreshed while stopped")
+	default:
+	}
+
+	lazy.SetVisible(true)
+	testBar.NextOutput().AssertText([]string{"c"}, "on resume")
+	select {
+	case <-m.refreshed:
+	case <-time.After(time.Second):
+		require.Fail(t, "not refreshed on resume")
+	}
+}
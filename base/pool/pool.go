@@ -0,0 +1,274 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pool runs timer-driven modules on a shared set of goroutines.
+//
+// Each module normally runs in its own goroutine, waiting on its own
+// scheduler. For bars with many modules that only update periodically, a Pool
+// instead triggers all of its modules from a single scheduler, and runs their
+// updates on a fixed number of worker goroutines. This bounds the number of
+// modules updating at the same time, and keeps goroutine dumps readable.
+//
+// Only the timers and the updates are pooled. The bar still streams each
+// module in its own goroutine, which stays in Stream for as long as the module
+// is on the bar, since returning from Stream would mark the module as
+// finished. That goroutine sends the module's output to the bar, so that
+// workers never wait for the bar, and a module removed from the bar (see
+// bar.RemovableModule) does not keep running on the pool.
+package pool // import "barista.run/base/pool"
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	l "barista.run/logging"
+	"barista.run/timing"
+)
+
+// Pool runs the updates of its modules on a fixed number of workers.
+type Pool struct {
+	workers int
+	start   sync.Once
+	sch     *timing.Scheduler
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	tasks []*task
+	queue []*task
+}
+
+// task is a single running module on the pool.
+type task struct {
+	fn       func(bar.Sink)
+	interval time.Duration
+	next     time.Time
+	// queued is true while the task is waiting for or running on a worker,
+	// so that a slow update does not pile up further runs of the same task.
+	queued bool
+	// again is true if the task was refreshed while queued.
+	again bool
+	// removed is true once the module has been removed from the bar.
+	removed bool
+
+	// The latest output, which is sent to the bar from the module's own
+	// goroutine.
+	outMu    sync.Mutex
+	out      bar.Output
+	notifyFn func()
+	outCh    <-chan struct{}
+}
+
+func newTask(fn func(bar.Sink), interval time.Duration) *task {
+	t := &task{fn: fn, interval: interval}
+	t.notifyFn, t.outCh = notifier.New()
+	return t
+}
+
+// output is the sink used for runs of the task, which only keeps the latest
+// output until the module's goroutine sends it to the bar.
+func (t *task) output(o bar.Output) {
+	t.outMu.Lock()
+	t.out = o
+	t.outMu.Unlock()
+	t.notifyFn()
+}
+
+func (t *task) latest() bar.Output {
+	t.outMu.Lock()
+	defer t.outMu.Unlock()
+	return t.out
+}
+
+// New creates a pool that runs at most the given number of updates at once.
+// The workers and scheduler are started when the first module is streamed.
+func New(workers int) *Pool {
+	if workers <= 0 {
+		panic(errors.New("non-positive workers for pool.New"))
+	}
+	p := &Pool{workers: workers}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Every constructs a module that calls fn on the pool immediately, and then
+// repeatedly at the given interval. Like timing.Scheduler, triggers are held
+// while the bar is paused, and any missed triggers are dropped.
+//
+// Since the workers are shared, fn should not block for long periods, and
+// long-running or event-driven modules are better left on their own
+// goroutines.
+func (p *Pool) Every(interval time.Duration, fn func(bar.Sink)) *Module {
+	if interval <= 0 {
+		panic(errors.New("non-positive interval for Pool#Every"))
+	}
+	return &Module{pool: p, interval: interval, fn: fn}
+}
+
+// Module is a bar.Module that runs its updates on a pool.
+type Module struct {
+	pool     *Pool
+	interval time.Duration
+	fn       func(bar.Sink)
+
+	mu   sync.Mutex
+	task *task
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	t := newTask(m.fn, m.interval)
+	m.mu.Lock()
+	m.task = t
+	m.mu.Unlock()
+	m.pool.add(t)
+	// The module's updates run on the pool, but returning would cause the
+	// bar to consider the module finished.
+	for range t.outCh {
+		s(t.latest())
+	}
+}
+
+// Refresh runs the module's function again, without waiting for the next
+// trigger. If an update is already in progress, the function runs again once
+// it completes.
+func (m *Module) Refresh() {
+	m.mu.Lock()
+	t := m.task
+	m.mu.Unlock()
+	if t != nil {
+		m.pool.refresh(t)
+	}
+}
+
+// Remove stops running the module's updates on the pool, once it has been
+// removed from the bar.
+func (m *Module) Remove() {
+	m.mu.Lock()
+	t := m.task
+	m.task = nil
+	m.mu.Unlock()
+	if t != nil {
+		m.pool.remove(t)
+	}
+}
+
+func (p *Pool) add(t *task) {
+	p.start.Do(p.startWorkers)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.next = timing.Now().Add(t.interval)
+	p.tasks = append(p.tasks, t)
+	p.enqueue(t)
+	p.reschedule()
+}
+
+func (p *Pool) remove(t *task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.removed = true
+	for i, other := range p.tasks {
+		if other == t {
+			p.tasks = append(p.tasks[:i], p.tasks[i+1:]...)
+			break
+		}
+	}
+	p.reschedule()
+}
+
+func (p *Pool) refresh(t *task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t.queued {
+		t.again = true
+		return
+	}
+	p.enqueue(t)
+}
+
+func (p *Pool) startWorkers() {
+	p.sch = timing.NewScheduler()
+	l.Attach(p, p.sch, "scheduler")
+	for i := 0; i < p.workers; i++ {
+		go p.worker()
+	}
+	go p.dispatch()
+}
+
+// dispatch queues each task as it becomes due.
+func (p *Pool) dispatch() {
+	for p.sch.Tick() {
+		p.mu.Lock()
+		now := timing.Now()
+		for _, t := range p.tasks {
+			if t.next.After(now) {
+				continue
+			}
+			missed := now.Sub(t.next) / t.interval
+			t.next = t.next.Add((missed + 1) * t.interval)
+			p.enqueue(t)
+		}
+		p.reschedule()
+		p.mu.Unlock()
+	}
+}
+
+// reschedule sets the scheduler to trigger when the earliest task is due.
+// Must be called with mu held.
+func (p *Pool) reschedule() {
+	var next time.Time
+	for _, t := range p.tasks {
+		if next.IsZero() || t.next.Before(next) {
+			next = t.next
+		}
+	}
+	p.sch.At(next)
+}
+
+// enqueue adds the task to the queue for the next free worker, unless it is
+// already queued. Must be called with mu held.
+func (p *Pool) enqueue(t *task) {
+	if t.queued {
+		return
+	}
+	t.queued = true
+	p.queue = append(p.queue, t)
+	p.cond.Signal()
+}
+
+func (p *Pool) worker() {
+	p.mu.Lock()
+	for {
+		for len(p.queue) == 0 {
+			p.cond.Wait()
+		}
+		t := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		if t.removed {
+			t.queued = false
+			continue
+		}
+		p.mu.Unlock()
+		t.fn(t.output)
+		p.mu.Lock()
+		t.queued = false
+		if t.again && !t.removed {
+			t.again = false
+			p.enqueue(t)
+		}
+	}
+}
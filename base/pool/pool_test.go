@@ -0,0 +1,172 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func counter() func(bar.Sink) {
+	var count int64
+	return func(s bar.Sink) {
+		s.Output(outputs.Textf("%d", atomic.AddInt64(&count, 1)))
+	}
+}
+
+func TestEvery(t *testing.T) {
+	testBar.New(t)
+	p := New(2)
+	testBar.Run(
+		p.Every(time.Second, counter()),
+		p.Every(3*time.Second, counter()),
+	)
+	testBar.LatestOutput().AssertText([]string{"1", "1"}, "on start")
+
+	start := timing.Now()
+	require.Equal(t, start.Add(time.Second), testBar.Tick())
+	testBar.NextOutput().AssertText([]string{"2", "1"})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"3", "1"})
+
+	require.Equal(t, start.Add(3*time.Second), testBar.Tick())
+	testBar.LatestOutput().AssertText([]string{"4", "2"},
+		"modules due at the same time both update")
+
+	timing.AdvanceBy(5500 * time.Millisecond)
+	testBar.LatestOutput().AssertText([]string{"5", "3"},
+		"missed triggers are dropped")
+	require.Equal(t, start.Add(9*time.Second), testBar.Tick(),
+		"triggers stay on multiples of the interval")
+	testBar.LatestOutput().AssertText([]string{"6", "4"})
+}
+
+func TestConcurrency(t *testing.T) {
+	testBar.New(t)
+	p := New(2)
+	var running, maxRunning int64
+	release := make(chan struct{})
+	blocking := func(s bar.Sink) {
+		r := atomic.AddInt64(&running, 1)
+		for {
+			m := atomic.LoadInt64(&maxRunning)
+			if r <= m || atomic.CompareAndSwapInt64(&maxRunning, m, r) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt64(&running, -1)
+		s.Output(outputs.Text("done"))
+	}
+	testBar.Run(
+		p.Every(time.Second, blocking),
+		p.Every(time.Second, blocking),
+		p.Every(time.Second, blocking),
+		p.Every(time.Second, blocking),
+	)
+	testBar.AssertNoOutput("while workers are busy")
+	require.Equal(t, int64(2), atomic.LoadInt64(&running))
+
+	close(release)
+	testBar.LatestOutput().AssertText([]string{"done", "done", "done", "done"})
+	require.Equal(t, int64(2), atomic.LoadInt64(&maxRunning),
+		"runs at most the given number of updates at once")
+}
+
+func TestRefresh(t *testing.T) {
+	testBar.New(t)
+	p := New(1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var count int64
+	m := p.Every(time.Minute, func(s bar.Sink) {
+		n := atomic.AddInt64(&count, 1)
+		if n > 1 {
+			started <- struct{}{}
+			<-release
+		}
+		s.Output(outputs.Textf("%d", n))
+	})
+	m.Refresh() // no-op before the module is started.
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"1"})
+
+	m.Refresh()
+	<-started
+	m.Refresh()
+	m.Refresh()
+	release <- struct{}{}
+	testBar.NextOutput().AssertText([]string{"2"})
+
+	<-started
+	release <- struct{}{}
+	testBar.NextOutput().AssertText([]string{"3"},
+		"refreshes while running are coalesced into one")
+	testBar.AssertNoOutput("after refresh")
+}
+
+func TestBlockedSink(t *testing.T) {
+	timing.TestMode()
+	p := New(1)
+	blocked := p.Every(time.Second, counter())
+	// e.g. the sink of a module that was removed from the bar.
+	go blocked.Stream(func(bar.Output) { select {} })
+
+	outs := make(chan bar.Output, 10)
+	other := p.Every(time.Second, counter())
+	go other.Stream(func(o bar.Output) { outs <- o })
+	for i := 0; i < 3; i++ {
+		select {
+		case <-outs:
+		case <-time.After(time.Second):
+			require.Fail(t, "worker held by blocked sink")
+		}
+		timing.NextTick()
+	}
+}
+
+func TestRemove(t *testing.T) {
+	testBar.New(t)
+	p := New(1)
+	var removedRuns int64
+	removed := p.Every(time.Second, func(s bar.Sink) {
+		s.Output(outputs.Textf("%d", atomic.AddInt64(&removedRuns, 1)))
+	})
+	removed.Remove() // no-op before the module is started.
+	testBar.Run(removed, p.Every(time.Second, counter()))
+	testBar.LatestOutput().AssertText([]string{"1", "1"}, "on start")
+
+	removed.Remove()
+	removed.Refresh()
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1", "2"})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1", "3"})
+	require.Equal(t, int64(1), atomic.LoadInt64(&removedRuns),
+		"removed module does not run")
+}
+
+func TestInvalid(t *testing.T) {
+	require.Panics(t, func() { New(0) })
+	require.Panics(t, func() { New(2).Every(0, counter()) })
+}
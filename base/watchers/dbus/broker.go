@@ -0,0 +1,376 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/logging"
+
+	"github.com/godbus/dbus"
+)
+
+// Reconnection delays when the bus goes away, doubled on each failed attempt.
+var (
+	reconnectDelay    = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// broker shares a single connection to a bus between all watchers, keeping
+// track of each watcher's signal matches so that signals are only delivered
+// to the watchers that asked for them. If the bus restarts, the broker
+// reconnects, restores all matches, and emits NameOwnerChanged signals for
+// any watched names so that watchers can refresh their state.
+type broker struct {
+	dial func() (dbusConn, error)
+
+	mu      sync.Mutex
+	conn    dbusConn
+	handles map[*sharedConn]bool
+}
+
+func newBroker(dial func() (dbusConn, error)) *broker {
+	return &broker{dial: dial, handles: map[*sharedConn]bool{}}
+}
+
+// connect returns a new handle to the shared connection, connecting to the
+// bus if there is no open connection.
+func (b *broker) connect() dbusConn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		conn, err := b.dial()
+		if err != nil {
+			panic("Could not connect to dbus: " + err.Error())
+		}
+		b.attach(conn)
+	}
+	h := &sharedConn{
+		broker:  b,
+		signals: map[chan<- *dbus.Signal]bool{},
+		done:    make(chan struct{}),
+	}
+	b.handles[h] = true
+	return h
+}
+
+// attach makes conn the shared connection, and starts delivering its signals.
+// It must be called with the lock held.
+func (b *broker) attach(conn dbusConn) {
+	b.conn = conn
+	ch := make(chan *dbus.Signal, 10)
+	conn.Signal(ch)
+	go b.listen(conn, ch)
+}
+
+func (b *broker) listen(conn dbusConn, ch <-chan *dbus.Signal) {
+	for sig := range ch {
+		for _, h := range b.handleList() {
+			h.deliver(sig)
+		}
+	}
+	// The signal channel is only closed when the connection is closed, which
+	// is either because the last handle was closed, or because the bus went
+	// away, in which case b.conn is still the old connection.
+	b.reconnect(conn)
+}
+
+func (b *broker) handleList() []*sharedConn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var handles []*sharedConn
+	for h := range b.handles {
+		handles = append(handles, h)
+	}
+	return handles
+}
+
+func (b *broker) reconnect(old dbusConn) {
+	delay := reconnectDelay
+	for {
+		conn, err := b.dial()
+		b.mu.Lock()
+		if b.conn != old {
+			b.mu.Unlock()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		if err == nil {
+			b.attach(conn)
+			b.mu.Unlock()
+			handles := b.handleList()
+			for _, h := range handles {
+				h.restoreMatches(conn)
+			}
+			resync(conn, handles)
+			return
+		}
+		b.mu.Unlock()
+		logging.Log("dbus: reconnect failed: %v, retrying in %v", err, delay)
+		time.Sleep(delay)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// resync emits a NameOwnerChanged signal for each name that matches a
+// handle's rules, since any owners they were tracking are now gone.
+func resync(conn dbusConn, handles []*sharedConn) {
+	var names []string
+	listNames.call(conn).Store(&names)
+	hasOwner := map[string]bool{}
+	for _, n := range names {
+		hasOwner[n] = true
+	}
+	// Names that were released while the bus was away cannot be found using
+	// ListNames, but handles watching exact names can still be told.
+	for _, h := range handles {
+		for _, n := range h.watchedNames() {
+			if _, ok := hasOwner[n]; !ok {
+				hasOwner[n] = false
+			}
+		}
+	}
+	for n, ok := range hasOwner {
+		sig := &dbus.Signal{
+			Sender: bus,
+			Path:   busPath,
+			Name:   nameOwnerChanged.String(),
+			Body:   []interface{}{n, "", ""},
+		}
+		fetched := false
+		for _, h := range handles {
+			if !h.wants(sig) {
+				continue
+			}
+			if ok && !fetched {
+				var owner string
+				getNameOwner.call(conn, n).Store(&owner)
+				sig.Body[2] = owner
+				fetched = true
+			}
+			h.deliver(sig)
+		}
+	}
+}
+
+// current returns the shared connection. While reconnecting, this is the old
+// connection, so calls will fail but any matches added will still be restored.
+func (b *broker) current() dbusConn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conn
+}
+
+// close closes a handle, closing the shared connection if it was the last.
+func (b *broker) close(h *sharedConn) {
+	b.mu.Lock()
+	delete(b.handles, h)
+	conn := b.conn
+	last := len(b.handles) == 0
+	if last {
+		b.conn = nil
+	}
+	b.mu.Unlock()
+	if last {
+		conn.Close()
+		return
+	}
+	busObj := conn.BusObject()
+	for _, m := range h.matchList() {
+		busObj.RemoveMatchSignal(m.iface, m.member, m.opts...)
+	}
+}
+
+// sharedConn is a handle to the connection shared by a broker, which tracks
+// the signal channels and matches added by a single watcher.
+type sharedConn struct {
+	broker *broker
+
+	mu      sync.Mutex
+	closed  bool
+	done    chan struct{}
+	signals map[chan<- *dbus.Signal]bool
+	matches []matchRule
+}
+
+// matchRule is a signal match added through a shared connection.
+type matchRule struct {
+	iface, member string
+	name          string
+	opts          []dbus.MatchOption
+	cond          map[string]string
+}
+
+func (h *sharedConn) BusObject() dbus.BusObject {
+	return &sharedBusObject{h.broker.current().BusObject(), h}
+}
+
+func (h *sharedConn) Object(dest string, path dbus.ObjectPath) dbus.BusObject {
+	return h.broker.current().Object(dest, path)
+}
+
+func (h *sharedConn) Signal(ch chan<- *dbus.Signal) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.signals[ch] = true
+}
+
+func (h *sharedConn) RemoveSignal(ch chan<- *dbus.Signal) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.signals, ch)
+}
+
+func (h *sharedConn) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return dbus.ErrClosed
+	}
+	h.closed = true
+	close(h.done)
+	h.signals = nil
+	h.mu.Unlock()
+	h.broker.close(h)
+	return nil
+}
+
+// wants returns true if the signal matches any of the handle's match rules.
+func (h *sharedConn) wants(sig *dbus.Signal) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, m := range h.matches {
+		if m.matches(sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver sends a signal to all of the handle's channels if it matches any of
+// the handle's rules. Like dbus.Conn, it does not block on full channels.
+func (h *sharedConn) deliver(sig *dbus.Signal) {
+	if !h.wants(sig) {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.signals {
+		select {
+		case ch <- sig:
+		default:
+			go func(ch chan<- *dbus.Signal) {
+				select {
+				case ch <- sig:
+				case <-h.done:
+				}
+			}(ch)
+		}
+	}
+}
+
+// matchList returns a copy of the handle's match rules.
+func (h *sharedConn) matchList() []matchRule {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]matchRule(nil), h.matches...)
+}
+
+// watchedNames returns all exact names for which the handle has a
+// NameOwnerChanged match.
+func (h *sharedConn) watchedNames() []string {
+	var names []string
+	for _, m := range h.matchList() {
+		if n, ok := m.cond["arg0"]; ok && m.name == nameOwnerChanged.String() {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// restoreMatches adds all of the handle's match rules to a new connection.
+func (h *sharedConn) restoreMatches(conn dbusConn) {
+	busObj := conn.BusObject()
+	for _, m := range h.matchList() {
+		busObj.AddMatchSignal(m.iface, m.member, m.opts...)
+	}
+}
+
+func (h *sharedConn) addMatch(iface, member string, opts []dbus.MatchOption) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.matches = append(h.matches, matchRule{
+		iface:  iface,
+		member: member,
+		name:   iface + "." + member,
+		opts:   opts,
+		cond:   dbusMatchOptionMap(opts),
+	})
+}
+
+func (h *sharedConn) removeMatch(iface, member string, opts []dbus.MatchOption) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cond := dbusMatchOptionMap(opts)
+	for i, m := range h.matches {
+		if m.iface == iface && m.member == member && reflect.DeepEqual(m.cond, cond) {
+			h.matches = append(h.matches[:i], h.matches[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m matchRule) matches(sig *dbus.Signal) bool {
+	if sig.Name != m.name {
+		return false
+	}
+	for k, v := range m.cond {
+		// Signals are always sent from unique names, but the bus has
+		// already resolved any well-known sender names in the match.
+		if k == "sender" && !strings.HasPrefix(v, ":") {
+			continue
+		}
+		if !checkSignalCondition(k, v, sig.Sender, sig.Path, sig.Body) {
+			return false
+		}
+	}
+	return true
+}
+
+// sharedBusObject wraps the bus object of a shared connection, to record the
+// signal matches added by each handle.
+type sharedBusObject struct {
+	dbus.BusObject
+	conn *sharedConn
+}
+
+func (o *sharedBusObject) AddMatchSignal(iface, member string, opts ...dbus.MatchOption) *dbus.Call {
+	// Matches are recorded even if adding them fails, since the connection
+	// may be closed while the broker is reconnecting.
+	o.conn.addMatch(iface, member, opts)
+	return o.BusObject.AddMatchSignal(iface, member, opts...)
+}
+
+func (o *sharedBusObject) RemoveMatchSignal(iface, member string, opts ...dbus.MatchOption) *dbus.Call {
+	o.conn.removeMatch(iface, member, opts)
+	return o.BusObject.RemoveMatchSignal(iface, member, opts...)
+}
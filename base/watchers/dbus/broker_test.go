@@ -0,0 +1,207 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/stretchr/testify/require"
+)
+
+// droppableConn is a test bus connection that can simulate the bus going
+// away, which closes all signal channels of a dbus.Conn.
+type droppableConn struct {
+	*testBusConnection
+	signals []chan<- *dbus.Signal
+}
+
+func (d *droppableConn) Signal(ch chan<- *dbus.Signal) {
+	d.testBusConnection.Signal(ch)
+	d.signals = append(d.signals, ch)
+}
+
+func (d *droppableConn) drop() {
+	d.testBusConnection.Close()
+	for _, ch := range d.signals {
+		close(ch)
+	}
+}
+
+func dropConnection(b *broker) {
+	b.current().(*droppableConn).drop()
+}
+
+func testBroker(bus *TestBus) (b *broker, dials *int64) {
+	dials = new(int64)
+	return newBroker(func() (dbusConn, error) {
+		atomic.AddInt64(dials, 1)
+		return &droppableConn{testBusConnection: bus.connect()}, nil
+	}), dials
+}
+
+func TestBrokerSharesConnection(t *testing.T) {
+	bus := SetupTestBus()
+	b, dials := testBroker(bus)
+
+	conn0 := b.connect()
+	conn1 := b.connect()
+	require.Equal(t, int64(1), atomic.LoadInt64(dials), "connection is shared")
+	require.Len(t, bus.connections, 1)
+
+	require.NoError(t, conn0.Close())
+	require.Error(t, conn0.Close(), "closing again")
+	require.Len(t, bus.connections, 1, "still open for other handles")
+
+	require.NoError(t, conn1.Close())
+	require.Empty(t, bus.connections, "closed with last handle")
+
+	conn2 := b.connect()
+	defer conn2.Close()
+	require.Equal(t, int64(2), atomic.LoadInt64(dials), "reconnects when needed")
+}
+
+func TestBrokerSignals(t *testing.T) {
+	bus := SetupTestBus()
+	b, _ := testBroker(bus)
+	s := bus.RegisterService("org.i3barista.Service")
+	obj := s.Object("/org/i3barista/Object", "")
+
+	conn0 := b.connect()
+	defer conn0.Close()
+	ch0 := make(chan *dbus.Signal, 10)
+	conn0.Signal(ch0)
+	conn0.BusObject().AddMatchSignal("org.i3barista.Iface", "Foo",
+		dbus.WithMatchOption("path", "/org/i3barista/Object"))
+
+	conn1 := b.connect()
+	ch1 := make(chan *dbus.Signal, 10)
+	conn1.Signal(ch1)
+	conn1.BusObject().AddMatchSignal("org.i3barista.Iface", "Bar",
+		dbus.WithMatchOption("sender", "org.i3barista.Service"))
+	conn1.BusObject().AddMatchSignal("org.i3barista.Iface", "Foo",
+		dbus.WithMatchOption("path", "/org/i3barista/Other"))
+
+	obj.Emit("org.i3barista.Iface.Foo", 1)
+	sig := assertSignalled(t, ch0, "on matching signal")
+	require.Equal(t, []interface{}{1}, sig.Body)
+	assertNotSignalled(t, ch1, "only delivered to matching handles")
+
+	obj.Emit("org.i3barista.Iface.Bar", 2)
+	assertNotSignalled(t, ch0, "only delivered to matching handles")
+	assertNotSignalled(t, ch1, "well-known sender names are not resolved")
+
+	conn1.BusObject().RemoveMatchSignal("org.i3barista.Iface", "Bar",
+		dbus.WithMatchOption("sender", "org.i3barista.Service"))
+	conn1.BusObject().AddMatchSignal("org.i3barista.Iface", "Bar",
+		dbus.WithMatchOption("sender", s.id))
+	obj.Emit("org.i3barista.Iface.Bar", 3)
+	sig = assertSignalled(t, ch1, "on signal from unique name")
+	require.Equal(t, []interface{}{3}, sig.Body)
+
+	conn1.RemoveSignal(ch1)
+	obj.Emit("org.i3barista.Iface.Bar", 4)
+	assertNotSignalled(t, ch1, "after removing channel")
+
+	conn1.Close()
+	bus.mu.Lock()
+	for c := range bus.connections {
+		c.mu.Lock()
+		require.Len(t, c.matches["org.i3barista.Iface.Foo"], 1,
+			"matches removed when handle is closed")
+		require.Empty(t, c.matches["org.i3barista.Iface.Bar"],
+			"matches removed when handle is closed")
+		c.mu.Unlock()
+	}
+	bus.mu.Unlock()
+
+	obj.Emit("org.i3barista.Iface.Foo", 5)
+	assertSignalled(t, ch0, "other handles still receive signals")
+}
+
+func TestBrokerReconnect(t *testing.T) {
+	oldDelay := reconnectDelay
+	defer func() { reconnectDelay = oldDelay }()
+	reconnectDelay = time.Millisecond
+
+	bus := SetupTestBus()
+	dialed := make(chan error, 10)
+	var failures int64
+	b := newBroker(func() (dbusConn, error) {
+		if atomic.AddInt64(&failures, -1) >= 0 {
+			dialed <- errors.New("bus not ready")
+			return nil, errors.New("bus not ready")
+		}
+		dialed <- nil
+		return &droppableConn{testBusConnection: bus.connect()}, nil
+	})
+
+	srv := bus.RegisterService("org.i3barista.services.FooService")
+	obj := srv.Object("/org/i3barista/objects/Foo", "org.i3barista.Service")
+	obj.SetProperty("a", 1, SignalTypeNone)
+
+	w := WatchProperties(BusType(b.connect),
+		"org.i3barista.services.FooService",
+		"/org/i3barista/objects/Foo",
+		"org.i3barista.Service").
+		Add("a")
+	defer w.Unsubscribe()
+	require.NoError(t, <-dialed)
+	require.Equal(t, map[string]interface{}{"a": 1}, w.Get())
+
+	// Simulate the bus going away while the service changes.
+	atomic.StoreInt64(&failures, 1)
+	obj.SetProperty("a", 2, SignalTypeNone)
+	dropConnection(b)
+	require.Error(t, <-dialed, "first reconnect attempt fails")
+	require.NoError(t, <-dialed, "retries reconnecting")
+
+	u := assertUpdated(t, w, "on reconnect")
+	require.Equal(t, PropertiesChange{"a": {1, 2}}, u,
+		"properties are refreshed on reconnect")
+
+	obj.SetProperty("a", 3, SignalTypeChanged)
+	u = assertUpdated(t, w, "on signal after reconnect")
+	require.Equal(t, PropertiesChange{"a": {2, 3}}, u,
+		"signal matches are restored on reconnect")
+
+	srv.Unregister()
+	u = assertUpdated(t, w, "on service going away")
+	require.Equal(t, PropertiesChange{"a": {3, nil}}, u)
+}
+
+func TestBrokerReconnectReleasedNames(t *testing.T) {
+	bus := SetupTestBus()
+	b, dials := testBroker(bus)
+	srv := bus.RegisterService("org.i3barista.services.FooService")
+
+	w := WatchNameOwner(BusType(b.connect), "org.i3barista.services.FooService")
+	defer w.Unsubscribe()
+	require.Equal(t, srv.id, w.GetOwner())
+
+	bus.mu.Lock()
+	srv.unregisterLocked()
+	bus.mu.Unlock()
+	dropConnection(b)
+
+	u := assertNotified(t, w.Updates, "on reconnect")
+	require.Equal(t, NameOwnerChange{"org.i3barista.services.FooService", ""}, u,
+		"names released while disconnected are reported")
+	require.Empty(t, w.GetOwner())
+	require.Equal(t, int64(2), atomic.LoadInt64(dials))
+}
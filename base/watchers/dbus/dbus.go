@@ -14,12 +14,17 @@
 
 // Package dbus provides watchers that notify when dbus name owners or object
 // properties change, and infrastructure for testing code that uses them.
+//
+// All watchers on the session or system bus share a single connection to that
+// bus, which is automatically re-established if the bus restarts.
 package dbus // import "barista.run/base/watchers/dbus"
 
 import (
 	"strings"
 	"sync/atomic"
 
+	"barista.run/doctor"
+
 	"github.com/godbus/dbus"
 )
 
@@ -36,8 +41,29 @@ var (
 	Test BusType = testBus
 )
 
-func sessionBus() dbusConn { return connect(dbus.SessionBusPrivate()) }
-func systemBus() dbusConn  { return connect(dbus.SystemBusPrivate()) }
+func init() {
+	// The session bus is only available when the bar is started from a
+	// desktop session, unlike the system bus.
+	doctor.Register("D-Bus session bus", checkSessionBus)
+}
+
+func checkSessionBus() error {
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		return doctor.Fix(err,
+			"Start the bar from a desktop session, or set DBUS_SESSION_BUS_ADDRESS")
+	}
+	defer conn.Close()
+	return doctor.Fix(conn.Auth(nil), "Check the permissions of the session bus")
+}
+
+var (
+	sessionBroker = newBroker(func() (dbusConn, error) { return dial(dbus.SessionBusPrivate()) })
+	systemBroker  = newBroker(func() (dbusConn, error) { return dial(dbus.SystemBusPrivate()) })
+)
+
+func sessionBus() dbusConn { return sessionBroker.connect() }
+func systemBus() dbusConn  { return systemBroker.connect() }
 func testBus() dbusConn    { return testBusInstance.Load().(*TestBus).connect() }
 
 var testBusInstance atomic.Value // of *TestBus
@@ -97,7 +123,7 @@ func (d dbusName) String() string {
 	return expand(d.iface, d.member)
 }
 
-func connect(bus *dbus.Conn, err error) dbusConn {
+func dial(bus *dbus.Conn, err error) (dbusConn, error) {
 	if err == nil {
 		err = bus.Auth(nil)
 	}
@@ -105,9 +131,12 @@ func connect(bus *dbus.Conn, err error) dbusConn {
 		err = bus.Hello()
 	}
 	if err != nil {
-		panic("Could not connect to dbus: " + err.Error())
+		if bus != nil {
+			bus.Close()
+		}
+		return nil, err
 	}
-	return bus
+	return bus, nil
 }
 
 func shorten(iface, name string) string {
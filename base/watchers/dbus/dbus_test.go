@@ -36,7 +36,10 @@ func TestBusTypes(t *testing.T) {
 	SetupTestBus()
 	require.NotPanics(t, func() { Test() }, "test bus after setup")
 
-	require.Panics(t, func() { connect(nil, errors.New("something")) })
+	_, err := dial(nil, errors.New("something"))
+	require.Error(t, err)
+	b := newBroker(func() (dbusConn, error) { return dial(nil, errors.New("something")) })
+	require.Panics(t, func() { b.connect() })
 }
 
 func TestExpandAndShorten(t *testing.T) {
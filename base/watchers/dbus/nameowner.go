@@ -80,7 +80,6 @@ func (n *NameOwnerWatcher) Unsubscribe() {
 }
 
 func (n *NameOwnerWatcher) listen() {
-	n.conn.Signal(n.dbusCh)
 	for sig := range n.dbusCh {
 		name := sig.Body[0].(string)
 		newOwner := sig.Body[2].(string)
@@ -143,6 +142,7 @@ func watchNameOwner(
 		}
 	}
 	nameOwnerChanged.addMatch(conn, matchOption)
+	conn.Signal(watcher.dbusCh)
 	go watcher.listen()
 	return watcher
 }
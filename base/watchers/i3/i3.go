@@ -0,0 +1,188 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package i3 provides a minimal client for the i3 (and sway) IPC protocol,
+// for subscribing to window manager events.
+package i3 // import "barista.run/base/watchers/i3"
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const magic = "i3-ipc"
+
+// Message types, see https://i3wm.org/docs/ipc.html.
+const (
+	msgSubscribe = 2
+	// Events are sent with the highest bit set.
+	eventBit = 1 << 31
+)
+
+// Event types that can be subscribed to.
+const (
+	Workspace = "workspace"
+	Output    = "output"
+	Mode      = "mode"
+	Window    = "window"
+	Binding   = "binding"
+	Shutdown  = "shutdown"
+	Tick      = "tick"
+)
+
+var eventTypes = map[uint32]string{
+	0: Workspace,
+	1: Output,
+	2: Mode,
+	3: Window,
+	5: Binding,
+	6: Shutdown,
+	7: Tick,
+}
+
+// Event is an event received from the window manager.
+type Event struct {
+	// Type is the type of event, e.g. Mode.
+	Type string
+	// Payload is the JSON payload of the event.
+	Payload json.RawMessage
+}
+
+// ModeEvent is the payload of a Mode event.
+type ModeEvent struct {
+	// Change is the name of the new binding mode, "default" if none.
+	Change string `json:"change"`
+}
+
+// BindingEvent is the payload of a Binding event.
+type BindingEvent struct {
+	Change  string `json:"change"`
+	Binding struct {
+		// Command is the command that the binding ran, e.g. "nop foo".
+		Command string `json:"command"`
+	} `json:"binding"`
+}
+
+// socketPath returns the path to the IPC socket of the running window
+// manager, from the environment, or by asking i3.
+func socketPath() (string, error) {
+	if path := testSocketPath(); path != "" {
+		return path, nil
+	}
+	for _, env := range []string{"I3SOCK", "SWAYSOCK"} {
+		if path := os.Getenv(env); path != "" {
+			return path, nil
+		}
+	}
+	out, err := exec.Command("i3", "--get-socketpath").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not find i3/sway IPC socket: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Subscribe connects to the window manager and subscribes to the given event
+// types. It returns a channel of events, which is closed when the connection
+// to the window manager is lost.
+func Subscribe(events ...string) (<-chan Event, error) {
+	path, err := socketPath()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	payload, _ := json.Marshal(events)
+	if err := write(conn, msgSubscribe, payload); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	typ, reply, err := read(conn)
+	if err == nil && typ != msgSubscribe {
+		err = fmt.Errorf("unexpected reply type %d", typ)
+	}
+	var r struct {
+		Success bool `json:"success"`
+	}
+	if err == nil {
+		err = json.Unmarshal(reply, &r)
+	}
+	if err == nil && !r.Success {
+		err = errors.New("subscription failed")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ch := make(chan Event, 10)
+	go func() {
+		defer conn.Close()
+		defer close(ch)
+		for {
+			typ, payload, err := read(conn)
+			if err != nil {
+				return
+			}
+			if typ&eventBit == 0 {
+				continue
+			}
+			if name, ok := eventTypes[typ&^eventBit]; ok {
+				ch <- Event{Type: name, Payload: payload}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// header is the fixed-size header of each IPC message, which is followed by
+// a payload of the given length. The protocol uses the native byte order,
+// which is little-endian on all platforms supported by i3 and sway.
+type header struct {
+	Magic  [6]byte
+	Length uint32
+	Type   uint32
+}
+
+func write(w io.Writer, typ uint32, payload []byte) error {
+	h := header{Length: uint32(len(payload)), Type: typ}
+	copy(h.Magic[:], magic)
+	if err := binary.Write(w, binary.LittleEndian, h); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func read(r io.Reader) (typ uint32, payload []byte, err error) {
+	var h header
+	if err = binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return 0, nil, err
+	}
+	if string(h.Magic[:]) != magic {
+		return 0, nil, errors.New("invalid i3 IPC message")
+	}
+	payload = make([]byte, h.Length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return h.Type, payload, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func nextEvent(t *testing.T, ch <-chan Event) Event {
+	select {
+	case e, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return e
+	case <-time.After(time.Second):
+		require.Fail(t, "no event received")
+	}
+	return Event{}
+}
+
+func TestSubscribe(t *testing.T) {
+	srv := SetupTestServer()
+	defer srv.Close()
+
+	ch, err := Subscribe(Mode, Binding)
+	require.NoError(t, err)
+	srv.WaitForSubscription()
+
+	srv.Emit(Mode, map[string]interface{}{"change": "resize"})
+	e := nextEvent(t, ch)
+	require.Equal(t, Mode, e.Type)
+	var m ModeEvent
+	require.NoError(t, json.Unmarshal(e.Payload, &m))
+	require.Equal(t, "resize", m.Change)
+
+	srv.Emit(Workspace, map[string]interface{}{"change": "focus"})
+	srv.Emit(Binding, map[string]interface{}{
+		"change":  "run",
+		"binding": map[string]interface{}{"command": "nop foo bar"},
+	})
+	e = nextEvent(t, ch)
+	require.Equal(t, Binding, e.Type, "unsubscribed events are not received")
+	var b BindingEvent
+	require.NoError(t, json.Unmarshal(e.Payload, &b))
+	require.Equal(t, "nop foo bar", b.Binding.Command)
+
+	srv.Close()
+	select {
+	case _, ok := <-ch:
+		require.False(t, ok, "channel closed on disconnect")
+	case <-time.After(time.Second):
+		require.Fail(t, "channel not closed on disconnect")
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	srv := SetupTestServer()
+	srv.Close()
+	testSocketMu.Lock()
+	testSocket = srv.dir + "/nonexistent.sock"
+	testSocketMu.Unlock()
+	defer func() {
+		testSocketMu.Lock()
+		testSocket = ""
+		testSocketMu.Unlock()
+	}()
+	_, err := Subscribe(Mode)
+	require.Error(t, err, "when socket does not exist")
+}
+
+func TestProtocol(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, write(buf, msgSubscribe, []byte(`["mode"]`)))
+	require.Equal(t,
+		append([]byte("i3-ipc\x08\x00\x00\x00\x02\x00\x00\x00"), `["mode"]`...),
+		buf.Bytes())
+	typ, payload, err := read(buf)
+	require.NoError(t, err)
+	require.Equal(t, uint32(msgSubscribe), typ)
+	require.Equal(t, `["mode"]`, string(payload))
+
+	_, _, err = read(bytes.NewBufferString("not-i3\x00\x00\x00\x00\x00\x00\x00\x00"))
+	require.Error(t, err, "with invalid magic")
+	_, _, err = read(bytes.NewBufferString("i3-ipc\x08\x00\x00\x00\x02\x00\x00\x00[]"))
+	require.Error(t, err, "with truncated payload")
+}
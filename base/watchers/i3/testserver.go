@@ -0,0 +1,145 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	testSocket   string
+	testSocketMu sync.Mutex
+)
+
+func testSocketPath() string {
+	testSocketMu.Lock()
+	defer testSocketMu.Unlock()
+	return testSocket
+}
+
+// TestServer is a fake window manager that accepts subscriptions, and can
+// be used to send events to subscribers in tests.
+type TestServer struct {
+	listener net.Listener
+	dir      string
+
+	mu    sync.Mutex
+	conns map[net.Conn][]string
+	subCh chan struct{}
+}
+
+// SetupTestServer starts a fake window manager, and directs all further
+// subscriptions to it. Call Close to clean up when done.
+func SetupTestServer() *TestServer {
+	dir, err := ioutil.TempDir("", "i3-test")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "ipc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		panic(err)
+	}
+	t := &TestServer{
+		listener: listener,
+		dir:      dir,
+		conns:    map[net.Conn][]string{},
+		subCh:    make(chan struct{}, 10),
+	}
+	testSocketMu.Lock()
+	testSocket = path
+	testSocketMu.Unlock()
+	go t.accept()
+	return t
+}
+
+func (t *TestServer) accept() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.serve(conn)
+	}
+}
+
+func (t *TestServer) serve(conn net.Conn) {
+	typ, payload, err := read(conn)
+	if err != nil || typ != msgSubscribe {
+		conn.Close()
+		return
+	}
+	var events []string
+	success := json.Unmarshal(payload, &events) == nil
+	reply, _ := json.Marshal(map[string]bool{"success": success})
+	write(conn, msgSubscribe, reply)
+	if !success {
+		conn.Close()
+		return
+	}
+	t.mu.Lock()
+	t.conns[conn] = events
+	t.mu.Unlock()
+	t.subCh <- struct{}{}
+}
+
+// WaitForSubscription blocks until a client has subscribed to events.
+func (t *TestServer) WaitForSubscription() {
+	<-t.subCh
+}
+
+// Emit sends an event with the given payload, marshalled to JSON, to all
+// clients subscribed to the event type.
+func (t *TestServer) Emit(event string, payload interface{}) {
+	var typ uint32
+	for k, v := range eventTypes {
+		if v == event {
+			typ = k | eventBit
+		}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		panic(err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for conn, events := range t.conns {
+		for _, e := range events {
+			if e == event {
+				write(conn, typ, data)
+			}
+		}
+	}
+}
+
+// Close disconnects all clients and stops the server.
+func (t *TestServer) Close() {
+	t.listener.Close()
+	t.mu.Lock()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.conns = map[net.Conn][]string{}
+	t.mu.Unlock()
+	testSocketMu.Lock()
+	testSocket = ""
+	testSocketMu.Unlock()
+	os.RemoveAll(t.dir)
+}
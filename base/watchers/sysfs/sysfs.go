@@ -0,0 +1,222 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package sysfs watches for changes to devices in sysfs, so that modules can
+update as soon as something changes instead of polling.
+
+Kernel uevents, received over netlink, notify of devices being added, removed,
+or changed, e.g. a battery's status changing when the charger is plugged in.
+Some attributes are not covered by uevents, but the kernel notifies watchers
+of the attribute file instead (e.g. a backlight's actual_brightness), so
+subscriptions can also watch specific files using inotify.
+
+Not all drivers send events for every change, so modules should continue to
+poll, but can poll much less often when Available returns true.
+*/
+package sysfs // import "barista.run/base/watchers/sysfs"
+
+import (
+	"bytes"
+	"path"
+	"sync"
+
+	"barista.run/base/notifier"
+	l "barista.run/logging"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// uevent is a kernel device event.
+type uevent struct {
+	action    string
+	subsystem string
+	devpath   string
+}
+
+// parseUevent parses a uevent message from the kernel, which has a header
+// (action@devpath) followed by null-separated KEY=value pairs.
+func parseUevent(msg []byte) (uevent, bool) {
+	var ev uevent
+	parts := bytes.Split(msg, []byte{0})
+	if len(parts) < 2 || !bytes.Contains(parts[0], []byte("@")) {
+		// Not a kernel uevent, e.g. a message from udev.
+		return ev, false
+	}
+	for _, p := range parts[1:] {
+		kv := bytes.SplitN(p, []byte("="), 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch string(kv[0]) {
+		case "ACTION":
+			ev.action = string(kv[1])
+		case "SUBSYSTEM":
+			ev.subsystem = string(kv[1])
+		case "DEVPATH":
+			ev.devpath = string(kv[1])
+		}
+	}
+	return ev, ev.action != "" && ev.subsystem != ""
+}
+
+var (
+	mu        sync.Mutex
+	subs      = map[*Subscription]struct{}{}
+	started   bool
+	available bool
+)
+
+// start starts listening for uevents if needed, and returns true if uevents
+// are available. Must be called with mu held.
+func start() bool {
+	if started {
+		return available
+	}
+	started = true
+	r, err := openUevents()
+	if err != nil {
+		l.Log("sysfs: uevents not available, modules will poll: %v", err)
+		return false
+	}
+	available = true
+	go listen(r)
+	return true
+}
+
+// Available returns true if kernel uevents are being received, in which case
+// modules can rely on events for most changes.
+func Available() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return start()
+}
+
+func listen(r ueventReader) {
+	for {
+		msg, err := r.Receive()
+		if err != nil {
+			l.Log("sysfs: failed to receive uevent: %v", err)
+			mu.Lock()
+			available = false
+			mu.Unlock()
+			return
+		}
+		if ev, ok := parseUevent(msg); ok {
+			dispatch(ev)
+		}
+	}
+}
+
+func dispatch(ev uevent) {
+	l.Fine("sysfs: %s %s %s", ev.action, ev.subsystem, ev.devpath)
+	mu.Lock()
+	defer mu.Unlock()
+	for s := range subs {
+		if s.subsystem == ev.subsystem {
+			s.notifyFn()
+		}
+	}
+}
+
+// Subscription notifies of changes to devices in a subsystem.
+type Subscription struct {
+	Updates <-chan struct{}
+
+	subsystem string
+	notifyFn  func()
+	fswatcher *fsnotify.Watcher
+}
+
+// Subscribe creates a subscription to uevents for devices in the given
+// subsystem (e.g. "power_supply", "backlight", or "thermal"), and to changes
+// to the given attribute files, if any.
+func Subscribe(subsystem string, files ...string) *Subscription {
+	s := &Subscription{subsystem: subsystem}
+	s.notifyFn, s.Updates = notifier.New()
+	l.Label(s, subsystem)
+	l.Register(s, "Updates")
+	if len(files) > 0 {
+		s.watchFiles(files)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	start()
+	subs[s] = struct{}{}
+	return s
+}
+
+func (s *Subscription) watchFiles(files []string) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		l.Log("%s: inotify not available: %v", l.ID(s), err)
+		return
+	}
+	s.fswatcher = w
+	for _, f := range files {
+		if err := w.Add(f); err != nil {
+			l.Log("%s: cannot watch %s: %v", l.ID(s), path.Base(f), err)
+		}
+	}
+	go func() {
+		for {
+			select {
+			case _, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				s.notifyFn()
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				l.Log("%s: %v", l.ID(s), err)
+			}
+		}
+	}()
+}
+
+// Unsubscribe stops listening for updates and frees any resources used.
+func (s *Subscription) Unsubscribe() {
+	mu.Lock()
+	delete(subs, s)
+	mu.Unlock()
+	if s.fswatcher != nil {
+		s.fswatcher.Close()
+	}
+}
+
+// Tester provides methods to simulate uevents for testing.
+type Tester interface {
+	// Uevent simulates a uevent for a device in a subsystem,
+	// e.g. Uevent("change", "power_supply", "BAT0").
+	Uevent(action, subsystem, device string)
+}
+
+type tester struct{}
+
+func (tester) Uevent(action, subsystem, device string) {
+	dispatch(uevent{action, subsystem, "/devices/test/" + subsystem + "/" + device})
+}
+
+// TestMode puts the sysfs watcher in test mode, where only simulated uevents
+// are received, and Available returns true. It also resets the subscribers,
+// so that modules from earlier tests are not notified.
+func TestMode() Tester {
+	mu.Lock()
+	defer mu.Unlock()
+	started, available = true, true
+	subs = map[*Subscription]struct{}{}
+	return tester{}
+}
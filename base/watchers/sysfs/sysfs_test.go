@@ -0,0 +1,166 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReader chan []byte
+
+func (f fakeReader) Receive() ([]byte, error) {
+	msg, ok := <-f
+	if !ok {
+		return nil, errors.New("closed")
+	}
+	return msg, nil
+}
+
+func msg(parts ...string) []byte {
+	return []byte(strings.Join(parts, "\x00") + "\x00")
+}
+
+// reset resets the watcher state, with uevents read from the returned channel.
+func reset(t *testing.T) chan []byte {
+	ch := make(chan []byte)
+	mu.Lock()
+	defer mu.Unlock()
+	started, available = false, false
+	subs = map[*Subscription]struct{}{}
+	openUevents = func() (ueventReader, error) { return fakeReader(ch), nil }
+	return ch
+}
+
+func assertNotified(t *testing.T, s *Subscription, formatAndArgs ...interface{}) {
+	select {
+	case <-s.Updates:
+	case <-time.After(time.Second):
+		require.Fail(t, "expected an update", formatAndArgs...)
+	}
+}
+
+func assertNotNotified(t *testing.T, s *Subscription, formatAndArgs ...interface{}) {
+	select {
+	case <-s.Updates:
+		require.Fail(t, "unexpected update", formatAndArgs...)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestParseUevent(t *testing.T) {
+	ev, ok := parseUevent(msg(
+		"change@/devices/LNXSYSTM:00/PNP0C0A:00/power_supply/BAT0",
+		"ACTION=change",
+		"DEVPATH=/devices/LNXSYSTM:00/PNP0C0A:00/power_supply/BAT0",
+		"SUBSYSTEM=power_supply",
+		"POWER_SUPPLY_NAME=BAT0",
+		"SEQNUM=4242",
+	))
+	require.True(t, ok)
+	require.Equal(t, uevent{"change", "power_supply",
+		"/devices/LNXSYSTM:00/PNP0C0A:00/power_supply/BAT0"}, ev)
+
+	_, ok = parseUevent(msg("libudev", "garbage"))
+	require.False(t, ok, "udev messages are ignored")
+	_, ok = parseUevent(msg("add@/devices/foo", "ACTION=add"))
+	require.False(t, ok, "events without a subsystem are ignored")
+	_, ok = parseUevent(nil)
+	require.False(t, ok)
+}
+
+func TestUevents(t *testing.T) {
+	ch := reset(t)
+	bat := Subscribe("power_supply")
+	defer bat.Unsubscribe()
+	thermal := Subscribe("thermal")
+	require.True(t, Available())
+
+	ch <- msg("change@/devices/ac/power_supply/AC", "ACTION=change",
+		"DEVPATH=/devices/ac/power_supply/AC", "SUBSYSTEM=power_supply")
+	assertNotified(t, bat, "on power_supply change")
+	assertNotNotified(t, thermal, "on power_supply change")
+
+	ch <- msg("libudev", "SUBSYSTEM=thermal")
+	ch <- msg("change@/devices/virtual/thermal/thermal_zone0", "ACTION=change",
+		"DEVPATH=/devices/virtual/thermal/thermal_zone0", "SUBSYSTEM=thermal")
+	assertNotified(t, thermal, "on thermal change")
+	assertNotNotified(t, bat, "on thermal change")
+
+	thermal.Unsubscribe()
+	ch <- msg("change@/devices/virtual/thermal/thermal_zone0", "ACTION=change",
+		"DEVPATH=/devices/virtual/thermal/thermal_zone0", "SUBSYSTEM=thermal")
+	assertNotNotified(t, thermal, "after unsubscribe")
+
+	close(ch)
+	for start := time.Now(); Available(); time.Sleep(time.Millisecond) {
+		require.True(t, time.Since(start) < time.Second,
+			"not available after receive errors")
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	reset(t)
+	openUevents = func() (ueventReader, error) {
+		return nil, errors.New("permission denied")
+	}
+	s := Subscribe("power_supply")
+	defer s.Unsubscribe()
+	require.False(t, Available())
+}
+
+func TestFiles(t *testing.T) {
+	reset(t)
+	dir, err := ioutil.TempDir("", "sysfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	brightness := filepath.Join(dir, "actual_brightness")
+	require.NoError(t, ioutil.WriteFile(brightness, []byte("10\n"), 0644))
+
+	s := Subscribe("backlight", brightness, filepath.Join(dir, "missing"))
+	assertNotNotified(t, s, "on subscribe")
+
+	require.NoError(t, ioutil.WriteFile(brightness, []byte("20\n"), 0644))
+	assertNotified(t, s, "on file change")
+
+	// Writing the file can produce more than one event.
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-s.Updates:
+	default:
+	}
+	s.Unsubscribe()
+	require.NoError(t, ioutil.WriteFile(brightness, []byte("30\n"), 0644))
+	assertNotNotified(t, s, "after unsubscribe")
+}
+
+func TestTestMode(t *testing.T) {
+	reset(t)
+	tester := TestMode()
+	require.True(t, Available())
+	s := Subscribe("power_supply")
+	defer s.Unsubscribe()
+	tester.Uevent("change", "power_supply", "BAT0")
+	assertNotified(t, s)
+	tester.Uevent("change", "backlight", "intel_backlight")
+	assertNotNotified(t, s)
+}
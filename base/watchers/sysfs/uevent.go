@@ -0,0 +1,61 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+type ueventReader interface {
+	Receive() ([]byte, error)
+}
+
+// socket receives kernel uevents from a netlink socket.
+type socket struct {
+	fd  int
+	buf []byte
+}
+
+func (s *socket) Receive() ([]byte, error) {
+	for {
+		n, _, err := unix.Recvfrom(s.fd, s.buf, 0)
+		if err == unix.EINTR || err == unix.ENOBUFS {
+			// ENOBUFS means some events were dropped, but since subscribers
+			// only need to know that something changed, it is safe to carry on.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s.buf[:n], nil
+	}
+}
+
+// openUevents subscribes to kernel uevents. Replaced in tests.
+var openUevents = func() (ueventReader, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK,
+		unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+	// Group 1 is events from the kernel, as opposed to those rebroadcast
+	// by udev.
+	addr := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}
+	if err := unix.Bind(fd, addr); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return &socket{fd: fd, buf: make([]byte, 16*1024)}, nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"barista.run/bar"
+	"barista.run/group"
+	"barista.run/group/collapsing"
+	"barista.run/modules/battery"
+	"barista.run/modules/clock"
+	"barista.run/modules/coprocess"
+	"barista.run/modules/cpuload"
+	"barista.run/modules/cputemp"
+	"barista.run/modules/diagnostics"
+	"barista.run/modules/diskspace"
+	"barista.run/modules/external"
+	"barista.run/modules/meminfo"
+	"barista.run/modules/netspeed"
+	"barista.run/modules/script"
+	"barista.run/modules/shell"
+	"barista.run/modules/static"
+	"barista.run/modules/sysinfo"
+	"barista.run/modules/uptime"
+	"barista.run/modules/wlan"
+	"barista.run/outputs"
+	"barista.run/plugin"
+)
+
+func init() {
+	RegisterType(ModuleType{
+		Name:        "text",
+		Description: "Static text",
+		Options:     []Option{{Name: "text", Kind: String}},
+		New: func(o *Options) (bar.Module, error) {
+			return static.New(outputs.Text(o.String("text", ""))), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "clock",
+		Description: "The current time",
+		Options: []Option{{
+			Name: "zone", Kind: String, Default: "local time",
+			Description: `Timezone, e.g. "Europe/London"`,
+		}},
+		New: func(o *Options) (bar.Module, error) {
+			if zone := o.String("zone", ""); zone != "" {
+				return clock.ZoneByName(zone)
+			}
+			return clock.Local(), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "shell",
+		Description: "The output of a shell command",
+		Options: []Option{
+			{Name: "command", Kind: String, Description: "Command, run using sh"},
+			{Name: "tail", Kind: Bool, Default: "false",
+				Description: "Show the last line of output from a long running command"},
+			{Name: "dir", Kind: String, Description: "Working directory for the command"},
+			{Name: "timeout", Kind: Duration,
+				Description: "Kill the command if it runs for longer (not for tail)"},
+			{Name: "on_click", Kind: String,
+				Description: "Command run on click, with the click in BLOCK_BUTTON etc. (not for tail)"},
+		},
+		New: func(o *Options) (bar.Module, error) {
+			cmd := o.String("command", "")
+			dir := o.String("dir", "")
+			if o.Bool("tail", false) {
+				return shell.Tail("sh", "-c", cmd).Dir(dir), nil
+			}
+			m := shell.New("sh", "-c", cmd).Dir(dir).Timeout(o.Duration("timeout", 0))
+			if onClick := o.String("on_click", ""); onClick != "" {
+				m.OnClick("sh", "-c", onClick)
+			}
+			return m, nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "external",
+		Description: "An i3blocks blocklet (see barista.run/modules/external)",
+		Options: []Option{
+			{Name: "command", Kind: String, Description: "Blocklet command, run using sh"},
+			{Name: "name", Kind: String, Description: "Block name, passed to the command"},
+			{Name: "instance", Kind: String, Description: "Block instance, passed to the command"},
+			{Name: "persist", Kind: Bool, Default: "false",
+				Description: "Keep the command running, reading a line per update"},
+			{Name: "json", Kind: Bool, Default: "false",
+				Description: "Parse output as JSON instead of lines"},
+			{Name: "signal", Kind: Int, Description: "Update on SIGRTMIN+signal"},
+		},
+		New: func(o *Options) (bar.Module, error) {
+			cmd := o.String("command", "")
+			m := external.New(cmd)
+			if o.Bool("persist", false) {
+				m = external.Persist(cmd)
+			}
+			if name := o.String("name", ""); name != "" {
+				m.Name(name)
+			}
+			if instance := o.String("instance", ""); instance != "" {
+				m.Instance(instance)
+			}
+			if o.Bool("json", false) {
+				m.JSON()
+			}
+			if sig := o.Int("signal", 0); sig > 0 {
+				m.Signal(sig)
+			}
+			return m, nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "coprocess",
+		Description: "A process speaking JSON (see barista.run/modules/coprocess)",
+		Options:     []Option{{Name: "command", Kind: String, Description: "Command, run using sh"}},
+		New: func(o *Options) (bar.Module, error) {
+			return coprocess.New("sh", "-c", o.String("command", "")), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name: "script",
+		Description: "Values printed by a command as key=value lines or JSON, " +
+			"shown using format (see barista.run/modules/script)",
+		Options: []Option{
+			{Name: "command", Kind: String, Description: "Command, run using sh"},
+			{Name: "dir", Kind: String, Description: "Working directory for the command"},
+			{Name: "timeout", Kind: Duration,
+				Description: "Kill the command if it runs for longer"},
+			{Name: "on_click", Kind: String,
+				Description: "Command run on click, with the click in BLOCK_BUTTON etc."},
+		},
+		New: func(o *Options) (bar.Module, error) {
+			m := script.New("sh", "-c", o.String("command", "")).
+				Dir(o.String("dir", "")).
+				Timeout(o.Duration("timeout", 0))
+			if onClick := o.String("on_click", ""); onClick != "" {
+				m.OnClick("sh", "-c", onClick)
+			}
+			return m, nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "plugin",
+		Description: "A module from a Go plugin (see barista.run/plugin)",
+		Options: []Option{
+			{Name: "path", Kind: String, Description: "Path to the plugin"},
+			{Name: "config", Kind: JSON, Description: "Passed to the plugin as JSON"},
+		},
+		New: func(o *Options) (bar.Module, error) {
+			return plugin.Load(o.String("path", ""), o.JSON("config"))
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "cpuload",
+		Description: "System load averages",
+		Options:     []Option{},
+		New: func(o *Options) (bar.Module, error) {
+			return cpuload.New(), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "cputemp",
+		Description: "CPU temperature",
+		Options: []Option{
+			{Name: "zone", Kind: String, Description: "Thermal zone, e.g. \"thermal_zone0\""},
+			{Name: "sensor", Kind: String, Description: "Sensor type, e.g. \"x86_pkg_temp\""},
+		},
+		New: func(o *Options) (bar.Module, error) {
+			if zone := o.String("zone", ""); zone != "" {
+				return cputemp.Zone(zone), nil
+			}
+			if typ := o.String("sensor", ""); typ != "" {
+				return cputemp.OfType(typ), nil
+			}
+			return cputemp.New(), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "meminfo",
+		Description: "Memory usage",
+		Options: []Option{{Name: "interval", Kind: Duration, Default: "3s",
+			Description: "Refresh interval, shared by all meminfo modules"}},
+		New: func(o *Options) (bar.Module, error) {
+			if o.Has("interval") {
+				meminfo.RefreshInterval(o.Duration("interval", 0))
+			}
+			return meminfo.New(), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "sysinfo",
+		Description: "System information, e.g. uptime and load",
+		Options: []Option{{Name: "interval", Kind: Duration, Default: "3s",
+			Description: "Refresh interval, shared by all sysinfo modules"}},
+		New: func(o *Options) (bar.Module, error) {
+			if o.Has("interval") {
+				sysinfo.RefreshInterval(o.Duration("interval", 0))
+			}
+			return sysinfo.New(), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "uptime",
+		Description: "System uptime",
+		Options:     []Option{},
+		New: func(o *Options) (bar.Module, error) {
+			return uptime.New(), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "diagnostics",
+		Description: "Recent warnings and errors from the bar",
+		Options: []Option{{Name: "file", Kind: String,
+			Description: "Where recent problems are written when clicked"}},
+		New: func(o *Options) (bar.Module, error) {
+			m := diagnostics.New()
+			if file := o.String("file", ""); file != "" {
+				m.DumpTo(file)
+			}
+			return m, nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "diskspace",
+		Description: "Free space on a filesystem",
+		Options:     []Option{{Name: "path", Kind: String, Default: "/"}},
+		New: func(o *Options) (bar.Module, error) {
+			return diskspace.New(o.String("path", "/")), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "battery",
+		Description: "Battery charge and status",
+		Options: []Option{{Name: "name", Kind: String, Default: "all batteries",
+			Description: `Battery name, e.g. "BAT0"`}},
+		New: func(o *Options) (bar.Module, error) {
+			if name := o.String("name", ""); name != "" {
+				return battery.Named(name), nil
+			}
+			return battery.All(), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "netspeed",
+		Description: "Network transfer rates",
+		Options:     []Option{{Name: "interface", Kind: String}},
+		New: func(o *Options) (bar.Module, error) {
+			return netspeed.New(o.String("interface", "")), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "wlan",
+		Description: "Wireless network",
+		Options:     []Option{{Name: "interface", Kind: String, Default: "any"}},
+		New: func(o *Options) (bar.Module, error) {
+			if iface := o.String("interface", ""); iface != "" {
+				return wlan.Named(iface), nil
+			}
+			return wlan.Any(), nil
+		},
+	})
+
+	RegisterType(ModuleType{
+		Name:        "group",
+		Description: "Shows all of its modules",
+		Options:     []Option{},
+		Group:       true,
+		New: func(o *Options) (bar.Module, error) {
+			return group.Simple(o.Modules()...), nil
+		},
+	})
+	RegisterType(ModuleType{
+		Name:        "collapsing",
+		Description: "Modules that can be collapsed by clicking",
+		Options: []Option{
+			{Name: "auto_collapse", Kind: Duration,
+				Description: "Collapse automatically after being expanded for this long"},
+			{Name: "expanded", Kind: Bool, Default: "false", Description: "Start expanded"},
+			{Name: "persist", Kind: String,
+				Description: "Key to persist the expanded state across restarts"},
+		},
+		Group: true,
+		New: func(o *Options) (bar.Module, error) {
+			grp, ctrl := collapsing.Group(o.Modules()...)
+			ctrl.AutoCollapseAfter(o.Duration("auto_collapse", 0))
+			if o.Bool("expanded", false) {
+				ctrl.Expand()
+			}
+			ctrl.Persist(o.String("persist", ""))
+			return grp, nil
+		},
+	})
+}
@@ -0,0 +1,326 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package config builds a bar from a declarative YAML file, allowing simple bars
+to be configured without writing any Go. For example:
+
+	colors:
+	  good: "#6d6"
+	  bad: "#d66"
+	modules:
+	  - type: cpuload
+	    format: 'Load: {{printf "%.2f" .Min1}}'
+	    interval: 5s
+	  - type: collapsing
+	    modules:
+	      - type: diskspace
+	        path: /
+	        format: '/: {{bytes .Available}}'
+	  - type: clock
+	    format: '{{.Format "Mon Jan 2 15:04"}}'
+	    interval: 1m
+	    color: good
+
+Each module has a type, any options specific to that type (e.g. path for
+diskspace), and the following common options:
+
+format is a text/template for the module's output, applied to the value that
+the module passes to its Output function (e.g. diskspace.Info). An empty result
+hides the module. In addition to the standard template functions, bytes, rate,
+unit, and duration format values using barista.run/format.
+
+pango, if true, treats the formatted output as pango markup.
+
+color sets the colour of the output, either a colour scheme name (from the
+colors section, or the bar configuration) or a hex colour.
+
+interval sets how often the module refreshes, e.g. "30s". For clocks, this is
+the granularity of the displayed time.
+
+The built-in module types, and their options, are:
+
+	text: text
+	clock: zone (e.g. "Europe/London", local time by default)
+	shell: command (run using sh), tail (if true, show the last line of output
+	       from a long running command), dir, timeout, on_click (a command
+	       run on click, see barista.run/modules/shell)
+	external: command (an i3blocks blocklet), name, instance, persist, json,
+	          signal (see barista.run/modules/external)
+	coprocess: command (run using sh, see barista.run/modules/coprocess)
+	script: command (run using sh, printing key=value lines or JSON for the
+	        format, see barista.run/modules/script), dir, timeout, on_click
+	plugin: path (to a Go plugin, see barista.run/plugin), config (passed to
+	        the plugin as JSON)
+	cpuload, meminfo, sysinfo, uptime
+	cputemp: zone or sensor (e.g. "x86_pkg_temp")
+	diagnostics: file (where recent problems are written when clicked)
+	diskspace: path (default "/")
+	battery: name (all batteries by default)
+	netspeed: interface
+	wlan: interface (any by default)
+
+Groups contain other modules under modules. The built-in group types are group,
+which shows all its modules, and collapsing, with the options auto_collapse (a
+duration), expanded, and persist (see collapsing.Controller). Other packages
+can add module types using Register, or RegisterType to also describe the type
+and its options. All registered types and their options are available from
+ModuleTypes, and configurations are checked against the described options
+before any modules are created.
+
+Since JSON is a subset of YAML, configuration can also be written in JSON.
+
+Bars started using Run are reloaded when the configuration file changes, or on
+SIGHUP. Modules whose configuration has not changed are kept running, so they
+keep their state, and only new or changed modules are (re)created.
+*/
+package config // import "barista.run/config"
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"barista.run"
+	"barista.run/bar"
+	"barista.run/base/watchers/file"
+	"barista.run/colors"
+	l "barista.run/logging"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is a declarative bar configuration.
+type Config struct {
+	// Colors are added to the colour scheme.
+	Colors map[string]string `yaml:"colors"`
+	// Modules are the modules on the bar, from left to right.
+	Modules []Module `yaml:"modules"`
+}
+
+// Module describes a single module, or a group of modules.
+type Module struct {
+	Type   string
+	Format string
+	Pango  bool
+	Color  string
+	// Modules are the modules in a group.
+	Modules []Module
+	// Options holds all other keys, for the module's constructor.
+	Options map[string]interface{}
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, separating the common keys from
+// the module-specific options.
+func (m *Module) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var common struct {
+		Type    string   `yaml:"type"`
+		Format  string   `yaml:"format"`
+		Pango   bool     `yaml:"pango"`
+		Color   string   `yaml:"color"`
+		Modules []Module `yaml:"modules"`
+	}
+	if err := unmarshal(&common); err != nil {
+		return err
+	}
+	var options map[string]interface{}
+	if err := unmarshal(&options); err != nil {
+		return err
+	}
+	for _, k := range []string{"type", "format", "pango", "color", "modules"} {
+		delete(options, k)
+	}
+	*m = Module{
+		Type:    common.Type,
+		Format:  common.Format,
+		Pango:   common.Pango,
+		Color:   common.Color,
+		Modules: common.Modules,
+		Options: options,
+	}
+	return nil
+}
+
+// Parse parses a configuration from YAML (or JSON).
+func Parse(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load reads and parses a configuration file.
+func Load(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return c, nil
+}
+
+// Build loads the colours from the configuration, and creates its modules.
+// Errors include the path to the module that failed, e.g. "modules[2].modules[0]".
+func (c *Config) Build() ([]bar.Module, error) {
+	colors.LoadFromMap(c.Colors)
+	return buildAll(c.Modules, "modules")
+}
+
+func buildAll(configs []Module, path string) ([]bar.Module, error) {
+	var mods []bar.Module
+	for i, cfg := range configs {
+		m, err := build(cfg, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, m)
+	}
+	return mods, nil
+}
+
+func build(cfg Module, path string) (bar.Module, error) {
+	typ, ok := Lookup(cfg.Type)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown module type %q", path, cfg.Type)
+	}
+	if err := typ.validate(cfg); err != nil {
+		return nil, fmt.Errorf("%s (%s): %v", path, cfg.Type, err)
+	}
+	children, err := buildAll(cfg.Modules, path+".modules")
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(cfg.Options, children)
+	m, err := typ.New(o)
+	if err == nil {
+		m, err = configure(m, cfg, o)
+	}
+	if err == nil {
+		err = o.check()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s (%s): %v", path, cfg.Type, err)
+	}
+	return m, nil
+}
+
+// Builder builds modules from configurations, reusing the modules built
+// previously for any unchanged top-level module configuration, so that they
+// keep their state when a bar is reloaded (see barista.OnReload). A group is
+// rebuilt if any of its modules changed. Since colours are resolved when the
+// modules are built, a change to the colors section rebuilds all modules.
+type Builder struct {
+	colors string
+	// Modules built previously, by their configuration. Identical
+	// configurations are reused in order.
+	modules map[string][]bar.Module
+}
+
+// NewBuilder creates a new Builder.
+func NewBuilder() *Builder {
+	return &Builder{modules: map[string][]bar.Module{}}
+}
+
+// Build loads the colours from the configuration, and returns its modules,
+// reusing any modules that were built before from the same configuration.
+func (b *Builder) Build(c *Config) ([]bar.Module, error) {
+	colorsKey := marshalKey(c.Colors)
+	previous := b.modules
+	if colorsKey != b.colors {
+		previous = nil
+	}
+	colors.LoadFromMap(c.Colors)
+	used := map[string]int{}
+	built := map[string][]bar.Module{}
+	var mods []bar.Module
+	for i, cfg := range c.Modules {
+		key := marshalKey(cfg)
+		var m bar.Module
+		if idx := used[key]; key != "" && idx < len(previous[key]) {
+			m = previous[key][idx]
+			used[key]++
+		} else {
+			var err error
+			m, err = build(cfg, fmt.Sprintf("modules[%d]", i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if key != "" {
+			built[key] = append(built[key], m)
+		}
+		mods = append(mods, m)
+	}
+	// Modules that were removed are dropped, since the bar blocks removed
+	// modules and they must not be added to it again.
+	b.modules = built
+	b.colors = colorsKey
+	return mods, nil
+}
+
+// Load builds the modules from the given configuration file.
+func (b *Builder) Load(filename string) ([]bar.Module, error) {
+	c, err := Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := b.Build(c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return mods, nil
+}
+
+// marshalKey returns a key for comparing configurations, or "" if the
+// configuration cannot be marshalled (e.g. if it was not parsed from YAML),
+// in which case modules are always rebuilt.
+func marshalKey(v interface{}) string {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// Run builds a bar from the given configuration file, and runs it. The bar is
+// reloaded when the file changes, or on SIGHUP, keeping any modules whose
+// configuration is unchanged.
+func Run(filename string) error {
+	b := NewBuilder()
+	mods, err := b.Load(filename)
+	if err != nil {
+		return err
+	}
+	barista.OnReload(func() ([]bar.Module, error) {
+		return b.Load(filename)
+	})
+	go reloadOnChange(file.Watch(filename))
+	return barista.Run(mods...)
+}
+
+func reloadOnChange(w *file.Watcher) {
+	for {
+		select {
+		case <-w.Updates:
+			barista.Reload()
+		case err := <-w.Errors:
+			// The watcher stops on errors, but SIGHUP still reloads the bar.
+			l.Log("Not watching configuration for changes: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/colors"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// counter is a module for tests, with an Output function that takes an int.
+type counter struct {
+	outputFunc value.Value // of func(int) bar.Output
+	interval   time.Duration
+}
+
+func (c *counter) Output(f func(int) bar.Output) *counter {
+	c.outputFunc.Set(f)
+	return c
+}
+
+func (c *counter) RefreshInterval(d time.Duration) *counter {
+	c.interval = d
+	return c
+}
+
+func (c *counter) Stream(s bar.Sink) {
+	sch := timing.NewScheduler().Every(time.Second)
+	for i := 0; ; i++ {
+		f, _ := c.outputFunc.Get().(func(int) bar.Output)
+		if f != nil {
+			s.Output(f(i))
+		}
+		<-sch.C
+	}
+}
+
+var counters []*counter
+
+func init() {
+	Register("counter", func(o *Options) (bar.Module, error) {
+		c := &counter{}
+		counters = append(counters, c)
+		return c, nil
+	})
+}
+
+func parseAndBuild(t *testing.T, yaml string) ([]bar.Module, error) {
+	c, err := Parse([]byte(yaml))
+	require.NoError(t, err)
+	return c.Build()
+}
+
+func TestBuild(t *testing.T) {
+	testBar.New(t)
+	counters = nil
+	mods, err := parseAndBuild(t, `
+colors:
+  accent: "#ff0000"
+modules:
+  - type: text
+    text: hello
+    color: accent
+  - type: counter
+    format: 'count: {{.}}'
+    interval: 5s
+  - type: group
+    modules:
+      - type: text
+        text: a
+      - type: counter
+        format: '<b>{{printf "%03d" .}}</b>'
+        pango: true
+        color: "#00ff00"
+  - type: clock
+    zone: UTC
+    format: '{{.Format "15:04:05"}}'
+`)
+	require.NoError(t, err)
+	require.Len(t, mods, 4)
+	require.Equal(t, 5*time.Second, counters[0].interval)
+	require.Equal(t, time.Duration(0), counters[1].interval)
+
+	testBar.Run(mods...)
+	// Groups may output before their modules, so wait for all updates.
+	out := testBar.Drain(100 * time.Millisecond)
+	out.AssertText([]string{"hello", "count: 0", "a", "<b>000</b>",
+		timing.Now().In(time.UTC).Format("15:04:05")})
+	c, _ := out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Hex("#ff0000"), c, "color from colors section")
+	c, _ = out.At(3).Segment().GetColor()
+	require.Equal(t, colors.Hex("#00ff00"), c, "hex color")
+	_, isPango := out.At(3).Segment().Content()
+	require.True(t, isPango)
+}
+
+func TestEmptyFormat(t *testing.T) {
+	testBar.New(t)
+	mods, err := parseAndBuild(t, `
+modules:
+  - type: counter
+    format: '{{if .}}{{.}}{{end}}'
+`)
+	require.NoError(t, err)
+	testBar.Run(mods...)
+	testBar.NextOutput().AssertEmpty("on empty template output")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1"})
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct{ yaml, err string }{
+		{"modules: [{type: foo}]", `modules[0]: unknown module type "foo"`},
+		{"modules: [{type: group, modules: [{type: text, txt: a}]}]",
+			"modules[0].modules[0] (text): unknown options: txt"},
+		{"modules: [{type: text, text: 1}]",
+			"modules[0] (text): option text: expected a string, got 1"},
+		{"modules: [{type: counter, interval: soon}]",
+			"modules[0] (counter): option interval: expected a duration, got soon"},
+		{"modules: [{type: text, interval: 1m}]",
+			"modules[0] (text): interval is not supported"},
+		{"modules: [{type: text, format: '{{.}}'}]",
+			"modules[0] (text): format is not supported"},
+		{"modules: [{type: counter, color: nope}]",
+			`modules[0] (counter): unknown color "nope"`},
+		{"modules: [{type: counter, modules: [{type: text}]}]",
+			"modules[0] (counter): modules are not supported"},
+	} {
+		_, err := parseAndBuild(t, tc.yaml)
+		require.EqualError(t, err, tc.err, tc.yaml)
+	}
+	_, err := parseAndBuild(t, "modules: [{type: counter, format: '{{if}}'}]")
+	require.Contains(t, err.Error(), "modules[0] (counter): template: format:1:")
+	_, err = Parse([]byte("modules: {type: text}"))
+	require.Error(t, err, "invalid yaml")
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = Load(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+
+	filename := filepath.Join(dir, "bar.json")
+	require.NoError(t, ioutil.WriteFile(filename,
+		[]byte(`{"modules": [{"type": "text", "text": "json"}]}`), 0644))
+	c, err := Load(filename)
+	require.NoError(t, err)
+	require.Equal(t, []Module{{Type: "text", Options: map[string]interface{}{"text": "json"}}},
+		c.Modules)
+
+	require.NoError(t, ioutil.WriteFile(filename, []byte("modules: ["), 0644))
+	_, err = Load(filename)
+	require.Contains(t, err.Error(), filename)
+
+	require.Contains(t, Types(), "clock")
+	require.Contains(t, Types(), "collapsing")
+}
+
+func TestModuleTypes(t *testing.T) {
+	clock, ok := Lookup("clock")
+	require.True(t, ok)
+	require.Equal(t, "clock", clock.Name)
+	require.NotEmpty(t, clock.Description)
+	require.Equal(t, []Option{{
+		Name: "zone", Kind: String, Default: "local time",
+		Description: `Timezone, e.g. "Europe/London"`,
+	}}, clock.Options)
+
+	_, ok = Lookup("not-a-type")
+	require.False(t, ok)
+
+	var names []string
+	for _, typ := range ModuleTypes() {
+		names = append(names, typ.Name)
+		if typ.Name == "counter" || typ.Name == "test-json" {
+			continue
+		}
+		require.NotEmpty(t, typ.Description, "built-in type %s", typ.Name)
+		require.NotNil(t, typ.New)
+	}
+	require.Equal(t, Types(), names)
+
+	created := 0
+	RegisterType(ModuleType{
+		Name:        "test-described",
+		Description: "For tests",
+		Options: []Option{
+			{Name: "count", Kind: Int},
+			{Name: "extra", Kind: JSON},
+		},
+		New: func(o *Options) (bar.Module, error) {
+			created++
+			o.Int("count", 0)
+			o.JSON("extra")
+			return &counter{}, nil
+		},
+	})
+	for _, tc := range []struct{ yaml, err string }{
+		{"modules: [{type: test-described, count: many}]",
+			"modules[0] (test-described): option count: expected an integer, got many"},
+		{"modules: [{type: test-described, cuont: 1}]",
+			"modules[0] (test-described): unknown options: cuont"},
+		{"modules: [{type: test-described, interval: soon}]",
+			"modules[0] (test-described): option interval: expected a duration, got soon"},
+		{"modules: [{type: test-described, modules: [{type: text}]}]",
+			"modules[0] (test-described): modules are not supported"},
+	} {
+		_, err := parseAndBuild(t, tc.yaml)
+		require.EqualError(t, err, tc.err, tc.yaml)
+	}
+	require.Equal(t, 0, created, "modules are not created with invalid options")
+
+	_, err := parseAndBuild(t, "modules: [{type: test-described, count: 2, extra: [a]}]")
+	require.NoError(t, err)
+	require.Equal(t, 1, created)
+}
+
+func TestBuilder(t *testing.T) {
+	build := func(b *Builder, yaml string) []bar.Module {
+		c, err := Parse([]byte(yaml))
+		require.NoError(t, err)
+		mods, err := b.Build(c)
+		require.NoError(t, err)
+		return mods
+	}
+	b := NewBuilder()
+	first := build(b, `
+modules:
+  - {type: counter, format: "a"}
+  - {type: counter, format: "a"}
+  - {type: text, text: "b"}
+  - {type: group, modules: [{type: counter}]}
+`)
+	require.Len(t, first, 4)
+
+	second := build(b, `
+modules:
+  - {type: text, text: "c"}
+  - {type: counter, format: "a"}
+  - {type: group, modules: [{type: counter, format: "x"}]}
+  - {type: counter, format: "a"}
+`)
+	require.False(t, first[2] == second[0], "changed module is rebuilt")
+	require.True(t, first[0] == second[1], "unchanged module is kept")
+	require.True(t, first[1] == second[3], "identical modules are kept in order")
+	require.False(t, first[3] == second[2], "group with changed module is rebuilt")
+
+	third := build(b, `
+modules:
+  - {type: text, text: "b"}
+  - {type: group, modules: [{type: counter, format: "x"}]}
+`)
+	require.False(t, first[2] == third[0], "removed module is rebuilt when added back")
+	require.True(t, second[2] == third[1])
+
+	fourth := build(b, `
+colors: {good: "#0f0"}
+modules:
+  - {type: text, text: "b"}
+`)
+	require.False(t, third[0] == fourth[0], "all modules rebuilt on colour change")
+
+	_, err := b.Build(&Config{Modules: []Module{{Type: "unknown"}}})
+	require.Error(t, err)
+	fifth := build(b, `
+colors: {good: "#0f0"}
+modules:
+  - {type: text, text: "b"}
+`)
+	require.True(t, fourth[0] == fifth[0], "modules kept after a failed build")
+}
+
+func TestBuilderLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	b := NewBuilder()
+	_, err = b.Load(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+
+	filename := filepath.Join(dir, "bar.yaml")
+	require.NoError(t, ioutil.WriteFile(filename,
+		[]byte("modules: [{type: text, text: a}]"), 0644))
+	first, err := b.Load(filename)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filename,
+		[]byte("modules: [{type: text, text: b}, {type: text, text: a}]"), 0644))
+	second, err := b.Load(filename)
+	require.NoError(t, err)
+	require.Len(t, second, 2)
+	require.True(t, first[0] == second[1])
+
+	require.NoError(t, ioutil.WriteFile(filename,
+		[]byte("modules: [{type: text, text: a, foo: bar}]"), 0644))
+	_, err = b.Load(filename)
+	require.Contains(t, err.Error(), filename)
+	require.Contains(t, err.Error(), "modules[0] (text)")
+}
+
+func TestExternal(t *testing.T) {
+	testBar.New(t)
+	mods, err := parseAndBuild(t, `
+modules:
+  - type: external
+    command: echo "hi $instance"
+    instance: there
+    format: '{{.FullText}}!'
+    interval: 10
+`)
+	require.NoError(t, err)
+	testBar.Run(mods...)
+	testBar.NextOutput().AssertText([]string{"hi there!"})
+}
+
+func TestScript(t *testing.T) {
+	testBar.New(t)
+	mods, err := parseAndBuild(t, `
+modules:
+  - type: script
+    command: 'echo user=alice; echo load=0.5'
+    format: '{{.user}}: {{.load}}'
+    interval: 10s
+    timeout: 5s
+`)
+	require.NoError(t, err)
+	testBar.Run(mods...)
+	testBar.NextOutput().AssertText([]string{"alice: 0.5"})
+}
+
+func TestJSONOption(t *testing.T) {
+	var config json.RawMessage
+	Register("test-json", func(o *Options) (bar.Module, error) {
+		config = o.JSON("config")
+		require.Nil(t, o.JSON("missing"))
+		return &counter{}, nil
+	})
+	_, err := parseAndBuild(t, `
+modules:
+  - type: test-json
+    config: {a: [1, {b: c}], 2: true}
+`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": [1, {"b": "c"}], "2": true}`, string(config))
+
+	_, err = parseAndBuild(t, `modules: [{type: plugin, path: /does/not/exist.so}]`)
+	require.Contains(t, err.Error(), "modules[0] (plugin)")
+}
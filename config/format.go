@@ -0,0 +1,173 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/format"
+	"barista.run/modules/meta/reformat"
+	"barista.run/outputs"
+
+	"github.com/martinlindhe/unit"
+)
+
+// funcs are the additional functions available to format templates.
+var funcs = template.FuncMap{
+	"bytes": func(v unit.Datasize) string { return format.IBytesize(v) },
+	"rate":  func(v unit.Datarate) string { return format.IByterate(v) },
+	"unit": func(v interface{}) string {
+		if vals, ok := format.Unit(v); ok {
+			return vals.String()
+		}
+		return fmt.Sprint(v)
+	},
+	"duration": func(d time.Duration) string { return format.Duration(d).String() },
+}
+
+var (
+	outputType   = reflect.TypeOf((*bar.Output)(nil)).Elem()
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// configure applies the common options to a newly constructed module.
+func configure(m bar.Module, cfg Module, o *Options) (bar.Module, error) {
+	// Some constructors use the interval themselves, e.g. for modules with
+	// a package-level refresh interval.
+	constructorUsed := o.used["interval"]
+	interval := o.Duration("interval", 0)
+	if constructorUsed {
+		interval = 0
+	}
+	intervalUsed := interval == 0
+	if cfg.Format != "" {
+		usesInterval, err := setFormat(m, cfg.Format, cfg.Pango, interval)
+		if err != nil {
+			return nil, err
+		}
+		intervalUsed = intervalUsed || usesInterval
+	}
+	if !intervalUsed {
+		if !callWithDuration(m, interval, "RefreshInterval", "Every") {
+			return nil, errors.New("interval is not supported")
+		}
+	}
+	if cfg.Color != "" {
+		c, err := parseColor(cfg.Color)
+		if err != nil {
+			return nil, err
+		}
+		m = reformat.New(m).Format(reformat.EachSegment(
+			reformat.SkipErrors(func(s *bar.Segment) *bar.Segment {
+				return s.Color(c)
+			})))
+	}
+	return m, nil
+}
+
+// setFormat sets the module's output to the result of a template, using the
+// module's Output method, which must take a function from some type (used as
+// the template's data) to bar.Output. Output methods that also take a leading
+// time.Duration (e.g. clock) are given the interval, and setFormat returns true
+// if the interval was used in this way.
+func setFormat(m bar.Module, text string, pango bool, interval time.Duration) (bool, error) {
+	tmpl, err := template.New("format").Funcs(funcs).Parse(text)
+	if err != nil {
+		return false, err
+	}
+	method := reflect.ValueOf(m).MethodByName("Output")
+	if !method.IsValid() {
+		return false, errors.New("format is not supported")
+	}
+	typ := method.Type()
+	n := typ.NumIn()
+	if n < 1 || n > 2 || (n == 2 && typ.In(0) != durationType) {
+		return false, errors.New("format is not supported")
+	}
+	fnType := typ.In(n - 1)
+	if fnType.Kind() != reflect.Func || fnType.NumIn() != 1 ||
+		fnType.NumOut() != 1 || fnType.Out(0) != outputType {
+		return false, errors.New("format is not supported")
+	}
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		out := reflect.New(outputType).Elem()
+		if o := render(tmpl, args[0].Interface(), pango); o != nil {
+			out.Set(reflect.ValueOf(o))
+		}
+		return []reflect.Value{out}
+	})
+	if n == 1 {
+		method.Call([]reflect.Value{fn})
+		return false, nil
+	}
+	if interval == 0 {
+		interval = time.Second
+	}
+	method.Call([]reflect.Value{reflect.ValueOf(interval), fn})
+	return true, nil
+}
+
+func render(tmpl *template.Template, data interface{}, pango bool) bar.Output {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return outputs.Error(err)
+	}
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		return nil
+	}
+	if pango {
+		return bar.PangoSegment(text)
+	}
+	return outputs.Text(text)
+}
+
+// callWithDuration calls the first of the named methods that exists on the
+// module with the given duration, and returns false if none exist.
+func callWithDuration(m bar.Module, d time.Duration, names ...string) bool {
+	for _, name := range names {
+		method := reflect.ValueOf(m).MethodByName(name)
+		if !method.IsValid() {
+			continue
+		}
+		typ := method.Type()
+		if typ.NumIn() == 1 && typ.In(0) == durationType {
+			method.Call([]reflect.Value{reflect.ValueOf(d)})
+			return true
+		}
+	}
+	return false
+}
+
+func parseColor(name string) (colors.ColorfulColor, error) {
+	var c colors.ColorfulColor
+	if strings.HasPrefix(name, "#") {
+		c = colors.Hex(name)
+	} else {
+		c = colors.Scheme(name)
+	}
+	if c == nil {
+		return nil, fmt.Errorf("unknown color %q", name)
+	}
+	return c, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+)
+
+// Options provides the options for a module to its Constructor. Options that
+// are missing use the given default, and errors (e.g. an option of the wrong
+// type) are reported after the constructor returns, so that constructors can
+// read options without checking for errors. Any options that are not read by
+// the constructor are also reported as errors, to catch typos.
+type Options struct {
+	values      map[string]interface{}
+	used        map[string]bool
+	modules     []bar.Module
+	modulesUsed bool
+	err         error
+}
+
+func newOptions(values map[string]interface{}, modules []bar.Module) *Options {
+	return &Options{values: values, used: map[string]bool{}, modules: modules}
+}
+
+// Has returns true if the option is set.
+func (o *Options) Has(key string) bool {
+	_, ok := o.values[key]
+	return ok
+}
+
+func (o *Options) get(key string) (interface{}, bool) {
+	o.used[key] = true
+	v, ok := o.values[key]
+	return v, ok
+}
+
+func (o *Options) setErr(key string, v interface{}, typ string) {
+	if o.err == nil {
+		o.err = fmt.Errorf("option %s: expected %s, got %v", key, typ, v)
+	}
+}
+
+// String returns the value of a string option.
+func (o *Options) String(key, def string) string {
+	v, ok := o.get(key)
+	if !ok {
+		return def
+	}
+	s, ok := v.(string)
+	if !ok {
+		o.setErr(key, v, "a string")
+		return def
+	}
+	return s
+}
+
+// Int returns the value of an integer option.
+func (o *Options) Int(key string, def int) int {
+	v, ok := o.get(key)
+	if !ok {
+		return def
+	}
+	i, ok := v.(int)
+	if !ok {
+		o.setErr(key, v, "an integer")
+		return def
+	}
+	return i
+}
+
+// Bool returns the value of a boolean option.
+func (o *Options) Bool(key string, def bool) bool {
+	v, ok := o.get(key)
+	if !ok {
+		return def
+	}
+	b, ok := v.(bool)
+	if !ok {
+		o.setErr(key, v, "true or false")
+		return def
+	}
+	return b
+}
+
+// Duration returns the value of a duration option, which can be given as a
+// string (e.g. "1m30s"), or a number of seconds.
+func (o *Options) Duration(key string, def time.Duration) time.Duration {
+	v, ok := o.get(key)
+	if !ok {
+		return def
+	}
+	switch d := v.(type) {
+	case int:
+		return time.Duration(d) * time.Second
+	case float64:
+		return time.Duration(d * float64(time.Second))
+	case string:
+		if dur, err := time.ParseDuration(d); err == nil {
+			return dur
+		}
+	}
+	o.setErr(key, v, "a duration")
+	return def
+}
+
+// JSON returns the value of an option as JSON, for options with arbitrary
+// structure, or nil if the option is not set.
+func (o *Options) JSON(key string) json.RawMessage {
+	v, ok := o.get(key)
+	if !ok {
+		return nil
+	}
+	out, err := json.Marshal(jsonValue(v))
+	if err != nil {
+		o.setErr(key, v, "a JSON value")
+		return nil
+	}
+	return out
+}
+
+// jsonValue converts the maps parsed from YAML, which can have any keys, to
+// maps with string keys for encoding as JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, val := range v {
+			m[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = jsonValue(val)
+		}
+		return s
+	}
+	return v
+}
+
+// Modules returns the modules in a group.
+func (o *Options) Modules() []bar.Module {
+	o.modulesUsed = true
+	return o.modules
+}
+
+// check returns the first error from reading options, or an error listing
+// any options that were not used.
+func (o *Options) check() error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(o.modules) > 0 && !o.modulesUsed {
+		return errors.New("modules are not supported")
+	}
+	var unused []string
+	for k := range o.values {
+		if !o.used[k] {
+			unused = append(unused, k)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return fmt.Errorf("unknown options: %s", strings.Join(unused, ", "))
+	}
+	return nil
+}
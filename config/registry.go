@@ -0,0 +1,145 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"barista.run/bar"
+)
+
+// Constructor creates a module of a registered type from its options.
+// Constructors for groups get the modules in the group from o.Modules().
+type Constructor func(o *Options) (bar.Module, error)
+
+// Kind is the kind of value expected for an option.
+type Kind string
+
+// Kinds of option values, corresponding to the methods of Options.
+const (
+	String   Kind = "string"
+	Int      Kind = "int"
+	Bool     Kind = "bool"
+	Duration Kind = "duration"
+	// JSON options can have any value, see Options.JSON.
+	JSON Kind = "json"
+)
+
+// Option describes an option of a module type.
+type Option struct {
+	Name string
+	Kind Kind
+	// Default describes the value used if the option is not set, if any.
+	Default     string
+	Description string
+}
+
+// ModuleType describes a module type that can be used in configuration files,
+// for documentation (e.g. listing the available modules) and validation.
+type ModuleType struct {
+	Name        string
+	Description string
+	// Options describes all of the module's options. Configurations with
+	// other options, or options of the wrong kind, are rejected before the
+	// module is created.
+	Options []Option
+	// Group is true for types that contain other modules.
+	Group bool
+	New   Constructor
+	// unchecked is true for types added using Register, which have no
+	// description of their options.
+	unchecked bool
+}
+
+var (
+	types   = map[string]ModuleType{}
+	typesMu sync.RWMutex
+)
+
+// RegisterType adds a module type, allowing it to be used in configuration
+// files. Registering an existing type replaces it.
+func RegisterType(t ModuleType) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	types[t.Name] = t
+}
+
+// Register adds a module type without a description or options, allowing it
+// to be used in configuration files. Registering an existing type replaces it.
+func Register(typ string, c Constructor) {
+	RegisterType(ModuleType{Name: typ, New: c, unchecked: true})
+}
+
+// Lookup returns the registered module type with the given name.
+func Lookup(name string) (ModuleType, bool) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	t, ok := types[name]
+	return t, ok
+}
+
+// Types returns the names of all registered module types.
+func Types() []string {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	var names []string
+	for n := range types {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ModuleTypes returns all registered module types, sorted by name.
+func ModuleTypes() []ModuleType {
+	var all []ModuleType
+	for _, n := range Types() {
+		if t, ok := Lookup(n); ok {
+			all = append(all, t)
+		}
+	}
+	return all
+}
+
+// validate checks a module's configuration against the options of its type.
+func (t ModuleType) validate(cfg Module) error {
+	if t.unchecked {
+		return nil
+	}
+	if len(cfg.Modules) > 0 && !t.Group {
+		return errors.New("modules are not supported")
+	}
+	o := newOptions(cfg.Options, nil)
+	// The interval is common to all modules, and checked when the module is
+	// configured.
+	o.Duration("interval", 0)
+	for _, opt := range t.Options {
+		switch opt.Kind {
+		case String:
+			o.String(opt.Name, "")
+		case Int:
+			o.Int(opt.Name, 0)
+		case Bool:
+			o.Bool(opt.Name, false)
+		case Duration:
+			o.Duration(opt.Name, 0)
+		default:
+			o.get(opt.Name)
+		}
+	}
+	return o.check()
+}
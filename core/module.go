@@ -17,6 +17,8 @@
 package core
 
 import (
+	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
@@ -89,6 +91,7 @@ func (m *Module) runLoop(realSink bar.Sink) {
 		select {
 		case out = <-outputCh:
 			started = true
+			reportErrors(m.original, out)
 			timedSink.Output(out, true)
 		case <-doneCh:
 			finished = true
@@ -116,6 +119,52 @@ func (m *Module) Replay() {
 	m.replayFn()
 }
 
+var (
+	// Errors that have been reported recently, since the output of a group
+	// includes the errors from its modules, which are already reported.
+	reported   []error
+	reportedMu sync.Mutex
+)
+
+// The number of recently reported errors to remember.
+const reportedLimit = 20
+
+// reportErrors records any errors in the output of a module, so that they can
+// be shown using modules/diagnostics.
+func reportErrors(m bar.Module, o bar.Output) {
+	for _, s := range toSegments(o) {
+		err := s.GetError()
+		if err == nil || alreadyReported(err) {
+			continue
+		}
+		source := l.ID(m)
+		if source == "" {
+			source = fmt.Sprintf("%T", m)
+		}
+		l.Error(source, "%v", err)
+	}
+}
+
+// alreadyReported returns true if the error was reported recently, and
+// otherwise remembers it.
+func alreadyReported(err error) bool {
+	if !reflect.TypeOf(err).Comparable() {
+		return false
+	}
+	reportedMu.Lock()
+	defer reportedMu.Unlock()
+	for _, r := range reported {
+		if r == err {
+			return true
+		}
+	}
+	if len(reported) == reportedLimit {
+		reported = append(reported[:0], reported[1:]...)
+	}
+	reported = append(reported, err)
+	return false
+}
+
 // isRestartableClick checks whether a click event should restart the
 // wrapped module. A left/right/middle click will restart the module.
 func isRestartableClick(e bar.Event) bool {
@@ -156,7 +205,32 @@ func addRestartHandlers(o bar.Output, restartFn func()) bar.Segments {
 	return out
 }
 
-// addRefreshHandlers adds middle-click refresh to the output.
+var (
+	refreshButtons   = map[bar.Button]bool{bar.ButtonMiddle: true}
+	refreshButtonsMu sync.RWMutex
+)
+
+// SetRefreshButtons sets the buttons that refresh modules that support it
+// (bar.RefresherModule), replacing the default of a middle click. Calling it
+// with no buttons disables refreshing on click, except for error segments.
+func SetRefreshButtons(btns ...bar.Button) {
+	newButtons := map[bar.Button]bool{}
+	for _, b := range btns {
+		newButtons[b] = true
+	}
+	refreshButtonsMu.Lock()
+	defer refreshButtonsMu.Unlock()
+	refreshButtons = newButtons
+}
+
+func isRefreshClick(e bar.Event) bool {
+	refreshButtonsMu.RLock()
+	defer refreshButtonsMu.RUnlock()
+	return refreshButtons[e.Button]
+}
+
+// addRefreshHandlers adds click-to-refresh (by default, middle click) to the
+// output.
 func addRefreshHandlers(o bar.Output, refreshFn func()) bar.Segments {
 	in := toSegments(o)
 	if refreshFn == nil {
@@ -168,7 +242,7 @@ func addRefreshHandlers(o bar.Output, refreshFn func()) bar.Segments {
 		hasError := s.GetError() != nil
 		out = append(out, s.Clone().OnClick(func(e bar.Event) {
 			switch {
-			case e.Button == bar.ButtonMiddle:
+			case isRefreshClick(e):
 				refreshFn()
 			case hasError && isRestartableClick(e):
 				refreshFn()
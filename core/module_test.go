@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/sink"
 	testModule "barista.run/testing/module"
@@ -127,6 +128,34 @@ func TestRestart(t *testing.T) {
 	tm.AssertStarted("on middle click")
 }
 
+func TestReportErrors(t *testing.T) {
+	tm := testModule.New(t)
+	m := NewModule(tm)
+	ch, sink := sink.New()
+	go m.Stream(sink)
+	tm.AssertStarted()
+
+	before, _ := l.Problems()
+	err := errors.New("something went wrong")
+	tm.Output(outputs.Group(outputs.Text("ok"), outputs.Error(err)))
+	nextOutput(t, ch)
+	problems, _ := l.Problems()
+	require.Len(t, problems, len(before)+1, "error is recorded")
+	p := problems[len(problems)-1]
+	require.True(t, p.Error)
+	require.Equal(t, "something went wrong", p.Message)
+
+	// e.g. the output of a group containing the module.
+	reportErrors(tm, outputs.Error(err))
+	problems, _ = l.Problems()
+	require.Len(t, problems, len(before)+1, "same error is not recorded again")
+
+	tm.Output(outputs.Errorf("something else"))
+	nextOutput(t, ch)
+	problems, _ = l.Problems()
+	require.Len(t, problems, len(before)+2)
+}
+
 func TestTimedOutput(t *testing.T) {
 	timing.TestMode()
 	tm := testModule.New(t).SkipClickHandlers()
@@ -329,3 +358,41 @@ func TestRefresh(t *testing.T) {
 	out[0].Click(bar.Event{Button: bar.ButtonLeft})
 	notifier.AssertNoUpdate(t, refreshCh, "left-click on finished module error")
 }
+
+func TestRefreshButtons(t *testing.T) {
+	defer SetRefreshButtons(bar.ButtonMiddle)
+	refreshCh := make(chan struct{}, 1)
+	tm := refreshableModule{testModule.New(t), refreshCh}
+	m := NewModule(tm)
+	ch, sink := sink.New()
+	go m.Stream(sink)
+	tm.AssertStarted()
+
+	SetRefreshButtons(bar.ButtonRight, bar.ButtonBack)
+	tm.Output(outputs.Text("foo"))
+	out := nextOutput(t, ch, "on regular output")
+
+	out[0].Click(bar.Event{Button: bar.ButtonMiddle})
+	notifier.AssertNoUpdate(t, refreshCh, "On middle-click")
+	tm.AssertClicked("middle-click handled normally")
+
+	out[0].Click(bar.Event{Button: bar.ButtonRight})
+	notifier.AssertNotified(t, refreshCh, "On right-click")
+	tm.AssertNotClicked("on right-click")
+
+	out[0].Click(bar.Event{Button: bar.ButtonBack})
+	notifier.AssertNotified(t, refreshCh, "On back-click")
+
+	SetRefreshButtons()
+	for _, btn := range []bar.Button{bar.ButtonMiddle, bar.ButtonRight} {
+		out[0].Click(bar.Event{Button: btn})
+		notifier.AssertNoUpdate(t, refreshCh, "with refresh disabled")
+		tm.AssertClicked("with refresh disabled")
+	}
+
+	tm.Output(bar.ErrorSegment(errors.New("foo")))
+	out = nextOutput(t, ch, "on error output")
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	notifier.AssertNotified(t, refreshCh,
+		"errors still refresh with refresh buttons disabled")
+}
@@ -29,6 +29,7 @@ type ModuleSet struct {
 	updateCh  chan int
 	outputs   []bar.Segments
 	outputsMu sync.RWMutex
+	streaming bool
 }
 
 // NewModuleSet creates a ModuleSet with the given modules.
@@ -48,25 +49,111 @@ func NewModuleSet(modules []bar.Module) *ModuleSet {
 // Stream starts streaming all modules and returns a channel that receives the
 // index of the module any time one updates with new output.
 func (m *ModuleSet) Stream() <-chan int {
-	for i, mod := range m.modules {
-		go mod.Stream(m.sinkFn(i))
+	m.outputsMu.Lock()
+	m.streaming = true
+	mods := append([]*Module(nil), m.modules...)
+	m.outputsMu.Unlock()
+	for _, mod := range mods {
+		go mod.Stream(m.sinkFn(mod))
 	}
 	return m.updateCh
 }
 
-func (m *ModuleSet) sinkFn(idx int) bar.Sink {
+func (m *ModuleSet) sinkFn(mod *Module) bar.Sink {
 	return sink.Func(func(out bar.Segments) {
-		l.Fine("%s new output from %s",
-			l.ID(m), l.ID(m.modules[idx].original))
+		l.Fine("%s new output from %s", l.ID(m), l.ID(mod.original))
 		m.outputsMu.Lock()
-		m.outputs[idx] = out
+		idx := m.indexOf(mod)
+		if idx >= 0 {
+			m.outputs[idx] = out
+		}
 		m.outputsMu.Unlock()
+		if idx < 0 {
+			// Modules cannot be stopped, so a module removed by Replace is
+			// blocked forever the next time it updates its output.
+			l.Fine("%s blocking removed module %s", l.ID(m), l.ID(mod.original))
+			select {}
+		}
 		m.updateCh <- idx
 	})
 }
 
+// indexOf returns the position of the module in the set, or -1 if it has been
+// removed. It must be called with the lock held.
+func (m *ModuleSet) indexOf(mod *Module) int {
+	for i, cm := range m.modules {
+		if cm == mod {
+			return i
+		}
+	}
+	return -1
+}
+
+// Replace changes the modules in the set. Modules that are already in the set
+// (the same instance) keep running and keep their last output, and new modules
+// are started if the set is streaming. Since modules cannot be stopped,
+// modules that are no longer in the set are blocked the next time they update
+// their output, and must not be added to the set again. Removed modules that
+// implement bar.RemovableModule are notified using Remove. Once the set has
+// been replaced, the update channel receives -1.
+func (m *ModuleSet) Replace(modules []bar.Module) {
+	m.outputsMu.Lock()
+	var added []*Module
+	oldModules, oldOutputs := m.modules, m.outputs
+	m.modules = make([]*Module, len(modules))
+	m.outputs = make([]bar.Segments, len(modules))
+	for i, mod := range modules {
+		if idx := find(oldModules, mod); idx >= 0 {
+			m.modules[i] = oldModules[idx]
+			m.outputs[i] = oldOutputs[idx]
+			continue
+		}
+		if idx := find(m.modules[:i], mod); idx >= 0 {
+			m.modules[i] = m.modules[idx]
+			continue
+		}
+		l.Fine("%s added as %s[%d]", l.ID(mod), l.ID(m), i)
+		m.modules[i] = NewModule(mod)
+		added = append(added, m.modules[i])
+	}
+	var removed []*Module
+	for _, cm := range oldModules {
+		if find(m.modules, cm.original) < 0 && find(removed, cm.original) < 0 {
+			removed = append(removed, cm)
+		}
+	}
+	streaming := m.streaming
+	m.outputsMu.Unlock()
+	for _, cm := range removed {
+		if r, ok := cm.original.(bar.RemovableModule); ok {
+			l.Fine("%s removed from %s", l.ID(cm.original), l.ID(m))
+			r.Remove()
+		}
+	}
+	if !streaming {
+		return
+	}
+	for _, cm := range added {
+		go cm.Stream(m.sinkFn(cm))
+	}
+	m.updateCh <- -1
+}
+
+// find returns the position of the wrapped module for the given module, or -1
+// if it is not in the list.
+func find(mods []*Module, mod bar.Module) int {
+	for i, cm := range mods {
+		if cm.original == mod {
+			return i
+		}
+	}
+	return -1
+}
+
 // Len returns the number of modules in this ModuleSet.
 func (m *ModuleSet) Len() int {
+	m.outputsMu.RLock()
+	defer m.outputsMu.RUnlock()
 	return len(m.modules)
 }
 
@@ -15,6 +15,7 @@
 package core
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -78,3 +79,138 @@ func TestModuleSet(t *testing.T) {
 	require.Equal(t, "foo", txt)
 	require.Empty(t, out[2])
 }
+
+func TestModuleSetReplace(t *testing.T) {
+	tms := []*testModule.TestModule{
+		testModule.New(t),
+		testModule.New(t),
+		testModule.New(t),
+		testModule.New(t),
+	}
+	ms := NewModuleSet([]bar.Module{tms[0], tms[1]})
+	ms.Replace([]bar.Module{tms[1], tms[0]})
+	require.Equal(t, 2, ms.Len())
+	for _, tm := range tms {
+		tm.AssertNotStarted("before stream")
+	}
+
+	updateCh := ms.Stream()
+	tms[0].AssertStarted("on moduleset stream")
+	tms[1].AssertStarted("on moduleset stream")
+	tms[0].OutputText("a")
+	require.Equal(t, 1, nextUpdate(t, updateCh, "on output"),
+		"uses positions from replaced module list")
+	tms[1].OutputText("b")
+	require.Equal(t, 0, nextUpdate(t, updateCh, "on output"))
+
+	// Kept modules are not restarted, which would fail the test since they
+	// are still streaming.
+	go ms.Replace([]bar.Module{tms[2], tms[0], tms[3]})
+	require.Equal(t, -1, nextUpdate(t, updateCh, "on replace"))
+	tms[2].AssertStarted("when added")
+	tms[3].AssertStarted("when added")
+	require.Equal(t, 3, ms.Len())
+	out := ms.LastOutputs()
+	require.Empty(t, out[0], "new module without output")
+	txt, _ := out[1][0].Content()
+	require.Equal(t, "a", txt, "kept module keeps its output")
+	require.Empty(t, out[2])
+
+	tms[1].OutputText("c")
+	assertNoUpdate(t, updateCh, "from removed module")
+	tms[3].OutputText("d")
+	require.Equal(t, 2, nextUpdate(t, updateCh, "on output from new module"))
+
+	go ms.Replace([]bar.Module{tms[3], tms[2]})
+	require.Equal(t, -1, nextUpdate(t, updateCh, "on replace"))
+	require.Empty(t, ms.LastOutput(1))
+	txt, _ = ms.LastOutput(0)[0].Content()
+	require.Equal(t, "d", txt, "kept module keeps its output")
+}
+
+// outputModule is a module that outputs each value sent to it, and signals
+// once the output has been accepted by the sink.
+type outputModule struct {
+	outputs chan string
+	done    chan struct{}
+}
+
+func newOutputModule() *outputModule {
+	return &outputModule{make(chan string), make(chan struct{})}
+}
+
+func (o *outputModule) Stream(s bar.Sink) {
+	for txt := range o.outputs {
+		s.Output(bar.TextSegment(txt))
+		o.done <- struct{}{}
+	}
+}
+
+func (o *outputModule) output(txt string) bool {
+	o.outputs <- txt
+	select {
+	case <-o.done:
+		return true
+	case <-time.After(10 * time.Millisecond):
+		return false
+	}
+}
+
+func TestModuleSetRepeatedReplace(t *testing.T) {
+	kept := newOutputModule()
+	ms := NewModuleSet([]bar.Module{kept})
+	updateCh := ms.Stream()
+
+	var removed []*outputModule
+	for i := 0; i < 10; i++ {
+		mod := newOutputModule()
+		go ms.Replace([]bar.Module{kept, mod})
+		require.Equal(t, -1, nextUpdate(t, updateCh, "on replace"))
+		go mod.output("new")
+		require.Equal(t, 1, nextUpdate(t, updateCh, "on output from new module"))
+		removed = append(removed, mod)
+	}
+	go ms.Replace([]bar.Module{kept})
+	require.Equal(t, -1, nextUpdate(t, updateCh, "on replace"))
+
+	ms.outputsMu.RLock()
+	require.Equal(t, 1, len(ms.modules), "removed modules are not kept")
+	require.Equal(t, 1, len(ms.outputs), "removed outputs are not kept")
+	ms.outputsMu.RUnlock()
+
+	for _, mod := range removed {
+		// The first output is read from the module, but the sink blocks, so
+		// the module cannot output again.
+		go mod.output("removed")
+		assertNoUpdate(t, updateCh, "from removed module")
+		require.False(t, mod.output("again"), "removed module is blocked")
+	}
+
+	go kept.output("kept")
+	require.Equal(t, 0, nextUpdate(t, updateCh, "on output from kept module"))
+}
+
+type removableModule struct {
+	*testModule.TestModule
+	removed int32
+}
+
+func (r *removableModule) Remove() { atomic.AddInt32(&r.removed, 1) }
+
+func TestModuleSetRemove(t *testing.T) {
+	kept := &removableModule{TestModule: testModule.New(t)}
+	removed := &removableModule{TestModule: testModule.New(t)}
+	ms := NewModuleSet([]bar.Module{kept, removed, testModule.New(t)})
+	updateCh := ms.Stream()
+
+	go ms.Replace([]bar.Module{kept})
+	require.Equal(t, -1, nextUpdate(t, updateCh, "on replace"))
+	require.Equal(t, int32(0), atomic.LoadInt32(&kept.removed))
+	require.Equal(t, int32(1), atomic.LoadInt32(&removed.removed),
+		"removed module is notified")
+
+	go ms.Replace([]bar.Module{kept})
+	require.Equal(t, -1, nextUpdate(t, updateCh, "on replace"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&removed.removed),
+		"only notified once")
+}
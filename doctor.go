@@ -0,0 +1,74 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"barista.run/doctor"
+)
+
+// for tests.
+var doctorOut io.Writer = os.Stdout
+var runChecks = doctor.Run
+var osExit = os.Exit
+
+// Doctor runs the checks registered by modules for their runtime
+// prerequisites (see package doctor), e.g. installed fonts for icons, D-Bus,
+// or credentials, and prints the results along with how to fix any problems.
+// It returns true if all checks passed.
+//
+// Run calls Doctor and exits instead of starting the bar when the first
+// argument is "doctor", so running the bar with "doctor" checks all the
+// modules that were created before Run.
+func Doctor() bool {
+	results := runChecks()
+	if len(results) == 0 {
+		fmt.Fprintln(doctorOut, "Nothing to check")
+		return true
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Err == nil {
+			fmt.Fprintf(doctorOut, "+ %s\n", r.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(doctorOut, "! %s: %v\n", r.Name, r.Err)
+		if fix := doctor.FixOf(r.Err); fix != "" {
+			fmt.Fprintf(doctorOut, "  %s\n", fix)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(doctorOut, "\n%d of %d checks failed\n", failed, len(results))
+		return false
+	}
+	fmt.Fprintf(doctorOut, "\nAll %d checks passed\n", len(results))
+	return true
+}
+
+// runDoctor runs Doctor and exits if the bar was started with "doctor".
+func runDoctor() {
+	if len(os.Args) < 2 || os.Args[1] != "doctor" {
+		return
+	}
+	if Doctor() {
+		osExit(0)
+	} else {
+		osExit(1)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package doctor provides checks for the runtime prerequisites of modules, such
+as fonts, services, devices, and credentials, so that missing prerequisites
+can be found by running the bar with "doctor" (see barista.Doctor) instead of
+modules showing errors on the bar.
+
+Modules register their checks when they're created, e.g.
+
+	doctor.Register("Battery "+name, func() error {
+		_, err := os.Stat("/sys/class/power_supply/" + name)
+		return doctor.Fix(err, "Check the name in /sys/class/power_supply")
+	})
+*/
+package doctor // import "barista.run/doctor"
+
+import "sync"
+
+type check struct {
+	name string
+	fn   func() error
+}
+
+var (
+	checks   []check
+	checksMu sync.Mutex
+)
+
+// Register registers a check for a prerequisite, which returns an error if the
+// prerequisite is missing. Checks are identified by name, so a check
+// registered by multiple modules (e.g. for the same font) is only run once.
+func Register(name string, fn func() error) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	for _, c := range checks {
+		if c.name == name {
+			return
+		}
+	}
+	checks = append(checks, check{name, fn})
+}
+
+// Result is the result of a single check.
+type Result struct {
+	Name string
+	// Err is nil if the check passed.
+	Err error
+}
+
+// Run runs all registered checks, in the order they were registered.
+func Run() []Result {
+	checksMu.Lock()
+	cs := append([]check(nil), checks...)
+	checksMu.Unlock()
+	var results []Result
+	for _, c := range cs {
+		results = append(results, Result{c.name, c.fn()})
+	}
+	return results
+}
+
+// Problem is an error from a check that also describes how to fix it.
+type Problem struct {
+	Err error
+	// Fix describes what the user can do to fix the problem, e.g.
+	// "Install fonts-font-awesome".
+	Fix string
+}
+
+func (p *Problem) Error() string {
+	return p.Err.Error()
+}
+
+// Fix adds a description of how to fix the problem to a non-nil error. It
+// returns nil if err is nil, so that it can be used directly as the result of
+// a check.
+func Fix(err error, fix string) error {
+	if err == nil {
+		return nil
+	}
+	return &Problem{err, fix}
+}
+
+// FixOf returns the description of how to fix the problem, if err is a
+// Problem, or an empty string otherwise.
+func FixOf(err error) string {
+	if p, ok := err.(*Problem); ok {
+		return p.Fix
+	}
+	return ""
+}
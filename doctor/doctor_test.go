@@ -0,0 +1,63 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func resetForTest() {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	checks = nil
+}
+
+func TestChecks(t *testing.T) {
+	resetForTest()
+	require.Empty(t, Run(), "with no checks")
+
+	calls := 0
+	Register("first", func() error { calls++; return nil })
+	Register("second", func() error { return errors.New("missing") })
+	Register("first", func() error { panic("duplicate check should not run") })
+	Register("third", func() error {
+		return Fix(errors.New("not installed"), "install it")
+	})
+
+	results := Run()
+	require.Equal(t, 1, calls)
+	require.Equal(t, 3, len(results))
+	require.Equal(t, Result{"first", nil}, results[0])
+	require.Equal(t, "second", results[1].Name)
+	require.EqualError(t, results[1].Err, "missing")
+	require.Equal(t, "", FixOf(results[1].Err))
+	require.Equal(t, "third", results[2].Name)
+	require.EqualError(t, results[2].Err, "not installed")
+	require.Equal(t, "install it", FixOf(results[2].Err))
+
+	Run()
+	require.Equal(t, 2, calls, "checks run each time")
+}
+
+func TestFix(t *testing.T) {
+	require.NoError(t, Fix(nil, "unused"))
+	require.Equal(t, "", FixOf(nil))
+	err := Fix(errors.New("oops"), "do something")
+	require.EqualError(t, err, "oops")
+	require.Equal(t, "do something", FixOf(err))
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"barista.run/doctor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctor(t *testing.T) {
+	var out bytes.Buffer
+	doctorOut = &out
+	defer func() { doctorOut = os.Stdout }()
+
+	var results []doctor.Result
+	runChecks = func() []doctor.Result { return results }
+	defer func() { runChecks = doctor.Run }()
+
+	require.True(t, Doctor())
+	require.Equal(t, "Nothing to check\n", out.String())
+
+	results = []doctor.Result{{Name: "Working check"}}
+	out.Reset()
+	require.True(t, Doctor())
+	require.Equal(t, "+ Working check\n\nAll 1 checks passed\n", out.String())
+
+	results = append(results,
+		doctor.Result{Name: "Font Test", Err: doctor.Fix(errors.New("not installed"), "Install the font")},
+		doctor.Result{Name: "Service", Err: errors.New("not running")})
+	out.Reset()
+	require.False(t, Doctor())
+	require.Equal(t, `+ Working check
+! Font Test: not installed
+  Install the font
+! Service: not running
+
+2 of 3 checks failed
+`, out.String())
+
+	exitCode := -1
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = os.Exit }()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"bar"}
+	runDoctor()
+	require.Equal(t, -1, exitCode, "without doctor arg")
+
+	os.Args = []string{"bar", "doctor"}
+	runDoctor()
+	require.Equal(t, 1, exitCode, "when checks fail")
+}
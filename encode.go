@@ -0,0 +1,146 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"image/color"
+	"strconv"
+	"unicode/utf8"
+
+	"barista.run/bar"
+)
+
+// The bar is printed on every update, so segments are encoded by appending to
+// a reused buffer rather than using encoding/json, which needs a map or struct
+// per segment and reflection to encode it.
+
+// appendSegment appends the i3bar attributes of the segment to buf as JSON
+// object members, without the enclosing braces. If border is not nil, it is
+// used instead of the segment's border colour.
+func appendSegment(buf []byte, s *bar.Segment, border color.Color) []byte {
+	txt, pango := s.Content()
+	buf = append(buf, `"full_text":`...)
+	buf = appendString(buf, txt)
+	if shortText, ok := s.GetShortText(); ok {
+		buf = append(buf, `,"short_text":`...)
+		buf = appendString(buf, shortText)
+	}
+	if color, ok := s.GetColor(); ok {
+		buf = append(buf, `,"color":`...)
+		buf = appendColor(buf, color)
+	}
+	if background, ok := s.GetBackground(); ok {
+		buf = append(buf, `,"background":`...)
+		buf = appendColor(buf, background)
+	}
+	if border == nil {
+		border, _ = s.GetBorder()
+	}
+	if border != nil {
+		buf = append(buf, `,"border":`...)
+		buf = appendColor(buf, border)
+	}
+	if minWidth, ok := s.GetMinWidth(); ok {
+		buf = append(buf, `,"min_width":`...)
+		switch w := minWidth.(type) {
+		case int:
+			buf = strconv.AppendInt(buf, int64(w), 10)
+		case string:
+			buf = appendString(buf, w)
+		}
+	}
+	if align, ok := s.GetAlignment(); ok {
+		buf = append(buf, `,"align":`...)
+		buf = appendString(buf, string(align))
+	}
+	if urgent, ok := s.IsUrgent(); ok {
+		buf = append(buf, `,"urgent":`...)
+		buf = strconv.AppendBool(buf, urgent)
+	}
+	if separator, ok := s.HasSeparator(); ok {
+		buf = append(buf, `,"separator":`...)
+		buf = strconv.AppendBool(buf, separator)
+	}
+	if padding, ok := s.GetPadding(); ok {
+		buf = append(buf, `,"separator_block_width":`...)
+		buf = strconv.AppendInt(buf, int64(padding), 10)
+	}
+	if pango {
+		return append(buf, `,"markup":"pango"`...)
+	}
+	return append(buf, `,"markup":"none"`...)
+}
+
+const hex = "0123456789abcdef"
+
+// appendString appends s to buf as a JSON string. Unlike encoding/json, it
+// does not escape HTML characters, since pango markup is common in the output.
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				buf = append(buf, '\\', c)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
+
+// appendColor appends c to buf as a quoted hex colour string. i3bar does not
+// support alpha, so the alpha pre-multiplication is undone and the alpha
+// discarded.
+func appendColor(buf []byte, c color.Color) []byte {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		r, g, b = 0, 0, 0
+	} else {
+		r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+	}
+	buf = append(buf, '"', '#')
+	for _, v := range [...]uint32{r, g, b} {
+		x := uint8(float64(v)/65535.0*255.0 + 0.5)
+		buf = append(buf, hex[x>>4], hex[x&0xf])
+	}
+	return append(buf, '"')
+}
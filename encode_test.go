@@ -0,0 +1,163 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"encoding/json"
+	"errors"
+	"image/color"
+	"io/ioutil"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/core"
+	"barista.run/outputs"
+
+	"github.com/lucasb-eyer/go-colorful"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendString(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"simple",
+		`"quoted" and \back\slashed`,
+		"<span color='red'>&amp;</span>",
+		"tab\tnewline\nreturn\rbell\x07nul\x00",
+		"unicode: ☃ 🍕 ñ  ",
+	} {
+		encoded := appendString(nil, s)
+		var decoded string
+		require.NoError(t, json.Unmarshal(encoded, &decoded), "%q -> %s", s, encoded)
+		require.Equal(t, s, decoded, "round trip of %q", s)
+	}
+
+	var decoded string
+	encoded := appendString(nil, "bad \xff utf8")
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	require.Equal(t, "bad � utf8", decoded, "invalid utf-8 is replaced")
+
+	require.Equal(t, `"<b>a&b</b>"`, string(appendString(nil, "<b>a&b</b>")),
+		"html characters are not escaped")
+}
+
+func TestAppendColor(t *testing.T) {
+	for _, c := range []color.Color{
+		color.Black,
+		color.White,
+		color.Transparent,
+		color.RGBA{0xff, 0x00, 0x00, 0xff},
+		color.RGBA{0x00, 0x77, 0x00, 0x77},
+		color.RGBA{0x12, 0x34, 0x56, 0x78},
+		color.NRGBA{0x12, 0x34, 0x56, 0x78},
+		color.Gray16{0x8080},
+		colorful.Hcl(120, 0.5, 0.5),
+	} {
+		cful, _ := colorful.MakeColor(c)
+		require.Equal(t, `"`+cful.Hex()+`"`, string(appendColor(nil, c)),
+			"encoding %#v", c)
+	}
+}
+
+// staticModule outputs a fixed output once.
+type staticModule struct{ bar.Output }
+
+func (s staticModule) Stream(sink bar.Sink) {
+	sink.Output(s.Output)
+	select {}
+}
+
+// benchmarkBar returns a bar with several typical modules that have all sent
+// their output.
+func benchmarkBar(t testing.TB) *i3Bar {
+	modules := []bar.Module{
+		staticModule{outputs.Text("CPU: 12%").Color(color.RGBA{0x00, 0xff, 0x00, 0xff})},
+		staticModule{outputs.Pango("<span color='#ff0000'>☀</span> 21℃").OnClick(func(bar.Event) {})},
+		staticModule{outputs.Group(
+			outputs.Text("eth0").Urgent(false),
+			outputs.Text("wlan0: connected").MinWidthPlaceholder("wlan0: disconnected"),
+		)},
+		staticModule{outputs.Errorf("something went wrong")},
+		staticModule{outputs.Textf("%s", "Mon Jan 2 15:04").Padding(0).Separator(false)},
+	}
+	set := core.NewModuleSet(modules)
+	updates := set.Stream()
+	for range modules {
+		<-updates
+	}
+	return &i3Bar{
+		moduleSet:    set,
+		writer:       ioutil.Discard,
+		focus:        -1,
+		errorHandler: func(bar.ErrorEvent) {},
+	}
+}
+
+func TestPrintAllocations(t *testing.T) {
+	b := benchmarkBar(t)
+	require.NoError(t, b.print())
+	allocs := testing.AllocsPerRun(100, func() {
+		b.printedFocus = -2 // Force the bar to be encoded again.
+		b.print()
+	})
+	// Only copying the last outputs and wrapping the click handler of the
+	// error segment should allocate.
+	require.True(t, allocs <= 3, "%v allocations per print", allocs)
+
+	allocs = testing.AllocsPerRun(100, func() { b.print() })
+	require.True(t, allocs <= 3, "%v allocations per unchanged print", allocs)
+}
+
+func TestPrintErrors(t *testing.T) {
+	b := benchmarkBar(t)
+	b.writer = errorWriter{}
+	require.Error(t, b.print())
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func BenchmarkEncodeSegment(b *testing.B) {
+	s := outputs.Pango("<span color='#ff0000'>☀</span> 21℃").
+		Color(color.RGBA{0x00, 0xff, 0x00, 0xff}).
+		MinWidthPlaceholder("00:00").
+		Padding(5)
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = appendSegment(buf[:0], s, nil)
+	}
+}
+
+func BenchmarkPrint(b *testing.B) {
+	bar := benchmarkBar(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bar.printedFocus = -2 // Force the bar to be encoded again.
+		bar.print()
+	}
+}
+
+func BenchmarkPrintUnchanged(b *testing.B) {
+	bar := benchmarkBar(b)
+	bar.print()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bar.print()
+	}
+}
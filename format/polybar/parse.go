@@ -0,0 +1,239 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package polybar
+
+import (
+	"fmt"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"barista.run/bar"
+)
+
+// item is a part of a parsed format string.
+type item interface{}
+
+// text is literal text.
+type text string
+
+// token is a %token% replaced by a value, optionally with a minimum and
+// maximum length, e.g. %title:10:30:...%.
+type token struct {
+	name     string
+	min, max int
+	ellipsis string
+}
+
+// element is a reference to another definition, e.g. <label-charging>.
+type element string
+
+// tag changes the formatting of the following text, e.g. %{F#f00}.
+type tag struct {
+	// kind is the type of tag, e.g. 'F' for foreground, or '+' and '-' for
+	// enabling and disabling underline or overline.
+	kind byte
+	// arg is the tag's argument, e.g. the colour or font index, and the
+	// attribute for '+' and '-'.
+	arg string
+	// For action tags, the button and command. An empty command closes the
+	// most recent action for the button (or any button if 0).
+	button bar.Button
+	cmd    string
+}
+
+var (
+	tokenRe   = regexp.MustCompile(`^%([a-zA-Z0-9_-]+)(?::(-?[0-9]*)(?::([0-9]*)(?::([^%]*))?)?)?%`)
+	elementRe = regexp.MustCompile(`^<([a-zA-Z0-9_-]+)>`)
+)
+
+// parse splits a format string into text, tokens, tags, and elements (if
+// allowed).
+func parse(format string, elements bool) ([]item, error) {
+	var items []item
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			items = append(items, text(literal.String()))
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(format); {
+		rest := format[i:]
+		if strings.HasPrefix(rest, "%{") {
+			tags, n, err := parseTags(rest[2:])
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			for _, t := range tags {
+				items = append(items, t)
+			}
+			i += 2 + n
+			continue
+		}
+		if m := tokenRe.FindStringSubmatch(rest); m != nil {
+			flush()
+			t := token{name: m[1], ellipsis: m[4]}
+			t.min, _ = strconv.Atoi(m[2])
+			t.max, _ = strconv.Atoi(m[3])
+			items = append(items, t)
+			i += len(m[0])
+			continue
+		}
+		if m := elementRe.FindStringSubmatch(rest); m != nil && elements {
+			flush()
+			items = append(items, element(m[1]))
+			i += len(m[0])
+			continue
+		}
+		literal.WriteByte(format[i])
+		i++
+	}
+	flush()
+	return items, nil
+}
+
+// parseTags parses the contents of %{...}, which can contain multiple tags
+// separated by spaces, and returns the tags and the length up to and
+// including the closing '}'.
+func parseTags(s string) ([]tag, int, error) {
+	var tags []tag
+	i := 0
+	for {
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("unterminated tag '%%{%s'", s)
+		}
+		if s[i] == '}' {
+			return tags, i + 1, nil
+		}
+		if s[i] == 'A' {
+			t, n, err := parseAction(s[i+1:])
+			if err != nil {
+				return nil, 0, err
+			}
+			tags = append(tags, t)
+			i += 1 + n
+			continue
+		}
+		end := strings.IndexAny(s[i:], " }")
+		if end < 0 {
+			return nil, 0, fmt.Errorf("unterminated tag '%%{%s'", s)
+		}
+		t := tag{kind: s[i], arg: s[i+1 : i+end]}
+		if err := t.check(); err != nil {
+			return nil, 0, err
+		}
+		tags = append(tags, t)
+		i += end
+	}
+}
+
+// parseAction parses an action tag after the 'A', e.g. "3:command:" to run
+// command on right click, or "3" or "" to close an action.
+func parseAction(s string) (tag, int, error) {
+	t := tag{kind: 'A'}
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i > 0 {
+		btn, _ := strconv.Atoi(s[:i])
+		t.button = bar.Button(btn)
+	}
+	if i >= len(s) || s[i] != ':' {
+		// Closing tag.
+		return t, i, nil
+	}
+	if t.button == 0 {
+		t.button = bar.ButtonLeft
+	}
+	var cmd strings.Builder
+	for i++; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == ':':
+			cmd.WriteByte(':')
+			i++
+		case s[i] == ':':
+			t.cmd = cmd.String()
+			if t.cmd == "" {
+				return t, 0, fmt.Errorf("empty command in action")
+			}
+			return t, i + 1, nil
+		default:
+			cmd.WriteByte(s[i])
+		}
+	}
+	return t, 0, fmt.Errorf("unterminated action command '%s'", cmd.String())
+}
+
+// check validates the argument of a tag.
+func (t tag) check() error {
+	switch t.kind {
+	case 'F', 'B', 'u', 'o':
+		if t.arg == "-" {
+			return nil
+		}
+		_, err := parseColor(t.arg)
+		return err
+	case '+', '-':
+		if t.arg != "u" && t.arg != "o" {
+			return fmt.Errorf("unknown attribute in tag '%c%s'", t.kind, t.arg)
+		}
+	case 'T':
+		if t.arg == "-" {
+			return nil
+		}
+		if _, err := strconv.Atoi(t.arg); err != nil {
+			return fmt.Errorf("invalid font index in tag 'T%s'", t.arg)
+		}
+	case 'R', 'O', 'l', 'c', 'r':
+		// Reverse is supported, others are accepted but have no effect.
+	default:
+		return fmt.Errorf("unknown tag '%c%s'", t.kind, t.arg)
+	}
+	return nil
+}
+
+// parseColor parses a polybar colour, which is #rgb, #rrggbb, or either form
+// with a leading alpha component (#argb or #aarrggbb).
+func parseColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		var long strings.Builder
+		for _, c := range hex {
+			long.WriteRune(c)
+			long.WriteRune(c)
+		}
+		hex = long.String()
+	}
+	if len(hex) == 6 {
+		hex = "ff" + hex
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 || !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("invalid color '%s'", s)
+	}
+	return color.NRGBA{
+		A: uint8(v >> 24),
+		R: uint8(v >> 16),
+		G: uint8(v >> 8),
+		B: uint8(v),
+	}, nil
+}
@@ -0,0 +1,569 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package polybar formats output using polybar-style format strings, to make it
+easier to move an existing polybar configuration to barista.
+
+A format is parsed from a format string and a set of definitions, which use the
+same keys as a polybar module section. For example, the polybar battery module
+
+	[module/battery]
+	format-charging = <animation-charging> <label-charging>
+	label-charging = %percentage%%
+	label-charging-foreground = #0f0
+	animation-charging-0 = ▁
+	animation-charging-1 = ▅
+	animation-charging-framerate = 750
+
+can be written as
+
+	charging := polybar.MustParse("<animation-charging> <label-charging>", polybar.Defs{
+		"label-charging":               "%percentage%%",
+		"label-charging-foreground":    "#0f0",
+		"animation-charging-0":         "▁",
+		"animation-charging-1":         "▅",
+		"animation-charging-framerate": "750",
+	})
+	battery.All().Output(func(i battery.Info) bar.Output {
+		return charging.Output(polybar.Values{"percentage": i.RemainingPct()})
+	})
+
+Format strings can contain:
+
+  - %token% (or %token:min:max:ellipsis%), replaced by the value for token.
+  - <label-*> elements, replaced by the label's definition.
+  - <ramp-*> elements, which show one of ramp-*-0, ramp-*-1, ..., picked using
+    the value for ramp-* as a percentage.
+  - <animation-*> elements, which cycle through animation-*-0, animation-*-1,
+    ..., changing every animation-*-framerate milliseconds (default 1000).
+  - Formatting tags: %{F#rrggbb} and %{B#rrggbb} for foreground and background
+    colours, %{u#rrggbb}, %{+u} and %{-u} for underline, %{T<n>} for fonts
+    (using font-<n-1> from the definitions), and %{R} to swap the foreground
+    and background. Colours can also be given as #rgb, or with a leading alpha
+    component. %{F-}, %{B-}, %{u-}, and %{T-} reset to the default.
+  - Action tags: %{A<button>:command:}...%{A}, which run command using sh when
+    the enclosed text is clicked. Buttons are numbered as in polybar and X11,
+    e.g. 1 for left and 3 for right click. Text with different actions is placed
+    in separate segments.
+
+Elements can also be formatted using the -foreground, -background, -underline,
+-font, and -padding definitions, e.g. label-charging-foreground. Frames of ramps
+and animations can have their own formatting, e.g. ramp-volume-0-foreground.
+
+Overline, offset, and alignment tags are accepted for compatibility but ignored.
+Other elements, like <bar-*>, are not supported, and should be replaced by
+barista's own formatting.
+*/
+package polybar // import "barista.run/format/polybar"
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"barista.run/bar"
+	"barista.run/base/actions"
+	"barista.run/outputs"
+	"barista.run/pango"
+	"barista.run/timing"
+)
+
+// Defs holds definitions for elements and fonts, using the keys of a polybar
+// module section, e.g. "label-charging" or "ramp-volume-0".
+type Defs map[string]string
+
+// Values holds the values for tokens and ramps when formatting output.
+type Values map[string]interface{}
+
+// Format is a parsed polybar format string.
+type Format struct {
+	items    []item
+	elements map[string]*elem
+	fonts    map[int]string
+	// framerate is the fastest animation framerate, or 0 if the format does
+	// not contain any animations.
+	framerate time.Duration
+}
+
+type elemKind int
+
+const (
+	label elemKind = iota
+	ramp
+	animation
+)
+
+// elem is a parsed element definition.
+type elem struct {
+	kind      elemKind
+	frames    []*frame
+	framerate time.Duration
+	style     elemStyle
+}
+
+// frame is a label, or one frame of a ramp or animation.
+type frame struct {
+	items []item
+	style elemStyle
+}
+
+// elemStyle is the formatting applied to an element or a frame.
+type elemStyle struct {
+	fg, bg, ul color.Color
+	font       int
+	padding    int
+}
+
+// Parse parses a polybar format string, with definitions for the elements and
+// fonts it uses.
+func Parse(format string, defs Defs) (*Format, error) {
+	items, err := parse(format, true)
+	if err != nil {
+		return nil, err
+	}
+	f := &Format{items: items, elements: map[string]*elem{}, fonts: map[int]string{}}
+	for key, val := range defs {
+		if !strings.HasPrefix(key, "font-") {
+			continue
+		}
+		idx, err := strconv.Atoi(strings.TrimPrefix(key, "font-"))
+		if err != nil {
+			continue
+		}
+		f.fonts[idx+1] = fontName(val)
+	}
+	for _, it := range items {
+		name, ok := it.(element)
+		if !ok || f.elements[string(name)] != nil {
+			continue
+		}
+		e, err := parseElem(string(name), defs)
+		if err != nil {
+			return nil, err
+		}
+		f.elements[string(name)] = e
+		if e.kind == animation && (f.framerate == 0 || e.framerate < f.framerate) {
+			f.framerate = e.framerate
+		}
+	}
+	return f, nil
+}
+
+// MustParse parses a polybar format string, panicking on errors. It is meant to
+// be used for formats specified in the bar's configuration.
+func MustParse(format string, defs Defs) *Format {
+	f, err := Parse(format, defs)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func parseElem(name string, defs Defs) (*elem, error) {
+	e := &elem{}
+	switch {
+	case strings.HasPrefix(name, "label"):
+		e.kind = label
+	case strings.HasPrefix(name, "ramp"):
+		e.kind = ramp
+	case strings.HasPrefix(name, "animation"):
+		e.kind = animation
+		e.framerate = time.Second
+	default:
+		return nil, fmt.Errorf("unsupported element <%s>", name)
+	}
+	var err error
+	if e.style, err = parseStyle(name, defs); err != nil {
+		return nil, err
+	}
+	if e.kind == label {
+		items, err := parse(defs[name], false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		e.frames = []*frame{{items: items}}
+		return e, nil
+	}
+	if rate, ok := defs[name+"-framerate"]; ok && e.kind == animation {
+		ms, err := strconv.Atoi(rate)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("invalid framerate '%s' for <%s>", rate, name)
+		}
+		e.framerate = time.Duration(ms) * time.Millisecond
+	}
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("%s-%d", name, i)
+		def, ok := defs[key]
+		if !ok {
+			break
+		}
+		fr, err := parseFrame(key, def, defs)
+		if err != nil {
+			return nil, err
+		}
+		e.frames = append(e.frames, fr)
+	}
+	if len(e.frames) == 0 {
+		return nil, fmt.Errorf("no definition for <%s>", name)
+	}
+	return e, nil
+}
+
+func parseFrame(key, def string, defs Defs) (*frame, error) {
+	items, err := parse(def, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	style, err := parseStyle(key, defs)
+	if err != nil {
+		return nil, err
+	}
+	return &frame{items, style}, nil
+}
+
+func parseStyle(key string, defs Defs) (s elemStyle, err error) {
+	for _, c := range []struct {
+		suffix string
+		color  *color.Color
+	}{
+		{"-foreground", &s.fg},
+		{"-background", &s.bg},
+		{"-underline", &s.ul},
+	} {
+		if val, ok := defs[key+c.suffix]; ok {
+			if *c.color, err = parseColor(val); err != nil {
+				return s, fmt.Errorf("%s%s: %v", key, c.suffix, err)
+			}
+		}
+	}
+	for _, c := range []struct {
+		suffix string
+		num    *int
+	}{
+		{"-font", &s.font},
+		{"-padding", &s.padding},
+	} {
+		if val, ok := defs[key+c.suffix]; ok {
+			if *c.num, err = strconv.Atoi(val); err != nil {
+				return s, fmt.Errorf("%s%s: invalid number '%s'", key, c.suffix, val)
+			}
+		}
+	}
+	return s, nil
+}
+
+// fontName converts a polybar (fontconfig) font, e.g. "Font Awesome:size=10",
+// to a pango font description, e.g. "Font Awesome 10".
+func fontName(font string) string {
+	parts := strings.Split(font, ":")
+	name := strings.TrimSpace(parts[0])
+	for _, p := range parts[1:] {
+		if strings.HasPrefix(p, "size=") {
+			name += " " + strings.TrimPrefix(p, "size=")
+		}
+	}
+	return name
+}
+
+// Output formats the given values. If the format contains animations, the
+// output is a bar.TimedOutput that updates at the animation framerate.
+func (f *Format) Output(values Values) bar.Output {
+	if f.framerate == 0 {
+		return f.render(values, 0)
+	}
+	start := timing.Now()
+	return outputs.Repeat(func(now time.Time) bar.Output {
+		return f.render(values, now.Sub(start))
+	}).Every(f.framerate)
+}
+
+// style is the formatting state while rendering.
+type style struct {
+	fg, bg, ul color.Color
+	underline  bool
+	font       int
+}
+
+// action is a click command, active for some part of the output.
+type action struct {
+	button bar.Button
+	cmd    string
+}
+
+// run is a span of text with the same formatting and actions.
+type run struct {
+	text    string
+	style   style
+	actions []action
+}
+
+type renderer struct {
+	*Format
+	values  Values
+	elapsed time.Duration
+	style   style
+	actions []action
+	runs    []run
+}
+
+func (f *Format) render(values Values, elapsed time.Duration) bar.Output {
+	r := &renderer{Format: f, values: values, elapsed: elapsed}
+	r.items(f.items)
+	return r.segments()
+}
+
+func (r *renderer) items(items []item) {
+	for _, it := range items {
+		switch it := it.(type) {
+		case text:
+			r.text(string(it))
+		case token:
+			r.text(it.format(r.values[it.name]))
+		case tag:
+			r.tag(it)
+		case element:
+			r.element(string(it))
+		}
+	}
+}
+
+func (r *renderer) text(s string) {
+	if s == "" {
+		return
+	}
+	if n := len(r.runs) - 1; n >= 0 && r.runs[n].style == r.style &&
+		sameActions(r.runs[n].actions, r.actions) {
+		r.runs[n].text += s
+		return
+	}
+	r.runs = append(r.runs, run{s, r.style, r.actions})
+}
+
+func (r *renderer) tag(t tag) {
+	switch t.kind {
+	case 'F':
+		r.style.fg = tagColor(t.arg)
+	case 'B':
+		r.style.bg = tagColor(t.arg)
+	case 'u':
+		r.style.ul = tagColor(t.arg)
+	case '+', '-':
+		if t.arg == "u" {
+			r.style.underline = t.kind == '+'
+		}
+	case 'T':
+		r.style.font, _ = strconv.Atoi(t.arg)
+	case 'R':
+		r.style.fg, r.style.bg = r.style.bg, r.style.fg
+	case 'A':
+		r.action(t)
+	}
+}
+
+// tagColor returns the colour for a tag argument that has already been
+// validated, or nil to reset to the default.
+func tagColor(arg string) color.Color {
+	if arg == "-" {
+		return nil
+	}
+	c, _ := parseColor(arg)
+	return c
+}
+
+func (r *renderer) action(t tag) {
+	// Copy the actions, since runs share the previous slice.
+	cmds := append([]action(nil), r.actions...)
+	if t.cmd != "" {
+		r.actions = append(cmds, action{t.button, t.cmd})
+		return
+	}
+	for i := len(cmds) - 1; i >= 0; i-- {
+		if t.button == 0 || cmds[i].button == t.button {
+			r.actions = append(cmds[:i], cmds[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *renderer) element(name string) {
+	e := r.elements[name]
+	var fr *frame
+	switch e.kind {
+	case label:
+		fr = e.frames[0]
+	case ramp:
+		fr = e.frames[rampIndex(r.values[name], len(e.frames))]
+	case animation:
+		fr = e.frames[int(r.elapsed/e.framerate)%len(e.frames)]
+	}
+	saved := r.style
+	r.apply(e.style)
+	r.apply(fr.style)
+	padding := strings.Repeat(" ", e.style.padding+fr.style.padding)
+	r.text(padding)
+	r.items(fr.items)
+	r.text(padding)
+	r.style = saved
+}
+
+func (r *renderer) apply(s elemStyle) {
+	if s.fg != nil {
+		r.style.fg = s.fg
+	}
+	if s.bg != nil {
+		r.style.bg = s.bg
+	}
+	if s.ul != nil {
+		r.style.ul = s.ul
+		r.style.underline = true
+	}
+	if s.font != 0 {
+		r.style.font = s.font
+	}
+}
+
+// rampIndex returns the frame to use for a percentage value.
+func rampIndex(value interface{}, count int) int {
+	var pct float64
+	switch v := value.(type) {
+	case int:
+		pct = float64(v)
+	case int64:
+		pct = float64(v)
+	case float64:
+		pct = v
+	case float32:
+		pct = float64(v)
+	}
+	idx := int(pct*float64(count-1)/100.0 + 0.5)
+	if idx < 0 {
+		return 0
+	}
+	if idx >= count {
+		return count - 1
+	}
+	return idx
+}
+
+// format formats a token's value, applying the minimum and maximum length.
+// A positive minimum pads on the left, and a negative minimum on the right.
+func (t token) format(value interface{}) string {
+	s := fmt.Sprint(value)
+	if value == nil {
+		s = ""
+	}
+	if t.max > 0 && utf8.RuneCountInString(s) > t.max {
+		s = string([]rune(s)[:t.max]) + t.ellipsis
+	}
+	min := t.min
+	if min < 0 {
+		min = -min
+	}
+	if pad := min - utf8.RuneCountInString(s); pad > 0 {
+		if t.min > 0 {
+			s = strings.Repeat(" ", pad) + s
+		} else {
+			s += strings.Repeat(" ", pad)
+		}
+	}
+	return s
+}
+
+func (r *renderer) segments() bar.Output {
+	var out bar.Segments
+	for i := 0; i < len(r.runs); {
+		actions := r.runs[i].actions
+		root := pango.New()
+		for ; i < len(r.runs) && sameActions(r.runs[i].actions, actions); i++ {
+			root.Append(r.node(r.runs[i]))
+		}
+		seg := bar.PangoSegment(root.String())
+		if len(actions) > 0 {
+			seg.OnClick(onClick(actions))
+		}
+		out = append(out, seg)
+	}
+	return out
+}
+
+func (r *renderer) node(rn run) *pango.Node {
+	n := pango.Text(rn.text)
+	if rn.style.fg != nil {
+		n.Color(rn.style.fg)
+	}
+	if rn.style.bg != nil {
+		n.Background(rn.style.bg)
+	}
+	if rn.style.underline {
+		n.UnderlineSingle()
+		if rn.style.ul != nil {
+			n.UnderlineColor(rn.style.ul)
+		}
+	}
+	if font, ok := r.fonts[rn.style.font]; ok {
+		n.Font(font)
+	}
+	return n
+}
+
+func sameActions(a, b []action) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func onClick(cmds []action) func(bar.Event) {
+	return func(e bar.Event) {
+		// Like polybar, the innermost action for the button wins.
+		for i := len(cmds) - 1; i >= 0; i-- {
+			if cmds[i].button != e.Button {
+				continue
+			}
+			if err := runCommand(cmds[i].cmd); err != nil {
+				actions.Report(err, e)
+			}
+			return
+		}
+	}
+}
+
+// runCommand runs an action's command, in a new session so that anything it
+// launches continues running if the bar is restarted. Replaced in tests.
+var runCommand = func(command string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%s: %v: %s", command, err, msg)
+	}
+	return fmt.Errorf("%s: %v", command, err)
+}
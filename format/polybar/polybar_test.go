@@ -0,0 +1,224 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package polybar
+
+import (
+	"errors"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/actions"
+	testBar "barista.run/testing/bar"
+	"barista.run/testing/pango"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func assertMarkup(t *testing.T, out bar.Output, expected []string, args ...interface{}) {
+	segs := out.Segments()
+	require.Equal(t, len(expected), len(segs), args...)
+	for i, s := range segs {
+		text, isPango := s.Content()
+		require.True(t, isPango, "segments use pango markup")
+		pango.AssertEqual(t, expected[i], text, args...)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	f := MustParse("%percentage%% %title:-8:12:...% [%name:5%] %missing%", nil)
+	out := f.Output(Values{"percentage": 42, "title": "short", "name": "ab"})
+	assertMarkup(t, out, []string{"42% short    [   ab] "})
+
+	out = f.Output(Values{"percentage": 1.5, "title": "a much longer title", "name": "abcdefg"})
+	assertMarkup(t, out, []string{"1.5% a much longe... [abcdefg] "})
+
+	out = MustParse("100% & a<b", nil).Output(nil)
+	assertMarkup(t, out, []string{"100% &amp; a&lt;b"}, "literal text is escaped")
+}
+
+func TestTags(t *testing.T) {
+	f := MustParse("%{F#f00}red%{F-} %{B#80ff0000 F#00ff00}both%{B- F-}"+
+		" %{u#00f +u}under%{-u} %{F#fff}%{R}rev%{R}%{F-}%{O10}%{r}%{T1}font%{T-}",
+		Defs{"font-0": "Font Awesome 5 Free:style=Solid:size=10"})
+	assertMarkup(t, f.Output(nil), []string{
+		"<span color='#ff0000'>red</span> " +
+			"<span color='#00ff00' background_alpha='32896' background='#ff0000'>both</span> " +
+			"<span underline='single' underline_color='#0000ff'>under</span> " +
+			"<span background='#ffffff'>rev</span>" +
+			"<span face='Font Awesome 5 Free 10'>font</span>",
+	})
+}
+
+func TestColors(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		out color.Color
+	}{
+		{"#f0a", color.NRGBA{0xff, 0x00, 0xaa, 0xff}},
+		{"#8f0a", color.NRGBA{0xff, 0x00, 0xaa, 0x88}},
+		{"#123456", color.NRGBA{0x12, 0x34, 0x56, 0xff}},
+		{"#00123456", color.NRGBA{0x12, 0x34, 0x56, 0x00}},
+	} {
+		c, err := parseColor(tc.in)
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.out, c, tc.in)
+	}
+	for _, in := range []string{"", "f00", "#ff", "#12345", "#xyz", "#123456789"} {
+		_, err := parseColor(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestElements(t *testing.T) {
+	f := MustParse("<ramp-volume> <label-volume>", Defs{
+		"label-volume":            "%percentage%%",
+		"label-volume-foreground": "#0f0",
+		"label-volume-padding":    "1",
+		"ramp-volume-0":           "low",
+		"ramp-volume-1":           "mid",
+		"ramp-volume-2":           "high",
+		"ramp-volume-2-underline": "#f00",
+		"type":                    "internal/volume",
+	})
+	assertMarkup(t, f.Output(Values{"percentage": 10, "ramp-volume": 10}),
+		[]string{"low <span color='#00ff00'> 10% </span>"})
+	assertMarkup(t, f.Output(Values{"percentage": 50, "ramp-volume": 60}),
+		[]string{"mid <span color='#00ff00'> 50% </span>"})
+	assertMarkup(t, f.Output(Values{"percentage": 100, "ramp-volume": 120.0}),
+		[]string{"<span underline='single' underline_color='#ff0000'>high</span>" +
+			" <span color='#00ff00'> 100% </span>"})
+}
+
+func TestAnimation(t *testing.T) {
+	testBar.New(t)
+	f := MustParse("<animation-charging><animation-slow>", Defs{
+		"animation-charging-0":         "a",
+		"animation-charging-1":         "b",
+		"animation-charging-2":         "c",
+		"animation-charging-framerate": "500",
+		"animation-slow-0":             "1",
+		"animation-slow-1":             "2",
+	})
+	out, ok := f.Output(nil).(bar.TimedOutput)
+	require.True(t, ok, "animations produce timed output")
+	start := timing.Now()
+	require.Equal(t, start.Add(500*time.Millisecond), out.NextRefresh())
+	assertMarkup(t, out, []string{"a1"})
+
+	for _, expected := range []string{"b1", "c2", "a2", "b1"} {
+		timing.AdvanceTo(out.NextRefresh())
+		assertMarkup(t, out, []string{expected})
+	}
+
+	_, ok = MustParse("<label>", Defs{"label": "x"}).Output(nil).(bar.TimedOutput)
+	require.False(t, ok, "no timed output without animations")
+}
+
+func TestActions(t *testing.T) {
+	testBar.New(t)
+	var mu sync.Mutex
+	var commands []string
+	defer func(orig func(string) error) { runCommand = orig }(runCommand)
+	runCommand = func(cmd string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, cmd)
+		if cmd == "fail" {
+			return errors.New("something went wrong")
+		}
+		return nil
+	}
+	ran := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		r := commands
+		commands = nil
+		return r
+	}
+	var reported []error
+	actions.SetErrorHandler(func(e bar.ErrorEvent) { reported = append(reported, e.Error) })
+	defer actions.SetErrorHandler(nil)
+
+	f := MustParse("pre %{A1:notify-send a\\:b:}%{A3:pavucontrol:}vol"+
+		"%{A1:fail:}%{F#f00}!%{F-}%{A}%{A}%{A}post", nil)
+	segs := f.Output(nil).Segments()
+	require.Equal(t, 4, len(segs))
+	assertMarkup(t, f.Output(nil),
+		[]string{"pre ", "vol", "<span color='#ff0000'>!</span>", "post"})
+
+	segs[0].Click(bar.Event{Button: bar.ButtonLeft})
+	segs[3].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Empty(t, ran(), "no actions outside action tags")
+
+	segs[1].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, []string{"notify-send a:b"}, ran())
+	segs[1].Click(bar.Event{Button: bar.ButtonRight})
+	require.Equal(t, []string{"pavucontrol"}, ran())
+	segs[1].Click(bar.Event{Button: bar.ButtonMiddle})
+	require.Empty(t, ran())
+
+	segs[2].Click(bar.Event{Button: bar.ButtonRight})
+	require.Equal(t, []string{"pavucontrol"}, ran(), "outer actions apply")
+	segs[2].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, []string{"fail"}, ran(), "innermost action wins")
+	require.Equal(t, 1, len(reported))
+	require.Contains(t, reported[0].Error(), "something went wrong")
+}
+
+func TestRunCommand(t *testing.T) {
+	require.NoError(t, runCommand("true"))
+	err := runCommand("echo oops >&2; exit 3")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "oops")
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		defs   Defs
+		err    string
+	}{
+		{"%{F#f00", nil, "unterminated tag"},
+		{"%{F#zzz}", nil, "invalid color"},
+		{"%{+x}", nil, "unknown attribute"},
+		{"%{Tx}", nil, "invalid font index"},
+		{"%{Q}", nil, "unknown tag"},
+		{"%{A1:cmd}", nil, "unterminated action"},
+		{"%{A1::}", nil, "empty command"},
+		{"<bar-volume>", nil, "unsupported element <bar-volume>"},
+		{"<ramp-volume>", nil, "no definition for <ramp-volume>"},
+		{"<label>", Defs{"label": "%{F#f"}, "label: unterminated tag"},
+		{"<label>", Defs{"label": "x", "label-foreground": "red"},
+			"label-foreground: invalid color"},
+		{"<label>", Defs{"label": "x", "label-padding": "a"},
+			"label-padding: invalid number"},
+		{"<animation-a>", Defs{"animation-a-0": "x", "animation-a-framerate": "0"},
+			"invalid framerate"},
+	} {
+		_, err := Parse(tc.format, tc.defs)
+		require.Error(t, err, tc.format)
+		require.Contains(t, err.Error(), tc.err, tc.format)
+	}
+	require.Panics(t, func() { MustParse("%{Q}", nil) })
+}
+
+func TestLabelsCannotNest(t *testing.T) {
+	f := MustParse("<label>", Defs{"label": "<label>", "label-other": "x"})
+	assertMarkup(t, f.Output(nil), []string{"&lt;label&gt;"})
+}
@@ -18,12 +18,16 @@ and a controller to allow programmatic expansion/collapse.
 
 When collapsed (default state), only a button to expand is visible.
 When expanded, all module outputs are shown, and buttons to collapse.
+
+Using AutoCollapseAfter, the group can also collapse on its own after being
+left expanded without any clicks for some time.
 */
 package collapsing // import "barista.run/group/collapsing"
 
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"barista.run/bar"
 	"barista.run/base/click"
@@ -31,6 +35,7 @@ import (
 	"barista.run/group"
 	l "barista.run/logging"
 	"barista.run/outputs"
+	"barista.run/timing"
 )
 
 // ButtonFunc produces outputs for buttons in a collapsing group.
@@ -48,12 +53,25 @@ type Controller interface {
 	Toggle()
 	// ButtonFunc controls the output for the button(s).
 	ButtonFunc(ButtonFunc)
+	// AutoCollapseAfter collapses the group after it has been expanded for
+	// the given duration without any clicks on its modules.
+	// A zero duration disables automatic collapsing.
+	AutoCollapseAfter(time.Duration)
+	// Persist saves the expanded state of the group under the given key in
+	// $XDG_STATE_HOME/barista/groups, and restores any previously saved
+	// state, so that it is retained across bar restarts.
+	// Keys must be unique across all groups. An empty key disables persistence.
+	Persist(key string)
 }
 
 // grouper implements a collapsing grouper.
 type grouper struct {
 	expanded   atomic.Value // of bool
 	buttonFunc ButtonFunc
+	// For automatically collapsing the group.
+	autoCollapse time.Duration
+	scheduler    *timing.Scheduler
+	stateKey     string
 
 	sync.Mutex
 	notifyCh <-chan struct{}
@@ -62,9 +80,10 @@ type grouper struct {
 
 // Group returns a new collapsing group, and a linked controller.
 func Group(m ...bar.Module) (bar.Module, Controller) {
-	g := &grouper{buttonFunc: DefaultButtons}
+	g := &grouper{buttonFunc: DefaultButtons, scheduler: timing.NewScheduler()}
 	g.expanded.Store(false)
 	g.notifyFn, g.notifyCh = notifier.New()
+	go g.collapseOnTimeout()
 	return group.New(g, m...), g
 }
 
@@ -118,9 +137,59 @@ func (g *grouper) setExpanded(expanded bool) {
 	}
 	l.Fine("%s.expanded = %v", l.ID(g), expanded)
 	g.expanded.Store(expanded)
+	g.restartTimeout()
+	if g.stateKey != "" {
+		group.SaveState(g.stateKey, expanded)
+	}
+	g.notifyFn()
+}
+
+// Clicked restarts the timeout for automatically collapsing the group.
+func (g *grouper) Clicked(int) {
+	g.Lock()
+	defer g.Unlock()
+	g.restartTimeout()
+}
+
+func (g *grouper) AutoCollapseAfter(timeout time.Duration) {
+	g.Lock()
+	defer g.Unlock()
+	g.autoCollapse = timeout
+	g.restartTimeout()
+}
+
+func (g *grouper) Persist(key string) {
+	g.Lock()
+	defer g.Unlock()
+	g.stateKey = key
+	var expanded bool
+	if key == "" || !group.LoadState(key, &expanded) || expanded == g.Expanded() {
+		return
+	}
+	l.Fine("%s.expanded = %v (restored)", l.ID(g), expanded)
+	g.expanded.Store(expanded)
+	g.restartTimeout()
 	g.notifyFn()
 }
 
+// restartTimeout restarts the timeout for automatically collapsing the group,
+// or stops it if the group is collapsed or automatic collapsing is disabled.
+// Must be called with the lock held.
+func (g *grouper) restartTimeout() {
+	if g.autoCollapse > 0 && g.Expanded() {
+		g.scheduler.After(g.autoCollapse)
+	} else {
+		g.scheduler.Stop()
+	}
+}
+
+func (g *grouper) collapseOnTimeout() {
+	for range g.scheduler.C {
+		l.Fine("%s auto-collapsing", l.ID(g))
+		g.Collapse()
+	}
+}
+
 func (g *grouper) ButtonFunc(f ButtonFunc) {
 	g.Lock()
 	defer g.Unlock()
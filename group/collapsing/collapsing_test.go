@@ -15,13 +15,17 @@
 package collapsing
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"barista.run/bar"
 	"barista.run/base/click"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	testModule "barista.run/testing/module"
+	"barista.run/timing"
 
 	"github.com/stretchr/testify/require"
 )
@@ -92,3 +96,86 @@ func TestCollapsing(t *testing.T) {
 	testBar.NextOutput().AssertText([]string{"->", "a", "b", "c", "<-"},
 		"On expansion with custom button func")
 }
+
+func TestAutoCollapse(t *testing.T) {
+	testBar.New(t)
+
+	tm0 := testModule.New(t)
+	tm1 := testModule.New(t)
+	grp, ctrl := Group(tm0, tm1)
+	testBar.Run(grp)
+	tm0.AssertStarted()
+	tm0.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"+"})
+
+	ctrl.AutoCollapseAfter(time.Minute)
+	testBar.AssertNoOutput("when collapsed")
+
+	start := timing.Now()
+	ctrl.Expand()
+	testBar.NextOutput().AssertText([]string{">", "a", "<"})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"+"}, "after timeout")
+	require.Equal(t, start.Add(time.Minute), timing.Now())
+	require.False(t, ctrl.Expanded())
+
+	ctrl.Expand()
+	out := testBar.NextOutput()
+	out.AssertText([]string{">", "a", "<"})
+	timing.AdvanceBy(50 * time.Second)
+	out.At(1).LeftClick()
+	tm0.AssertClicked()
+	timing.AdvanceBy(50 * time.Second)
+	testBar.AssertNoOutput("timeout restarted on click")
+	timing.AdvanceBy(10 * time.Second)
+	testBar.NextOutput().AssertText([]string{"+"}, "after timeout")
+
+	ctrl.Expand()
+	testBar.NextOutput().AssertText([]string{">", "a", "<"})
+	ctrl.Collapse()
+	testBar.NextOutput().AssertText([]string{"+"})
+	ctrl.Expand()
+	testBar.NextOutput().AssertText([]string{">", "a", "<"})
+	ctrl.AutoCollapseAfter(0)
+	timing.AdvanceBy(time.Hour)
+	testBar.AssertNoOutput("when auto-collapse is disabled")
+	require.True(t, ctrl.Expanded())
+
+	ctrl.AutoCollapseAfter(time.Second)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"+"},
+		"timeout starts when enabled while expanded")
+}
+
+func TestPersist(t *testing.T) {
+	testBar.New(t)
+	tmpDir, err := ioutil.TempDir("", "collapsing")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	os.Setenv("XDG_STATE_HOME", tmpDir)
+	defer os.Unsetenv("XDG_STATE_HOME")
+
+	_, ctrl := Group(testModule.New(t))
+	ctrl.Persist("collapsing")
+	require.False(t, ctrl.Expanded(), "with no saved state")
+	ctrl.Expand()
+
+	grp, ctrl := Group(testModule.New(t))
+	testBar.Run(grp)
+	testBar.NextOutput().AssertText([]string{"+"})
+	ctrl.Persist("collapsing")
+	require.True(t, ctrl.Expanded(), "restores saved state")
+	testBar.NextOutput().AssertText([]string{">", "<"})
+
+	ctrl.Collapse()
+	testBar.NextOutput().AssertText([]string{"+"})
+
+	_, ctrl = Group(testModule.New(t))
+	ctrl.Persist("collapsing")
+	require.False(t, ctrl.Expanded(), "saves state on change")
+
+	_, ctrl = Group(testModule.New(t))
+	ctrl.Expand()
+	ctrl.Persist("")
+	require.True(t, ctrl.Expanded(), "empty key disables persistence")
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package conditional provides a group that only shows its modules while a
+condition holds, e.g. while a VPN interface exists, while on battery, or while
+an external monitor is connected.
+
+The condition is evaluated when the group is created, and again each time the
+trigger channel receives a value. For conditions that are not tied to an event
+source, a timing.Scheduler can be used as the trigger:
+
+	sch := timing.NewScheduler().Every(10 * time.Second)
+	conditional.New(vpnConnected, sch.C, vpnModules...)
+*/
+package conditional // import "barista.run/group/conditional"
+
+import (
+	"sync"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/group"
+	l "barista.run/logging"
+)
+
+// grouper implements a conditional grouper.
+type grouper struct {
+	predicate func() bool
+
+	sync.Mutex
+	visible  bool
+	notifyCh <-chan struct{}
+	notifyFn func()
+}
+
+// New returns a group that shows the given modules only while predicate
+// returns true, re-evaluating it whenever trigger fires.
+func New(predicate func() bool, trigger <-chan struct{}, m ...bar.Module) bar.Module {
+	g := &grouper{predicate: predicate, visible: predicate()}
+	g.notifyFn, g.notifyCh = notifier.New()
+	go g.watch(trigger)
+	return group.New(g, m...)
+}
+
+func (g *grouper) watch(trigger <-chan struct{}) {
+	for range trigger {
+		g.update()
+	}
+}
+
+func (g *grouper) update() {
+	visible := g.predicate()
+	// Group calls Visible once for each module. To ensure a consistent value
+	// across the entire set, we prevent changes to visible while the lock is
+	// held. Group only releases the lock once it's done with the grouper.
+	g.Lock()
+	defer g.Unlock()
+	if g.visible == visible {
+		return
+	}
+	l.Fine("%s.visible = %v", l.ID(g), visible)
+	g.visible = visible
+	g.notifyFn()
+}
+
+func (g *grouper) Visible(int) bool {
+	return g.visible
+}
+
+func (g *grouper) Buttons() (start, end bar.Output) {
+	return nil, nil
+}
+
+func (g *grouper) Signal() <-chan struct{} {
+	return g.notifyCh
+}
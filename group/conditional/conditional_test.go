@@ -0,0 +1,73 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conditional
+
+import (
+	"sync/atomic"
+	"testing"
+
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditional(t *testing.T) {
+	testBar.New(t)
+
+	var cond, evals int32
+	predicate := func() bool {
+		atomic.AddInt32(&evals, 1)
+		return atomic.LoadInt32(&cond) == 1
+	}
+	trigger := make(chan struct{})
+
+	tm0 := testModule.New(t)
+	tm1 := testModule.New(t)
+	grp := New(predicate, trigger, tm0, tm1)
+	require.Equal(t, int32(1), atomic.LoadInt32(&evals),
+		"predicate evaluated on creation")
+	tm0.AssertNotStarted("on group creation")
+
+	testBar.Run(grp)
+	tm0.AssertStarted("on stream")
+	tm1.AssertStarted()
+	testBar.NextOutput().AssertEmpty("while condition is false")
+
+	tm0.OutputText("a")
+	tm1.OutputText("b")
+	testBar.AssertNoOutput("on hidden module updates")
+
+	trigger <- struct{}{}
+	testBar.AssertNoOutput("when condition is unchanged")
+
+	atomic.StoreInt32(&cond, 1)
+	testBar.AssertNoOutput("until triggered")
+	trigger <- struct{}{}
+	testBar.NextOutput().AssertText([]string{"a", "b"},
+		"when condition becomes true")
+
+	tm1.OutputText("c")
+	testBar.NextOutput().AssertText([]string{"a", "c"},
+		"on visible module update")
+
+	trigger <- struct{}{}
+	testBar.AssertNoOutput("when condition is unchanged")
+
+	atomic.StoreInt32(&cond, 0)
+	trigger <- struct{}{}
+	testBar.NextOutput().AssertEmpty("when condition becomes false")
+	require.Equal(t, int32(5), atomic.LoadInt32(&evals))
+}
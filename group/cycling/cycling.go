@@ -14,6 +14,9 @@
 
 // Package cycling provides a group that continuously cycles between
 // all modules at a fixed interval.
+//
+// Clicking on any module restarts the interval, so that interactive modules
+// (including nested groups) are not cycled away while in use.
 package cycling // import "barista.run/group/cycling"
 
 import (
@@ -29,13 +32,21 @@ import (
 
 // Controller provides an interface to control a collapsing group.
 type Controller interface {
+	// SetInterval sets the interval between switching modules.
 	SetInterval(time.Duration)
+	// Persist saves the visible module under the given key in
+	// $XDG_STATE_HOME/barista/groups, and restores any previously saved
+	// module, so that it is retained across bar restarts.
+	// Keys must be unique across all groups. An empty key disables persistence.
+	Persist(key string)
 }
 
 // grouper implements a cycling grouper.
 type grouper struct {
 	current   int
 	count     int
+	interval  time.Duration
+	stateKey  string
 	scheduler *timing.Scheduler
 
 	sync.Mutex
@@ -46,7 +57,7 @@ type grouper struct {
 // Group returns a new cycling group with the given interval,
 // and a linked Controller.
 func Group(interval time.Duration, m ...bar.Module) (bar.Module, Controller) {
-	g := &grouper{count: len(m), scheduler: timing.NewScheduler()}
+	g := &grouper{count: len(m), interval: interval, scheduler: timing.NewScheduler()}
 	g.scheduler.Every(interval)
 	g.notifyFn, g.notifyCh = notifier.New()
 	go g.cycle()
@@ -62,13 +73,44 @@ func (g *grouper) Signal() <-chan struct{} { return g.notifyCh }
 func (g *grouper) cycle() {
 	for range g.scheduler.C {
 		g.Lock()
+		if g.count == 0 {
+			g.Unlock()
+			continue
+		}
 		l.Fine("%s %d++", l.ID(g), g.current)
 		g.current = (g.current + 1) % g.count
+		if g.stateKey != "" {
+			group.SaveState(g.stateKey, g.current)
+		}
 		g.Unlock()
 		g.notifyFn()
 	}
 }
 
+// Clicked restarts the interval, delaying the switch to the next module.
+func (g *grouper) Clicked(int) {
+	g.Lock()
+	defer g.Unlock()
+	g.scheduler.Every(g.interval)
+}
+
+func (g *grouper) Persist(key string) {
+	g.Lock()
+	defer g.Unlock()
+	g.stateKey = key
+	var current int
+	if key == "" || g.count == 0 || !group.LoadState(key, &current) {
+		return
+	}
+	current = (current%g.count + g.count) % g.count
+	l.Fine("%s restored #%d", l.ID(g), current)
+	g.current = current
+	g.notifyFn()
+}
+
 func (g *grouper) SetInterval(interval time.Duration) {
+	g.Lock()
+	defer g.Unlock()
+	g.interval = interval
 	g.scheduler.Every(interval)
 }
@@ -15,6 +15,8 @@
 package cycling
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -70,3 +72,43 @@ func TestCycling(t *testing.T) {
 		"switched to module with an update")
 	require.Equal(t, start.Add(61*time.Second), timing.Now())
 }
+
+func TestPersist(t *testing.T) {
+	testBar.New(t)
+	tmpDir, err := ioutil.TempDir("", "cycling")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	os.Setenv("XDG_STATE_HOME", tmpDir)
+	defer os.Unsetenv("XDG_STATE_HOME")
+
+	tm0 := testModule.New(t)
+	tm1 := testModule.New(t)
+	grp, ctrl := Group(time.Second, tm0, tm1)
+	ctrl.Persist("cycling")
+	testBar.Run(grp)
+	tm0.AssertStarted()
+	tm1.AssertStarted()
+	testBar.NextOutput().AssertEmpty()
+
+	tm0.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"a"}, "with no saved state")
+	testBar.Tick()
+	testBar.NextOutput().AssertEmpty("switched to module with no output")
+
+	testBar.New(t)
+	tm0 = testModule.New(t)
+	tm1 = testModule.New(t)
+	grp, ctrl = Group(time.Second, tm0, tm1)
+	testBar.Run(grp)
+	tm0.AssertStarted()
+	tm1.AssertStarted()
+	testBar.NextOutput().AssertEmpty()
+
+	tm0.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"a"})
+	tm1.OutputText("b")
+	testBar.AssertNoOutput("inactive module update")
+
+	ctrl.Persist("cycling")
+	testBar.NextOutput().AssertText([]string{"b"}, "restores saved state")
+}
@@ -14,29 +14,84 @@
 
 // Package following provides a group that always shows the output from
 // the most recently updated module in the set.
+//
+// With GroupWithDefault, the most recently updated module is only shown for
+// a while, after which the group reverts to a default module. This is useful
+// for transient information such as volume or brightness changes, which
+// should appear briefly and then get out of the way.
 package following // import "barista.run/group/following"
 
 import (
-	"sync/atomic"
+	"sync"
+	"time"
 
 	"barista.run/bar"
+	"barista.run/base/notifier"
 	"barista.run/group"
+	l "barista.run/logging"
+	"barista.run/timing"
 )
 
 // grouper implements a following grouper.
-type grouper struct{ current int64 }
+type grouper struct {
+	current int
+	// For reverting to the default module (always the first one).
+	dwell     time.Duration
+	scheduler *timing.Scheduler
+
+	sync.Mutex
+	notifyCh <-chan struct{}
+	notifyFn func()
+}
 
 // Group returns a new following group.
 func Group(m ...bar.Module) bar.Module {
 	return group.New(&grouper{}, m...)
 }
 
+// GroupWithDefault returns a new following group that shows def unless one
+// of the other modules has updated within the last dwell duration. Updates
+// to def itself do not interrupt the display of another module.
+func GroupWithDefault(dwell time.Duration, def bar.Module, m ...bar.Module) bar.Module {
+	g := &grouper{dwell: dwell, scheduler: timing.NewScheduler()}
+	g.notifyFn, g.notifyCh = notifier.New()
+	go g.revertOnTimeout()
+	return group.New(g, append([]bar.Module{def}, m...)...)
+}
+
 func (g *grouper) Visible(idx int) bool {
-	return atomic.LoadInt64(&g.current) == int64(idx)
+	return g.current == idx
 }
 
 func (g *grouper) Updated(idx int) {
-	atomic.StoreInt64(&g.current, int64(idx))
+	// Group calls Visible once for each module. To ensure a consistent value
+	// across the entire set, we prevent changes to current while the lock is
+	// held. Group only releases the lock once it's done with the grouper.
+	g.Lock()
+	defer g.Unlock()
+	if g.scheduler == nil {
+		g.current = idx
+		return
+	}
+	if idx == 0 {
+		return
+	}
+	g.current = idx
+	g.scheduler.After(g.dwell)
 }
 
 func (g *grouper) Buttons() (start, end bar.Output) { return nil, nil }
+
+func (g *grouper) Signal() <-chan struct{} {
+	return g.notifyCh
+}
+
+func (g *grouper) revertOnTimeout() {
+	for range g.scheduler.C {
+		g.Lock()
+		l.Fine("%s reverting to default from #%d", l.ID(g), g.current)
+		g.current = 0
+		g.Unlock()
+		g.notifyFn()
+	}
+}
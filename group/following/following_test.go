@@ -16,9 +16,13 @@ package following
 
 import (
 	"testing"
+	"time"
 
 	testBar "barista.run/testing/bar"
 	testModule "barista.run/testing/module"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestFollowing(t *testing.T) {
@@ -50,3 +54,45 @@ func TestFollowing(t *testing.T) {
 	tm2.OutputText("c")
 	testBar.NextOutput().AssertText([]string{"c"})
 }
+
+func TestFollowingWithDefault(t *testing.T) {
+	testBar.New(t)
+
+	def := testModule.New(t)
+	tm0 := testModule.New(t)
+	tm1 := testModule.New(t)
+
+	grp := GroupWithDefault(3*time.Second, def, tm0, tm1)
+	testBar.Run(grp)
+	def.AssertStarted()
+	tm0.AssertStarted()
+	tm1.AssertStarted()
+
+	testBar.NextOutput().AssertEmpty("With no module output")
+
+	def.OutputText("default")
+	testBar.NextOutput().AssertText([]string{"default"},
+		"default module shown initially")
+
+	tm0.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"a"}, "on module update")
+
+	def.OutputText("default2")
+	testBar.AssertNoOutput("default update while another module is shown")
+
+	timing.AdvanceBy(2 * time.Second)
+	tm1.OutputText("b")
+	testBar.NextOutput().AssertText([]string{"b"})
+
+	timing.AdvanceBy(2 * time.Second)
+	testBar.AssertNoOutput("dwell restarted on update")
+
+	start := timing.Now()
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"default2"},
+		"reverts to default after dwell")
+	require.Equal(t, start.Add(time.Second), timing.Now())
+
+	def.OutputText("default3")
+	testBar.NextOutput().AssertText([]string{"default3"})
+}
@@ -12,8 +12,30 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package group provides a module that groups existing modules, and uses
-// a provided Grouper to selectively display output from these modules.
+/*
+Package group provides a module that groups existing modules, and uses
+a provided Grouper to selectively display output from these modules.
+
+Groups are modules themselves, so they can be nested freely, e.g. a cycling
+group inside a collapsing group, or vice versa. When nesting groups:
+
+  - A group's buttons always enclose the output of its visible modules, so
+    the buttons of a nested group appear within the buttons of the group that
+    contains it, and only while the nested group is visible.
+
+  - Clicks are delivered to exactly one handler, the one on the clicked
+    segment. Every enclosing group that is a ClickListener is notified once,
+    outermost first, with the index of its module that contains the segment.
+    A group is not notified of clicks on its own buttons, but the buttons of a
+    nested group count as output of the module that contains it.
+
+  - Updates from modules that are not visible, including nested groups that
+    are hidden, do not cause any output from the enclosing group.
+
+  - Modules that are a VisibilityListener are notified whenever they are
+    shown or hidden, including when a group that contains them is shown or
+    hidden by its own enclosing group.
+*/
 package group // import "barista.run/group"
 
 import (
@@ -49,22 +71,48 @@ type UpdateListener interface {
 	Updated(index int)
 }
 
+// ClickListener receives an update whenever a segment from a module in the
+// group is clicked.
+type ClickListener interface {
+	// Clicked is called with the index of the module whose output was
+	// clicked, before the segment's click handler.
+	Clicked(index int)
+}
+
+// VisibilityListener can be implemented by modules in a group to be notified
+// when they are shown or hidden, e.g. to avoid doing work while hidden.
+// Modules are notified of their initial visibility before they are streamed.
+type VisibilityListener interface {
+	SetVisible(visible bool)
+}
+
 // group is a general-purpose grouped module that can show
 // a subset of the wrapped modules, with buttons on either end.
 type group struct {
 	grouper   Grouper
+	modules   []bar.Module
 	moduleSet *core.ModuleSet
+
+	visibilityMu sync.Mutex
+	hidden       bool         // Set if an enclosing group hides this group.
+	shown        map[int]bool // Last visibility sent to each listener.
 }
 
 // New constructs a new group using the given Grouper and modules.
 func New(g Grouper, m ...bar.Module) bar.Module {
-	grp := &group{g, core.NewModuleSet(m)}
+	grp := &group{grouper: g, modules: m, moduleSet: core.NewModuleSet(m),
+		shown: map[int]bool{}}
 	l.Register(grp, "grouper", "moduleSet")
 	return grp
 }
 
 // Stream starts the modules and wraps their before sending it to the bar.
 func (g *group) Stream(sink bar.Sink) {
+	if g.hasListeners() {
+		unlock := g.lockGrouper()
+		g.updateVisibility()
+		unlock()
+	}
 	moduleSetCh := g.moduleSet.Stream()
 	var signalCh <-chan struct{}
 	if sig, ok := g.grouper.(Signaller); ok {
@@ -89,19 +137,31 @@ func (g *group) Stream(sink bar.Sink) {
 	}
 }
 
+// Remove notifies all modules in the group that they have been removed from
+// the bar, since removing a group also removes its modules.
+func (g *group) Remove() {
+	for _, m := range g.modules {
+		if r, ok := m.(bar.RemovableModule); ok {
+			r.Remove()
+		}
+	}
+}
+
 // output creates the complete output from this Group.
 func (g *group) output(moduleIdx int) (o bar.Output, changed bool) {
-	if l, ok := g.grouper.(sync.Locker); ok {
-		l.Lock()
-		defer l.Unlock()
-	}
+	defer g.lockGrouper()()
+	g.updateVisibility()
 	out := outputs.Group()
 	stBtn, eBtn := g.grouper.Buttons()
 	out.Append(stBtn)
+	clickListener, _ := g.grouper.(ClickListener)
 	for idx, o := range g.moduleSet.LastOutputs() {
 		if !g.grouper.Visible(idx) {
 			continue
 		}
+		if clickListener != nil {
+			o = notifyClicks(o, idx, clickListener)
+		}
 		out.Append(o)
 		if idx == moduleIdx {
 			changed = true
@@ -111,6 +171,90 @@ func (g *group) output(moduleIdx int) (o bar.Output, changed bool) {
 	return out, changed
 }
 
+// lockGrouper locks the grouper if it supports locking, and returns a function
+// to unlock it.
+func (g *group) lockGrouper() (unlock func()) {
+	if l, ok := g.grouper.(sync.Locker); ok {
+		l.Lock()
+		return l.Unlock
+	}
+	return func() {}
+}
+
+// hasListeners returns true if any module in the group is a
+// VisibilityListener.
+func (g *group) hasListeners() bool {
+	for _, m := range g.modules {
+		if _, ok := m.(VisibilityListener); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SetVisible is called by an enclosing group, and hides all of this group's
+// modules while the group itself is hidden.
+func (g *group) SetVisible(visible bool) {
+	g.visibilityMu.Lock()
+	g.hidden = !visible
+	g.visibilityMu.Unlock()
+	defer g.lockGrouper()()
+	g.updateVisibility()
+}
+
+// updateVisibility notifies any modules that are VisibilityListeners if their
+// visibility has changed. It must be called with the grouper locked.
+func (g *group) updateVisibility() {
+	g.visibilityMu.Lock()
+	defer g.visibilityMu.Unlock()
+	for idx, m := range g.modules {
+		v, ok := m.(VisibilityListener)
+		if !ok {
+			continue
+		}
+		visible := !g.hidden && g.grouper.Visible(idx)
+		if shown, ok := g.shown[idx]; ok && shown == visible {
+			continue
+		}
+		g.shown[idx] = visible
+		v.SetVisible(visible)
+	}
+}
+
+// Expand expands the group if its grouper supports it, e.g. a collapsing group.
+// This allows groups on the bar to be expanded from the keyboard.
+func (g *group) Expand() {
+	if e, ok := g.grouper.(interface{ Expand() }); ok {
+		e.Expand()
+	}
+}
+
+// Collapse collapses the group if its grouper supports it.
+func (g *group) Collapse() {
+	if c, ok := g.grouper.(interface{ Collapse() }); ok {
+		c.Collapse()
+	}
+}
+
+// notifyClicks wraps the click handlers of all clickable segments in o to
+// also notify the ClickListener.
+func notifyClicks(o bar.Segments, idx int, c ClickListener) bar.Segments {
+	wrapped := make(bar.Segments, len(o))
+	for i, s := range o {
+		if !s.HasClick() {
+			wrapped[i] = s
+			continue
+		}
+		// because go.
+		s := s
+		wrapped[i] = s.Clone().OnClick(func(e bar.Event) {
+			c.Clicked(idx)
+			s.Click(e)
+		})
+	}
+	return wrapped
+}
+
 // nopGrouper implements a grouper that shows all modules.
 type nopGrouper bool
 
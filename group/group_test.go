@@ -308,6 +308,62 @@ func TestUpdatingGrouper(t *testing.T) {
 	}
 }
 
+type clickListeningGrouper struct {
+	*simpleGrouper
+	clickedIdx chan int
+}
+
+func (c *clickListeningGrouper) Clicked(idx int) {
+	c.clickedIdx <- idx
+}
+
+func TestClickListeningGrouper(t *testing.T) {
+	testBar.New(t)
+
+	m0 := testModule.New(t)
+	m1 := testModule.New(t).SkipClickHandlers()
+	m2 := testModule.New(t)
+	g := &clickListeningGrouper{
+		simpleGrouper: &simpleGrouper{
+			visible: []int{0, 1, 2},
+			start:   outputs.Text("start"),
+			end:     outputs.Text("end"),
+			clicked: make(chan string, 10),
+		},
+		clickedIdx: make(chan int, 10),
+	}
+
+	grp := New(g, m0, m1, m2)
+	testBar.Run(grp)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	m2.AssertStarted()
+	testBar.NextOutput().AssertText([]string{"start", "end"})
+
+	m0.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"start", "a", "end"})
+	m1.OutputText("b")
+	testBar.NextOutput().AssertText([]string{"start", "a", "b", "end"})
+	m2.Output(outputs.Group(outputs.Text("c"), outputs.Text("d")))
+	out := testBar.NextOutput()
+	out.AssertText([]string{"start", "a", "b", "c", "d", "end"})
+
+	out.At(3).LeftClick()
+	require.Equal(t, 2, <-g.clickedIdx, "click listener notified")
+	m2.AssertClicked("original click handler called")
+
+	out.At(1).LeftClick()
+	require.Equal(t, 0, <-g.clickedIdx)
+	m0.AssertClicked()
+
+	require.False(t, out.At(2).Segment().HasClick(),
+		"segments without click handlers are not wrapped")
+
+	out.At(0).LeftClick()
+	require.Equal(t, "start", <-g.clicked)
+	require.Empty(t, g.clickedIdx, "not notified for button clicks")
+}
+
 func TestSimple(t *testing.T) {
 	testBar.New(t)
 
@@ -340,3 +396,20 @@ func TestSimple(t *testing.T) {
 	out.At(1).Click(bar.Event{})
 	m2.AssertClicked("clicks pass through the group")
 }
+
+type removableModule struct {
+	*testModule.TestModule
+	removed int32
+}
+
+func (r *removableModule) Remove() { atomic.AddInt32(&r.removed, 1) }
+
+func TestRemove(t *testing.T) {
+	m0 := &removableModule{TestModule: testModule.New(t)}
+	m1 := &removableModule{TestModule: testModule.New(t)}
+	grp := Simple(m0, Simple(m1, testModule.New(t)))
+	grp.(bar.RemovableModule).Remove()
+	require.Equal(t, int32(1), atomic.LoadInt32(&m0.removed))
+	require.Equal(t, int32(1), atomic.LoadInt32(&m1.removed),
+		"nested groups are also removed")
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/group"
+	"barista.run/group/collapsing"
+	"barista.run/group/cycling"
+	"barista.run/group/switching"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// listeningGrouper shows all modules between named buttons, and records
+// clicks on its modules.
+type listeningGrouper struct {
+	name    string
+	clicked chan<- string
+}
+
+func (g listeningGrouper) Visible(int) bool { return true }
+
+func (g listeningGrouper) Buttons() (start, end bar.Output) {
+	return outputs.Text(g.name + "<").OnClick(func(bar.Event) {
+			g.clicked <- g.name + " start"
+		}), outputs.Text(">" + g.name).OnClick(func(bar.Event) {
+			g.clicked <- g.name + " end"
+		})
+}
+
+func (g listeningGrouper) Clicked(idx int) {
+	g.clicked <- fmt.Sprintf("%s %d", g.name, idx)
+}
+
+func TestNestedClickRouting(t *testing.T) {
+	testBar.New(t)
+	clicked := make(chan string, 10)
+
+	m0 := testModule.New(t)
+	m1 := testModule.New(t)
+	inner := group.New(listeningGrouper{"in", clicked}, m0, m1)
+	m2 := testModule.New(t)
+	outer := group.New(listeningGrouper{"out", clicked}, m2, inner)
+
+	testBar.Run(outer)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	m2.AssertStarted()
+	// Nested groups may produce their initial output before or after the
+	// enclosing group.
+	testBar.Drain(100 * time.Millisecond).
+		AssertText([]string{"out<", "in<", ">in", ">out"})
+
+	m1.OutputText("b")
+	out := testBar.NextOutput()
+	out.AssertText([]string{"out<", "in<", "b", ">in", ">out"},
+		"nested buttons are within outer buttons")
+
+	out.At(2).LeftClick()
+	require.Equal(t, "out 1", <-clicked, "outermost listener notified first")
+	require.Equal(t, "in 1", <-clicked)
+	m1.AssertClicked("original handler called")
+	require.Empty(t, clicked, "each listener notified once")
+
+	out.At(1).LeftClick()
+	require.Equal(t, "out 1", <-clicked,
+		"nested group buttons are output of the containing module")
+	require.Equal(t, "in start", <-clicked)
+	require.Empty(t, clicked, "not notified for own buttons")
+
+	out.At(4).LeftClick()
+	require.Equal(t, "out end", <-clicked)
+	require.Empty(t, clicked)
+
+	m2.OutputText("c")
+	out = testBar.NextOutput()
+	out.AssertText([]string{"out<", "c", "in<", "b", ">in", ">out"})
+	out.At(1).LeftClick()
+	require.Equal(t, "out 0", <-clicked)
+	m2.AssertClicked()
+	require.Empty(t, clicked)
+}
+
+func TestSwitchingInCollapsing(t *testing.T) {
+	testBar.New(t)
+
+	m0 := testModule.New(t)
+	m1 := testModule.New(t)
+	sw, swCtrl := switching.Group(m0, m1)
+	col, colCtrl := collapsing.Group(sw)
+
+	testBar.Run(col)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	out := testBar.Drain(100 * time.Millisecond)
+	out.AssertText([]string{"+"})
+
+	m0.OutputText("a")
+	testBar.AssertNoOutput("update from hidden nested group")
+	m1.OutputText("b")
+	testBar.AssertNoOutput("update from hidden nested group")
+
+	colCtrl.Expand()
+	out = testBar.NextOutput()
+	out.AssertText([]string{">", "a", ">", "<"},
+		"nested buttons are within outer buttons")
+
+	out.At(2).LeftClick()
+	out = testBar.NextOutput()
+	out.AssertText([]string{">", "<", "b", "<"},
+		"nested group buttons control the nested group")
+	require.Equal(t, 1, swCtrl.Current())
+	require.True(t, colCtrl.Expanded(), "outer group is unaffected")
+
+	out.At(0).LeftClick()
+	testBar.NextOutput().AssertText([]string{"+"},
+		"outer group buttons control the outer group")
+	require.Equal(t, 1, swCtrl.Current(), "nested group is unaffected")
+
+	swCtrl.Previous()
+	testBar.AssertNoOutput("update from hidden nested group")
+	colCtrl.Expand()
+	testBar.NextOutput().AssertText([]string{">", "a", ">", "<"})
+}
+
+func TestCollapsingInCycling(t *testing.T) {
+	testBar.New(t)
+
+	m0 := testModule.New(t)
+	m1 := testModule.New(t)
+	col, colCtrl := collapsing.Group(m0)
+	cyc, _ := cycling.Group(time.Minute, col, m1)
+
+	testBar.Run(cyc)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	out := testBar.Drain(100 * time.Millisecond)
+	out.AssertText([]string{"+"})
+
+	m0.OutputText("a")
+	testBar.AssertNoOutput("update from hidden module in nested group")
+
+	start := timing.Now()
+	timing.AdvanceBy(40 * time.Second)
+	out.At(0).LeftClick()
+	testBar.NextOutput().AssertText([]string{">", "a", "<"},
+		"nested group expanded")
+	require.True(t, colCtrl.Expanded())
+
+	testBar.Tick()
+	testBar.NextOutput().AssertEmpty("switched to module with no output")
+	require.Equal(t, start.Add(100*time.Second), timing.Now(),
+		"clicks within a nested group restart the interval")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{">", "a", "<"},
+		"nested group keeps its state")
+}
+
+// visibilityModule records visibility changes from its group.
+type visibilityModule struct {
+	*testModule.TestModule
+	visible chan bool
+}
+
+func (v visibilityModule) SetVisible(visible bool) { v.visible <- visible }
+
+func assertVisibility(t *testing.T, m visibilityModule, expected bool, msg string) {
+	select {
+	case v := <-m.visible:
+		require.Equal(t, expected, v, msg)
+	case <-time.After(time.Second):
+		require.Fail(t, "visibility not updated", msg)
+	}
+}
+
+func TestNestedVisibility(t *testing.T) {
+	testBar.New(t)
+
+	m0 := visibilityModule{testModule.New(t), make(chan bool, 10)}
+	m1 := visibilityModule{testModule.New(t), make(chan bool, 10)}
+	sw, swCtrl := switching.Group(m0, m1)
+	col, colCtrl := collapsing.Group(sw)
+
+	testBar.Run(col)
+	assertVisibility(t, m0, false, "hidden by collapsed outer group")
+	assertVisibility(t, m1, false, "hidden by nested group")
+	m0.AssertStarted("after initial visibility")
+	testBar.Drain(100 * time.Millisecond)
+
+	colCtrl.Expand()
+	assertVisibility(t, m0, true, "on expanding outer group")
+	testBar.NextOutput()
+
+	swCtrl.Next()
+	assertVisibility(t, m0, false, "on switching nested group")
+	assertVisibility(t, m1, true, "on switching nested group")
+	testBar.NextOutput()
+
+	colCtrl.Collapse()
+	assertVisibility(t, m1, false, "on collapsing outer group")
+	testBar.NextOutput()
+
+	swCtrl.Previous()
+	select {
+	case <-m0.visible:
+		require.Fail(t, "visibility changed in hidden nested group")
+	case <-m1.visible:
+		require.Fail(t, "visibility changed in hidden nested group")
+	case <-time.After(10 * time.Millisecond): // test passed.
+	}
+
+	colCtrl.Expand()
+	assertVisibility(t, m0, true, "on expanding outer group")
+}
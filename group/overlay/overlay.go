@@ -0,0 +1,136 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package overlay provides a group that can be temporarily taken over by a
+detailed output, hiding all of its modules until the overlay is dismissed or
+times out, after which the normal output is restored.
+
+Wrapping all modules on the bar in an overlay group allows a module to take
+over the entire bar, e.g. clicking on a battery module could show details for
+each battery, the power draw, and charge thresholds for a few seconds.
+Wrapping a subset of the modules limits the overlay to that region.
+
+Clicking on any segment of the overlay that does not have its own click
+handler dismisses it.
+*/
+package overlay // import "barista.run/group/overlay"
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/group"
+	l "barista.run/logging"
+	"barista.run/timing"
+)
+
+// Controller provides an interface to control an overlay group.
+type Controller interface {
+	// Show replaces the output of the group with the given output until it
+	// is dismissed, or for the given duration. A zero duration shows the
+	// overlay until it is dismissed. Calling Show while an overlay is active
+	// replaces it, and restarts the timeout.
+	Show(out bar.Output, timeout time.Duration)
+	// Dismiss removes the overlay, restoring the output of all modules.
+	Dismiss()
+	// Active returns true if an overlay is currently being shown.
+	Active() bool
+}
+
+// grouper implements an overlay grouper.
+type grouper struct {
+	overlay   bar.Segments
+	scheduler *timing.Scheduler
+
+	sync.Mutex
+	notifyCh <-chan struct{}
+	notifyFn func()
+}
+
+// Group returns a new overlay group, and a linked controller.
+func Group(m ...bar.Module) (bar.Module, Controller) {
+	g := &grouper{scheduler: timing.NewScheduler()}
+	g.notifyFn, g.notifyCh = notifier.New()
+	go g.dismissOnTimeout()
+	return group.New(g, m...), g
+}
+
+func (g *grouper) Visible(int) bool {
+	return g.overlay == nil
+}
+
+func (g *grouper) Buttons() (start, end bar.Output) {
+	return g.overlay, nil
+}
+
+func (g *grouper) Signal() <-chan struct{} {
+	return g.notifyCh
+}
+
+func (g *grouper) Show(out bar.Output, timeout time.Duration) {
+	var overlay bar.Segments
+	if out != nil {
+		for _, s := range out.Segments() {
+			if !s.HasClick() {
+				s = s.Clone().OnClick(func(bar.Event) { g.Dismiss() })
+			}
+			overlay = append(overlay, s)
+		}
+	}
+	if overlay == nil {
+		// A nil overlay means no overlay is active, but an empty output
+		// should still hide all modules.
+		overlay = bar.Segments{}
+	}
+	// Group calls Visible once for each module. To ensure a consistent value
+	// across the entire set, we prevent changes to the overlay while the lock
+	// is held. Group only releases the lock once it's done with the grouper.
+	g.Lock()
+	defer g.Unlock()
+	l.Fine("%s showing overlay for %v", l.ID(g), timeout)
+	g.overlay = overlay
+	if timeout > 0 {
+		g.scheduler.After(timeout)
+	} else {
+		g.scheduler.Stop()
+	}
+	g.notifyFn()
+}
+
+func (g *grouper) Dismiss() {
+	g.Lock()
+	defer g.Unlock()
+	if g.overlay == nil {
+		return
+	}
+	l.Fine("%s dismissing overlay", l.ID(g))
+	g.overlay = nil
+	g.scheduler.Stop()
+	g.notifyFn()
+}
+
+func (g *grouper) Active() bool {
+	g.Lock()
+	defer g.Unlock()
+	return g.overlay != nil
+}
+
+func (g *grouper) dismissOnTimeout() {
+	for range g.scheduler.C {
+		g.Dismiss()
+	}
+}
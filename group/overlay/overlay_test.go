@@ -0,0 +1,91 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package overlay
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/base/click"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlay(t *testing.T) {
+	testBar.New(t)
+
+	tm0 := testModule.New(t)
+	tm1 := testModule.New(t)
+	grp, ctrl := Group(tm0, tm1)
+
+	testBar.Run(grp)
+	tm0.AssertStarted()
+	tm1.AssertStarted()
+	testBar.NextOutput().AssertEmpty()
+	require.False(t, ctrl.Active())
+
+	tm0.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"a"})
+	tm1.OutputText("b")
+	testBar.NextOutput().AssertText([]string{"a", "b"})
+
+	start := timing.Now()
+	ctrl.Show(outputs.Group(outputs.Text("x"), outputs.Text("y")), 5*time.Second)
+	testBar.NextOutput().AssertText([]string{"x", "y"}, "on overlay")
+	require.True(t, ctrl.Active())
+
+	tm0.OutputText("c")
+	testBar.AssertNoOutput("module update while overlay is shown")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"c", "b"}, "after overlay timeout")
+	require.Equal(t, start.Add(5*time.Second), timing.Now())
+	require.False(t, ctrl.Active())
+
+	ctrl.Show(outputs.Text("z"), 0)
+	out := testBar.NextOutput()
+	out.AssertText([]string{"z"})
+	out.At(0).LeftClick()
+	testBar.NextOutput().AssertText([]string{"c", "b"}, "clicking dismisses overlay")
+
+	clicked := make(chan struct{}, 1)
+	ctrl.Show(outputs.Group(
+		outputs.Text("btn").OnClick(click.Left(func() { clicked <- struct{}{} })),
+		outputs.Text("info"),
+	), time.Minute)
+	out = testBar.NextOutput()
+	out.AssertText([]string{"btn", "info"})
+	out.At(0).LeftClick()
+	<-clicked
+	testBar.AssertNoOutput("overlay click handlers are kept")
+	require.True(t, ctrl.Active())
+
+	ctrl.Show(outputs.Text("new"), time.Minute)
+	testBar.NextOutput().AssertText([]string{"new"}, "replaces existing overlay")
+
+	ctrl.Dismiss()
+	testBar.NextOutput().AssertText([]string{"c", "b"}, "on dismiss")
+	ctrl.Dismiss()
+	testBar.AssertNoOutput("dismiss without overlay")
+
+	ctrl.Show(nil, time.Second)
+	testBar.NextOutput().AssertEmpty("empty overlay hides modules")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"c", "b"})
+}
@@ -0,0 +1,171 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package paged provides a group that splits modules into pages of a fixed
+size, shows one page at a time along with a page indicator (e.g. "2/4"),
+and a controller to switch between pages.
+
+By default, clicking or scrolling on the indicator switches pages: left click
+or scroll down for the next page, and right click or scroll up for the
+previous page.
+*/
+package paged // import "barista.run/group/paged"
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/group"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// ButtonFunc produces outputs for buttons in a paged group.
+type ButtonFunc func(Controller) (start, end bar.Output)
+
+// Controller provides an interface to control a paged group.
+type Controller interface {
+	// Current returns the index of the currently visible page.
+	Current() int
+	// Previous switches to the previous page.
+	Previous()
+	// Next switches to the next page.
+	Next()
+	// Show switches to the given page.
+	Show(int)
+	// Count returns the number of pages in this group.
+	Count() int
+	// ButtonFunc controls the output for the buttons on either end.
+	ButtonFunc(ButtonFunc)
+	// Persist saves the visible page under the given key in
+	// $XDG_STATE_HOME/barista/groups, and restores any previously saved
+	// page, so that it is retained across bar restarts.
+	// Keys must be unique across all groups. An empty key disables persistence.
+	Persist(key string)
+}
+
+// grouper implements a paged grouper.
+type grouper struct {
+	current    atomic.Value // of int
+	pageSize   int
+	count      int
+	buttonFunc ButtonFunc
+	stateKey   string
+
+	sync.Mutex
+	notifyCh <-chan struct{}
+	notifyFn func()
+}
+
+// Group returns a new paged group that shows pageSize modules at a time,
+// and a linked controller.
+func Group(pageSize int, m ...bar.Module) (bar.Module, Controller) {
+	if pageSize < 1 {
+		panic(fmt.Sprintf("Invalid page size %d", pageSize))
+	}
+	count := (len(m) + pageSize - 1) / pageSize
+	if count < 1 {
+		count = 1
+	}
+	g := &grouper{pageSize: pageSize, count: count, buttonFunc: DefaultButtons}
+	g.current.Store(0)
+	g.notifyFn, g.notifyCh = notifier.New()
+	return group.New(g, m...), g
+}
+
+// DefaultButtons provides the default buttons for a paged group: a page
+// indicator at the end, which switches pages on click or scroll. There is no
+// indicator if all modules fit on a single page.
+func DefaultButtons(c Controller) (start, end bar.Output) {
+	if c.Count() < 2 {
+		return nil, nil
+	}
+	return nil, outputs.Textf("%d/%d", c.Current()+1, c.Count()).
+		OnClick(click.Map{}.
+			Left(c.Next).
+			ScrollDown(c.Next).
+			Right(c.Previous).
+			ScrollUp(c.Previous).
+			Handle)
+}
+
+func (g *grouper) Visible(idx int) bool {
+	return idx/g.pageSize == g.Current()
+}
+
+func (g *grouper) Buttons() (start, end bar.Output) {
+	return g.buttonFunc(g)
+}
+
+func (g *grouper) Signal() <-chan struct{} {
+	return g.notifyCh
+}
+
+func (g *grouper) Current() int {
+	return g.current.Load().(int)
+}
+
+func (g *grouper) Previous() {
+	g.setPage(g.Current() - 1)
+}
+
+func (g *grouper) Next() {
+	g.setPage(g.Current() + 1)
+}
+
+func (g *grouper) Show(page int) {
+	g.setPage(page)
+}
+
+func (g *grouper) Count() int {
+	return g.count
+}
+
+func (g *grouper) setPage(page int) {
+	// Group calls Visible once for each module. To ensure a consistent value
+	// across the entire set, we prevent changes to current while the lock is
+	// held. Group only releases the lock once it's done with the grouper.
+	g.Lock()
+	defer g.Unlock()
+	// Handle wrap around on either side.
+	current := (page%g.count + g.count) % g.count
+	l.Fine("%s switched to page %d", l.ID(g), current)
+	g.current.Store(current)
+	if g.stateKey != "" {
+		group.SaveState(g.stateKey, current)
+	}
+	g.notifyFn()
+}
+
+func (g *grouper) Persist(key string) {
+	g.Lock()
+	g.stateKey = key
+	g.Unlock()
+	var current int
+	if key != "" && group.LoadState(key, &current) {
+		g.setPage(current)
+	}
+}
+
+func (g *grouper) ButtonFunc(f ButtonFunc) {
+	g.Lock()
+	defer g.Unlock()
+	g.buttonFunc = f
+	g.notifyFn()
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package paged
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaged(t *testing.T) {
+	testBar.New(t)
+
+	var mods []bar.Module
+	var tms []*testModule.TestModule
+	for i := 0; i < 5; i++ {
+		tm := testModule.New(t)
+		tms = append(tms, tm)
+		mods = append(mods, tm)
+	}
+
+	grp, ctrl := Group(2, mods...)
+	tms[0].AssertNotStarted("on group creation")
+
+	testBar.Run(grp)
+	for _, tm := range tms {
+		tm.AssertStarted("on stream")
+	}
+
+	require.Equal(t, 3, ctrl.Count())
+	require.Equal(t, 0, ctrl.Current())
+	testBar.NextOutput().AssertText([]string{"1/3"},
+		"with no output from modules")
+
+	tms[0].OutputText("a")
+	testBar.NextOutput().AssertText([]string{"a", "1/3"})
+	tms[1].OutputText("b")
+	out := testBar.NextOutput()
+	out.AssertText([]string{"a", "b", "1/3"})
+	tms[2].OutputText("c")
+	tms[3].OutputText("d")
+	tms[4].OutputText("e")
+	testBar.AssertNoOutput("on hidden module updates")
+
+	out.At(2).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"c", "d", "2/3"})
+	require.Equal(t, 1, ctrl.Current())
+
+	tms[0].OutputText("x")
+	testBar.AssertNoOutput("on hidden module update")
+
+	out.At(2).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"e", "3/3"}, "partial last page")
+
+	out.At(1).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"x", "b", "1/3"}, "wraparound on next")
+
+	out.At(2).Click(bar.Event{Button: bar.ButtonRight})
+	testBar.NextOutput().AssertText([]string{"e", "3/3"}, "wraparound on previous")
+
+	ctrl.Previous()
+	out = testBar.NextOutput()
+	out.AssertText([]string{"c", "d", "2/3"})
+	out.At(2).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput().AssertText([]string{"x", "b", "1/3"})
+
+	ctrl.ButtonFunc(func(c Controller) (start, end bar.Output) {
+		return outputs.Text("<").OnClick(click.Left(c.Previous)),
+			outputs.Textf("[%d]", c.Current())
+	})
+	testBar.NextOutput().AssertText([]string{"<", "x", "b", "[0]"})
+
+	ctrl.Show(4)
+	testBar.NextOutput().AssertText([]string{"<", "c", "d", "[1]"})
+}
+
+func TestSinglePage(t *testing.T) {
+	testBar.New(t)
+	tm := testModule.New(t)
+	grp, ctrl := Group(3, tm)
+	testBar.Run(grp)
+	tm.AssertStarted()
+	require.Equal(t, 1, ctrl.Count())
+	testBar.NextOutput().AssertEmpty()
+	tm.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"a"}, "no indicator with one page")
+
+	require.Panics(t, func() { Group(0, tm) })
+
+	grp, ctrl = Group(3)
+	require.Equal(t, 1, ctrl.Count(), "empty group")
+	testBar.New(t)
+	testBar.Run(grp)
+	testBar.NextOutput().AssertEmpty()
+}
+
+func TestPersist(t *testing.T) {
+	testBar.New(t)
+	tmpDir, err := ioutil.TempDir("", "paged")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	os.Setenv("XDG_STATE_HOME", tmpDir)
+	defer os.Unsetenv("XDG_STATE_HOME")
+
+	var mods []bar.Module
+	for i := 0; i < 6; i++ {
+		mods = append(mods, testModule.New(t))
+	}
+	_, ctrl := Group(2, mods...)
+	ctrl.Persist("paged")
+	require.Equal(t, 0, ctrl.Current(), "with no saved state")
+	ctrl.Next()
+	ctrl.Next()
+
+	_, ctrl = Group(2, mods...)
+	ctrl.Persist("paged")
+	require.Equal(t, 2, ctrl.Current(), "restores saved state")
+
+	_, ctrl = Group(3, mods...)
+	ctrl.Persist("paged")
+	require.Equal(t, 0, ctrl.Current(),
+		"saved state wraps around if there are fewer pages")
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group
+
+import (
+	"barista.run/bar"
+	"barista.run/base/click"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// refreshGrouper shows all modules, with a refresh button at the end.
+type refreshGrouper struct {
+	module bar.Module
+	button bar.Segments
+}
+
+// WithRefreshButton adds a button to the end of the output from m, which
+// calls Refresh() on every RefresherModule in m when clicked. If m is a group,
+// this includes all modules in the group and in any nested groups. If button
+// is nil, a default '↻' button is used.
+func WithRefreshButton(m bar.Module, button bar.Output) bar.Module {
+	if button == nil {
+		button = outputs.Text("↻")
+	}
+	g := &refreshGrouper{module: m}
+	for _, s := range button.Segments() {
+		g.button = append(g.button, s.Clone().OnClick(click.Left(g.refresh)))
+	}
+	return New(g, m)
+}
+
+func (g *refreshGrouper) Visible(int) bool { return true }
+
+func (g *refreshGrouper) Buttons() (start, end bar.Output) {
+	return nil, g.button
+}
+
+func (g *refreshGrouper) refresh() {
+	l.Fine("%s refreshing", l.ID(g))
+	refreshAll(g.module)
+}
+
+// refreshAll calls Refresh on the given module if supported, or on all of its
+// modules if it's a group.
+func refreshAll(m bar.Module) {
+	switch m := m.(type) {
+	case *group:
+		for _, mod := range m.modules {
+			refreshAll(mod)
+		}
+	case bar.RefresherModule:
+		m.Refresh()
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group
+
+import (
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+type refresherModule struct {
+	*testModule.TestModule
+	name      string
+	refreshed chan<- string
+}
+
+func (r refresherModule) Refresh() { r.refreshed <- r.name }
+
+func TestRefreshButton(t *testing.T) {
+	testBar.New(t)
+	refreshed := make(chan string, 10)
+
+	m0 := refresherModule{testModule.New(t), "m0", refreshed}
+	m1 := testModule.New(t)
+	m2 := refresherModule{testModule.New(t), "m2", refreshed}
+	m3 := refresherModule{testModule.New(t), "m3", refreshed}
+
+	grp := WithRefreshButton(Simple(m0, m1, Simple(m2, WithRefreshButton(m3, nil))), nil)
+	testBar.Run(grp)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	m2.AssertStarted()
+	m3.AssertStarted()
+
+	m0.OutputText("a")
+	m1.OutputText("b")
+	m2.OutputText("c")
+	m3.OutputText("d")
+	out := testBar.LatestOutput()
+	for out.Len() < 6 {
+		out = testBar.NextOutput()
+	}
+	out.AssertText([]string{"a", "b", "c", "d", "↻", "↻"})
+
+	out.At(5).Click(bar.Event{Button: bar.ButtonRight})
+	require.Empty(t, refreshed, "only left click refreshes")
+
+	out.At(5).LeftClick()
+	var names []string
+	for i := 0; i < 3; i++ {
+		names = append(names, <-refreshed)
+	}
+	require.Equal(t, []string{"m0", "m2", "m3"}, names,
+		"refreshes all refreshable modules, including nested groups")
+	require.Empty(t, refreshed)
+
+	out.At(4).LeftClick()
+	require.Equal(t, "m3", <-refreshed, "nested refresh button")
+	require.Empty(t, refreshed)
+
+	out.At(1).LeftClick()
+	m1.AssertClicked("module click handlers are unaffected")
+	require.Empty(t, refreshed)
+}
+
+func TestCustomRefreshButton(t *testing.T) {
+	testBar.New(t)
+	refreshed := make(chan string, 10)
+	m := refresherModule{testModule.New(t), "m", refreshed}
+
+	testBar.Run(WithRefreshButton(m,
+		outputs.Group(outputs.Text("re"), outputs.Text("fresh"))))
+	m.AssertStarted()
+	testBar.NextOutput().AssertText([]string{"re", "fresh"})
+
+	m.OutputText("a")
+	out := testBar.NextOutput()
+	out.AssertText([]string{"a", "re", "fresh"})
+
+	out.At(2).LeftClick()
+	require.Equal(t, "m", <-refreshed)
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	require.Equal(t, "m", <-refreshed, "module's own middle click refresh")
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group
+
+import (
+	l "barista.run/logging"
+	"barista.run/state"
+)
+
+// store keeps the state of groups, under $XDG_STATE_HOME/barista/groups.
+var store = state.New("groups")
+
+// LoadState loads the previously saved state for the given key into v,
+// and returns true if any state was loaded. Groupers can use this to restore
+// their state (e.g. the visible module) across bar restarts.
+func LoadState(key string, v interface{}) bool {
+	return store.Load(key, v)
+}
+
+// SaveState saves v as the state for the given key, to be restored using
+// LoadState the next time the bar is started.
+func SaveState(key string, v interface{}) {
+	if err := store.Save(key, v); err != nil {
+		l.Log("Failed to save group state for %s: %v", key, err)
+	}
+}
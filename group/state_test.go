@@ -0,0 +1,58 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestState(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "group-state")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	oldState, hadState := os.LookupEnv("XDG_STATE_HOME")
+	os.Setenv("XDG_STATE_HOME", tmpDir)
+	defer func() {
+		if hadState {
+			os.Setenv("XDG_STATE_HOME", oldState)
+		} else {
+			os.Unsetenv("XDG_STATE_HOME")
+		}
+	}()
+
+	var current int
+	require.False(t, LoadState("test", &current), "with no saved state")
+
+	SaveState("test", 3)
+	require.True(t, LoadState("test", &current))
+	require.Equal(t, 3, current)
+	require.FileExists(t, filepath.Join(tmpDir, "barista", "groups", "test.json"))
+
+	var expanded bool
+	require.False(t, LoadState("other", &expanded), "keys are separate")
+	SaveState("other", true)
+	require.True(t, LoadState("other", &expanded))
+	require.True(t, expanded)
+
+	require.NoError(t, ioutil.WriteFile(
+		filepath.Join(tmpDir, "barista", "groups", "test.json"),
+		[]byte("not-json"), 0600))
+	require.False(t, LoadState("test", &current), "with invalid state")
+}
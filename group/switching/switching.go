@@ -41,10 +41,19 @@ type Controller interface {
 	Next()
 	// Show sets the currently active module.
 	Show(int)
+	// ShowFrom sets the currently active module to each index received on
+	// the channel, until it is closed. This allows the group to be driven
+	// by other modules, scripts, or anything else that can send to a channel.
+	ShowFrom(<-chan int)
 	// Count returns the number of modules in this group
 	Count() int
 	// ButtonFunc controls the output for the buttons on either end.
 	ButtonFunc(ButtonFunc)
+	// Persist saves the active module under the given key in
+	// $XDG_STATE_HOME/barista/groups, and restores any previously saved
+	// module, so that it is retained across bar restarts.
+	// Keys must be unique across all groups. An empty key disables persistence.
+	Persist(key string)
 }
 
 // grouper implements a switching grouper.
@@ -52,6 +61,7 @@ type grouper struct {
 	current    atomic.Value // of int
 	count      int
 	buttonFunc ButtonFunc
+	stateKey   string
 
 	sync.Mutex
 	notifyCh <-chan struct{}
@@ -107,6 +117,14 @@ func (g *grouper) Show(index int) {
 	g.setIndex(index)
 }
 
+func (g *grouper) ShowFrom(ch <-chan int) {
+	go func() {
+		for index := range ch {
+			g.setIndex(index)
+		}
+	}()
+}
+
 func (g *grouper) Count() int {
 	return g.count
 }
@@ -117,13 +135,29 @@ func (g *grouper) setIndex(index int) {
 	// held. Group only releases the lock once it's done with the grouper.
 	g.Lock()
 	defer g.Unlock()
+	if g.count == 0 {
+		return
+	}
 	// Handle wrap around on either side.
-	current := (index + g.count) % g.count
+	current := (index%g.count + g.count) % g.count
 	l.Fine("%s switched to #%d", l.ID(g), current)
 	g.current.Store(current)
+	if g.stateKey != "" {
+		group.SaveState(g.stateKey, current)
+	}
 	g.notifyFn()
 }
 
+func (g *grouper) Persist(key string) {
+	g.Lock()
+	g.stateKey = key
+	g.Unlock()
+	var current int
+	if key != "" && group.LoadState(key, &current) {
+		g.setIndex(current)
+	}
+}
+
 func (g *grouper) ButtonFunc(f ButtonFunc) {
 	g.Lock()
 	defer g.Unlock()
@@ -15,6 +15,8 @@
 package switching
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"barista.run/bar"
@@ -83,3 +85,70 @@ func TestSwitching(t *testing.T) {
 	testBar.NextOutput().AssertText([]string{"/*", "0", "*/"})
 	require.Equal(t, 0, ctrl.Current(), "wraparound on right")
 }
+
+func TestEmptySwitching(t *testing.T) {
+	testBar.New(t)
+	grp, ctrl := Group()
+	testBar.Run(grp)
+	testBar.NextOutput().AssertEmpty()
+
+	require.NotPanics(t, ctrl.Next)
+	require.NotPanics(t, func() { ctrl.Show(-5) })
+	testBar.AssertNoOutput("with no modules")
+	require.Equal(t, 0, ctrl.Current())
+}
+
+func TestPersist(t *testing.T) {
+	testBar.New(t)
+	tmpDir, err := ioutil.TempDir("", "switching")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	os.Setenv("XDG_STATE_HOME", tmpDir)
+	defer os.Unsetenv("XDG_STATE_HOME")
+
+	_, ctrl := Group(testModule.New(t), testModule.New(t), testModule.New(t))
+	ctrl.Persist("switching")
+	require.Equal(t, 0, ctrl.Current(), "with no saved state")
+	ctrl.Show(2)
+
+	_, ctrl = Group(testModule.New(t), testModule.New(t), testModule.New(t))
+	ctrl.Persist("switching")
+	require.Equal(t, 2, ctrl.Current(), "restores saved state")
+
+	_, ctrl = Group(testModule.New(t), testModule.New(t))
+	ctrl.Persist("switching")
+	require.Equal(t, 0, ctrl.Current(),
+		"saved state wraps around if there are fewer modules")
+}
+
+func TestShowFrom(t *testing.T) {
+	testBar.New(t)
+
+	tm0 := testModule.New(t)
+	tm1 := testModule.New(t)
+	grp, ctrl := Group(tm0, tm1)
+
+	testBar.Run(grp)
+	tm0.AssertStarted()
+	tm1.AssertStarted()
+	testBar.NextOutput().AssertText([]string{">"})
+
+	tm0.OutputText("a")
+	testBar.NextOutput().AssertText([]string{"a", ">"})
+	tm1.OutputText("b")
+	testBar.AssertNoOutput("on hidden module update")
+
+	ch := make(chan int)
+	ctrl.ShowFrom(ch)
+	ch <- 1
+	testBar.NextOutput().AssertText([]string{"<", "b"}, "on external signal")
+	require.Equal(t, 1, ctrl.Current())
+
+	ch <- 0
+	testBar.NextOutput().AssertText([]string{"a", ">"})
+
+	close(ch)
+	ctrl.Next()
+	testBar.NextOutput().AssertText([]string{"<", "b"},
+		"controller works after channel is closed")
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Keys maps keys, in bindsym syntax (e.g. "Left" or "Shift+Tab"), to bar
+// keyboard commands (see barista.EnableKeyboard). The special command "exit"
+// returns the window manager to the default binding mode.
+type Keys map[string]string
+
+// DefaultKeys returns the default keys for controlling the bar: arrow keys
+// (or h and l) to move between modules, return or space to click, m and r for
+// middle and right clicks, up and down to scroll, e and c to expand or
+// collapse groups, and escape to exit.
+func DefaultKeys() Keys {
+	return Keys{
+		"Left":   "previous",
+		"h":      "previous",
+		"Right":  "next",
+		"l":      "next",
+		"Return": "click",
+		"space":  "click",
+		"m":      "click middle",
+		"r":      "click right",
+		"Up":     "click up",
+		"Down":   "click down",
+		"e":      "expand",
+		"c":      "collapse",
+		"Escape": "exit",
+	}
+}
+
+// KeyboardMode returns the i3 (or sway) configuration for a binding mode that
+// controls the bar using the given keys, to be added to the window manager's
+// config (or included from it). For example,
+//
+//	wm.KeyboardMode("barista", wm.DefaultKeys())
+//
+// returns a "barista" mode, which can be entered with a binding such as
+//
+//	bindsym $mod+b mode barista
+func KeyboardMode(mode string, keys Keys) string {
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "mode %q {\n", mode)
+	for _, k := range sorted {
+		cmd := keys[k]
+		if cmd == "exit" {
+			fmt.Fprintf(&out, "\tbindsym %s mode default\n", k)
+		} else {
+			fmt.Fprintf(&out, "\tbindsym %s %sbar %s\n", k, prefix, cmd)
+		}
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
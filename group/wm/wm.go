@@ -0,0 +1,229 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package wm provides controllers for groups that are driven by the window
+manager (i3 or sway) over IPC, instead of by mouse clicks.
+
+FollowMode switches a modal group to follow the window manager's binding mode,
+e.g. showing system stats while in i3's "resize" mode:
+
+	m := modal.New()
+	m.Mode("resize").Detail(cpuload, meminfo)
+	grp, ctrl := m.Build()
+	wm.FollowMode(ctrl)
+
+Bind (and the Bind* helpers for existing groups) allow controlling groups from
+key bindings, using i3's "nop" command. For example, with
+
+	wm.BindSwitching("media", ctrl)
+
+the following bindings switch between modules in the "media" group:
+
+	bindsym $mod+bracketleft nop barista media previous
+	bindsym $mod+bracketright nop barista media next
+
+KeyboardMode generates a binding mode for controlling the whole bar from the
+keyboard, see barista.EnableKeyboard.
+*/
+package wm // import "barista.run/group/wm"
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"barista.run/base/watchers/i3"
+	"barista.run/group/collapsing"
+	"barista.run/group/modal"
+	"barista.run/group/switching"
+	l "barista.run/logging"
+)
+
+// prefix is the prefix of nop commands handled by barista.
+const prefix = "nop barista "
+
+var (
+	once      sync.Once
+	mu        sync.Mutex
+	followers []modal.Controller
+	listeners []func(string)
+	handlers  = map[string]func(...string){}
+)
+
+// connect subscribes to window manager events, the first time it's called.
+func connect() {
+	once.Do(func() {
+		events, err := i3.Subscribe(i3.Mode, i3.Binding)
+		if err != nil {
+			l.Log("Failed to connect to window manager: %v", err)
+			return
+		}
+		go listen(events)
+	})
+}
+
+func listen(events <-chan i3.Event) {
+	for e := range events {
+		switch e.Type {
+		case i3.Mode:
+			var m i3.ModeEvent
+			if json.Unmarshal(e.Payload, &m) == nil {
+				modeChanged(m.Change)
+			}
+		case i3.Binding:
+			var b i3.BindingEvent
+			if json.Unmarshal(e.Payload, &b) == nil {
+				runCommand(b.Binding.Command)
+			}
+		}
+	}
+	l.Log("Lost connection to window manager")
+}
+
+func modeChanged(mode string) {
+	mu.Lock()
+	fs := append([]modal.Controller(nil), followers...)
+	ls := append([]func(string){}, listeners...)
+	mu.Unlock()
+	for _, fn := range ls {
+		fn(mode)
+	}
+	for _, c := range fs {
+		if hasMode(c, mode) {
+			c.Activate(mode)
+		} else {
+			c.Reset()
+		}
+	}
+}
+
+func hasMode(c modal.Controller, mode string) bool {
+	for _, m := range c.Modes() {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func runCommand(cmd string) {
+	if !strings.HasPrefix(cmd, prefix) {
+		return
+	}
+	args := strings.Fields(strings.TrimPrefix(cmd, prefix))
+	if len(args) == 0 {
+		return
+	}
+	mu.Lock()
+	handler, ok := handlers[args[0]]
+	mu.Unlock()
+	if !ok {
+		l.Log("No handler for window manager command '%s'", cmd)
+		return
+	}
+	handler(args[1:]...)
+}
+
+// FollowMode activates the mode of the modal group with the same name as the
+// window manager's current binding mode, and resets the group when the window
+// manager returns to the default mode (or any mode not in the group).
+func FollowMode(c modal.Controller) {
+	mu.Lock()
+	followers = append(followers, c)
+	mu.Unlock()
+	connect()
+}
+
+// OnModeChange calls the given function with the name of the window manager's
+// binding mode whenever it changes, "default" if none.
+func OnModeChange(fn func(mode string)) {
+	mu.Lock()
+	listeners = append(listeners, fn)
+	mu.Unlock()
+	connect()
+}
+
+// Bind calls the handler with the remaining arguments whenever the window
+// manager runs a "nop barista <name> [args...]" command, e.g. from a bindsym.
+// Binding the same name again replaces the previous handler.
+func Bind(name string, handler func(args ...string)) {
+	mu.Lock()
+	handlers[name] = handler
+	mu.Unlock()
+	connect()
+}
+
+// BindModal binds commands to control a modal group:
+//
+//	nop barista <name> activate <mode>
+//	nop barista <name> toggle <mode>
+//	nop barista <name> reset
+func BindModal(name string, c modal.Controller) {
+	Bind(name, func(args ...string) {
+		switch {
+		case len(args) == 2 && args[0] == "activate":
+			c.Activate(args[1])
+		case len(args) == 2 && args[0] == "toggle":
+			c.Toggle(args[1])
+		case len(args) == 1 && args[0] == "reset":
+			c.Reset()
+		default:
+			l.Log("Unknown modal group command %v", args)
+		}
+	})
+}
+
+// BindSwitching binds commands to control a switching group:
+//
+//	nop barista <name> next
+//	nop barista <name> previous
+//	nop barista <name> show <index>
+func BindSwitching(name string, c switching.Controller) {
+	Bind(name, func(args ...string) {
+		switch {
+		case len(args) == 1 && args[0] == "next":
+			c.Next()
+		case len(args) == 1 && args[0] == "previous":
+			c.Previous()
+		case len(args) == 2 && args[0] == "show":
+			if idx, err := strconv.Atoi(args[1]); err == nil {
+				c.Show(idx)
+			}
+		default:
+			l.Log("Unknown switching group command %v", args)
+		}
+	})
+}
+
+// BindCollapsing binds commands to control a collapsing group:
+//
+//	nop barista <name> expand
+//	nop barista <name> collapse
+//	nop barista <name> toggle
+func BindCollapsing(name string, c collapsing.Controller) {
+	Bind(name, func(args ...string) {
+		switch {
+		case len(args) == 1 && args[0] == "expand":
+			c.Expand()
+		case len(args) == 1 && args[0] == "collapse":
+			c.Collapse()
+		case len(args) == 1 && args[0] == "toggle":
+			c.Toggle()
+		default:
+			l.Log("Unknown collapsing group command %v", args)
+		}
+	})
+}
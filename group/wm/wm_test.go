@@ -0,0 +1,171 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wm
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"barista.run/base/watchers/i3"
+	"barista.run/group/collapsing"
+	"barista.run/group/modal"
+	"barista.run/group/switching"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+var srv *i3.TestServer
+
+func mode(name string) {
+	srv.Emit(i3.Mode, map[string]interface{}{"change": name})
+}
+
+func binding(cmd string) {
+	srv.Emit(i3.Binding, map[string]interface{}{
+		"change":  "run",
+		"binding": map[string]interface{}{"command": cmd},
+	})
+}
+
+func waitFor(t *testing.T, fn func() bool, msg string) {
+	for start := time.Now(); !fn(); {
+		require.True(t, time.Since(start) < time.Second, msg)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFollowMode(t *testing.T) {
+	m := modal.New()
+	m.Mode("resize").Detail(testModule.New(t))
+	m.Mode("launch").Detail(testModule.New(t))
+	_, ctrl := m.Build()
+	FollowMode(ctrl)
+
+	current := func(mode string) func() bool {
+		return func() bool { return ctrl.Current() == mode }
+	}
+	mode("resize")
+	waitFor(t, current("resize"), "activates matching mode")
+	mode("launch")
+	waitFor(t, current("launch"), "switches modes")
+	mode("default")
+	waitFor(t, current(""), "resets on default mode")
+	mode("launch")
+	waitFor(t, current("launch"), "activates matching mode")
+	mode("other")
+	waitFor(t, current(""), "resets on unknown mode")
+}
+
+func TestOnModeChange(t *testing.T) {
+	modes := make(chan string, 10)
+	OnModeChange(func(mode string) {
+		select {
+		case modes <- mode:
+		default:
+			// Listeners are never removed, so this must not block when the
+			// test is run more than once.
+		}
+	})
+	mode("resize")
+	mode("default")
+	for _, expected := range []string{"resize", "default"} {
+		select {
+		case m := <-modes:
+			require.Equal(t, expected, m)
+		case <-time.After(time.Second):
+			require.Fail(t, "mode change not received", expected)
+		}
+	}
+}
+
+func TestKeyboardMode(t *testing.T) {
+	require.Equal(t, `mode "barista" {
+	bindsym Escape mode default
+	bindsym Left nop barista bar previous
+	bindsym Return nop barista bar click
+	bindsym r nop barista bar click right
+}
+`, KeyboardMode("barista", Keys{
+		"Left":   "previous",
+		"Return": "click",
+		"r":      "click right",
+		"Escape": "exit",
+	}))
+
+	config := KeyboardMode("bar", DefaultKeys())
+	require.Contains(t, config, "mode \"bar\" {\n")
+	require.Contains(t, config, "\tbindsym Right nop barista bar next\n")
+	require.Contains(t, config, "\tbindsym Escape mode default\n")
+}
+
+func TestBindings(t *testing.T) {
+	m := modal.New()
+	m.Mode("a").Detail(testModule.New(t))
+	m.Mode("b").Detail(testModule.New(t))
+	_, modalCtrl := m.Build()
+	_, switchingCtrl := switching.Group(
+		testModule.New(t), testModule.New(t), testModule.New(t))
+	_, collapsingCtrl := collapsing.Group(testModule.New(t))
+
+	BindModal("modal", modalCtrl)
+	BindSwitching("switch", switchingCtrl)
+	BindCollapsing("collapse", collapsingCtrl)
+
+	binding("nop barista modal activate b")
+	waitFor(t, func() bool { return modalCtrl.Current() == "b" }, "modal activate")
+	binding("nop barista modal toggle b")
+	waitFor(t, func() bool { return modalCtrl.Current() == "" }, "modal toggle")
+	binding("nop barista modal toggle a")
+	waitFor(t, func() bool { return modalCtrl.Current() == "a" }, "modal toggle")
+	binding("nop barista modal reset")
+	waitFor(t, func() bool { return modalCtrl.Current() == "" }, "modal reset")
+
+	binding("nop barista switch next")
+	waitFor(t, func() bool { return switchingCtrl.Current() == 1 }, "switching next")
+	binding("nop barista switch show 0")
+	waitFor(t, func() bool { return switchingCtrl.Current() == 0 }, "switching show")
+	binding("nop barista switch previous")
+	waitFor(t, func() bool { return switchingCtrl.Current() == 2 }, "switching previous")
+
+	binding("nop barista collapse expand")
+	waitFor(t, collapsingCtrl.Expanded, "collapsing expand")
+	binding("nop barista collapse toggle")
+	waitFor(t, func() bool { return !collapsingCtrl.Expanded() }, "collapsing toggle")
+
+	called := make(chan []string, 10)
+	Bind("custom", func(args ...string) { called <- args })
+	binding("nop something else")
+	binding("nop barista unknown foo")
+	binding("nop barista")
+	binding("nop barista custom  foo   bar ")
+	select {
+	case args := <-called:
+		require.Equal(t, []string{"foo", "bar"}, args,
+			"only barista commands for the bound name are handled")
+	case <-time.After(time.Second):
+		require.Fail(t, "custom binding not called")
+	}
+}
+
+func TestMain(m *testing.M) {
+	srv = i3.SetupTestServer()
+	connect()
+	srv.WaitForSubscription()
+	code := m.Run()
+	srv.Close()
+	os.Exit(code)
+}
@@ -0,0 +1,271 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package httpclient provides a shared HTTP client for modules and providers that
+fetch data over the network.
+
+All requests share a pool of connections, and have consistent timeouts. GET
+responses with an ETag or Last-Modified header are kept in memory, and later
+requests for the same URL are made conditional, so that servers can reply with
+304 Not Modified instead of sending the same response again. Callers always
+receive the full response, and can ignore caching entirely. Requests that set
+their own conditional headers (If-None-Match or If-Modified-Since) are not
+changed, and their responses are passed through as-is. The cache is limited
+to a few megabytes, and the least recently used responses are evicted first.
+
+Responses are transparently decompressed if the server supports gzip, and
+requests that do not set a User-Agent use UserAgent.
+*/
+package httpclient // import "barista.run/httpclient"
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserAgent is sent with requests that do not set their own User-Agent.
+var UserAgent = "barista (+https://barista.run)"
+
+// Timeout is the maximum duration of requests made using Client, including
+// reading the response body.
+const Timeout = 30 * time.Second
+
+var (
+	// maxEntries is the number of responses kept in memory.
+	maxEntries = 256
+	// maxBodySize is the largest response body kept in memory.
+	maxBodySize int64 = 256 << 10
+	// maxCacheSize is the total size of response bodies kept in memory.
+	maxCacheSize int64 = 4 << 20
+)
+
+var transport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:          64,
+	MaxIdleConnsPerHost:   4,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 20 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+var shared = &cache{
+	base:    transport,
+	entries: map[string]*list.Element{},
+	lru:     list.New(),
+}
+
+// Client returns a new client that uses the shared transport, with the default
+// timeout. Clients are cheap, and can be modified (e.g. in tests) without
+// affecting other users of the shared transport.
+func Client() *http.Client {
+	return &http.Client{Transport: shared, Timeout: Timeout}
+}
+
+// Transport returns the shared transport, for clients that need to wrap it,
+// e.g. to authorise requests using oauth.
+func Transport() http.RoundTripper {
+	return shared
+}
+
+// cache is a RoundTripper that revalidates cached responses using conditional
+// requests.
+type cache struct {
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *entry
+	lru     *list.List
+	size    int64 // of all cached bodies.
+}
+
+// entry is a cached response.
+type entry struct {
+	key    string
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (c *cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = cloneRequest(req)
+		req.Header.Set("User-Agent", UserAgent)
+	}
+	if !cacheable(req) {
+		return c.base.RoundTrip(req)
+	}
+	key := cacheKey(req)
+	e := c.get(key)
+	if e != nil {
+		req = cloneRequest(req)
+		if etag := e.header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastMod := e.header.Get("Last-Modified"); lastMod != "" {
+			req.Header.Set("If-Modified-Since", lastMod)
+		}
+	}
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && e != nil {
+		resp.Body.Close()
+		return e.response(req), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	if !storable(resp) {
+		// The new response replaces any cached one, which must not be used
+		// for later 304 responses.
+		if e != nil {
+			c.remove(key)
+		}
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > maxBodySize {
+		// Too large to keep, so return the rest of the body as it is read.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	c.put(&entry{key, resp.StatusCode, cloneHeader(resp.Header), body})
+	return resp, nil
+}
+
+// cacheable returns true if the request's response can be cached. Requests
+// that are already conditional are left alone, since the caller is handling
+// caching itself and expects to see 304 responses.
+func cacheable(req *http.Request) bool {
+	if req.Method != "" && req.Method != "GET" {
+		return false
+	}
+	for _, h := range []string{"If-None-Match", "If-Modified-Since", "Range"} {
+		if req.Header.Get(h) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// storable returns true if the response can be revalidated later.
+func storable(resp *http.Response) bool {
+	if strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return false
+	}
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// cacheKey returns the key for a request. Requests with different credentials
+// are kept separate, since their responses are likely to be different.
+func cacheKey(req *http.Request) string {
+	return req.URL.String() + "\x00" + req.Header.Get("Authorization")
+}
+
+func (c *cache) get(key string) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*entry)
+}
+
+func (c *cache) put(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[e.key]; ok {
+		c.size -= int64(len(el.Value.(*entry).body))
+		el.Value = e
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[e.key] = c.lru.PushFront(e)
+	}
+	c.size += int64(len(e.body))
+	for c.lru.Len() > maxEntries || c.size > maxCacheSize {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// removeElement removes an entry from the cache. Must be called with mu held.
+func (c *cache) removeElement(el *list.Element) {
+	e := el.Value.(*entry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	c.size -= int64(len(e.body))
+}
+
+func (e *entry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cloneHeader(e.header),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// cloneRequest returns a copy of the request with its own headers, since
+// RoundTrippers must not modify the original request.
+func cloneRequest(req *http.Request) *http.Request {
+	r := req.WithContext(req.Context())
+	r.Header = cloneHeader(req.Header)
+	return r
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
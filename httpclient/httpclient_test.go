@@ -0,0 +1,292 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// server counts requests, and serves the contents set for each path, with an
+// ETag of the current version.
+type server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests int
+	versions map[string]int
+	headers  []http.Header
+}
+
+func newServer() *server {
+	s := &server{versions: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.headers = append(s.headers, r.Header)
+	version := s.versions[r.URL.Path]
+	etag := fmt.Sprintf(`"v%d"`, version)
+	switch r.URL.Path {
+	case "/nostore":
+		w.Header().Set("Cache-Control", "no-store")
+	case "/modified":
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-Modified-Since") != "" && version == 0 {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "%s v%d", r.URL.Path, version)
+		return
+	case "/plain":
+		fmt.Fprintf(w, "%s v%d", r.URL.Path, version)
+		return
+	case "/changing":
+		// Only the first version has an ETag.
+		if version == 0 {
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		fmt.Fprintf(w, "%s v%d", r.URL.Path, version)
+		return
+	case "/gzip":
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprintf(gz, "%s v%d", r.URL.Path, version)
+		gz.Close()
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	fmt.Fprintf(w, "%s v%d %s", r.URL.Path, version, r.Header.Get("Authorization"))
+}
+
+func (s *server) update(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[path]++
+}
+
+func (s *server) lastHeader() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers[len(s.headers)-1]
+}
+
+func get(t *testing.T, req *http.Request) (int, string) {
+	resp, err := Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func newRequest(method, url string) *http.Request {
+	req, _ := http.NewRequest(method, url, nil)
+	return req
+}
+
+func TestETag(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+
+	code, body := get(t, newRequest("GET", s.URL+"/etag"))
+	require.Equal(t, 200, code)
+	require.Equal(t, "/etag v0 ", body)
+	require.Equal(t, "", s.lastHeader().Get("If-None-Match"))
+	require.Equal(t, UserAgent, s.lastHeader().Get("User-Agent"))
+
+	code, body = get(t, newRequest("GET", s.URL+"/etag"))
+	require.Equal(t, 200, code, "304 is returned as the cached response")
+	require.Equal(t, "/etag v0 ", body)
+	require.Equal(t, `"v0"`, s.lastHeader().Get("If-None-Match"))
+
+	s.update("/etag")
+	_, body = get(t, newRequest("GET", s.URL+"/etag"))
+	require.Equal(t, "/etag v1 ", body, "on change")
+	_, body = get(t, newRequest("GET", s.URL+"/etag"))
+	require.Equal(t, "/etag v1 ", body)
+	require.Equal(t, `"v1"`, s.lastHeader().Get("If-None-Match"))
+	require.Equal(t, 4, s.requests)
+}
+
+func TestLastModified(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+
+	_, body := get(t, newRequest("GET", s.URL+"/modified"))
+	require.Equal(t, "/modified v0", body)
+	code, body := get(t, newRequest("GET", s.URL+"/modified"))
+	require.Equal(t, 200, code)
+	require.Equal(t, "/modified v0", body)
+	require.Equal(t, "Mon, 02 Jan 2006 15:04:05 GMT",
+		s.lastHeader().Get("If-Modified-Since"))
+}
+
+func TestNotCached(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+
+	for _, path := range []string{"/plain", "/nostore"} {
+		get(t, newRequest("GET", s.URL+path))
+		get(t, newRequest("GET", s.URL+path))
+		require.Equal(t, "", s.lastHeader().Get("If-None-Match"), path)
+		require.Equal(t, "", s.lastHeader().Get("If-Modified-Since"), path)
+	}
+
+	get(t, newRequest("POST", s.URL+"/post"))
+	get(t, newRequest("POST", s.URL+"/post"))
+	require.Equal(t, "", s.lastHeader().Get("If-None-Match"), "POST")
+
+	req := newRequest("GET", s.URL+"/conditional")
+	get(t, req)
+	req.Header.Set("If-None-Match", `"v0"`)
+	code, body := get(t, req)
+	require.Equal(t, 304, code, "conditional requests are passed through")
+	require.Empty(t, body)
+}
+
+func TestReplacedWithoutValidators(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+
+	get(t, newRequest("GET", s.URL+"/changing"))
+	_, body := get(t, newRequest("GET", s.URL+"/changing"))
+	require.Equal(t, "/changing v0", body)
+	require.Equal(t, `"v0"`, s.lastHeader().Get("If-None-Match"))
+
+	s.update("/changing")
+	_, body = get(t, newRequest("GET", s.URL+"/changing"))
+	require.Equal(t, "/changing v1", body)
+	_, body = get(t, newRequest("GET", s.URL+"/changing"))
+	require.Equal(t, "/changing v1", body)
+	require.Equal(t, "", s.lastHeader().Get("If-None-Match"),
+		"cached response removed when replaced by one without an ETag")
+}
+
+func TestAuthorization(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+
+	req := newRequest("GET", s.URL+"/auth")
+	req.Header.Set("Authorization", "alice")
+	_, body := get(t, req)
+	require.Equal(t, "/auth v0 alice", body)
+
+	req = newRequest("GET", s.URL+"/auth")
+	req.Header.Set("Authorization", "bob")
+	req.Header.Set("User-Agent", "custom")
+	_, body = get(t, req)
+	require.Equal(t, "/auth v0 bob", body)
+	require.Equal(t, "", s.lastHeader().Get("If-None-Match"),
+		"different credentials are cached separately")
+	require.Equal(t, "custom", s.lastHeader().Get("User-Agent"))
+}
+
+func TestGzip(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+	_, body := get(t, newRequest("GET", s.URL+"/gzip"))
+	require.Equal(t, "/gzip v0", body)
+	require.Equal(t, "gzip", s.lastHeader().Get("Accept-Encoding"))
+}
+
+func TestEviction(t *testing.T) {
+	defer func(orig int) { maxEntries = orig }(maxEntries)
+	maxEntries = 2
+	s := newServer()
+	defer s.Close()
+
+	for _, path := range []string{"/a", "/b", "/a", "/c"} {
+		get(t, newRequest("GET", s.URL+path))
+	}
+	get(t, newRequest("GET", s.URL+"/a"))
+	require.Equal(t, `"v0"`, s.lastHeader().Get("If-None-Match"),
+		"recently used entries are kept")
+	get(t, newRequest("GET", s.URL+"/b"))
+	require.Equal(t, "", s.lastHeader().Get("If-None-Match"),
+		"least recently used entries are evicted")
+}
+
+func TestMaxCacheSize(t *testing.T) {
+	defer func(orig int64) { maxCacheSize = orig }(maxCacheSize)
+	s := newServer()
+	defer s.Close()
+	// Each response body is 6 bytes, e.g. "/a v0 ".
+	maxCacheSize = 12
+
+	for _, path := range []string{"/a", "/b", "/a", "/c"} {
+		get(t, newRequest("GET", s.URL+path))
+	}
+	get(t, newRequest("GET", s.URL+"/a"))
+	require.Equal(t, `"v0"`, s.lastHeader().Get("If-None-Match"),
+		"recently used entries are kept")
+	get(t, newRequest("GET", s.URL+"/b"))
+	require.Equal(t, "", s.lastHeader().Get("If-None-Match"),
+		"least recently used entries are evicted to stay within the size")
+
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	size := int64(0)
+	for el := shared.lru.Front(); el != nil; el = el.Next() {
+		size += int64(len(el.Value.(*entry).body))
+	}
+	require.Equal(t, size, shared.size, "tracked size matches cached bodies")
+	require.True(t, shared.size <= maxCacheSize)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	defer func(orig int64) { maxBodySize = orig }(maxBodySize)
+	maxBodySize = 4
+	s := newServer()
+	defer s.Close()
+
+	_, body := get(t, newRequest("GET", s.URL+"/large"))
+	require.Equal(t, "/large v0 ", body, "large responses are returned in full")
+	get(t, newRequest("GET", s.URL+"/large"))
+	require.Equal(t, "", s.lastHeader().Get("If-None-Match"),
+		"large responses are not cached")
+}
+
+func TestTransport(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+	c := &http.Client{Transport: Transport()}
+	resp, err := c.Get(s.URL + "/transport")
+	require.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.True(t, strings.HasPrefix(string(body), "/transport v0"))
+	require.Equal(t, UserAgent, s.lastHeader().Get("User-Agent"))
+}
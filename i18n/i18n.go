@@ -0,0 +1,140 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package i18n provides translations of the text shown by barista and its
+built-in modules, such as the names of days and months, battery statuses, and
+prompts.
+
+The language is taken from the environment (LC_ALL, LC_MESSAGES, or LANG), and
+can be changed using SetLanguage. Text without a translation for the current
+language is shown in English.
+
+Messages are looked up by their English text, which is also used as the format
+string. Out-of-tree modules can use Sprintf for their own text, and Register
+their translations, e.g.
+
+	func init() {
+		i18n.Register("de", map[string]string{
+			"%d unread": "%d ungelesen",
+		})
+	}
+
+	return outputs.Text(i18n.Sprintf("%d unread", count))
+*/
+package i18n // import "barista.run/i18n"
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+var (
+	mu      sync.Mutex
+	builder = catalog.NewBuilder(catalog.Fallback(language.English))
+	lang    = envLanguage()
+	printer atomic.Value // of *message.Printer
+)
+
+func init() {
+	for tag, translations := range builtin {
+		if err := Register(tag, translations); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// envLanguage returns the language set in the environment, using the same
+// precedence as gettext.
+func envLanguage() language.Tag {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(env); locale != "" {
+			return parseLocale(locale)
+		}
+	}
+	return language.English
+}
+
+// parseLocale converts a POSIX locale, e.g. "de_DE.UTF-8", to a language tag.
+func parseLocale(locale string) language.Tag {
+	if idx := strings.IndexAny(locale, ".@"); idx >= 0 {
+		locale = locale[:idx]
+	}
+	if locale == "C" || locale == "POSIX" {
+		return language.English
+	}
+	tag, err := language.Parse(strings.Replace(locale, "_", "-", -1))
+	if err != nil {
+		return language.English
+	}
+	return tag
+}
+
+// SetLanguage sets the language used for all text, as a BCP 47 tag (e.g. "fr"
+// or "pt-BR"). The closest available translation is used for each message.
+func SetLanguage(tag string) error {
+	t, err := language.Parse(tag)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	lang = t
+	update()
+	return nil
+}
+
+// Language returns the language currently used for all text.
+func Language() language.Tag {
+	mu.Lock()
+	defer mu.Unlock()
+	return lang
+}
+
+// Register adds translations for the given language, as a map of English
+// messages to translated messages. Translated messages use the same format
+// verbs as the English message.
+func Register(tag string, translations map[string]string) error {
+	t, err := language.Parse(tag)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for key, msg := range translations {
+		if err := builder.SetString(t, key, msg); err != nil {
+			return err
+		}
+	}
+	update()
+	return nil
+}
+
+// update replaces the printer after a change to the language or the
+// translations. It must be called with the lock held.
+func update() {
+	printer.Store(message.NewPrinter(lang, message.Catalog(builder)))
+}
+
+// Sprintf formats a message in the current language, using the translation
+// of format if one is available.
+func Sprintf(format string, args ...interface{}) string {
+	return printer.Load().(*message.Printer).Sprintf(format, args...)
+}
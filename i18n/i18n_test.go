@@ -0,0 +1,134 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i18n
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestParseLocale(t *testing.T) {
+	for _, tc := range []struct {
+		locale   string
+		expected language.Tag
+	}{
+		{"de_DE.UTF-8", language.MustParse("de-DE")},
+		{"fr_CA", language.MustParse("fr-CA")},
+		{"es", language.Spanish},
+		{"sr_RS@latin", language.MustParse("sr-RS")},
+		{"C", language.English},
+		{"POSIX", language.English},
+		{"C.UTF-8", language.English},
+		{"not a locale", language.English},
+	} {
+		require.Equal(t, tc.expected, parseLocale(tc.locale), tc.locale)
+	}
+}
+
+func TestEnvLanguage(t *testing.T) {
+	envs := []string{"LC_ALL", "LC_MESSAGES", "LANG"}
+	old := map[string]string{}
+	for _, env := range envs {
+		old[env] = os.Getenv(env)
+		os.Unsetenv(env)
+	}
+	defer func() {
+		for env, val := range old {
+			os.Setenv(env, val)
+		}
+	}()
+
+	require.Equal(t, language.English, envLanguage(), "with no locale")
+	os.Setenv("LANG", "fr_FR.UTF-8")
+	require.Equal(t, language.MustParse("fr-FR"), envLanguage())
+	os.Setenv("LC_MESSAGES", "de_DE.UTF-8")
+	require.Equal(t, language.MustParse("de-DE"), envLanguage(),
+		"LC_MESSAGES overrides LANG")
+	os.Setenv("LC_ALL", "es_ES.UTF-8")
+	require.Equal(t, language.MustParse("es-ES"), envLanguage(),
+		"LC_ALL overrides everything")
+}
+
+func TestSprintf(t *testing.T) {
+	defer SetLanguage("en")
+
+	require.NoError(t, SetLanguage("en"))
+	require.Equal(t, language.English, Language())
+	require.Equal(t, "Full", Sprintf("Full"))
+	require.Equal(t, "Errors: 3", Sprintf("Errors: %d", 3))
+
+	require.NoError(t, SetLanguage("de-AT"))
+	require.Equal(t, "Voll", Sprintf("Full"), "uses closest language")
+	require.Equal(t, "Fehler: 3", Sprintf("Errors: %d", 3))
+	require.Equal(t, "Untranslated 4", Sprintf("Untranslated %d", 4),
+		"falls back to English")
+
+	require.NoError(t, SetLanguage("ja"))
+	require.Equal(t, "Full", Sprintf("Full"), "with no translations")
+
+	require.Error(t, SetLanguage("not a language"))
+	require.Equal(t, language.Japanese, Language(), "unchanged on error")
+}
+
+func TestRegister(t *testing.T) {
+	defer SetLanguage("en")
+
+	require.NoError(t, Register("nl", map[string]string{
+		"%d unread": "%d ongelezen",
+		"Full":      "Vol",
+	}))
+	require.Error(t, Register("???", map[string]string{"a": "b"}))
+
+	require.NoError(t, SetLanguage("nl-BE"))
+	require.Equal(t, "5 ongelezen", Sprintf("%d unread", 5))
+	require.Equal(t, "Vol", Sprintf("Full"))
+
+	require.NoError(t, SetLanguage("en"))
+	require.Equal(t, "5 unread", Sprintf("%d unread", 5))
+
+	require.NoError(t, SetLanguage("fr"))
+	require.NoError(t, Register("fr", map[string]string{"%d unread": "%d non lus"}))
+	require.Equal(t, "5 non lus", Sprintf("%d unread", 5),
+		"on registering for the current language")
+	require.Equal(t, "Chargée", Sprintf("Full"), "keeps built-in translations")
+}
+
+func TestFormatTime(t *testing.T) {
+	defer SetLanguage("en")
+	tm := time.Date(2018, time.March, 5, 14, 30, 0, 0, time.UTC)
+
+	SetLanguage("en")
+	for _, layout := range []string{
+		"Mon Jan 2 15:04", "Monday, January 2, 2006", "2006-01-02", "", "Month",
+	} {
+		require.Equal(t, tm.Format(layout), FormatTime(tm, layout), layout)
+	}
+
+	SetLanguage("de")
+	require.Equal(t, "Mo 5. Mär 14:30", FormatTime(tm, "Mon 2. Jan 15:04"))
+	require.Equal(t, "Montag, 5. März 2018", FormatTime(tm, "Monday, 2. January 2006"))
+	require.Equal(t, "2018-03-05", FormatTime(tm, "2006-01-02"))
+
+	SetLanguage("fr")
+	require.Equal(t, "lundi 5 mars", FormatTime(tm, "Monday 2 January"))
+	require.Equal(t, "lun. 5 mars, lundi", FormatTime(tm, "Mon 2 Jan, Monday"))
+
+	SetLanguage("es")
+	require.Equal(t, "lunes, 5 de marzo", FormatTime(tm, "Monday, 2 de January"))
+}
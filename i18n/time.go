@@ -0,0 +1,45 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i18n
+
+import (
+	"strings"
+	"time"
+)
+
+// Layout elements that are translated, longest first so that e.g. "January"
+// is not treated as "Jan" followed by "uary".
+var names = []string{"January", "Monday", "Jan", "Mon"}
+
+// FormatTime formats a time like time.Format, but with the names of days and
+// months in the current language.
+func FormatTime(t time.Time, layout string) string {
+	var out strings.Builder
+	for {
+		idx, name := -1, ""
+		for _, n := range names {
+			if i := strings.Index(layout, n); i >= 0 && (idx < 0 || i < idx) {
+				idx, name = i, n
+			}
+		}
+		if idx < 0 {
+			out.WriteString(t.Format(layout))
+			return out.String()
+		}
+		out.WriteString(t.Format(layout[:idx]))
+		out.WriteString(Sprintf(t.Format(name)))
+		layout = layout[idx+len(name):]
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i18n
+
+// builtin contains the translations of text used by barista and its built-in
+// modules.
+var builtin = map[string]map[string]string{
+	"de": {
+		"Monday": "Montag", "Tuesday": "Dienstag", "Wednesday": "Mittwoch",
+		"Thursday": "Donnerstag", "Friday": "Freitag", "Saturday": "Samstag",
+		"Sunday": "Sonntag",
+
+		"Mon": "Mo", "Tue": "Di", "Wed": "Mi", "Thu": "Do", "Fri": "Fr",
+		"Sat": "Sa", "Sun": "So",
+
+		"January": "Januar", "February": "Februar", "March": "März",
+		"April": "April", "May": "Mai", "June": "Juni", "July": "Juli",
+		"August": "August", "September": "September", "October": "Oktober",
+		"November": "November", "December": "Dezember",
+
+		"Jan": "Jan", "Feb": "Feb", "Mar": "Mär", "Apr": "Apr", "Jun": "Jun",
+		"Jul": "Jul", "Aug": "Aug", "Sep": "Sep", "Oct": "Okt", "Nov": "Nov",
+		"Dec": "Dez",
+
+		"Error":                  "Fehler",
+		"Click again to confirm": "Zum Bestätigen erneut klicken",
+
+		"Disconnected": "Getrennt",
+		"Charging":     "Lädt",
+		"Discharging":  "Entlädt",
+		"Full":         "Voll",
+		"Not charging": "Lädt nicht",
+
+		"Work":        "Arbeit",
+		"Short break": "Kurze Pause",
+		"Long break":  "Lange Pause",
+		"(paused)":    "(angehalten)",
+
+		"Not tracking": "Keine Zeiterfassung",
+
+		"Errors: %d":   "Fehler: %d",
+		"Warnings: %d": "Warnungen: %d",
+	},
+	"es": {
+		"Monday": "lunes", "Tuesday": "martes", "Wednesday": "miércoles",
+		"Thursday": "jueves", "Friday": "viernes", "Saturday": "sábado",
+		"Sunday": "domingo",
+
+		"Mon": "lun", "Tue": "mar", "Wed": "mié", "Thu": "jue", "Fri": "vie",
+		"Sat": "sáb", "Sun": "dom",
+
+		"January": "enero", "February": "febrero", "March": "marzo",
+		"April": "abril", "May": "mayo", "June": "junio", "July": "julio",
+		"August": "agosto", "September": "septiembre", "October": "octubre",
+		"November": "noviembre", "December": "diciembre",
+
+		"Jan": "ene", "Feb": "feb", "Mar": "mar", "Apr": "abr", "Jun": "jun",
+		"Jul": "jul", "Aug": "ago", "Sep": "sept", "Oct": "oct", "Nov": "nov",
+		"Dec": "dic",
+
+		"Error":                  "Error",
+		"Click again to confirm": "Haz clic de nuevo para confirmar",
+
+		"Disconnected": "Desconectada",
+		"Charging":     "Cargando",
+		"Discharging":  "Descargando",
+		"Full":         "Completa",
+		"Not charging": "Sin cargar",
+
+		"Work":        "Trabajo",
+		"Short break": "Descanso corto",
+		"Long break":  "Descanso largo",
+		"(paused)":    "(en pausa)",
+
+		"Not tracking": "Sin registrar",
+
+		"Errors: %d":   "Errores: %d",
+		"Warnings: %d": "Avisos: %d",
+	},
+	"fr": {
+		"Monday": "lundi", "Tuesday": "mardi", "Wednesday": "mercredi",
+		"Thursday": "jeudi", "Friday": "vendredi", "Saturday": "samedi",
+		"Sunday": "dimanche",
+
+		"Mon": "lun.", "Tue": "mar.", "Wed": "mer.", "Thu": "jeu.",
+		"Fri": "ven.", "Sat": "sam.", "Sun": "dim.",
+
+		"January": "janvier", "February": "février", "March": "mars",
+		"April": "avril", "May": "mai", "June": "juin", "July": "juillet",
+		"August": "août", "September": "septembre", "October": "octobre",
+		"November": "novembre", "December": "décembre",
+
+		"Jan": "janv.", "Feb": "févr.", "Mar": "mars", "Apr": "avr.",
+		"Jun": "juin", "Jul": "juil.", "Aug": "août", "Sep": "sept.",
+		"Oct": "oct.", "Nov": "nov.", "Dec": "déc.",
+
+		"Error":                  "Erreur",
+		"Click again to confirm": "Cliquez à nouveau pour confirmer",
+
+		"Disconnected": "Déconnectée",
+		"Charging":     "En charge",
+		"Discharging":  "Sur batterie",
+		"Full":         "Chargée",
+		"Not charging": "Pas en charge",
+
+		"Work":        "Travail",
+		"Short break": "Pause courte",
+		"Long break":  "Pause longue",
+		"(paused)":    "(en pause)",
+
+		"Not tracking": "Aucun suivi",
+
+		"Errors: %d":   "Erreurs : %d",
+		"Warnings: %d": "Avertissements : %d",
+	},
+}
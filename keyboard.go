@@ -0,0 +1,187 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"image/color"
+	"sync"
+
+	"barista.run/bar"
+	"barista.run/group/wm"
+	l "barista.run/logging"
+)
+
+// The name of the wm command handled by the bar, i.e. "nop barista bar ...".
+const keyboardCommand = "bar"
+
+var (
+	keyboardOnce sync.Once
+	// The bar that receives keyboard commands.
+	keyboardBar *i3Bar
+	keyboardMu  sync.Mutex
+)
+
+// EnableKeyboard allows the bar to be used without the mouse, from key
+// bindings in the window manager (i3 or sway). While the window manager is in
+// the given binding mode, one of the modules on the bar is focused, and the
+// following commands control it:
+//
+//	nop barista bar next
+//	nop barista bar previous
+//	nop barista bar click [left|middle|right|up|down]
+//	nop barista bar expand
+//	nop barista bar collapse
+//
+// next and previous move the focus between modules, click sends a click
+// (left by default) to the first clickable segment of the focused module,
+// and expand and collapse control collapsing groups. The commands "focus" and
+// "unfocus" focus the first module and remove the focus respectively, for use
+// outside of the binding mode.
+//
+// wm.KeyboardMode generates the window manager configuration for such a mode.
+// Must be called before Run.
+func EnableKeyboard(mode string) {
+	construct()
+	instance.Lock()
+	if instance.started {
+		instance.Unlock()
+		panic("Cannot enable keyboard after .Run()")
+	}
+	instance.keyboardMode = mode
+	instance.Unlock()
+	keyboardMu.Lock()
+	keyboardBar = instance
+	keyboardMu.Unlock()
+	keyboardOnce.Do(func() {
+		wm.Bind(keyboardCommand, sendKeyCommand)
+		wm.OnModeChange(modeChanged)
+	})
+}
+
+// SetFocusColor sets the colour of the border drawn around the module that is
+// focused from the keyboard. Must be called before Run.
+func SetFocusColor(c color.Color) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change focus color after .Run()")
+	}
+	instance.focusColor = c
+}
+
+func sendKeyCommand(args ...string) {
+	keyboardMu.Lock()
+	b := keyboardBar
+	keyboardMu.Unlock()
+	b.keys <- args
+}
+
+// modeChanged focuses the first module when the window manager enters the
+// keyboard mode, and removes the focus when it leaves.
+func modeChanged(mode string) {
+	keyboardMu.Lock()
+	b := keyboardBar
+	keyboardMu.Unlock()
+	b.Lock()
+	keyboardMode := b.keyboardMode
+	b.Unlock()
+	if mode == keyboardMode {
+		sendKeyCommand("focus")
+	} else {
+		sendKeyCommand("unfocus")
+	}
+}
+
+// buttons maps the button names for the click command.
+var buttons = map[string]bar.Button{
+	"left":   bar.ButtonLeft,
+	"middle": bar.ButtonMiddle,
+	"right":  bar.ButtonRight,
+	"up":     bar.ScrollUp,
+	"down":   bar.ScrollDown,
+}
+
+// keyCommand handles a keyboard command from the window manager, and returns
+// true if the bar needs to be printed again to show a change in focus.
+func (b *i3Bar) keyCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	cmd := args[0]
+	switch {
+	case cmd == "focus" && len(args) == 1:
+		return b.setFocus(b.nextFocus(-1, 1))
+	case cmd == "unfocus" && len(args) == 1:
+		return b.setFocus(-1)
+	case cmd == "next" && len(args) == 1:
+		return b.setFocus(b.nextFocus(b.focus, 1))
+	case cmd == "previous" && len(args) == 1:
+		return b.setFocus(b.nextFocus(b.focus, -1))
+	case cmd == "click" && len(args) <= 2:
+		btn := bar.ButtonLeft
+		if len(args) == 2 {
+			var ok bool
+			if btn, ok = buttons[args[1]]; !ok {
+				l.Log("Unknown button '%s' for keyboard click", args[1])
+				return false
+			}
+		}
+		if b.focusClick != nil {
+			go b.focusClick(bar.Event{Button: btn})
+		}
+	case (cmd == "expand" || cmd == "collapse") && len(args) == 1:
+		if b.focus >= 0 {
+			go expandOrCollapse(b.modules[b.focus], cmd == "expand")
+		}
+	default:
+		l.Log("Unknown keyboard command %v", args)
+	}
+	return false
+}
+
+func expandOrCollapse(m bar.Module, expand bool) {
+	if e, ok := m.(interface{ Expand() }); ok && expand {
+		e.Expand()
+	}
+	if c, ok := m.(interface{ Collapse() }); ok && !expand {
+		c.Collapse()
+	}
+}
+
+func (b *i3Bar) setFocus(focus int) bool {
+	if focus == b.focus {
+		return false
+	}
+	b.focus = focus
+	return true
+}
+
+// nextFocus returns the index of the next module with output, starting from
+// the given index (or either end if none) and moving in the given direction,
+// wrapping around at the ends. It returns -1 if no modules have any output.
+func (b *i3Bar) nextFocus(from, dir int) int {
+	n := b.moduleSet.Len()
+	if from < 0 && dir < 0 {
+		from = 0
+	}
+	for i := 1; i <= n; i++ {
+		idx := ((from+dir*i)%n + n) % n
+		if len(b.moduleSet.LastOutput(idx)) > 0 {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/i3"
+	"barista.run/group/collapsing"
+	"barista.run/outputs"
+	"barista.run/testing/mockio"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The window manager connection is shared by all bars, so the test server
+// is only set up once, and kept for repeated runs of the test.
+var srv *i3.TestServer
+
+func TestKeyboard(t *testing.T) {
+	mode := func(name string) {
+		srv.Emit(i3.Mode, map[string]interface{}{"change": name})
+	}
+	binding := func(cmd string) {
+		srv.Emit(i3.Binding, map[string]interface{}{
+			"change":  "run",
+			"binding": map[string]interface{}{"command": "nop barista bar " + cmd},
+		})
+	}
+
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	SetFocusColor(color.RGBA{0xff, 0, 0, 0xff})
+	if srv == nil {
+		srv = i3.SetupTestServer()
+		EnableKeyboard("barista")
+		srv.WaitForSubscription()
+	} else {
+		EnableKeyboard("barista")
+	}
+
+	module1 := testModule.New(t)
+	module2 := testModule.New(t)
+	module3 := testModule.New(t)
+	grp, ctrl := collapsing.Group(testModule.New(t))
+	go Run(module1, module2, module3, grp)
+	_, err := mockStdout.ReadUntil('[', time.Second)
+	require.NoError(t, err)
+
+	module1.AssertStarted()
+	module1.Output(outputs.Text("a"))
+	readOutput(t, mockStdout)
+	module3.AssertStarted()
+	module3.Output(outputs.Group(outputs.Text("b"), outputs.Text("c")))
+	// The collapsing group may update before or after the other modules.
+	for len(readOutput(t, mockStdout)) < 4 {
+	}
+
+	borders := func() (b []string) {
+		for _, o := range readOutput(t, mockStdout) {
+			border, _ := o["border"].(string)
+			b = append(b, border)
+		}
+		return b
+	}
+
+	mode("barista")
+	require.Equal(t, []string{"#ff0000", "", "", ""}, borders(),
+		"first module focused on entering the mode")
+
+	binding("next")
+	require.Equal(t, []string{"", "#ff0000", "#ff0000", ""}, borders(),
+		"modules without output are skipped")
+
+	binding("click")
+	evt := module3.AssertClicked("on click")
+	require.Equal(t, bar.ButtonLeft, evt.Button)
+	binding("click right")
+	evt = module3.AssertClicked("on right click")
+	require.Equal(t, bar.ButtonRight, evt.Button)
+	binding("click sideways")
+	module3.AssertNotClicked("with unknown button")
+	module1.AssertNotClicked("only the focused module is clicked")
+
+	binding("next")
+	require.Equal(t, []string{"", "", "", "#ff0000"}, borders())
+	binding("expand")
+	for start := time.Now(); !ctrl.Expanded(); {
+		require.True(t, time.Since(start) < time.Second, "group expanded")
+		time.Sleep(time.Millisecond)
+	}
+	readOutput(t, mockStdout)
+	binding("collapse")
+	for start := time.Now(); ctrl.Expanded(); {
+		require.True(t, time.Since(start) < time.Second, "group collapsed")
+		time.Sleep(time.Millisecond)
+	}
+	readOutput(t, mockStdout)
+
+	binding("next")
+	require.Equal(t, []string{"#ff0000", "", "", ""}, borders(),
+		"wraps around to the first module")
+	binding("previous")
+	require.Equal(t, []string{"", "", "", "#ff0000"}, borders(),
+		"wraps around to the last module")
+
+	binding("unknown")
+	binding("click")
+	for start := time.Now(); !ctrl.Expanded(); {
+		require.True(t, time.Since(start) < time.Second, "group expanded on click")
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, []string{"", "", "", "#ff0000", "#ff0000"}, borders(),
+		"all segments of the focused module are highlighted")
+	module1.AssertNotClicked("only the focused module is clicked")
+
+	mode("default")
+	require.Equal(t, []string{"", "", "", "", ""}, borders(),
+		"focus removed on leaving the mode")
+	binding("click")
+	_, err = mockStdout.ReadUntil(']', 10*time.Millisecond)
+	require.Error(t, err, "no output without a focus change")
+
+	require.Panics(t, func() { EnableKeyboard("other") },
+		"enabling keyboard on a running bar")
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build debuglog
+
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The socket for the native journal protocol, overridden in tests.
+var journalSocket = "/run/systemd/journal/socket"
+
+// Syslog priorities used for log statements.
+const (
+	priorityInfo  = 6
+	priorityDebug = 7
+)
+
+// JournalHandler returns a handler that sends log statements to the systemd
+// journal, with the calling module and source location as fields, allowing
+// e.g. `journalctl BARISTA_MODULE=mod:cpuload.Module.worker`. Fine log
+// statements use the debug priority. Use it with SetHandler.
+func JournalHandler() (func(Record), error) {
+	conn, err := net.DialUnix("unixgram", nil,
+		&net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	identifier := filepath.Base(os.Args[0])
+	return func(r Record) {
+		priority := priorityInfo
+		if r.Fine {
+			priority = priorityDebug
+		}
+		var buf bytes.Buffer
+		writeJournalField(&buf, "MESSAGE", r.Message)
+		writeJournalField(&buf, "PRIORITY", strconv.Itoa(priority))
+		writeJournalField(&buf, "SYSLOG_IDENTIFIER", identifier)
+		writeJournalField(&buf, "BARISTA_MODULE", r.Module)
+		writeJournalField(&buf, "CODE_FILE", r.File)
+		writeJournalField(&buf, "CODE_LINE", strconv.Itoa(r.Line))
+		// There is nowhere to report errors from logging.
+		conn.Write(buf.Bytes())
+	}, nil
+}
+
+// writeJournalField writes a field in the journal's native format, which
+// requires an explicit length for values that contain newlines.
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(key + "=" + value + "\n")
+		return
+	}
+	buf.WriteString(key + "\n")
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
@@ -26,6 +26,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 func trimSuffix(s, suffix string) (result string, trimmed bool) {
@@ -48,6 +49,9 @@ func construct() {
 	}
 	logger = log.New(os.Stderr, "", 0)
 	SetFlags(log.LstdFlags | log.Lshortfile)
+	if mods := os.Getenv("BARISTA_FINELOG"); mods != "" {
+		fineLogModules = append(fineLogModules, strings.Split(mods, ",")...)
+	}
 	for _, arg := range os.Args {
 		if mods, ok := trimPrefix(arg, "--finelog="); ok {
 			fineLogModules = append(fineLogModules, strings.Split(mods, ",")...)
@@ -93,6 +97,20 @@ func shorten(path string) string {
 
 var fineLogModules = []string{}
 var fineLogModulesCache sync.Map
+var fineLogMu sync.RWMutex
+
+// SetFineLog replaces the modules for which fine logging is enabled, allowing
+// fine logging to be turned on or off while the bar is running. Modules are
+// given as prefixes, in the same format as the --finelog flag.
+func SetFineLog(modules ...string) {
+	fineLogMu.Lock()
+	defer fineLogMu.Unlock()
+	fineLogModules = modules
+	fineLogModulesCache.Range(func(k, v interface{}) bool {
+		fineLogModulesCache.Delete(k)
+		return true
+	})
+}
 
 // fineLogEnabled returns true if finelog is enabled for the module.
 // It caches results in a sync.Map so subsequent lookups can be faster.
@@ -101,6 +119,8 @@ func fineLogEnabled(mod string) bool {
 	if ok {
 		return cache.(bool)
 	}
+	fineLogMu.RLock()
+	defer fineLogMu.RUnlock()
 	for _, fineMod := range fineLogModules {
 		if strings.HasPrefix(mod, fineMod) {
 			fineLogModulesCache.Store(mod, true)
@@ -116,35 +136,58 @@ func fineLogEnabled(mod string) bool {
 //     - mod:$module for modules included with barista (e.g. mod:cpuinfo)
 //     - bar:$core for core barista code (e.g. bar:notifier, bar:base)
 //     - $package for all other code (e.g. github.com/user/repo/module)
-// The source location is empty if neither shortfile nor longfile flags are set,
-// otherwise it is the appropriately formatted file name, ":", and line number.
-func callingModule() (mod string, loc string) {
+// The file is relative to the go source root.
+func callingModule() (mod string, file string, line int) {
 	pc, file, line, ok := runtime.Caller(2)
-	fFlags := int(atomic.LoadInt64(&fileFlags))
-	if fFlags != 0 {
-		file, _ = trimPrefix(file, goSrcRoot)
-		if fFlags&log.Lshortfile != 0 {
-			file = filepath.Base(file)
-		}
-		loc = fmt.Sprintf("%s:%d", file, line)
-	}
+	file, _ = trimPrefix(file, goSrcRoot)
 	if !ok {
-		return "unknown", loc
+		return "unknown", file, line
 	}
 	fnName := runtime.FuncForPC(pc).Name()
-	return shorten(fnName), loc
+	return shorten(fnName), file, line
 }
 
 var fileFlags int64
 var logger *log.Logger
 
+// Record is a single log statement, as passed to a handler set using
+// SetHandler.
+type Record struct {
+	Time time.Time
+	// Module is the calling module, in the same format as --finelog,
+	// e.g. mod:cpuload.Module.worker.
+	Module string
+	// File and Line are the source location of the log statement.
+	File string
+	Line int
+	// Fine is true for statements logged using Fine.
+	Fine    bool
+	Message string
+}
+
+var handler atomic.Value // of func(Record)
+
+// SetHandler sends all log statements to the given function instead of the
+// output set using SetOutput, for structured logging (e.g. to the journal
+// using JournalHandler). Setting a nil handler restores the default output.
+func SetHandler(h func(Record)) {
+	handler.Store(h)
+}
+
 // doLog actually logs the given statement, with appropriate file information
 // depending on the currently set flags.
-func doLog(mod, loc string, format string, args ...interface{}) {
+func doLog(mod, file string, line int, fine bool, format string, args ...interface{}) {
 	out := fmt.Sprintf(format, args...)
+	if h, _ := handler.Load().(func(Record)); h != nil {
+		h(Record{time.Now(), mod, file, line, fine, out})
+		return
+	}
 	fFlags := int(atomic.LoadInt64(&fileFlags))
 	if fFlags != 0 {
-		out = fmt.Sprintf("%s (%s) %s", loc, mod, out)
+		if fFlags&log.Lshortfile != 0 {
+			file = filepath.Base(file)
+		}
+		out = fmt.Sprintf("%s:%d (%s) %s", file, line, mod, out)
 	}
 	logger.Output(3, out)
 }
@@ -163,16 +206,17 @@ func SetFlags(flags int) {
 
 // Log logs a formatted message.
 func Log(format string, args ...interface{}) {
-	mod, loc := callingModule()
-	doLog(mod, loc, format, args...)
+	mod, file, line := callingModule()
+	doLog(mod, file, line, false, format, args...)
 }
 
 // Fine logs a formatted message if fine logging is enabled for the
 // calling module. Enable fine logging using the commandline flag,
-// `--finelog=$module1,$module2`. [Requires debug logging].
+// `--finelog=$module1,$module2`, the environment variable
+// BARISTA_FINELOG=$module1,$module2, or SetFineLog. [Requires debug logging].
 func Fine(format string, args ...interface{}) {
-	mod, loc := callingModule()
+	mod, file, line := callingModule()
 	if fineLogEnabled(mod) {
-		doLog(mod, loc, format, args...)
+		doLog(mod, file, line, true, format, args...)
 	}
 }
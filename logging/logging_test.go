@@ -18,10 +18,15 @@ package logging
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"barista.run/testing/mockio"
 
@@ -36,7 +41,7 @@ func resetLoggingState() {
 
 	nodes = map[ident]node{}
 	instances = map[string]int{}
-	fineLogModules = []string{}
+	SetFineLog()
 	objectIDs = map[ident]string{}
 	labels = map[ident]string{}
 
@@ -45,6 +50,7 @@ func resetLoggingState() {
 		return true
 	})
 
+	SetHandler(nil)
 	construct()
 	mockStderr = mockio.Stdout()
 	SetFlags(0) // To make test output as deterministic as possible.
@@ -133,3 +139,93 @@ func TestFileLocations(t *testing.T) {
 	_, _, line, _ := runtime.Caller(0)
 	assertLogged(t, fmt.Sprintf("logging_test.go:%d (bar:logging.TestFileLocations) foo", line-1))
 }
+
+func TestSetFineLog(t *testing.T) {
+	originalArgs := os.Args
+	defer func() { os.Args = originalArgs }()
+	os.Args = []string{os.Args[0]}
+	os.Setenv("BARISTA_FINELOG", "bar:colors,bar:logging.TestSetFineLog")
+	resetLoggingState()
+	os.Unsetenv("BARISTA_FINELOG")
+	Fine("foo")
+	assertLogged(t, "foo")
+
+	SetFineLog("bar:colors")
+	Fine("foo")
+	require.Empty(t, mockStderr.ReadNow(), "after disabling fine log")
+
+	SetFineLog("mod:clock", "bar:logging")
+	Fine("foo")
+	assertLogged(t, "foo")
+}
+
+func TestHandler(t *testing.T) {
+	resetLoggingState()
+	SetFineLog("bar:logging.TestHandler")
+	var records []Record
+	SetHandler(func(r Record) { records = append(records, r) })
+	Log("foo: %d", 1)
+	_, file, line, _ := runtime.Caller(0)
+	Fine("bar")
+	require.Empty(t, mockStderr.ReadNow(), "with handler")
+
+	require.Len(t, records, 2)
+	require.Equal(t, "bar:logging.TestHandler", records[0].Module)
+	require.Equal(t, "foo: 1", records[0].Message)
+	require.False(t, records[0].Fine)
+	require.True(t, strings.HasSuffix(file, records[0].File),
+		"%s is the path of %s", records[0].File, file)
+	require.Equal(t, line-1, records[0].Line)
+	require.WithinDuration(t, time.Now(), records[0].Time, time.Minute)
+	require.True(t, records[1].Fine)
+	require.Equal(t, line+1, records[1].Line)
+
+	SetHandler(nil)
+	Log("baz")
+	assertLogged(t, "baz")
+}
+
+func TestJournalHandler(t *testing.T) {
+	resetLoggingState()
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(s string) { journalSocket = s }(journalSocket)
+	journalSocket = filepath.Join(dir, "socket")
+
+	_, err = JournalHandler()
+	require.Error(t, err, "without journal")
+
+	conn, err := net.ListenUnixgram("unixgram",
+		&net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+	h, err := JournalHandler()
+	require.NoError(t, err)
+	SetHandler(h)
+	defer SetHandler(nil)
+
+	read := func() string {
+		buf := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	Log("foo")
+	_, _, line, _ := runtime.Caller(0)
+	msg := read()
+	require.Contains(t, msg, "MESSAGE=foo\n")
+	require.Contains(t, msg, "PRIORITY=6\n")
+	require.Contains(t, msg, "BARISTA_MODULE=bar:logging.TestJournalHandler\n")
+	require.Contains(t, msg, fmt.Sprintf("CODE_LINE=%d\n", line-1))
+
+	SetFineLog("bar:logging")
+	Fine("multi\nline")
+	msg = read()
+	require.Contains(t, msg, "PRIORITY=7\n")
+	require.Contains(t, msg,
+		"MESSAGE\n\x0a\x00\x00\x00\x00\x00\x00\x00multi\nline\n",
+		"uses explicit length for multi-line values")
+}
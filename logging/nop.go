@@ -17,9 +17,17 @@
 // Package logging provides logging functions for use in the bar and modules.
 // It uses build tags to provide nop functions in the default case, and
 // actual logging functions when built with `-tags debuglog`.
+//
+// Each log statement is attributed to the module that logged it, which is
+// used to enable fine logging for specific modules (see Fine), and is
+// included in the structured records sent to handlers set using SetHandler,
+// e.g. for the systemd journal (JournalHandler) or log/slog (SlogHandler).
 package logging
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // SetOutput sets the output stream for logging.
 func SetOutput(output io.Writer) {}
@@ -27,12 +35,44 @@ func SetOutput(output io.Writer) {}
 // SetFlags sets flags to control logging output.
 func SetFlags(flags int) {}
 
+// Record is a single log statement, as passed to a handler set using
+// SetHandler.
+type Record struct {
+	Time time.Time
+	// Module is the calling module, in the same format as --finelog,
+	// e.g. mod:cpuload.Module.worker.
+	Module string
+	// File and Line are the source location of the log statement.
+	File string
+	Line int
+	// Fine is true for statements logged using Fine.
+	Fine    bool
+	Message string
+}
+
+// SetHandler sends all log statements to the given function instead of the
+// output set using SetOutput, for structured logging (e.g. to the journal
+// using JournalHandler). Setting a nil handler restores the default output.
+func SetHandler(h func(Record)) {}
+
+// JournalHandler returns a handler that sends log statements to the systemd
+// journal, with the calling module and source location as fields, allowing
+// e.g. `journalctl BARISTA_MODULE=mod:cpuload.Module.worker`. Fine log
+// statements use the debug priority. Use it with SetHandler.
+func JournalHandler() (func(Record), error) { return func(Record) {}, nil }
+
+// SetFineLog replaces the modules for which fine logging is enabled, allowing
+// fine logging to be turned on or off while the bar is running. Modules are
+// given as prefixes, in the same format as the --finelog flag.
+func SetFineLog(modules ...string) {}
+
 // Log logs a formatted message.
 func Log(format string, args ...interface{}) {}
 
 // Fine logs a formatted message if fine logging is enabled for the
 // calling module. Enable fine logging using the commandline flag,
-// `--finelog=$module1,$module2`. [Requires debug logging].
+// `--finelog=$module1,$module2`, the environment variable
+// BARISTA_FINELOG=$module1,$module2, or SetFineLog. [Requires debug logging].
 func Fine(format string, args ...interface{}) {}
 
 // ID returns a unique name for the given value of the form 'type'#'index'
@@ -36,4 +36,9 @@ func TestNopMethods(t *testing.T) {
 	Attach(t, 4, "->int")
 	Attachf(t, 1.0, "->float:%g", 1.0)
 	Register(t, "Fail", "FailNow")
+	SetFineLog("mod:clock")
+	h, err := JournalHandler()
+	require.NoError(t, err)
+	SetHandler(h)
+	h(Record{Message: "foo"})
 }
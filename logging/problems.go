@@ -0,0 +1,75 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Problem is a warning or an error. Recent problems are kept even without
+// debug logging, so that they can be shown on the bar (see
+// modules/diagnostics).
+type Problem struct {
+	Time time.Time
+	// Error is true for errors, and false for warnings.
+	Error bool
+	// Source is where the problem occurred, e.g. the module.
+	Source  string
+	Message string
+}
+
+// The number of problems kept.
+const problemLimit = 100
+
+var (
+	problems   []Problem
+	problemsMu sync.Mutex
+	// Closed and replaced each time a problem is recorded.
+	problemsChanged = make(chan struct{})
+)
+
+// Warn records a warning from the given source, and logs it if debug logging
+// is enabled.
+func Warn(source string, format string, args ...interface{}) {
+	record(false, source, fmt.Sprintf(format, args...))
+}
+
+// Error records an error from the given source, and logs it if debug logging
+// is enabled.
+func Error(source string, format string, args ...interface{}) {
+	record(true, source, fmt.Sprintf(format, args...))
+}
+
+func record(isError bool, source string, msg string) {
+	Log("%s: %s", source, msg)
+	problemsMu.Lock()
+	defer problemsMu.Unlock()
+	if len(problems) == problemLimit {
+		problems = append(problems[:0], problems[1:]...)
+	}
+	problems = append(problems, Problem{time.Now(), isError, source, msg})
+	close(problemsChanged)
+	problemsChanged = make(chan struct{})
+}
+
+// Problems returns the most recent problems, oldest first, and a channel that
+// is closed when another problem is recorded.
+func Problems() ([]Problem, <-chan struct{}) {
+	problemsMu.Lock()
+	defer problemsMu.Unlock()
+	return append([]Problem(nil), problems...), problemsChanged
+}
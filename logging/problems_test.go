@@ -0,0 +1,58 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblems(t *testing.T) {
+	problemsMu.Lock()
+	problems = nil
+	problemsMu.Unlock()
+
+	p, changed := Problems()
+	require.Empty(t, p)
+	Warn("mod:test", "something %s", "odd")
+	select {
+	case <-changed:
+	default:
+		require.Fail(t, "changed is closed on new problem")
+	}
+	p, changed = Problems()
+	require.Len(t, p, 1)
+	require.Equal(t, "mod:test", p[0].Source)
+	require.Equal(t, "something odd", p[0].Message)
+	require.False(t, p[0].Error)
+	require.WithinDuration(t, time.Now(), p[0].Time, time.Minute)
+
+	Error("mod:other", "failed")
+	<-changed
+	p, _ = Problems()
+	require.Len(t, p, 2)
+	require.True(t, p[1].Error)
+
+	for i := 0; i < problemLimit; i++ {
+		Error("mod:test", "%d", i)
+	}
+	p, _ = Problems()
+	require.Len(t, p, problemLimit, "only recent problems are kept")
+	require.Equal(t, "0", p[0].Message)
+	require.Equal(t, fmt.Sprintf("%d", problemLimit-1), p[problemLimit-1].Message)
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build debuglog,go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler returns a handler that sends log statements to an slog.Handler,
+// with the calling module and source location as the attributes "module",
+// "file", and "line". Fine log statements use slog.LevelDebug. Use it with
+// SetHandler. [Requires Go 1.21].
+func SlogHandler(h slog.Handler) func(Record) {
+	return func(r Record) {
+		level := slog.LevelInfo
+		if r.Fine {
+			level = slog.LevelDebug
+		}
+		ctx := context.Background()
+		if !h.Enabled(ctx, level) {
+			return
+		}
+		rec := slog.NewRecord(r.Time, level, r.Message, 0)
+		rec.AddAttrs(
+			slog.String("module", r.Module),
+			slog.String("file", r.File),
+			slog.Int("line", r.Line),
+		)
+		h.Handle(ctx, rec)
+	}
+}
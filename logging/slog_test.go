@@ -0,0 +1,47 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build debuglog,go1.21
+
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogHandler(t *testing.T) {
+	resetLoggingState()
+	var buf bytes.Buffer
+	SetHandler(SlogHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "file" || a.Key == "line" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	defer SetHandler(nil)
+
+	SetFineLog("bar:logging")
+	Log("foo")
+	Fine("bar")
+	require.Equal(t,
+		"level=INFO msg=foo module=bar:logging.TestSlogHandler\n",
+		buf.String(), "debug level is disabled by default")
+	require.Empty(t, mockStderr.ReadNow())
+}
@@ -17,14 +17,20 @@ package battery // import "barista.run/modules/battery"
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"math"
+	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"barista.run/bar"
 	"barista.run/base/value"
+	"barista.run/base/watchers/sysfs"
+	"barista.run/doctor"
+	"barista.run/i18n"
 	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -51,8 +57,18 @@ const (
 	Unknown Status = ""
 )
 
+// Text returns the status for display, in the current language (see i18n).
+func (s Status) Text() string {
+	if s == Unknown {
+		return ""
+	}
+	return i18n.Sprintf(string(s))
+}
+
 // Info represents the current battery information.
 type Info struct {
+	// Name of the battery, e.g. "BAT0". Empty for aggregated information.
+	Name string
 	// Capacity in *percents*, from 0 to 100.
 	Capacity int
 	// Energy when the battery is full, in Wh.
@@ -69,6 +85,15 @@ type Info struct {
 	Status Status
 	// Technology of the battery, e.g. "Li-Ion", "Li-Poly", "Ni-MH".
 	Technology string
+	// ChargeStartThreshold is the capacity (in percent) below which the
+	// battery starts charging, or 0 if not supported.
+	ChargeStartThreshold int
+	// ChargeEndThreshold is the capacity (in percent) at which the battery
+	// stops charging, or 0 if not supported.
+	ChargeEndThreshold int
+	// Batteries contains the information for each individual battery when
+	// aggregating multiple batteries, ordered by name.
+	Batteries []Info
 }
 
 // Remaining returns the fraction of battery capacity remaining.
@@ -122,21 +147,55 @@ func (i Info) SignedPower() float64 {
 	return i.Power
 }
 
+// ChargeLimited returns true if the charge end threshold caps charging below
+// full capacity.
+func (i Info) ChargeLimited() bool {
+	return i.ChargeEndThreshold > 0 && i.ChargeEndThreshold < 100
+}
+
+// HeldAtThreshold returns true if the battery is plugged in but intentionally
+// not charging because of the charge thresholds.
+func (i Info) HeldAtThreshold() bool {
+	return i.ChargeLimited() && (i.Status == NotCharging || i.Status == Full)
+}
+
+// ChargeProfile represents a pair of charge thresholds.
+type ChargeProfile struct {
+	Start, End int
+}
+
+var (
+	// FullCharge is a charge profile that always charges the battery to full
+	// capacity.
+	FullCharge = ChargeProfile{Start: 0, End: 100}
+	// Longevity is a charge profile that keeps the battery between 75% and
+	// 80%, which reduces wear when the laptop is mostly plugged in.
+	Longevity = ChargeProfile{Start: 75, End: 80}
+)
+
 // Module represents a battery bar module. It supports setting the output
 // format, click handler, update frequency, and urgency/colour functions.
 type Module struct {
 	updateFunc func() Info
+	names      func() []string // of the batteries shown by the module.
 	scheduler  *timing.Scheduler
 	outputFunc value.Value // of func(Info) bar.Output
 }
 
-func newModule(updateFunc func() Info) *Module {
+func newModule(updateFunc func() Info, names func() []string) *Module {
 	m := &Module{
 		updateFunc: updateFunc,
+		names:      names,
 		scheduler:  timing.NewScheduler(),
 	}
 	l.Register(m, "scheduler", "format")
-	m.RefreshInterval(3 * time.Second)
+	// Battery changes such as plugging in the charger are picked up from
+	// uevents, so only gradual changes like the capacity need polling.
+	if sysfs.Available() {
+		m.RefreshInterval(30 * time.Second)
+	} else {
+		m.RefreshInterval(3 * time.Second)
+	}
 	// Construct a simple template that's just the available battery percent.
 	m.Output(func(i Info) bar.Output {
 		return outputs.Textf("BATT %d%%", i.RemainingPct())
@@ -146,14 +205,27 @@ func newModule(updateFunc func() Info) *Module {
 
 // Named constructs an instance of the battery module for the given battery name.
 func Named(name string) *Module {
-	m := newModule(func() Info { return batteryInfo(name) })
+	m := newModule(
+		func() Info { return batteryInfo(name) },
+		func() []string { return []string{name} })
 	l.Label(m, name)
+	doctor.Register("Battery "+name, func() error {
+		_, err := fs.Stat(fmt.Sprintf("/sys/class/power_supply/%s/uevent", name))
+		return doctor.Fix(err, "Use a battery name from /sys/class/power_supply")
+	})
 	return m
 }
 
 // All constructs a battery module that aggregates all detected batteries.
+// Capacities and energy are summed across batteries, and the power draw is
+// smoothed over successive readings to keep the remaining time estimate
+// stable, even as the load shifts between batteries. Details for each battery
+// are available in the Batteries field.
 func All() *Module {
-	return newModule(allBatteriesInfo)
+	smooth := powerSmoother()
+	return newModule(
+		func() Info { return smooth(allBatteriesInfo()) },
+		allBatteryNames)
 }
 
 // Output configures a module to display the output of a user-defined function.
@@ -162,23 +234,63 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 	return m
 }
 
-// RefreshInterval configures the polling frequency for battery info.
+// RefreshInterval configures the polling frequency for battery info. Batteries
+// are also updated whenever the kernel reports a change to a power supply.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
 	m.scheduler.Every(interval)
 	return m
 }
 
+// SetChargeProfile sets the charge thresholds of the battery (or all
+// batteries, for All). Since this requires root, the thresholds are written
+// using pkexec, which prompts for authentication via polkit.
+func (m *Module) SetChargeProfile(p ChargeProfile) error {
+	var scripts []string
+	for _, name := range m.names() {
+		if script := thresholdScript(name, p.Start, p.End); script != "" {
+			scripts = append(scripts, script)
+		}
+	}
+	if len(scripts) == 0 {
+		return errors.New("battery: charge thresholds not supported")
+	}
+	// Change thresholds for all batteries together, to avoid repeated
+	// authentication prompts.
+	return runPrivileged(strings.Join(scripts, " && "))
+}
+
+// ToggleChargeProfile switches between the FullCharge and Longevity profiles,
+// logging any errors. It is intended for use as a click handler.
+func (m *Module) ToggleChargeProfile() {
+	p := Longevity
+	for _, name := range m.names() {
+		if end := readThreshold(name, "end"); end > 0 {
+			if end < 100 {
+				p = FullCharge
+			}
+			break
+		}
+	}
+	if err := m.SetChargeProfile(p); err != nil {
+		l.Log("Failed to set charge profile: %s", err)
+	}
+}
+
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	info := m.updateFunc()
 	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
+	events := sysfs.Subscribe("power_supply")
+	defer events.Unsubscribe()
 	for {
 		s.Output(outputFunc(info))
 		select {
 		case <-m.scheduler.C:
 			info = m.updateFunc()
+		case <-events.Updates:
+			info = m.updateFunc()
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
 		}
@@ -241,13 +353,13 @@ func batteryInfo(name string) Info {
 	f, err := fs.Open(batteryPath)
 	if err != nil {
 		l.Log("Failed to read stats for %s: %s", name, err)
-		return Info{Status: Disconnected}
+		return Info{Name: name, Status: Disconnected}
 	}
 	defer f.Close()
 	s := bufio.NewScanner(f)
 	s.Split(bufio.ScanLines)
 
-	var info Info
+	info := Info{Name: name}
 	var energyNow, powerNow, energyFull, energyMax electricValue
 	for s.Scan() {
 		line := strings.TrimSpace(s.Text())
@@ -291,9 +403,52 @@ func batteryInfo(name string) Info {
 	info.EnergyMax = energyMax.toWatts(info.Voltage)
 	info.EnergyFull = energyFull.toWatts(info.Voltage)
 	info.Power = powerNow.toWatts(info.Voltage)
+	info.ChargeStartThreshold = readThreshold(info.Name, "start")
+	info.ChargeEndThreshold = readThreshold(info.Name, "end")
 	return info
 }
 
+func thresholdFile(name, threshold string) string {
+	return fmt.Sprintf("/sys/class/power_supply/%s/charge_control_%s_threshold",
+		name, threshold)
+}
+
+func readThreshold(name, threshold string) int {
+	val, err := afero.ReadFile(fs, thresholdFile(name, threshold))
+	if err != nil {
+		return 0
+	}
+	t, _ := strconv.Atoi(strings.TrimSpace(string(val)))
+	return t
+}
+
+// thresholdScript builds a shell script to change the charge thresholds of
+// the named battery, or returns "" if the battery does not support them.
+func thresholdScript(name string, start, end int) string {
+	oldEnd := readThreshold(name, "end")
+	if oldEnd == 0 {
+		return ""
+	}
+	endCmd := fmt.Sprintf("echo %d > %s", end, thresholdFile(name, "end"))
+	// Not all drivers support a start threshold.
+	if _, err := fs.Stat(thresholdFile(name, "start")); err != nil {
+		return endCmd
+	}
+	startCmd := fmt.Sprintf("echo %d > %s", start, thresholdFile(name, "start"))
+	// Drivers reject a start threshold above the end threshold, so when
+	// lowering the thresholds the start threshold must be written first.
+	if end < oldEnd {
+		return startCmd + " && " + endCmd
+	}
+	return endCmd + " && " + startCmd
+}
+
+// runPrivileged runs a shell script as root using polkit.
+// It can be replaced in tests.
+var runPrivileged = func(script string) error {
+	return exec.Command("pkexec", "sh", "-c", script).Run()
+}
+
 func allBatteriesInfo() Info {
 	dir, err := fs.Open("/sys/class/power_supply")
 	if err != nil {
@@ -305,6 +460,7 @@ func allBatteriesInfo() Info {
 		l.Log("Failed to list batteries: %s", err)
 		return Info{Status: Unknown}
 	}
+	sort.Strings(batts)
 	var infos []Info
 	for _, batt := range batts {
 		if !strings.HasPrefix(batt, "BAT") {
@@ -349,5 +505,54 @@ func allBatteriesInfo() Info {
 	allInfo.Voltage = voltEnergySum / allInfo.EnergyNow
 	allInfo.Capacity = int(allInfo.EnergyNow * 100.0 / allInfo.EnergyFull)
 	allInfo.Technology = strings.Join(techs, ",")
+	allInfo.Batteries = infos
+	// Report the thresholds of the first battery that supports them.
+	for _, info := range infos {
+		if info.ChargeEndThreshold > 0 {
+			allInfo.ChargeStartThreshold = info.ChargeStartThreshold
+			allInfo.ChargeEndThreshold = info.ChargeEndThreshold
+			break
+		}
+	}
 	return allInfo
 }
+
+// allBatteryNames returns the names of all batteries, ordered by name.
+func allBatteryNames() []string {
+	dir, err := fs.Open("/sys/class/power_supply")
+	if err != nil {
+		return nil
+	}
+	defer dir.Close()
+	batts, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil
+	}
+	sort.Strings(batts)
+	var names []string
+	for _, batt := range batts {
+		if strings.HasPrefix(batt, "BAT") {
+			names = append(names, batt)
+		}
+	}
+	return names
+}
+
+// powerSmoothing is the weight given to the latest power reading when
+// computing the exponential moving average of the power draw.
+const powerSmoothing = 0.25
+
+// powerSmoother returns a function that replaces the instantaneous power draw
+// with a moving average. The average is reset whenever the status changes,
+// since the power draw when charging is unrelated to that when discharging.
+func powerSmoother() func(Info) Info {
+	var lastStatus Status
+	var lastPower float64
+	return func(i Info) Info {
+		if i.Status == lastStatus && lastPower > 0 && i.Power > 0 {
+			i.Power = lastPower + powerSmoothing*(i.Power-lastPower)
+		}
+		lastStatus, lastPower = i.Status, i.Power
+		return i
+	}
+}
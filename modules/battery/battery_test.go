@@ -16,11 +16,16 @@ package battery
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/watchers/sysfs"
+	"barista.run/doctor"
+	"barista.run/i18n"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 
@@ -89,6 +94,32 @@ func TestUnknownAndMissingStatus(t *testing.T) {
 	require.Equal(Unknown, info.Status)
 }
 
+func TestDoctor(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	write(battery{"NAME": "BAT0", "STATUS": "Full"})
+	Named("BAT0")
+	Named("BAT9")
+
+	errs := map[string]error{}
+	for _, r := range doctor.Run() {
+		errs[r.Name] = r.Err
+	}
+	require.Contains(t, errs, "Battery BAT0")
+	require.NoError(t, errs["Battery BAT0"])
+	require.Error(t, errs["Battery BAT9"])
+	require.Contains(t, doctor.FixOf(errs["Battery BAT9"]), "/sys/class/power_supply")
+}
+
+func TestStatusText(t *testing.T) {
+	defer i18n.SetLanguage("en")
+	i18n.SetLanguage("en")
+	require.Equal(t, "Not charging", NotCharging.Text())
+	require.Equal(t, "", Unknown.Text())
+	i18n.SetLanguage("de")
+	require.Equal(t, "Voll", Full.Text())
+	require.Equal(t, "Lädt", Charging.Text())
+}
+
 func TestGarbageFiles(t *testing.T) {
 	require := require.New(t)
 	fs = afero.NewMemMapFs()
@@ -336,3 +367,163 @@ func TestCombined(t *testing.T) {
 	testBar.NextOutput().AssertText([]string{
 		"Discharging - 50/5h0m0s"})
 }
+
+func TestAllDetailsAndSmoothing(t *testing.T) {
+	require := require.New(t)
+	fs = afero.NewMemMapFs()
+
+	internal := battery{
+		"NAME":        "BAT0",
+		"STATUS":      "Discharging",
+		"VOLTAGE_NOW": 12 * micros,
+		"POWER_NOW":   8 * micros,
+		"ENERGY_FULL": 24 * micros,
+		"ENERGY_NOW":  12 * micros,
+	}
+	external := battery{
+		"NAME":        "BAT1",
+		"STATUS":      "Unknown",
+		"VOLTAGE_NOW": 12 * micros,
+		"POWER_NOW":   0,
+		"ENERGY_FULL": 72 * micros,
+		"ENERGY_NOW":  36 * micros,
+	}
+	write(external)
+	write(internal)
+
+	testBar.New(t)
+	testBar.Run(All().Output(func(i Info) bar.Output {
+		var details []string
+		for _, b := range i.Batteries {
+			details = append(details, fmt.Sprintf("%s:%d", b.Name, b.RemainingPct()))
+		}
+		return outputs.Textf("%d%% %v %v", i.RemainingPct(), i.RemainingTime(), details)
+	}))
+
+	// 48Wh remaining at 8W.
+	testBar.NextOutput().AssertText([]string{
+		"50% 6h0m0s [BAT0:50 BAT1:50]"}, "on start")
+
+	// The load moves to the external battery, with a spike in power draw.
+	internal["STATUS"] = "Unknown"
+	internal["POWER_NOW"] = 0
+	external["STATUS"] = "Discharging"
+	external["POWER_NOW"] = 16 * micros
+	write(internal)
+	write(external)
+	testBar.Tick()
+
+	// Smoothed power: 8W + 0.25 * (16W - 8W) = 10W, so 48Wh lasts 4h48m.
+	testBar.NextOutput().AssertText([]string{
+		"50% 4h48m0s [BAT0:50 BAT1:50]"}, "power is smoothed")
+
+	internal["STATUS"] = "Charging"
+	internal["POWER_NOW"] = 12 * micros
+	external["STATUS"] = "Unknown"
+	external["POWER_NOW"] = 0
+	write(internal)
+	write(external)
+	testBar.Tick()
+
+	// 48Wh to charge at 12W, with no smoothing across the status change.
+	testBar.NextOutput().AssertText([]string{
+		"50% 4h0m0s [BAT0:50 BAT1:50]"}, "smoothing reset on status change")
+
+	info := batteryInfo("BAT1")
+	require.Equal("BAT1", info.Name)
+	require.Empty(info.Batteries)
+}
+
+func writeThreshold(name, threshold string, value int) {
+	afero.WriteFile(fs, thresholdFile(name, threshold),
+		[]byte(fmt.Sprintf("%d\n", value)), 0644)
+}
+
+func TestChargeThresholds(t *testing.T) {
+	require := require.New(t)
+	fs = afero.NewMemMapFs()
+	var scripts []string
+	runPrivileged = func(script string) error {
+		scripts = append(scripts, script)
+		return nil
+	}
+
+	bat0 := Named("BAT0")
+	write(battery{"NAME": "BAT0", "STATUS": "Not charging"})
+	info := batteryInfo("BAT0")
+	require.Equal(0, info.ChargeEndThreshold)
+	require.False(info.ChargeLimited())
+	require.False(info.HeldAtThreshold())
+	require.Error(bat0.SetChargeProfile(Longevity), "thresholds not supported")
+	bat0.ToggleChargeProfile()
+	require.Empty(scripts)
+
+	writeThreshold("BAT0", "end", 100)
+	info = batteryInfo("BAT0")
+	require.Equal(100, info.ChargeEndThreshold)
+	require.False(info.ChargeLimited(), "end threshold of 100%")
+	bat0.ToggleChargeProfile()
+	require.Equal([]string{
+		"echo 80 > /sys/class/power_supply/BAT0/charge_control_end_threshold",
+	}, scripts, "without start threshold")
+
+	scripts = nil
+	writeThreshold("BAT0", "start", 75)
+	writeThreshold("BAT0", "end", 80)
+	info = batteryInfo("BAT0")
+	require.Equal(75, info.ChargeStartThreshold)
+	require.Equal(80, info.ChargeEndThreshold)
+	require.True(info.ChargeLimited())
+	require.True(info.HeldAtThreshold())
+	bat0.ToggleChargeProfile()
+	require.Equal([]string{
+		"echo 100 > /sys/class/power_supply/BAT0/charge_control_end_threshold && " +
+			"echo 0 > /sys/class/power_supply/BAT0/charge_control_start_threshold",
+	}, scripts, "raising thresholds writes end threshold first")
+
+	scripts = nil
+	writeThreshold("BAT0", "start", 0)
+	writeThreshold("BAT0", "end", 100)
+	write(battery{"NAME": "BAT0", "STATUS": "Charging"})
+	write(battery{"NAME": "BAT1", "STATUS": "Charging"})
+	writeThreshold("BAT1", "start", 0)
+	writeThreshold("BAT1", "end", 100)
+	info = allBatteriesInfo()
+	require.Equal(100, info.ChargeEndThreshold)
+	require.False(info.HeldAtThreshold())
+	all := All()
+	require.NoError(all.SetChargeProfile(ChargeProfile{Start: 40, End: 60}))
+	require.Equal([]string{
+		"echo 40 > /sys/class/power_supply/BAT0/charge_control_start_threshold && " +
+			"echo 60 > /sys/class/power_supply/BAT0/charge_control_end_threshold && " +
+			"echo 40 > /sys/class/power_supply/BAT1/charge_control_start_threshold && " +
+			"echo 60 > /sys/class/power_supply/BAT1/charge_control_end_threshold",
+	}, scripts, "all batteries changed together")
+
+	runPrivileged = func(string) error { return errors.New("dismissed") }
+	require.Error(all.SetChargeProfile(FullCharge))
+	require.NotPanics(func() { all.ToggleChargeProfile() })
+
+	require.True(reflect.DeepEqual(batteryInfo("BAT0"), batteryInfo("BAT0")),
+		"Info is plain data")
+}
+
+func TestUevents(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	uevents := sysfs.TestMode()
+	testBar.New(t)
+	write(battery{"NAME": "BAT0", "STATUS": "Discharging", "CAPACITY": 40})
+
+	bat := Named("BAT0").Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %d", i.Status, i.Capacity)
+	})
+	testBar.Run(bat)
+	testBar.NextOutput().AssertText([]string{"Discharging 40"})
+
+	write(battery{"NAME": "BAT0", "STATUS": "Charging", "CAPACITY": 40})
+	uevents.Uevent("change", "backlight", "intel_backlight")
+	testBar.AssertNoOutput("on unrelated uevent")
+	uevents.Uevent("change", "power_supply", "AC")
+	testBar.NextOutput("on power supply uevent").
+		AssertText([]string{"Charging 40"})
+}
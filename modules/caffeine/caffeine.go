@@ -0,0 +1,221 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package caffeine provides an i3bar module that toggles an idle inhibitor,
+preventing the screen from locking and the system from suspending.
+
+By default, the inhibitor is a systemd-logind inhibitor lock. Under sway, the
+Wayland idle-inhibit protocol can be used in addition:
+
+	caffeine.New(caffeine.Logind(), caffeine.Wayland())
+
+The inhibitor can optionally be released automatically after a fixed duration.
+*/
+package caffeine // import "barista.run/modules/caffeine"
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info represents the current state of the idle inhibitor.
+type Info struct {
+	// Active is true if idle is currently being inhibited.
+	Active bool
+	// Until is the time at which the inhibitor will be released
+	// automatically, or zero if it will be held until deactivated.
+	Until  time.Time
+	module *Module
+}
+
+// Remaining returns the time remaining until the inhibitor is released
+// automatically, or zero if the inhibitor is inactive or held indefinitely.
+func (i Info) Remaining() time.Duration {
+	if !i.Active || i.Until.IsZero() {
+		return 0
+	}
+	return i.Until.Sub(timing.Now())
+}
+
+// Activate starts inhibiting idle, for the module's configured duration.
+func (i Info) Activate() {
+	i.module.activate(i.module.duration.Get().(time.Duration))
+}
+
+// ActivateFor starts inhibiting idle for the given duration, replacing any
+// existing duration.
+func (i Info) ActivateFor(duration time.Duration) {
+	i.module.activate(duration)
+}
+
+// Deactivate stops inhibiting idle.
+func (i Info) Deactivate() {
+	i.module.deactivate()
+}
+
+// Toggle switches the inhibitor on or off.
+func (i Info) Toggle() {
+	if i.Active {
+		i.Deactivate()
+	} else {
+		i.Activate()
+	}
+}
+
+// Module represents a caffeine bar module.
+type Module struct {
+	inhibitors []Inhibitor
+	duration   value.Value      // of time.Duration
+	state      value.ErrorValue // of Info
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+
+	mu       sync.Mutex
+	releases []func()
+}
+
+// New creates a caffeine module that uses the given inhibitors. If none are
+// given, a systemd-logind inhibitor lock is used.
+func New(inhibitors ...Inhibitor) *Module {
+	if len(inhibitors) == 0 {
+		inhibitors = []Inhibitor{Logind()}
+	}
+	m := &Module{
+		inhibitors: inhibitors,
+		scheduler:  timing.NewScheduler(),
+	}
+	l.Register(m, "outputFunc", "duration", "state", "scheduler")
+	m.duration.Set(time.Duration(0))
+	m.state.Set(Info{})
+	// Default output shows whether idle is inhibited, and for how long.
+	m.Output(func(i Info) bar.Output {
+		switch {
+		case !i.Active:
+			return outputs.Text("caffeine off")
+		case i.Until.IsZero():
+			return outputs.Text("caffeine on")
+		default:
+			return outputs.Textf("caffeine until %s", i.Until.Format("15:04"))
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Duration configures the module to automatically release the inhibitor
+// after the given duration. A zero duration (the default) holds the inhibitor
+// until it is deactivated.
+func (m *Module) Duration(duration time.Duration) *Module {
+	m.duration.Set(duration)
+	return m
+}
+
+func (m *Module) activate(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.releaseLocked()
+	for _, inh := range m.inhibitors {
+		release, err := inh.Inhibit()
+		if err != nil {
+			m.releaseLocked()
+			m.state.Error(err)
+			return
+		}
+		m.releases = append(m.releases, release)
+	}
+	i := Info{Active: true}
+	if duration > 0 {
+		i.Until = timing.Now().Add(duration)
+		m.scheduler.At(i.Until)
+	}
+	m.state.Set(i)
+}
+
+func (m *Module) deactivate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.releaseLocked()
+	m.state.Set(Info{})
+}
+
+// expire releases the inhibitor if its duration has elapsed. The check guards
+// against a stale trigger after the inhibitor was re-activated.
+func (m *Module) expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.state.Get()
+	if err != nil {
+		return
+	}
+	until := i.(Info).Until
+	if until.IsZero() || timing.Now().Before(until) {
+		return
+	}
+	m.releaseLocked()
+	m.state.Set(Info{})
+}
+
+func (m *Module) releaseLocked() {
+	m.scheduler.Stop()
+	for _, release := range m.releases {
+		release()
+	}
+	m.releases = nil
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	if _, err := m.state.Get(); err != nil {
+		// Clear any error from a previous run, e.g. when restarted by a
+		// click on the error segment.
+		m.state.Set(Info{})
+	}
+	i, err := m.state.Get()
+	nextState, done := m.state.Subscribe()
+	defer done()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	for {
+		if s.Error(err) {
+			return
+		}
+		info := i.(Info)
+		info.module = m
+		s.Output(outputs.Group(outputFunc(info)).OnClick(click.Left(info.Toggle)))
+		select {
+		case <-nextState:
+			i, err = m.state.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			m.expire()
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caffeine
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	godbus "github.com/godbus/dbus"
+	"github.com/stretchr/testify/require"
+)
+
+type testInhibitor struct {
+	sync.Mutex
+	held int
+	err  error
+}
+
+func (t *testInhibitor) Inhibit() (func(), error) {
+	t.Lock()
+	defer t.Unlock()
+	if t.err != nil {
+		return nil, t.err
+	}
+	t.held++
+	return func() {
+		t.Lock()
+		defer t.Unlock()
+		t.held--
+	}, nil
+}
+
+func (t *testInhibitor) count() int {
+	t.Lock()
+	defer t.Unlock()
+	return t.held
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	inh1, inh2 := &testInhibitor{}, &testInhibitor{}
+	m := New(inh1, inh2)
+	testBar.Run(m)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"caffeine off"})
+	require.Equal(t, 0, inh1.count())
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"caffeine on"})
+	require.Equal(t, 1, inh1.count())
+	require.Equal(t, 1, inh2.count())
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on second click")
+	out.AssertText([]string{"caffeine off"})
+	require.Equal(t, 0, inh1.count())
+	require.Equal(t, 0, inh2.count())
+
+	m.Duration(time.Hour).Output(func(i Info) bar.Output {
+		if !i.Active {
+			return outputs.Text("off")
+		}
+		return outputs.Textf("%v", i.Remaining())
+	})
+	out = testBar.NextOutput("on output change")
+	out.AssertText([]string{"off"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{"1h0m0s"})
+	require.Equal(t, 1, inh1.count())
+
+	timing.NextTick()
+	testBar.NextOutput("on expiry").AssertText([]string{"off"})
+	require.Equal(t, 0, inh1.count(), "released after duration")
+
+	inh2.Lock()
+	inh2.err = errors.New("foo")
+	inh2.Unlock()
+	testBar.LatestOutput().At(0).LeftClick()
+	errs := testBar.NextOutput("on inhibit error").AssertError()
+	require.Equal(t, []string{"foo"}, errs)
+	require.Equal(t, 0, inh1.count(), "partial inhibitors released")
+
+	inh2.Lock()
+	inh2.err = nil
+	inh2.Unlock()
+	out = testBar.NextOutput("with restart click handler")
+	out.At(0).LeftClick()
+	testBar.NextOutput().Expect("on restart, clears error segment")
+	testBar.NextOutput("on restart").AssertText([]string{"off"})
+}
+
+func TestActivateFor(t *testing.T) {
+	testBar.New(t)
+	inh := &testInhibitor{}
+	var info Info
+	m := New(inh).Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%v", i.Active)
+	})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"false"})
+
+	info.ActivateFor(30 * time.Minute)
+	testBar.NextOutput().AssertText([]string{"true"})
+	require.Equal(t, timing.Now().Add(30*time.Minute), info.Until)
+
+	info.ActivateFor(2 * time.Hour)
+	testBar.NextOutput().AssertText([]string{"true"})
+	require.Equal(t, 1, inh.count(), "re-activation replaces inhibitor")
+
+	timing.AdvanceBy(time.Hour)
+	testBar.AssertNoOutput("before new expiry")
+	require.Equal(t, time.Hour, info.Remaining())
+
+	info.Deactivate()
+	testBar.NextOutput().AssertText([]string{"false"})
+	require.Equal(t, time.Duration(0), info.Remaining())
+	require.Equal(t, 0, inh.count())
+}
+
+func TestLogind(t *testing.T) {
+	busType = dbus.Test
+	bus := dbus.SetupTestBus()
+	login := bus.RegisterService("org.freedesktop.login1")
+	mgr := login.Object("/org/freedesktop/login1", "org.freedesktop.login1.Manager")
+
+	var args []interface{}
+	mgr.On("Inhibit", func(a ...interface{}) ([]interface{}, error) {
+		args = a
+		return []interface{}{godbus.UnixFD(42)}, nil
+	})
+	var closed []int
+	closeFd = func(fd int) error {
+		closed = append(closed, fd)
+		return nil
+	}
+
+	release, err := Logind().Inhibit()
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"idle:sleep", "barista", "User requested", "block"}, args)
+	require.Empty(t, closed)
+	release()
+	require.Equal(t, []int{42}, closed)
+
+	_, err = LogindIdle().Inhibit()
+	require.NoError(t, err)
+	require.Equal(t, "idle", args[0])
+
+	mgr.On("Inhibit", func(a ...interface{}) ([]interface{}, error) {
+		return []interface{}{"not-an-fd"}, nil
+	})
+	_, err = Logind().Inhibit()
+	require.Error(t, err, "unexpected response")
+
+	mgr.On("Inhibit", func(a ...interface{}) ([]interface{}, error) {
+		return nil, errors.New("access denied")
+	})
+	_, err = Logind().Inhibit()
+	require.Error(t, err)
+}
+
+func TestCommand(t *testing.T) {
+	release, err := Command("sleep", "60").Inhibit()
+	require.NoError(t, err)
+	release()
+
+	_, err = Command("/nonexistent/inhibitor").Inhibit()
+	require.Error(t, err)
+	require.Equal(t, command{name: "wlinhibit"}, Wayland())
+}
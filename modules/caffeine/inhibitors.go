@@ -0,0 +1,108 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caffeine
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+
+	"barista.run/base/watchers/dbus"
+
+	godbus "github.com/godbus/dbus"
+)
+
+// Inhibitor is an interface for mechanisms that prevent the system from
+// going idle.
+type Inhibitor interface {
+	// Inhibit prevents the system from going idle until the returned release
+	// function is called.
+	Inhibit() (release func(), err error)
+}
+
+type logind struct {
+	what string
+}
+
+// Logind returns an inhibitor that takes a blocking inhibitor lock from
+// systemd-logind, preventing both the idle action (e.g. locking the screen)
+// and suspend.
+func Logind() Inhibitor {
+	return logind{"idle:sleep"}
+}
+
+// LogindIdle returns an inhibitor that takes a blocking inhibitor lock from
+// systemd-logind that only prevents the idle action, but still permits the
+// system to be suspended.
+func LogindIdle() Inhibitor {
+	return logind{"idle"}
+}
+
+var busType = dbus.System
+
+// closeFd closes the file descriptor for a logind inhibitor lock, which
+// releases the lock. It can be replaced in tests.
+var closeFd = syscall.Close
+
+func (l logind) Inhibit() (func(), error) {
+	w := dbus.WatchProperties(busType,
+		"org.freedesktop.login1",
+		"/org/freedesktop/login1",
+		"org.freedesktop.login1.Manager")
+	defer w.Unsubscribe()
+	res, err := w.Call("Inhibit", l.what, "barista", "User requested", "block")
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 1 {
+		return nil, fmt.Errorf("logind: unexpected response %v", res)
+	}
+	fd, ok := res[0].(godbus.UnixFD)
+	if !ok {
+		return nil, fmt.Errorf("logind: unexpected response %v", res)
+	}
+	// The lock is held for as long as the file descriptor is open.
+	return func() { closeFd(int(fd)) }, nil
+}
+
+type command struct {
+	name string
+	args []string
+}
+
+// Command returns an inhibitor that keeps the given command running for as
+// long as idle is inhibited, and terminates it on release. This can be used
+// with any tool that inhibits idle for its lifetime.
+func Command(name string, args ...string) Inhibitor {
+	return command{name, args}
+}
+
+// Wayland returns an inhibitor that uses the Wayland idle-inhibit protocol,
+// which is respected by sway and swayidle. Since the protocol requires a
+// surface, this uses the wlinhibit tool, which must be installed separately.
+func Wayland() Inhibitor {
+	return Command("wlinhibit")
+}
+
+func (c command) Inhibit() (func(), error) {
+	cmd := exec.Command(c.name, c.args...)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package caldav provides calendar events from a CalDAV (RFC 4791) server, such as
+Nextcloud, Radicale, Fastmail, or iCloud.
+
+Recurring events are expanded by the server, so servers that do not support
+expansion in calendar queries will only report the first occurrence.
+*/
+package caldav // import "barista.run/modules/calendar/caldav"
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/calendar"
+)
+
+// Provider provides events from a calendar collection on a CalDAV server.
+type Provider struct {
+	url      string
+	username string
+	password string
+}
+
+// New creates a provider for the calendar collection at the given URL,
+// e.g. "https://cloud.example.com/remote.php/dav/calendars/me/personal/",
+// authenticating with the given username and (app) password.
+func New(url, username, password string) *Provider {
+	return &Provider{url, username, password}
+}
+
+var client = httpclient.Client()
+
+const timeFormat = "20060102T150405Z"
+
+const query = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <C:calendar-data>
+      <C:expand start="%[1]s" end="%[2]s"/>
+    </C:calendar-data>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%[1]s" end="%[2]s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+
+type multistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			CalendarData string `xml:"prop>calendar-data"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// Events implements calendar.Provider.
+func (p *Provider) Events(from, to time.Time) ([]calendar.Event, error) {
+	body := fmt.Sprintf(query, from.UTC().Format(timeFormat), to.UTC().Format(timeFormat))
+	req, err := http.NewRequest("REPORT", p.url, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	req.SetBasicAuth(p.username, p.password)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("caldav: %s", res.Status)
+	}
+	var ms multistatus
+	if err := xml.NewDecoder(res.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+	events := []calendar.Event{}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if ps.CalendarData == "" {
+				continue
+			}
+			evts, err := parseICS(ps.CalendarData)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, evts...)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caldav
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/modules/calendar"
+
+	"github.com/stretchr/testify/require"
+)
+
+const calendarData = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Test//EN
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Standup
+LOCATION:Room 1\, 2nd floor
+DTSTART:20180301T100000Z
+DTEND:20180301T101500Z
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Not the event summary
+TRIGGER:-PT10M
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+const multistatusResponse = `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+  <d:response>
+    <d:href>/calendars/me/personal/standup.ics</d:href>
+    <d:propstat>
+      <d:prop>
+        <cal:calendar-data>%s</cal:calendar-data>
+      </d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+  <d:response>
+    <d:href>/calendars/me/personal/holiday.ics</d:href>
+    <d:propstat>
+      <d:prop>
+        <cal:calendar-data>BEGIN:VCALENDAR
+BEGIN:VEVENT
+SUMMARY:Holiday
+DTSTART;VALUE=DATE:20180301
+END:VEVENT
+END:VCALENDAR
+</cal:calendar-data>
+      </d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>`
+
+func TestEvents(t *testing.T) {
+	var req *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		if user, pass, _ := r.BasicAuth(); user != "me" || pass != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, multistatusResponse, calendarData)
+	}))
+	defer server.Close()
+
+	from := time.Date(2018, time.March, 1, 9, 0, 0, 0, time.UTC)
+	events, err := New(server.URL+"/calendars/me/personal/", "me", "app-password").
+		Events(from, from.Add(12*time.Hour))
+	require.NoError(t, err)
+
+	require.Equal(t, "REPORT", req.Method)
+	require.Equal(t, "/calendars/me/personal/", req.URL.Path)
+	require.Equal(t, "1", req.Header.Get("Depth"))
+	require.Contains(t, body, `<C:time-range start="20180301T090000Z" end="20180301T210000Z"/>`)
+	require.Contains(t, body, `<C:expand start="20180301T090000Z" end="20180301T210000Z"/>`)
+
+	require.Equal(t, []calendar.Event{
+		{
+			Summary:  "Standup",
+			Location: "Room 1, 2nd floor",
+			Start:    time.Date(2018, time.March, 1, 10, 0, 0, 0, time.UTC),
+			End:      time.Date(2018, time.March, 1, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			Summary: "Holiday",
+			Start:   time.Date(2018, time.March, 1, 0, 0, 0, 0, time.Local),
+			End:     time.Date(2018, time.March, 2, 0, 0, 0, 0, time.Local),
+			AllDay:  true,
+		},
+	}, events)
+
+	_, err = New(server.URL, "me", "wrong").Events(from, from.Add(time.Hour))
+	require.Error(t, err, "unauthorized")
+
+	_, err = New("not a url\x7f", "me", "app-password").Events(from, from.Add(time.Hour))
+	require.Error(t, err, "bad url")
+}
+
+func TestParseICS(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	events, err := parseICS(strings.Replace(`BEGIN:VCALENDAR
+BEGIN:VEVENT
+SUMMARY:A meeting with a very long summary that has been folded across
+  multiple lines
+DTSTART;TZID=Europe/Berlin:20180301T140000
+DURATION:PT1H30M
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Cancelled
+STATUS:CANCELLED
+DTSTART:20180301T100000Z
+DTEND:20180301T110000Z
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Conference
+DTSTART;VALUE=DATE:20180301
+DTEND;VALUE=DATE:20180303
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Reminder
+DTSTART:20180301T120000
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n", -1))
+	require.NoError(t, err)
+	require.Equal(t, []calendar.Event{
+		{
+			Summary: "A meeting with a very long summary that has been folded across multiple lines",
+			Start:   time.Date(2018, time.March, 1, 14, 0, 0, 0, berlin),
+			End:     time.Date(2018, time.March, 1, 15, 30, 0, 0, berlin),
+		},
+		{
+			Summary: "Conference",
+			Start:   time.Date(2018, time.March, 1, 0, 0, 0, 0, time.Local),
+			End:     time.Date(2018, time.March, 3, 0, 0, 0, 0, time.Local),
+			AllDay:  true,
+		},
+		{
+			Summary: "Reminder",
+			Start:   time.Date(2018, time.March, 1, 12, 0, 0, 0, time.Local),
+			End:     time.Date(2018, time.March, 1, 12, 0, 0, 0, time.Local),
+		},
+	}, events)
+
+	_, err = parseICS("BEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\n")
+	require.Error(t, err, "bad time")
+	_, err = parseICS("BEGIN:VEVENT\nDURATION:1 hour\nEND:VEVENT\n")
+	require.Error(t, err, "bad duration")
+}
+
+func TestParseProperty(t *testing.T) {
+	p := parseProperty(`ATTENDEE;CN="Doe, John: Jr";PARTSTAT=ACCEPTED:mailto:john@example.com`)
+	require.Equal(t, "ATTENDEE", p.name)
+	require.Equal(t, "mailto:john@example.com", p.value)
+	require.Equal(t, map[string]string{"CN": "Doe, John: Jr", "PARTSTAT": "ACCEPTED"}, p.params)
+}
+
+func TestParseDuration(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"PT15M":    15 * time.Minute,
+		"PT1H30M":  90 * time.Minute,
+		"P1D":      24 * time.Hour,
+		"P1W":      7 * 24 * time.Hour,
+		"P1DT2H":   26 * time.Hour,
+		"-PT10M":   -10 * time.Minute,
+		"+PT5S":    5 * time.Second,
+		"PT1H0M0S": time.Hour,
+	} {
+		d, err := parseDuration(value)
+		require.NoError(t, err, value)
+		require.Equal(t, expected, d, value)
+	}
+	for _, value := range []string{"", "P", "PT", "1H", "PT1D"} {
+		_, err := parseDuration(value)
+		require.Error(t, err, value)
+	}
+}
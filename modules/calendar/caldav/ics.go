@@ -0,0 +1,198 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caldav
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/modules/calendar"
+)
+
+// property is a single iCalendar (RFC 5545) content line.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// unfold joins folded lines, which continue with a leading space or tab.
+func unfold(data string) []string {
+	data = strings.Replace(data, "\r\n", "\n", -1)
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func parseProperty(line string) property {
+	p := property{params: map[string]string{}}
+	// The value starts at the first colon that is not in a quoted parameter.
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		p.name = strings.ToUpper(line)
+		return p
+	}
+	p.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return p
+}
+
+var textEscapes = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+// parseICS returns the events in iCalendar data. Cancelled events are skipped.
+func parseICS(data string) ([]calendar.Event, error) {
+	var events []calendar.Event
+	var props []property
+	// Components nested in events (e.g. alarms) are ignored.
+	depth := 0
+	for _, line := range unfold(data) {
+		p := parseProperty(line)
+		switch {
+		case p.name == "BEGIN" && strings.ToUpper(p.value) == "VEVENT":
+			depth = 1
+			props = nil
+		case p.name == "BEGIN" && depth > 0:
+			depth++
+		case p.name == "END" && depth > 1:
+			depth--
+		case p.name == "END" && depth == 1:
+			depth = 0
+			evt, cancelled, err := makeEvent(props)
+			if err != nil {
+				return nil, err
+			}
+			if !cancelled {
+				events = append(events, evt)
+			}
+		case depth == 1:
+			props = append(props, p)
+		}
+	}
+	return events, nil
+}
+
+func makeEvent(props []property) (e calendar.Event, cancelled bool, err error) {
+	var end time.Time
+	var duration time.Duration
+	hasDuration := false
+	for _, p := range props {
+		switch p.name {
+		case "SUMMARY":
+			e.Summary = textEscapes.Replace(p.value)
+		case "LOCATION":
+			e.Location = textEscapes.Replace(p.value)
+		case "STATUS":
+			cancelled = strings.ToUpper(p.value) == "CANCELLED"
+		case "DTSTART":
+			if e.Start, e.AllDay, err = parseTime(p); err != nil {
+				return e, false, err
+			}
+		case "DTEND":
+			if end, _, err = parseTime(p); err != nil {
+				return e, false, err
+			}
+		case "DURATION":
+			if duration, err = parseDuration(p.value); err != nil {
+				return e, false, err
+			}
+			hasDuration = true
+		}
+	}
+	switch {
+	case !end.IsZero():
+		e.End = end
+	case hasDuration:
+		e.End = e.Start.Add(duration)
+	case e.AllDay:
+		// All-day events without an end last for a single day.
+		e.End = e.Start.AddDate(0, 0, 1)
+	default:
+		e.End = e.Start
+	}
+	return e, cancelled, nil
+}
+
+// parseTime parses a DATE or DATE-TIME value. Times in UTC end with "Z",
+// otherwise they are in the time zone given by the TZID parameter, or
+// "floating" local times.
+func parseTime(p property) (time.Time, bool, error) {
+	if p.params["VALUE"] == "DATE" || len(p.value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", p.value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse(timeFormat, p.value)
+		return t, false, err
+	}
+	loc := time.Local
+	if tzid, ok := p.params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", p.value, loc)
+	return t, false, err
+}
+
+var durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses an iCalendar duration, e.g. "PT1H30M" or "P1D".
+func parseDuration(value string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("caldav: invalid duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package calendar provides an i3bar module that shows the next upcoming event
+from a calendar, e.g. "Standup in 12m".
+
+Events are fetched using a Provider, implemented by the provider packages:
+google for Google Calendar, and caldav for CalDAV servers (e.g. Nextcloud,
+Fastmail, or iCloud).
+
+All-day events are kept separate from timed events, since they rarely need
+a countdown, but are available to the output function.
+*/
+package calendar // import "barista.run/modules/calendar"
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Event represents a calendar event.
+type Event struct {
+	Summary  string
+	Location string
+	Start    time.Time
+	End      time.Time
+	// AllDay is true for events that span entire days rather than having a
+	// start and end time. For these events Start and End are midnight in the
+	// local time zone, and End is the day after the last day of the event.
+	AllDay bool
+}
+
+// UntilStart returns the time remaining until the event starts.
+func (e Event) UntilStart() time.Duration {
+	return e.Start.Sub(timing.Now())
+}
+
+// UntilEnd returns the time remaining until the event ends.
+func (e Event) UntilEnd() time.Duration {
+	return e.End.Sub(timing.Now())
+}
+
+// InProgress returns true if the event has started but not yet ended.
+func (e Event) InProgress() bool {
+	now := timing.Now()
+	return !now.Before(e.Start) && now.Before(e.End)
+}
+
+// Info represents the events in the module's time window.
+type Info struct {
+	// Events are timed events that have not yet ended, sorted by start time.
+	Events []Event
+	// AllDay are all-day events that have not yet ended, sorted by start date.
+	AllDay  []Event
+	urgency time.Duration
+}
+
+// Next returns the next timed event that has not yet started, and false if
+// there are no upcoming events in the time window.
+func (i Info) Next() (Event, bool) {
+	for _, e := range i.Events {
+		if e.UntilStart() > 0 {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// Current returns the timed events that are currently in progress.
+func (i Info) Current() []Event {
+	var current []Event
+	for _, e := range i.Events {
+		if e.InProgress() {
+			current = append(current, e)
+		}
+	}
+	return current
+}
+
+// Urgent returns true if the next event starts within the urgency duration
+// configured on the module.
+func (i Info) Urgent() bool {
+	next, ok := i.Next()
+	return ok && next.UntilStart() <= i.urgency
+}
+
+// Provider is an interface for calendar providers, implemented by the various
+// provider packages.
+type Provider interface {
+	// Events returns the events that overlap the given time range.
+	Events(from, to time.Time) ([]Event, error)
+}
+
+// Module represents a calendar bar module.
+type Module struct {
+	provider   Provider
+	window     value.Value // of time.Duration
+	urgency    value.Value // of time.Duration
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a calendar module that shows events from the given provider.
+func New(provider Provider) *Module {
+	m := &Module{provider: provider, scheduler: timing.NewScheduler().Named("calendar")}
+	l.Register(m, "outputFunc", "window", "urgency", "scheduler")
+	m.TimeWindow(12 * time.Hour)
+	m.UrgentWithin(5 * time.Minute)
+	// Default output is the next event and the time until it starts, which
+	// is marked urgent as the event approaches.
+	m.Output(func(i Info) bar.Output {
+		next, ok := i.Next()
+		if !ok {
+			return nil
+		}
+		return outputs.Textf("%s in %s", next.Summary, Until(next.UntilStart())).
+			Urgent(i.Urgent())
+	})
+	m.RefreshInterval(5 * time.Minute)
+	return m
+}
+
+// Until formats a duration as a compact countdown, e.g. "12m" or "1h5m",
+// rounding up to the next minute.
+func Until(d time.Duration) string {
+	mins := int((d + time.Minute - 1) / time.Minute)
+	if mins < 60 {
+		return fmt.Sprintf("%dm", mins)
+	}
+	if mins%60 == 0 {
+		return fmt.Sprintf("%dh", mins/60)
+	}
+	return fmt.Sprintf("%dh%dm", mins/60, mins%60)
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency for events. The output is
+// updated every minute regardless, to keep countdowns current.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// TimeWindow sets how far ahead to look for events.
+func (m *Module) TimeWindow(window time.Duration) *Module {
+	m.window.Set(window)
+	return m
+}
+
+// UrgentWithin sets how soon before the start of the next event it is
+// considered urgent.
+func (m *Module) UrgentWithin(urgency time.Duration) *Module {
+	m.urgency.Set(urgency)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	events, err := m.fetch()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextWindow, doneWindow := m.window.Subscribe()
+	defer doneWindow()
+	nextUrgency, doneUrgency := m.urgency.Subscribe()
+	defer doneUrgency()
+	renderer := timing.NewScheduler().Every(time.Minute)
+	defer renderer.Stop()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(m.makeInfo(events)))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextWindow:
+			events, err = m.fetch()
+		case <-nextUrgency:
+		case <-m.scheduler.C:
+			events, err = m.fetch()
+		case <-renderer.C:
+		}
+	}
+}
+
+func (m *Module) fetch() ([]Event, error) {
+	now := timing.Now()
+	return m.provider.Events(now, now.Add(m.window.Get().(time.Duration)))
+}
+
+func (m *Module) makeInfo(events []Event) Info {
+	now := timing.Now()
+	i := Info{urgency: m.urgency.Get().(time.Duration)}
+	for _, e := range events {
+		if !e.End.After(now) {
+			continue
+		}
+		if e.AllDay {
+			i.AllDay = append(i.AllDay, e)
+		} else {
+			i.Events = append(i.Events, e)
+		}
+	}
+	sort.SliceStable(i.Events, func(a, b int) bool {
+		return i.Events[a].Start.Before(i.Events[b].Start)
+	})
+	sort.SliceStable(i.AllDay, func(a, b int) bool {
+		return i.AllDay[a].Start.Before(i.AllDay[b].Start)
+	})
+	return i
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calendar
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sync.Mutex
+	events   []Event
+	err      error
+	from, to time.Time
+}
+
+func (t *testProvider) Events(from, to time.Time) ([]Event, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.from, t.to = from, to
+	return t.events, t.err
+}
+
+func (t *testProvider) set(err error, events ...Event) {
+	t.Lock()
+	defer t.Unlock()
+	t.events, t.err = events, err
+}
+
+func (t *testProvider) window() time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	return t.to.Sub(t.from)
+}
+
+func event(summary string, start, length time.Duration) Event {
+	now := timing.Now()
+	return Event{
+		Summary: summary,
+		Start:   now.Add(start),
+		End:     now.Add(start + length),
+	}
+}
+
+func allDay(summary string, days int) Event {
+	y, m, d := timing.Now().Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	return Event{
+		Summary: summary,
+		Start:   start,
+		End:     start.AddDate(0, 0, days),
+		AllDay:  true,
+	}
+}
+
+func TestUntil(t *testing.T) {
+	for _, tc := range []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "0m"},
+		{time.Second, "1m"},
+		{11*time.Minute + 30*time.Second, "12m"},
+		{time.Hour, "1h"},
+		{time.Hour + 5*time.Minute, "1h5m"},
+		{26 * time.Hour, "26h"},
+	} {
+		require.Equal(t, tc.expected, Until(tc.d), "Until(%s)", tc.d)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	timing.TestMode()
+	m := New(&testProvider{})
+	i := m.makeInfo([]Event{
+		event("Lunch", 2*time.Hour, time.Hour),
+		event("Standup", 10*time.Minute, 15*time.Minute),
+		event("Ended", -time.Hour, 30*time.Minute),
+		event("Focus", -time.Hour, 2*time.Hour),
+		allDay("Holiday", 1),
+	})
+	require.Len(t, i.Events, 3, "ended events are removed")
+	require.Equal(t, "Focus", i.Events[0].Summary, "sorted by start")
+	require.Len(t, i.AllDay, 1)
+
+	next, ok := i.Next()
+	require.True(t, ok)
+	require.Equal(t, "Standup", next.Summary)
+	require.Equal(t, 10*time.Minute, next.UntilStart())
+	require.Equal(t, 25*time.Minute, next.UntilEnd())
+	require.False(t, i.Urgent())
+
+	current := i.Current()
+	require.Len(t, current, 1)
+	require.Equal(t, "Focus", current[0].Summary)
+	require.True(t, i.AllDay[0].InProgress())
+
+	timing.AdvanceBy(6 * time.Minute)
+	require.True(t, i.Urgent())
+
+	_, ok = Info{}.Next()
+	require.False(t, ok)
+	require.False(t, Info{}.Urgent())
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{}
+	p.set(nil,
+		event("Standup", 12*time.Minute, 15*time.Minute),
+		event("Lunch", 3*time.Hour, time.Hour),
+		allDay("Holiday", 1),
+	)
+	m := New(p).RefreshInterval(time.Hour)
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"Standup in 12m"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+	require.Equal(t, 12*time.Hour, p.window())
+
+	testBar.Tick()
+	testBar.NextOutput("on render tick").AssertText([]string{"Standup in 11m"})
+
+	for i := 0; i < 6; i++ {
+		testBar.Tick()
+		out = testBar.NextOutput("on render tick")
+	}
+	out.AssertText([]string{"Standup in 5m"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	m.UrgentWithin(time.Minute)
+	out = testBar.NextOutput("on urgency change")
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	for i := 0; i < 5; i++ {
+		testBar.Tick()
+		out = testBar.NextOutput("on render tick")
+	}
+	out.AssertText([]string{"Lunch in 2h48m"})
+
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, e := range i.AllDay {
+			out.Append(outputs.Text(e.Summary))
+		}
+		for _, e := range i.Current() {
+			out.Append(outputs.Textf("%s until %s", e.Summary, Until(e.UntilEnd())))
+		}
+		return out
+	})
+	testBar.NextOutput("on output change").
+		AssertText([]string{"Holiday", "Standup until 15m"})
+
+	m.TimeWindow(time.Hour)
+	testBar.NextOutput("on window change")
+	require.Equal(t, time.Hour, p.window())
+
+	p.set(errors.New("foo"))
+	m.TimeWindow(2 * time.Hour)
+	testBar.NextOutput("on error").AssertError()
+}
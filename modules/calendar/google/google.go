@@ -0,0 +1,163 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package google provides calendar events from Google Calendar, using the
+Calendar API at https://developers.google.com/calendar.
+
+Like the gsuite modules, this requires an oauth client config, and the token
+is obtained by running the bar with the "setup-oauth" argument.
+*/
+package google // import "barista.run/modules/calendar/google"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"barista.run/modules/calendar"
+	"barista.run/oauth"
+
+	oauthgoogle "golang.org/x/oauth2/google"
+)
+
+const readonlyScope = "https://www.googleapis.com/auth/calendar.readonly"
+
+// Provider provides events from a Google Calendar.
+type Provider struct {
+	config       *oauth.Config
+	calendarID   string
+	showDeclined bool
+}
+
+// New creates a provider for the user's primary calendar from the given
+// oauth client config.
+func New(clientConfig []byte) *Provider {
+	conf, err := oauthgoogle.ConfigFromJSON(clientConfig, readonlyScope)
+	if err != nil {
+		panic("Bad client config: " + err.Error())
+	}
+	return &Provider{config: oauth.Register(conf), calendarID: "primary"}
+}
+
+// CalendarID sets the ID of the calendar to fetch events from.
+func (p *Provider) CalendarID(id string) *Provider {
+	p.calendarID = id
+	return p
+}
+
+// ShowDeclined controls whether declined events are included.
+func (p *Provider) ShowDeclined(show bool) *Provider {
+	p.showDeclined = show
+	return p
+}
+
+// for tests, to wrap the client in a transport that redirects requests.
+var wrapForTest func(*http.Client)
+
+type gcalTime struct {
+	Date     string `json:"date"`
+	DateTime string `json:"dateTime"`
+}
+
+type gcalEvents struct {
+	Items []struct {
+		Status    string   `json:"status"`
+		Summary   string   `json:"summary"`
+		Location  string   `json:"location"`
+		Start     gcalTime `json:"start"`
+		End       gcalTime `json:"end"`
+		Attendees []struct {
+			Self           bool   `json:"self"`
+			ResponseStatus string `json:"responseStatus"`
+		} `json:"attendees"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// Events implements calendar.Provider.
+func (p *Provider) Events(from, to time.Time) ([]calendar.Event, error) {
+	// A missing token is reported by the client on each request.
+	client, _ := p.config.Client()
+	if wrapForTest != nil {
+		wrapForTest(client)
+	}
+	q := url.Values{}
+	// Simplify recurring events by converting them to single events.
+	q.Set("singleEvents", "true")
+	q.Set("orderBy", "startTime")
+	q.Set("maxAttendees", "1")
+	q.Set("timeMin", from.Format(time.RFC3339))
+	q.Set("timeMax", to.Format(time.RFC3339))
+	events := []calendar.Event{}
+	for {
+		u := url.URL{
+			Scheme:   "https",
+			Host:     "www.googleapis.com",
+			Path:     fmt.Sprintf("/calendar/v3/calendars/%s/events", url.PathEscape(p.calendarID)),
+			RawQuery: q.Encode(),
+		}
+		res, err := client.Get(u.String())
+		if err != nil {
+			return nil, err
+		}
+		var r gcalEvents
+		err = json.NewDecoder(res.Body).Decode(&r)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("calendar API: %s", res.Status)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range r.Items {
+			if e.Status == "cancelled" {
+				continue
+			}
+			declined := false
+			for _, a := range e.Attendees {
+				if a.Self && a.ResponseStatus == "declined" {
+					declined = true
+				}
+			}
+			if declined && !p.showDeclined {
+				continue
+			}
+			evt := calendar.Event{Summary: e.Summary, Location: e.Location}
+			if evt.Start, evt.AllDay, err = parseTime(e.Start); err != nil {
+				return nil, err
+			}
+			if evt.End, _, err = parseTime(e.End); err != nil {
+				return nil, err
+			}
+			events = append(events, evt)
+		}
+		if r.NextPageToken == "" {
+			return events, nil
+		}
+		q.Set("pageToken", r.NextPageToken)
+	}
+}
+
+// parseTime parses an event time, which has only a date for all-day events.
+func parseTime(t gcalTime) (time.Time, bool, error) {
+	if t.DateTime != "" {
+		tm, err := time.Parse(time.RFC3339, t.DateTime)
+		return tm, false, err
+	}
+	tm, err := time.ParseInLocation("2006-01-02", t.Date, time.Local)
+	return tm, true, err
+}
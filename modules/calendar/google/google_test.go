@@ -0,0 +1,174 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"barista.run/modules/calendar"
+	"barista.run/oauth"
+	"barista.run/testing/httpclient"
+
+	"github.com/stretchr/testify/require"
+)
+
+var fakeClientConfig = []byte(`{
+	"installed": {
+		"client_id": "143832941570-ek4civ0n1csaahcspkpag91dmfmudd7k.apps.googleusercontent.com",
+		"project_id": "i3-barista",
+		"auth_uri": "https://accounts.google.com/o/oauth2/auth",
+		"token_uri": "https://www.googleapis.com/oauth2/v3/token",
+		"auth_provider_x509_cert_url": "https://www.googleapis.com/oauth2/v1/certs",
+		"client_secret": "yFSEf5c-vgzzDnfb4vLHqAlr",
+		"redirect_uris": ["urn:ietf:wg:oauth:2.0:oob", "http://localhost"]
+	}
+}`)
+
+var requests []*http.Request
+
+const page1 = `{
+	"items": [
+		{
+			"status": "confirmed",
+			"summary": "Holiday",
+			"start": {"date": "2018-03-01"},
+			"end": {"date": "2018-03-02"}
+		},
+		{
+			"status": "confirmed",
+			"summary": "Standup",
+			"location": "Room 1",
+			"start": {"dateTime": "2018-03-01T10:00:00Z"},
+			"end": {"dateTime": "2018-03-01T10:15:00Z"},
+			"attendees": [{"self": true, "responseStatus": "accepted"}]
+		},
+		{
+			"status": "cancelled",
+			"summary": "Cancelled",
+			"start": {"dateTime": "2018-03-01T11:00:00Z"},
+			"end": {"dateTime": "2018-03-01T12:00:00Z"}
+		}
+	],
+	"nextPageToken": "page2"
+}`
+
+const page2 = `{
+	"items": [
+		{
+			"status": "confirmed",
+			"summary": "Declined",
+			"start": {"dateTime": "2018-03-01T13:00:00+01:00"},
+			"end": {"dateTime": "2018-03-01T14:00:00+01:00"},
+			"attendees": [{"self": true, "responseStatus": "declined"}]
+		}
+	]
+}`
+
+func TestEvents(t *testing.T) {
+	requests = nil
+	from := time.Date(2018, time.March, 1, 9, 0, 0, 0, time.UTC)
+	events, err := New(fakeClientConfig).Events(from, from.Add(12*time.Hour))
+	require.NoError(t, err)
+
+	require.Len(t, requests, 2, "fetches all pages")
+	q := requests[0].URL.Query()
+	require.Equal(t, "/calendar/v3/calendars/primary/events", requests[0].URL.Path)
+	require.Equal(t, "2018-03-01T09:00:00Z", q.Get("timeMin"))
+	require.Equal(t, "2018-03-01T21:00:00Z", q.Get("timeMax"))
+	require.Equal(t, "true", q.Get("singleEvents"))
+	require.Equal(t, "page2", requests[1].URL.Query().Get("pageToken"))
+	require.Equal(t, "Bearer authtoken-placeholder", requests[0].Header.Get("Authorization"))
+
+	require.Equal(t, []calendar.Event{
+		{
+			Summary: "Holiday",
+			Start:   time.Date(2018, time.March, 1, 0, 0, 0, 0, time.Local),
+			End:     time.Date(2018, time.March, 2, 0, 0, 0, 0, time.Local),
+			AllDay:  true,
+		},
+		{
+			Summary:  "Standup",
+			Location: "Room 1",
+			Start:    time.Date(2018, time.March, 1, 10, 0, 0, 0, time.UTC),
+			End:      time.Date(2018, time.March, 1, 10, 15, 0, 0, time.UTC),
+		},
+	}, events, "cancelled and declined events are skipped")
+
+	events, err = New(fakeClientConfig).
+		CalendarID("team@group.calendar.google.com").
+		ShowDeclined(true).
+		Events(from, from.Add(12*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, "Declined", events[2].Summary)
+	require.True(t, events[2].Start.Equal(time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)))
+	require.Equal(t,
+		"/calendar/v3/calendars/team@group.calendar.google.com/events",
+		requests[2].URL.Path)
+}
+
+func TestErrors(t *testing.T) {
+	require.Panics(t, func() { New([]byte(`not-a-json-config`)) })
+
+	from := time.Date(2018, time.March, 1, 9, 0, 0, 0, time.UTC)
+	_, err := New(fakeClientConfig).CalendarID("missing").
+		Events(from, from.Add(time.Hour))
+	require.Error(t, err, "http error")
+
+	_, err = New(fakeClientConfig).CalendarID("bad-json").
+		Events(from, from.Add(time.Hour))
+	require.Error(t, err, "bad json")
+
+	_, err = New(fakeClientConfig).CalendarID("bad-time").
+		Events(from, from.Add(time.Hour))
+	require.Error(t, err, "bad time")
+}
+
+func TestMain(m *testing.M) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/calendar/v3/calendars/primary/events",
+			"/calendar/v3/calendars/team@group.calendar.google.com/events":
+			if r.URL.Query().Get("pageToken") == "page2" {
+				fmt.Fprint(w, page2)
+			} else {
+				fmt.Fprint(w, page1)
+			}
+		case "/calendar/v3/calendars/bad-json/events":
+			fmt.Fprint(w, `{"items": [`)
+		case "/calendar/v3/calendars/bad-time/events":
+			fmt.Fprint(w, `{"items": [{"start": {"dateTime": "10am"}}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": 404}}`)
+		}
+	}))
+	defer server.Close()
+
+	oauth.SetEncryptionKey([]byte("test"))
+	wrapForTest = func(c *http.Client) {
+		httpclient.FreezeOauthToken(c, "authtoken-placeholder")
+		httpclient.Wrap(c, server.URL)
+	}
+
+	os.Exit(m.Run())
+}
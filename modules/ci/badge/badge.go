@@ -0,0 +1,141 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package badge provides the status of a build from any endpoint that serves a
+shields.io style JSON badge (https://shields.io/endpoint), e.g.
+
+	{"schemaVersion": 1, "label": "build", "message": "passing", "color": "green"}
+
+The status is derived from the message if possible, falling back to the
+colour of the badge.
+*/
+package badge // import "barista.run/modules/ci/badge"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"barista.run/httpclient"
+	"barista.run/modules/ci"
+)
+
+// Provider provides the status of a single badge.
+type Provider struct {
+	url     string
+	name    string
+	link    string
+	headers map[string]string
+}
+
+// New creates a provider for the JSON badge at the given URL.
+func New(url string) *Provider {
+	return &Provider{url: url, headers: map[string]string{}}
+}
+
+// Name sets the name of the run, instead of using the label of the badge.
+func (p *Provider) Name(name string) *Provider {
+	p.name = name
+	return p
+}
+
+// Link sets the URL to open when the run is clicked.
+func (p *Provider) Link(link string) *Provider {
+	p.link = link
+	return p
+}
+
+// Header adds a header to the request, e.g. for authorization.
+func (p *Provider) Header(key, value string) *Provider {
+	p.headers[key] = value
+	return p
+}
+
+var client = httpclient.Client()
+
+type badge struct {
+	Label   string `json:"label"`
+	Message string `json:"message"`
+	Color   string `json:"color"`
+}
+
+// Runs implements ci.Provider.
+func (p *Provider) Runs() ([]ci.Run, error) {
+	req, err := http.NewRequest("GET", p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("badge: %s", res.Status)
+	}
+	var b badge
+	if err := json.NewDecoder(res.Body).Decode(&b); err != nil {
+		return nil, err
+	}
+	run := ci.Run{Name: p.name, Status: status(b), URL: p.link}
+	if run.Name == "" {
+		run.Name = b.Label
+	}
+	return []ci.Run{run}, nil
+}
+
+var messages = map[string]ci.Status{
+	"passing":     ci.StatusSuccess,
+	"passed":      ci.StatusSuccess,
+	"success":     ci.StatusSuccess,
+	"succeeded":   ci.StatusSuccess,
+	"ok":          ci.StatusSuccess,
+	"failing":     ci.StatusFailure,
+	"failed":      ci.StatusFailure,
+	"failure":     ci.StatusFailure,
+	"error":       ci.StatusFailure,
+	"broken":      ci.StatusFailure,
+	"pending":     ci.StatusPending,
+	"queued":      ci.StatusPending,
+	"running":     ci.StatusPending,
+	"in progress": ci.StatusPending,
+	"building":    ci.StatusPending,
+	"cancelled":   ci.StatusCancelled,
+	"canceled":    ci.StatusCancelled,
+}
+
+var colours = map[string]ci.Status{
+	"brightgreen": ci.StatusSuccess,
+	"green":       ci.StatusSuccess,
+	"success":     ci.StatusSuccess,
+	"red":         ci.StatusFailure,
+	"critical":    ci.StatusFailure,
+	"yellow":      ci.StatusPending,
+	"important":   ci.StatusPending,
+}
+
+func status(b badge) ci.Status {
+	if s, ok := messages[strings.ToLower(strings.TrimSpace(b.Message))]; ok {
+		return s
+	}
+	if s, ok := colours[strings.ToLower(b.Color)]; ok {
+		return s
+	}
+	return ci.StatusUnknown
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badge
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"barista.run/modules/ci"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	for _, tc := range []struct {
+		message, color string
+		expected       ci.Status
+	}{
+		{"passing", "brightgreen", ci.StatusSuccess},
+		{" Failing ", "red", ci.StatusFailure},
+		{"in progress", "", ci.StatusPending},
+		{"canceled", "lightgrey", ci.StatusCancelled},
+		{"v1.2.3", "green", ci.StatusSuccess},
+		{"42 errors", "critical", ci.StatusFailure},
+		{"unknown", "lightgrey", ci.StatusUnknown},
+	} {
+		require.Equal(t, tc.expected, status(badge{Message: tc.message, Color: tc.color}),
+			"%q/%q", tc.message, tc.color)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	var token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Token")
+		if r.URL.Path != "/badge.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, `{"schemaVersion": 1, "label": "build", "message": "failing", "color": "red"}`)
+	}))
+	defer srv.Close()
+
+	runs, err := New(srv.URL + "/badge.json").Runs()
+	require.NoError(t, err)
+	require.Equal(t, []ci.Run{{Name: "build", Status: ci.StatusFailure}}, runs)
+	require.Empty(t, token)
+
+	runs, err = New(srv.URL+"/badge.json").
+		Name("nightly").
+		Link("https://ci.example.com/nightly").
+		Header("X-Token", "secret").
+		Runs()
+	require.NoError(t, err)
+	require.Equal(t, []ci.Run{{
+		Name: "nightly", Status: ci.StatusFailure, URL: "https://ci.example.com/nightly",
+	}}, runs)
+	require.Equal(t, "secret", token)
+
+	_, err = New(srv.URL + "/other.json").Runs()
+	require.Error(t, err)
+}
@@ -0,0 +1,229 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package ci provides an i3bar module that shows the status of the latest
+continuous integration runs, e.g. of selected GitHub Actions workflows.
+
+Runs are fetched using one or more Providers, implemented by the provider
+packages:
+  - github: GitHub Actions workflow runs.
+  - badge: any endpoint that serves a shields.io style JSON badge.
+*/
+package ci // import "barista.run/modules/ci"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	"barista.run/colors"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Status represents the status of a CI run.
+type Status int
+
+// Possible statuses for a run.
+const (
+	StatusUnknown Status = iota
+	StatusPending
+	StatusSuccess
+	StatusFailure
+	StatusCancelled
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPending:
+		return "pending"
+	case StatusSuccess:
+		return "success"
+	case StatusFailure:
+		return "failure"
+	case StatusCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// Run represents the latest run of a build or workflow.
+type Run struct {
+	// Name is the name of the workflow or build.
+	Name   string
+	Branch string
+	Status Status
+	// URL is the web page for the run, if available.
+	URL string
+}
+
+// Info represents the latest runs from all providers.
+type Info struct {
+	Runs []Run
+}
+
+// Status returns the combined status of all runs: failure if any run failed,
+// otherwise pending if any run is still in progress, and success if all runs
+// succeeded.
+func (i Info) Status() Status {
+	if len(i.Runs) == 0 {
+		return StatusUnknown
+	}
+	pending, success := false, true
+	for _, r := range i.Runs {
+		switch r.Status {
+		case StatusFailure:
+			return StatusFailure
+		case StatusPending:
+			pending = true
+		}
+		success = success && r.Status == StatusSuccess
+	}
+	switch {
+	case pending:
+		return StatusPending
+	case success:
+		return StatusSuccess
+	default:
+		return StatusUnknown
+	}
+}
+
+// Failed returns the runs that failed.
+func (i Info) Failed() []Run {
+	var failed []Run
+	for _, r := range i.Runs {
+		if r.Status == StatusFailure {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Provider is an interface for CI providers, implemented by the various
+// provider packages.
+type Provider interface {
+	// Runs returns the latest run of each build or workflow.
+	Runs() ([]Run, error)
+}
+
+// Module represents a CI status bar module.
+type Module struct {
+	providers  []Provider
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a CI module that shows runs from all of the given providers.
+func New(providers ...Provider) *Module {
+	m := &Module{providers: providers, scheduler: timing.NewScheduler().Named("ci")}
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is a segment for each run, coloured by its status,
+	// which opens the run when clicked.
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, r := range i.Runs {
+			seg := outputs.Textf("%s %s", symbol(r.Status), r.Name).
+				Color(color(r.Status))
+			if r.URL != "" {
+				seg.OnClick(click.RunLeft("xdg-open", r.URL))
+			}
+			out.Append(seg)
+		}
+		return out
+	})
+	m.RefreshInterval(5 * time.Minute)
+	return m
+}
+
+func symbol(s Status) string {
+	switch s {
+	case StatusPending:
+		return "…"
+	case StatusSuccess:
+		return "✓"
+	case StatusFailure:
+		return "✗"
+	case StatusCancelled:
+		return "⊘"
+	default:
+		return "?"
+	}
+}
+
+func color(s Status) colors.ColorfulColor {
+	switch s {
+	case StatusPending:
+		return colors.Scheme("degraded")
+	case StatusSuccess:
+		return colors.Scheme("good")
+	case StatusFailure:
+		return colors.Scheme("bad")
+	default:
+		return nil
+	}
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency for runs.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+func (m *Module) fetch() (Info, error) {
+	i := Info{}
+	for _, p := range m.providers {
+		runs, err := p.Runs()
+		if err != nil {
+			return i, err
+		}
+		i.Runs = append(i.Runs, runs...)
+	}
+	return i, nil
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.fetch()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.fetch()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ci
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sync.Mutex
+	runs []Run
+	err  error
+}
+
+func (t *testProvider) Runs() ([]Run, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.runs, t.err
+}
+
+func (t *testProvider) set(err error, runs ...Run) {
+	t.Lock()
+	defer t.Unlock()
+	t.runs, t.err = runs, err
+}
+
+func TestStatus(t *testing.T) {
+	for _, tc := range []struct {
+		statuses []Status
+		expected Status
+	}{
+		{nil, StatusUnknown},
+		{[]Status{StatusSuccess, StatusSuccess}, StatusSuccess},
+		{[]Status{StatusSuccess, StatusPending}, StatusPending},
+		{[]Status{StatusPending, StatusFailure}, StatusFailure},
+		{[]Status{StatusSuccess, StatusCancelled}, StatusUnknown},
+	} {
+		i := Info{}
+		for _, s := range tc.statuses {
+			i.Runs = append(i.Runs, Run{Status: s})
+		}
+		require.Equal(t, tc.expected, i.Status(), "%v", tc.statuses)
+	}
+	require.Equal(t, "failure", StatusFailure.String())
+	require.Equal(t, "unknown", Status(42).String())
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	a, b := &testProvider{}, &testProvider{}
+	a.set(nil, Run{Name: "build", Branch: "main", Status: StatusSuccess})
+	b.set(nil, Run{Name: "lint", Status: StatusFailure}, Run{Name: "test", Status: StatusPending})
+	m := New(a, b).Output(func(i Info) bar.Output {
+		return outputs.Textf("%d runs, %s, %d failed", len(i.Runs), i.Status(), len(i.Failed()))
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"3 runs, failure, 1 failed"})
+
+	b.set(nil, Run{Name: "lint", Status: StatusSuccess})
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"2 runs, success, 0 failed"})
+
+	a.set(errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+
+	a.set(nil)
+	testBar.Tick()
+	testBar.NextOutput("after error").AssertText([]string{"1 runs, success, 0 failed"})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	colors.LoadFromMap(map[string]string{"good": "#0f0", "bad": "#f00"})
+	defer colors.LoadFromMap(map[string]string{})
+
+	testBar.New(t)
+	p := &testProvider{}
+	p.set(nil,
+		Run{Name: "build", Status: StatusSuccess, URL: "https://ci/build/1"},
+		Run{Name: "lint", Status: StatusFailure, URL: "https://ci/lint/1"},
+		Run{Name: "deploy", Status: StatusCancelled},
+	)
+	testBar.Run(New(p))
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"✓ build", "✗ lint", "⊘ deploy"})
+	for idx, expected := range []string{"#00ff00", "#ff0000", ""} {
+		c, _ := out.At(idx).Segment().GetColor()
+		actual := ""
+		if c != nil {
+			cf, _ := colorful.MakeColor(c)
+			actual = cf.Hex()
+		}
+		require.Equal(t, expected, actual, "colour of segment %d", idx)
+	}
+	require.True(t, out.At(1).Segment().HasClick())
+	require.False(t, out.At(2).Segment().HasClick())
+
+	p.set(nil)
+	testBar.Tick()
+	testBar.NextOutput("with no runs").AssertEmpty()
+}
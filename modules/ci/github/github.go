@@ -0,0 +1,151 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package github provides the status of GitHub Actions workflow runs, using the
+REST API at https://docs.github.com/en/rest/actions/workflow-runs.
+
+Public repositories can be queried without a token, but unauthenticated
+requests are limited to 60 per hour, shared by all workflows.
+*/
+package github // import "barista.run/modules/ci/github"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"barista.run/httpclient"
+	"barista.run/modules/ci"
+)
+
+// Provider provides the latest workflow runs for a GitHub repository.
+type Provider struct {
+	repo      string
+	workflows []string
+	branch    string
+	token     string
+}
+
+// New creates a provider for the given repository ("owner/name"). If any
+// workflows are given, either as a file name ("build.yml") or a numeric ID,
+// the latest run of each is provided. Otherwise only the latest run of any
+// workflow is provided.
+func New(repo string, workflows ...string) *Provider {
+	return &Provider{repo: repo, workflows: workflows}
+}
+
+// Branch restricts runs to those for the given branch.
+func (p *Provider) Branch(branch string) *Provider {
+	p.branch = branch
+	return p
+}
+
+// Token sets a personal access token, required for private repositories.
+func (p *Provider) Token(token string) *Provider {
+	p.token = token
+	return p
+}
+
+var client = httpclient.Client()
+
+// baseURL can be replaced in tests.
+var baseURL = "https://api.github.com"
+
+type ghRun struct {
+	Name       string `json:"name"`
+	HeadBranch string `json:"head_branch"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HTMLURL    string `json:"html_url"`
+}
+
+func (p *Provider) latestRun(path string) (*ghRun, error) {
+	query := url.Values{"per_page": {"1"}}
+	if p.branch != "" {
+		query.Set("branch", p.branch)
+	}
+	req, err := http.NewRequest("GET", baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github: %s", res.Status)
+	}
+	var resp struct {
+		WorkflowRuns []ghRun `json:"workflow_runs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if len(resp.WorkflowRuns) == 0 {
+		return nil, nil
+	}
+	return &resp.WorkflowRuns[0], nil
+}
+
+// Runs implements ci.Provider.
+func (p *Provider) Runs() ([]ci.Run, error) {
+	repo := "/repos/" + p.repo
+	paths := []string{repo + "/actions/runs"}
+	if len(p.workflows) > 0 {
+		paths = nil
+		for _, w := range p.workflows {
+			paths = append(paths, repo+"/actions/workflows/"+url.PathEscape(w)+"/runs")
+		}
+	}
+	runs := []ci.Run{}
+	for _, path := range paths {
+		r, err := p.latestRun(path)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			continue
+		}
+		runs = append(runs, ci.Run{
+			Name:   r.Name,
+			Branch: r.HeadBranch,
+			Status: status(r.Status, r.Conclusion),
+			URL:    r.HTMLURL,
+		})
+	}
+	return runs, nil
+}
+
+func status(status, conclusion string) ci.Status {
+	if status != "completed" {
+		return ci.StatusPending
+	}
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return ci.StatusSuccess
+	case "failure", "timed_out", "startup_failure", "action_required":
+		return ci.StatusFailure
+	case "cancelled":
+		return ci.StatusCancelled
+	default:
+		return ci.StatusUnknown
+	}
+}
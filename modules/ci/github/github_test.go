@@ -0,0 +1,94 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"barista.run/modules/ci"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	for _, tc := range []struct {
+		status, conclusion string
+		expected           ci.Status
+	}{
+		{"queued", "", ci.StatusPending},
+		{"in_progress", "", ci.StatusPending},
+		{"completed", "success", ci.StatusSuccess},
+		{"completed", "skipped", ci.StatusSuccess},
+		{"completed", "failure", ci.StatusFailure},
+		{"completed", "timed_out", ci.StatusFailure},
+		{"completed", "cancelled", ci.StatusCancelled},
+		{"completed", "stale", ci.StatusUnknown},
+	} {
+		require.Equal(t, tc.expected, status(tc.status, tc.conclusion),
+			"%s/%s", tc.status, tc.conclusion)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	var requests []string
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		auth = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/repos/foo/bar/actions/runs":
+			io.WriteString(w, `{"total_count": 40, "workflow_runs": [
+{"name": "CI", "head_branch": "main", "status": "in_progress", "html_url": "https://github.com/foo/bar/actions/runs/3"}
+]}`)
+		case "/repos/foo/bar/actions/workflows/build.yml/runs":
+			io.WriteString(w, `{"total_count": 1, "workflow_runs": [
+{"name": "Build", "head_branch": "release", "status": "completed", "conclusion": "failure"}
+]}`)
+		case "/repos/foo/bar/actions/workflows/new.yml/runs":
+			io.WriteString(w, `{"total_count": 0, "workflow_runs": []}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	baseURL = srv.URL
+
+	runs, err := New("foo/bar").Runs()
+	require.NoError(t, err)
+	require.Equal(t, []ci.Run{{
+		Name: "CI", Branch: "main", Status: ci.StatusPending,
+		URL: "https://github.com/foo/bar/actions/runs/3",
+	}}, runs)
+	require.Equal(t, []string{"/repos/foo/bar/actions/runs?per_page=1"}, requests)
+	require.Empty(t, auth)
+
+	requests = nil
+	runs, err = New("foo/bar", "build.yml", "new.yml").Branch("release").Token("secret").Runs()
+	require.NoError(t, err)
+	require.Equal(t, []ci.Run{{Name: "Build", Branch: "release", Status: ci.StatusFailure}}, runs)
+	require.Equal(t, []string{
+		"/repos/foo/bar/actions/workflows/build.yml/runs?branch=release&per_page=1",
+		"/repos/foo/bar/actions/workflows/new.yml/runs?branch=release&per_page=1",
+	}, requests)
+	require.Equal(t, "Bearer secret", auth)
+
+	_, err = New("foo/baz").Runs()
+	require.Error(t, err)
+	require.Contains(t, fmt.Sprintf("%v", err), "404")
+}
@@ -0,0 +1,183 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package clipboard provides an i3bar module that shows a preview of the contents
+of the clipboard or the primary selection, and can clear it, e.g. to make sure
+that a copied password does not stay there.
+
+Under X11, changes are detected using the XFixes extension. Under Wayland,
+wl-paste and wl-copy (from wl-clipboard) are used.
+*/
+package clipboard // import "barista.run/modules/clipboard"
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// maxText is the maximum number of bytes of text read from the clipboard.
+// Only a preview is needed, and the clipboard may contain a lot of text.
+const maxText = 1024
+
+// Info represents the contents of the clipboard.
+type Info struct {
+	// Text contains the text in the clipboard, up to the first 1 KiB. It is
+	// empty if the clipboard is empty or does not contain text.
+	Text string
+	// HasContent is true if the clipboard is not empty, even if it does not
+	// contain text (e.g. an image).
+	HasContent bool
+	clear      func()
+}
+
+// Preview returns the text in the clipboard on a single line, truncated to
+// the given number of characters.
+func (i Info) Preview(length int) string {
+	text := strings.Join(strings.Fields(i.Text), " ")
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	if length < 1 {
+		return ""
+	}
+	return strings.TrimRight(string([]rune(text)[:length-1]), " ") + "…"
+}
+
+// Clear clears the clipboard.
+func (i Info) Clear() {
+	i.clear()
+}
+
+// contents is the clipboard contents sent by a backend.
+type contents struct {
+	text       string
+	hasContent bool
+}
+
+// backend is a display server specific clipboard implementation.
+type backend interface {
+	// watch sends the clipboard contents on the updates channel, initially
+	// and every time they change, until done is closed.
+	watch(updates chan<- contents, done <-chan struct{}) error
+	// clear clears the clipboard.
+	clear() error
+}
+
+// Module represents a clipboard bar module.
+type Module struct {
+	backend    backend
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(selection string) *Module {
+	var b backend = x11{selection}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		b = wayland{primary: selection == "PRIMARY"}
+	}
+	m := &Module{backend: b}
+	l.Register(m, "outputFunc")
+	// Default output is a short preview of the clipboard contents, which
+	// clears the clipboard when clicked.
+	m.Output(func(i Info) bar.Output {
+		if !i.HasContent {
+			return nil
+		}
+		text := i.Preview(20)
+		if text == "" {
+			text = "(data)"
+		}
+		return outputs.Text("📋 " + text).OnClick(click.Left(i.Clear))
+	})
+	return m
+}
+
+// New creates a module that shows the contents of the clipboard.
+func New() *Module {
+	return newModule("CLIPBOARD")
+}
+
+// Primary creates a module that shows the contents of the primary selection,
+// i.e. the most recently selected text.
+func Primary() *Module {
+	return newModule("PRIMARY")
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	done := make(chan struct{})
+	defer close(done)
+	updates := make(chan contents)
+	errs := make(chan error, 1)
+	go func() { errs <- m.backend.watch(updates, done) }()
+
+	clear := func() {
+		if err := m.backend.clear(); err != nil {
+			l.Log("Failed to clear clipboard: %v", err)
+		}
+	}
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, doneSub := m.outputFunc.Subscribe()
+	defer doneSub()
+
+	var info *Info
+	for {
+		select {
+		case c := <-updates:
+			info = &Info{Text: c.text, HasContent: c.hasContent, clear: clear}
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case err := <-errs:
+			s.Error(err)
+			return
+		}
+		// Wait for the initial contents before the first output.
+		if info != nil {
+			s.Output(outputFunc(*info))
+		}
+	}
+}
+
+// send sends the contents on the updates channel, unless done is closed first.
+func send(updates chan<- contents, c contents, done <-chan struct{}) {
+	select {
+	case updates <- c:
+	case <-done:
+	}
+}
+
+// truncate limits text to maxText bytes, without splitting any characters.
+func truncate(text []byte) string {
+	if len(text) <= maxText {
+		return string(text)
+	}
+	cut := maxText
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return string(text[:cut])
+}
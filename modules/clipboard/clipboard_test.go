@@ -0,0 +1,181 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClipboard emulates wl-clipboard.
+type fakeClipboard struct {
+	sync.Mutex
+	types    string
+	text     string
+	commands []string
+	events   *io.PipeWriter
+}
+
+var clip = &fakeClipboard{}
+
+func (f *fakeClipboard) set(types, text string) {
+	f.Lock()
+	f.types, f.text = types, text
+	w := f.events
+	f.Unlock()
+	if w != nil {
+		fmt.Fprintln(w)
+	}
+}
+
+func (f *fakeClipboard) takeCommands() []string {
+	f.Lock()
+	defer f.Unlock()
+	c := f.commands
+	f.commands = nil
+	return c
+}
+
+func init() {
+	os.Setenv("WAYLAND_DISPLAY", "wayland-test")
+	command = func(name string, args ...string) ([]byte, error) {
+		clip.Lock()
+		defer clip.Unlock()
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		clip.commands = append(clip.commands, cmd)
+		switch strings.Replace(cmd, " --primary", "", 1) {
+		case "wl-paste --list-types":
+			if clip.types == "" {
+				return nil, errors.New("Nothing is copied")
+			}
+			return []byte(clip.types), nil
+		case "wl-paste --no-newline --type text":
+			if !strings.Contains(clip.types, "text/") {
+				return nil, errors.New("No suitable type of content copied")
+			}
+			return []byte(clip.text), nil
+		case "wl-copy --clear":
+			clip.types, clip.text = "", ""
+			go fmt.Fprintln(clip.events)
+			return nil, nil
+		}
+		return nil, errors.New("unexpected command")
+	}
+	start = func(name string, args ...string) (io.ReadCloser, error) {
+		clip.Lock()
+		defer clip.Unlock()
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		clip.commands = append(clip.commands, cmd)
+		r, w := io.Pipe()
+		clip.events = w
+		// wl-paste runs the command once on start.
+		go fmt.Fprintln(w)
+		return r, nil
+	}
+}
+
+func TestPreview(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		length   int
+		expected string
+	}{
+		{"", 10, ""},
+		{"hunter2", 10, "hunter2"},
+		{"  multiple\n\tlines  \n", 20, "multiple lines"},
+		{"a long line of text", 10, "a long li…"},
+		{"a long line of text", 8, "a long…"},
+		{"ünïcödé text", 5, "ünïc…"},
+		{"anything", 0, ""},
+	} {
+		require.Equal(t, tc.expected, Info{Text: tc.text}.Preview(tc.length),
+			"Preview(%d) of %q", tc.length, tc.text)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	require.Equal(t, "short", truncate([]byte("short")))
+	long := strings.Repeat("a", maxText-1) + "é"
+	require.Equal(t, strings.Repeat("a", maxText-1), truncate([]byte(long)),
+		"does not split characters")
+	require.Len(t, truncate([]byte(strings.Repeat("ab", maxText))), maxText)
+}
+
+func TestWayland(t *testing.T) {
+	clip.set("", "")
+	clip.takeCommands()
+	testBar.New(t)
+	testBar.Run(New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %q", i.HasContent, i.Text).
+			OnClick(func(bar.Event) { i.Clear() })
+	}))
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{`false ""`})
+	require.Equal(t,
+		[]string{"wl-paste --watch echo", "wl-paste --list-types"},
+		clip.takeCommands())
+
+	clip.set("text/plain;charset=utf-8\nUTF8_STRING\n", "hunter2")
+	out = testBar.NextOutput("on copy")
+	out.AssertText([]string{`true "hunter2"`})
+
+	clip.set("image/png\n", "")
+	testBar.NextOutput("on image copy").AssertText([]string{`true ""`})
+
+	clip.takeCommands()
+	out.At(0).LeftClick()
+	testBar.NextOutput("on clear").AssertText([]string{`false ""`})
+	require.Contains(t, clip.takeCommands(), "wl-copy --clear")
+
+	clip.Lock()
+	clip.events.CloseWithError(errors.New("foo"))
+	clip.Unlock()
+	testBar.NextOutput("on exit").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	clip.set("", "")
+	clip.takeCommands()
+	testBar.New(t)
+	testBar.Run(Primary())
+	testBar.NextOutput("on start").AssertEmpty()
+	require.Equal(t,
+		[]string{"wl-paste --primary --watch echo", "wl-paste --primary --list-types"},
+		clip.takeCommands())
+
+	clip.set("text/plain\n", "a password that is quite long")
+	out := testBar.NextOutput("on selection")
+	out.AssertText([]string{"📋 a password that is…"})
+
+	clip.set("image/png\n", "")
+	out = testBar.NextOutput("on image")
+	out.AssertText([]string{"📋 (data)"})
+
+	clip.takeCommands()
+	out.At(0).LeftClick()
+	testBar.NextOutput("on clear").AssertEmpty()
+	require.Contains(t, clip.takeCommands(), "wl-copy --primary --clear")
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clipboard
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// command runs a command and returns its output. It can be replaced in tests.
+var command = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// start starts a long-running command and returns its output. Closing the
+// reader stops the command. It can be replaced in tests.
+var start = func(name string, args ...string) (io.ReadCloser, error) {
+	cmd := exec.Command(name, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process{out, cmd}, nil
+}
+
+type process struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (p *process) Close() error {
+	p.cmd.Process.Kill()
+	p.ReadCloser.Close()
+	return p.cmd.Wait()
+}
+
+// wayland is a backend that uses wl-clipboard.
+type wayland struct {
+	primary bool
+}
+
+func (w wayland) args(args ...string) []string {
+	if w.primary {
+		return append([]string{"--primary"}, args...)
+	}
+	return args
+}
+
+func (w wayland) watch(updates chan<- contents, done <-chan struct{}) error {
+	// wl-paste runs the given command once on start, and then every time
+	// the clipboard changes, including when it is cleared. The command is
+	// only used for notifications, the contents are read separately.
+	events, err := start("wl-paste", w.args("--watch", "echo")...)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-done
+		events.Close()
+	}()
+	s := bufio.NewScanner(events)
+	for s.Scan() {
+		send(updates, w.contents(), done)
+	}
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return errors.New("wl-paste exited")
+}
+
+func (w wayland) contents() contents {
+	types, err := command("wl-paste", w.args("--list-types")...)
+	if err != nil || strings.TrimSpace(string(types)) == "" {
+		// wl-paste exits with an error if the clipboard is empty.
+		return contents{}
+	}
+	c := contents{hasContent: true}
+	// This fails if none of the offered types are text.
+	if text, err := command("wl-paste", w.args("--no-newline", "--type", "text")...); err == nil {
+		c.text = truncate(text)
+	}
+	return c
+}
+
+func (w wayland) clear() error {
+	_, err := command("wl-copy", w.args("--clear")...)
+	return err
+}
@@ -0,0 +1,150 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clipboard
+
+import (
+	"errors"
+
+	l "barista.run/logging"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xfixes"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// x11 is a backend that reads an X11 selection, using the XFixes extension
+// to be notified when the selection owner changes.
+type x11 struct {
+	selection string
+}
+
+func atom(conn *xgb.Conn, name string) (xproto.Atom, error) {
+	r, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, err
+	}
+	return r.Atom, nil
+}
+
+func (x x11) watch(updates chan<- contents, done <-chan struct{}) error {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-done
+		conn.Close()
+	}()
+	if err := xfixes.Init(conn); err != nil {
+		return err
+	}
+	// The server requires the version to be negotiated before any other
+	// XFixes requests are made.
+	if _, err := xfixes.QueryVersion(conn, 5, 0).Reply(); err != nil {
+		return err
+	}
+
+	// Selection contents are transferred to a property on a window owned by
+	// the requestor, so create a window that is never mapped.
+	screen := xproto.Setup(conn).DefaultScreen(conn)
+	win, err := xproto.NewWindowId(conn)
+	if err != nil {
+		return err
+	}
+	err = xproto.CreateWindowChecked(conn, 0, win, screen.Root,
+		0, 0, 1, 1, 0, xproto.WindowClassInputOnly, screen.RootVisual,
+		0, nil).Check()
+	if err != nil {
+		return err
+	}
+
+	atoms := map[string]xproto.Atom{}
+	for _, name := range []string{x.selection, "UTF8_STRING", "INCR", "BARISTA_SELECTION"} {
+		if atoms[name], err = atom(conn, name); err != nil {
+			return err
+		}
+	}
+	selection, property := atoms[x.selection], atoms["BARISTA_SELECTION"]
+	err = xfixes.SelectSelectionInputChecked(conn, win, selection,
+		xfixes.SelectionEventMaskSetSelectionOwner|
+			xfixes.SelectionEventMaskSelectionWindowDestroy|
+			xfixes.SelectionEventMaskSelectionClientClose).Check()
+	if err != nil {
+		return err
+	}
+
+	convert := func() {
+		xproto.ConvertSelection(conn, win, selection, atoms["UTF8_STRING"],
+			property, xproto.TimeCurrentTime)
+	}
+	convert()
+	for {
+		ev, xErr := conn.WaitForEvent()
+		if ev == nil && xErr == nil {
+			select {
+			case <-done:
+				return nil
+			default:
+				return errors.New("X11 connection closed")
+			}
+		}
+		if xErr != nil {
+			l.Log("clipboard: X11 error: %v", xErr)
+			continue
+		}
+		switch e := ev.(type) {
+		case xfixes.SelectionNotifyEvent:
+			if e.Owner == 0 {
+				send(updates, contents{}, done)
+			} else {
+				convert()
+			}
+		case xproto.SelectionNotifyEvent:
+			if e.Property == xproto.AtomNone {
+				// The conversion was refused, either because the selection
+				// has no owner, or because it cannot be converted to text.
+				owner, err := xproto.GetSelectionOwner(conn, selection).Reply()
+				hasOwner := err == nil && owner.Owner != 0
+				send(updates, contents{hasContent: hasOwner}, done)
+				continue
+			}
+			r, err := xproto.GetProperty(conn, true, win, property,
+				xproto.GetPropertyTypeAny, 0, maxText/4+1).Reply()
+			if err != nil {
+				return err
+			}
+			c := contents{hasContent: true}
+			// Large selections are transferred incrementally, which is not
+			// worth supporting just for a preview.
+			if r.Type != atoms["INCR"] {
+				c.text = truncate(r.Value)
+			}
+			send(updates, c, done)
+		}
+	}
+}
+
+func (x x11) clear() error {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	selection, err := atom(conn, x.selection)
+	if err != nil {
+		return err
+	}
+	return xproto.SetSelectionOwnerChecked(conn, 0, selection, xproto.TimeCurrentTime).Check()
+}
@@ -20,8 +20,10 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/actions"
 	"barista.run/base/value"
 	"barista.run/base/watchers/localtz"
+	"barista.run/i18n"
 	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -43,8 +45,10 @@ func (m *Module) getConfig() config {
 	return m.config.Get().(config)
 }
 
+// defaultOutput shows the time, and copies the full timestamp on click.
 func defaultOutput(now time.Time) bar.Output {
-	return outputs.Text(now.Format("15:04"))
+	return outputs.Text(now.Format("15:04")).
+		OnClick(actions.OnClickCopy(now.Format(time.RFC3339)))
 }
 
 // Zone constructs a clock module for the given timezone.
@@ -93,6 +97,7 @@ func (m *Module) Output(
 }
 
 // OutputFormat configures a module to display the time in a given format.
+// Names of days and months are shown in the current language (see i18n).
 func (m *Module) OutputFormat(format string) *Module {
 	granularity := time.Hour
 	switch {
@@ -108,7 +113,7 @@ func (m *Module) OutputFormat(format string) *Module {
 		granularity = time.Minute
 	}
 	return m.Output(granularity, func(now time.Time) bar.Output {
-		return outputs.Text(now.Format(format))
+		return outputs.Text(i18n.FormatTime(now, format))
 	})
 }
 
@@ -130,11 +135,10 @@ func (m *Module) Stream(s bar.Sink) {
 	defer done()
 
 	var tzChange <-chan struct{}
+	sch.EveryAlign(cfg.granularity, 0)
 
 	for {
 		now := timing.Now()
-		next := now.Add(cfg.granularity).Truncate(cfg.granularity)
-		sch.At(next)
 
 		if cfg.timezone == nil {
 			if tzChange == nil {
@@ -152,6 +156,7 @@ func (m *Module) Stream(s bar.Sink) {
 			tzChange = nil
 		case <-nextCfg:
 			cfg = m.getConfig()
+			sch.EveryAlign(cfg.granularity, 0)
 		}
 	}
 }
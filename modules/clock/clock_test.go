@@ -20,6 +20,7 @@ import (
 
 	"barista.run/bar"
 	"barista.run/base/watchers/localtz"
+	"barista.run/i18n"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
@@ -103,6 +104,12 @@ func TestAutoGranularities(t *testing.T) {
 		[]string{"00:01:00.11"}, "on next tick")
 
 	testBar.AssertNoOutput("when time is frozen")
+
+	i18n.SetLanguage("de")
+	defer i18n.SetLanguage("en")
+	local.OutputFormat("Monday 2. January")
+	testBar.NextOutput().AssertText(
+		[]string{"Mittwoch 1. März"}, "in the current language")
 }
 
 func TestManualGranularities(t *testing.T) {
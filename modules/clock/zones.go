@@ -0,0 +1,240 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/pango"
+	"barista.run/timing"
+)
+
+// Place is a labelled timezone shown by a multi-timezone clock.
+type Place struct {
+	Label    string
+	Location *time.Location
+}
+
+// PlaceByName returns a place for the given zone name (e.g. "Europe/London"),
+// and returns any errors.
+func PlaceByName(label, name string) (Place, error) {
+	tz, err := time.LoadLocation(name)
+	if err != nil {
+		return Place{}, err
+	}
+	return Place{label, tz}, nil
+}
+
+// ZoneTime is the time in a single place.
+type ZoneTime struct {
+	Place
+	Time time.Time
+	// Working is true if the time falls within working hours on a weekday.
+	Working bool
+}
+
+// ZonesInfo represents the current time in all places, and the currently
+// selected place when rotating.
+type ZonesInfo struct {
+	Times []ZoneTime
+	// Current is the index of the currently selected place.
+	Current int
+	// Rotating is true if only the current place should be displayed.
+	Rotating bool
+	rotate   func(int)
+}
+
+// Zone returns the time in the currently selected place.
+func (i ZonesInfo) Zone() ZoneTime {
+	return i.Times[i.Current]
+}
+
+// Next selects the next place.
+func (i ZonesInfo) Next() {
+	if i.rotate != nil {
+		i.rotate(1)
+	}
+}
+
+// Previous selects the previous place.
+func (i ZonesInfo) Previous() {
+	if i.rotate != nil {
+		i.rotate(-1)
+	}
+}
+
+// ZonesModule represents a clock bar module that shows the time in several
+// timezones at once.
+type ZonesModule struct {
+	places  []Place
+	config  value.Value
+	current value.Value // of int
+}
+
+type zonesConfig struct {
+	granularity time.Duration
+	outputFunc  func(ZonesInfo) bar.Output
+	workStart   time.Duration
+	workEnd     time.Duration
+	rotate      bool
+}
+
+func (m *ZonesModule) getConfig() zonesConfig {
+	return m.config.Get().(zonesConfig)
+}
+
+func defaultZonesOutput(i ZonesInfo) bar.Output {
+	times := i.Times
+	if i.Rotating {
+		times = []ZoneTime{i.Zone()}
+	}
+	out := pango.New()
+	for idx, t := range times {
+		if idx > 0 {
+			out.AppendText(" ")
+		}
+		zone := pango.Textf("%s %s", t.Label, t.Time.Format("15:04"))
+		if t.Working {
+			zone.Bold()
+		}
+		out.Append(zone)
+	}
+	if !i.Rotating {
+		return outputs.Pango(out)
+	}
+	return outputs.Pango(out).OnClick(click.Map{}.
+		ScrollUp(i.Previous).
+		ScrollDown(i.Next).
+		Handle)
+}
+
+// Zones constructs a clock module that shows the time in all of the given
+// places in a single segment. Places currently within working hours
+// (09:00 to 17:00 on weekdays by default) are highlighted.
+func Zones(places ...Place) *ZonesModule {
+	m := &ZonesModule{places: places}
+	l.Register(m, "config", "current")
+	m.current.Set(0)
+	m.config.Set(zonesConfig{
+		granularity: time.Minute,
+		outputFunc:  defaultZonesOutput,
+		workStart:   9 * time.Hour,
+		workEnd:     17 * time.Hour,
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+// The granularity works the same way as for single timezone clocks.
+func (m *ZonesModule) Output(
+	granularity time.Duration,
+	outputFunc func(ZonesInfo) bar.Output,
+) *ZonesModule {
+	c := m.getConfig()
+	c.granularity = granularity
+	c.outputFunc = outputFunc
+	m.config.Set(c)
+	return m
+}
+
+// WorkingHours sets the start and end of working hours, as offsets from
+// midnight in each place.
+func (m *ZonesModule) WorkingHours(start, end time.Duration) *ZonesModule {
+	c := m.getConfig()
+	c.workStart = start
+	c.workEnd = end
+	m.config.Set(c)
+	return m
+}
+
+// Rotate configures the module to show one place at a time, switching
+// between them on scroll.
+func (m *ZonesModule) Rotate(rotate bool) *ZonesModule {
+	c := m.getConfig()
+	c.rotate = rotate
+	m.config.Set(c)
+	return m
+}
+
+func (m *ZonesModule) rotate(delta int) {
+	if len(m.places) == 0 {
+		return
+	}
+	cur := (m.current.Get().(int) + delta) % len(m.places)
+	if cur < 0 {
+		cur += len(m.places)
+	}
+	m.current.Set(cur)
+}
+
+func (c zonesConfig) working(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	h, min, sec := t.Clock()
+	offset := time.Duration(h)*time.Hour +
+		time.Duration(min)*time.Minute +
+		time.Duration(sec)*time.Second
+	return offset >= c.workStart && offset < c.workEnd
+}
+
+// Stream starts the module.
+func (m *ZonesModule) Stream(s bar.Sink) {
+	sch := timing.NewScheduler()
+	l.Attach(m, sch, ".scheduler")
+
+	cfg := m.getConfig()
+	nextCfg, done := m.config.Subscribe()
+	defer done()
+	nextCurrent, doneCurrent := m.current.Subscribe()
+	defer doneCurrent()
+	sch.EveryAlign(cfg.granularity, 0)
+
+	for {
+		now := timing.Now()
+
+		if len(m.places) == 0 {
+			s.Output(nil)
+		} else {
+			info := ZonesInfo{
+				Times:    make([]ZoneTime, len(m.places)),
+				Current:  m.current.Get().(int),
+				Rotating: cfg.rotate,
+				rotate:   m.rotate,
+			}
+			for idx, p := range m.places {
+				t := now
+				if p.Location != nil {
+					t = now.In(p.Location)
+				}
+				info.Times[idx] = ZoneTime{p, t, cfg.working(t)}
+			}
+			s.Output(cfg.outputFunc(info))
+		}
+
+		select {
+		case <-sch.C:
+		case <-nextCurrent:
+		case <-nextCfg:
+			cfg = m.getConfig()
+			sch.EveryAlign(cfg.granularity, 0)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testPlaces(t *testing.T) []Place {
+	var places []Place
+	for _, p := range [][2]string{
+		{"SF", "America/Los_Angeles"},
+		{"LON", "Europe/London"},
+		{"BLR", "Asia/Kolkata"},
+	} {
+		place, err := PlaceByName(p[0], p[1])
+		require.NoError(t, err)
+		places = append(places, place)
+	}
+	return places
+}
+
+func TestPlaceByName(t *testing.T) {
+	_, err := PlaceByName("Nowhere", "Not/A_Timezone")
+	require.Error(t, err)
+}
+
+func TestMultipleZones(t *testing.T) {
+	testBar.New(t)
+	// Wednesday, 16:00 UTC.
+	timing.AdvanceTo(time.Date(2017, time.March, 1, 16, 0, 0, 0, time.UTC))
+
+	m := Zones(testPlaces(t)...).Output(time.Minute, func(i ZonesInfo) bar.Output {
+		var zones []string
+		for _, z := range i.Times {
+			zones = append(zones, fmt.Sprintf("%s %s %v",
+				z.Label, z.Time.Format("15:04"), z.Working))
+		}
+		return outputs.Text(strings.Join(zones, ", ")).
+			OnClick(func(bar.Event) { i.Next() })
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{
+		"SF 08:00 false, LON 16:00 true, BLR 21:30 false"})
+
+	timing.NextTick()
+	testBar.NextOutput("on tick").AssertText([]string{
+		"SF 08:01 false, LON 16:01 true, BLR 21:31 false"})
+
+	m.WorkingHours(8*time.Hour, 22*time.Hour)
+	testBar.NextOutput("on working hours change").AssertText([]string{
+		"SF 08:01 true, LON 16:01 true, BLR 21:31 true"})
+
+	// Saturday. Advancing steps through every minute in between, so the
+	// module may output some intermediate times before the final one.
+	timing.AdvanceTo(time.Date(2017, time.March, 4, 12, 0, 0, 0, time.UTC))
+	testBar.Drain(50*time.Millisecond, "on weekend").AssertText([]string{
+		"SF 04:00 false, LON 12:00 false, BLR 17:30 false"})
+}
+
+func TestZonesDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(time.Date(2017, time.March, 1, 16, 0, 0, 0, time.UTC))
+
+	m := Zones(testPlaces(t)...)
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{
+		"SF 08:00 <span weight='bold'>LON 16:00</span> BLR 21:30"})
+
+	m.Rotate(true)
+	out = testBar.NextOutput("on rotate")
+	out.AssertText([]string{"SF 08:00"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll down")
+	out.AssertText([]string{"<span weight='bold'>LON 16:00</span>"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll up")
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput("wraps around").AssertText([]string{"BLR 21:30"})
+
+	testBar.New(t)
+	testBar.Run(Zones())
+	testBar.NextOutput("without places").AssertEmpty()
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	l "barista.run/logging"
+)
+
+// apiBackend is a Backend that uses a docker-compatible REST API served
+// over a unix socket. Both docker and podman provide such an API.
+type apiBackend struct {
+	socket string
+	path   string
+	client *http.Client
+}
+
+func newAPIBackend(socket, path string) *apiBackend {
+	l.Fine("container: using socket %s", socket)
+	return &apiBackend{
+		socket: socket,
+		path:   path,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// apiContainer represents a container in the json response. The docker and
+// libpod APIs differ in capitalisation of the ID, but encoding/json matches
+// field names case-insensitively, so a single struct handles both.
+type apiContainer struct {
+	ID     string
+	Names  []string
+	Image  string
+	State  string
+	Status string
+}
+
+func (a *apiBackend) Containers() (Info, error) {
+	// The host is ignored since all requests go over the socket.
+	r, err := a.client.Get("http://localhost" + a.path + "?all=true")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Status %d", r.StatusCode)
+	}
+	var resp []apiContainer
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	info := make(Info, 0, len(resp))
+	for _, c := range resp {
+		ctr := Container{
+			ID:     c.ID,
+			Image:  c.Image,
+			State:  State(strings.ToLower(c.State)),
+			Status: c.Status,
+		}
+		if len(c.Names) > 0 {
+			// Docker prefixes names with '/', podman does not.
+			ctr.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		info = append(info, ctr)
+	}
+	return info, nil
+}
+
+// for tests.
+var getenv = os.Getenv
+var getuid = os.Getuid
+var fileExists = func(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Docker returns a backend that uses the docker daemon. The socket is read
+// from $DOCKER_HOST if set to a unix:// address, and defaults to
+// /var/run/docker.sock otherwise.
+func Docker() Backend {
+	socket := "/var/run/docker.sock"
+	if host := getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		socket = strings.TrimPrefix(host, "unix://")
+	}
+	return DockerSocket(socket)
+}
+
+// DockerSocket returns a backend that uses the docker API at the given socket.
+func DockerSocket(socket string) Backend {
+	return newAPIBackend(socket, "/containers/json")
+}
+
+// Podman returns a backend that uses the podman REST service. It prefers the
+// rootless socket for the current user, and falls back to the system socket.
+// $CONTAINER_HOST, if set to a unix:// address, overrides autodetection.
+func Podman() Backend {
+	return PodmanSocket(podmanSocket())
+}
+
+// PodmanSocket returns a backend that uses the podman API at the given socket.
+func PodmanSocket(socket string) Backend {
+	return newAPIBackend(socket, "/v1.0.0/libpod/containers/json")
+}
+
+func podmanSocket() string {
+	if host := getenv("CONTAINER_HOST"); strings.HasPrefix(host, "unix://") {
+		return strings.TrimPrefix(host, "unix://")
+	}
+	runtimeDir := getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", getuid())
+	}
+	rootless := filepath.Join(runtimeDir, "podman", "podman.sock")
+	if fileExists(rootless) {
+		return rootless
+	}
+	return "/run/podman/podman.sock"
+}
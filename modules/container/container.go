@@ -0,0 +1,165 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package container provides an i3bar module that shows the state of containers
+managed by a container engine.
+
+The engine is pluggable: Docker() talks to the docker daemon, while Podman()
+talks to the podman REST service, automatically finding the rootless socket
+for the current user and falling back to the system-wide socket.
+*/
+package container // import "barista.run/modules/container"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// State represents the state of a container.
+type State string
+
+const (
+	// Created represents a container that has been created but not started.
+	Created State = "created"
+	// Running represents a running container.
+	Running State = "running"
+	// Paused represents a container whose processes have been paused.
+	Paused State = "paused"
+	// Restarting represents a container in the process of restarting.
+	Restarting State = "restarting"
+	// Exited represents a container that is no longer running.
+	Exited State = "exited"
+	// Dead represents a container that could not be stopped cleanly.
+	Dead State = "dead"
+)
+
+// Container represents a single container.
+type Container struct {
+	ID     string
+	Name   string
+	Image  string
+	State  State
+	Status string
+}
+
+// Info represents the containers known to the engine.
+type Info []Container
+
+// Count returns the number of containers in the given state.
+func (i Info) Count(state State) int {
+	c := 0
+	for _, ctr := range i {
+		if ctr.State == state {
+			c++
+		}
+	}
+	return c
+}
+
+// Running returns the number of running containers.
+func (i Info) Running() int {
+	return i.Count(Running)
+}
+
+// Find returns the container with the given name, and whether it was found.
+func (i Info) Find(name string) (Container, bool) {
+	for _, ctr := range i {
+		if ctr.Name == name {
+			return ctr, true
+		}
+	}
+	return Container{}, false
+}
+
+// Backend is an interface for container engines.
+type Backend interface {
+	// Containers returns all containers, including stopped ones.
+	Containers() (Info, error)
+}
+
+// Module represents a bar.Module that displays container information.
+type Module struct {
+	backend    Backend
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs an instance of the container module using the given backend.
+func New(backend Backend) *Module {
+	m := &Module{
+		backend:   backend,
+		scheduler: timing.NewScheduler(),
+	}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the number of running containers, if any.
+	m.Output(func(i Info) bar.Output {
+		if i.Running() == 0 {
+			return nil
+		}
+		return outputs.Textf("%d running", i.Running())
+	})
+	m.RefreshInterval(10 * time.Second)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Refresh fetches updated container information.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.backend.Containers()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.backend.Containers()
+		case <-m.refreshCh:
+			if err != nil {
+				s(nil)
+			}
+			info, err = m.backend.Containers()
+		}
+	}
+}
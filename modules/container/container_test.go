@@ -0,0 +1,194 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	responses   = map[string]string{}
+	responsesMu sync.Mutex
+)
+
+func respondWith(path, body string) {
+	responsesMu.Lock()
+	defer responsesMu.Unlock()
+	responses[path] = body
+}
+
+func serveSocket(t *testing.T) (socket string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "container-test")
+	require.NoError(t, err)
+	socket = filepath.Join(dir, "api.sock")
+	lis, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			responsesMu.Lock()
+			body, ok := responses[r.URL.Path]
+			responsesMu.Unlock()
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			require.Equal(t, "true", r.URL.Query().Get("all"))
+			io.WriteString(w, body)
+		})}
+	go srv.Serve(lis)
+	return socket, func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDocker(t *testing.T) {
+	socket, cleanup := serveSocket(t)
+	defer cleanup()
+
+	respondWith("/containers/json", `[
+{"Id": "abc", "Names": ["/web"], "Image": "nginx", "State": "running", "Status": "Up 2 hours"},
+{"Id": "def", "Names": ["/db"], "Image": "postgres", "State": "exited", "Status": "Exited (0)"}
+]`)
+	info, err := DockerSocket(socket).Containers()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		{ID: "abc", Name: "web", Image: "nginx", State: Running, Status: "Up 2 hours"},
+		{ID: "def", Name: "db", Image: "postgres", State: Exited, Status: "Exited (0)"},
+	}, info)
+	require.Equal(t, 1, info.Running())
+	require.Equal(t, 1, info.Count(Exited))
+	db, ok := info.Find("db")
+	require.True(t, ok)
+	require.Equal(t, "postgres", db.Image)
+	_, ok = info.Find("cache")
+	require.False(t, ok)
+
+	respondWith("/containers/json", `not-json`)
+	_, err = DockerSocket(socket).Containers()
+	require.Error(t, err, "bad json")
+
+	_, err = PodmanSocket(socket).Containers()
+	require.Error(t, err, "404 for libpod endpoint")
+
+	_, err = DockerSocket(socket + ".missing").Containers()
+	require.Error(t, err, "missing socket")
+}
+
+func TestPodman(t *testing.T) {
+	socket, cleanup := serveSocket(t)
+	defer cleanup()
+
+	respondWith("/v1.0.0/libpod/containers/json", `[
+{"ID": "123", "Names": ["toolbox"], "Image": "fedora-toolbox", "State": "running", "Status": "Up"},
+{"ID": "456", "Names": ["build"], "Image": "golang", "State": "paused", "Status": "Paused"}
+]`)
+	info, err := PodmanSocket(socket).Containers()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		{ID: "123", Name: "toolbox", Image: "fedora-toolbox", State: Running, Status: "Up"},
+		{ID: "456", Name: "build", Image: "golang", State: Paused, Status: "Paused"},
+	}, info)
+}
+
+func TestSocketDetection(t *testing.T) {
+	env := map[string]string{}
+	existing := map[string]bool{}
+	getenv = func(k string) string { return env[k] }
+	getuid = func() int { return 1000 }
+	fileExists = func(p string) bool { return existing[p] }
+
+	require.Equal(t, "/run/podman/podman.sock", podmanSocket(),
+		"falls back to system socket")
+
+	existing["/run/user/1000/podman/podman.sock"] = true
+	require.Equal(t, "/run/user/1000/podman/podman.sock", podmanSocket(),
+		"rootless socket from uid")
+
+	env["XDG_RUNTIME_DIR"] = "/tmp/runtime"
+	require.Equal(t, "/run/podman/podman.sock", podmanSocket(),
+		"uses XDG_RUNTIME_DIR when set")
+
+	existing["/tmp/runtime/podman/podman.sock"] = true
+	require.Equal(t, "/tmp/runtime/podman/podman.sock", podmanSocket())
+
+	env["CONTAINER_HOST"] = "unix:///custom/podman.sock"
+	require.Equal(t, "/custom/podman.sock", podmanSocket(),
+		"CONTAINER_HOST overrides detection")
+
+	require.Equal(t, "/var/run/docker.sock", Docker().(*apiBackend).socket)
+	env["DOCKER_HOST"] = "unix:///custom/docker.sock"
+	require.Equal(t, "/custom/docker.sock", Docker().(*apiBackend).socket)
+	env["DOCKER_HOST"] = "tcp://127.0.0.1:2375"
+	require.Equal(t, "/var/run/docker.sock", Docker().(*apiBackend).socket,
+		"non-unix DOCKER_HOST is ignored")
+}
+
+type testBackend struct {
+	sync.Mutex
+	info Info
+	err  error
+}
+
+func (t *testBackend) Containers() (Info, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.info, t.err
+}
+
+func (t *testBackend) set(info Info, err error) {
+	t.Lock()
+	defer t.Unlock()
+	t.info, t.err = info, err
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	b := &testBackend{}
+	m := New(b)
+	testBar.Run(m)
+
+	testBar.NextOutput().AssertEmpty("no running containers")
+
+	b.set(Info{{Name: "a", State: Running}, {Name: "b", State: Running}}, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"2 running"}, "on tick")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d", i.Running(), len(i))
+	})
+	testBar.NextOutput().AssertText([]string{"2/2"}, "on output change")
+
+	b.set(nil, os.ErrNotExist)
+	m.Refresh()
+	testBar.NextOutput().AssertError("on refresh with error")
+
+	b.set(Info{{Name: "a", State: Exited}}, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"0/1"}, "recovers from error")
+}
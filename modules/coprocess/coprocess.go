@@ -0,0 +1,283 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package coprocess provides a module backed by a long running child process,
+which can be written in any language, using newline-delimited JSON.
+
+The process writes its output to stdout whenever it changes, one line at a
+time. Each line is either a single block or an array of blocks, using the keys
+of the i3bar protocol (full_text, short_text, color, background, border,
+min_width, align, urgent, separator, separator_block_width, markup) as well as
+name and instance, which identify blocks in click events, and error, which
+shows the block as an error. An empty array hides the module.
+
+Clicks on any block are written to the process's stdin as a single line of
+JSON, with the name and instance of the block that was clicked, the button,
+and the position (x, y, relative_x, relative_y, width, height) as in i3bar.
+For example, a module in Python:
+
+	import json, sys
+
+	count = 0
+	def show():
+	    print(json.dumps({"full_text": "Clicks: %d" % count, "name": "counter"}),
+	          flush=True)
+
+	show()
+	for line in sys.stdin:
+	    click = json.loads(line)
+	    count += 1 if click["button"] == 1 else -1
+	    show()
+
+Anything the process writes to stderr is included in the error if it exits with
+a non-zero status. If the process exits successfully, the last output remains
+on the bar.
+*/
+package coprocess // import "barista.run/modules/coprocess"
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	l "barista.run/logging"
+)
+
+// maxLineSize is the longest line of output that the process can write.
+var maxLineSize = 1024 * 1024
+
+// Module represents a coprocess module.
+type Module struct {
+	cmd    string
+	args   []string
+	env    []string
+	clicks chan click
+}
+
+// click is a click event along with the block that was clicked.
+type click struct {
+	Name     string `json:"name,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	bar.Event
+}
+
+// New constructs a module that runs the given command, and shows the blocks it
+// writes to stdout.
+func New(cmd string, args ...string) *Module {
+	m := &Module{cmd: cmd, args: args, clicks: make(chan click, 10)}
+	l.Label(m, cmd)
+	return m
+}
+
+// Env adds an environment variable for the process. Must be called before the
+// module is streamed.
+func (m *Module) Env(key, value string) *Module {
+	m.env = append(m.env, key+"="+value)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	cmd := exec.Command(m.cmd, m.args...)
+	// Prevent SIGUSR for bar pause/resume from propagating to the
+	// child process, as with shell.Tail.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Env = append(os.Environ(), m.env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if s.Error(err) {
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if s.Error(err) {
+		return
+	}
+	if s.Error(cmd.Start()) {
+		return
+	}
+	defer stdin.Close()
+
+	lines := make(chan []byte)
+	done := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(nil, maxLineSize)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+		close(lines)
+		scanErr := scanner.Err()
+		if scanErr != nil {
+			// Nothing reads stdout any more, so the process (or its
+			// children) could block writing to it and never exit.
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		err := cmd.Wait()
+		if scanErr != nil {
+			err = fmt.Errorf("failed to read output: %v", scanErr)
+		} else if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		done <- err
+	}()
+	encoder := json.NewEncoder(stdin)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// Wait for the process to exit.
+				lines = nil
+				continue
+			}
+			out, err := m.parse(line)
+			if s.Error(err) {
+				cmd.Process.Kill()
+				go func() {
+					for range lines {
+					}
+				}()
+				return
+			}
+			s.Output(out)
+		case c := <-m.clicks:
+			if err := encoder.Encode(c); err != nil {
+				l.Log("%s: failed to send click: %v", l.ID(m), err)
+			}
+		case err := <-done:
+			s.Error(err)
+			return
+		}
+	}
+}
+
+func (m *Module) click(c click) {
+	select {
+	case m.clicks <- c:
+	default:
+		// Clicks faster than the process can handle are dropped.
+	}
+}
+
+// block is a single block of output, in the i3bar format.
+type block struct {
+	FullText            string      `json:"full_text"`
+	ShortText           *string     `json:"short_text"`
+	Markup              string      `json:"markup"`
+	Color               string      `json:"color"`
+	Background          string      `json:"background"`
+	Border              string      `json:"border"`
+	MinWidth            interface{} `json:"min_width"`
+	Align               string      `json:"align"`
+	Urgent              *bool       `json:"urgent"`
+	Separator           *bool       `json:"separator"`
+	SeparatorBlockWidth *int        `json:"separator_block_width"`
+	Name                string      `json:"name"`
+	Instance            string      `json:"instance"`
+	Error               string      `json:"error"`
+}
+
+// parse parses a line of output, either a single block or an array of blocks.
+func (m *Module) parse(line []byte) (bar.Output, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, nil
+	}
+	var blocks []block
+	var err error
+	if line[0] == '[' {
+		err = json.Unmarshal(line, &blocks)
+	} else {
+		blocks = make([]block, 1)
+		err = json.Unmarshal(line, &blocks[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid output %q: %v", line, err)
+	}
+	out := bar.Segments{}
+	for _, b := range blocks {
+		seg, err := m.segment(b)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, seg)
+	}
+	return out, nil
+}
+
+func (m *Module) segment(b block) (*bar.Segment, error) {
+	if b.Error != "" {
+		return bar.ErrorSegment(errors.New(b.Error)), nil
+	}
+	seg := bar.TextSegment(b.FullText)
+	if b.Markup == "pango" {
+		seg = bar.PangoSegment(b.FullText)
+	}
+	if b.ShortText != nil {
+		seg.ShortText(*b.ShortText)
+	}
+	for _, c := range []struct {
+		value string
+		set   func(color.Color) *bar.Segment
+	}{
+		{b.Color, seg.Color},
+		{b.Background, seg.Background},
+		{b.Border, seg.Border},
+	} {
+		if c.value == "" {
+			continue
+		}
+		col := colors.Hex(c.value)
+		if col == nil {
+			return nil, fmt.Errorf("invalid color %q", c.value)
+		}
+		c.set(col)
+	}
+	switch w := b.MinWidth.(type) {
+	case nil:
+	case float64:
+		seg.MinWidth(int(w))
+	case string:
+		seg.MinWidthPlaceholder(w)
+	default:
+		return nil, fmt.Errorf("invalid min_width %v", w)
+	}
+	if b.Align != "" {
+		seg.Align(bar.TextAlignment(b.Align))
+	}
+	if b.Urgent != nil {
+		seg.Urgent(*b.Urgent)
+	}
+	if b.Separator != nil {
+		seg.Separator(*b.Separator)
+	}
+	if b.SeparatorBlockWidth != nil {
+		seg.Padding(*b.SeparatorBlockWidth)
+	}
+	name, instance := b.Name, b.Instance
+	seg.OnClick(func(e bar.Event) {
+		m.click(click{name, instance, e})
+	})
+	return seg, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coprocess
+
+import (
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// echoClicks outputs a block, and then each click it receives as full text.
+const echoClicks = `
+echo '[{"full_text": "a", "name": "first"}, {"full_text": "b", "instance": "2"}]'
+while read click; do
+  echo "{\"full_text\": \"$(echo "$click" | tr -d '"{}')\"}"
+done`
+
+func TestCoprocess(t *testing.T) {
+	testBar.New(t)
+	m := New("sh", "-c", echoClicks)
+	testBar.Run(m)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"a", "b"})
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight, X: 3, Width: 10})
+	testBar.NextOutput("on click").At(0).AssertText(
+		"name:first,button:3,relative_x:3,width:10", "click is sent as json")
+
+	out.At(1).LeftClick()
+	testBar.NextOutput("on click").At(0).AssertText(
+		"instance:2,button:1", "click includes instance")
+}
+
+func TestBlocks(t *testing.T) {
+	testBar.New(t)
+	m := New("sh", "-c", `
+echo '{"full_text": "<b>a</b>", "short_text": "", "markup": "pango",
+  "color": "#ff0000", "min_width": 100, "align": "center", "urgent": true,
+  "separator": false, "separator_block_width": 0}' | tr -d '\n'
+echo
+read x
+echo '{"full_text": "b", "min_width": "placeholder", "background": "#00ff00"}'
+read x
+echo '[]'
+read x
+echo '{"error": "something failed"}'
+`)
+	testBar.Run(m)
+
+	out := testBar.NextOutput("on start")
+	seg := out.At(0).Segment()
+	txt, isPango := seg.Content()
+	require.Equal(t, "<b>a</b>", txt)
+	require.True(t, isPango)
+	short, ok := seg.GetShortText()
+	require.True(t, ok)
+	require.Equal(t, "", short)
+	col, _ := seg.GetColor()
+	require.Equal(t, colors.Hex("#ff0000"), col)
+	minWidth, _ := seg.GetMinWidth()
+	require.Equal(t, 100, minWidth)
+	align, _ := seg.GetAlignment()
+	require.Equal(t, bar.AlignCenter, align)
+	urgent, _ := seg.IsUrgent()
+	require.True(t, urgent)
+	sep, _ := seg.HasSeparator()
+	require.False(t, sep)
+	padding, _ := seg.GetPadding()
+	require.Equal(t, 0, padding)
+
+	m.click(click{})
+	out = testBar.NextOutput("on update")
+	seg = out.At(0).Segment()
+	minWidth, _ = seg.GetMinWidth()
+	require.Equal(t, "placeholder", minWidth)
+	bg, _ := seg.GetBackground()
+	require.Equal(t, colors.Hex("#00ff00"), bg)
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on empty array").AssertEmpty()
+
+	m.click(click{})
+	errs := testBar.NextOutput("on error").AssertError()
+	require.Equal(t, []string{"something failed"}, errs)
+}
+
+func TestErrors(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(New("sh", "-c", `echo '{"full_text": "ok"}'; sleep 0.05; echo 'not json'`))
+	testBar.NextOutput("on start").AssertText([]string{"ok"})
+	errs := testBar.NextOutput("on invalid output").AssertError()
+	require.Contains(t, errs[0], "not json")
+
+	testBar.New(t)
+	testBar.Run(New("sh", "-c", `echo '{"color": "not-a-color"}'`))
+	errs = testBar.NextOutput("on invalid color").AssertError()
+	require.Contains(t, errs[0], "not-a-color")
+
+	testBar.New(t)
+	testBar.Run(New("sh", "-c", `echo "it broke" >&2; exit 3`))
+	errs = testBar.NextOutput("on exit").AssertError()
+	require.Contains(t, errs[0], "exit status 3: it broke")
+
+	testBar.New(t)
+	testBar.Run(New("sh", "-c", `echo '{"full_text": "done"}'`).Env("FOO", "bar"))
+	testBar.NextOutput("on start").AssertText([]string{"done"})
+	testBar.NextOutput("on successful exit").AssertText([]string{"done"},
+		"keeps the last output")
+
+	testBar.New(t)
+	testBar.Run(New("sh", "-c", `echo "{\"full_text\": \"$FOO\"}"; read x`).Env("FOO", "bar"))
+	testBar.NextOutput("with env").AssertText([]string{"bar"})
+
+	testBar.New(t)
+	testBar.Run(New("sh", "-c", `head -c 2000000 /dev/zero | tr '\0' a; echo; read x`))
+	errs = testBar.NextOutput("on a line that is too long").AssertError()
+	require.Contains(t, errs[0], "token too long")
+
+	testBar.New(t)
+	testBar.Run(New("this-is-not-a-valid-command"))
+	testBar.NextOutput().AssertError("when starting an invalid command")
+}
@@ -23,6 +23,7 @@ import (
 
 	"barista.run/bar"
 	"barista.run/base/value"
+	"barista.run/base/watchers/sysfs"
 	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -100,6 +101,10 @@ func (m *Module) Stream(s bar.Sink) {
 	outputFunc := m.outputFunc.Get().(func(unit.Temperature) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
+	// Temperatures need polling, but crossing a trip point generates a
+	// uevent, which is worth showing immediately.
+	events := sysfs.Subscribe("thermal")
+	defer events.Unsubscribe()
 	for {
 		if s.Error(err) {
 			return
@@ -108,6 +113,8 @@ func (m *Module) Stream(s bar.Sink) {
 		select {
 		case <-m.scheduler.C:
 			temp, err = getTemperature(m.thermalFile)
+		case <-events.Updates:
+			temp, err = getTemperature(m.thermalFile)
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(unit.Temperature) bar.Output)
 		}
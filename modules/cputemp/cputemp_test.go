@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/watchers/sysfs"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 
@@ -145,3 +146,17 @@ func TestDefaultZoneDetection(t *testing.T) {
 	out.At(0).AssertError("temperature missing")
 	out.At(1).AssertError("no zone of type")
 }
+
+func TestUevents(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	uevents := sysfs.TestMode()
+	testBar.New(t)
+
+	shouldReturn("48800")
+	testBar.Run(Zone("thermal_zone0").RefreshInterval(time.Hour))
+	testBar.NextOutput().AssertText([]string{"48.8℃"})
+
+	shouldReturn("95000")
+	uevents.Uevent("change", "thermal", "thermal_zone0")
+	testBar.NextOutput("on thermal uevent").AssertText([]string{"95.0℃"})
+}
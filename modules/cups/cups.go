@@ -0,0 +1,259 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package cups provides an i3bar module that shows the state of printers and
+their queued jobs, using the IPP protocol to query a CUPS server.
+
+By default, the module is hidden while all printers are idle, and clicking on
+it opens the CUPS web interface.
+*/
+package cups // import "barista.run/modules/cups"
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// State represents the state of a printer.
+type State int
+
+// Printer states, as defined by IPP.
+const (
+	Idle       State = 3
+	Processing State = 4
+	Stopped    State = 5
+)
+
+// Printer represents the state of a single printer.
+type Printer struct {
+	Name  string
+	State State
+	// Reasons contains additional information about the printer's state,
+	// e.g. "media-empty-error" or "toner-low-warning".
+	Reasons []string
+	// Message is a human readable description of the printer's state.
+	Message string
+	// Jobs is the number of jobs queued for the printer.
+	Jobs int
+}
+
+// HasReason returns true if the printer's state includes the given reason,
+// ignoring the severity suffix, e.g. HasReason("media-empty") matches
+// "media-empty-error".
+func (p Printer) HasReason(reason string) bool {
+	for _, r := range p.Reasons {
+		for _, suffix := range []string{"-error", "-warning", "-report"} {
+			r = strings.TrimSuffix(r, suffix)
+		}
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Paused returns true if the printer is not accepting jobs for printing.
+func (p Printer) Paused() bool {
+	return p.State == Stopped || p.HasReason("paused")
+}
+
+// OutOfPaper returns true if the printer has run out of paper.
+func (p Printer) OutOfPaper() bool {
+	return p.HasReason("media-empty") || p.HasReason("media-needed")
+}
+
+// Error returns true if the printer is reporting an error.
+func (p Printer) Error() bool {
+	for _, r := range p.Reasons {
+		if strings.HasSuffix(r, "-error") {
+			return true
+		}
+	}
+	return false
+}
+
+// Idle returns true if the printer has no queued jobs and no problems.
+func (p Printer) Idle() bool {
+	return p.Jobs == 0 && !p.Paused() && !p.OutOfPaper() && !p.Error()
+}
+
+// Info represents the state of all selected printers.
+type Info struct {
+	Printers []Printer
+	// WebURL is the address of the CUPS web interface.
+	WebURL string
+}
+
+// Jobs returns the total number of queued jobs across all printers.
+func (i Info) Jobs() int {
+	jobs := 0
+	for _, p := range i.Printers {
+		jobs += p.Jobs
+	}
+	return jobs
+}
+
+// Idle returns true if all printers are idle.
+func (i Info) Idle() bool {
+	for _, p := range i.Printers {
+		if !p.Idle() {
+			return false
+		}
+	}
+	return true
+}
+
+// Module represents a CUPS bar module.
+type Module struct {
+	printers   []string
+	server     value.Value // of string
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a module that shows the state of the given printers, or of all
+// printers if none are given.
+func New(printers ...string) *Module {
+	m := &Module{printers: printers, scheduler: timing.NewScheduler()}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "server", "scheduler")
+	server := os.Getenv("CUPS_SERVER")
+	if server == "" || strings.HasPrefix(server, "/") {
+		// A socket path cannot be used for the web interface, so fall back
+		// to the default port on localhost.
+		server = "localhost:631"
+	}
+	m.Server(server)
+	// Default output shows the number of queued jobs and any printer
+	// problems, and is hidden while all printers are idle.
+	m.Output(func(i Info) bar.Output {
+		if i.Idle() {
+			return nil
+		}
+		var parts []string
+		if jobs := i.Jobs(); jobs > 0 {
+			parts = append(parts, fmt.Sprintf("%d jobs", jobs))
+		}
+		urgent := false
+		for _, p := range i.Printers {
+			var problem string
+			switch {
+			case p.OutOfPaper():
+				problem = "no paper"
+			case p.Error():
+				problem = "error"
+			case p.Paused():
+				problem = "paused"
+			default:
+				continue
+			}
+			parts = append(parts, p.Name+": "+problem)
+			urgent = true
+		}
+		return outputs.Text("🖶 " + strings.Join(parts, ", ")).
+			Urgent(urgent).
+			OnClick(click.RunLeft("xdg-open", i.WebURL))
+	})
+	m.RefreshInterval(30 * time.Second)
+	return m
+}
+
+// Server configures the address (host:port) of the CUPS server.
+// The default is the CUPS_SERVER environment variable, or localhost:631.
+func (m *Module) Server(server string) *Module {
+	m.server.Set(server)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Refresh fetches updated printer information.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.getInfo()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextServer, doneServer := m.server.Subscribe()
+	defer doneServer()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextServer:
+			info, err = m.getInfo()
+		case <-m.scheduler.C:
+			info, err = m.getInfo()
+		case <-m.refreshCh:
+			if err != nil {
+				s(nil)
+			}
+			info, err = m.getInfo()
+		}
+	}
+}
+
+func (m *Module) getInfo() (Info, error) {
+	server := m.server.Get().(string)
+	i := Info{WebURL: "http://" + server + "/printers/"}
+	if len(m.printers) == 1 {
+		i.WebURL += m.printers[0]
+	}
+	printers, err := getPrinters(server)
+	if err != nil {
+		return i, err
+	}
+	if len(m.printers) == 0 {
+		i.Printers = printers
+		return i, nil
+	}
+	for _, name := range m.printers {
+		for _, p := range printers {
+			if p.Name == name {
+				i.Printers = append(i.Printers, p)
+			}
+		}
+	}
+	return i, nil
+}
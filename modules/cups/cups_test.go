@@ -0,0 +1,221 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cups
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func int32Value(v int) string {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return string(b)
+}
+
+type testPrinter struct {
+	name    string
+	state   State
+	reasons []string
+	jobs    int
+}
+
+type testServer struct {
+	*httptest.Server
+	sync.Mutex
+	printers []testPrinter
+	status   uint16
+}
+
+func (t *testServer) set(status uint16, printers ...testPrinter) {
+	t.Lock()
+	defer t.Unlock()
+	t.status = status
+	t.printers = printers
+}
+
+func (t *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.URL.Path != "/" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/ipp" ||
+		binary.BigEndian.Uint16(body[2:4]) != opCupsGetPrinters {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	out := &ippWriter{}
+	out.Write([]byte{2, 0})
+	binary.Write(out, binary.BigEndian, t.status)
+	out.Write(body[4:8])
+	out.WriteByte(tagOperation)
+	out.attribute(tagCharset, "attributes-charset", "utf-8")
+	for _, p := range t.printers {
+		out.WriteByte(tagPrinter)
+		out.attribute(0x42, "printer-name", p.name)
+		out.attribute(tagEnum, "printer-state", int32Value(int(p.state)))
+		reasons := p.reasons
+		if len(reasons) == 0 {
+			reasons = []string{"none"}
+		}
+		out.attribute(tagKeyword, "printer-state-reasons", reasons...)
+		out.attribute(0x41, "printer-state-message", strings.Join(p.reasons, "; "))
+		out.attribute(tagInteger, "queued-job-count", int32Value(p.jobs))
+		out.attribute(0x45, "printer-uri-supported", "ipp://localhost/printers/"+p.name)
+	}
+	out.WriteByte(tagEnd)
+	w.Header().Set("Content-Type", "application/ipp")
+	w.Write(out.Bytes())
+}
+
+func newTestServer() *testServer {
+	t := &testServer{}
+	t.Server = httptest.NewServer(t)
+	return t
+}
+
+func (t *testServer) addr() string {
+	return strings.TrimPrefix(t.URL, "http://")
+}
+
+func TestGetPrinters(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	srv.set(0,
+		testPrinter{name: "office", state: Processing, jobs: 2},
+		testPrinter{name: "home", state: Stopped,
+			reasons: []string{"paused", "media-empty-error", "toner-low-warning"}},
+	)
+	printers, err := getPrinters(srv.addr())
+	require.NoError(t, err)
+	require.Equal(t, []Printer{
+		{
+			Name:    "home",
+			State:   Stopped,
+			Reasons: []string{"paused", "media-empty-error", "toner-low-warning"},
+			Message: "paused; media-empty-error; toner-low-warning",
+		},
+		{Name: "office", State: Processing, Jobs: 2},
+	}, printers)
+
+	home := printers[0]
+	require.True(t, home.Paused())
+	require.True(t, home.OutOfPaper())
+	require.True(t, home.Error())
+	require.True(t, home.HasReason("toner-low"))
+	require.False(t, home.Idle())
+	require.False(t, printers[1].Idle())
+	require.True(t, Printer{State: Idle}.Idle())
+	require.True(t, Printer{State: Processing,
+		Reasons: []string{"toner-low-warning"}}.Idle(), "warnings are not problems")
+
+	srv.set(0x0406)
+	_, err = getPrinters(srv.addr())
+	require.Error(t, err, "IPP error status")
+
+	_, err = getPrinters(srv.addr() + "/missing")
+	require.Error(t, err, "HTTP error")
+}
+
+func TestModule(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	srv.set(0,
+		testPrinter{name: "office", state: Idle},
+		testPrinter{name: "home", state: Idle},
+		testPrinter{name: "label", state: Processing, jobs: 4},
+	)
+
+	testBar.New(t)
+	m := New("office", "home").Server(srv.addr()).Output(func(i Info) bar.Output {
+		var s []string
+		for _, p := range i.Printers {
+			s = append(s, p.Name)
+		}
+		return outputs.Textf("%d %s %s", i.Jobs(), strings.Join(s, ","), i.WebURL)
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText(
+		[]string{"0 office,home http://" + srv.addr() + "/printers/"})
+
+	srv.set(0,
+		testPrinter{name: "office", state: Processing, jobs: 1},
+		testPrinter{name: "home", state: Processing, jobs: 2},
+	)
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText(
+		[]string{"3 office,home http://" + srv.addr() + "/printers/"})
+
+	srv.set(0x0500)
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+
+	srv.set(0, testPrinter{name: "office", state: Idle})
+	m.Refresh()
+	testBar.NextOutput().AssertEmpty("clears error on refresh")
+	testBar.NextOutput("on refresh").AssertText(
+		[]string{"0 office http://" + srv.addr() + "/printers/"})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	srv.set(0, testPrinter{name: "office", state: Idle})
+
+	testBar.New(t)
+	m := New("office").Server(srv.addr())
+	testBar.Run(m)
+	testBar.NextOutput("when idle").AssertEmpty()
+
+	srv.set(0, testPrinter{name: "office", state: Processing, jobs: 2})
+	m.Refresh()
+	out := testBar.NextOutput("with jobs")
+	out.AssertText([]string{"🖶 2 jobs"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	srv.set(0, testPrinter{name: "office", state: Stopped, jobs: 1,
+		reasons: []string{"paused"}})
+	m.Refresh()
+	out = testBar.NextOutput("when paused")
+	out.AssertText([]string{"🖶 1 jobs, office: paused"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	srv.set(0, testPrinter{name: "office", state: Idle,
+		reasons: []string{"media-needed-report"}})
+	m.Refresh()
+	testBar.NextOutput("without paper").AssertText([]string{"🖶 office: no paper"})
+
+	srv.set(0, testPrinter{name: "office", state: Idle,
+		reasons: []string{"door-open-error"}})
+	m.Refresh()
+	testBar.NextOutput("on error").AssertText([]string{"🖶 office: error"})
+}
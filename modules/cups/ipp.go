@@ -0,0 +1,214 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cups
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"barista.run/httpclient"
+)
+
+// IPP (RFC 8010) operations, delimiter tags, and value tags used by the module.
+const (
+	opCupsGetPrinters = 0x4002
+
+	tagOperation = 0x01
+	tagEnd       = 0x03
+	tagPrinter   = 0x04
+
+	tagInteger  = 0x21
+	tagEnum     = 0x23
+	tagKeyword  = 0x44
+	tagCharset  = 0x47
+	tagLanguage = 0x48
+)
+
+var client = httpclient.Client()
+
+// attribute is a single IPP attribute, which may have multiple values.
+type attribute struct {
+	tag    byte
+	values [][]byte
+}
+
+type ippWriter struct {
+	bytes.Buffer
+}
+
+func (w *ippWriter) attribute(tag byte, name string, values ...string) {
+	for i, v := range values {
+		if i > 0 {
+			// Additional values are written with an empty name.
+			name = ""
+		}
+		w.WriteByte(tag)
+		binary.Write(w, binary.BigEndian, uint16(len(name)))
+		w.WriteString(name)
+		binary.Write(w, binary.BigEndian, uint16(len(v)))
+		w.WriteString(v)
+	}
+}
+
+// request encodes an IPP request with the given operation attributes.
+func request(op uint16, attrs func(w *ippWriter)) []byte {
+	w := &ippWriter{}
+	// IPP version 2.0, operation, and request ID.
+	w.Write([]byte{2, 0})
+	binary.Write(w, binary.BigEndian, op)
+	binary.Write(w, binary.BigEndian, uint32(1))
+	w.WriteByte(tagOperation)
+	w.attribute(tagCharset, "attributes-charset", "utf-8")
+	w.attribute(tagLanguage, "attributes-natural-language", "en")
+	attrs(w)
+	w.WriteByte(tagEnd)
+	return w.Bytes()
+}
+
+// response decodes an IPP response, returning the attributes of each group
+// with the given delimiter tag.
+func response(r io.Reader, groupTag byte) ([]map[string]attribute, error) {
+	br := bufio.NewReader(r)
+	var header struct {
+		Version   [2]byte
+		Status    uint16
+		RequestID uint32
+	}
+	if err := binary.Read(br, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	// Status codes 0x0000-0x00ff are successful.
+	if header.Status > 0xff {
+		return nil, fmt.Errorf("IPP status 0x%04x", header.Status)
+	}
+	var groups []map[string]attribute
+	var group map[string]attribute
+	var lastName string
+	for {
+		tag, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if tag == tagEnd {
+			return groups, nil
+		}
+		if tag < 0x10 {
+			group = nil
+			if tag == groupTag {
+				group = map[string]attribute{}
+				groups = append(groups, group)
+			}
+			continue
+		}
+		name, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			name = lastName
+		}
+		lastName = name
+		if group != nil {
+			a := group[name]
+			a.tag = tag
+			a.values = append(a.values, []byte(value))
+			group[name] = a
+		}
+	}
+}
+
+func readString(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	_, err := io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+func (a attribute) string() string {
+	if len(a.values) == 0 {
+		return ""
+	}
+	return string(a.values[0])
+}
+
+func (a attribute) strings() []string {
+	var s []string
+	for _, v := range a.values {
+		s = append(s, string(v))
+	}
+	return s
+}
+
+func (a attribute) int() int {
+	if len(a.values) == 0 || len(a.values[0]) != 4 {
+		return 0
+	}
+	if a.tag != tagInteger && a.tag != tagEnum {
+		return 0
+	}
+	return int(int32(binary.BigEndian.Uint32(a.values[0])))
+}
+
+// getPrinters returns the state of all printers on the CUPS server.
+func getPrinters(server string) ([]Printer, error) {
+	req := request(opCupsGetPrinters, func(w *ippWriter) {
+		w.attribute(tagKeyword, "requested-attributes",
+			"printer-name", "printer-state", "printer-state-reasons",
+			"printer-state-message", "queued-job-count")
+	})
+	resp, err := client.Post("http://"+server+"/", "application/ipp", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Status %d", resp.StatusCode)
+	}
+	groups, err := response(resp.Body, tagPrinter)
+	if err != nil {
+		return nil, err
+	}
+	var printers []Printer
+	for _, g := range groups {
+		p := Printer{
+			Name:    g["printer-name"].string(),
+			State:   State(g["printer-state"].int()),
+			Message: g["printer-state-message"].string(),
+			Jobs:    g["queued-job-count"].int(),
+		}
+		for _, r := range g["printer-state-reasons"].strings() {
+			if r != "none" {
+				p.Reasons = append(p.Reasons, r)
+			}
+		}
+		printers = append(printers, p)
+	}
+	sort.Slice(printers, func(i, j int) bool {
+		return printers[i].Name < printers[j].Name
+	})
+	return printers, nil
+}
@@ -0,0 +1,316 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package dailytimes provides an i3bar module that shows a countdown to the next
+of a set of daily events, such as prayer times or sunset, computed locally
+from the latitude and longitude.
+
+Events are described by rules that compute the time of the event on a given
+day. Rules are provided for the elevation of the sun (sunrise, sunset,
+twilight), the length of shadows (used for Asr), and fixed clock times, and
+can be offset by a fixed duration.
+*/
+package dailytimes // import "barista.run/modules/dailytimes"
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Coords represents a location on earth.
+type Coords struct {
+	Lat, Lon float64
+}
+
+// Rule computes the time of an event on the given day (midnight in the local
+// timezone) at the given location. It returns false if the event does not
+// occur on that day, e.g. sunset during the polar summer.
+type Rule func(day time.Time, c Coords) (time.Time, bool)
+
+// SunElevation returns a rule for when the sun reaches the given elevation
+// (in degrees above the horizon) in the morning, or in the evening if rising
+// is false.
+func SunElevation(elevation float64, rising bool) Rule {
+	return func(day time.Time, c Coords) (time.Time, bool) {
+		noon, decl := solarNoon(day.Year(), day.Month(), day.Day(), c.Lon)
+		h, ok := hourAngle(elevation, c.Lat, decl)
+		if rising {
+			h = -h
+		}
+		return noon.Add(h), ok
+	}
+}
+
+// Sunrise returns a rule for when the top of the sun appears over the horizon.
+func Sunrise() Rule {
+	return SunElevation(-0.833, true)
+}
+
+// Sunset returns a rule for when the sun disappears below the horizon.
+func Sunset() Rule {
+	return SunElevation(-0.833, false)
+}
+
+// SolarNoon returns a rule for when the sun is highest in the sky.
+func SolarNoon() Rule {
+	return func(day time.Time, c Coords) (time.Time, bool) {
+		noon, _ := solarNoon(day.Year(), day.Month(), day.Day(), c.Lon)
+		return noon, true
+	}
+}
+
+// Shadow returns a rule for the time in the afternoon when the shadow of an
+// object is the given multiple of its length, plus the length of its shadow
+// at noon.
+func Shadow(factor float64) Rule {
+	return func(day time.Time, c Coords) (time.Time, bool) {
+		noon, decl := solarNoon(day.Year(), day.Month(), day.Day(), c.Lon)
+		elevation := deg(math.Atan(1 / (factor + math.Tan(rad(math.Abs(c.Lat-decl))))))
+		h, ok := hourAngle(elevation, c.Lat, decl)
+		return noon.Add(h), ok
+	}
+}
+
+// At returns a rule for a fixed time of day, in the local timezone.
+func At(hour, min int) Rule {
+	return func(day time.Time, c Coords) (time.Time, bool) {
+		return time.Date(day.Year(), day.Month(), day.Day(),
+			hour, min, 0, 0, day.Location()), true
+	}
+}
+
+// Offset returns a rule for a fixed duration after (or before, if negative)
+// the given rule.
+func Offset(r Rule, d time.Duration) Rule {
+	return func(day time.Time, c Coords) (time.Time, bool) {
+		t, ok := r(day, c)
+		return t.Add(d), ok
+	}
+}
+
+// Method represents the parameters used to calculate prayer times.
+type Method struct {
+	// Fajr is the depression of the sun below the horizon at Fajr.
+	Fajr float64
+	// Isha is the depression of the sun below the horizon at Isha. If zero,
+	// IshaDelay is used instead.
+	Isha float64
+	// IshaDelay is the time between Maghrib and Isha, when Isha is zero.
+	IshaDelay time.Duration
+	// Asr is the shadow factor at Asr: 1 for the standard method, and 2 for
+	// the Hanafi method.
+	Asr float64
+}
+
+// Common prayer time calculation methods.
+var (
+	MuslimWorldLeague = Method{Fajr: 18, Isha: 17, Asr: 1}
+	ISNA              = Method{Fajr: 15, Isha: 15, Asr: 1}
+	Egypt             = Method{Fajr: 19.5, Isha: 17.5, Asr: 1}
+	Karachi           = Method{Fajr: 18, Isha: 18, Asr: 1}
+	UmmAlQura         = Method{Fajr: 18.5, IshaDelay: 90 * time.Minute, Asr: 1}
+)
+
+// Event represents a single occurrence of a daily event.
+type Event struct {
+	Name string
+	Time time.Time
+}
+
+// Info represents the upcoming event.
+type Info struct {
+	// Events are all of today's events, in order.
+	Events []Event
+	// Next is the next event to occur.
+	Next Event
+	// Remaining is the time until the next event.
+	Remaining time.Duration
+	// Imminent is true if the next event is within the warning period.
+	Imminent bool
+}
+
+// Format formats a countdown in minutes, e.g. "5m" or "1h05m".
+func Format(d time.Duration) string {
+	mins := int((d + time.Minute - 1) / time.Minute)
+	if mins < 60 {
+		return fmt.Sprintf("%dm", mins)
+	}
+	return fmt.Sprintf("%dh%02dm", mins/60, mins%60)
+}
+
+type rule struct {
+	name string
+	rule Rule
+}
+
+// Module represents a daily events bar module.
+type Module struct {
+	coords     Coords
+	rules      value.Value // of []rule
+	warning    value.Value // of time.Duration
+	timezone   value.Value // of *time.Location
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a module for daily events at the given coordinates. Events
+// must be added using Add.
+func New(lat, lon float64) *Module {
+	m := &Module{
+		coords:    Coords{lat, lon},
+		scheduler: timing.NewScheduler(),
+	}
+	l.Register(m, "outputFunc", "rules", "warning", "timezone", "scheduler")
+	m.rules.Set([]rule(nil))
+	m.timezone.Set((*time.Location)(nil))
+	m.Warning(10 * time.Minute)
+	// Default output is the name of the next event and a countdown, urgent
+	// when the event is imminent.
+	m.Output(func(i Info) bar.Output {
+		if i.Next.Name == "" {
+			return nil
+		}
+		return outputs.Textf("%s in %s", i.Next.Name, Format(i.Remaining)).
+			Urgent(i.Imminent)
+	})
+	return m
+}
+
+// Prayers constructs a module for the five daily prayers (and sunrise) at the
+// given coordinates, using the given calculation method.
+func Prayers(lat, lon float64, method Method) *Module {
+	isha := SunElevation(-method.Isha, false)
+	if method.Isha == 0 {
+		isha = Offset(Sunset(), method.IshaDelay)
+	}
+	return New(lat, lon).
+		Add("Fajr", SunElevation(-method.Fajr, true)).
+		Add("Sunrise", Sunrise()).
+		Add("Dhuhr", SolarNoon()).
+		Add("Asr", Shadow(method.Asr)).
+		Add("Maghrib", Sunset()).
+		Add("Isha", isha)
+}
+
+// Add adds a named event that occurs daily at the time given by the rule.
+func (m *Module) Add(name string, r Rule) *Module {
+	rules := append([]rule(nil), m.rules.Get().([]rule)...)
+	m.rules.Set(append(rules, rule{name, r}))
+	return m
+}
+
+// Warning sets how long before an event the output is marked as imminent.
+func (m *Module) Warning(warning time.Duration) *Module {
+	m.warning.Set(warning)
+	return m
+}
+
+// Timezone sets the timezone used to determine the start of each day. By
+// default, the current machine's timezone is used.
+func (m *Module) Timezone(timezone *time.Location) *Module {
+	m.timezone.Set(timezone)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// events returns all events on the day starting at the given midnight.
+func (m *Module) events(day time.Time) []Event {
+	var events []Event
+	for _, r := range m.rules.Get().([]rule) {
+		if t, ok := r.rule(day, m.coords); ok {
+			events = append(events, Event{r.name, t.In(day.Location())})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
+
+func (m *Module) info(now time.Time) Info {
+	tz, _ := m.timezone.Get().(*time.Location)
+	if tz == nil {
+		tz = time.Local
+	}
+	now = now.In(tz)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, tz)
+	i := Info{Events: m.events(today)}
+	upcoming := i.Events
+	// The next event may be tomorrow, or (near the poles) even later.
+	for day := 1; day <= 7; day++ {
+		for _, e := range upcoming {
+			if e.Time.After(now) {
+				i.Next = e
+				i.Remaining = e.Time.Sub(now)
+				i.Imminent = i.Remaining <= m.warning.Get().(time.Duration)
+				return i
+			}
+		}
+		upcoming = m.events(today.AddDate(0, 0, day))
+	}
+	return i
+}
+
+// nextChange returns the next time the countdown changes, which is when the
+// remaining time crosses a minute boundary.
+func nextChange(now time.Time, i Info) time.Time {
+	if i.Next.Name == "" {
+		return now.Add(time.Hour)
+	}
+	if rem := i.Remaining % time.Minute; rem > 0 {
+		return now.Add(rem)
+	}
+	return now.Add(time.Minute)
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextRules, doneRules := m.rules.Subscribe()
+	defer doneRules()
+	nextWarning, doneWarning := m.warning.Subscribe()
+	defer doneWarning()
+	nextTimezone, doneTimezone := m.timezone.Subscribe()
+	defer doneTimezone()
+	for {
+		now := timing.Now()
+		info := m.info(now)
+		m.scheduler.At(nextChange(now, info))
+		s.Output(outputFunc(info))
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextRules:
+		case <-nextWarning:
+		case <-nextTimezone:
+		case <-m.scheduler.C:
+		}
+	}
+}
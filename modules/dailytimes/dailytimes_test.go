@@ -0,0 +1,169 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dailytimes
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	tz, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return tz
+}
+
+func requireNear(t *testing.T, expected time.Time, actual time.Time, msg string) {
+	diff := actual.Sub(expected)
+	require.True(t, diff > -2*time.Minute && diff < 2*time.Minute,
+		"%s: expected %v, got %v", msg, expected, actual)
+}
+
+func TestRules(t *testing.T) {
+	london := loadLocation(t, "Europe/London")
+	day := time.Date(2017, time.June, 21, 0, 0, 0, 0, london)
+	c := Coords{51.5074, -0.1278}
+
+	rise, ok := Sunrise()(day, c)
+	require.True(t, ok)
+	requireNear(t, time.Date(2017, time.June, 21, 4, 43, 0, 0, london), rise, "sunrise")
+
+	set, ok := Sunset()(day, c)
+	require.True(t, ok)
+	requireNear(t, time.Date(2017, time.June, 21, 21, 21, 0, 0, london), set, "sunset")
+
+	noon, _ := SolarNoon()(day, c)
+	requireNear(t, time.Date(2017, time.June, 21, 13, 2, 0, 0, london), noon, "noon")
+
+	at, _ := At(9, 30)(day, c)
+	require.Equal(t, time.Date(2017, time.June, 21, 9, 30, 0, 0, london), at)
+
+	later, _ := Offset(At(9, 30), 45*time.Minute)(day, c)
+	require.Equal(t, time.Date(2017, time.June, 21, 10, 15, 0, 0, london), later)
+
+	oslo := loadLocation(t, "Europe/Oslo")
+	_, ok = Sunset()(time.Date(2017, time.June, 21, 0, 0, 0, 0, oslo),
+		Coords{69.6492, 18.9553})
+	require.False(t, ok, "no sunset during polar day")
+}
+
+func TestPrayers(t *testing.T) {
+	riyadh := loadLocation(t, "Asia/Riyadh")
+	m := Prayers(21.4225, 39.8262, UmmAlQura)
+	events := m.events(time.Date(2017, time.March, 1, 0, 0, 0, 0, riyadh))
+	expected := []struct {
+		name   string
+		hh, mm int
+	}{
+		{"Fajr", 5, 24},
+		{"Sunrise", 6, 42},
+		{"Dhuhr", 12, 33},
+		{"Asr", 15, 54},
+		{"Maghrib", 18, 24},
+		{"Isha", 19, 54},
+	}
+	require.Len(t, events, len(expected))
+	for idx, e := range expected {
+		require.Equal(t, e.name, events[idx].Name)
+		requireNear(t,
+			time.Date(2017, time.March, 1, e.hh, e.mm, 0, 0, riyadh),
+			events[idx].Time, e.name)
+	}
+
+	newYork := loadLocation(t, "America/New_York")
+	hanafi := ISNA
+	hanafi.Asr = 2
+	events = Prayers(40.7128, -74.0060, hanafi).
+		events(time.Date(2017, time.July, 1, 0, 0, 0, 0, newYork))
+	require.Equal(t, "Asr", events[3].Name)
+	requireNear(t, time.Date(2017, time.July, 1, 18, 13, 0, 0, newYork),
+		events[3].Time, "Hanafi Asr")
+}
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		d        time.Duration
+		expected string
+	}{
+		{30 * time.Second, "1m"},
+		{5 * time.Minute, "5m"},
+		{5*time.Minute + time.Second, "6m"},
+		{65 * time.Minute, "1h05m"},
+		{26 * time.Hour, "26h00m"},
+	} {
+		require.Equal(t, tc.expected, Format(tc.d), "Format(%v)", tc.d)
+	}
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(time.Date(2017, time.March, 1, 8, 58, 30, 0, time.UTC))
+
+	m := New(0, 0).
+		Timezone(time.UTC).
+		Add("Lunch", At(12, 0)).
+		Add("Standup", At(9, 0)).
+		Output(func(i Info) bar.Output {
+			return outputs.Textf("%s %v %v %d",
+				i.Next.Name, i.Remaining, i.Imminent, len(i.Events))
+		})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"Standup 1m30s true 2"})
+
+	timing.NextTick()
+	testBar.NextOutput("on minute boundary").AssertText([]string{"Standup 1m0s true 2"})
+
+	timing.AdvanceBy(time.Minute)
+	testBar.NextOutput("on event").AssertText([]string{"Lunch 3h0m0s false 2"})
+
+	m.Warning(4 * time.Hour)
+	testBar.NextOutput("on warning change").AssertText([]string{"Lunch 3h0m0s true 2"})
+
+	timing.AdvanceTo(time.Date(2017, time.March, 1, 13, 0, 0, 0, time.UTC))
+	testBar.NextOutput("after the last event of the day").
+		AssertText([]string{"Standup 20h0m0s false 2"})
+
+	m.Add("Dinner", At(19, 0))
+	testBar.NextOutput("on new event").AssertText([]string{"Dinner 6h0m0s false 3"})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(time.Date(2017, time.March, 1, 10, 0, 0, 0, time.UTC))
+
+	m := New(0, 0).Timezone(time.UTC)
+	testBar.Run(m)
+	testBar.NextOutput("without events").AssertEmpty()
+
+	m.Add("Meeting", At(11, 5))
+	out := testBar.NextOutput("with event")
+	out.AssertText([]string{"Meeting in 1h05m"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	timing.AdvanceTo(time.Date(2017, time.March, 1, 10, 56, 0, 0, time.UTC))
+	out = testBar.NextOutput("when imminent")
+	out.AssertText([]string{"Meeting in 9m"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "when imminent")
+
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dailytimes
+
+import (
+	"math"
+	"time"
+)
+
+// The solar position calculations below are based on the approximations
+// published by the U.S. Naval Observatory, and are accurate to about a
+// minute for latitudes below the polar circles.
+
+func deg(rad float64) float64 { return rad * 180 / math.Pi }
+func rad(deg float64) float64 { return deg * math.Pi / 180 }
+
+func fixAngle(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+// sunPosition returns the declination of the sun (in degrees), and the
+// equation of time (in hours), for the given time.
+func sunPosition(t time.Time) (decl, eqt float64) {
+	d := float64(t.Unix())/86400 + 2440587.5 - 2451545.0
+	g := rad(fixAngle(357.529 + 0.98560028*d))
+	q := fixAngle(280.459 + 0.98564736*d)
+	l := rad(fixAngle(q + 1.915*math.Sin(g) + 0.020*math.Sin(2*g)))
+	e := rad(23.439 - 0.00000036*d)
+	ra := fixAngle(deg(math.Atan2(math.Cos(e)*math.Sin(l), math.Cos(l)))) / 15
+	eqt = q/15 - ra
+	// Keep the equation of time in its natural range of about ±20 minutes.
+	eqt -= 24 * math.Floor((eqt+12)/24)
+	return deg(math.Asin(math.Sin(e) * math.Sin(l))), eqt
+}
+
+// solarNoon returns the time of solar noon on the given date, along with
+// the declination of the sun at that time.
+func solarNoon(year int, month time.Month, day int, lon float64) (time.Time, float64) {
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	noon := midnight.Add(hours(12 - lon/15))
+	decl, eqt := sunPosition(noon)
+	return noon.Add(hours(-eqt)), decl
+}
+
+// hourAngle returns the time between solar noon and the sun reaching the
+// given elevation, or false if the sun never reaches that elevation.
+func hourAngle(elevation, lat, decl float64) (time.Duration, bool) {
+	cos := (math.Sin(rad(elevation)) - math.Sin(rad(lat))*math.Sin(rad(decl))) /
+		(math.Cos(rad(lat)) * math.Cos(rad(decl)))
+	if cos < -1 || cos > 1 {
+		return 0, false
+	}
+	return hours(deg(math.Acos(cos)) / 15), true
+}
+
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
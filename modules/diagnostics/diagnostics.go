@@ -0,0 +1,229 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package diagnostics provides a module that shows recent problems with the bar,
+such as errors from modules and failed click actions, even when debug logging
+is not enabled (see logging.Problems).
+
+The default output shows the number of errors and warnings since the module
+was last cleared. A left click writes all recent problems to a file and opens
+it, and a right click clears them. The latest messages can be shown using
+Detail, e.g. as the detail of a mode in group/modal:
+
+	diag := diagnostics.New()
+	modal.Mode("diagnostics").Summary(diag).Detail(diag.Detail(5))
+*/
+package diagnostics // import "barista.run/modules/diagnostics"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/actions"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/i18n"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Info represents the problems since the module was last cleared.
+type Info struct {
+	// Problems are the recent problems, oldest first.
+	Problems []l.Problem
+}
+
+// Errors returns the number of errors.
+func (i Info) Errors() int {
+	count := 0
+	for _, p := range i.Problems {
+		if p.Error {
+			count++
+		}
+	}
+	return count
+}
+
+// Warnings returns the number of warnings.
+func (i Info) Warnings() int {
+	return len(i.Problems) - i.Errors()
+}
+
+// Latest returns up to count of the most recent problems, newest first.
+func (i Info) Latest(count int) []l.Problem {
+	var latest []l.Problem
+	for idx := len(i.Problems) - 1; idx >= 0 && len(latest) < count; idx-- {
+		latest = append(latest, i.Problems[idx])
+	}
+	return latest
+}
+
+// Module represents a diagnostics bar module.
+type Module struct {
+	outputFunc value.Value // of func(Info) bar.Output
+	cleared    value.Value // of time.Time
+	filename   value.Value // of string
+}
+
+// New creates a diagnostics module.
+func New() *Module {
+	m := &Module{}
+	l.Register(m, "outputFunc", "cleared", "filename")
+	m.cleared.Set(time.Time{})
+	m.filename.Set(filepath.Join(os.TempDir(), "barista-problems.log"))
+	m.Output(m.defaultOutput)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined
+// function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// DumpTo sets the file that problems are written to by Dump.
+func (m *Module) DumpTo(filename string) *Module {
+	m.filename.Set(filename)
+	return m
+}
+
+// Clear hides all current problems.
+func (m *Module) Clear() {
+	m.cleared.Set(time.Now())
+}
+
+// Dump writes all recent problems, including those that have been cleared, to
+// the file set using DumpTo (a file in the temporary directory by default),
+// and returns its name.
+func (m *Module) Dump() (string, error) {
+	problems, _ := l.Problems()
+	var out strings.Builder
+	for _, p := range problems {
+		out.WriteString(format(p, "2006-01-02 15:04:05.000") + "\n")
+	}
+	filename := m.filename.Get().(string)
+	return filename, ioutil.WriteFile(filename, []byte(out.String()), 0600)
+}
+
+func format(p l.Problem, timeFormat string) string {
+	level := "WARNING"
+	if p.Error {
+		level = "ERROR"
+	}
+	return fmt.Sprintf("%s %s %s: %s",
+		p.Time.Format(timeFormat), level, p.Source, p.Message)
+}
+
+// info returns the problems that have not been cleared.
+func (m *Module) info(problems []l.Problem) Info {
+	cleared := m.cleared.Get().(time.Time)
+	var i Info
+	for _, p := range problems {
+		if p.Time.After(cleared) {
+			i.Problems = append(i.Problems, p)
+		}
+	}
+	return i
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	for {
+		problems, changed := l.Problems()
+		s.Output(outputFunc(m.info(problems)))
+		select {
+		case <-changed:
+		case <-m.cleared.Next():
+		case <-m.outputFunc.Next():
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) defaultOutput(i Info) bar.Output {
+	if len(i.Problems) == 0 {
+		return nil
+	}
+	var parts []string
+	col := colors.Scheme("degraded")
+	if errs := i.Errors(); errs > 0 {
+		parts = append(parts, i18n.Sprintf("Errors: %d", errs))
+		col = colors.Scheme("bad")
+	}
+	if warnings := i.Warnings(); warnings > 0 {
+		parts = append(parts, i18n.Sprintf("Warnings: %d", warnings))
+	}
+	return outputs.Text(strings.Join(parts, ", ")).
+		Color(col).
+		OnClick(m.click)
+}
+
+func levelColor(p l.Problem) colors.ColorfulColor {
+	if p.Error {
+		return colors.Scheme("bad")
+	}
+	return colors.Scheme("degraded")
+}
+
+// For tests.
+var openURL = actions.OpenURL
+
+func (m *Module) click(e bar.Event) {
+	switch e.Button {
+	case bar.ButtonLeft:
+		filename, err := m.Dump()
+		if err == nil {
+			err = openURL("file://" + filename)
+		}
+		if err != nil {
+			actions.Report(err, e)
+		}
+	case bar.ButtonRight:
+		m.Clear()
+	}
+}
+
+// Detail returns a module that shows up to count of the latest problems that
+// have not been cleared, one per segment, newest first.
+func (m *Module) Detail(count int) bar.Module {
+	return &detail{m, count}
+}
+
+type detail struct {
+	m     *Module
+	count int
+}
+
+func (d *detail) Stream(s bar.Sink) {
+	for {
+		problems, changed := l.Problems()
+		out := outputs.Group()
+		for _, p := range d.m.info(problems).Latest(d.count) {
+			out.Append(outputs.Text(format(p, "15:04:05")).Color(levelColor(p)))
+		}
+		s.Output(out)
+		select {
+		case <-changed:
+		case <-d.m.cleared.Next():
+		}
+	}
+}
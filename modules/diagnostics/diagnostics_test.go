@@ -0,0 +1,156 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/testing/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics(t *testing.T) {
+	testBar.New(t)
+	d := New()
+	// Problems from earlier tests in this package are not shown.
+	d.Clear()
+	time.Sleep(time.Millisecond)
+	testBar.Run(d)
+	testBar.NextOutput().AssertEmpty("with no problems")
+
+	l.Warn("mod", "something odd")
+	testBar.NextOutput().AssertText([]string{"Warnings: 1"})
+
+	l.Error("mod", "something broke")
+	testBar.NextOutput().AssertText([]string{"Errors: 1, Warnings: 1"})
+
+	l.Error("other", "also broken")
+	out := testBar.NextOutput()
+	out.AssertText([]string{"Errors: 2, Warnings: 1"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	testBar.NextOutput().AssertEmpty("on right click")
+
+	d.Output(func(i Info) bar.Output {
+		var msgs []string
+		for _, p := range i.Latest(2) {
+			msgs = append(msgs, p.Source+": "+p.Message)
+		}
+		return outputs.Textf("%d:%s", len(i.Problems), strings.Join(msgs, ","))
+	})
+	testBar.NextOutput().AssertText([]string{"0:"}, "on output func change")
+
+	l.Warn("a", "one")
+	testBar.NextOutput().AssertText([]string{"1:a: one"})
+	l.Error("b", "two")
+	testBar.NextOutput().AssertText([]string{"2:b: two,a: one"})
+	l.Warn("c", "three")
+	testBar.NextOutput().AssertText([]string{"3:c: three,b: two"})
+}
+
+func TestDetail(t *testing.T) {
+	testBar.New(t)
+	d := New()
+	d.Clear()
+	time.Sleep(time.Millisecond)
+	testBar.Run(d.Detail(2))
+	testBar.NextOutput().AssertEmpty("with no problems")
+
+	l.Error("mod", "failed")
+	testBar.NextOutput().AssertText(
+		[]string{lastTime(t) + " ERROR mod: failed"}, "shows latest problem")
+
+	l.Warn("mod", "warned")
+	out := testBar.NextOutput()
+	require.Equal(t, 2, out.Len())
+	require.Contains(t, text(out, 0), "WARNING mod: warned")
+	require.Contains(t, text(out, 1), "ERROR mod: failed")
+
+	l.Warn("other", "ignored")
+	out = testBar.NextOutput()
+	require.Equal(t, 2, out.Len(), "only shows count problems")
+	require.Contains(t, text(out, 0), "other: ignored")
+
+	d.Clear()
+	testBar.NextOutput().AssertEmpty("on clear")
+}
+
+func text(out output.Assertions, i int) string {
+	txt, _ := out.At(i).Segment().Content()
+	return txt
+}
+
+func lastTime(t *testing.T) string {
+	problems, _ := l.Problems()
+	require.NotEmpty(t, problems)
+	return problems[len(problems)-1].Time.Format("15:04:05")
+}
+
+func TestDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "problems.log")
+
+	var opened []string
+	openURL = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+	defer func() { openURL = nil }()
+
+	testBar.New(t)
+	d := New().DumpTo(filename)
+	testBar.Run(d)
+	testBar.LatestOutput()
+
+	l.Error("dump", "first")
+	d.Clear()
+	l.Warn("dump", "second")
+	out := testBar.NextOutput()
+	out.AssertText([]string{"Warnings: 1"})
+
+	out.At(0).LeftClick()
+	require.Equal(t, []string{"file://" + filename}, opened)
+	contents, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.True(t, len(lines) >= 2)
+	require.Contains(t, lines[len(lines)-2], " ERROR dump: first",
+		"includes cleared problems")
+	require.Contains(t, lines[len(lines)-1], " WARNING dump: second")
+
+	openURL = func(string) error { return errors.New("no xdg-open") }
+	out.At(0).LeftClick()
+	// Reported action errors are also recorded.
+	testBar.NextOutput().AssertText([]string{"Errors: 1, Warnings: 1"})
+	problems, _ := l.Problems()
+	require.Equal(t, "no xdg-open", problems[len(problems)-1].Message)
+
+	d.DumpTo(filepath.Join(dir, "missing", "problems.log"))
+	_, err = d.Dump()
+	require.Error(t, err, "when the file cannot be written")
+}
@@ -0,0 +1,487 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package external runs blocklets written for i3blocks, allowing existing
+scripts to be used on the bar without changes.
+
+Blocks follow the i3blocks protocol. Each run of the command prints the full
+text, short text, and colour on successive lines (or, in JSON mode, a JSON
+object with i3bar keys such as "full_text" and "color"). An exit code of 33
+marks the block as urgent, and any other non-zero exit code is shown as an
+error, with the command's stderr.
+
+Clicks run the command again, with the click in the environment using both
+the current and legacy names (button and BLOCK_BUTTON, x and BLOCK_X, etc.),
+along with name, instance, and any properties added using Set.
+
+Persistent blocks (see Persist) are long running commands where each line of
+output is an update, and clicks are written to the command's stdin: the
+button number, or in JSON mode, the click event as JSON.
+*/
+package external // import "barista.run/modules/external"
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/timing"
+)
+
+// Info represents the output of a block.
+type Info struct {
+	FullText  string
+	ShortText string
+	// Pango is true if the text uses pango markup (JSON mode only).
+	Pango      bool
+	Color      color.Color
+	Background color.Color
+	Border     color.Color
+	// Urgent is true if the command exited with code 33, or if it was set
+	// in JSON mode.
+	Urgent bool
+}
+
+// urgentExitCode is the exit code used by blocks to mark themselves urgent.
+const urgentExitCode = 33
+
+// Module represents an external block. It runs the command using sh, in the
+// same way as i3blocks.
+type Module struct {
+	command    string
+	persist    bool
+	json       bool
+	env        []string
+	outputFunc value.Value // of func(Info) bar.Output
+	scheduler  *timing.Scheduler
+	notifyFn   func()
+	notifyCh   <-chan struct{}
+	signalCh   chan os.Signal
+	clicks     chan bar.Event
+}
+
+// New constructs a block that runs the given command once, and again on
+// click, Refresh, or at the interval set by Every.
+func New(command string) *Module {
+	m := &Module{
+		command:   command,
+		scheduler: timing.NewScheduler(),
+		clicks:    make(chan bar.Event, 10),
+	}
+	m.notifyFn, m.notifyCh = notifier.New()
+	m.outputFunc.Set(defaultOutput)
+	return m
+}
+
+// Persist constructs a block for a long running command, where each line of
+// output updates the block (the "persist" interval in i3blocks).
+func Persist(command string) *Module {
+	m := New(command)
+	m.persist = true
+	return m
+}
+
+// Name sets the name of the block, passed to the command as name (and
+// BLOCK_NAME). Must be called before the module is streamed.
+func (m *Module) Name(name string) *Module {
+	return m.Set("name", name).Set("BLOCK_NAME", name)
+}
+
+// Instance sets the instance of the block, passed to the command as instance
+// (and BLOCK_INSTANCE). Must be called before the module is streamed.
+func (m *Module) Instance(instance string) *Module {
+	return m.Set("instance", instance).Set("BLOCK_INSTANCE", instance)
+}
+
+// Set adds a property to the command's environment, for blocklets that are
+// configured using custom properties (e.g. LABEL). Must be called before the
+// module is streamed.
+func (m *Module) Set(key, value string) *Module {
+	m.env = append(m.env, key+"="+value)
+	return m
+}
+
+// JSON sets the block to use the JSON format, where the command prints an
+// object with i3bar keys, and persistent blocks receive clicks as JSON. Must be
+// called before the module is streamed.
+func (m *Module) JSON() *Module {
+	m.json = true
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Every sets the interval at which the command is run again. A zero interval
+// stops automatic repeats (but clicks and Refresh will still work). It has no
+// effect on persistent blocks.
+func (m *Module) Every(interval time.Duration) *Module {
+	if interval == 0 {
+		m.scheduler.Stop()
+	} else {
+		m.scheduler.Every(interval)
+	}
+	return m
+}
+
+// Signal runs the command again when the bar receives the real-time signal
+// SIGRTMIN+n, as with the signal property in i3blocks, allowing updates using
+// e.g. "pkill -RTMIN+10 barista". It has no effect on persistent blocks.
+func (m *Module) Signal(n int) *Module {
+	if m.signalCh == nil {
+		m.signalCh = make(chan os.Signal, 1)
+	}
+	signal.Notify(m.signalCh, rtSignal(n))
+	return m
+}
+
+// sigRTMin is SIGRTMIN as seen by programs using glibc, which reserves the
+// first two real-time signals.
+const sigRTMin = 34
+
+func rtSignal(n int) os.Signal {
+	return syscall.Signal(sigRTMin + n)
+}
+
+// Refresh runs the command again.
+func (m *Module) Refresh() {
+	m.notifyFn()
+}
+
+func defaultOutput(i Info) bar.Output {
+	if i.FullText == "" {
+		return nil
+	}
+	out := bar.TextSegment(i.FullText)
+	if i.Pango {
+		out = bar.PangoSegment(i.FullText)
+	}
+	if i.ShortText != "" {
+		out.ShortText(i.ShortText)
+	}
+	if i.Color != nil {
+		out.Color(i.Color)
+	}
+	if i.Background != nil {
+		out.Background(i.Background)
+	}
+	if i.Border != nil {
+		out.Border(i.Border)
+	}
+	if i.Urgent {
+		out.Urgent(true)
+	}
+	return out
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	if m.persist {
+		m.streamPersistent(s)
+		return
+	}
+	info, err := m.run(nil)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(m.withClick(outputFunc(info)))
+		select {
+		case <-m.outputFunc.Next():
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.notifyCh:
+			info, err = m.run(nil)
+		case <-m.scheduler.C:
+			info, err = m.run(nil)
+		case <-m.signalCh:
+			info, err = m.run(nil)
+		case e := <-m.clicks:
+			info, err = m.run(&e)
+		}
+	}
+}
+
+// withClick sends clicks on segments without their own click handlers to the
+// block.
+func (m *Module) withClick(o bar.Output) bar.Output {
+	if o == nil {
+		return nil
+	}
+	var out bar.Segments
+	for _, seg := range o.Segments() {
+		if !seg.HasClick() {
+			seg = seg.Clone().OnClick(m.click)
+		}
+		out = append(out, seg)
+	}
+	return out
+}
+
+func (m *Module) click(e bar.Event) {
+	select {
+	case m.clicks <- e:
+	default:
+		// Clicks faster than the command can run are dropped.
+	}
+}
+
+func (m *Module) newCmd(e *bar.Event) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", m.command)
+	// Prevent SIGUSR for bar pause/resume from propagating to the
+	// child process, as with shell.Tail.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Env = append(os.Environ(), m.env...)
+	if e != nil {
+		cmd.Env = append(cmd.Env, clickEnv(*e)...)
+	}
+	return cmd
+}
+
+func clickEnv(e bar.Event) []string {
+	vars := map[string]int{
+		"button":     int(e.Button),
+		"x":          e.ScreenX,
+		"y":          e.ScreenY,
+		"relative_x": e.X,
+		"relative_y": e.Y,
+		"width":      e.Width,
+		"height":     e.Height,
+	}
+	env := []string{
+		"BLOCK_BUTTON=" + strconv.Itoa(int(e.Button)),
+		"BLOCK_X=" + strconv.Itoa(e.ScreenX),
+		"BLOCK_Y=" + strconv.Itoa(e.ScreenY),
+	}
+	for k, v := range vars {
+		env = append(env, k+"="+strconv.Itoa(v))
+	}
+	return env
+}
+
+// run runs the command, optionally for a click, and parses its output.
+func (m *Module) run(e *bar.Event) (Info, error) {
+	cmd := m.newCmd(e)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	urgent := false
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		status, _ := exitErr.Sys().(syscall.WaitStatus)
+		if status.ExitStatus() == urgentExitCode {
+			urgent, err = true, nil
+		} else if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	if m.json {
+		info, err = parseJSON(bytes.TrimSpace(out))
+	} else {
+		info = parseLines(string(out))
+	}
+	info.Urgent = info.Urgent || urgent
+	return info, err
+}
+
+// parseLines parses the full text, short text, and colour from the lines of
+// output.
+func parseLines(out string) Info {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	var info Info
+	info.FullText = lines[0]
+	if len(lines) > 1 {
+		info.ShortText = lines[1]
+	}
+	if len(lines) > 2 {
+		info.Color = parseColor(lines[2])
+	}
+	return info
+}
+
+type jsonBlock struct {
+	FullText   string `json:"full_text"`
+	ShortText  string `json:"short_text"`
+	Markup     string `json:"markup"`
+	Color      string `json:"color"`
+	Background string `json:"background"`
+	Border     string `json:"border"`
+	Urgent     bool   `json:"urgent"`
+}
+
+func parseJSON(out []byte) (Info, error) {
+	if len(out) == 0 {
+		return Info{}, nil
+	}
+	var b jsonBlock
+	if err := json.Unmarshal(out, &b); err != nil {
+		return Info{}, err
+	}
+	return Info{
+		FullText:   b.FullText,
+		ShortText:  b.ShortText,
+		Pango:      b.Markup == "pango",
+		Color:      parseColor(b.Color),
+		Background: parseColor(b.Background),
+		Border:     parseColor(b.Border),
+		Urgent:     b.Urgent,
+	}, nil
+}
+
+// parseColor parses a colour, returning nil (rather than a nil ColorfulColor)
+// if the colour is empty or invalid.
+func parseColor(s string) color.Color {
+	if c := colors.Hex(strings.TrimSpace(s)); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (m *Module) streamPersistent(s bar.Sink) {
+	cmd := m.newCmd(nil)
+	stdin, err := cmd.StdinPipe()
+	if s.Error(err) {
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if s.Error(err) {
+		return
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if s.Error(cmd.Start()) {
+		return
+	}
+	defer stdin.Close()
+	lines := make(chan string)
+	done := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+		err := cmd.Wait()
+		if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		done <- err
+	}()
+	var info *Info
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// Wait for the command to exit.
+				lines = nil
+				continue
+			}
+			i, err := m.parseLine(line)
+			if s.Error(err) {
+				cmd.Process.Kill()
+				go func() {
+					for range lines {
+					}
+				}()
+				return
+			}
+			info = &i
+		case <-m.outputFunc.Next():
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case e := <-m.clicks:
+			m.sendClick(stdin, e)
+			continue
+		case err := <-done:
+			s.Error(err)
+			return
+		}
+		if info != nil {
+			s.Output(m.withClick(outputFunc(*info)))
+		}
+	}
+}
+
+// parseLine parses a single line of output from a persistent block, which is
+// either the full text or a JSON object.
+func (m *Module) parseLine(line string) (Info, error) {
+	if m.json {
+		return parseJSON([]byte(line))
+	}
+	return Info{FullText: line}, nil
+}
+
+type jsonClick struct {
+	Name      string `json:"name,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Button    int    `json:"button"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	RelativeX int    `json:"relative_x"`
+	RelativeY int    `json:"relative_y"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+}
+
+// sendClick writes a click to the stdin of a persistent block.
+func (m *Module) sendClick(w io.Writer, e bar.Event) {
+	if !m.json {
+		fmt.Fprintln(w, int(e.Button))
+		return
+	}
+	c := jsonClick{
+		Name:      m.property("name"),
+		Instance:  m.property("instance"),
+		Button:    int(e.Button),
+		X:         e.ScreenX,
+		Y:         e.ScreenY,
+		RelativeX: e.X,
+		RelativeY: e.Y,
+		Width:     e.Width,
+		Height:    e.Height,
+	}
+	json.NewEncoder(w).Encode(c)
+}
+
+// property returns the last value set for a property.
+func (m *Module) property(key string) string {
+	val := ""
+	for _, kv := range m.env {
+		if strings.HasPrefix(kv, key+"=") {
+			val = kv[len(key)+1:]
+		}
+	}
+	return val
+}
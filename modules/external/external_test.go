@@ -0,0 +1,156 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestBlock(t *testing.T) {
+	testBar.New(t)
+	b := New(`echo "full text"; echo short; echo "#ff0000"`).Every(time.Second)
+	testBar.Run(b)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"full text"})
+	seg := out.At(0).Segment()
+	short, _ := seg.GetShortText()
+	require.Equal(t, "short", short)
+	col, _ := seg.GetColor()
+	require.Equal(t, colors.Hex("#ff0000"), col)
+
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"full text"})
+
+	b.Every(0)
+	testBar.Tick()
+	testBar.AssertNoOutput("on zero interval")
+
+	b.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s|%s", i.FullText, i.ShortText)
+	})
+	testBar.NextOutput("on output change").AssertText([]string{"full text|short"})
+
+	b.Refresh()
+	testBar.NextOutput("on refresh").AssertText([]string{"full text|short"})
+}
+
+func TestClick(t *testing.T) {
+	testBar.New(t)
+	b := New(`echo "$BLOCK_BUTTON,$button,$x,$relative_y,$name,$BLOCK_INSTANCE,$LABEL"`).
+		Name("test").Instance("1").Set("LABEL", "L:")
+	testBar.Run(b)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{",,,,test,1,L:"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight, ScreenX: 100, Y: 4})
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"3,3,100,4,test,1,L:"})
+
+	b.Output(func(i Info) bar.Output {
+		return outputs.Text(i.FullText).OnClick(nil)
+	})
+	out = testBar.NextOutput("on output change")
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("with click handler from output")
+}
+
+func TestExitCodes(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(New(`echo urgent; exit 33`))
+	urgent, _ := testBar.NextOutput("on start").At(0).Segment().IsUrgent()
+	require.True(t, urgent, "exit code 33 is urgent")
+
+	testBar.New(t)
+	testBar.Run(New(`echo foo; echo "it broke" >&2; exit 1`))
+	errs := testBar.NextOutput("on start").AssertError()
+	require.Contains(t, errs[0], "it broke", "includes stderr")
+
+	testBar.New(t)
+	testBar.Run(New(`true`))
+	testBar.NextOutput("on start").AssertEmpty("without output")
+}
+
+func TestJSON(t *testing.T) {
+	testBar.New(t)
+	b := New(`echo '{"full_text": "<b>json</b>", "markup": "pango", "background": "#00ff00", "urgent": true}'`).JSON()
+	testBar.Run(b)
+	seg := testBar.NextOutput("on start").At(0).Segment()
+	txt, isPango := seg.Content()
+	require.Equal(t, "<b>json</b>", txt)
+	require.True(t, isPango)
+	bg, _ := seg.GetBackground()
+	require.Equal(t, colors.Hex("#00ff00"), bg)
+	urgent, _ := seg.IsUrgent()
+	require.True(t, urgent)
+
+	testBar.New(t)
+	testBar.Run(New(`echo '{"full_text": '`).JSON())
+	testBar.NextOutput("on start").AssertError("on invalid json")
+}
+
+func TestPersist(t *testing.T) {
+	testBar.New(t)
+	b := Persist(`echo start; while read button; do echo "clicked $button"; done`)
+	testBar.Run(b)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"start"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput("on click").AssertText([]string{"clicked 4"})
+
+	testBar.New(t)
+	b = Persist(`echo '{"full_text": "start"}'
+while read click; do
+  echo "{\"full_text\": \"$(echo "$click" | tr -d '"{}')\"}"
+done`).JSON().Instance("inst")
+	testBar.Run(b)
+	out = testBar.NextOutput("on start")
+	out.AssertText([]string{"start"})
+	out.At(0).Click(bar.Event{Button: bar.ButtonLeft, Width: 10})
+	testBar.NextOutput("on click").At(0).AssertText(
+		"instance:inst,button:1,x:0,y:0,relative_x:0,relative_y:0,width:10,height:0",
+		"click is written as json")
+
+	testBar.New(t)
+	testBar.Run(Persist(`echo a; sleep 0.05; echo b; sleep 0.05; echo "failed" >&2; exit 2`))
+	testBar.NextOutput().AssertText([]string{"a"})
+	testBar.NextOutput().AssertText([]string{"b"})
+	errs := testBar.NextOutput().AssertError("on exit")
+	require.Contains(t, errs[0], "failed")
+}
+
+func TestSignal(t *testing.T) {
+	testBar.New(t)
+	b := New(`date +%N`).Signal(3)
+	testBar.Run(b)
+	testBar.NextOutput("on start").Expect("on start")
+	// The scheduler is not used for signals, so wait for the real signal.
+	timing.AdvanceBy(time.Minute)
+	testBar.AssertNoOutput("without signal")
+
+	unix.Kill(unix.Getpid(), unix.Signal(sigRTMin+3))
+	testBar.NextOutput("on signal").Expect("on signal")
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package ecb provides exchange rates using the euro foreign exchange reference
+rates published by the European Central Bank, available at
+https://www.ecb.europa.eu/stats/policy_and_exchange_rates/euro_reference_exchange_rates.
+
+Reference rates are published around 16:00 CET on working days. Rates between
+two currencies other than the euro are calculated from their euro rates.
+*/
+package ecb // import "barista.run/modules/forex/ecb"
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/forex"
+)
+
+// Provider wraps the ECB daily reference rates url and a currency pair
+// so that it can be used as a forex.Provider.
+type Provider struct {
+	url  string
+	from string
+	to   string
+}
+
+// Pair creates a provider for the exchange rate between the given currencies,
+// e.g. Pair("EUR", "USD") for the amount of dollars in one euro.
+func Pair(from, to string) forex.Provider {
+	return Provider{
+		url:  "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml",
+		from: strings.ToUpper(from),
+		to:   strings.ToUpper(to),
+	}
+}
+
+// ecbRates represents the daily reference rates xml.
+type ecbRates struct {
+	Cube struct {
+		Cube struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+// GetRate gets the exchange rate from the ECB reference rates.
+func (p Provider) GetRate() (forex.Rate, error) {
+	response, err := httpclient.Client().Get(p.url)
+	if err != nil {
+		return forex.Rate{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return forex.Rate{}, fmt.Errorf("HTTP Status %d", response.StatusCode)
+	}
+	r := ecbRates{}
+	if err := xml.NewDecoder(response.Body).Decode(&r); err != nil {
+		return forex.Rate{}, err
+	}
+	// All rates are the amount of the currency in one euro.
+	euroRates := map[string]float64{"EUR": 1}
+	for _, rate := range r.Cube.Cube.Rates {
+		euroRates[rate.Currency] = rate.Rate
+	}
+	from, ok := euroRates[p.from]
+	if !ok || from == 0 {
+		return forex.Rate{}, fmt.Errorf("No reference rate for %s", p.from)
+	}
+	to, ok := euroRates[p.to]
+	if !ok {
+		return forex.Rate{}, fmt.Errorf("No reference rate for %s", p.to)
+	}
+	updated, err := time.Parse("2006-01-02", r.Cube.Cube.Time)
+	if err != nil {
+		return forex.Rate{}, err
+	}
+	return forex.Rate{
+		From:        p.from,
+		To:          p.to,
+		Rate:        to / from,
+		Updated:     updated,
+		Attribution: "ECB",
+	}, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecb
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"barista.run/modules/forex"
+	testServer "barista.run/testing/httpserver"
+
+	"github.com/stretchr/testify/require"
+)
+
+var ts *httptest.Server
+
+func TestMain(m *testing.M) {
+	ts = testServer.New()
+	defer ts.Close()
+	os.Exit(m.Run())
+}
+
+func TestGood(t *testing.T) {
+	updated := time.Date(2018, 4, 27, 0, 0, 0, 0, time.UTC)
+
+	rate, err := Provider{ts.URL + "/static/daily.xml", "EUR", "USD"}.GetRate()
+	require.NoError(t, err)
+	require.Equal(t, forex.Rate{
+		From: "EUR", To: "USD", Rate: 1.209,
+		Updated: updated, Attribution: "ECB",
+	}, rate)
+
+	rate, err = Provider{ts.URL + "/static/daily.xml", "USD", "EUR"}.GetRate()
+	require.NoError(t, err)
+	require.InDelta(t, 1/1.209, rate.Rate, 1e-9)
+
+	rate, err = Provider{ts.URL + "/static/daily.xml", "GBP", "JPY"}.GetRate()
+	require.NoError(t, err)
+	require.InDelta(t, 150.473, rate.Rate, 1e-3, "cross rate")
+	require.Equal(t, updated, rate.Updated)
+}
+
+func TestPair(t *testing.T) {
+	p := Pair("gbp", "chf").(Provider)
+	require.Equal(t, "GBP", p.from)
+	require.Equal(t, "CHF", p.to)
+	require.Contains(t, p.url, "ecb.europa.eu")
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Provider{ts.URL + "/static/bad.xml", "EUR", "USD"}.GetRate()
+	require.Error(t, err, "bad xml")
+
+	_, err = Provider{ts.URL + "/static/daily.xml", "XYZ", "USD"}.GetRate()
+	require.Error(t, err, "unknown currency")
+
+	_, err = Provider{ts.URL + "/static/daily.xml", "EUR", "XYZ"}.GetRate()
+	require.Error(t, err, "unknown currency")
+
+	_, err = Provider{ts.URL + "/code/404", "EUR", "USD"}.GetRate()
+	require.Error(t, err, "http error")
+
+	_, err = Provider{ts.URL + "/redir", "EUR", "USD"}.GetRate()
+	require.Error(t, err, "http error")
+}
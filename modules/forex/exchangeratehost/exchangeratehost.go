@@ -0,0 +1,125 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package exchangeratehost provides exchange rates using the exchangerate.host
+API, available at https://exchangerate.host.
+
+Rates are fetched relative to the US dollar, which is the only source currency
+available on the free plan, and converted to the requested pair.
+*/
+package exchangeratehost // import "barista.run/modules/forex/exchangeratehost"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/forex"
+)
+
+// Config represents exchangerate.host API configuration (just the access key)
+// from which a forex.Provider can be built.
+type Config string
+
+// New creates a new exchangerate.host API configuration.
+func New(accessKey string) Config {
+	return Config(accessKey)
+}
+
+// Provider wraps an exchangerate.host API url and a currency pair so that
+// it can be used as a forex.Provider.
+type Provider struct {
+	url  string
+	from string
+	to   string
+}
+
+// Pair builds a provider for the exchange rate between the given currencies,
+// e.g. Pair("EUR", "USD") for the amount of dollars in one euro.
+func (c Config) Pair(from, to string) forex.Provider {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	qp := url.Values{}
+	qp.Add("access_key", string(c))
+	qp.Add("source", "USD")
+	qp.Add("currencies", from+","+to)
+	u := url.URL{
+		Scheme:   "https",
+		Host:     "api.exchangerate.host",
+		Path:     "/live",
+		RawQuery: qp.Encode(),
+	}
+	return Provider{url: u.String(), from: from, to: to}
+}
+
+// erhLive represents an exchangerate.host json response.
+type erhLive struct {
+	Success   bool
+	Timestamp int64
+	Source    string
+	Quotes    map[string]float64
+	Error     struct {
+		Code int
+		Info string
+	}
+}
+
+// GetRate gets the exchange rate from exchangerate.host.
+func (p Provider) GetRate() (forex.Rate, error) {
+	response, err := httpclient.Client().Get(p.url)
+	if err != nil {
+		return forex.Rate{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return forex.Rate{}, fmt.Errorf("HTTP Status %d", response.StatusCode)
+	}
+	e := erhLive{}
+	if err := json.NewDecoder(response.Body).Decode(&e); err != nil {
+		return forex.Rate{}, err
+	}
+	if !e.Success {
+		return forex.Rate{}, fmt.Errorf("exchangerate.host error %d: %s", e.Error.Code, e.Error.Info)
+	}
+	// Quotes are keyed by the source and target currency, e.g. "USDEUR".
+	rate := func(currency string) (float64, error) {
+		if currency == e.Source {
+			return 1, nil
+		}
+		r, ok := e.Quotes[e.Source+currency]
+		if !ok || r == 0 {
+			return 0, fmt.Errorf("No rate for %s", currency)
+		}
+		return r, nil
+	}
+	from, err := rate(p.from)
+	if err != nil {
+		return forex.Rate{}, err
+	}
+	to, err := rate(p.to)
+	if err != nil {
+		return forex.Rate{}, err
+	}
+	return forex.Rate{
+		From:        p.from,
+		To:          p.to,
+		Rate:        to / from,
+		Updated:     time.Unix(e.Timestamp, 0),
+		Attribution: "exchangerate.host",
+	}, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchangeratehost
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"barista.run/modules/forex"
+	testServer "barista.run/testing/httpserver"
+
+	"github.com/stretchr/testify/require"
+)
+
+var ts *httptest.Server
+
+func TestMain(m *testing.M) {
+	ts = testServer.New()
+	defer ts.Close()
+	os.Exit(m.Run())
+}
+
+func TestPair(t *testing.T) {
+	p := New("key").Pair("eur", "gbp").(Provider)
+	u, err := url.Parse(p.url)
+	require.NoError(t, err)
+	require.Equal(t, "api.exchangerate.host", u.Host)
+	require.Equal(t, url.Values{
+		"access_key": {"key"},
+		"source":     {"USD"},
+		"currencies": {"EUR,GBP"},
+	}, u.Query())
+}
+
+func TestGood(t *testing.T) {
+	rate, err := Provider{ts.URL + "/static/live.json", "EUR", "GBP"}.GetRate()
+	require.NoError(t, err)
+	require.Equal(t, "EUR", rate.From)
+	require.Equal(t, "GBP", rate.To)
+	require.InDelta(t, 0.90625, rate.Rate, 1e-9)
+	require.Equal(t, time.Unix(1524844800, 0), rate.Updated)
+	require.Equal(t, "exchangerate.host", rate.Attribution)
+
+	rate, err = Provider{ts.URL + "/static/live.json", "USD", "EUR"}.GetRate()
+	require.NoError(t, err)
+	require.Equal(t, forex.Rate{
+		From: "USD", To: "EUR", Rate: 0.8,
+		Updated:     time.Unix(1524844800, 0),
+		Attribution: "exchangerate.host",
+	}, rate)
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Provider{ts.URL + "/static/bad.json", "EUR", "GBP"}.GetRate()
+	require.Error(t, err, "bad json")
+
+	_, err = Provider{ts.URL + "/static/error.json", "EUR", "GBP"}.GetRate()
+	require.Error(t, err, "api error")
+	require.Contains(t, err.Error(), "valid API Access Key")
+
+	_, err = Provider{ts.URL + "/static/live.json", "EUR", "XYZ"}.GetRate()
+	require.Error(t, err, "unknown currency")
+
+	_, err = Provider{ts.URL + "/code/500", "EUR", "GBP"}.GetRate()
+	require.Error(t, err, "http error")
+
+	_, err = Provider{ts.URL + "/redir", "EUR", "GBP"}.GetRate()
+	require.Error(t, err, "http error")
+}
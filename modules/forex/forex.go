@@ -0,0 +1,133 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package forex provides an i3bar module that displays a currency exchange rate.
+package forex // import "barista.run/modules/forex"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Rate represents the exchange rate for a currency pair.
+type Rate struct {
+	// From and To are ISO 4217 currency codes, e.g. "EUR" and "USD".
+	From string
+	To   string
+	// Rate is the amount of To currency for one unit of From currency.
+	Rate        float64
+	Updated     time.Time
+	Attribution string
+}
+
+// Convert converts an amount in the From currency to the To currency.
+func (r Rate) Convert(amount float64) float64 {
+	return amount * r.Rate
+}
+
+// Inverse returns the rate for converting from the To currency to the From
+// currency.
+func (r Rate) Inverse() Rate {
+	inv := r
+	inv.From, inv.To = r.To, r.From
+	if r.Rate != 0 {
+		inv.Rate = 1 / r.Rate
+	}
+	return inv
+}
+
+// Provider is an interface for exchange rate providers,
+// implemented by the various provider packages.
+type Provider interface {
+	GetRate() (Rate, error)
+}
+
+// Module represents a bar.Module that displays an exchange rate.
+type Module struct {
+	provider   Provider
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc value.Value // of func(Rate) bar.Output
+}
+
+// New constructs an instance of the forex module with the provided configuration.
+func New(provider Provider) *Module {
+	m := &Module{
+		provider:  provider,
+		scheduler: timing.NewScheduler().Named("forex"),
+	}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the currency pair and the rate.
+	m.Output(func(r Rate) bar.Output {
+		return outputs.Textf("%s/%s %.4f", r.From, r.To, r.Rate)
+	})
+	// Reference rates are usually only updated once a day.
+	m.RefreshInterval(time.Hour)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Rate) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Refresh fetches the updated exchange rate.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	rate, err := m.provider.GetRate()
+	outputFunc := m.outputFunc.Get().(func(Rate) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(rate))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Rate) bar.Output)
+		case <-m.scheduler.C:
+			rate, err = m.provider.GetRate()
+		case <-m.refreshCh:
+			if err != nil {
+				s(nil)
+			}
+			rate, err = m.provider.GetRate()
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forex
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sync.RWMutex
+	Rate
+	error
+}
+
+func (t *testProvider) GetRate() (Rate, error) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.Rate, t.error
+}
+
+func TestRate(t *testing.T) {
+	r := Rate{From: "EUR", To: "USD", Rate: 1.25}
+	require.Equal(t, 125.0, r.Convert(100))
+	require.Equal(t, Rate{From: "USD", To: "EUR", Rate: 0.8}, r.Inverse())
+	require.Equal(t, Rate{From: "B", To: "A"}, Rate{From: "A", To: "B"}.Inverse())
+}
+
+func TestForex(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{Rate: Rate{
+		From: "EUR", To: "USD", Rate: 1.20907, Attribution: "Test",
+	}}
+	f := New(p)
+	testBar.Run(f)
+
+	testBar.NextOutput().AssertText([]string{"EUR/USD 1.2091"}, "on start")
+
+	testBar.Tick()
+	testBar.NextOutput().Expect("on tick")
+
+	f.Output(func(r Rate) bar.Output {
+		return outputs.Textf("€1000 = $%.2f (%s)", r.Convert(1000), r.Attribution)
+	})
+	testBar.NextOutput().AssertText([]string{"€1000 = $1209.07 (Test)"},
+		"on output format change")
+
+	p.Lock()
+	p.error = errors.New("foo")
+	p.Unlock()
+
+	testBar.Tick()
+	testBar.NextOutput().AssertError("on tick with error")
+
+	p.Lock()
+	p.error = nil
+	p.Rate.Rate = 1.1
+	f.Refresh()
+	testBar.NextOutput().AssertEmpty("clears error on refresh")
+
+	p.Unlock()
+	testBar.NextOutput().AssertText([]string{"€1000 = $1100.00 (Test)"})
+}
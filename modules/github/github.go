@@ -12,7 +12,17 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package github provides a barista module to show github notifications.
+/*
+Package github provides a barista module to show github notifications.
+
+Notifications can be filtered by repository, organisation, or reason. The
+module can also show open pull requests that are awaiting the user's review,
+using the search API. Since this needs access to private repositories, it is
+only available for modules created using NewWithReviews.
+
+Failed requests are retried after 30 seconds, backing off to once an hour
+while the API remains unavailable. Clicking on the error retries immediately.
+*/
 package github // import "barista.run/modules/github"
 
 import (
@@ -20,10 +30,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/actions"
+	"barista.run/base/notifier"
 	"barista.run/base/value"
 	"barista.run/oauth"
 	"barista.run/outputs"
@@ -48,41 +62,146 @@ func (n Notifications) Total() int {
 	return t
 }
 
+// PullRequest represents an open pull request.
+type PullRequest struct {
+	// Repo is the full name of the repository, e.g. "owner/name".
+	Repo   string
+	Number int
+	Title  string
+	Author string
+	URL    string
+}
+
+// Info represents the unread notifications and pull requests awaiting review.
+type Info struct {
+	// Notifications are the unread notifications grouped by reason.
+	Notifications Notifications
+	// Repos are the unread notifications grouped by repository, keyed by the
+	// full name of the repository ("owner/name").
+	Repos map[string]int
+	// ReviewRequests are open pull requests where the user's review has been
+	// requested. Only available for modules created using NewWithReviews.
+	ReviewRequests []PullRequest
+}
+
+// Total returns the total number of unread notifications.
+func (i Info) Total() int {
+	return i.Notifications.Total()
+}
+
+// filter restricts the notifications that are counted. Empty sets match all
+// notifications.
+type filter struct {
+	repos   map[string]bool
+	orgs    map[string]bool
+	reasons map[string]bool
+}
+
+func (f filter) matches(n ghNotification) bool {
+	if len(f.reasons) > 0 && !f.reasons[n.Reason] {
+		return false
+	}
+	repo := strings.ToLower(n.Repository.FullName)
+	org := strings.ToLower(n.Repository.Owner.Login)
+	switch {
+	case len(f.repos) == 0 && len(f.orgs) == 0:
+		return true
+	case f.repos[repo], f.orgs[org]:
+		return true
+	default:
+		return false
+	}
+}
+
+func set(values []string, normalise func(string) string) map[string]bool {
+	s := map[string]bool{}
+	for _, v := range values {
+		s[normalise(v)] = true
+	}
+	return s
+}
+
+func identity(s string) string { return s }
+
 // Module represents a GitHub barista module that displays notification counts.
 type Module struct {
 	config     *oauth.Config
-	outputFunc value.Value // of func(Notifications) bar.Output
+	reviews    bool
+	filter     value.Value // of filter
+	outputFunc value.Value // of func(Info) bar.Output
 
 	// Use the poll interval and last modified from the previous response to
 	// control when we next check for notifications.
 	scheduler    *timing.Scheduler
 	lastModified string
+
+	refreshFn func()
+	refreshCh <-chan struct{}
 }
 
 // New creates a GitHub module using the given clientID and secret.
 func New(clientID, clientSecret string) *Module {
+	return newModule(clientID, clientSecret, false)
+}
+
+// NewWithReviews creates a GitHub module using the given clientID and secret,
+// that also shows pull requests awaiting the user's review. This requires
+// the "repo" scope, which grants full access to private repositories.
+func NewWithReviews(clientID, clientSecret string) *Module {
+	return newModule(clientID, clientSecret, true)
+}
+
+func newModule(clientID, clientSecret string, reviews bool) *Module {
+	scopes := []string{"notifications"}
+	if reviews {
+		scopes = append(scopes, "repo")
+	}
 	config := oauth.Register(&oauth2.Config{
 		Endpoint:     github.Endpoint,
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
-		Scopes:       []string{"notifications"},
+		Scopes:       scopes,
 	})
 	m := &Module{
 		config:    config,
-		scheduler: timing.NewScheduler(),
+		reviews:   reviews,
+		scheduler: timing.NewScheduler().Named("github"),
 	}
-	m.Output(func(n Notifications) bar.Output {
-		if n.Total() == 0 {
+	m.refreshFn, m.refreshCh = notifier.New()
+	m.filter.Set(filter{})
+	m.Output(func(i Info) bar.Output {
+		reviews := len(i.ReviewRequests)
+		var out *bar.Segment
+		switch {
+		case i.Total() > 0 && reviews > 0:
+			out = outputs.Textf("GH: %d, %d reviews", i.Total(), reviews)
+		case i.Total() > 0:
+			out = outputs.Textf("GH: %d", i.Total())
+		case reviews > 0:
+			out = outputs.Textf("GH: %d reviews", reviews)
+		default:
 			return nil
 		}
-		return outputs.Textf("GH: %d", n.Total())
+		return out.OnClick(actions.OnClickOpen("https://github.com/notifications"))
 	})
 	return m
 }
 
 type ghNotification struct {
-	Reason string
-	Unread bool
+	Reason     string
+	Unread     bool
+	Repository struct {
+		FullName string `json:"full_name"`
+		Owner    struct {
+			Login string
+		}
+	}
+}
+
+// Refresh fetches notifications (and review requests) again, without waiting
+// for the next poll or retry.
+func (m *Module) Refresh() {
+	m.refreshFn()
 }
 
 // for tests, to wrap the client in a transport that redirects requests.
@@ -94,35 +213,94 @@ func (m *Module) Stream(sink bar.Sink) {
 	if wrapForTest != nil {
 		wrapForTest(client)
 	}
-	outf := m.outputFunc.Get().(func(Notifications) bar.Output)
+	outf := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
-	info, err := m.getNotifications(client)
+	nextFilter, doneFilter := m.filter.Subscribe()
+	defer doneFilter()
+	var reviews []PullRequest
+	var nextReviews time.Time
+	// Review requests are fetched at most once a minute, since the search API
+	// has a much lower rate limit than the notifications API.
+	updateReviews := func() error {
+		if !m.reviews || timing.Now().Before(nextReviews) {
+			return errCached
+		}
+		nextReviews = timing.Now().Add(time.Minute)
+		r, err := m.getReviewRequests(client)
+		if err == nil {
+			reviews = r
+		}
+		return err
+	}
+	// Errors are retried with backoff, replacing the polling interval
+	// requested by the API.
+	result := func(err error) {
+		if err == errCached {
+			err = nil
+		}
+		m.scheduler.Result(err)
+	}
+	var notifs []ghNotification
+	update := func() error {
+		n, err := m.getNotifications(client)
+		if err == nil {
+			notifs = n
+		}
+		if err == nil || err == errCached {
+			// Unchanged notifications still need a new output if
+			// the review requests were refreshed.
+			if re := updateReviews(); re != errCached {
+				err = re
+			}
+		}
+		result(err)
+		return err
+	}
+	err := update()
 	for {
 		if err != errCached {
-			if sink.Error(err) {
-				return
+			if !sink.Error(err) {
+				sink.Output(outf(m.makeInfo(notifs, reviews)))
 			}
-			sink.Output(outf(info))
 		}
 		err = nil
 		select {
 		case <-nextOutputFunc:
-			outf = m.outputFunc.Get().(func(Notifications) bar.Output)
+			outf = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextFilter:
+		case <-m.refreshCh:
+			// Retry immediately, backing off from the start if it fails.
+			m.scheduler.ResetBackoff()
+			nextReviews = time.Time{}
+			err = update()
 		case <-m.scheduler.C:
-			i, e := m.getNotifications(client)
-			err = e
-			if e != errCached {
-				info = i
-			}
+			err = update()
+		}
+	}
+}
+
+func (m *Module) makeInfo(notifs []ghNotification, reviews []PullRequest) Info {
+	f := m.filter.Get().(filter)
+	info := Info{
+		Notifications:  Notifications{},
+		Repos:          map[string]int{},
+		ReviewRequests: reviews,
+	}
+	for _, n := range notifs {
+		if !n.Unread || !f.matches(n) {
+			continue
 		}
+		info.Notifications[n.Reason]++
+		info.Repos[n.Repository.FullName]++
 	}
+	return info
 }
 
 // This is a terrible hack.
 var errCached = errors.New("NothingChanged")
 
-func (m *Module) getNotifications(client *http.Client) (Notifications, error) {
+func (m *Module) getNotifications(client *http.Client) ([]ghNotification, error) {
 	req, _ := http.NewRequest("GET", "https://api.github.com/notifications", nil)
 	if m.lastModified != "" {
 		req.Header.Add("If-Modified-Since", m.lastModified)
@@ -143,26 +321,92 @@ func (m *Module) getNotifications(client *http.Client) (Notifications, error) {
 	if r.StatusCode != 200 {
 		return nil, fmt.Errorf("HTTP Status %d", r.StatusCode)
 	}
-	info := Notifications{}
 	resp := []ghNotification{}
 	err = json.NewDecoder(r.Body).Decode(&resp)
 	defer r.Body.Close()
 	if err != nil {
 		return nil, err
 	}
-	for _, n := range resp {
-		if !n.Unread {
-			continue
+	return resp, nil
+}
+
+type ghSearchResults struct {
+	Items []struct {
+		Number        int
+		Title         string
+		HTMLURL       string `json:"html_url"`
+		RepositoryURL string `json:"repository_url"`
+		User          struct {
+			Login string
 		}
-		count := info[n.Reason]
-		count++
-		info[n.Reason] = count
 	}
-	return info, nil
+}
+
+func (m *Module) getReviewRequests(client *http.Client) ([]PullRequest, error) {
+	q := url.Values{}
+	q.Set("q", "is:open is:pr review-requested:@me archived:false")
+	q.Set("sort", "created")
+	q.Set("order", "asc")
+	r, err := client.Get("https://api.github.com/search/issues?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP Status %d", r.StatusCode)
+	}
+	resp := ghSearchResults{}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	prs := []PullRequest{}
+	for _, i := range resp.Items {
+		prs = append(prs, PullRequest{
+			// The repository URL is https://api.github.com/repos/{owner}/{name}.
+			Repo:   strings.TrimPrefix(i.RepositoryURL, "https://api.github.com/repos/"),
+			Number: i.Number,
+			Title:  i.Title,
+			Author: i.User.Login,
+			URL:    i.HTMLURL,
+		})
+	}
+	return prs, nil
 }
 
 // Output sets the output format for this module.
-func (m *Module) Output(outputFunc func(Notifications) bar.Output) *Module {
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 	m.outputFunc.Set(outputFunc)
 	return m
 }
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+func (m *Module) updateFilter(update func(*filter)) *Module {
+	f := m.filter.Get().(filter)
+	update(&f)
+	m.filter.Set(f)
+	return m
+}
+
+// Repos restricts notifications to the given repositories, in the form
+// "owner/name". If organisations are also set, notifications from either
+// are counted.
+func (m *Module) Repos(repos ...string) *Module {
+	return m.updateFilter(func(f *filter) { f.repos = set(repos, strings.ToLower) })
+}
+
+// Orgs restricts notifications to repositories owned by the given users or
+// organisations.
+func (m *Module) Orgs(orgs ...string) *Module {
+	return m.updateFilter(func(f *filter) { f.orgs = set(orgs, strings.ToLower) })
+}
+
+// Reasons restricts notifications to the given reasons, e.g. "mention" or
+// "review_requested".
+func (m *Module) Reasons(reasons ...string) *Module {
+	return m.updateFilter(func(f *filter) { f.reasons = set(reasons, identity) })
+}
@@ -51,6 +51,20 @@ func respondWithSuccess(contents string, lastModified string, pollInterval strin
 	})
 }
 
+var (
+	reviewsFunc func(http.ResponseWriter, *http.Request)
+	reviewsMu   sync.Mutex
+)
+
+func respondWithReviews(contents string) {
+	reviewsMu.Lock()
+	defer reviewsMu.Unlock()
+	reviewsFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, contents)
+	}
+}
+
 func TestSimple(t *testing.T) {
 	testBar.New(t)
 
@@ -91,7 +105,8 @@ func TestSimple(t *testing.T) {
 	testBar.Tick()
 	testBar.NextOutput().AssertText([]string{"GH: 4"})
 
-	gh.Output(func(n Notifications) bar.Output {
+	gh.Output(func(i Info) bar.Output {
+		n := i.Notifications
 		return outputs.Textf("M:%d,F:%d", n["mention"], n["following"])
 	})
 	testBar.NextOutput().AssertText([]string{"M:3,F:1"},
@@ -105,8 +120,8 @@ func TestSimple(t *testing.T) {
 	testBar.Tick()
 	testBar.AssertNoOutput("On 304")
 
-	gh.Output(func(n Notifications) bar.Output {
-		return outputs.Textf("GH:%d", n.Total())
+	gh.Output(func(i Info) bar.Output {
+		return outputs.Textf("GH:%d", i.Total())
 	})
 	testBar.NextOutput().AssertText([]string{"GH:4"},
 		"keeps previous value on cached response")
@@ -121,6 +136,93 @@ func TestSimple(t *testing.T) {
 	testBar.NextOutput().AssertText([]string{"GH:3"}, "Skips read notifications")
 }
 
+func TestFilters(t *testing.T) {
+	testBar.New(t)
+	respondWithSuccess(`[
+{"reason": "mention", "unread": true, "repository": {"full_name": "foo/bar", "owner": {"login": "foo"}}},
+{"reason": "comment", "unread": true, "repository": {"full_name": "foo/baz", "owner": {"login": "foo"}}},
+{"reason": "mention", "unread": true, "repository": {"full_name": "Other/Repo", "owner": {"login": "Other"}}},
+{"reason": "author", "unread": true, "repository": {"full_name": "other/thing", "owner": {"login": "other"}}},
+{"reason": "mention", "unread": false, "repository": {"full_name": "foo/bar", "owner": {"login": "foo"}}}
+]`, "", "")
+
+	gh := New("clientid", "clientsecret").Output(func(i Info) bar.Output {
+		return outputs.Textf("%d,%d,%d", i.Total(), i.Notifications["mention"], i.Repos["foo/bar"])
+	})
+	testBar.Run(gh)
+	testBar.NextOutput().AssertText([]string{"4,2,1"}, "with no filters")
+
+	gh.Orgs("foo")
+	testBar.NextOutput().AssertText([]string{"2,1,1"}, "on org filter")
+
+	gh.Repos("other/repo")
+	testBar.NextOutput().AssertText([]string{"3,2,1"},
+		"repo and org filters are combined, case insensitive")
+
+	gh.Reasons("mention", "author")
+	testBar.NextOutput().AssertText([]string{"2,2,1"}, "on reason filter")
+
+	gh.Orgs()
+	testBar.NextOutput().AssertText([]string{"1,1,0"}, "on clearing org filter")
+
+	gh.Repos()
+	testBar.NextOutput().AssertText([]string{"3,2,1"}, "on clearing repo filter")
+}
+
+func TestReviewRequests(t *testing.T) {
+	testBar.New(t)
+	respondWithSuccess(`[{"reason": "mention", "unread": true}]`, "", "")
+	respondWithReviews(`{"total_count": 2, "items": [
+{"number": 12, "title": "Fix the thing", "html_url": "https://github.com/foo/bar/pull/12",
+ "repository_url": "https://api.github.com/repos/foo/bar", "user": {"login": "alice"}},
+{"number": 3, "title": "Add docs", "html_url": "https://github.com/foo/baz/pull/3",
+ "repository_url": "https://api.github.com/repos/foo/baz", "user": {"login": "bob"}}
+]}`)
+
+	gh := NewWithReviews("clientid", "clientsecret")
+	testBar.Run(gh)
+	testBar.NextOutput().AssertText([]string{"GH: 1, 2 reviews"})
+
+	respondWithSuccess(`[]`, "", "600")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"GH: 2 reviews"})
+
+	gh.Output(func(i Info) bar.Output {
+		pr := i.ReviewRequests[0]
+		return outputs.Textf("%s#%d %s by %s", pr.Repo, pr.Number, pr.Title, pr.Author)
+	})
+	testBar.NextOutput().AssertText([]string{"foo/bar#12 Fix the thing by alice"})
+
+	gh.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d,%d", i.Total(), len(i.ReviewRequests))
+	})
+	testBar.NextOutput().AssertText([]string{"0,2"})
+
+	respondWithReviews(`{"total_count": 0, "items": []}`)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"0,0"}, "on review updates")
+
+	respondWith(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Poll-Interval", "600")
+		w.WriteHeader(http.StatusNotModified)
+	})
+	respondWithReviews(`{"total_count": 1, "items": [
+{"number": 4, "title": "Tests", "repository_url": "https://api.github.com/repos/a/b"}
+]}`)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"0,1"},
+		"updates reviews even if notifications are unchanged")
+
+	reviewsMu.Lock()
+	reviewsFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	reviewsMu.Unlock()
+	testBar.Tick()
+	err := testBar.NextOutput().AssertError("on search error")
+	require.Contains(t, err, "HTTP Status 422")
+}
+
 func TestErrors(t *testing.T) {
 	testBar.New(t)
 
@@ -131,22 +233,57 @@ func TestErrors(t *testing.T) {
 	gh := New("clientid", "clientsecret")
 	testBar.Run(gh)
 
-	err := testBar.NextOutput().AssertError("On HTTP Error")
+	out := testBar.NextOutput()
+	err := out.AssertError("On HTTP Error")
 	require.Contains(t, err, "HTTP Status 403")
 
 	respondWithSuccess("not-valid-json", "", "")
-	testBar.NextOutput().At(0).LeftClick()
-	testBar.NextOutput().Expect("on restart")
+	out.At(0).LeftClick()
+	out = testBar.NextOutput()
+	out.AssertError("On JSON Error after click")
 
+	respondWith(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Location", "/notifications")
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	out.At(0).LeftClick()
+	testBar.NextOutput().AssertError("On HTTP Client Error after click")
+	errAt := timing.Now()
+
+	respondWithSuccess("not-valid-json", "", "")
+	testBar.Tick()
+	out = testBar.NextOutput()
+	out.AssertError("On JSON Error")
+	require.Equal(t, errAt.Add(30*time.Second), timing.Now(),
+		"retries after an error")
+
+	testBar.Tick()
 	testBar.NextOutput().AssertError("On JSON Error")
+	require.Equal(t, errAt.Add(90*time.Second), timing.Now(),
+		"backs off on repeated errors")
+
+	out.At(0).LeftClick()
+	testBar.NextOutput().AssertError("On JSON Error after click")
+	errAt = timing.Now()
 
 	respondWith(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Location", "/notifications")
 		w.WriteHeader(http.StatusTemporaryRedirect)
 	})
-	testBar.NextOutput().At(0).LeftClick()
-	testBar.NextOutput().Expect("on restart")
+	testBar.Tick()
 	testBar.NextOutput().AssertError("On HTTP Client Error")
+	require.Equal(t, errAt.Add(30*time.Second), timing.Now(),
+		"click resets the backoff")
+
+	respondWithSuccess("[]", "", "60")
+	testBar.Tick()
+	testBar.NextOutput().AssertEmpty("on recovery")
+	require.Equal(t, errAt.Add(90*time.Second), timing.Now())
+
+	testBar.Tick()
+	testBar.NextOutput().AssertEmpty("on poll")
+	require.Equal(t, errAt.Add(150*time.Second), timing.Now(),
+		"poll interval restored on success")
 }
 
 func TestMain(m *testing.M) {
@@ -156,6 +293,15 @@ func TestMain(m *testing.M) {
 		defer responseFuncMu.Unlock()
 		responseFunc(w, r)
 	})
+	mux.HandleFunc("/search/issues", func(w http.ResponseWriter, r *http.Request) {
+		reviewsMu.Lock()
+		defer reviewsMu.Unlock()
+		if r.URL.Query().Get("q") != "is:open is:pr review-requested:@me archived:false" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reviewsFunc(w, r)
+	})
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
@@ -0,0 +1,255 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package gitlab provides an i3bar module that shows the number of pending todos
+and open merge requests assigned to the user on GitLab, and optionally the
+status of the latest pipeline of a project.
+
+It works with gitlab.com or a self-hosted instance, and authenticates using a
+personal access token with the read_api scope.
+*/
+package gitlab // import "barista.run/modules/gitlab"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	"barista.run/httpclient"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Pipeline represents a GitLab CI pipeline.
+type Pipeline struct {
+	ID     int
+	Ref    string
+	Status string
+	URL    string
+}
+
+// Success returns true if the pipeline finished successfully.
+func (p Pipeline) Success() bool {
+	return p.Status == "success"
+}
+
+// Failed returns true if the pipeline failed.
+func (p Pipeline) Failed() bool {
+	return p.Status == "failed"
+}
+
+// Running returns true if the pipeline has not finished yet.
+func (p Pipeline) Running() bool {
+	switch p.Status {
+	case "created", "waiting_for_resource", "preparing", "pending", "running":
+		return true
+	}
+	return false
+}
+
+// Info represents the todos, merge requests, and pipeline status.
+type Info struct {
+	// Todos is the number of pending todos.
+	Todos int
+	// MergeRequests is the number of open merge requests assigned to the user.
+	MergeRequests int
+	// Pipeline is the latest pipeline of the project, if a project was set
+	// and it has any pipelines.
+	Pipeline *Pipeline
+	// URL is the GitLab instance, e.g. "https://gitlab.com".
+	URL string
+}
+
+type pipelineConfig struct {
+	project string
+	ref     string
+}
+
+// Module represents a GitLab bar module.
+type Module struct {
+	server     string
+	token      string
+	pipeline   value.Value // of pipelineConfig
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a GitLab module for gitlab.com, using the given personal access
+// token.
+func New(token string) *Module {
+	return SelfHosted("https://gitlab.com", token)
+}
+
+// SelfHosted creates a GitLab module for a self-hosted instance, e.g.
+// "https://gitlab.example.com", using the given personal access token.
+func SelfHosted(server, token string) *Module {
+	m := &Module{
+		server:    strings.TrimSuffix(server, "/"),
+		token:     token,
+		scheduler: timing.NewScheduler().Named("gitlab"),
+	}
+	l.Label(m, m.server)
+	l.Register(m, "outputFunc", "pipeline", "scheduler")
+	m.pipeline.Set(pipelineConfig{})
+	m.Output(func(i Info) bar.Output {
+		var parts []string
+		if i.Todos > 0 {
+			parts = append(parts, fmt.Sprintf("%d todos", i.Todos))
+		}
+		if i.MergeRequests > 0 {
+			parts = append(parts, fmt.Sprintf("%d MRs", i.MergeRequests))
+		}
+		failed := i.Pipeline != nil && i.Pipeline.Failed()
+		if failed {
+			parts = append(parts, "pipeline failed")
+		}
+		if len(parts) == 0 {
+			return nil
+		}
+		return outputs.Textf("GL: %s", strings.Join(parts, ", ")).
+			Urgent(failed).
+			OnClick(click.RunLeft("xdg-open", i.URL+"/dashboard/todos"))
+	})
+	m.RefreshInterval(2 * time.Minute)
+	return m
+}
+
+// Pipeline sets the project whose latest pipeline is shown, either as a path
+// ("group/project") or a numeric ID. If ref is not empty, only pipelines for
+// that branch or tag are considered.
+func (m *Module) Pipeline(project, ref string) *Module {
+	m.pipeline.Set(pipelineConfig{project, ref})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+var client = httpclient.Client()
+
+// get fetches an API path, decoding the JSON response into result (if not
+// nil), and returns the total number of results from the X-Total header.
+func (m *Module) get(path string, query url.Values, result interface{}) (int, error) {
+	req, err := http.NewRequest("GET", m.server+"/api/v4"+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", m.token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("gitlab: %s", res.Status)
+	}
+	// X-Total is omitted for very large result sets, but that is unlikely
+	// for todos or merge requests.
+	total, _ := strconv.Atoi(res.Header.Get("X-Total"))
+	if result == nil {
+		return total, nil
+	}
+	return total, json.NewDecoder(res.Body).Decode(result)
+}
+
+type glPipeline struct {
+	ID     int    `json:"id"`
+	Ref    string `json:"ref"`
+	Status string `json:"status"`
+	WebURL string `json:"web_url"`
+}
+
+func (m *Module) getInfo() (Info, error) {
+	i := Info{URL: m.server}
+	var err error
+	// Only the count is needed, so request the smallest possible page.
+	if i.Todos, err = m.get("/todos", url.Values{
+		"state":    {"pending"},
+		"per_page": {"1"},
+	}, nil); err != nil {
+		return i, err
+	}
+	if i.MergeRequests, err = m.get("/merge_requests", url.Values{
+		"state":    {"opened"},
+		"scope":    {"assigned_to_me"},
+		"per_page": {"1"},
+	}, nil); err != nil {
+		return i, err
+	}
+	p := m.pipeline.Get().(pipelineConfig)
+	if p.project == "" {
+		return i, nil
+	}
+	query := url.Values{"per_page": {"1"}}
+	if p.ref != "" {
+		query.Set("ref", p.ref)
+	}
+	var pipelines []glPipeline
+	path := "/projects/" + url.PathEscape(p.project) + "/pipelines"
+	if _, err = m.get(path, query, &pipelines); err != nil {
+		return i, err
+	}
+	if len(pipelines) > 0 {
+		pl := pipelines[0]
+		i.Pipeline = &Pipeline{pl.ID, pl.Ref, pl.Status, pl.WebURL}
+	}
+	return i, nil
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.getInfo()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextPipeline, donePipeline := m.pipeline.Subscribe()
+	defer donePipeline()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextPipeline:
+			info, err = m.getInfo()
+		case <-m.scheduler.C:
+			info, err = m.getInfo()
+		}
+	}
+}
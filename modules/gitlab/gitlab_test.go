@@ -0,0 +1,167 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlab
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeGitLab struct {
+	sync.Mutex
+	todos     int
+	mrs       int
+	pipelines string
+	status    int
+	requests  []string
+}
+
+func (f *fakeGitLab) set(todos, mrs int, pipelines string) {
+	f.Lock()
+	defer f.Unlock()
+	f.todos, f.mrs, f.pipelines = todos, mrs, pipelines
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests = append(f.requests, r.URL.RequestURI())
+	if r.Header.Get("PRIVATE-TOKEN") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	switch r.URL.Path {
+	case "/api/v4/todos":
+		w.Header().Set("X-Total", fmt.Sprintf("%d", f.todos))
+		io.WriteString(w, "[{}]")
+	case "/api/v4/merge_requests":
+		w.Header().Set("X-Total", fmt.Sprintf("%d", f.mrs))
+		io.WriteString(w, "[{}]")
+	case "/api/v4/projects/group/project/pipelines":
+		io.WriteString(w, f.pipelines)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeGitLab) takeRequests() []string {
+	f.Lock()
+	defer f.Unlock()
+	r := f.requests
+	f.requests = nil
+	return r
+}
+
+func TestPipeline(t *testing.T) {
+	require.True(t, Pipeline{Status: "success"}.Success())
+	require.True(t, Pipeline{Status: "failed"}.Failed())
+	require.True(t, Pipeline{Status: "pending"}.Running())
+	require.False(t, Pipeline{Status: "canceled"}.Running())
+	require.False(t, Pipeline{Status: "canceled"}.Failed())
+}
+
+func TestModule(t *testing.T) {
+	fake := &fakeGitLab{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	testBar.New(t)
+	fake.set(3, 1, "")
+	m := SelfHosted(srv.URL+"/", "token").Output(func(i Info) bar.Output {
+		out := fmt.Sprintf("%d/%d", i.Todos, i.MergeRequests)
+		if i.Pipeline != nil {
+			out += fmt.Sprintf(" #%d@%s:%s", i.Pipeline.ID, i.Pipeline.Ref, i.Pipeline.Status)
+		}
+		return outputs.Text(out)
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"3/1"})
+	require.Equal(t, []string{
+		"/api/v4/todos?per_page=1&state=pending",
+		"/api/v4/merge_requests?per_page=1&scope=assigned_to_me&state=opened",
+	}, fake.takeRequests())
+
+	fake.set(3, 1, `[{"id": 42, "ref": "main", "status": "running", "web_url": "https://x/42"}]`)
+	m.Pipeline("group/project", "main")
+	testBar.NextOutput("on pipeline change").AssertText([]string{"3/1 #42@main:running"})
+	require.Contains(t, fake.takeRequests(),
+		"/api/v4/projects/group%2Fproject/pipelines?per_page=1&ref=main")
+
+	fake.set(0, 2, `[{"id": 42, "ref": "main", "status": "success"}]`)
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"0/2 #42@main:success"})
+
+	fake.set(0, 2, `[]`)
+	m.Pipeline("group/project", "")
+	testBar.NextOutput("with no pipelines").AssertText([]string{"0/2"})
+
+	fake.Lock()
+	fake.status = http.StatusInternalServerError
+	fake.Unlock()
+	testBar.Tick()
+	err := testBar.NextOutput("on error").AssertError()
+	require.Contains(t, err[0], "500")
+}
+
+func TestDefaultOutput(t *testing.T) {
+	fake := &fakeGitLab{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	testBar.New(t)
+	m := SelfHosted(srv.URL, "token").RefreshInterval(time.Hour)
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertEmpty()
+
+	fake.set(2, 0, `[{"id": 1, "status": "failed"}]`)
+	m.Pipeline("group/project", "")
+	out := testBar.NextOutput("on pipeline change")
+	out.AssertText([]string{"GL: 2 todos, pipeline failed"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	fake.set(1, 4, `[{"id": 2, "status": "success"}]`)
+	testBar.Tick()
+	out = testBar.NextOutput("on refresh")
+	out.AssertText([]string{"GL: 1 todos, 4 MRs"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+}
+
+func TestUnauthorized(t *testing.T) {
+	fake := &fakeGitLab{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	testBar.New(t)
+	testBar.Run(SelfHosted(srv.URL, "wrong"))
+	err := testBar.NextOutput("on start").AssertError()
+	require.Contains(t, err[0], "401")
+}
@@ -103,7 +103,7 @@ func New(clientConfig []byte) *Module {
 	}
 	m := &Module{
 		oauthConfig: oauth.Register(conf),
-		scheduler:   timing.NewScheduler(),
+		scheduler:   timing.NewScheduler().Named("gsuite/calendar"),
 	}
 	m.config.Set(config{calendarID: "primary"})
 	m.RefreshInterval(10 * time.Minute)
@@ -153,6 +153,10 @@ func (m *Module) Stream(sink bar.Sink) {
 	renderer := timing.NewScheduler()
 	evts, err := fetch(srv, conf)
 	for {
+		if err != nil && m.waitForAuth(sink) {
+			evts, err = fetch(srv, conf)
+			continue
+		}
 		if sink.Error(err) {
 			return
 		}
@@ -174,6 +178,24 @@ func (m *Module) Stream(sink bar.Sink) {
 	}
 }
 
+// waitForAuth prompts for re-authentication if the oauth config needs to be
+// authorised again, instead of showing an error that will not go away on its
+// own. It returns true once a new token is available, or false if the config
+// does not need to be authorised.
+func (m *Module) waitForAuth(sink bar.Sink) bool {
+	for {
+		authChanged := m.oauthConfig.AuthChanged()
+		if !m.oauthConfig.NeedsAuth() {
+			return false
+		}
+		sink.Output(m.oauthConfig.ReauthOutput())
+		<-authChanged
+		if !m.oauthConfig.NeedsAuth() {
+			return true
+		}
+	}
+}
+
 func fetch(srv *calendar.Service, conf config) ([]Event, error) {
 	timeMin := timing.Now()
 	timeMax := timeMin.Add(conf.lookahead)
@@ -286,6 +308,12 @@ func (m *Module) Output(outputFunc func(EventList) bar.Output) *Module {
 	return m
 }
 
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
 // RefreshInterval sets the interval for fetching new events. Note that this is
 // distinct from the rendering interval, which is returned by the output func
 // on each new output.
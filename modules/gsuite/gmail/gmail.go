@@ -12,7 +12,13 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package gmail provides a gmail barista module.
+/*
+Package gmail provides a gmail barista module.
+
+Multiple accounts (e.g. work and personal) can be monitored by a single module
+using NewAccounts. Each account gets its own oauth token, and the counts are
+available both for each account and aggregated across all accounts.
+*/
 package gmail // import "barista.run/modules/gsuite/gmail"
 
 import (
@@ -20,11 +26,14 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/actions"
+	"barista.run/base/notifier"
 	"barista.run/base/value"
 	"barista.run/oauth"
 	"barista.run/outputs"
 	"barista.run/timing"
 
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	gmail "google.golang.org/api/gmail/v1"
 )
@@ -32,25 +41,60 @@ import (
 // Info represents the unread and total thread counts for labels.
 // The keys are the names (not IDs) of the labels, and the values are the thread
 // counts (Threads is total threads, while Unread is just unread threads).
+// For modules with multiple accounts, the counts are summed across accounts,
+// and the counts for individual accounts are available in Accounts.
 type Info struct {
+	Threads  map[string]int64
+	Unread   map[string]int64
+	Accounts []Account
+}
+
+// Account represents the thread counts for labels in a single account.
+type Account struct {
+	// Name of the account, as given during construction. This is empty for
+	// modules created using New.
+	Name    string
 	Threads map[string]int64
 	Unread  map[string]int64
 }
 
+// TotalUnread is the total number of unread threads across all labels in the
+// account.
+func (a Account) TotalUnread() int64 {
+	return sum(a.Unread)
+}
+
+// TotalThreads is the total number of threads across all labels in the
+// account.
+func (a Account) TotalThreads() int64 {
+	return sum(a.Threads)
+}
+
+// Account returns the counts for the named account, and whether the module
+// is configured with an account of that name.
+func (i Info) Account(name string) (Account, bool) {
+	for _, a := range i.Accounts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Account{}, false
+}
+
 // TotalUnread is the total number of unread threads across all labels. (as set
 // during construction).
 func (i Info) TotalUnread() int64 {
-	t := int64(0)
-	for _, u := range i.Unread {
-		t += u
-	}
-	return t
+	return sum(i.Unread)
 }
 
 // TotalThreads is the total number of threads across all configured labels.
 func (i Info) TotalThreads() int64 {
+	return sum(i.Threads)
+}
+
+func sum(counts map[string]int64) int64 {
 	t := int64(0)
-	for _, c := range i.Threads {
+	for _, c := range counts {
 		t += c
 	}
 	return t
@@ -58,33 +102,64 @@ func (i Info) TotalThreads() int64 {
 
 // Module represents a Gmail barista module.
 type Module struct {
-	config     *oauth.Config
+	accounts   []account
 	labels     []string
 	scheduler  *timing.Scheduler
 	outputFunc value.Value // of func(Info) bar.Output
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+}
+
+type account struct {
+	name   string
+	config *oauth.Config
 }
 
 // New creates a gmail module from the given oauth config, that fetches unread
 // and total thread counts for the given set of labels.
 func New(clientConfig []byte, labels ...string) *Module {
+	config := parseConfig(clientConfig)
+	return newModule([]account{{config: oauth.Register(config)}}, labels)
+}
+
+// NewAccounts creates a gmail module that fetches unread and total thread
+// counts for the given set of labels from each of the given accounts, using a
+// separate oauth token for each account. The account names are used to
+// identify the accounts during oauth setup, and should be email addresses.
+func NewAccounts(clientConfig []byte, accounts []string, labels ...string) *Module {
+	config := parseConfig(clientConfig)
+	accts := make([]account, len(accounts))
+	for idx, name := range accounts {
+		accts[idx] = account{name, oauth.RegisterAccount(config, name)}
+	}
+	return newModule(accts, labels)
+}
+
+func parseConfig(clientConfig []byte) *oauth2.Config {
 	config, err := google.ConfigFromJSON(clientConfig, gmail.GmailLabelsScope)
 	if err != nil {
 		panic("Bad client config: " + err.Error())
 	}
+	return config
+}
+
+func newModule(accounts []account, labels []string) *Module {
 	if len(labels) == 0 {
 		labels = []string{"INBOX"}
 	}
 	m := &Module{
-		config:    oauth.Register(config),
+		accounts:  accounts,
 		labels:    labels,
-		scheduler: timing.NewScheduler(),
+		scheduler: timing.NewScheduler().Named("gmail"),
 	}
+	m.refreshFn, m.refreshCh = notifier.New()
 	m.RefreshInterval(5 * time.Minute)
 	m.Output(func(i Info) bar.Output {
 		if i.TotalUnread() == 0 {
 			return nil
 		}
-		return outputs.Textf("Gmail: %d", i.TotalUnread())
+		return outputs.Textf("Gmail: %d", i.TotalUnread()).
+			OnClick(actions.OnClickOpen("https://mail.google.com"))
 	})
 	return m
 }
@@ -92,51 +167,131 @@ func New(clientConfig []byte, labels ...string) *Module {
 // for tests, to wrap the client in a transport that redirects requests.
 var wrapForTest func(*http.Client)
 
-// Stream starts the module.
-func (m *Module) Stream(sink bar.Sink) {
-	client, _ := m.config.Client()
+// service is a gmail service for a single account, with the label IDs
+// for that account.
+type service struct {
+	name     string
+	srv      *gmail.Service
+	labelIDs map[string]string
+}
+
+func (a account) service() (*service, error) {
+	client, _ := a.config.Client()
 	if wrapForTest != nil {
 		wrapForTest(client)
 	}
 	srv, _ := gmail.New(client)
 	r, err := srv.Users.Labels.List("me").Do()
-	if sink.Error(err) {
-		return
+	if err != nil {
+		return nil, err
 	}
 	labelIDs := map[string]string{}
 	for _, l := range r.Labels {
 		labelIDs[l.Name] = l.Id
 	}
-	i, err := fetch(srv, m.labels, labelIDs)
+	return &service{a.name, srv, labelIDs}, nil
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	var services []*service
+	var i Info
+	update := func() (err error) {
+		if services == nil {
+			if services, err = m.services(); err != nil {
+				return err
+			}
+		}
+		i, err = fetch(services, m.labels)
+		return err
+	}
+	err := update()
+	m.scheduler.Result(err)
 	outf := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
 	for {
-		if sink.Error(err) {
-			return
+		var authChanged <-chan struct{}
+		if conf, changed := m.needsAuth(err); conf != nil {
+			// Prompt for re-authentication instead of showing an error that
+			// will not go away on its own.
+			authChanged = changed
+			sink.Output(conf.ReauthOutput())
+		} else if !sink.Error(err) {
+			sink.Output(outf(i))
 		}
-		sink.Output(outf(i))
 		select {
 		case <-nextOutputFunc:
 			outf = m.outputFunc.Get().(func(Info) bar.Output)
 		case <-m.scheduler.C:
-			i, err = fetch(srv, m.labels, labelIDs)
+			err = update()
+			m.scheduler.Result(err)
+		case <-m.refreshCh:
+			// Retry immediately, backing off from the start if it fails.
+			m.scheduler.ResetBackoff()
+			err = update()
+			m.scheduler.Result(err)
+		case <-authChanged:
+			if conf, _ := m.needsAuth(err); conf == nil {
+				err = update()
+				m.scheduler.Result(err)
+			}
+		}
+	}
+}
+
+// needsAuth returns the oauth config of the first account that needs to be
+// authorised again if the last update failed, along with a channel that will
+// be closed when its authentication state changes.
+func (m *Module) needsAuth(err error) (*oauth.Config, <-chan struct{}) {
+	if err == nil {
+		return nil, nil
+	}
+	for _, a := range m.accounts {
+		changed := a.config.AuthChanged()
+		if a.config.NeedsAuth() {
+			return a.config, changed
+		}
+	}
+	return nil, nil
+}
+
+// services creates a gmail service for each account, or returns nil and the
+// first error if any of them fail.
+func (m *Module) services() ([]*service, error) {
+	var services []*service
+	for _, a := range m.accounts {
+		s, err := a.service()
+		if err != nil {
+			return nil, err
 		}
+		services = append(services, s)
 	}
+	return services, nil
 }
 
-func fetch(srv *gmail.Service, labels []string, labelIDs map[string]string) (Info, error) {
+func fetch(services []*service, labels []string) (Info, error) {
 	i := Info{
 		Threads: map[string]int64{},
 		Unread:  map[string]int64{},
 	}
-	for _, l := range labels {
-		r, err := srv.Users.Labels.Get("me", labelIDs[l]).Do()
-		if err != nil {
-			return i, err
+	for _, s := range services {
+		a := Account{
+			Name:    s.name,
+			Threads: map[string]int64{},
+			Unread:  map[string]int64{},
+		}
+		for _, l := range labels {
+			r, err := s.srv.Users.Labels.Get("me", s.labelIDs[l]).Do()
+			if err != nil {
+				return i, err
+			}
+			a.Threads[l] = r.ThreadsTotal
+			a.Unread[l] = r.ThreadsUnread
+			i.Threads[l] += r.ThreadsTotal
+			i.Unread[l] += r.ThreadsUnread
 		}
-		i.Threads[l] = r.ThreadsTotal
-		i.Unread[l] = r.ThreadsUnread
+		i.Accounts = append(i.Accounts, a)
 	}
 	return i, nil
 }
@@ -147,7 +302,20 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 	return m
 }
 
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// Refresh checks for new mail without waiting for the next check or retry.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
 // RefreshInterval sets the interval between consecutive checks for new mail.
+// Failed checks are retried sooner at first, backing off to once an hour,
+// or immediately when the error is clicked.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
 	m.scheduler.Every(interval)
 	return m
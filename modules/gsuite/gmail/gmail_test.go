@@ -22,11 +22,13 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"barista.run/bar"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/testing/httpclient"
+	"barista.run/timing"
 	"github.com/stretchr/testify/require"
 )
 
@@ -101,6 +103,41 @@ func TestSimple(t *testing.T) {
 		"Labels not included in module construction are ignored")
 }
 
+func TestAccounts(t *testing.T) {
+	testBar.New(t)
+	setLabels(
+		label{"INBOX", "INBOX", 10, 2},
+		label{"label-000", "My label", 2, 1},
+	)
+
+	gm := NewAccounts(fakeClientConfig,
+		[]string{"me@work.example.com", "me@home.example.com"},
+		"INBOX", "My label")
+	testBar.Run(gm)
+	testBar.NextOutput().AssertText([]string{"Gmail: 6"},
+		"unread count is aggregated across accounts")
+
+	gm.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, a := range i.Accounts {
+			out.Append(outputs.Textf("%s: %d/%d", a.Name, a.TotalUnread(), a.TotalThreads()))
+		}
+		return out
+	})
+	testBar.NextOutput().AssertText([]string{
+		"me@work.example.com: 3/12",
+		"me@home.example.com: 3/12",
+	}, "per-account counts")
+
+	gm.Output(func(i Info) bar.Output {
+		a, ok := i.Account("me@home.example.com")
+		_, other := i.Account("nobody@example.com")
+		return outputs.Textf("%v %d %v %d",
+			ok, a.Unread["My label"], other, i.Unread["My label"])
+	})
+	testBar.NextOutput().AssertText([]string{"true 1 false 2"})
+}
+
 func TestErrors(t *testing.T) {
 	require.Panics(t, func() { New([]byte(`not-a-json-config`)) })
 
@@ -114,7 +151,46 @@ func TestErrors(t *testing.T) {
 	testBar.New(t)
 	gm = New(fakeClientConfig)
 	testBar.Run(gm)
-	testBar.NextOutput().AssertError("error fetching list of labels")
+	out := testBar.NextOutput()
+	out.AssertError("error fetching list of labels")
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput()
+	out.AssertError("error on retry after click")
+	errAt := timing.Now()
+
+	testBar.Tick()
+	testBar.NextOutput().AssertError("error on retry")
+	require.Equal(t, errAt.Add(30*time.Second), timing.Now(),
+		"retries after an error")
+
+	testBar.Tick()
+	out = testBar.NextOutput()
+	out.AssertError("error on retry")
+	require.Equal(t, errAt.Add(90*time.Second), timing.Now(),
+		"backs off on repeated errors")
+
+	out.At(0).LeftClick()
+	testBar.NextOutput().AssertError("error on retry after click")
+
+	setLabels(label{"INBOX", "INBOX", 15, 3})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"Gmail: 3"}, "on recovery")
+	require.Equal(t, errAt.Add(120*time.Second), timing.Now(),
+		"click resets the backoff")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"Gmail: 3"})
+	require.Equal(t, errAt.Add(120*time.Second+5*time.Minute), timing.Now(),
+		"refresh interval restored on success")
+
+	testBar.New(t)
+	gm = New(fakeClientConfig)
+	testBar.Run(gm)
+	testBar.NextOutput().AssertText([]string{"Gmail: 3"})
+	setLabels(label{"INBOX", "INBOX", 15, 4})
+	gm.Refresh()
+	testBar.NextOutput().AssertText([]string{"Gmail: 4"}, "on refresh")
 }
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,406 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package homeassistant provides an i3bar module that shows the state of
+selected Home Assistant entities, e.g. a thermostat, a door sensor, or an
+electric vehicle charger.
+
+The module connects to the Home Assistant WebSocket API using a long-lived
+access token (created from the user's profile page), and updates as soon as
+any of the entities change state. Clicking an entity can call a service,
+e.g. to toggle a light.
+*/
+package homeassistant // import "barista.run/modules/homeassistant"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+
+	"golang.org/x/net/websocket"
+)
+
+// Entity represents the state of a Home Assistant entity.
+type Entity struct {
+	ID          string
+	State       string
+	Attributes  map[string]interface{}
+	LastChanged time.Time
+}
+
+// Name returns the friendly name of the entity, or its ID if it does not
+// have one.
+func (e Entity) Name() string {
+	if name, ok := e.Attributes["friendly_name"].(string); ok && name != "" {
+		return name
+	}
+	return e.ID
+}
+
+// Unit returns the unit of measurement of the entity's state, if any.
+func (e Entity) Unit() string {
+	unit, _ := e.Attributes["unit_of_measurement"].(string)
+	return unit
+}
+
+// Domain returns the domain of the entity, e.g. "light" for "light.kitchen".
+func (e Entity) Domain() string {
+	return strings.SplitN(e.ID, ".", 2)[0]
+}
+
+// Unavailable returns true if Home Assistant cannot reach the device.
+func (e Entity) Unavailable() bool {
+	return e.State == "unavailable" || e.State == "unknown"
+}
+
+// Info represents the current state of the selected entities.
+type Info struct {
+	// Entities are in the order given to New. Entities that do not exist
+	// are omitted.
+	Entities []Entity
+	m        *Module
+}
+
+// Entity returns the state of the given entity.
+func (i Info) Entity(id string) (Entity, bool) {
+	for _, e := range i.Entities {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Entity{}, false
+}
+
+// Call calls a Home Assistant service, e.g. Call("light", "toggle",
+// map[string]interface{}{"entity_id": "light.kitchen"}).
+func (i Info) Call(domain, service string, data map[string]interface{}) error {
+	if i.m == nil {
+		return errors.New("not connected")
+	}
+	return i.m.call(domain, service, data)
+}
+
+// Click calls the service configured for the given entity using OnClick,
+// if any.
+func (i Info) Click(id string) {
+	if i.m == nil {
+		return
+	}
+	svc, ok := i.m.services.Get().(map[string]service)[id]
+	if !ok {
+		return
+	}
+	if err := i.Call(svc.domain, svc.service, svc.data); err != nil {
+		l.Log("Failed to call %s.%s: %v", svc.domain, svc.service, err)
+	}
+}
+
+type service struct {
+	domain, service string
+	data            map[string]interface{}
+}
+
+// Module represents a Home Assistant bar module.
+type Module struct {
+	server     string
+	token      string
+	entities   []string
+	services   value.Value // of map[string]service
+	outputFunc value.Value // of func(Info) bar.Output
+
+	mu     sync.Mutex
+	ws     *websocket.Conn
+	nextID int
+}
+
+// New creates a module that shows the given entities from the Home Assistant
+// server at the given URL (e.g. "http://homeassistant.local:8123"), using a
+// long-lived access token.
+func New(server, token string, entities ...string) *Module {
+	m := &Module{
+		server:   strings.TrimSuffix(server, "/"),
+		token:    token,
+		entities: entities,
+	}
+	l.Register(m, "outputFunc", "services")
+	m.services.Set(map[string]service{})
+	// Default output is a segment for each entity with its state and unit,
+	// which calls the configured service when clicked.
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, e := range i.Entities {
+			e := e
+			text := e.State
+			if unit := e.Unit(); unit != "" {
+				text += " " + unit
+			}
+			out.Append(outputs.Textf("%s: %s", e.Name(), text).
+				OnClick(click.Left(func() { i.Click(e.ID) })))
+		}
+		return out
+	})
+	return m
+}
+
+// OnClick configures a service to call when the entity is clicked. If data
+// is nil, the entity is used as the target, e.g. OnClick("switch.charger",
+// "switch", "toggle", nil).
+func (m *Module) OnClick(entity, domain, svc string, data map[string]interface{}) *Module {
+	if data == nil {
+		data = map[string]interface{}{"entity_id": entity}
+	}
+	services := map[string]service{}
+	for k, v := range m.services.Get().(map[string]service) {
+		services[k] = v
+	}
+	services[entity] = service{domain, svc, data}
+	m.services.Set(services)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+type haState struct {
+	EntityID    string                 `json:"entity_id"`
+	State       string                 `json:"state"`
+	Attributes  map[string]interface{} `json:"attributes"`
+	LastChanged time.Time              `json:"last_changed"`
+}
+
+func (s haState) entity() Entity {
+	return Entity{s.EntityID, s.State, s.Attributes, s.LastChanged}
+}
+
+type message struct {
+	ID      int             `json:"id"`
+	Type    string          `json:"type"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Event struct {
+		EventType string `json:"event_type"`
+		Data      struct {
+			EntityID string   `json:"entity_id"`
+			NewState *haState `json:"new_state"`
+		} `json:"data"`
+	} `json:"event"`
+}
+
+// Message IDs for the initial requests. Service calls use later IDs.
+const (
+	getStatesID = iota + 1
+	subscribeID
+)
+
+func (m *Module) websocketURL() string {
+	u := m.server
+	switch {
+	case strings.HasPrefix(u, "https://"):
+		u = "wss://" + strings.TrimPrefix(u, "https://")
+	case strings.HasPrefix(u, "http://"):
+		u = "ws://" + strings.TrimPrefix(u, "http://")
+	}
+	return u + "/api/websocket"
+}
+
+// connect opens a websocket to Home Assistant and authenticates.
+func (m *Module) connect() (*websocket.Conn, error) {
+	ws, err := websocket.Dial(m.websocketURL(), "", m.server)
+	if err != nil {
+		return nil, err
+	}
+	var msg message
+	if err = websocket.JSON.Receive(ws, &msg); err == nil && msg.Type != "auth_required" {
+		err = fmt.Errorf("unexpected message %q", msg.Type)
+	}
+	if err == nil {
+		err = websocket.JSON.Send(ws, map[string]string{
+			"type":         "auth",
+			"access_token": m.token,
+		})
+	}
+	if err == nil {
+		err = websocket.JSON.Receive(ws, &msg)
+	}
+	if err == nil && msg.Type != "auth_ok" {
+		err = fmt.Errorf("authentication failed: %s", msg.Type)
+	}
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// send sends a command, assigning it the next message ID.
+func (m *Module) send(cmd map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ws == nil {
+		return errors.New("not connected")
+	}
+	m.nextID++
+	cmd["id"] = m.nextID
+	return websocket.JSON.Send(m.ws, cmd)
+}
+
+func (m *Module) call(domain, service string, data map[string]interface{}) error {
+	return m.send(map[string]interface{}{
+		"type":         "call_service",
+		"domain":       domain,
+		"service":      service,
+		"service_data": data,
+	})
+}
+
+func (m *Module) info(states map[string]Entity) Info {
+	i := Info{m: m}
+	for _, id := range m.entities {
+		if e, ok := states[id]; ok {
+			i.Entities = append(i.Entities, e)
+		}
+	}
+	return i
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	ws, err := m.connect()
+	if s.Error(err) {
+		return
+	}
+	defer ws.Close()
+	m.mu.Lock()
+	m.ws, m.nextID = ws, 0
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.ws = nil
+		m.mu.Unlock()
+	}()
+
+	err = m.send(map[string]interface{}{"type": "get_states"})
+	if err == nil {
+		err = m.send(map[string]interface{}{
+			"type":       "subscribe_events",
+			"event_type": "state_changed",
+		})
+	}
+	if s.Error(err) {
+		return
+	}
+
+	msgs := make(chan message)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			var msg message
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				errs <- err
+				return
+			}
+			msgs <- msg
+		}
+	}()
+
+	wanted := map[string]bool{}
+	for _, id := range m.entities {
+		wanted[id] = true
+	}
+	var states map[string]Entity
+	for {
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case err := <-errs:
+			s.Error(err)
+			return
+		case msg := <-msgs:
+			changed, err := m.handle(msg, wanted, &states)
+			if s.Error(err) {
+				return
+			}
+			if !changed {
+				continue
+			}
+		}
+		if states != nil {
+			s.Output(outputFunc(m.info(states)))
+		}
+	}
+}
+
+// handle processes a message from Home Assistant, returning whether any of
+// the selected entities changed.
+func (m *Module) handle(msg message, wanted map[string]bool, states *map[string]Entity) (bool, error) {
+	switch msg.Type {
+	case "result":
+		if !msg.Success {
+			err := fmt.Errorf("%s: %s", msg.Error.Code, msg.Error.Message)
+			if msg.ID > subscribeID {
+				// Failed service calls should not break the module.
+				l.Log("Service call failed: %v", err)
+				return false, nil
+			}
+			return false, err
+		}
+		if msg.ID != getStatesID {
+			return false, nil
+		}
+		var all []haState
+		if err := json.Unmarshal(msg.Result, &all); err != nil {
+			return false, err
+		}
+		*states = map[string]Entity{}
+		for _, st := range all {
+			if wanted[st.EntityID] {
+				(*states)[st.EntityID] = st.entity()
+			}
+		}
+		return true, nil
+	case "event":
+		id := msg.Event.Data.EntityID
+		if msg.Event.EventType != "state_changed" || !wanted[id] || *states == nil {
+			return false, nil
+		}
+		if st := msg.Event.Data.NewState; st != nil {
+			(*states)[id] = st.entity()
+		} else {
+			delete(*states, id)
+		}
+		return true, nil
+	}
+	return false, nil
+}
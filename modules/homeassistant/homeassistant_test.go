@@ -0,0 +1,217 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package homeassistant
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// fakeHA emulates the Home Assistant WebSocket API. Messages sent on events
+// are forwarded to the client, and commands from the client after the initial
+// requests are sent on commands.
+type fakeHA struct {
+	*httptest.Server
+	states   []map[string]interface{}
+	events   chan interface{}
+	commands chan map[string]interface{}
+}
+
+func newFakeHA(states ...map[string]interface{}) *fakeHA {
+	f := &fakeHA{
+		states:   states,
+		events:   make(chan interface{}),
+		commands: make(chan map[string]interface{}, 10),
+	}
+	f.Server = httptest.NewServer(websocket.Handler(f.serve))
+	return f
+}
+
+func (f *fakeHA) serve(ws *websocket.Conn) {
+	if ws.Request().URL.Path != "/api/websocket" {
+		return
+	}
+	websocket.JSON.Send(ws, map[string]string{"type": "auth_required"})
+	var auth map[string]string
+	websocket.JSON.Receive(ws, &auth)
+	if auth["access_token"] != "token" {
+		websocket.JSON.Send(ws, map[string]string{"type": "auth_invalid"})
+		return
+	}
+	websocket.JSON.Send(ws, map[string]string{"type": "auth_ok"})
+	go func() {
+		for {
+			var cmd map[string]interface{}
+			if err := websocket.JSON.Receive(ws, &cmd); err != nil {
+				return
+			}
+			switch cmd["type"] {
+			case "get_states":
+				websocket.JSON.Send(ws, map[string]interface{}{
+					"id": cmd["id"], "type": "result", "success": true, "result": f.states,
+				})
+			case "subscribe_events":
+				websocket.JSON.Send(ws, map[string]interface{}{
+					"id": cmd["id"], "type": "result", "success": true,
+				})
+			default:
+				f.commands <- cmd
+			}
+		}
+	}()
+	for e := range f.events {
+		if e == nil {
+			return
+		}
+		websocket.JSON.Send(ws, e)
+	}
+}
+
+func state(id, value string, attrs map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"entity_id":    id,
+		"state":        value,
+		"attributes":   attrs,
+		"last_changed": "2017-03-01T08:00:00+00:00",
+	}
+}
+
+func stateChanged(id string, newState interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id": 2, "type": "event",
+		"event": map[string]interface{}{
+			"event_type": "state_changed",
+			"data":       map[string]interface{}{"entity_id": id, "new_state": newState},
+		},
+	}
+}
+
+func TestEntity(t *testing.T) {
+	e := Entity{ID: "sensor.temp", Attributes: map[string]interface{}{
+		"friendly_name": "Living room", "unit_of_measurement": "°C",
+	}}
+	require.Equal(t, "Living room", e.Name())
+	require.Equal(t, "°C", e.Unit())
+	require.Equal(t, "sensor", e.Domain())
+	require.False(t, e.Unavailable())
+
+	e = Entity{ID: "binary_sensor.door", State: "unavailable"}
+	require.Equal(t, "binary_sensor.door", e.Name())
+	require.Equal(t, "", e.Unit())
+	require.True(t, e.Unavailable())
+
+	require.Error(t, Info{}.Call("light", "toggle", nil))
+	require.NotPanics(t, func() { Info{}.Click("light.kitchen") })
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	ha := newFakeHA(
+		state("sensor.temp", "21.5", map[string]interface{}{"unit_of_measurement": "°C"}),
+		state("binary_sensor.door", "off", nil),
+		state("light.kitchen", "on", nil),
+	)
+	defer ha.Close()
+
+	m := New(ha.URL+"/", "token", "binary_sensor.door", "sensor.temp", "switch.missing").
+		Output(func(i Info) bar.Output {
+			out := outputs.Group()
+			for _, e := range i.Entities {
+				out.Append(outputs.Textf("%s=%s", e.ID, e.State))
+			}
+			return out
+		})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText(
+		[]string{"binary_sensor.door=off", "sensor.temp=21.5"})
+
+	ha.events <- stateChanged("light.kitchen", state("light.kitchen", "off", nil))
+	testBar.AssertNoOutput("on change of other entity")
+
+	ha.events <- stateChanged("binary_sensor.door", state("binary_sensor.door", "on", nil))
+	testBar.NextOutput("on state change").AssertText(
+		[]string{"binary_sensor.door=on", "sensor.temp=21.5"})
+
+	ha.events <- stateChanged("sensor.temp", nil)
+	testBar.NextOutput("on entity removal").AssertText(
+		[]string{"binary_sensor.door=on"})
+
+	ha.events <- map[string]interface{}{
+		"id": 3, "type": "result", "success": false,
+		"error": map[string]string{"code": "not_found", "message": "Service not found"},
+	}
+	testBar.AssertNoOutput("on failed service call")
+
+	ha.events <- nil
+	testBar.NextOutput("on disconnect").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	ha := newFakeHA(
+		state("sensor.temp", "21.5", map[string]interface{}{
+			"friendly_name": "Thermostat", "unit_of_measurement": "°C",
+		}),
+		state("switch.charger", "off", nil),
+	)
+	defer ha.Close()
+
+	m := New(ha.URL, "token", "sensor.temp", "switch.charger").
+		OnClick("switch.charger", "switch", "toggle", nil).
+		OnClick("sensor.temp", "climate", "set_temperature",
+			map[string]interface{}{"entity_id": "climate.home", "temperature": 22})
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"Thermostat: 21.5 °C", "switch.charger: off"})
+
+	out.At(1).LeftClick()
+	cmd := <-ha.commands
+	require.Equal(t, "call_service", cmd["type"])
+	require.Equal(t, "switch", cmd["domain"])
+	require.Equal(t, "toggle", cmd["service"])
+	require.Equal(t, map[string]interface{}{"entity_id": "switch.charger"}, cmd["service_data"])
+	require.Equal(t, 3.0, cmd["id"])
+
+	out.At(0).LeftClick()
+	cmd = <-ha.commands
+	require.Equal(t, "set_temperature", cmd["service"])
+	require.Equal(t, map[string]interface{}{"entity_id": "climate.home", "temperature": 22.0},
+		cmd["service_data"])
+	require.Equal(t, 4.0, cmd["id"])
+
+	ha.events <- stateChanged("switch.charger", state("switch.charger", "on", nil))
+	testBar.NextOutput("on state change").AssertText(
+		[]string{"Thermostat: 21.5 °C", "switch.charger: on"})
+}
+
+func TestErrors(t *testing.T) {
+	testBar.New(t)
+	ha := newFakeHA()
+	defer ha.Close()
+
+	testBar.Run(New(ha.URL, "wrong-token", "sensor.temp"))
+	testBar.NextOutput("on auth failure").AssertError()
+
+	testBar.New(t)
+	testBar.Run(New("http://127.0.0.1:1", "token", "sensor.temp"))
+	testBar.NextOutput("on connection failure").AssertError()
+}
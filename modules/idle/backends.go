@@ -0,0 +1,103 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idle
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	"barista.run/timing"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/screensaver"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+type x11 struct {
+	once sync.Once
+	conn *xgb.Conn
+	err  error
+}
+
+func (x *x11) connect() (*xgb.Conn, error) {
+	x.once.Do(func() {
+		x.conn, x.err = xgb.NewConn()
+		if x.err == nil {
+			x.err = screensaver.Init(x.conn)
+		}
+	})
+	return x.conn, x.err
+}
+
+func (x *x11) idle() (time.Duration, error) {
+	conn, err := x.connect()
+	if err != nil {
+		return 0, err
+	}
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+	r, err := screensaver.QueryInfo(conn, xproto.Drawable(root)).Reply()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(r.MsSinceUserInput) * time.Millisecond, nil
+}
+
+func (x *x11) reset() error {
+	conn, err := x.connect()
+	if err != nil {
+		return err
+	}
+	return xproto.ForceScreenSaverChecked(conn, xproto.ScreenSaverReset).Check()
+}
+
+var busType = dbus.System
+
+type logind struct {
+	once    sync.Once
+	watcher *dbus.PropertiesWatcher
+}
+
+func (d *logind) w() *dbus.PropertiesWatcher {
+	d.once.Do(func() {
+		// "auto" refers to the session of the calling process, or the
+		// user's display session if it is not part of a session.
+		d.watcher = dbus.WatchProperties(busType,
+			"org.freedesktop.login1",
+			"/org/freedesktop/login1/session/auto",
+			"org.freedesktop.login1.Session",
+		).Add("IdleHint", "IdleSinceHint")
+	})
+	return d.watcher
+}
+
+func (d *logind) idle() (time.Duration, error) {
+	props := d.w().Get()
+	if idle, _ := props["IdleHint"].(bool); !idle {
+		return 0, nil
+	}
+	// IdleSinceHint is in microseconds since the epoch.
+	since, _ := props["IdleSinceHint"].(uint64)
+	idle := timing.Now().Sub(time.Unix(0, int64(since)*int64(time.Microsecond)))
+	if idle < 0 {
+		return 0, nil
+	}
+	return idle, nil
+}
+
+func (d *logind) reset() error {
+	_, err := d.w().Call("SetIdleHint", false)
+	return err
+}
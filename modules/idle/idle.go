@@ -0,0 +1,176 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package idle provides an i3bar module that tracks how long the session has been
+idle, and shows a countdown before the screen is locked, giving the user a
+chance to notice and cancel an imminent lock.
+
+The lock itself is not performed by this module: the lock timeout should match
+the one configured for the screen locker (e.g. xss-lock, xautolock, or
+swayidle).
+
+Under X11, the idle time is read using the MIT-SCREEN-SAVER extension. Otherwise,
+the IdleHint set on the systemd-logind session is used. In that case the idle
+time is measured from when the hint was set, rather than from the last input.
+*/
+package idle // import "barista.run/modules/idle"
+
+import (
+	"os"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info represents the idle state of the session.
+type Info struct {
+	// Idle is how long the session has been idle.
+	Idle time.Duration
+	// LockAfter is the idle time after which the screen will be locked.
+	LockAfter time.Duration
+	reset     func()
+}
+
+// UntilLock returns the time remaining until the screen is locked, or zero
+// if it should already be locked.
+func (i Info) UntilLock() time.Duration {
+	if i.Idle >= i.LockAfter {
+		return 0
+	}
+	return i.LockAfter - i.Idle
+}
+
+// Locked returns true if the idle time has exceeded the lock timeout.
+func (i Info) Locked() bool {
+	return i.Idle >= i.LockAfter
+}
+
+// Cancel resets the idle time, postponing the lock.
+func (i Info) Cancel() {
+	i.reset()
+}
+
+// backend provides the idle time of the session.
+type backend interface {
+	idle() (time.Duration, error)
+	reset() error
+}
+
+// Module represents an idle countdown bar module.
+type Module struct {
+	backend    backend
+	lockAfter  value.Value // of time.Duration
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(b backend, lockAfter time.Duration) *Module {
+	m := &Module{backend: b, scheduler: timing.NewScheduler()}
+	l.Register(m, "outputFunc", "lockAfter", "scheduler")
+	m.LockAfter(lockAfter)
+	// Default output is an urgent countdown in the 30 seconds before the
+	// screen is locked, which cancels the lock when clicked.
+	m.Output(func(i Info) bar.Output {
+		if i.Locked() || i.UntilLock() > 30*time.Second {
+			return nil
+		}
+		return outputs.Textf("lock in %s", i.UntilLock().Round(time.Second)).
+			Urgent(true).
+			OnClick(click.Left(i.Cancel))
+	})
+	m.RefreshInterval(time.Second)
+	return m
+}
+
+// New creates a module that counts down to the screen being locked after
+// the session has been idle for the given duration. It uses X11 if available,
+// and systemd-logind otherwise.
+func New(lockAfter time.Duration) *Module {
+	if os.Getenv("DISPLAY") != "" {
+		return X11(lockAfter)
+	}
+	return Logind(lockAfter)
+}
+
+// X11 creates a module that uses the X11 screen saver extension to track the
+// time since the last user input.
+func X11(lockAfter time.Duration) *Module {
+	return newModule(&x11{}, lockAfter)
+}
+
+// Logind creates a module that uses the IdleHint of the systemd-logind session.
+func Logind(lockAfter time.Duration) *Module {
+	return newModule(&logind{}, lockAfter)
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// LockAfter sets the idle time after which the screen is locked.
+func (m *Module) LockAfter(lockAfter time.Duration) *Module {
+	m.lockAfter.Set(lockAfter)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for the idle time.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	reset := func() {
+		if err := m.backend.reset(); err != nil {
+			l.Log("Failed to reset idle time: %v", err)
+		}
+	}
+	getInfo := func() (Info, error) {
+		idle, err := m.backend.idle()
+		return Info{
+			Idle:      idle,
+			LockAfter: m.lockAfter.Get().(time.Duration),
+			reset:     reset,
+		}, err
+	}
+	info, err := getInfo()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextLockAfter, doneLockAfter := m.lockAfter.Subscribe()
+	defer doneLockAfter()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputFunc(info))
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextLockAfter:
+			info.LockAfter = m.lockAfter.Get().(time.Duration)
+		case <-m.scheduler.C:
+			info, err = getInfo()
+		}
+	}
+}
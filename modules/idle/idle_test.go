@@ -0,0 +1,159 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idle
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testBackend struct {
+	sync.Mutex
+	idleTime time.Duration
+	err      error
+	resets   int
+}
+
+func (t *testBackend) idle() (time.Duration, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.idleTime, t.err
+}
+
+func (t *testBackend) reset() error {
+	t.Lock()
+	defer t.Unlock()
+	t.resets++
+	t.idleTime = 0
+	return nil
+}
+
+func (t *testBackend) set(idle time.Duration, err error) {
+	t.Lock()
+	defer t.Unlock()
+	t.idleTime, t.err = idle, err
+}
+
+func TestInfo(t *testing.T) {
+	i := Info{Idle: 4 * time.Minute, LockAfter: 5 * time.Minute}
+	require.Equal(t, time.Minute, i.UntilLock())
+	require.False(t, i.Locked())
+	i.Idle = 6 * time.Minute
+	require.Equal(t, time.Duration(0), i.UntilLock())
+	require.True(t, i.Locked())
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	b := &testBackend{}
+	m := newModule(b, 5*time.Minute).Output(func(i Info) bar.Output {
+		return outputs.Textf("%s/%s", i.Idle, i.UntilLock()).
+			OnClick(func(bar.Event) { i.Cancel() })
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"0s/5m0s"})
+
+	b.set(4*time.Minute, nil)
+	testBar.Tick()
+	out := testBar.NextOutput("on tick")
+	out.AssertText([]string{"4m0s/1m0s"})
+
+	m.LockAfter(10 * time.Minute)
+	testBar.NextOutput("on lock timeout change").AssertText([]string{"4m0s/6m0s"})
+
+	out.At(0).LeftClick()
+	testBar.Tick()
+	testBar.NextOutput("after cancel").AssertText([]string{"0s/10m0s"})
+	require.Equal(t, 1, b.resets)
+
+	b.set(0, errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	b := &testBackend{}
+	testBar.Run(newModule(b, 5*time.Minute))
+	testBar.NextOutput("on start").AssertEmpty()
+
+	b.set(4*time.Minute, nil)
+	testBar.Tick()
+	testBar.NextOutput("before warning").AssertEmpty()
+
+	b.set(4*time.Minute+45*time.Second+300*time.Millisecond, nil)
+	testBar.Tick()
+	out := testBar.NextOutput("during warning")
+	out.AssertText([]string{"lock in 15s"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	out.At(0).LeftClick()
+	testBar.Tick()
+	testBar.NextOutput("after cancel").AssertEmpty()
+	require.Equal(t, 1, b.resets)
+
+	b.set(6*time.Minute, nil)
+	testBar.Tick()
+	testBar.NextOutput("when locked").AssertEmpty()
+}
+
+func TestLogind(t *testing.T) {
+	busType = dbus.Test
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService("org.freedesktop.login1")
+	obj := srv.Object("/org/freedesktop/login1/session/auto", "org.freedesktop.login1.Session")
+	obj.SetProperties(map[string]interface{}{
+		"IdleHint":      false,
+		"IdleSinceHint": uint64(0),
+	}, dbus.SignalTypeNone)
+	var hints []bool
+	obj.On("SetIdleHint", func(args ...interface{}) ([]interface{}, error) {
+		hints = append(hints, args[0].(bool))
+		return nil, nil
+	})
+
+	timing.TestMode()
+	l := &logind{}
+	idle, err := l.idle()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), idle)
+
+	since := timing.Now().Add(-90 * time.Second)
+	obj.SetProperties(map[string]interface{}{
+		"IdleHint":      true,
+		"IdleSinceHint": uint64(since.UnixNano() / 1000),
+	}, dbus.SignalTypeChanged)
+	for hint, _ := l.w().Get()["IdleHint"].(bool); !hint; {
+		<-l.w().Updates
+		hint, _ = l.w().Get()["IdleHint"].(bool)
+	}
+	idle, err = l.idle()
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Second, idle)
+
+	require.NoError(t, l.reset())
+	require.Equal(t, []bool{false}, hints)
+}
@@ -0,0 +1,267 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package kube provides an i3bar module that shows the current kubernetes
+context and namespace, along with the number of pods that are not ready in
+selected namespaces.
+
+The kubeconfig is located the same way as kubectl does, using $KUBECONFIG if
+set, and ~/.kube/config otherwise. Switching contexts from the bar updates the
+kubeconfig, so the change is also visible to kubectl.
+*/
+package kube // import "barista.run/modules/kube"
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Info represents the current kubernetes context and workload health.
+type Info struct {
+	// Context is the name of the current kubeconfig context.
+	Context string
+	// Cluster is the name of the cluster used by the current context.
+	Cluster string
+	// Namespace is the default namespace of the current context.
+	Namespace string
+	// Contexts lists the names of all contexts in the kubeconfig, sorted.
+	Contexts []string
+	// NotReady is the number of pods that are not ready, keyed by namespace.
+	NotReady map[string]int
+	// For switching contexts.
+	useContext func(string)
+}
+
+// TotalNotReady returns the number of pods that are not ready across all
+// watched namespaces.
+func (i Info) TotalNotReady() int {
+	total := 0
+	for _, c := range i.NotReady {
+		total += c
+	}
+	return total
+}
+
+// UseContext switches the current kubeconfig context to the given context.
+func (i Info) UseContext(name string) {
+	if i.useContext != nil {
+		i.useContext(name)
+	}
+}
+
+// NextContext switches to the context following the current one, in sorted
+// order, wrapping around at the end.
+func (i Info) NextContext() {
+	if len(i.Contexts) == 0 {
+		return
+	}
+	next := 0
+	for idx, c := range i.Contexts {
+		if c == i.Context {
+			next = (idx + 1) % len(i.Contexts)
+			break
+		}
+	}
+	i.UseContext(i.Contexts[next])
+}
+
+// Module represents a kubernetes bar module.
+type Module struct {
+	namespaces []string
+	rules      *clientcmd.ClientConfigLoadingRules
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a kubernetes module that counts pods that are not ready in the
+// given namespaces. If no namespaces are given, the default namespace of the
+// current context is used.
+func New(namespaces ...string) *Module {
+	m := &Module{
+		namespaces: namespaces,
+		rules:      clientcmd.NewDefaultClientConfigLoadingRules(),
+		scheduler:  timing.NewScheduler(),
+	}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is context/namespace, with the count of pods that are
+	// not ready (if any), and cycles through contexts on click.
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Textf("%s/%s", i.Context, i.Namespace)
+		if n := i.TotalNotReady(); n > 0 {
+			out = outputs.Textf("%s/%s (%d not ready)", i.Context, i.Namespace, n).
+				Urgent(true)
+		}
+		return out.OnClick(click.Left(i.NextContext))
+	})
+	m.RefreshInterval(time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Refresh re-reads the kubeconfig and fetches updated pod information.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.fetch()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.fetch()
+		case <-m.refreshCh:
+			if err != nil {
+				s(nil)
+			}
+			info, err = m.fetch()
+		}
+	}
+}
+
+// newClient creates a kubernetes client from the rest config, and can be
+// replaced in tests to use a fake clientset.
+var newClient = func(c *rest.Config) (kubernetes.Interface, error) {
+	return kubernetes.NewForConfig(c)
+}
+
+// requestTimeout bounds each request to the API server, so that an
+// unreachable cluster does not block the bar indefinitely.
+const requestTimeout = 10 * time.Second
+
+func (m *Module) fetch() (Info, error) {
+	raw, err := m.rules.Load()
+	if err != nil {
+		return Info{}, err
+	}
+	i := Info{
+		Context:    raw.CurrentContext,
+		NotReady:   map[string]int{},
+		useContext: m.useContext,
+	}
+	for name := range raw.Contexts {
+		i.Contexts = append(i.Contexts, name)
+	}
+	sort.Strings(i.Contexts)
+	kubeCtx, ok := raw.Contexts[raw.CurrentContext]
+	if !ok {
+		return i, fmt.Errorf("kube: context %q not found", raw.CurrentContext)
+	}
+	i.Cluster = kubeCtx.Cluster
+	i.Namespace = kubeCtx.Namespace
+	if i.Namespace == "" {
+		i.Namespace = metav1.NamespaceDefault
+	}
+	cfg, err := clientcmd.NewDefaultClientConfig(*raw, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return i, err
+	}
+	cfg.Timeout = requestTimeout
+	client, err := newClient(cfg)
+	if err != nil {
+		return i, err
+	}
+	namespaces := m.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{i.Namespace}
+	}
+	for _, ns := range namespaces {
+		pods, err := client.CoreV1().Pods(ns).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return i, err
+		}
+		notReady := 0
+		for _, p := range pods.Items {
+			if !podReady(p) {
+				notReady++
+			}
+		}
+		i.NotReady[ns] = notReady
+	}
+	return i, nil
+}
+
+// podReady returns true if the pod is either running and ready, or has
+// completed successfully. Completed pods (e.g. from jobs) are not expected to
+// be ready, so they are not counted as unhealthy.
+func podReady(p corev1.Pod) bool {
+	switch p.Status.Phase {
+	case corev1.PodSucceeded:
+		return true
+	case corev1.PodRunning:
+		for _, c := range p.Status.Conditions {
+			if c.Type == corev1.PodReady {
+				return c.Status == corev1.ConditionTrue
+			}
+		}
+	}
+	return false
+}
+
+func (m *Module) useContext(name string) {
+	raw, err := m.rules.Load()
+	if err != nil {
+		l.Log("%s: failed to load kubeconfig: %v", l.ID(m), err)
+		return
+	}
+	if _, ok := raw.Contexts[name]; !ok {
+		l.Log("%s: unknown context %s", l.ID(m), name)
+		return
+	}
+	raw.CurrentContext = name
+	if err := clientcmd.ModifyConfig(m.rules, *raw, false); err != nil {
+		l.Log("%s: failed to switch context: %v", l.ID(m), err)
+		return
+	}
+	m.Refresh()
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const kubeconfig = `
+apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster: {server: "https://dev.example.com"}
+- name: prod-cluster
+  cluster: {server: "https://prod.example.com"}
+contexts:
+- name: dev
+  context: {cluster: dev-cluster, namespace: apps}
+- name: prod
+  context: {cluster: prod-cluster}
+users: []
+`
+
+func pod(ns, name string, phase corev1.PodPhase, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Status: corev1.PodStatus{
+			Phase: phase,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: status},
+			},
+		},
+	}
+}
+
+func setup(t *testing.T) (cleanup func()) {
+	dir, err := ioutil.TempDir("", "kube-test")
+	require.NoError(t, err)
+	cfgFile := filepath.Join(dir, "config")
+	require.NoError(t, ioutil.WriteFile(cfgFile, []byte(kubeconfig), 0600))
+	oldEnv := os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
+	os.Setenv(clientcmd.RecommendedConfigPathEnvVar, cfgFile)
+
+	clients := map[string]kubernetes.Interface{
+		"https://dev.example.com": fake.NewSimpleClientset(
+			pod("apps", "web-1", corev1.PodRunning, true),
+			pod("apps", "web-2", corev1.PodRunning, false),
+			pod("apps", "migrate", corev1.PodSucceeded, false),
+			pod("apps", "worker", corev1.PodPending, false),
+			pod("kube-system", "dns", corev1.PodRunning, true),
+			pod("kube-system", "proxy", corev1.PodFailed, false),
+		),
+		"https://prod.example.com": fake.NewSimpleClientset(
+			pod("default", "api", corev1.PodRunning, true),
+		),
+	}
+	newClient = func(c *rest.Config) (kubernetes.Interface, error) {
+		return clients[c.Host], nil
+	}
+	return func() {
+		os.Setenv(clientcmd.RecommendedConfigPathEnvVar, oldEnv)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestModule(t *testing.T) {
+	defer setup(t)()
+	testBar.New(t)
+	m := New()
+	testBar.Run(m)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"dev/apps (2 not ready)"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on context switch").AssertText(
+		[]string{"prod/default"})
+
+	cfg, err := clientcmd.LoadFromFile(os.Getenv(clientcmd.RecommendedConfigPathEnvVar))
+	require.NoError(t, err)
+	require.Equal(t, "prod", cfg.CurrentContext, "kubeconfig is updated")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s@%s %v", i.Context, i.Cluster, i.Contexts).
+			OnClick(func(bar.Event) { i.NextContext() })
+	})
+	out = testBar.NextOutput("on output change")
+	out.AssertText([]string{"prod@prod-cluster [dev prod]"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("wraps around").AssertText(
+		[]string{"dev@dev-cluster [dev prod]"})
+}
+
+func TestNamespaces(t *testing.T) {
+	defer setup(t)()
+	testBar.New(t)
+	m := New("apps", "kube-system").Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d/%d",
+			i.NotReady["apps"], i.NotReady["kube-system"], i.TotalNotReady())
+	})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"2/1/3"})
+
+	Info{Contexts: []string{"dev", "prod"}, useContext: m.useContext}.
+		UseContext("nonexistent")
+	testBar.AssertNoOutput("on switch to unknown context")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"2/1/3"},
+		"context is unchanged")
+}
+
+func TestErrors(t *testing.T) {
+	defer setup(t)()
+	testBar.New(t)
+	os.Setenv(clientcmd.RecommendedConfigPathEnvVar, "/nonexistent/kubeconfig")
+	m := New()
+	testBar.Run(m)
+	testBar.NextOutput().AssertError("with missing kubeconfig")
+
+	require.NotPanics(t, func() {
+		Info{}.NextContext()
+		Info{Contexts: []string{"a"}}.NextContext()
+	})
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package live provides an i3bar module that shows which of the followed
+// channels on Twitch are currently streaming, using the Helix API.
+package live // import "barista.run/modules/live"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/oauth"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/twitch"
+)
+
+// Stream represents a live stream on a followed channel.
+type Stream struct {
+	// Channel is the login name of the channel, as used in its URL.
+	Channel string
+	// Name is the display name of the channel.
+	Name    string
+	Game    string
+	Title   string
+	Viewers int
+	Started time.Time
+}
+
+// URL returns the web page for the stream.
+func (s Stream) URL() string {
+	return "https://www.twitch.tv/" + s.Channel
+}
+
+// Info represents the live streams on followed channels.
+type Info struct {
+	// Streams are ordered by the number of viewers, highest first.
+	Streams []Stream
+}
+
+// Live returns the number of followed channels that are live.
+func (i Info) Live() int {
+	return len(i.Streams)
+}
+
+// Top returns the live stream with the most viewers.
+func (i Info) Top() (Stream, bool) {
+	if len(i.Streams) == 0 {
+		return Stream{}, false
+	}
+	return i.Streams[0], true
+}
+
+// Module represents a live channels bar module.
+type Module struct {
+	clientID   string
+	config     *oauth.Config
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	userID     string
+}
+
+// New creates a module that shows live streams on Twitch using the given
+// clientID and secret, which must be registered at https://dev.twitch.tv.
+func New(clientID, clientSecret string) *Module {
+	config := oauth.Register(&oauth2.Config{
+		Endpoint:     twitch.Endpoint,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"user:read:follows"},
+	})
+	m := &Module{
+		clientID:  clientID,
+		config:    config,
+		scheduler: timing.NewScheduler().Named("live"),
+	}
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the number of live channels and the top channel,
+	// which opens the stream when clicked.
+	m.Output(func(i Info) bar.Output {
+		s, ok := i.Top()
+		if !ok {
+			return nil
+		}
+		out := outputs.Textf("● %s", s.Name)
+		if i.Live() > 1 {
+			out = outputs.Textf("● %d live: %s", i.Live(), s.Name)
+		}
+		return out.OnClick(click.RunLeft("xdg-open", s.URL()))
+	})
+	m.RefreshInterval(2 * time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency for live streams.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// for tests, to wrap the client in a transport that redirects requests.
+var wrapForTest func(*http.Client)
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	client, _ := m.config.Client()
+	if wrapForTest != nil {
+		wrapForTest(client)
+	}
+	info, err := m.fetch(client)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.fetch(client)
+		}
+	}
+}
+
+type helixStreams struct {
+	Data []struct {
+		UserLogin   string    `json:"user_login"`
+		UserName    string    `json:"user_name"`
+		GameName    string    `json:"game_name"`
+		Title       string    `json:"title"`
+		ViewerCount int       `json:"viewer_count"`
+		StartedAt   time.Time `json:"started_at"`
+	}
+}
+
+type helixUsers struct {
+	Data []struct {
+		ID string `json:"id"`
+	}
+}
+
+func (m *Module) get(client *http.Client, path string, out interface{}) error {
+	req, err := http.NewRequest("GET", "https://api.twitch.tv/helix"+path, nil)
+	if err != nil {
+		return err
+	}
+	// Helix requires the client ID in addition to the user's token.
+	req.Header.Set("Client-Id", m.clientID)
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP Status %d", res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (m *Module) fetch(client *http.Client) (Info, error) {
+	if m.userID == "" {
+		users := helixUsers{}
+		if err := m.get(client, "/users", &users); err != nil {
+			return Info{}, err
+		}
+		if len(users.Data) == 0 {
+			return Info{}, errors.New("no user for token")
+		}
+		m.userID = users.Data[0].ID
+	}
+	streams := helixStreams{}
+	if err := m.get(client, "/streams/followed?first=100&user_id="+m.userID, &streams); err != nil {
+		return Info{}, err
+	}
+	i := Info{}
+	for _, s := range streams.Data {
+		i.Streams = append(i.Streams, Stream{
+			Channel: s.UserLogin,
+			Name:    s.UserName,
+			Game:    s.GameName,
+			Title:   s.Title,
+			Viewers: s.ViewerCount,
+			Started: s.StartedAt,
+		})
+	}
+	sort.SliceStable(i.Streams, func(a, b int) bool {
+		return i.Streams[a].Viewers > i.Streams[b].Viewers
+	})
+	return i, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package live
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/testing/httpclient"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	mu          sync.Mutex
+	streamsJSON string
+	usersCalls  int
+	lastQuery   string
+	clientID    string
+)
+
+func respondWith(streams string) {
+	mu.Lock()
+	defer mu.Unlock()
+	streamsJSON = streams
+}
+
+func requests() (users int, userID, client string) {
+	mu.Lock()
+	defer mu.Unlock()
+	return usersCalls, lastQuery, clientID
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	mu.Lock()
+	usersCalls = 0
+	mu.Unlock()
+	respondWith(`{"data": [
+		{"user_login": "b", "user_name": "B", "game_name": "Chess", "title": "blitz",
+		 "viewer_count": 10, "started_at": "2017-03-01T08:00:00Z"},
+		{"user_login": "a", "user_name": "A", "viewer_count": 250}
+	]}`)
+	m := New("clientid", "clientsecret").Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, s := range i.Streams {
+			out.Append(outputs.Textf("%s/%s/%d", s.Name, s.Game, s.Viewers))
+		}
+		return out
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"A//250", "B/Chess/10"})
+
+	users, userID, client := requests()
+	require.Equal(t, 1, users)
+	require.Equal(t, "42", userID)
+	require.Equal(t, "clientid", client)
+
+	respondWith(`{"data": []}`)
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertEmpty()
+	users, _, _ = requests()
+	require.Equal(t, 1, users, "user ID is cached")
+
+	respondWith(`invalid`)
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	respondWith(`{"data": [{"user_login": "b", "user_name": "Bee", "viewer_count": 10,
+		"started_at": "2017-03-01T08:00:00Z"}]}`)
+	testBar.Run(New("clientid", "clientsecret"))
+	testBar.NextOutput("on start").AssertText([]string{"● Bee"})
+
+	respondWith(`{"data": [
+		{"user_login": "b", "user_name": "Bee", "viewer_count": 10},
+		{"user_login": "a", "user_name": "Ay", "viewer_count": 20}
+	]}`)
+	testBar.Tick()
+	testBar.NextOutput("with multiple streams").AssertText([]string{"● 2 live: Ay"})
+
+	respondWith(`{"data": []}`)
+	testBar.Tick()
+	testBar.NextOutput("when none are live").AssertEmpty()
+}
+
+func TestInfo(t *testing.T) {
+	_, ok := Info{}.Top()
+	require.False(t, ok)
+	s := Stream{Channel: "foo", Started: time.Now()}
+	require.Equal(t, "https://www.twitch.tv/foo", s.URL())
+}
+
+func TestMain(m *testing.M) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/helix/users", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		usersCalls++
+		io.WriteString(w, `{"data": [{"id": "42", "login": "me"}]}`)
+	})
+	mux.HandleFunc("/helix/streams/followed", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		lastQuery = r.URL.Query().Get("user_id")
+		clientID = r.Header.Get("Client-Id")
+		io.WriteString(w, streamsJSON)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	wrapForTest = func(c *http.Client) {
+		httpclient.FreezeOauthToken(c, "authtoken-placeholder")
+		httpclient.Wrap(c, server.URL)
+	}
+	timing.TestMode()
+
+	os.Exit(m.Run())
+}
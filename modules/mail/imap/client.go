@@ -0,0 +1,211 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// client is a minimal IMAP (RFC 3501) client, supporting only what is needed
+// to count the unread messages in a mailbox and wait for changes using IDLE
+// (RFC 2177).
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+func newClient(conn net.Conn) (*client, error) {
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return nil, fmt.Errorf("imap: unexpected greeting %q", greeting)
+	}
+	return c, nil
+}
+
+// readLine reads a single response line, discarding the contents of any
+// literals, since none of the responses used by the module need them.
+func (c *client) readLine() (string, error) {
+	var line strings.Builder
+	for {
+		l, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		l = strings.TrimRight(l, "\r\n")
+		line.WriteString(l)
+		size, ok := literalSize(l)
+		if !ok {
+			return line.String(), nil
+		}
+		if _, err := io.CopyN(ioutil.Discard, c.r, size); err != nil {
+			return "", err
+		}
+	}
+}
+
+// literalSize returns the size of the literal announced at the end of a line,
+// e.g. "{12}".
+func literalSize(line string) (int64, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	start := strings.LastIndexByte(line, '{')
+	if start < 0 {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(line[start+1:len(line)-1], 10, 64)
+	return size, err == nil
+}
+
+func (c *client) nextTag() string {
+	c.tag++
+	return fmt.Sprintf("a%d", c.tag)
+}
+
+// command sends a command and returns the untagged responses received before
+// it completed, without the leading "* ".
+func (c *client) command(cmd string) ([]string, error) {
+	tag := c.nextTag()
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, err
+	}
+	return c.response(tag)
+}
+
+func (c *client) response(tag string) ([]string, error) {
+	var untagged []string
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, "* ") {
+			untagged = append(untagged, line[2:])
+			continue
+		}
+		status := strings.TrimPrefix(line, tag+" ")
+		if status == line {
+			// Continuation requests or responses to other commands.
+			continue
+		}
+		if strings.HasPrefix(status, "OK") {
+			return untagged, nil
+		}
+		return untagged, fmt.Errorf("imap: %s", status)
+	}
+}
+
+// quote returns s as an IMAP quoted string.
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+func (c *client) login(username, password string) error {
+	_, err := c.command("LOGIN " + quote(username) + " " + quote(password))
+	return err
+}
+
+func (c *client) capabilities() (map[string]bool, error) {
+	resp, err := c.command("CAPABILITY")
+	if err != nil {
+		return nil, err
+	}
+	caps := map[string]bool{}
+	for _, r := range resp {
+		fields := strings.Fields(r)
+		if len(fields) == 0 || fields[0] != "CAPABILITY" {
+			continue
+		}
+		for _, f := range fields[1:] {
+			caps[strings.ToUpper(f)] = true
+		}
+	}
+	return caps, nil
+}
+
+// examine selects a mailbox in read-only mode, so that checking for unread
+// messages never changes their flags.
+func (c *client) examine(mailbox string) error {
+	_, err := c.command("EXAMINE " + quote(mailbox))
+	return err
+}
+
+// unseen returns the number of unread messages in the selected mailbox.
+func (c *client) unseen() (int, error) {
+	resp, err := c.command("SEARCH UNSEEN")
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, r := range resp {
+		fields := strings.Fields(r)
+		if len(fields) > 0 && fields[0] == "SEARCH" {
+			count += len(fields) - 1
+		}
+	}
+	return count, nil
+}
+
+// idle waits until the server reports a change to the selected mailbox, or
+// until the timeout elapses. Servers may drop connections that are idle for
+// more than 30 minutes, so the timeout should be less than that.
+func (c *client) idle(timeout time.Duration) error {
+	tag := c.nextTag()
+	if _, err := fmt.Fprintf(c.conn, "%s IDLE\r\n", tag); err != nil {
+		return err
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+") {
+		return fmt.Errorf("imap: IDLE rejected: %s", line)
+	}
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		line, err = c.readLine()
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// "* OK" responses are keepalives, anything else (EXISTS, EXPUNGE,
+		// FETCH with new flags, ...) indicates a change to the mailbox.
+		if strings.HasPrefix(line, "* ") && !strings.HasPrefix(line, "* OK") {
+			break
+		}
+	}
+	c.conn.SetReadDeadline(time.Time{})
+	if _, err := fmt.Fprint(c.conn, "DONE\r\n"); err != nil {
+		return err
+	}
+	_, err = c.response(tag)
+	return err
+}
@@ -0,0 +1,220 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package imap provides an i3bar module that shows the number of unread messages
+in folders of an IMAP mailbox, for mail providers other than Gmail (e.g.
+Fastmail, Outlook, or a self-hosted server).
+
+Connections always use TLS (IMAPS, port 993 by default), and authenticate using
+a username and password. Since the password is stored in the bar's
+configuration, an app-specific password should be used where the provider
+supports it.
+
+Rather than polling, the module keeps a connection open for each folder and
+uses IMAP IDLE to be notified of changes, so the server must support IDLE.
+Folder names are used as-is, so folders with non-ASCII names must be given in
+IMAP's modified UTF-7 encoding.
+*/
+package imap // import "barista.run/modules/mail/imap"
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Info represents the unread message counts for folders.
+type Info struct {
+	// Unread is the number of unread messages, keyed by folder name.
+	Unread map[string]int
+}
+
+// TotalUnread is the total number of unread messages across all folders.
+func (i Info) TotalUnread() int {
+	t := 0
+	for _, u := range i.Unread {
+		t += u
+	}
+	return t
+}
+
+// Module represents an IMAP unread mail bar module.
+type Module struct {
+	addr       string
+	username   string
+	password   string
+	folders    []string
+	timeout    time.Duration
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a module that shows unread message counts for the given folders
+// (INBOX by default) on the given server. The server may include a port,
+// otherwise the standard IMAPS port 993 is used.
+func New(server, username, password string, folders ...string) *Module {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "993")
+	}
+	if len(folders) == 0 {
+		folders = []string{"INBOX"}
+	}
+	m := &Module{
+		addr:     server,
+		username: username,
+		password: password,
+		folders:  folders,
+		timeout:  idleTimeout,
+	}
+	l.Register(m, "outputFunc")
+	l.Labelf(m, "%s@%s", username, server)
+	// Default output is the total number of unread messages, if any.
+	m.Output(func(i Info) bar.Output {
+		if i.TotalUnread() == 0 {
+			return nil
+		}
+		return outputs.Textf("Mail: %d", i.TotalUnread())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// dial connects to the server. It can be replaced in tests.
+var dial = func(addr string) (net.Conn, error) {
+	return tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, nil)
+}
+
+// idleTimeout is how long to wait in IDLE before re-checking the unread
+// count. RFC 2177 recommends re-issuing IDLE at least every 29 minutes.
+var idleTimeout = 25 * time.Minute
+
+type update struct {
+	folder string
+	unread int
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	done := make(chan struct{})
+	defer close(done)
+	updates := make(chan update)
+	errs := make(chan error, len(m.folders))
+	for _, f := range m.folders {
+		go func(folder string) {
+			errs <- m.watch(folder, updates, done)
+		}(f)
+	}
+
+	info := Info{Unread: map[string]int{}}
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, doneSub := m.outputFunc.Subscribe()
+	defer doneSub()
+
+	for {
+		select {
+		case u := <-updates:
+			unread := map[string]int{u.folder: u.unread}
+			for f, c := range info.Unread {
+				if f != u.folder {
+					unread[f] = c
+				}
+			}
+			info.Unread = unread
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case err := <-errs:
+			s.Error(err)
+			return
+		}
+		// Wait for the initial count of each folder before the first output.
+		if len(info.Unread) == len(m.folders) {
+			s.Output(outputFunc(info))
+		}
+	}
+}
+
+// watch sends the unread count of a folder on updates whenever it changes,
+// until done is closed.
+func (m *Module) watch(folder string, updates chan<- update, done <-chan struct{}) error {
+	conn, err := dial(m.addr)
+	if err != nil {
+		return err
+	}
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-done:
+		case <-closed:
+		}
+		conn.Close()
+	}()
+	err = m.watchConn(conn, folder, updates, done)
+	select {
+	case <-done:
+		// Errors caused by closing the connection are expected.
+		return nil
+	default:
+		return err
+	}
+}
+
+func (m *Module) watchConn(conn net.Conn, folder string, updates chan<- update, done <-chan struct{}) error {
+	c, err := newClient(conn)
+	if err != nil {
+		return err
+	}
+	if err := c.login(m.username, m.password); err != nil {
+		return err
+	}
+	caps, err := c.capabilities()
+	if err != nil {
+		return err
+	}
+	if !caps["IDLE"] {
+		return errors.New("imap: server does not support IDLE")
+	}
+	if err := c.examine(folder); err != nil {
+		return err
+	}
+	last := -1
+	for {
+		unread, err := c.unseen()
+		if err != nil {
+			return err
+		}
+		if unread != last {
+			last = unread
+			select {
+			case updates <- update{folder, unread}:
+			case <-done:
+				return nil
+			}
+		}
+		if err := c.idle(m.timeout); err != nil {
+			return err
+		}
+	}
+}
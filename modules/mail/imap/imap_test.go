@@ -0,0 +1,297 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer is a minimal IMAP server, supporting only the commands used by
+// the module.
+type fakeServer struct {
+	sync.Mutex
+	unread   map[string]int
+	noIdle   bool
+	addrs    []string
+	idling   map[*serverConn]string
+	commands []string
+}
+
+type serverConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *serverConn) send(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.Conn, format+"\r\n", args...)
+}
+
+var server *fakeServer
+
+func newServer(unread map[string]int) *fakeServer {
+	server = &fakeServer{unread: unread, idling: map[*serverConn]string{}}
+	return server
+}
+
+func init() {
+	dial = func(addr string) (net.Conn, error) {
+		server.Lock()
+		defer server.Unlock()
+		server.addrs = append(server.addrs, addr)
+		client, conn := net.Pipe()
+		go server.serve(&serverConn{Conn: conn})
+		return client, nil
+	}
+}
+
+// setUnread changes the unread count of a folder, and notifies idling
+// connections if notify is true.
+func (s *fakeServer) setUnread(folder string, unread int, notify bool) {
+	s.Lock()
+	s.unread[folder] = unread
+	var conns []*serverConn
+	for c, f := range s.idling {
+		if f == folder {
+			conns = append(conns, c)
+		}
+	}
+	s.Unlock()
+	if notify {
+		for _, c := range conns {
+			c.send("* OK Still here")
+			c.send("* %d EXISTS", unread)
+		}
+	}
+}
+
+func (s *fakeServer) takeCommands() []string {
+	s.Lock()
+	defer s.Unlock()
+	c := s.commands
+	s.commands = nil
+	return c
+}
+
+func (s *fakeServer) serve(c *serverConn) {
+	defer c.Close()
+	c.send("* OK IMAP4rev1 Service Ready")
+	r := bufio.NewReader(c)
+	folder := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		parts := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		tag, cmd, args := parts[0], parts[1], ""
+		if len(parts) > 2 {
+			args = parts[2]
+		}
+		s.Lock()
+		s.commands = append(s.commands, cmd)
+		unread, exists := s.unread[strings.Trim(args, `"`)]
+		noIdle := s.noIdle
+		count := s.unread[folder]
+		s.Unlock()
+		switch cmd {
+		case "LOGIN":
+			if args != `"me@example.com" "app \"password\""` {
+				c.send("%s NO [AUTHENTICATIONFAILED] Invalid credentials", tag)
+				continue
+			}
+		case "CAPABILITY":
+			if noIdle {
+				c.send("* CAPABILITY IMAP4rev1")
+			} else {
+				c.send("* CAPABILITY IMAP4rev1 IDLE")
+			}
+		case "EXAMINE":
+			if !exists {
+				c.send("%s NO Mailbox doesn't exist", tag)
+				continue
+			}
+			folder = strings.Trim(args, `"`)
+			c.send("* %d EXISTS", unread)
+			c.send("* FLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)")
+		case "SEARCH":
+			ids := []string{"SEARCH"}
+			for i := 1; i <= count; i++ {
+				ids = append(ids, fmt.Sprintf("%d", i))
+			}
+			c.send("* %s", strings.Join(ids, " "))
+		case "IDLE":
+			c.send("+ idling")
+			s.Lock()
+			s.idling[c] = folder
+			s.Unlock()
+			_, err := r.ReadString('\n')
+			s.Lock()
+			delete(s.idling, c)
+			s.Unlock()
+			if err != nil {
+				return
+			}
+			c.send("%s OK IDLE terminated", tag)
+			continue
+		}
+		c.send("%s OK %s completed", tag, cmd)
+	}
+}
+
+func (s *fakeServer) waitIdle(count int) {
+	for {
+		s.Lock()
+		n := len(s.idling)
+		s.Unlock()
+		if n >= count {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	require.Equal(t, `"INBOX"`, quote("INBOX"))
+	require.Equal(t, `"a \"quoted\" \\ string"`, quote(`a "quoted" \ string`))
+}
+
+func TestLiteralSize(t *testing.T) {
+	size, ok := literalSize(`* LIST () "/" {12}`)
+	require.True(t, ok)
+	require.Equal(t, int64(12), size)
+	_, ok = literalSize(`* SEARCH 1 2 3`)
+	require.False(t, ok)
+	_, ok = literalSize(`* OK {not a literal}`)
+	require.False(t, ok)
+}
+
+func TestReadLine(t *testing.T) {
+	client, conn := net.Pipe()
+	go func() {
+		fmt.Fprint(conn, "* OK ready\r\n* LIST () \"/\" {5}\r\nhello more\r\n")
+	}()
+	c, err := newClient(client)
+	require.NoError(t, err)
+	line, err := c.readLine()
+	require.NoError(t, err)
+	require.Equal(t, `* LIST () "/" {5} more`, line)
+
+	client, conn = net.Pipe()
+	go fmt.Fprint(conn, "* BYE go away\r\n")
+	_, err = newClient(client)
+	require.Error(t, err, "unexpected greeting")
+}
+
+const password = `app "password"`
+
+func TestModule(t *testing.T) {
+	srv := newServer(map[string]int{"INBOX": 0, "Lists": 2})
+	testBar.New(t)
+	m := New("imap.example.com", "me@example.com", password, "INBOX", "Lists").
+		Output(func(i Info) bar.Output {
+			return outputs.Textf("%d+%d=%d",
+				i.Unread["INBOX"], i.Unread["Lists"], i.TotalUnread())
+		})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"0+2=2"})
+	require.Equal(t, []string{"imap.example.com:993", "imap.example.com:993"},
+		srv.addrs, "connection per folder, with default port")
+
+	srv.waitIdle(2)
+	srv.setUnread("INBOX", 3, true)
+	testBar.NextOutput("on new mail").AssertText([]string{"3+2=5"})
+
+	srv.waitIdle(2)
+	srv.setUnread("Lists", 0, true)
+	testBar.NextOutput("on read").AssertText([]string{"3+0=3"})
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d", i.TotalUnread())
+	})
+	testBar.NextOutput("on output change").AssertText([]string{"3"})
+
+	srv.waitIdle(2)
+	srv.takeCommands()
+	srv.setUnread("INBOX", 3, true)
+	testBar.AssertNoOutput("when count is unchanged")
+	srv.waitIdle(2)
+	require.Equal(t, []string{"SEARCH", "IDLE"}, srv.takeCommands())
+}
+
+func TestDefaultOutput(t *testing.T) {
+	srv := newServer(map[string]int{"INBOX": 0})
+	testBar.New(t)
+	testBar.Run(New("imap.example.com:1993", "me@example.com", password))
+	testBar.NextOutput("on start").AssertEmpty()
+	require.Equal(t, []string{"imap.example.com:1993"}, srv.addrs)
+
+	srv.waitIdle(1)
+	srv.setUnread("INBOX", 4, true)
+	testBar.NextOutput("on new mail").AssertText([]string{"Mail: 4"})
+}
+
+func TestIdleTimeout(t *testing.T) {
+	defer func(d time.Duration) { idleTimeout = d }(idleTimeout)
+	idleTimeout = 10 * time.Millisecond
+	srv := newServer(map[string]int{"INBOX": 1})
+	testBar.New(t)
+	testBar.Run(New("imap.example.com", "me@example.com", password))
+	testBar.NextOutput("on start").AssertText([]string{"Mail: 1"})
+
+	srv.setUnread("INBOX", 2, false)
+	testBar.NextOutput("after idle timeout").AssertText([]string{"Mail: 2"})
+}
+
+func TestErrors(t *testing.T) {
+	newServer(map[string]int{"INBOX": 0})
+	testBar.New(t)
+	testBar.Run(New("imap.example.com", "me@example.com", "wrong"))
+	testBar.NextOutput("bad password").AssertError()
+
+	newServer(map[string]int{"INBOX": 0})
+	testBar.New(t)
+	testBar.Run(New("imap.example.com", "me@example.com", password, "Nope"))
+	testBar.NextOutput("no such folder").AssertError()
+
+	srv := newServer(map[string]int{"INBOX": 0})
+	srv.noIdle = true
+	testBar.New(t)
+	testBar.Run(New("imap.example.com", "me@example.com", password))
+	errs := testBar.NextOutput("no IDLE support").AssertError()
+	require.Contains(t, errs[0], "IDLE")
+
+	defer func(d func(string) (net.Conn, error)) { dial = d }(dial)
+	dial = func(string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	testBar.New(t)
+	testBar.Run(New("imap.example.com", "me@example.com", password))
+	testBar.NextOutput("connection error").AssertError()
+}
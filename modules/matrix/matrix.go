@@ -0,0 +1,252 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package matrix provides an i3bar module that shows unread rooms and mentions
+on a Matrix homeserver, using the client-server sync API.
+
+The counts are the notification counts computed by the homeserver from the
+user's push rules, so they work the same way for end-to-end encrypted rooms,
+without needing to decrypt any messages. A filter is used to exclude message
+contents, state, and presence from the sync responses.
+*/
+package matrix // import "barista.run/modules/matrix"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/httpclient"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Room represents the unread counts of a single joined room.
+type Room struct {
+	ID string
+	// Notifications is the number of unread messages that notify the user.
+	Notifications int
+	// Highlights is the number of unread messages that mention the user.
+	Highlights int
+}
+
+// Info represents the unread state of all joined rooms.
+type Info struct {
+	// Rooms contains only the joined rooms with unread notifications.
+	Rooms []Room
+	// Invites is the number of pending room invitations.
+	Invites int
+}
+
+// Mentions returns the total number of unread mentions.
+func (i Info) Mentions() int {
+	total := 0
+	for _, r := range i.Rooms {
+		total += r.Highlights
+	}
+	return total
+}
+
+// Unread returns the total number of unread notifications.
+func (i Info) Unread() int {
+	total := 0
+	for _, r := range i.Rooms {
+		total += r.Notifications
+	}
+	return total
+}
+
+// Module represents a Matrix bar module.
+type Module struct {
+	homeserver  string
+	accessToken string
+	outputFunc  value.Value // of func(Info) bar.Output
+}
+
+// New creates a module that shows unread counts from the given homeserver
+// (e.g. "https://matrix.org"), using an access token for the account.
+func New(homeserver, accessToken string) *Module {
+	m := &Module{
+		homeserver:  strings.TrimSuffix(homeserver, "/"),
+		accessToken: accessToken,
+	}
+	l.Register(m, "outputFunc")
+	// Default output is the number of unread rooms and mentions, hidden
+	// if there are none.
+	m.Output(func(i Info) bar.Output {
+		switch {
+		case i.Mentions() > 0:
+			return outputs.Textf("Matrix: %d rooms, %d mentions", len(i.Rooms), i.Mentions())
+		case len(i.Rooms) > 0:
+			return outputs.Textf("Matrix: %d rooms", len(i.Rooms))
+		default:
+			return nil
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// client allows longer than the default timeout, since sync requests wait for
+// up to pollTimeout for new events.
+var client = func() *http.Client {
+	c := httpclient.Client()
+	c.Timeout = time.Minute
+	return c
+}()
+
+// pollTimeout is how long the homeserver waits for new events before
+// responding. It can be reduced in tests.
+var pollTimeout = 30 * time.Second
+
+// syncFilter only includes the unread counts and invitations.
+const syncFilter = `{
+	"account_data": {"types": []},
+	"presence": {"types": []},
+	"room": {
+		"account_data": {"types": []},
+		"ephemeral": {"types": []},
+		"state": {"types": []},
+		"timeline": {"limit": 0}
+	}
+}`
+
+type syncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			UnreadNotifications struct {
+				NotificationCount int `json:"notification_count"`
+				HighlightCount    int `json:"highlight_count"`
+			} `json:"unread_notifications"`
+		} `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+		Leave  map[string]json.RawMessage `json:"leave"`
+	} `json:"rooms"`
+}
+
+// state accumulates the incremental sync responses.
+type state struct {
+	since   string
+	rooms   map[string]Room
+	invites map[string]bool
+}
+
+// sync performs a single sync request, returning whether any rooms changed.
+func (m *Module) sync(st *state) (bool, error) {
+	q := url.Values{}
+	q.Set("filter", syncFilter)
+	if st.since != "" {
+		q.Set("since", st.since)
+		q.Set("timeout", fmt.Sprintf("%d", pollTimeout/time.Millisecond))
+	}
+	req, err := http.NewRequest("GET", m.homeserver+"/_matrix/client/v3/sync?"+q.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+	res, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("matrix: %s", res.Status)
+	}
+	var r syncResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return false, err
+	}
+	// The initial sync always produces an output, even without any rooms.
+	changed := st.since == "" ||
+		len(r.Rooms.Join)+len(r.Rooms.Invite)+len(r.Rooms.Leave) > 0
+	st.since = r.NextBatch
+	for id, room := range r.Rooms.Join {
+		n := room.UnreadNotifications
+		st.rooms[id] = Room{id, n.NotificationCount, n.HighlightCount}
+		delete(st.invites, id)
+	}
+	for id := range r.Rooms.Invite {
+		st.invites[id] = true
+	}
+	for id := range r.Rooms.Leave {
+		delete(st.rooms, id)
+		delete(st.invites, id)
+	}
+	return changed, nil
+}
+
+func (st *state) info() Info {
+	i := Info{Invites: len(st.invites)}
+	for _, r := range st.rooms {
+		if r.Notifications > 0 || r.Highlights > 0 {
+			i.Rooms = append(i.Rooms, r)
+		}
+	}
+	sort.Slice(i.Rooms, func(a, b int) bool {
+		return i.Rooms[a].ID < i.Rooms[b].ID
+	})
+	return i
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	infos := make(chan Info)
+	errs := make(chan error)
+	go func() {
+		st := &state{rooms: map[string]Room{}, invites: map[string]bool{}}
+		for {
+			changed, err := m.sync(st)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if changed {
+				infos <- st.info()
+			}
+		}
+	}()
+
+	var info *Info
+	for {
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case i := <-infos:
+			info = &i
+		case err := <-errs:
+			s.Error(err)
+			return
+		}
+		if info != nil {
+			s.Output(outputFunc(*info))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package matrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	since, timeout, auth string
+	filter               map[string]interface{}
+}
+
+// testServer responds to sync requests with the bodies sent on responses.
+// Responding with an empty string fails the request.
+type testServer struct {
+	*httptest.Server
+	requests  chan request
+	responses chan string
+}
+
+func newTestServer() *testServer {
+	t := &testServer{
+		requests:  make(chan request, 10),
+		responses: make(chan string),
+	}
+	t.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_matrix/client/v3/sync" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		q := r.URL.Query()
+		req := request{
+			since:   q.Get("since"),
+			timeout: q.Get("timeout"),
+			auth:    r.Header.Get("Authorization"),
+		}
+		json.Unmarshal([]byte(q.Get("filter")), &req.filter)
+		t.requests <- req
+		select {
+		case body := <-t.responses:
+			if body == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, body)
+		case <-time.After(time.Second):
+			io.WriteString(w, `{"next_batch": "timeout"}`)
+		}
+	}))
+	return t
+}
+
+func (t *testServer) respond(batch int, rooms string) {
+	t.responses <- fmt.Sprintf(`{"next_batch": "s%d", "rooms": {%s}}`, batch, rooms)
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	srv := newTestServer()
+	defer srv.Close()
+
+	m := New(srv.URL+"/", "secret").Output(func(i Info) bar.Output {
+		return outputs.Textf("%d rooms, %d unread, %d mentions, %d invites",
+			len(i.Rooms), i.Unread(), i.Mentions(), i.Invites)
+	})
+	testBar.Run(m)
+
+	req := <-srv.requests
+	require.Equal(t, "Bearer secret", req.auth)
+	require.Empty(t, req.since, "initial sync")
+	require.Empty(t, req.timeout, "initial sync returns immediately")
+	require.Equal(t, map[string]interface{}{"limit": 0.0},
+		req.filter["room"].(map[string]interface{})["timeline"])
+	srv.respond(1, `"join": {
+		"!a:example.org": {"unread_notifications": {"notification_count": 3, "highlight_count": 1}},
+		"!b:example.org": {"unread_notifications": {"notification_count": 0, "highlight_count": 0}},
+		"!c:example.org": {"unread_notifications": {"notification_count": 2}}
+	}`)
+	testBar.NextOutput("on initial sync").AssertText(
+		[]string{"2 rooms, 5 unread, 1 mentions, 0 invites"})
+
+	req = <-srv.requests
+	require.Equal(t, "s1", req.since)
+	require.Equal(t, "30000", req.timeout)
+	srv.respond(2, "")
+	testBar.AssertNoOutput("when nothing changed")
+
+	<-srv.requests
+	srv.respond(3, `"join": {
+		"!a:example.org": {"unread_notifications": {"notification_count": 0, "highlight_count": 0}}
+	}, "invite": {"!d:example.org": {}}`)
+	testBar.NextOutput("on incremental sync").AssertText(
+		[]string{"1 rooms, 2 unread, 0 mentions, 1 invites"})
+
+	<-srv.requests
+	srv.respond(4, `"join": {"!d:example.org": {}}, "leave": {"!c:example.org": {}}`)
+	testBar.NextOutput("on join and leave").AssertText(
+		[]string{"0 rooms, 0 unread, 0 mentions, 0 invites"})
+
+	<-srv.requests
+	srv.responses <- ""
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	srv := newTestServer()
+	defer srv.Close()
+
+	testBar.Run(New(srv.URL, "secret"))
+	<-srv.requests
+	srv.respond(1, "")
+	testBar.NextOutput("without unread rooms").AssertEmpty()
+
+	<-srv.requests
+	srv.respond(2, `"join": {"!a:example.org": {"unread_notifications": {"notification_count": 3}}}`)
+	testBar.NextOutput("with unread rooms").AssertText([]string{"Matrix: 1 rooms"})
+
+	<-srv.requests
+	srv.respond(3, `"join": {"!b:example.org": {"unread_notifications": {"notification_count": 1, "highlight_count": 1}}}`)
+	testBar.NextOutput("with mentions").AssertText([]string{"Matrix: 2 rooms, 1 mentions"})
+}
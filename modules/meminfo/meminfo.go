@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/cgroup"
 	"barista.run/base/value"
 	"barista.run/format"
 	l "barista.run/logging"
@@ -90,6 +91,30 @@ func RefreshInterval(interval time.Duration) {
 	updater.Every(interval)
 }
 
+var (
+	useCgroup   bool
+	useCgroupMu sync.Mutex
+)
+
+// CgroupLimits configures whether memory is shown relative to the limit of the
+// cgroup that barista is running in (e.g. a container, or a systemd slice with
+// a memory limit), instead of the whole machine. If enabled and the limit is
+// lower than the total memory, MemTotal is set to the limit, and MemAvailable
+// and MemFree to the memory that can still be used before reaching it.
+func CgroupLimits(enabled bool) {
+	useCgroupMu.Lock()
+	useCgroup = enabled
+	useCgroupMu.Unlock()
+	construct()
+	update()
+}
+
+func cgroupLimits() bool {
+	useCgroupMu.Lock()
+	defer useCgroupMu.Unlock()
+	return useCgroup
+}
+
 // Module represents a bar.Module that displays memory information.
 type Module struct {
 	outputFunc value.Value
@@ -167,5 +192,38 @@ func update() {
 			info[name] = unit.Datasize(intval) * mult
 		}
 	}
+	if cgroupLimits() {
+		lim, err := readCgroup()
+		if currentInfo.Error(err) {
+			return
+		}
+		applyLimits(info, lim)
+	}
 	currentInfo.Set(info)
 }
+
+// To allow tests to mock out cgroup.Read.
+var readCgroup = cgroup.Read
+
+// applyLimits adjusts the memory information to the cgroup's memory limit,
+// if it is lower than the total memory.
+func applyLimits(i Info, lim cgroup.Limits) {
+	total, ok := i["MemTotal"]
+	if !ok || lim.MemoryMax == 0 || lim.MemoryMax >= total {
+		return
+	}
+	avail := lim.MemoryAvailable()
+	if hostAvail := i.Available(); hostAvail < avail {
+		avail = hostAvail
+	}
+	free := lim.MemoryMax - lim.MemoryCurrent
+	if free < 0 {
+		free = 0
+	}
+	if hostFree, ok := i["MemFree"]; ok && hostFree < free {
+		free = hostFree
+	}
+	i["MemTotal"] = lim.MemoryMax
+	i["MemAvailable"] = avail
+	i["MemFree"] = free
+}
@@ -16,18 +16,21 @@ package meminfo
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/cgroup"
 	"barista.run/base/value"
 	"barista.run/format"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
 
+	"github.com/martinlindhe/unit"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
@@ -174,3 +177,62 @@ func TestErrors(t *testing.T) {
 		[]string{"0.5", "1.0 MiB", "2.1 MB"},
 		"when meminfo is back to normal")
 }
+
+func TestCgroupLimits(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	shouldReturn(meminfo{
+		"MemAvailable": 2048,
+		"MemTotal":     4096,
+		"MemFree":      1024,
+	})
+	var lim cgroup.Limits
+	var limErr error
+	var limMu sync.Mutex
+	readCgroup = func() (cgroup.Limits, error) {
+		limMu.Lock()
+		defer limMu.Unlock()
+		return lim, limErr
+	}
+	setLimits := func(l cgroup.Limits, err error) {
+		limMu.Lock()
+		defer limMu.Unlock()
+		lim, limErr = l, err
+	}
+	defer func() { readCgroup = cgroup.Read }()
+
+	testBar.New(t)
+	resetForTest()
+	m := New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%v/%v/%v",
+			i["MemFree"].Kibibytes(), i.Available().Kibibytes(), i["MemTotal"].Kibibytes())
+	})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"1024/2048/4096"}, "on start")
+
+	setLimits(cgroup.Limits{
+		MemoryMax:         1024 * unit.Kibibyte,
+		MemoryCurrent:     768 * unit.Kibibyte,
+		MemoryReclaimable: 128 * unit.Kibibyte,
+	}, nil)
+	CgroupLimits(true)
+	testBar.NextOutput().AssertText([]string{"256/384/1024"},
+		"relative to cgroup limit")
+
+	setLimits(cgroup.Limits{MemoryMax: 3072 * unit.Kibibyte}, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1024/2048/3072"},
+		"host memory is used when lower")
+
+	setLimits(cgroup.Limits{MemoryCurrent: 1024 * unit.Kibibyte}, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1024/2048/4096"},
+		"without a memory limit")
+
+	setLimits(cgroup.Limits{}, errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput().AssertError("on cgroup error")
+
+	CgroupLimits(false)
+	testBar.NextOutput().AssertText([]string{"1024/2048/4096"},
+		"when disabled")
+}
@@ -0,0 +1,419 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package mqtt provides an i3bar module that subscribes to topics on an MQTT
+broker and displays the latest payloads, and can publish messages on click.
+
+Payloads can optionally be JSON, in which case a path to a single value can
+be given, e.g. "sensors.0.temperature" for
+{"sensors": [{"temperature": 21.5}]}.
+
+Only QoS 0 is supported, which is sufficient for status displays. Brokers
+are given as URLs, e.g. "tcp://localhost:1883" or "tls://broker:8883".
+*/
+package mqtt // import "barista.run/modules/mqtt"
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Message represents the latest message received on a topic.
+type Message struct {
+	Topic   string
+	Payload []byte
+	// Value is the payload as a string, or the value at the JSON path
+	// configured for the subscription.
+	Value    string
+	Received time.Time
+}
+
+// Info represents the latest messages received on all subscribed topics.
+type Info struct {
+	// Messages are keyed by topic. For subscriptions with wildcards, there
+	// may be multiple topics for each subscription.
+	Messages map[string]Message
+	// Topics lists the topics in the order their first message was received.
+	Topics []string
+	m      *Module
+}
+
+// Value returns the value of the latest message on the given topic, or an
+// empty string if no message has been received.
+func (i Info) Value(topic string) string {
+	return i.Messages[topic].Value
+}
+
+// Publish publishes a message to the given topic.
+func (i Info) Publish(topic, payload string) error {
+	if i.m == nil {
+		return errors.New("not connected")
+	}
+	return i.m.publish(topic, []byte(payload), false)
+}
+
+type subscription struct {
+	filter string
+	path   string
+}
+
+type publication struct {
+	topic, payload string
+}
+
+// Module represents an MQTT bar module.
+type Module struct {
+	broker       string
+	username     string
+	password     string
+	subs         []subscription
+	clickPublish value.Value // of *publication
+	outputFunc   value.Value // of func(Info) bar.Output
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// New creates a module for the MQTT broker at the given URL. At least one
+// topic should be subscribed to before the module is streamed.
+func New(broker string) *Module {
+	m := &Module{broker: broker}
+	l.Register(m, "outputFunc", "clickPublish")
+	m.clickPublish.Set((*publication)(nil))
+	// Default output is the latest value on each topic, which publishes
+	// the configured message when clicked.
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, t := range i.Topics {
+			seg := outputs.Text(i.Messages[t].Value)
+			if p := m.clickPublish.Get().(*publication); p != nil {
+				seg.OnClick(click.Left(func() {
+					if err := i.Publish(p.topic, p.payload); err != nil {
+						l.Log("Failed to publish to %s: %v", p.topic, err)
+					}
+				}))
+			}
+			out.Append(seg)
+		}
+		return out
+	})
+	return m
+}
+
+// Auth sets the username and password used to connect to the broker.
+func (m *Module) Auth(username, password string) *Module {
+	m.username, m.password = username, password
+	return m
+}
+
+// Subscribe subscribes to a topic filter, which may contain wildcards.
+func (m *Module) Subscribe(filter string) *Module {
+	return m.SubscribeJSON(filter, "")
+}
+
+// SubscribeJSON subscribes to a topic filter with JSON payloads, extracting
+// the value at the given dotted path, e.g. "state.battery.0".
+func (m *Module) SubscribeJSON(filter, path string) *Module {
+	m.subs = append(m.subs, subscription{filter, path})
+	return m
+}
+
+// PublishOnClick configures the default output to publish the given payload
+// to the topic when clicked.
+func (m *Module) PublishOnClick(topic, payload string) *Module {
+	m.clickPublish.Set(&publication{topic, payload})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// keepAlive is the keep alive interval sent to the broker. A ping is sent
+// at half this interval.
+var keepAlive = 60 * time.Second
+
+func (m *Module) dial() (net.Conn, error) {
+	u, err := url.Parse(m.broker)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		return dialer.Dial("tcp", hostPort(u, "1883"))
+	case "tls", "ssl", "mqtts":
+		return tls.DialWithDialer(dialer, "tcp", hostPort(u, "8883"),
+			&tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported scheme %q", u.Scheme)
+	}
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+func clientID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "barista-" + hex.EncodeToString(b)
+}
+
+func (m *Module) write(p packet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		return errors.New("not connected")
+	}
+	_, err := m.conn.Write(p.encode())
+	return err
+}
+
+func (m *Module) publish(topic string, payload []byte, retain bool) error {
+	return m.write(publishPacket(topic, payload, retain))
+}
+
+// connect connects to the broker and subscribes to all topics.
+func (m *Module) connect() (*bufio.Reader, error) {
+	conn, err := m.dial()
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	m.mu.Lock()
+	m.conn = conn
+	m.mu.Unlock()
+	err = m.write(connectPacket(clientID(), m.username, m.password,
+		uint16(keepAlive/time.Second)))
+	var ack packet
+	if err == nil {
+		ack, err = readPacket(r)
+	}
+	if err == nil && (ack.kind != packetConnack || len(ack.body) != 2) {
+		err = errMalformed
+	}
+	if err == nil && ack.body[1] != 0 {
+		err = fmt.Errorf("mqtt: connection refused (%d)", ack.body[1])
+	}
+	if err == nil && len(m.subs) > 0 {
+		var filters []string
+		for _, s := range m.subs {
+			filters = append(filters, s.filter)
+		}
+		err = m.write(subscribePacket(1, filters))
+	}
+	return r, err
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextClick, doneClick := m.clickPublish.Subscribe()
+	defer doneClick()
+
+	r, err := m.connect()
+	defer func() {
+		m.mu.Lock()
+		if m.conn != nil {
+			m.conn.Close()
+			m.conn = nil
+		}
+		m.mu.Unlock()
+	}()
+	if s.Error(err) {
+		return
+	}
+
+	msgs := make(chan Message)
+	errs := make(chan error, 1)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() { errs <- m.read(r, msgs, quit) }()
+	pings := time.NewTicker(keepAlive / 2)
+	defer pings.Stop()
+
+	info := Info{Messages: map[string]Message{}, m: m}
+	for {
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextClick:
+		case <-pings.C:
+			if s.Error(m.write(packet{kind: packetPingreq})) {
+				return
+			}
+			continue
+		case err := <-errs:
+			s.Error(err)
+			return
+		case msg := <-msgs:
+			if _, ok := info.Messages[msg.Topic]; !ok {
+				info.Topics = append(info.Topics, msg.Topic)
+			}
+			messages := map[string]Message{msg.Topic: msg}
+			for k, v := range info.Messages {
+				if k != msg.Topic {
+					messages[k] = v
+				}
+			}
+			info.Messages = messages
+		}
+		if len(info.Topics) > 0 {
+			s.Output(outputFunc(info))
+		}
+	}
+}
+
+// read reads packets from the broker until an error occurs or quit is closed,
+// sending any messages received.
+func (m *Module) read(r *bufio.Reader, msgs chan<- Message, quit <-chan struct{}) error {
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return err
+		}
+		switch p.kind {
+		case packetSuback:
+			if len(p.body) < 2 {
+				return errMalformed
+			}
+			for _, code := range p.body[2:] {
+				if code == 0x80 {
+					return errors.New("mqtt: subscription refused")
+				}
+			}
+		case packetPublish:
+			topic, payload, id, err := parsePublish(p)
+			if err != nil {
+				return err
+			}
+			if id != 0 {
+				if err := m.write(packet{kind: packetPuback, body: appendUint16(nil, id)}); err != nil {
+					return err
+				}
+			}
+			select {
+			case msgs <- m.message(topic, payload):
+			case <-quit:
+				return nil
+			}
+		}
+	}
+}
+
+func (m *Module) message(topic string, payload []byte) Message {
+	msg := Message{
+		Topic:    topic,
+		Payload:  payload,
+		Value:    string(payload),
+		Received: timing.Now(),
+	}
+	for _, s := range m.subs {
+		if s.path != "" && matches(s.filter, topic) {
+			v, err := extract(payload, s.path)
+			if err != nil {
+				l.Log("Failed to extract %s from %s: %v", s.path, topic, err)
+			}
+			msg.Value = v
+			break
+		}
+	}
+	return msg
+}
+
+// matches returns true if the topic matches the filter, which may contain
+// the single-level (+) and multi-level (#) wildcards.
+func matches(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, part := range f {
+		switch {
+		case part == "#":
+			return true
+		case i >= len(t):
+			return false
+		case part != "+" && part != t[i]:
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// extract returns the value at the given dotted path in a JSON document.
+func extract(payload []byte, path string) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[key]; !ok {
+				return "", fmt.Errorf("no key %q", key)
+			}
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(t) {
+				return "", fmt.Errorf("no index %q", key)
+			}
+			v = t[idx]
+		default:
+			return "", fmt.Errorf("cannot index %T with %q", v, key)
+		}
+	}
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	case nil:
+		return "", nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	default:
+		b, err := json.Marshal(t)
+		return string(b), err
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqtt
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBroker accepts a single connection, and records the client's packets.
+type fakeBroker struct {
+	net.Listener
+	refuse   byte
+	conn     chan net.Conn
+	received chan packet
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	b := &fakeBroker{
+		Listener: ln,
+		conn:     make(chan net.Conn, 1),
+		received: make(chan packet, 10),
+	}
+	go b.serve()
+	return b
+}
+
+func (b *fakeBroker) URL() string {
+	return "tcp://" + b.Addr().String()
+}
+
+func (b *fakeBroker) serve() {
+	conn, err := b.Accept()
+	if err != nil {
+		return
+	}
+	r := bufio.NewReader(conn)
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		b.received <- p
+		switch p.kind {
+		case packetConnect:
+			conn.Write(packet{kind: packetConnack, body: []byte{0, b.refuse}}.encode())
+			b.conn <- conn
+		case packetSubscribe:
+			id := append([]byte(nil), p.body[:2]...)
+			codes := []byte{}
+			for _, f := range strings.Split(string(p.body[2:]), "\x00") {
+				if strings.Contains(f, "forbidden") {
+					codes = append(codes, 0x80)
+				} else if f != "" {
+					codes = append(codes, 0)
+				}
+			}
+			conn.Write(packet{kind: packetSuback, body: append(id, codes...)}.encode())
+		}
+	}
+}
+
+func (b *fakeBroker) publish(conn net.Conn, topic, payload string) {
+	conn.Write(publishPacket(topic, []byte(payload), false).encode())
+}
+
+func TestMatches(t *testing.T) {
+	for _, tc := range []struct {
+		filter, topic string
+		expected      bool
+	}{
+		{"home/temp", "home/temp", true},
+		{"home/temp", "home/humidity", false},
+		{"home/+", "home/temp", true},
+		{"home/+", "home/temp/living", false},
+		{"home/+/living", "home/temp/living", true},
+		{"home/#", "home/temp/living", true},
+		{"home/#", "home", true},
+		{"#", "anything/at/all", true},
+		{"home/temp/living", "home/temp", false},
+	} {
+		require.Equal(t, tc.expected, matches(tc.filter, tc.topic),
+			"%s matches %s", tc.filter, tc.topic)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	doc := []byte(`{"a": {"b": [1, {"c": "x"}, 1000000]}, "t": true, "n": null, "f": 21.5}`)
+	for path, expected := range map[string]string{
+		"a.b.0":   "1",
+		"a.b.1.c": "x",
+		"a.b.2":   "1000000",
+		"t":       "true",
+		"n":       "",
+		"f":       "21.5",
+		"a.b.1":   `{"c":"x"}`,
+	} {
+		v, err := extract(doc, path)
+		require.NoError(t, err, path)
+		require.Equal(t, expected, v, path)
+	}
+	for _, path := range []string{"x", "a.b.3", "a.b.x", "t.x"} {
+		_, err := extract(doc, path)
+		require.Error(t, err, path)
+	}
+	_, err := extract([]byte("not json"), "a")
+	require.Error(t, err)
+}
+
+func TestPacket(t *testing.T) {
+	p := packet{kind: packetPublish, body: make([]byte, 200)}
+	b := p.encode()
+	require.Equal(t, []byte{0x30, 0xc8, 0x01}, b[:3], "multi-byte length")
+	r, err := readPacket(bufio.NewReader(strings.NewReader(string(b))))
+	require.NoError(t, err)
+	require.Equal(t, p, r)
+
+	_, err = readPacket(bufio.NewReader(strings.NewReader("\x30\xff\xff\xff\xff\x01")))
+	require.Error(t, err)
+
+	_, _, _, err = parsePublish(packet{kind: packetPublish, body: []byte{0, 5, 'a'}})
+	require.Error(t, err)
+	topic, payload, id, err := parsePublish(packet{
+		kind: packetPublish, flags: 2, body: []byte{0, 1, 'a', 0, 7, 'x'},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "a", topic)
+	require.Equal(t, []byte("x"), payload)
+	require.Equal(t, uint16(7), id)
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	b := newFakeBroker(t)
+	defer b.Close()
+
+	m := New(b.URL()).
+		Auth("user", "pass").
+		Subscribe("home/+/state").
+		SubscribeJSON("sensors/#", "temperature").
+		Output(func(i Info) bar.Output {
+			out := outputs.Group()
+			for _, t := range i.Topics {
+				out.Append(outputs.Textf("%s=%s", t, i.Value(t)))
+			}
+			return out.OnClick(func(bar.Event) { i.Publish("home/light/set", "toggle") })
+		})
+	testBar.Run(m)
+
+	connect := <-b.received
+	require.Equal(t, byte(packetConnect), connect.kind)
+	r := &reader{b: connect.body}
+	require.Equal(t, "MQTT", r.string())
+	r.b = r.b[4:]
+	require.True(t, strings.HasPrefix(r.string(), "barista-"))
+	require.Equal(t, "user", r.string())
+	require.Equal(t, "pass", r.string())
+
+	sub := <-b.received
+	require.Equal(t, byte(packetSubscribe), sub.kind)
+	require.Equal(t, byte(2), sub.flags)
+	require.Contains(t, string(sub.body), "home/+/state")
+	require.Contains(t, string(sub.body), "sensors/#")
+
+	conn := <-b.conn
+	testBar.AssertNoOutput("until a message is received")
+
+	b.publish(conn, "home/door/state", "closed")
+	testBar.NextOutput("on message").AssertText([]string{"home/door/state=closed"})
+
+	b.publish(conn, "sensors/living", `{"temperature": 21.5, "humidity": 40}`)
+	testBar.NextOutput("on json message").AssertText(
+		[]string{"home/door/state=closed", "sensors/living=21.5"})
+
+	b.publish(conn, "home/door/state", "open")
+	out := testBar.NextOutput("on update")
+	out.AssertText([]string{"home/door/state=open", "sensors/living=21.5"})
+
+	out.At(0).LeftClick()
+	pub := <-b.received
+	topic, payload, _, err := parsePublish(pub)
+	require.NoError(t, err)
+	require.Equal(t, "home/light/set", topic)
+	require.Equal(t, "toggle", string(payload))
+
+	// QoS 1 messages are acknowledged.
+	conn.Write(packet{kind: packetPublish, flags: 2,
+		body: []byte{0, 15, 'h', 'o', 'm', 'e', '/', 'x', '/', 's', 't', 'a', 't', 'e', '/', '/', '/', 0, 9, 'y'},
+	}.encode())
+	ack := <-b.received
+	require.Equal(t, byte(packetPuback), ack.kind)
+	require.Equal(t, []byte{0, 9}, ack.body)
+	testBar.NextOutput("on qos 1 message")
+
+	conn.Close()
+	testBar.NextOutput("on disconnect").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	b := newFakeBroker(t)
+	defer b.Close()
+
+	testBar.Run(New(b.URL()).Subscribe("a").Subscribe("b").PublishOnClick("cmd", "go"))
+	<-b.received
+	<-b.received
+	conn := <-b.conn
+
+	b.publish(conn, "b", "2")
+	b.publish(conn, "a", "1")
+	out := testBar.LatestOutput()
+	out.AssertText([]string{"2", "1"})
+	out.At(1).LeftClick()
+	topic, payload, _, _ := parsePublish(<-b.received)
+	require.Equal(t, "cmd", topic)
+	require.Equal(t, "go", string(payload))
+}
+
+func TestErrors(t *testing.T) {
+	testBar.New(t)
+	b := newFakeBroker(t)
+	b.refuse = 5
+	defer b.Close()
+	testBar.Run(New(b.URL()).Subscribe("a"))
+	testBar.NextOutput("when not authorised").AssertError()
+
+	testBar.New(t)
+	b = newFakeBroker(t)
+	defer b.Close()
+	testBar.Run(New(b.URL()).Subscribe("a").Subscribe("forbidden"))
+	testBar.NextOutput("when subscription is refused").AssertError()
+
+	testBar.New(t)
+	testBar.Run(New("ws://localhost"))
+	testBar.NextOutput("with unsupported scheme").AssertError()
+
+	require.Error(t, Info{}.Publish("a", "b"))
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// A minimal implementation of the MQTT 3.1.1 wire protocol, supporting only
+// what is needed to subscribe and publish at QoS 0 (and acknowledge QoS 1
+// messages from brokers that do not downgrade them).
+
+// Packet types.
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+	protocolLevel311  = 4
+	connectCleanStart = 0x02
+	connectPassword   = 0x40
+	connectUsername   = 0x80
+)
+
+var errMalformed = errors.New("mqtt: malformed packet")
+
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// encode returns the packet with its fixed header.
+func (p packet) encode() []byte {
+	b := []byte{p.kind<<4 | p.flags}
+	n := len(p.body)
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, p.body...)
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, shift := 0, uint(0)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length |= int(c&0x7f) << shift
+		if c&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return packet{}, errMalformed
+		}
+	}
+	p := packet{kind: h >> 4, flags: h & 0x0f, body: make([]byte, length)}
+	_, err = io.ReadFull(r, p.body)
+	return p, err
+}
+
+// reader reads fields from the body of a packet.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) uint16() uint16 {
+	if len(r.b) < 2 {
+		r.err = errMalformed
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.b)
+	r.b = r.b[2:]
+	return v
+}
+
+func (r *reader) string() string {
+	n := int(r.uint16())
+	if len(r.b) < n {
+		r.err = errMalformed
+		return ""
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+func connectPacket(clientID, username, password string, keepAlive uint16) packet {
+	flags := byte(connectCleanStart)
+	if username != "" {
+		flags |= connectUsername
+	}
+	if password != "" {
+		flags |= connectPassword
+	}
+	b := appendString(nil, "MQTT")
+	b = append(b, protocolLevel311, flags)
+	b = appendUint16(b, keepAlive)
+	b = appendString(b, clientID)
+	if username != "" {
+		b = appendString(b, username)
+	}
+	if password != "" {
+		b = appendString(b, password)
+	}
+	return packet{kind: packetConnect, body: b}
+}
+
+func subscribePacket(id uint16, filters []string) packet {
+	b := appendUint16(nil, id)
+	for _, f := range filters {
+		b = append(appendString(b, f), 0)
+	}
+	// SUBSCRIBE packets must have the reserved flags set to 0010.
+	return packet{kind: packetSubscribe, flags: 2, body: b}
+}
+
+func publishPacket(topic string, payload []byte, retain bool) packet {
+	p := packet{kind: packetPublish, body: append(appendString(nil, topic), payload...)}
+	if retain {
+		p.flags = 1
+	}
+	return p
+}
+
+// parsePublish returns the topic, payload, and (for QoS 1 and above) the
+// packet ID of a PUBLISH packet.
+func parsePublish(p packet) (topic string, payload []byte, id uint16, err error) {
+	r := &reader{b: p.body}
+	topic = r.string()
+	if qos := (p.flags >> 1) & 3; qos > 0 {
+		id = r.uint16()
+	}
+	return topic, r.b, id, r.err
+}
@@ -0,0 +1,180 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nightlight
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"barista.run/base/watchers/dbus"
+)
+
+// command runs a command and returns its output. It can be replaced in tests.
+var command = func(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	return string(out), err
+}
+
+type oneShot struct {
+	name string
+}
+
+// Gammastep returns a backend that uses gammastep's one-shot manual mode to
+// set the colour temperature. It does not require the gammastep daemon, and
+// should not be used while the daemon is running.
+func Gammastep() Backend {
+	return oneShot{"gammastep"}
+}
+
+// Redshift returns a backend that uses redshift's one-shot manual mode to set
+// the colour temperature. It does not require the redshift daemon, and should
+// not be used while the daemon is running.
+func Redshift() Backend {
+	return oneShot{"redshift"}
+}
+
+func (o oneShot) SetTemperature(kelvin int) error {
+	if kelvin == Neutral {
+		_, err := command(o.name, "-x")
+		return err
+	}
+	_, err := command(o.name, "-P", "-O", strconv.Itoa(kelvin))
+	return err
+}
+
+type xrandr struct {
+	outputs []string
+}
+
+// Xrandr returns a backend that sets the gamma of the given X11 outputs using
+// RandR. If no outputs are given, all connected outputs are adjusted.
+func Xrandr(outputs ...string) Backend {
+	return xrandr{outputs}
+}
+
+func (x xrandr) connectedOutputs() ([]string, error) {
+	if len(x.outputs) > 0 {
+		return x.outputs, nil
+	}
+	out, err := command("xrandr", "--query")
+	if err != nil {
+		return nil, err
+	}
+	var outputs []string
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		// e.g. "eDP-1 connected primary 1920x1080+0+0 ..."
+		f := strings.Fields(s.Text())
+		if len(f) >= 2 && f[1] == "connected" {
+			outputs = append(outputs, f[0])
+		}
+	}
+	if len(outputs) == 0 {
+		return nil, errors.New("xrandr: no connected outputs")
+	}
+	return outputs, nil
+}
+
+func (x xrandr) SetTemperature(kelvin int) error {
+	outputs, err := x.connectedOutputs()
+	if err != nil {
+		return err
+	}
+	r, g, b := whitePoint(kelvin)
+	gamma := fmt.Sprintf("%.3f:%.3f:%.3f", r, g, b)
+	for _, o := range outputs {
+		if _, err := command("xrandr", "--output", o, "--gamma", gamma); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// whitePoint returns the relative red, green, and blue levels for the given
+// colour temperature, normalised such that Neutral is 1:1:1. It uses an
+// approximation of the black-body radiation curve that is accurate enough
+// for adjusting displays.
+func whitePoint(kelvin int) (r, g, b float64) {
+	r, g, b = blackBody(float64(kelvin))
+	nr, ng, nb := blackBody(Neutral)
+	return math.Min(r/nr, 1), math.Min(g/ng, 1), math.Min(b/nb, 1)
+}
+
+func blackBody(kelvin float64) (r, g, b float64) {
+	t := kelvin / 100
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(255, v)) / 255 }
+	return clamp(r), clamp(g), clamp(b)
+}
+
+var busType = dbus.Session
+
+type gammaRelay struct {
+	once    sync.Once
+	watcher *dbus.PropertiesWatcher
+}
+
+// GammaRelay returns a backend that controls wl-gammarelay over D-Bus, which
+// applies the colour temperature using the wlr-gamma-control protocol.
+func GammaRelay() Backend {
+	return &gammaRelay{}
+}
+
+func (g *gammaRelay) w() *dbus.PropertiesWatcher {
+	g.once.Do(func() {
+		g.watcher = dbus.WatchProperties(busType,
+			"rs.wl-gammarelay", "/", "rs.wl.gammarelay").
+			Fetch("Temperature")
+	})
+	return g.watcher
+}
+
+func (g *gammaRelay) Temperature() (int, error) {
+	t, ok := g.w().Get()["Temperature"].(uint16)
+	if !ok {
+		return 0, errors.New("gammarelay: temperature not available")
+	}
+	return int(t), nil
+}
+
+func (g *gammaRelay) SetTemperature(kelvin int) error {
+	current, err := g.Temperature()
+	if err != nil {
+		return err
+	}
+	// wl-gammarelay only supports relative changes to the temperature.
+	_, err = g.w().Call("UpdateTemperature", int16(kelvin-current))
+	return err
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package nightlight provides an i3bar module that controls the colour
+temperature of the screen, to reduce blue light at night.
+
+The mechanism used to change the colour temperature is pluggable:
+GammaRelay() controls wl-gammarelay over D-Bus, which applies the temperature
+using the wlr-gamma-control protocol on Wayland compositors such as sway.
+Xrandr() applies gamma directly to X11 outputs using RandR.
+Gammastep() and Redshift() use the one-shot modes of those tools.
+
+By default, a left click toggles the night light, and scrolling adjusts the
+temperature.
+*/
+package nightlight // import "barista.run/modules/nightlight"
+
+import (
+	"sync"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+const (
+	// Neutral is the colour temperature that results in no adjustment.
+	Neutral = 6500
+	// MinTemperature is the lowest supported colour temperature.
+	MinTemperature = 1000
+	// MaxTemperature is the highest supported colour temperature.
+	MaxTemperature = 10000
+)
+
+// Backend is an interface for mechanisms that change the colour temperature.
+type Backend interface {
+	// SetTemperature applies the given colour temperature, in Kelvin. Setting
+	// the temperature to Neutral removes any adjustment.
+	SetTemperature(kelvin int) error
+}
+
+// temperatureReader is implemented by backends that can report the colour
+// temperature currently in effect.
+type temperatureReader interface {
+	Temperature() (int, error)
+}
+
+// Info represents the state of the night light.
+type Info struct {
+	// Enabled is true if the night light colour temperature is applied.
+	Enabled bool
+	// Temperature is the night light colour temperature, in Kelvin. It is
+	// retained while the night light is disabled.
+	Temperature int
+	module      *Module
+}
+
+// Toggle switches the night light on or off.
+func (i Info) Toggle() {
+	i.module.set(!i.Enabled, i.Temperature)
+}
+
+// SetEnabled switches the night light on or off.
+func (i Info) SetEnabled(enabled bool) {
+	i.module.set(enabled, i.Temperature)
+}
+
+// SetTemperature changes the night light colour temperature, and enables the
+// night light if necessary.
+func (i Info) SetTemperature(kelvin int) {
+	if kelvin < MinTemperature {
+		kelvin = MinTemperature
+	}
+	if kelvin > MaxTemperature {
+		kelvin = MaxTemperature
+	}
+	i.module.set(true, kelvin)
+}
+
+// Module represents a night light bar module.
+type Module struct {
+	backend    Backend
+	step       int
+	state      value.ErrorValue // of Info
+	outputFunc value.Value      // of func(Info) bar.Output
+	mu         sync.Mutex
+}
+
+// New creates a night light module that uses the given backend.
+func New(backend Backend) *Module {
+	m := &Module{backend: backend, step: 100}
+	l.Register(m, "outputFunc", "state")
+	m.state.Set(Info{Temperature: 4500})
+	// Default output is the colour temperature when enabled, and "off"
+	// otherwise.
+	m.Output(func(i Info) bar.Output {
+		if !i.Enabled {
+			return outputs.Text("night off")
+		}
+		return outputs.Textf("night %dK", i.Temperature)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Temperature sets the night light colour temperature used when the night
+// light is first enabled. The default is 4500K.
+func (m *Module) Temperature(kelvin int) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, _ := m.state.Get()
+	if info, ok := i.(Info); ok {
+		info.Temperature = kelvin
+		m.state.Set(info)
+	}
+	return m
+}
+
+// Step sets the change in colour temperature for each scroll event.
+// The default is 100K.
+func (m *Module) Step(kelvin int) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.step = kelvin
+	return m
+}
+
+func (m *Module) set(enabled bool, kelvin int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	apply := Neutral
+	if enabled {
+		apply = kelvin
+	}
+	if err := m.backend.SetTemperature(apply); err != nil {
+		m.state.Error(err)
+		return
+	}
+	m.state.Set(Info{Enabled: enabled, Temperature: kelvin})
+}
+
+// clickHandler toggles the night light on left click, and adjusts the
+// temperature on scroll: scrolling up makes the screen cooler (bluer), and
+// scrolling down makes it warmer.
+func (m *Module) clickHandler(i Info) func(bar.Event) {
+	return func(e bar.Event) {
+		m.mu.Lock()
+		step := m.step
+		m.mu.Unlock()
+		switch e.Button {
+		case bar.ButtonLeft:
+			i.Toggle()
+		case bar.ScrollUp:
+			i.SetTemperature(i.Temperature + step)
+		case bar.ScrollDown:
+			i.SetTemperature(i.Temperature - step)
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	m.mu.Lock()
+	if _, err := m.state.Get(); err != nil {
+		// Clear any error from a previous run, e.g. when restarted by a
+		// click on the error segment.
+		m.state.Set(Info{Temperature: 4500})
+	}
+	if r, ok := m.backend.(temperatureReader); ok {
+		// Pick up the current state if the night light is already on.
+		if t, err := r.Temperature(); err == nil && t != Neutral {
+			m.state.Set(Info{Enabled: true, Temperature: t})
+		}
+	}
+	m.mu.Unlock()
+
+	i, err := m.state.Get()
+	nextState, done := m.state.Subscribe()
+	defer done()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	for {
+		if s.Error(err) {
+			return
+		}
+		info := i.(Info)
+		info.module = m
+		s.Output(outputs.Group(outputFunc(info)).OnClick(m.clickHandler(info)))
+		select {
+		case <-nextState:
+			i, err = m.state.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
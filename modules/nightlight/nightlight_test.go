@@ -0,0 +1,217 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nightlight
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	commands   []string
+	commandsMu sync.Mutex
+	xrandrOut  string
+)
+
+func init() {
+	command = func(name string, args ...string) (string, error) {
+		commandsMu.Lock()
+		defer commandsMu.Unlock()
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		commands = append(commands, cmd)
+		if strings.HasPrefix(cmd, "missing") {
+			return "", errors.New("not found")
+		}
+		if cmd == "xrandr --query" {
+			return xrandrOut, nil
+		}
+		return "", nil
+	}
+}
+
+func takeCommands() []string {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	c := commands
+	commands = nil
+	return c
+}
+
+func TestOneShot(t *testing.T) {
+	takeCommands()
+	require.NoError(t, Gammastep().SetTemperature(4000))
+	require.NoError(t, Redshift().SetTemperature(Neutral))
+	require.Equal(t, []string{"gammastep -P -O 4000", "redshift -x"}, takeCommands())
+	require.Error(t, oneShot{"missing"}.SetTemperature(3000))
+}
+
+func TestXrandr(t *testing.T) {
+	takeCommands()
+	require.NoError(t, Xrandr("HDMI-1").SetTemperature(Neutral))
+	require.Equal(t, []string{"xrandr --output HDMI-1 --gamma 1.000:1.000:1.000"},
+		takeCommands())
+
+	xrandrOut = `Screen 0: minimum 8 x 8, current 3840 x 1080, maximum 32767 x 32767
+eDP-1 connected primary 1920x1080+0+0 (normal left inverted right x axis y axis) 309mm x 174mm
+   1920x1080     60.02*+
+DP-1 disconnected (normal left inverted right x axis y axis)
+HDMI-1 connected 1920x1080+1920+0 (normal left inverted right x axis y axis) 527mm x 296mm
+`
+	require.NoError(t, Xrandr().SetTemperature(3400))
+	require.Equal(t, []string{
+		"xrandr --query",
+		"xrandr --output eDP-1 --gamma 1.000:0.746:0.541",
+		"xrandr --output HDMI-1 --gamma 1.000:0.746:0.541",
+	}, takeCommands())
+
+	xrandrOut = ""
+	require.Error(t, Xrandr().SetTemperature(3400), "no outputs")
+
+	r, g, b := whitePoint(MaxTemperature)
+	require.True(t, r < 1 && g < 1 && b == 1, "cooler than neutral: %v %v %v", r, g, b)
+	r, g, b = whitePoint(MinTemperature)
+	require.True(t, r == 1 && g < 0.5 && b == 0, "very warm: %v %v %v", r, g, b)
+}
+
+func TestGammaRelay(t *testing.T) {
+	busType = dbus.Test
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService("rs.wl-gammarelay")
+	obj := srv.Object("/", "rs.wl.gammarelay")
+	obj.SetProperties(map[string]interface{}{"Temperature": uint16(6500)},
+		dbus.SignalTypeNone)
+	var deltas []int16
+	obj.On("UpdateTemperature", func(args ...interface{}) ([]interface{}, error) {
+		deltas = append(deltas, args[0].(int16))
+		return nil, nil
+	})
+
+	g := GammaRelay()
+	temp, err := g.(temperatureReader).Temperature()
+	require.NoError(t, err)
+	require.Equal(t, Neutral, temp)
+
+	require.NoError(t, g.SetTemperature(4000))
+	require.Equal(t, []int16{-2500}, deltas)
+
+	obj.SetProperty("Temperature", uint16(4000), dbus.SignalTypeChanged)
+	require.NoError(t, g.SetTemperature(4500))
+	require.Equal(t, []int16{-2500, 500}, deltas)
+}
+
+type testBackend struct {
+	sync.Mutex
+	temps []int
+	err   error
+}
+
+func (t *testBackend) SetTemperature(kelvin int) error {
+	t.Lock()
+	defer t.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	t.temps = append(t.temps, kelvin)
+	return nil
+}
+
+func (t *testBackend) applied() []int {
+	t.Lock()
+	defer t.Unlock()
+	r := t.temps
+	t.temps = nil
+	return r
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	b := &testBackend{}
+	m := New(b)
+	testBar.Run(m)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"night off"})
+	require.Empty(t, b.applied(), "nothing applied on start")
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"night 4500K"})
+	require.Equal(t, []int{4500}, b.applied())
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll down")
+	out.AssertText([]string{"night 4400K"})
+
+	m.Step(500)
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll up")
+	out.AssertText([]string{"night 4900K"})
+	require.Equal(t, []int{4400, 4900}, b.applied())
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"night off"})
+	require.Equal(t, []int{Neutral}, b.applied())
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll while disabled")
+	out.AssertText([]string{"night 5400K"})
+
+	var info Info
+	m.Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%v/%d", i.Enabled, i.Temperature)
+	})
+	testBar.NextOutput("on output change").AssertText([]string{"true/5400"})
+
+	info.SetTemperature(50000)
+	testBar.NextOutput("clamps temperature").AssertText([]string{"true/10000"})
+	info.SetTemperature(0)
+	testBar.NextOutput("clamps temperature").AssertText([]string{"true/1000"})
+	info.SetEnabled(false)
+	testBar.NextOutput().AssertText([]string{"false/1000"})
+
+	b.Lock()
+	b.err = errors.New("foo")
+	b.Unlock()
+	info.Toggle()
+	testBar.NextOutput("on error").AssertError()
+}
+
+type readerBackend struct{ testBackend }
+
+func (*readerBackend) Temperature() (int, error) { return 3500, nil }
+
+func TestInitialState(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(New(&readerBackend{}))
+	testBar.NextOutput().AssertText([]string{"night 3500K"},
+		"reads current temperature")
+
+	testBar.New(t)
+	testBar.Run(New(&testBackend{}).Temperature(3000).Output(func(i Info) bar.Output {
+		return outputs.Textf("%v/%d", i.Enabled, i.Temperature)
+	}))
+	testBar.NextOutput().AssertText([]string{"false/3000"})
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifications
+
+import (
+	"reflect"
+
+	"barista.run/base/watchers/dbus"
+
+	godbus "github.com/godbus/dbus"
+)
+
+const (
+	notificationsService = "org.freedesktop.Notifications"
+	propertiesSet        = "org.freedesktop.DBus.Properties.Set"
+)
+
+type dunst struct{}
+
+const dunstIface = "org.dunstproject.cmd0"
+
+func (dunst) watch() *dbus.PropertiesWatcher {
+	return dbus.WatchProperties(busType,
+		notificationsService,
+		"/org/freedesktop/Notifications",
+		dunstIface,
+	).
+		Add("paused").
+		Fetch("displayedLength", "waitingLength", "historyLength")
+}
+
+func (dunst) info(w *dbus.PropertiesWatcher) Info {
+	props := w.Get()
+	i := Info{}
+	if paused, ok := props["paused"].(bool); ok {
+		i.Connected = true
+		i.DoNotDisturb = paused
+	}
+	i.Displayed = toInt(props["displayedLength"])
+	i.Waiting = toInt(props["waitingLength"])
+	i.History = toInt(props["historyLength"])
+	return i
+}
+
+func (dunst) setDND(w *dbus.PropertiesWatcher, dnd bool) error {
+	_, err := w.Call(propertiesSet, dunstIface, "paused", godbus.MakeVariant(dnd))
+	return err
+}
+
+func (dunst) showLast(w *dbus.PropertiesWatcher) error {
+	_, err := w.Call("NotificationShow")
+	return err
+}
+
+type mako struct {
+	dndMode string
+}
+
+func (mako) watch() *dbus.PropertiesWatcher {
+	return dbus.WatchProperties(busType,
+		notificationsService, "/fr/emersion/Mako", "fr.emersion.Mako")
+}
+
+func (m mako) modes(w *dbus.PropertiesWatcher) ([]string, error) {
+	res, err := w.Call("GetModes")
+	if err != nil {
+		return nil, err
+	}
+	if len(res) > 0 {
+		modes, _ := res[0].([]string)
+		return modes, nil
+	}
+	return nil, nil
+}
+
+func (m mako) info(w *dbus.PropertiesWatcher) Info {
+	i := Info{}
+	modes, err := m.modes(w)
+	if err != nil {
+		return i
+	}
+	i.Connected = true
+	for _, mode := range modes {
+		if mode == m.dndMode {
+			i.DoNotDisturb = true
+		}
+	}
+	if res, err := w.Call("ListNotifications"); err == nil && len(res) > 0 {
+		i.Displayed = length(res[0])
+	}
+	if res, err := w.Call("ListHistory"); err == nil && len(res) > 0 {
+		i.History = length(res[0])
+	}
+	return i
+}
+
+func (m mako) setDND(w *dbus.PropertiesWatcher, dnd bool) error {
+	modes, err := m.modes(w)
+	if err != nil {
+		return err
+	}
+	newModes := []string{}
+	for _, mode := range modes {
+		if mode != m.dndMode {
+			newModes = append(newModes, mode)
+		}
+	}
+	if dnd {
+		newModes = append(newModes, m.dndMode)
+	}
+	_, err = w.Call("SetModes", newModes)
+	return err
+}
+
+func (mako) showLast(w *dbus.PropertiesWatcher) error {
+	_, err := w.Call("RestoreNotification")
+	return err
+}
+
+func toInt(v interface{}) int {
+	switch v := v.(type) {
+	case uint32:
+		return int(v)
+	case int32:
+		return int(v)
+	case int:
+		return int(v)
+	}
+	return 0
+}
+
+// length returns the length of a list returned over D-Bus, without needing
+// to know the concrete type of its elements.
+func length(v interface{}) int {
+	r := reflect.ValueOf(v)
+	if r.Kind() != reflect.Slice {
+		return 0
+	}
+	return r.Len()
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package notifications provides an i3bar module that shows the state of the
+notification daemon, using the D-Bus interfaces of dunst or mako.
+
+It shows whether do-not-disturb is enabled, along with the number of
+notifications that are displayed, waiting (held back while do-not-disturb is
+enabled), or in the history. By default, a left click toggles do-not-disturb,
+and a right click redisplays the last notification from the history.
+*/
+package notifications // import "barista.run/modules/notifications"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info represents the state of the notification daemon.
+type Info struct {
+	// DoNotDisturb is true if notifications are not being displayed.
+	DoNotDisturb bool
+	// Displayed is the number of notifications currently on screen.
+	Displayed int
+	// Waiting is the number of notifications held back because
+	// do-not-disturb is enabled. Not all daemons report this.
+	Waiting int
+	// History is the number of dismissed notifications in the history.
+	History int
+	// Connected is true if the notification daemon is running.
+	Connected bool
+	setDND    func(bool)
+	showLast  func()
+}
+
+// SetDoNotDisturb enables or disables do-not-disturb.
+func (i Info) SetDoNotDisturb(dnd bool) {
+	i.setDND(dnd)
+}
+
+// ToggleDoNotDisturb toggles the do-not-disturb state.
+func (i Info) ToggleDoNotDisturb() {
+	i.setDND(!i.DoNotDisturb)
+}
+
+// ShowLast redisplays the most recent notification from the history.
+func (i Info) ShowLast() {
+	i.showLast()
+}
+
+// daemon abstracts the D-Bus interface of a notification daemon.
+type daemon interface {
+	// watch creates a properties watcher for the daemon's control object.
+	watch() *dbus.PropertiesWatcher
+	// info returns the current state of the daemon.
+	info(w *dbus.PropertiesWatcher) Info
+	setDND(w *dbus.PropertiesWatcher, dnd bool) error
+	showLast(w *dbus.PropertiesWatcher) error
+}
+
+var busType = dbus.Session
+
+// Module represents a notification daemon bar module.
+type Module struct {
+	daemon     daemon
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(d daemon) *Module {
+	m := &Module{daemon: d, scheduler: timing.NewScheduler()}
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output shows the do-not-disturb state, and the number of
+	// notifications waiting to be shown, if any.
+	m.Output(func(i Info) bar.Output {
+		if !i.Connected {
+			return nil
+		}
+		if !i.DoNotDisturb {
+			return outputs.Text("notif on")
+		}
+		if i.Waiting > 0 {
+			return outputs.Textf("DND (%d)", i.Waiting)
+		}
+		return outputs.Text("DND")
+	})
+	// Not all counts are signalled when they change, so poll as well.
+	m.RefreshInterval(5 * time.Second)
+	return m
+}
+
+// Dunst creates a module that shows the state of dunst.
+func Dunst() *Module {
+	return newModule(dunst{})
+}
+
+// Mako creates a module that shows the state of mako (1.7 or later).
+// Do-not-disturb is implemented using mako's modes, and expects the mako
+// configuration to contain a "do-not-disturb" mode that hides notifications:
+//
+//	[mode=do-not-disturb]
+//	invisible=1
+func Mako() *Module {
+	return newModule(mako{dndMode: "do-not-disturb"})
+}
+
+// MakoMode creates a module that shows the state of mako, using the given
+// mode for do-not-disturb.
+func MakoMode(dndMode string) *Module {
+	return newModule(mako{dndMode: dndMode})
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for notification counts.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	w := m.daemon.watch()
+	defer w.Unsubscribe()
+
+	refreshFn, refreshCh := notifier.New()
+	getInfo := func() Info {
+		i := m.daemon.info(w)
+		i.setDND = func(dnd bool) {
+			if err := m.daemon.setDND(w, dnd); err != nil {
+				l.Log("Failed to set do-not-disturb: %v", err)
+			}
+			refreshFn()
+		}
+		i.showLast = func() {
+			if err := m.daemon.showLast(w); err != nil {
+				l.Log("Failed to show last notification: %v", err)
+			}
+			refreshFn()
+		}
+		return i
+	}
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	info := getInfo()
+	for {
+		s.Output(outputs.Group(outputFunc(info)).OnClick(
+			click.Map{}.
+				Left(info.ToggleDoNotDisturb).
+				Right(info.ShowLast).
+				Handle,
+		))
+		select {
+		case <-w.Updates:
+			info = getInfo()
+		case <-refreshCh:
+			info = getInfo()
+		case <-m.scheduler.C:
+			info = getInfo()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifications
+
+import (
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	godbus "github.com/godbus/dbus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	busType = dbus.Test
+}
+
+type calls struct {
+	sync.Mutex
+	names []string
+}
+
+func (c *calls) record(name string) {
+	c.Lock()
+	defer c.Unlock()
+	c.names = append(c.names, name)
+}
+
+func (c *calls) take() []string {
+	c.Lock()
+	defer c.Unlock()
+	r := c.names
+	c.names = nil
+	return r
+}
+
+func TestDunst(t *testing.T) {
+	testBar.New(t)
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService(notificationsService)
+	obj := srv.Object("/org/freedesktop/Notifications", dunstIface)
+	obj.SetProperties(map[string]interface{}{
+		"paused":          false,
+		"displayedLength": uint32(1),
+		"waitingLength":   uint32(0),
+		"historyLength":   uint32(4),
+	}, dbus.SignalTypeNone)
+
+	c := &calls{}
+	setCh := make(chan bool, 1)
+	obj.OnElse(func(method string, args ...interface{}) ([]interface{}, error) {
+		c.record(method)
+		if method == propertiesSet {
+			require.Equal(t, dunstIface, args[0])
+			require.Equal(t, "paused", args[1])
+			setCh <- args[2].(godbus.Variant).Value().(bool)
+		}
+		return nil, nil
+	})
+
+	testBar.Run(Dunst().Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %d/%d/%d", i.DoNotDisturb, i.Displayed, i.Waiting, i.History)
+	}))
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"false 1/0/4"})
+
+	out.At(0).LeftClick()
+	require.True(t, <-setCh, "enables dnd on click")
+	testBar.NextOutput("refreshes after action")
+	obj.SetProperties(map[string]interface{}{
+		"paused":        true,
+		"waitingLength": uint32(2),
+	}, dbus.SignalTypeChanged)
+	out = testBar.NextOutput("on signal")
+	out.AssertText([]string{"true 1/2/4"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	testBar.NextOutput("refreshes after action")
+	require.Equal(t, []string{propertiesSet, dunstIface + ".NotificationShow"}, c.take())
+
+	obj.SetProperty("historyLength", uint32(3), dbus.SignalTypeNone)
+	testBar.AssertNoOutput("without signal")
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"true 1/2/3"})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	dbus.SetupTestBus()
+	testBar.Run(Dunst())
+	testBar.NextOutput("not running").AssertEmpty()
+
+	testBar.New(t)
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService(notificationsService)
+	obj := srv.Object("/org/freedesktop/Notifications", dunstIface)
+	obj.SetProperties(map[string]interface{}{
+		"paused":        false,
+		"waitingLength": uint32(0),
+	}, dbus.SignalTypeNone)
+	testBar.Run(Dunst())
+	testBar.NextOutput("on start").AssertText([]string{"notif on"})
+
+	obj.SetProperty("paused", true, dbus.SignalTypeChanged)
+	testBar.NextOutput("on signal").AssertText([]string{"DND"})
+
+	obj.SetProperty("waitingLength", uint32(3), dbus.SignalTypeNone)
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"DND (3)"})
+}
+
+func TestMako(t *testing.T) {
+	testBar.New(t)
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService(notificationsService)
+	obj := srv.Object("/fr/emersion/Mako", "fr.emersion.Mako")
+
+	var modesMu sync.Mutex
+	modes := []string{"default"}
+	c := &calls{}
+	obj.OnElse(func(method string, args ...interface{}) ([]interface{}, error) {
+		c.record(method)
+		modesMu.Lock()
+		defer modesMu.Unlock()
+		switch method {
+		case "fr.emersion.Mako.GetModes":
+			return []interface{}{modes}, nil
+		case "fr.emersion.Mako.SetModes":
+			modes = args[0].([]string)
+		case "fr.emersion.Mako.ListNotifications":
+			return []interface{}{[]map[string]godbus.Variant{{}, {}}}, nil
+		case "fr.emersion.Mako.ListHistory":
+			return []interface{}{[]map[string]godbus.Variant{{}}}, nil
+		}
+		return nil, nil
+	})
+
+	testBar.Run(Mako().Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %d/%d", i.DoNotDisturb, i.Displayed, i.History)
+	}))
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"false 2/1"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"true 2/1"})
+	modesMu.Lock()
+	require.Equal(t, []string{"default", "do-not-disturb"}, modes)
+	modesMu.Unlock()
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"false 2/1"})
+	modesMu.Lock()
+	require.Equal(t, []string{"default"}, modes)
+	modesMu.Unlock()
+
+	c.take()
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	testBar.NextOutput("on right click")
+	require.Contains(t, c.take(), "fr.emersion.Mako.RestoreNotification")
+}
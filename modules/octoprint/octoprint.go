@@ -0,0 +1,324 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package octoprint provides an i3bar module that shows the progress of the
+current 3D print, along with the hotend and bed temperatures.
+
+Both OctoPrint and PrusaLink (used by recent Prusa printers) are supported.
+Both require an API key, which is shown in the OctoPrint settings, or on the
+printer for PrusaLink.
+*/
+package octoprint // import "barista.run/modules/octoprint"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/httpclient"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Temperature represents the current and target temperature of a heater.
+type Temperature struct {
+	Actual unit.Temperature
+	// Target is zero if the heater is off.
+	Target unit.Temperature
+}
+
+// Info represents the state of the printer and the current job.
+type Info struct {
+	// State is the state of the printer as reported by the server, e.g.
+	// "Printing", or "Offline" if the printer is not connected.
+	State string
+	// Printing is true while a job is printing or paused.
+	Printing bool
+	Paused   bool
+	File     string
+	// Progress is the fraction of the job that is complete, from 0 to 1.
+	Progress  float64
+	Elapsed   time.Duration
+	Remaining time.Duration
+	Hotend    Temperature
+	Bed       Temperature
+}
+
+// ETA returns the estimated time when the job will finish.
+func (i Info) ETA() time.Time {
+	return timing.Now().Add(i.Remaining)
+}
+
+// backend fetches the printer state from a server.
+type backend interface {
+	fetch(get getFunc) (Info, error)
+}
+
+// getFunc fetches the JSON at the given path into out. It returns false
+// (with no error) if the server responds with one of the allowed statuses.
+type getFunc func(path string, out interface{}, allowed ...int) (bool, error)
+
+// Module represents a 3D printer bar module.
+type Module struct {
+	server     string
+	apiKey     string
+	backend    backend
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(server, apiKey string, b backend) *Module {
+	m := &Module{
+		server:    strings.TrimSuffix(server, "/"),
+		apiKey:    apiKey,
+		backend:   b,
+		scheduler: timing.NewScheduler().Named("octoprint"),
+	}
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the progress, time remaining, and temperatures,
+	// hidden when not printing.
+	m.Output(func(i Info) bar.Output {
+		if !i.Printing {
+			return nil
+		}
+		if i.Paused {
+			return outputs.Textf("%.0f%% paused", i.Progress*100)
+		}
+		rem := i.Remaining
+		return outputs.Textf("%.0f%% %d:%02d left, %.0f/%.0f℃",
+			i.Progress*100, int(rem.Hours()), int(rem.Minutes())%60,
+			i.Hotend.Actual.Celsius(), i.Bed.Actual.Celsius())
+	})
+	m.RefreshInterval(30 * time.Second)
+	return m
+}
+
+// New creates a module for the OctoPrint server at the given URL, e.g.
+// "http://octopi.local".
+func New(server, apiKey string) *Module {
+	return newModule(server, apiKey, octoPrint{})
+}
+
+// PrusaLink creates a module for the PrusaLink printer at the given URL.
+func PrusaLink(server, apiKey string) *Module {
+	return newModule(server, apiKey, prusaLink{})
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+var client = httpclient.Client()
+
+func (m *Module) get(path string, out interface{}, allowed ...int) (bool, error) {
+	req, err := http.NewRequest("GET", m.server+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("X-Api-Key", m.apiKey)
+	res, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	for _, s := range allowed {
+		if res.StatusCode == s {
+			return false, nil
+		}
+	}
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s: %s", path, res.Status)
+	}
+	return true, json.NewDecoder(res.Body).Decode(out)
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.backend.fetch(m.get)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.backend.fetch(m.get)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s) * time.Second
+}
+
+type octoPrint struct{}
+
+type octoJob struct {
+	Job struct {
+		File struct {
+			Name string `json:"name"`
+		} `json:"file"`
+	} `json:"job"`
+	Progress struct {
+		Completion    *float64 `json:"completion"`
+		PrintTime     *float64 `json:"printTime"`
+		PrintTimeLeft *float64 `json:"printTimeLeft"`
+	} `json:"progress"`
+	State string `json:"state"`
+}
+
+type octoTemp struct {
+	Actual float64 `json:"actual"`
+	Target float64 `json:"target"`
+}
+
+func (t *octoTemp) temperature() Temperature {
+	if t == nil {
+		return Temperature{}
+	}
+	return Temperature{unit.FromCelsius(t.Actual), unit.FromCelsius(t.Target)}
+}
+
+type octoPrinter struct {
+	Temperature struct {
+		Tool0 *octoTemp `json:"tool0"`
+		Bed   *octoTemp `json:"bed"`
+	} `json:"temperature"`
+	State struct {
+		Flags struct {
+			Printing bool `json:"printing"`
+			Paused   bool `json:"paused"`
+		} `json:"flags"`
+	} `json:"state"`
+}
+
+func (octoPrint) fetch(get getFunc) (Info, error) {
+	var p octoPrinter
+	// OctoPrint responds with 409 Conflict if the printer is not connected.
+	ok, err := get("/api/printer", &p, http.StatusConflict)
+	if err != nil {
+		return Info{}, err
+	}
+	if !ok {
+		return Info{State: "Offline"}, nil
+	}
+	var j octoJob
+	if _, err := get("/api/job", &j); err != nil {
+		return Info{}, err
+	}
+	i := Info{
+		State:    j.State,
+		Paused:   p.State.Flags.Paused,
+		Printing: p.State.Flags.Printing || p.State.Flags.Paused,
+		File:     j.Job.File.Name,
+		Hotend:   p.Temperature.Tool0.temperature(),
+		Bed:      p.Temperature.Bed.temperature(),
+	}
+	if c := j.Progress.Completion; c != nil {
+		i.Progress = *c / 100
+	}
+	if t := j.Progress.PrintTime; t != nil {
+		i.Elapsed = seconds(*t)
+	}
+	if t := j.Progress.PrintTimeLeft; t != nil {
+		i.Remaining = seconds(*t)
+	}
+	return i, nil
+}
+
+type prusaLink struct{}
+
+type prusaStatus struct {
+	Job *struct {
+		Progress      float64 `json:"progress"`
+		TimeRemaining float64 `json:"time_remaining"`
+		TimePrinting  float64 `json:"time_printing"`
+	} `json:"job"`
+	Printer struct {
+		State        string  `json:"state"`
+		TempNozzle   float64 `json:"temp_nozzle"`
+		TargetNozzle float64 `json:"target_nozzle"`
+		TempBed      float64 `json:"temp_bed"`
+		TargetBed    float64 `json:"target_bed"`
+	} `json:"printer"`
+}
+
+type prusaJob struct {
+	File struct {
+		DisplayName string `json:"display_name"`
+		Name        string `json:"name"`
+	} `json:"file"`
+}
+
+func (prusaLink) fetch(get getFunc) (Info, error) {
+	var s prusaStatus
+	if _, err := get("/api/v1/status", &s); err != nil {
+		return Info{}, err
+	}
+	p := s.Printer
+	state := strings.ToUpper(p.State)
+	i := Info{
+		// PrusaLink states are upper case, e.g. PRINTING.
+		State:  strings.Title(strings.ToLower(p.State)),
+		Paused: state == "PAUSED",
+		Hotend: Temperature{unit.FromCelsius(p.TempNozzle), unit.FromCelsius(p.TargetNozzle)},
+		Bed:    Temperature{unit.FromCelsius(p.TempBed), unit.FromCelsius(p.TargetBed)},
+	}
+	i.Printing = state == "PRINTING" || i.Paused
+	if s.Job == nil || !i.Printing {
+		return i, nil
+	}
+	i.Progress = s.Job.Progress / 100
+	i.Elapsed = seconds(s.Job.TimePrinting)
+	i.Remaining = seconds(s.Job.TimeRemaining)
+	// The job endpoint responds with 204 No Content if there is no job.
+	var j prusaJob
+	ok, err := get("/api/v1/job", &j, http.StatusNoContent)
+	if err != nil {
+		return Info{}, err
+	}
+	if ok {
+		i.File = j.File.DisplayName
+		if i.File == "" {
+			i.File = j.File.Name
+		}
+	}
+	return i, nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package octoprint
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses map[string]string
+	statuses  map[string]int
+}
+
+func newServer() *fakeServer {
+	f := &fakeServer{
+		responses: map[string]string{},
+		statuses:  map[string]int{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if s, ok := f.statuses[r.URL.Path]; ok {
+			w.WriteHeader(s)
+			return
+		}
+		body, ok := f.responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	return f
+}
+
+func (f *fakeServer) respond(path, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.statuses, path)
+	f.responses[path] = body
+}
+
+func (f *fakeServer) status(path string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[path] = status
+}
+
+const octoPrinting = `{
+	"temperature": {
+		"tool0": {"actual": 214.8, "target": 215.0},
+		"bed": {"actual": 60.1, "target": 60.0}
+	},
+	"state": {"text": "Printing", "flags": {"printing": true, "paused": false}}
+}`
+
+const octoJobPrinting = `{
+	"job": {"file": {"name": "benchy.gcode"}},
+	"progress": {"completion": 42.3, "printTime": 1800, "printTimeLeft": 3900},
+	"state": "Printing"
+}`
+
+func TestOctoPrint(t *testing.T) {
+	testBar.New(t)
+	srv := newServer()
+	defer srv.Close()
+	srv.status("/api/printer", http.StatusConflict)
+
+	m := New(srv.URL+"/", "key")
+	testBar.Run(m)
+	testBar.NextOutput("printer offline").AssertEmpty()
+
+	srv.respond("/api/printer", octoPrinting)
+	srv.respond("/api/job", octoJobPrinting)
+	testBar.Tick()
+	testBar.NextOutput("printing").AssertText([]string{"42% 1:05 left, 215/60℃"})
+
+	var info Info
+	m.Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%s %s", i.State, i.File)
+	})
+	testBar.NextOutput("output changed").AssertText([]string{"Printing benchy.gcode"})
+	require.InDelta(t, 0.423, info.Progress, 0.0001)
+	require.Equal(t, 30*time.Minute, info.Elapsed)
+	require.Equal(t, 65*time.Minute, info.Remaining)
+	require.InDelta(t, 214.8, info.Hotend.Actual.Celsius(), 0.0001)
+	require.Equal(t, unit.FromCelsius(60), info.Bed.Target)
+	require.True(t, info.Printing)
+
+	srv.respond("/api/printer", `{
+		"temperature": {"tool0": {"actual": 40, "target": 0}, "bed": {"actual": 35, "target": 0}},
+		"state": {"flags": {"printing": false, "paused": false, "operational": true}}
+	}`)
+	srv.respond("/api/job", `{
+		"job": {"file": {"name": null}},
+		"progress": {"completion": null, "printTime": null, "printTimeLeft": null},
+		"state": "Operational"
+	}`)
+	testBar.Tick()
+	testBar.NextOutput("idle").AssertText([]string{"Operational "})
+	require.False(t, info.Printing)
+	require.Equal(t, time.Duration(0), info.Remaining)
+
+	srv.status("/api/job", http.StatusInternalServerError)
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	srv := newServer()
+	defer srv.Close()
+	srv.respond("/api/printer", `{
+		"temperature": {"tool0": {"actual": 170, "target": 0}, "bed": {"actual": 58, "target": 60}},
+		"state": {"flags": {"printing": false, "paused": true}}
+	}`)
+	srv.respond("/api/job", octoJobPrinting)
+
+	testBar.Run(New(srv.URL, "key"))
+	testBar.NextOutput("paused").AssertText([]string{"42% paused"})
+
+	srv.respond("/api/printer", octoPrinting)
+	testBar.Tick()
+	testBar.NextOutput("printing").AssertText([]string{"42% 1:05 left, 215/60℃"})
+}
+
+func TestPrusaLink(t *testing.T) {
+	testBar.New(t)
+	srv := newServer()
+	defer srv.Close()
+	srv.respond("/api/v1/status", `{
+		"printer": {"state": "IDLE", "temp_nozzle": 24.5, "target_nozzle": 0,
+			"temp_bed": 23.9, "target_bed": 0}
+	}`)
+	srv.status("/api/v1/job", http.StatusNoContent)
+
+	var info Info
+	m := PrusaLink(srv.URL, "key")
+	testBar.Run(m)
+	testBar.NextOutput("idle").AssertEmpty()
+
+	m.Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%s %s %.0f%%", i.State, i.File, i.Progress*100)
+	})
+	testBar.NextOutput("output changed").AssertText([]string{"Idle  0%"})
+	require.False(t, info.Printing)
+	require.InDelta(t, 24.5, info.Hotend.Actual.Celsius(), 0.0001)
+
+	srv.respond("/api/v1/status", `{
+		"job": {"id": 12, "progress": 87, "time_remaining": 600, "time_printing": 5400},
+		"printer": {"state": "PRINTING", "temp_nozzle": 249.6, "target_nozzle": 250,
+			"temp_bed": 89.8, "target_bed": 90}
+	}`)
+	srv.respond("/api/v1/job", `{
+		"id": 12, "state": "PRINTING", "progress": 87,
+		"file": {"name": "PLANTE~1.BGC", "display_name": "planter.bgcode"}
+	}`)
+	testBar.Tick()
+	testBar.NextOutput("printing").AssertText([]string{"Printing planter.bgcode 87%"})
+	require.True(t, info.Printing)
+	require.Equal(t, 10*time.Minute, info.Remaining)
+	require.Equal(t, 90*time.Minute, info.Elapsed)
+	require.Equal(t, unit.FromCelsius(250), info.Hotend.Target)
+
+	srv.status("/api/v1/status", http.StatusUnauthorized)
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestBadKey(t *testing.T) {
+	testBar.New(t)
+	srv := newServer()
+	defer srv.Close()
+	srv.respond("/api/v1/status", `{}`)
+	testBar.Run(PrusaLink(srv.URL, "wrong"))
+	errs := testBar.NextOutput("with wrong key").AssertError()
+	require.Contains(t, errs[0], "403")
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package oncall provides an i3bar module that shows whether the user is
+currently on call, and the number of open incidents assigned to them.
+
+On-call status and incidents are fetched using a Provider, implemented by the
+provider packages:
+  - pagerduty: PagerDuty, using a user API token.
+  - opsgenie: Opsgenie, using an API key and the username.
+*/
+package oncall // import "barista.run/modules/oncall"
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Incident represents an open incident.
+type Incident struct {
+	ID    string
+	Title string
+	// URL is the web page for the incident, if available.
+	URL          string
+	Acknowledged bool
+	Created      time.Time
+}
+
+// Info represents the on-call status and open incidents.
+type Info struct {
+	OnCall bool
+	// Incidents are the open incidents assigned to the user, most recent
+	// first.
+	Incidents   []Incident
+	acknowledge func(Incident)
+}
+
+// Triggered returns the incidents that have not been acknowledged.
+func (i Info) Triggered() []Incident {
+	var triggered []Incident
+	for _, inc := range i.Incidents {
+		if !inc.Acknowledged {
+			triggered = append(triggered, inc)
+		}
+	}
+	return triggered
+}
+
+// Urgent returns true if any incident has not been acknowledged.
+func (i Info) Urgent() bool {
+	return len(i.Triggered()) > 0
+}
+
+// Acknowledge acknowledges the most recent incident that has not been
+// acknowledged yet, if any.
+func (i Info) Acknowledge() {
+	if t := i.Triggered(); len(t) > 0 && i.acknowledge != nil {
+		i.acknowledge(t[0])
+	}
+}
+
+// Provider is an interface for paging providers, implemented by the various
+// provider packages.
+type Provider interface {
+	// OnCall returns true if the user is currently on call.
+	OnCall() (bool, error)
+	// Incidents returns the open incidents assigned to the user.
+	Incidents() ([]Incident, error)
+	// Acknowledge acknowledges the given incident.
+	Acknowledge(Incident) error
+}
+
+// Module represents an on-call status bar module.
+type Module struct {
+	provider   Provider
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+}
+
+// New creates an on-call module using the given provider.
+func New(provider Provider) *Module {
+	m := &Module{provider: provider, scheduler: timing.NewScheduler().Named("oncall")}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the number of open incidents, which is urgent if any
+	// of them have not been acknowledged. Clicking acknowledges the most
+	// recent incident.
+	m.Output(func(i Info) bar.Output {
+		var text string
+		switch n := len(i.Incidents); {
+		case n > 0 && i.OnCall:
+			text = fmt.Sprintf("On call: %d incidents", n)
+		case n > 0:
+			text = fmt.Sprintf("%d incidents", n)
+		case i.OnCall:
+			text = "On call"
+		default:
+			return nil
+		}
+		return outputs.Text(text).
+			Urgent(i.Urgent()).
+			OnClick(click.Left(i.Acknowledge))
+	})
+	m.RefreshInterval(time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+func (m *Module) acknowledge(inc Incident) {
+	if err := m.provider.Acknowledge(inc); err != nil {
+		l.Log("Failed to acknowledge %s: %v", inc.ID, err)
+	}
+	m.refreshFn()
+}
+
+func (m *Module) fetch() (Info, error) {
+	i := Info{acknowledge: m.acknowledge}
+	var err error
+	if i.OnCall, err = m.provider.OnCall(); err != nil {
+		return i, err
+	}
+	if i.Incidents, err = m.provider.Incidents(); err != nil {
+		return i, err
+	}
+	sort.SliceStable(i.Incidents, func(a, b int) bool {
+		return i.Incidents[a].Created.After(i.Incidents[b].Created)
+	})
+	return i, nil
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.fetch()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.fetch()
+		case <-m.refreshCh:
+			info, err = m.fetch()
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oncall
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sync.Mutex
+	onCall    bool
+	incidents []Incident
+	err       error
+	acked     []string
+}
+
+func (t *testProvider) OnCall() (bool, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.onCall, t.err
+}
+
+func (t *testProvider) Incidents() ([]Incident, error) {
+	t.Lock()
+	defer t.Unlock()
+	return append([]Incident(nil), t.incidents...), t.err
+}
+
+func (t *testProvider) Acknowledge(i Incident) error {
+	t.Lock()
+	defer t.Unlock()
+	t.acked = append(t.acked, i.ID)
+	for idx := range t.incidents {
+		if t.incidents[idx].ID == i.ID {
+			t.incidents[idx].Acknowledged = true
+		}
+	}
+	return t.err
+}
+
+func (t *testProvider) set(onCall bool, err error, incidents ...Incident) {
+	t.Lock()
+	defer t.Unlock()
+	t.onCall, t.err, t.incidents = onCall, err, incidents
+}
+
+func (t *testProvider) takeAcked() []string {
+	t.Lock()
+	defer t.Unlock()
+	a := t.acked
+	t.acked = nil
+	return a
+}
+
+var start = time.Date(2018, 4, 1, 9, 0, 0, 0, time.UTC)
+
+func TestInfo(t *testing.T) {
+	i := Info{Incidents: []Incident{
+		{ID: "a", Acknowledged: true},
+		{ID: "b"},
+	}}
+	require.True(t, i.Urgent())
+	require.Equal(t, []Incident{{ID: "b"}}, i.Triggered())
+	require.NotPanics(t, i.Acknowledge, "zero value")
+
+	i.Incidents[1].Acknowledged = true
+	require.False(t, i.Urgent())
+	require.Empty(t, i.Triggered())
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{}
+	p.set(true, nil)
+	m := New(p).Output(func(i Info) bar.Output {
+		ids := ""
+		for _, inc := range i.Incidents {
+			ids += inc.ID
+		}
+		return outputs.Textf("%v %s %d", i.OnCall, ids, len(i.Triggered())).
+			OnClick(func(bar.Event) { i.Acknowledge() })
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"true  0"})
+
+	p.set(false, nil,
+		Incident{ID: "a", Created: start},
+		Incident{ID: "b", Created: start.Add(time.Hour)},
+		Incident{ID: "c", Created: start.Add(-time.Hour), Acknowledged: true},
+	)
+	testBar.Tick()
+	out := testBar.NextOutput("on refresh")
+	out.AssertText([]string{"false bac 2"}, "sorted by most recent")
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on acknowledge")
+	out.AssertText([]string{"false bac 1"})
+	require.Equal(t, []string{"b"}, p.takeAcked())
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on acknowledge")
+	out.AssertText([]string{"false bac 0"})
+	require.Equal(t, []string{"a"}, p.takeAcked())
+
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("with nothing to acknowledge")
+	require.Empty(t, p.takeAcked())
+
+	p.set(false, errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{}
+	testBar.Run(New(p))
+	testBar.NextOutput("on start").AssertEmpty()
+
+	p.set(true, nil)
+	testBar.Tick()
+	testBar.NextOutput("on call").AssertText([]string{"On call"})
+
+	p.set(true, nil, Incident{ID: "a"}, Incident{ID: "b", Acknowledged: true})
+	testBar.Tick()
+	out := testBar.NextOutput("with incidents")
+	out.AssertText([]string{"On call: 2 incidents"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on acknowledge")
+	out.AssertText([]string{"On call: 2 incidents"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+	require.Equal(t, []string{"a"}, p.takeAcked())
+
+	p.set(false, nil, Incident{ID: "c", Acknowledged: true})
+	testBar.Tick()
+	testBar.NextOutput("off call").AssertText([]string{"1 incidents"})
+}
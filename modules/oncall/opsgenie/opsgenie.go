@@ -0,0 +1,165 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package opsgenie provides on-call status and open alerts from Opsgenie, using
+the REST API at https://docs.opsgenie.com/docs/api-overview.
+
+It requires an API key with read and create-and-update access, from an API
+integration or the account settings.
+*/
+package opsgenie // import "barista.run/modules/oncall/opsgenie"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/oncall"
+)
+
+// Provider provides on-call status and alerts from Opsgenie.
+type Provider struct {
+	apiKey   string
+	username string
+	server   string
+	query    string
+}
+
+// New creates a provider using the given API key, for the user with the given
+// username (usually their email address).
+func New(apiKey, username string) *Provider {
+	return &Provider{
+		apiKey:   apiKey,
+		username: username,
+		server:   "https://api.opsgenie.com",
+		query:    fmt.Sprintf(`status:open AND (owner:"%[1]s" OR recipients:"%[1]s")`, username),
+	}
+}
+
+// EU uses the API endpoint for accounts hosted in the EU region.
+func (p *Provider) EU() *Provider {
+	p.server = "https://api.eu.opsgenie.com"
+	return p
+}
+
+// Query overrides the search query used to find open alerts, which defaults to
+// open alerts owned by or sent to the user.
+// See https://support.atlassian.com/opsgenie/docs/search-queries-for-alerts.
+func (p *Provider) Query(query string) *Provider {
+	p.query = query
+	return p
+}
+
+var client = httpclient.Client()
+
+type ogAlert struct {
+	ID           string    `json:"id"`
+	Message      string    `json:"message"`
+	Acknowledged bool      `json:"acknowledged"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (p *Provider) do(method, path string, query url.Values, body interface{}, result interface{}) error {
+	u := p.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "GenieKey "+p.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// Alert actions are processed asynchronously, and return 202 Accepted.
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("opsgenie: %s", res.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// OnCall implements oncall.Provider.
+func (p *Provider) OnCall() (bool, error) {
+	var resp struct {
+		Data []struct {
+			OnCallRecipients []string `json:"onCallRecipients"`
+		} `json:"data"`
+	}
+	// Lists the current on-call participants of every schedule.
+	if err := p.do("GET", "/v2/schedules/on-calls", url.Values{"flat": {"true"}}, nil, &resp); err != nil {
+		return false, err
+	}
+	for _, s := range resp.Data {
+		for _, r := range s.OnCallRecipients {
+			if r == p.username {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Incidents implements oncall.Provider.
+func (p *Provider) Incidents() ([]oncall.Incident, error) {
+	var resp struct {
+		Data []ogAlert `json:"data"`
+	}
+	if err := p.do("GET", "/v2/alerts", url.Values{
+		"query": {p.query},
+		"sort":  {"createdAt"},
+		"order": {"desc"},
+	}, nil, &resp); err != nil {
+		return nil, err
+	}
+	incidents := []oncall.Incident{}
+	for _, a := range resp.Data {
+		incidents = append(incidents, oncall.Incident{
+			ID:           a.ID,
+			Title:        a.Message,
+			Acknowledged: a.Acknowledged,
+			Created:      a.CreatedAt,
+		})
+	}
+	return incidents, nil
+}
+
+// Acknowledge implements oncall.Provider.
+func (p *Provider) Acknowledge(i oncall.Incident) error {
+	return p.do("POST", "/v2/alerts/"+url.PathEscape(i.ID)+"/acknowledge",
+		url.Values{"identifierType": {"id"}},
+		map[string]string{"user": p.username}, nil)
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opsgenie
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/modules/oncall"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	var query, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v2/schedules/on-calls":
+			io.WriteString(w, `{"data": [
+{"_parent": {"name": "primary"}, "onCallRecipients": ["other@example.com"]},
+{"_parent": {"name": "secondary"}, "onCallRecipients": ["me@example.com"]}
+]}`)
+		case "GET /v2/alerts":
+			query = r.URL.Query().Get("query")
+			io.WriteString(w, `{"data": [
+{"id": "a1", "message": "Disk full", "acknowledged": false, "createdAt": "2018-04-01T09:00:00.123Z"}
+]}`)
+		case "POST /v2/alerts/a1/acknowledge":
+			require.Equal(t, "id", r.URL.Query().Get("identifierType"))
+			b, _ := ioutil.ReadAll(r.Body)
+			body = string(b)
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, `{"result": "Request will be processed"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := New("secret", "me@example.com")
+	require.Equal(t, "https://api.eu.opsgenie.com", New("", "").EU().server)
+	p.server = srv.URL
+
+	onCall, err := p.OnCall()
+	require.NoError(t, err)
+	require.True(t, onCall)
+
+	incidents, err := p.Incidents()
+	require.NoError(t, err)
+	require.Equal(t, []oncall.Incident{{
+		ID:      "a1",
+		Title:   "Disk full",
+		Created: time.Date(2018, 4, 1, 9, 0, 0, 123000000, time.UTC),
+	}}, incidents)
+	require.Equal(t,
+		`status:open AND (owner:"me@example.com" OR recipients:"me@example.com")`, query)
+
+	require.NoError(t, p.Acknowledge(incidents[0]))
+	require.JSONEq(t, `{"user": "me@example.com"}`, body)
+
+	p.Query("status:open")
+	_, err = p.Incidents()
+	require.NoError(t, err)
+	require.Equal(t, "status:open", query)
+
+	other := New("secret", "someone@example.com")
+	other.server = srv.URL
+	onCall, err = other.OnCall()
+	require.NoError(t, err)
+	require.False(t, onCall)
+
+	other.apiKey = "wrong"
+	_, err = other.Incidents()
+	require.Error(t, err)
+}
@@ -0,0 +1,181 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package pagerduty provides on-call status and incidents from PagerDuty, using
+the REST API at https://developer.pagerduty.com/api-reference.
+
+It requires a user API token (created in the user's profile, under User
+Settings), since account tokens are not associated with a user.
+*/
+package pagerduty // import "barista.run/modules/oncall/pagerduty"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/oncall"
+)
+
+// Provider provides on-call status and incidents from PagerDuty.
+type Provider struct {
+	token string
+
+	mu   sync.Mutex
+	user *pdUser
+}
+
+// New creates a provider using the given user API token.
+func New(token string) *Provider {
+	return &Provider{token: token}
+}
+
+var client = httpclient.Client()
+
+// baseURL can be replaced in tests.
+var baseURL = "https://api.pagerduty.com"
+
+type pdUser struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+type pdIncident struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (p *Provider) do(method, path string, query url.Values, body interface{}, result interface{}) error {
+	u := baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token token="+p.token)
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		// Updates need the email of the user making the change.
+		if p.user != nil {
+			req.Header.Set("From", p.user.Email)
+		}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("pagerduty: %s", res.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// me returns the user that owns the token, which is fetched on first use.
+func (p *Provider) me() (*pdUser, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.user != nil {
+		return p.user, nil
+	}
+	var resp struct {
+		User pdUser `json:"user"`
+	}
+	if err := p.do("GET", "/users/me", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	p.user = &resp.User
+	return p.user, nil
+}
+
+// OnCall implements oncall.Provider.
+func (p *Provider) OnCall() (bool, error) {
+	user, err := p.me()
+	if err != nil {
+		return false, err
+	}
+	var resp struct {
+		Oncalls []struct{} `json:"oncalls"`
+	}
+	// Without a time range, only current on-call entries are returned.
+	err = p.do("GET", "/oncalls", url.Values{"user_ids[]": {user.ID}}, nil, &resp)
+	return len(resp.Oncalls) > 0, err
+}
+
+// Incidents implements oncall.Provider.
+func (p *Provider) Incidents() ([]oncall.Incident, error) {
+	user, err := p.me()
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Incidents []pdIncident `json:"incidents"`
+	}
+	if err := p.do("GET", "/incidents", url.Values{
+		"user_ids[]": {user.ID},
+		"statuses[]": {"triggered", "acknowledged"},
+		"sort_by":    {"created_at:desc"},
+	}, nil, &resp); err != nil {
+		return nil, err
+	}
+	incidents := []oncall.Incident{}
+	for _, i := range resp.Incidents {
+		incidents = append(incidents, oncall.Incident{
+			ID:           i.ID,
+			Title:        i.Title,
+			URL:          i.HTMLURL,
+			Acknowledged: i.Status == "acknowledged",
+			Created:      i.CreatedAt,
+		})
+	}
+	return incidents, nil
+}
+
+// Acknowledge implements oncall.Provider.
+func (p *Provider) Acknowledge(i oncall.Incident) error {
+	if _, err := p.me(); err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"incident": map[string]string{
+			"type":   "incident_reference",
+			"status": "acknowledged",
+		},
+	}
+	return p.do("PUT", "/incidents/"+url.PathEscape(i.ID), nil, body, nil)
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pagerduty
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/modules/oncall"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	var requests []string
+	var from, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token token=secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/users/me":
+			io.WriteString(w, `{"user": {"id": "PUSER", "email": "me@example.com"}}`)
+		case "/oncalls":
+			io.WriteString(w, `{"oncalls": [{"escalation_level": 1}]}`)
+		case "/incidents":
+			io.WriteString(w, `{"incidents": [
+{"id": "P1", "title": "Disk full", "status": "triggered",
+ "html_url": "https://example.pagerduty.com/incidents/P1", "created_at": "2018-04-01T09:00:00Z"},
+{"id": "P2", "title": "High latency", "status": "acknowledged", "created_at": "2018-04-01T08:00:00Z"}
+]}`)
+		case "/incidents/P1":
+			from = r.Header.Get("From")
+			b, _ := ioutil.ReadAll(r.Body)
+			body = string(b)
+			io.WriteString(w, `{"incident": {}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	baseURL = srv.URL
+
+	p := New("secret")
+	onCall, err := p.OnCall()
+	require.NoError(t, err)
+	require.True(t, onCall)
+
+	incidents, err := p.Incidents()
+	require.NoError(t, err)
+	require.Equal(t, []oncall.Incident{
+		{
+			ID:      "P1",
+			Title:   "Disk full",
+			URL:     "https://example.pagerduty.com/incidents/P1",
+			Created: time.Date(2018, 4, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:           "P2",
+			Title:        "High latency",
+			Acknowledged: true,
+			Created:      time.Date(2018, 4, 1, 8, 0, 0, 0, time.UTC),
+		},
+	}, incidents)
+
+	require.NoError(t, p.Acknowledge(incidents[0]))
+	require.Equal(t, "me@example.com", from)
+	require.JSONEq(t,
+		`{"incident": {"type": "incident_reference", "status": "acknowledged"}}`, body)
+
+	require.Equal(t, []string{
+		"GET /users/me",
+		"GET /oncalls?user_ids%5B%5D=PUSER",
+		"GET /incidents?sort_by=created_at%3Adesc&statuses%5B%5D=triggered&statuses%5B%5D=acknowledged&user_ids%5B%5D=PUSER",
+		"PUT /incidents/P1",
+	}, requests, "user is only fetched once")
+
+	_, err = New("wrong").OnCall()
+	require.Error(t, err)
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pihole
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type piHole struct {
+	token string
+}
+
+type piHoleStatus struct {
+	Status  string `json:"status"`
+	Queries int    `json:"dns_queries_today"`
+	Blocked int    `json:"ads_blocked_today"`
+}
+
+func (p piHole) call(m *Module, params url.Values, out interface{}) error {
+	params.Set("auth", p.token)
+	res, err := client.Get(m.server + "/admin/api.php?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("pi-hole: %s", res.Status)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	// The API responds with an empty array if the token is not valid.
+	if string(bytes.TrimSpace(body)) == "[]" {
+		return errors.New("pi-hole: unauthorised")
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (p piHole) fetch(m *Module) (Info, error) {
+	var s piHoleStatus
+	params := url.Values{}
+	params.Set("summaryRaw", "")
+	if err := p.call(m, params, &s); err != nil {
+		return Info{}, err
+	}
+	return Info{Enabled: s.Status == "enabled", Queries: s.Queries, Blocked: s.Blocked}, nil
+}
+
+func (p piHole) setBlocking(m *Module, enabled bool, d time.Duration) error {
+	params := url.Values{}
+	if enabled {
+		params.Set("enable", "")
+	} else {
+		params.Set("disable", strconv.Itoa(int(d/time.Second)))
+	}
+	return p.call(m, params, &piHoleStatus{})
+}
+
+type adGuard struct {
+	username, password string
+}
+
+func (a adGuard) call(m *Module, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, m.server+"/control/"+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(a.username, a.password)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("adguard %s: %s", path, res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (a adGuard) fetch(m *Module) (Info, error) {
+	var status struct {
+		ProtectionEnabled bool `json:"protection_enabled"`
+	}
+	if err := a.call(m, "GET", "status", nil, &status); err != nil {
+		return Info{}, err
+	}
+	// Statistics cover the retention interval configured on the server,
+	// which defaults to one day.
+	var stats struct {
+		Queries int `json:"num_dns_queries"`
+		Blocked int `json:"num_blocked_filtering"`
+	}
+	if err := a.call(m, "GET", "stats", nil, &stats); err != nil {
+		return Info{}, err
+	}
+	return Info{
+		Enabled: status.ProtectionEnabled,
+		Queries: stats.Queries,
+		Blocked: stats.Blocked,
+	}, nil
+}
+
+func (a adGuard) setBlocking(m *Module, enabled bool, d time.Duration) error {
+	req := map[string]interface{}{"enabled": enabled}
+	if !enabled {
+		// Duration is in milliseconds.
+		req["duration"] = int64(d / time.Millisecond)
+	}
+	return a.call(m, "POST", "protection", req, nil)
+}
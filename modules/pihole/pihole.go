@@ -0,0 +1,186 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package pihole provides an i3bar module that shows the blocking status and
+the percentage of queries blocked today by a Pi-hole or AdGuard Home server.
+
+Clicking the default output disables blocking for a few minutes, or re-enables
+it if blocking is currently disabled.
+*/
+package pihole // import "barista.run/modules/pihole"
+
+import (
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/httpclient"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info represents the current blocking status and statistics.
+type Info struct {
+	// Enabled is true if blocking is enabled.
+	Enabled bool
+	// Queries is the number of DNS queries today.
+	Queries int
+	// Blocked is the number of DNS queries blocked today.
+	Blocked int
+	m       *Module
+}
+
+// BlockedPercent returns the percentage of today's queries that were blocked.
+func (i Info) BlockedPercent() float64 {
+	if i.Queries == 0 {
+		return 0
+	}
+	return float64(i.Blocked) * 100 / float64(i.Queries)
+}
+
+// Disable disables blocking for the duration set by DisableFor.
+func (i Info) Disable() {
+	i.setBlocking(false)
+}
+
+// Enable re-enables blocking.
+func (i Info) Enable() {
+	i.setBlocking(true)
+}
+
+// Toggle disables blocking if it is enabled, and enables it otherwise.
+func (i Info) Toggle() {
+	i.setBlocking(!i.Enabled)
+}
+
+func (i Info) setBlocking(enabled bool) {
+	if i.m == nil {
+		return
+	}
+	d := i.m.disableFor.Get().(time.Duration)
+	if err := i.m.backend.setBlocking(i.m, enabled, d); err != nil {
+		l.Log("Failed to set blocking to %v: %v", enabled, err)
+	}
+	i.m.refreshFn()
+}
+
+// backend is the API of a specific ad-blocking DNS server.
+type backend interface {
+	fetch(m *Module) (Info, error)
+	setBlocking(m *Module, enabled bool, d time.Duration) error
+}
+
+// Module represents a Pi-hole bar module.
+type Module struct {
+	server     string
+	backend    backend
+	disableFor value.Value // of time.Duration
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+}
+
+func newModule(server string, b backend) *Module {
+	m := &Module{
+		server:    strings.TrimSuffix(server, "/"),
+		backend:   b,
+		scheduler: timing.NewScheduler().Named("pihole"),
+	}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "disableFor", "scheduler")
+	m.DisableFor(5 * time.Minute)
+	// Default output is the percentage of blocked queries, or a warning if
+	// blocking is disabled. Clicking toggles blocking.
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Textf("%.1f%% blocked", i.BlockedPercent())
+		if !i.Enabled {
+			out = outputs.Text("blocking off").Color(colors.Scheme("degraded"))
+		}
+		return out.OnClick(click.Left(i.Toggle))
+	})
+	m.RefreshInterval(time.Minute)
+	return m
+}
+
+// New creates a module for the Pi-hole at the given URL, e.g.
+// "http://pi.hole", using the API token from the Pi-hole settings page.
+func New(server, token string) *Module {
+	return newModule(server, piHole{token})
+}
+
+// AdGuardHome creates a module for the AdGuard Home server at the given URL,
+// using the given credentials for the web interface.
+func AdGuardHome(server, username, password string) *Module {
+	return newModule(server, adGuard{username, password})
+}
+
+// DisableFor sets how long blocking is disabled for when clicked.
+func (m *Module) DisableFor(d time.Duration) *Module {
+	m.disableFor.Set(d)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+var client = httpclient.Client()
+
+func (m *Module) fetch() (Info, error) {
+	i, err := m.backend.fetch(m)
+	i.m = m
+	return i, err
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.fetch()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.refreshCh:
+			info, err = m.fetch()
+		case <-m.scheduler.C:
+			info, err = m.fetch()
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pihole
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakePiHole struct {
+	sync.Mutex
+	enabled  bool
+	disabled []string
+	queries  int
+	blocked  int
+}
+
+func (f *fakePiHole) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	q := r.URL.Query()
+	if r.URL.Path != "/admin/api.php" || q.Get("auth") != "token" {
+		io.WriteString(w, "[]")
+		return
+	}
+	if _, ok := q["enable"]; ok {
+		f.enabled = true
+	}
+	if d, ok := q["disable"]; ok {
+		f.enabled = false
+		f.disabled = append(f.disabled, d[0])
+	}
+	status := "disabled"
+	if f.enabled {
+		status = "enabled"
+	}
+	fmt.Fprintf(w, `{"status": %q, "dns_queries_today": %d, "ads_blocked_today": %d,
+		"ads_percentage_today": 0, "domains_being_blocked": 123456}`,
+		status, f.queries, f.blocked)
+}
+
+func (f *fakePiHole) disables() []string {
+	f.Lock()
+	defer f.Unlock()
+	return append([]string(nil), f.disabled...)
+}
+
+func TestPiHole(t *testing.T) {
+	testBar.New(t)
+	f := &fakePiHole{enabled: true, queries: 2000, blocked: 250}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	p := New(srv.URL+"/", "token")
+	testBar.Run(p)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"12.5% blocked"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"blocking off"})
+	require.Equal(t, []string{"300"}, f.disables())
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{"12.5% blocked"})
+	require.Equal(t, []string{"300"}, f.disables(), "enable on click when disabled")
+
+	p.DisableFor(time.Hour)
+	p.Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %d/%d", i.Enabled, i.Blocked, i.Queries).
+			OnClick(func(bar.Event) { i.Disable() })
+	})
+	out = testBar.NextOutput("on output change")
+	out.AssertText([]string{"true 250/2000"})
+	out.At(0).LeftClick()
+	testBar.NextOutput("on disable").AssertText([]string{"false 250/2000"})
+	require.Equal(t, []string{"300", "3600"}, f.disables())
+
+	f.Lock()
+	f.queries = 0
+	f.Unlock()
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"false 250/0"})
+}
+
+func TestPiHoleErrors(t *testing.T) {
+	testBar.New(t)
+	srv := httptest.NewServer(&fakePiHole{})
+	defer srv.Close()
+
+	testBar.Run(New(srv.URL, "wrong"))
+	errs := testBar.NextOutput("with wrong token").AssertError()
+	require.Contains(t, errs[0], "unauthorised")
+
+	srv.Close()
+	testBar.New(t)
+	testBar.Run(New(srv.URL, "token"))
+	testBar.NextOutput("with server down").AssertError()
+}
+
+func TestAdGuardHome(t *testing.T) {
+	testBar.New(t)
+	var mu sync.Mutex
+	enabled := false
+	var requests []map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/control/status", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, `{"running": true, "protection_enabled": %v}`, enabled)
+	})
+	mux.HandleFunc("/control/stats", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"num_dns_queries": 400, "num_blocked_filtering": 100}`)
+	})
+	mux.HandleFunc("/control/protection", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var req map[string]interface{}
+		if r.Method != "POST" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, req)
+		enabled = req["enabled"].(bool)
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "admin" || p != "hunter2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	testBar.Run(AdGuardHome(srv.URL, "admin", "hunter2").DisableFor(time.Minute))
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"blocking off"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on enable")
+	out.AssertText([]string{"25.0% blocked"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on disable").AssertText([]string{"blocking off"})
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []map[string]interface{}{
+		{"enabled": true},
+		{"enabled": false, "duration": 60000.0},
+	}, requests)
+
+	testBar.New(t)
+	testBar.Run(AdGuardHome(srv.URL, "admin", "wrong"))
+	errs := testBar.NextOutput("with wrong password").AssertError()
+	require.Contains(t, errs[0], "401")
+}
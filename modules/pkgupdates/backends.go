@@ -0,0 +1,219 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgupdates
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// command runs a command and returns its stdout and exit code. A non-nil
+// error is only returned if the command could not be run at all.
+// It can be replaced in tests.
+var command = func(name string, args ...string) (string, int, error) {
+	out, err := exec.Command(name, args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(out), exitErr.Sys().(syscall.WaitStatus).ExitStatus(), nil
+	}
+	return string(out), 0, err
+}
+
+// run runs a command, treating any exit code in okCodes (in addition to 0)
+// as successful.
+func run(okCodes []int, name string, args ...string) (string, error) {
+	out, code, err := command(name, args...)
+	if err != nil {
+		return "", err
+	}
+	if code == 0 {
+		return out, nil
+	}
+	for _, c := range okCodes {
+		if code == c {
+			return out, nil
+		}
+	}
+	return "", fmt.Errorf("%s: exit status %d", name, code)
+}
+
+func lines(out string) []string {
+	var ls []string
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			ls = append(ls, line)
+		}
+	}
+	return ls
+}
+
+type backendFunc func() (Info, error)
+
+func (b backendFunc) Updates() (Info, error) {
+	return b()
+}
+
+// Pacman returns a backend that uses checkupdates (from pacman-contrib),
+// which checks for updates using a separate copy of the sync database.
+func Pacman() Backend {
+	return backendFunc(func() (Info, error) {
+		// checkupdates exits with status 2 if there are no updates.
+		out, err := run([]int{2}, "checkupdates")
+		if err != nil {
+			return nil, err
+		}
+		var info Info
+		for _, line := range lines(out) {
+			// pkg oldver -> newver
+			f := strings.Fields(line)
+			if len(f) < 4 {
+				continue
+			}
+			info = append(info, Package{Name: f[0], Version: f[1], NewVersion: f[3]})
+		}
+		return info, nil
+	})
+}
+
+// Apt returns a backend that uses apt's list of upgradable packages. It does
+// not update the package lists, which is usually done periodically by the
+// system. Updates from a -security suite are marked as security updates.
+func Apt() Backend {
+	return backendFunc(func() (Info, error) {
+		out, err := run(nil, "apt", "list", "--upgradable")
+		if err != nil {
+			return nil, err
+		}
+		var info Info
+		for _, line := range lines(out) {
+			// pkg/suite1,suite2 newver arch [upgradable from: oldver]
+			f := strings.Fields(line)
+			slash := strings.Index(line, "/")
+			if len(f) < 3 || slash < 0 {
+				// e.g. "Listing..."
+				continue
+			}
+			p := Package{Name: line[:slash], NewVersion: f[1]}
+			suites := strings.TrimPrefix(f[0], p.Name+"/")
+			for _, suite := range strings.Split(suites, ",") {
+				if strings.HasSuffix(suite, "-security") {
+					p.Security = true
+				}
+			}
+			if idx := strings.Index(line, "upgradable from: "); idx >= 0 {
+				p.Version = strings.TrimSuffix(line[idx+len("upgradable from: "):], "]")
+			}
+			info = append(info, p)
+		}
+		return info, nil
+	})
+}
+
+// Dnf returns a backend that uses dnf check-update, and marks packages with a
+// security advisory as security updates.
+func Dnf() Backend {
+	return backendFunc(func() (Info, error) {
+		// check-update exits with status 100 if there are updates.
+		out, err := run([]int{100}, "dnf", "-q", "check-update")
+		if err != nil {
+			return nil, err
+		}
+		secOut, err := run(nil, "dnf", "-q", "updateinfo", "list", "--security")
+		if err != nil {
+			return nil, err
+		}
+		// advisory type nevra
+		var secNevras []string
+		for _, line := range lines(secOut) {
+			if f := strings.Fields(line); len(f) >= 3 {
+				secNevras = append(secNevras, f[2])
+			}
+		}
+		var info Info
+		for _, line := range lines(out) {
+			// name.arch version repo
+			f := strings.Fields(line)
+			if len(f) != 3 {
+				// e.g. "Obsoleting Packages" or wrapped lines.
+				continue
+			}
+			dot := strings.LastIndex(f[0], ".")
+			if dot < 0 {
+				continue
+			}
+			p := Package{Name: f[0][:dot], NewVersion: f[1]}
+			for _, nevra := range secNevras {
+				if strings.HasPrefix(nevra, p.Name+"-") {
+					p.Security = true
+					break
+				}
+			}
+			info = append(info, p)
+		}
+		return info, nil
+	})
+}
+
+// Zypper returns a backend that uses zypper list-updates.
+func Zypper() Backend {
+	return backendFunc(func() (Info, error) {
+		out, err := run(nil, "zypper", "--non-interactive", "--quiet", "list-updates")
+		if err != nil {
+			return nil, err
+		}
+		var info Info
+		for _, line := range lines(out) {
+			// S | Repository | Name | Current Version | Available Version | Arch
+			f := strings.Split(line, "|")
+			if len(f) < 6 {
+				continue
+			}
+			for idx := range f {
+				f[idx] = strings.TrimSpace(f[idx])
+			}
+			if f[0] != "v" {
+				// Header or separator.
+				continue
+			}
+			info = append(info, Package{Name: f[2], Version: f[3], NewVersion: f[4]})
+		}
+		return info, nil
+	})
+}
+
+// Flatpak returns a backend that lists flatpak applications and runtimes with
+// pending updates.
+func Flatpak() Backend {
+	return backendFunc(func() (Info, error) {
+		out, err := run(nil, "flatpak", "remote-ls", "--updates",
+			"--columns=application,version")
+		if err != nil {
+			return nil, err
+		}
+		var info Info
+		for _, line := range lines(out) {
+			f := strings.Split(line, "\t")
+			p := Package{Name: strings.TrimSpace(f[0])}
+			if len(f) > 1 {
+				p.NewVersion = strings.TrimSpace(f[1])
+			}
+			info = append(info, p)
+		}
+		return info, nil
+	})
+}
@@ -0,0 +1,167 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package pkgupdates provides an i3bar module that shows the number of pending
+package updates.
+
+The package manager is pluggable, with backends provided for pacman, apt, dnf,
+zypper, and flatpak. Backends that can identify security updates (apt and dnf)
+mark them as such, allowing them to be displayed differently.
+*/
+package pkgupdates // import "barista.run/modules/pkgupdates"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Package represents a single pending package update.
+type Package struct {
+	Name string
+	// Version is the currently installed version, if known.
+	Version string
+	// NewVersion is the version that will be installed by the update.
+	NewVersion string
+	// Security is true if the update is known to fix a security issue.
+	Security bool
+}
+
+// Info represents the pending updates.
+type Info []Package
+
+// Count returns the number of pending updates.
+func (i Info) Count() int {
+	return len(i)
+}
+
+// Security returns the number of pending updates that fix security issues.
+func (i Info) Security() int {
+	c := 0
+	for _, p := range i {
+		if p.Security {
+			c++
+		}
+	}
+	return c
+}
+
+// Backend is an interface for package managers.
+type Backend interface {
+	// Updates returns the list of packages with pending updates.
+	Updates() (Info, error)
+}
+
+type combined []Backend
+
+func (c combined) Updates() (Info, error) {
+	var info Info
+	for _, b := range c {
+		i, err := b.Updates()
+		if err != nil {
+			return nil, err
+		}
+		info = append(info, i...)
+	}
+	return info, nil
+}
+
+// Combined returns a backend that reports the updates from all of the given
+// backends, for example a system package manager and flatpak.
+func Combined(backends ...Backend) Backend {
+	return combined(backends)
+}
+
+// Module represents a bar.Module that displays pending package updates.
+type Module struct {
+	backend    Backend
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs an instance of the updates module using the given backend.
+func New(backend Backend) *Module {
+	m := &Module{
+		backend:   backend,
+		scheduler: timing.NewScheduler(),
+	}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the number of updates (if any), marked urgent if
+	// there are any security updates.
+	m.Output(func(i Info) bar.Output {
+		switch {
+		case i.Count() == 0:
+			return nil
+		case i.Security() > 0:
+			return outputs.Textf("%d updates (%d security)", i.Count(), i.Security()).
+				Urgent(true)
+		default:
+			return outputs.Textf("%d updates", i.Count())
+		}
+	})
+	// Checking for updates is slow and can be taxing on mirrors, so only
+	// check infrequently by default.
+	m.RefreshInterval(time.Hour)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Refresh checks for pending updates, e.g. after installing updates.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.backend.Updates()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.backend.Updates()
+		case <-m.refreshCh:
+			if err != nil {
+				s(nil)
+			}
+			info, err = m.backend.Updates()
+		}
+	}
+}
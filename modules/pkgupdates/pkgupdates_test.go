@@ -0,0 +1,207 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgupdates
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type result struct {
+	out  string
+	code int
+	err  error
+}
+
+var (
+	results   = map[string]result{}
+	resultsMu sync.Mutex
+)
+
+func respond(cmd string, out string, code int) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	results[cmd] = result{out: out, code: code}
+}
+
+func init() {
+	command = func(name string, args ...string) (string, int, error) {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		r, ok := results[cmd]
+		if !ok {
+			return "", 0, errors.New("command not found: " + cmd)
+		}
+		return r.out, r.code, r.err
+	}
+}
+
+func TestPacman(t *testing.T) {
+	respond("checkupdates", "", 2)
+	info, err := Pacman().Updates()
+	require.NoError(t, err)
+	require.Empty(t, info, "exit status 2 means no updates")
+
+	respond("checkupdates", `linux 6.1.1.arch1-1 -> 6.1.2.arch1-1
+go 2:1.19.4-1 -> 2:1.19.5-1
+`, 0)
+	info, err = Pacman().Updates()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		{Name: "linux", Version: "6.1.1.arch1-1", NewVersion: "6.1.2.arch1-1"},
+		{Name: "go", Version: "2:1.19.4-1", NewVersion: "2:1.19.5-1"},
+	}, info)
+	require.Equal(t, 0, info.Security())
+
+	respond("checkupdates", "", 1)
+	_, err = Pacman().Updates()
+	require.Error(t, err, "other exit codes")
+}
+
+func TestApt(t *testing.T) {
+	respond("apt list --upgradable", `Listing...
+libssl3/jammy-updates,jammy-security 3.0.2-0ubuntu1.8 amd64 [upgradable from: 3.0.2-0ubuntu1.7]
+vim/jammy-updates 2:8.2.3995-1ubuntu2.3 amd64 [upgradable from: 2:8.2.3995-1ubuntu2.2]
+`, 0)
+	info, err := Apt().Updates()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		{Name: "libssl3", Version: "3.0.2-0ubuntu1.7",
+			NewVersion: "3.0.2-0ubuntu1.8", Security: true},
+		{Name: "vim", Version: "2:8.2.3995-1ubuntu2.2",
+			NewVersion: "2:8.2.3995-1ubuntu2.3"},
+	}, info)
+	require.Equal(t, 2, info.Count())
+	require.Equal(t, 1, info.Security())
+}
+
+func TestDnf(t *testing.T) {
+	respond("dnf -q check-update", `
+openssl-libs.x86_64    1:3.0.9-2.fc38    updates
+kernel.x86_64          6.5.5-200.fc38    updates
+Obsoleting Packages
+`, 100)
+	respond("dnf -q updateinfo list --security",
+		"FEDORA-2023-abc  Moderate/Sec.  openssl-libs-1:3.0.9-2.fc38.x86_64\n", 0)
+	info, err := Dnf().Updates()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		{Name: "openssl-libs", NewVersion: "1:3.0.9-2.fc38", Security: true},
+		{Name: "kernel", NewVersion: "6.5.5-200.fc38"},
+	}, info)
+
+	respond("dnf -q updateinfo list --security", "", 1)
+	_, err = Dnf().Updates()
+	require.Error(t, err, "error in security check")
+}
+
+func TestZypper(t *testing.T) {
+	respond("zypper --non-interactive --quiet list-updates", `
+S | Repository | Name  | Current Version | Available Version | Arch
+--+------------+-------+-----------------+-------------------+-------
+v | Update     | curl  | 8.0.1-1.1       | 8.0.1-1.2         | x86_64
+v | Update     | bash  | 5.2.15-1.1      | 5.2.15-1.3        | x86_64
+`, 0)
+	info, err := Zypper().Updates()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		{Name: "curl", Version: "8.0.1-1.1", NewVersion: "8.0.1-1.2"},
+		{Name: "bash", Version: "5.2.15-1.1", NewVersion: "5.2.15-1.3"},
+	}, info)
+}
+
+func TestFlatpak(t *testing.T) {
+	respond("flatpak remote-ls --updates --columns=application,version",
+		"org.mozilla.firefox\t118.0\norg.freedesktop.Platform\n", 0)
+	info, err := Flatpak().Updates()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		{Name: "org.mozilla.firefox", NewVersion: "118.0"},
+		{Name: "org.freedesktop.Platform"},
+	}, info)
+
+	respond("checkupdates", "linux 1 -> 2\n", 0)
+	info, err = Combined(Pacman(), Flatpak()).Updates()
+	require.NoError(t, err)
+	require.Equal(t, 3, info.Count())
+
+	_, err = Combined(Flatpak(), Zypper(), backendFunc(func() (Info, error) {
+		return nil, errors.New("foo")
+	})).Updates()
+	require.Error(t, err, "any backend error")
+}
+
+type testBackend struct {
+	sync.Mutex
+	info Info
+	err  error
+}
+
+func (t *testBackend) Updates() (Info, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.info, t.err
+}
+
+func (t *testBackend) set(info Info, err error) {
+	t.Lock()
+	defer t.Unlock()
+	t.info, t.err = info, err
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	b := &testBackend{}
+	m := New(b)
+	testBar.Run(m)
+
+	testBar.NextOutput().AssertEmpty("no updates")
+
+	b.set(Info{{Name: "a"}, {Name: "b"}}, nil)
+	testBar.Tick()
+	out := testBar.NextOutput("on tick")
+	out.AssertText([]string{"2 updates"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	b.set(Info{{Name: "a", Security: true}, {Name: "b"}}, nil)
+	m.Refresh()
+	out = testBar.NextOutput("on refresh")
+	out.AssertText([]string{"2 updates (1 security)"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "security updates are urgent")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d", i.Security(), i.Count())
+	})
+	testBar.NextOutput().AssertText([]string{"1/2"}, "on output change")
+
+	b.set(nil, errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput().AssertError("on error")
+
+	b.set(nil, nil)
+	m.Refresh()
+	testBar.NextOutput().AssertText([]string{"0/0"}, "on refresh after error")
+}
@@ -0,0 +1,390 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package pomodoro provides an i3bar module for a pomodoro timer, which
+alternates between work sessions and breaks, with a longer break after every
+few work sessions.
+
+A desktop notification (using notify-send) is shown at the end of each work
+session or break. The timer state is saved using barista.run/state, so that a
+running timer continues across bar restarts.
+*/
+package pomodoro // import "barista.run/modules/pomodoro"
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/i18n"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	persist "barista.run/state"
+	"barista.run/timing"
+)
+
+// Phase represents the current phase of the pomodoro cycle.
+type Phase int
+
+// Possible phases of the cycle.
+const (
+	Work Phase = iota
+	ShortBreak
+	LongBreak
+)
+
+func (p Phase) String() string {
+	switch p {
+	case ShortBreak:
+		return i18n.Sprintf("Short break")
+	case LongBreak:
+		return i18n.Sprintf("Long break")
+	default:
+		return i18n.Sprintf("Work")
+	}
+}
+
+// IsBreak returns true for short and long breaks.
+func (p Phase) IsBreak() bool {
+	return p != Work
+}
+
+// Info represents the current state of the pomodoro timer.
+type Info struct {
+	Phase Phase
+	// Remaining is the time left in the current phase, rounded up to the
+	// next second.
+	Remaining time.Duration
+	Running   bool
+	// Completed is the number of work sessions completed since the last
+	// reset.
+	Completed int
+	m         *Module
+}
+
+// Start starts (or resumes) the timer.
+func (i Info) Start() {
+	if i.m != nil {
+		i.m.update(func(s *state) { s.start() })
+	}
+}
+
+// Pause pauses the timer.
+func (i Info) Pause() {
+	if i.m != nil {
+		i.m.update(func(s *state) { s.pause() })
+	}
+}
+
+// Toggle pauses a running timer, and starts a paused one.
+func (i Info) Toggle() {
+	if i.Running {
+		i.Pause()
+	} else {
+		i.Start()
+	}
+}
+
+// Skip moves to the next phase without waiting for the current one to end.
+func (i Info) Skip() {
+	if i.m != nil {
+		i.m.update(func(s *state) { i.m.advance(s, false) })
+	}
+}
+
+// Reset stops the timer and starts over with a work session.
+func (i Info) Reset() {
+	if i.m != nil {
+		i.m.update(func(s *state) {
+			*s = state{Phase: Work, Remaining: i.m.durations[Work]}
+		})
+	}
+}
+
+// state is the persisted state of the timer.
+type state struct {
+	Phase   Phase
+	Running bool
+	// End is when the current phase ends, only set while running.
+	End time.Time
+	// Remaining is the time left in the current phase, only set while paused.
+	Remaining time.Duration
+	Completed int
+}
+
+func (s *state) start() {
+	if !s.Running {
+		s.Running = true
+		s.End = timing.Now().Add(s.Remaining)
+		s.Remaining = 0
+	}
+}
+
+func (s *state) pause() {
+	if s.Running {
+		s.Running = false
+		s.Remaining = s.End.Sub(timing.Now())
+		s.End = time.Time{}
+	}
+}
+
+// Module represents a pomodoro timer bar module.
+type Module struct {
+	mu             sync.Mutex
+	state          state
+	loaded         bool
+	durations      [3]time.Duration
+	longBreakEvery int
+	autoStart      bool
+	stateKey       string
+
+	ticker     *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	updateFn   func()
+	updateCh   <-chan struct{}
+}
+
+// New creates a pomodoro module with the usual durations: 25 minute work
+// sessions, 5 minute short breaks, and a 15 minute long break after every
+// four work sessions.
+func New() *Module {
+	m := &Module{
+		durations:      [3]time.Duration{25 * time.Minute, 5 * time.Minute, 15 * time.Minute},
+		longBreakEvery: 4,
+		stateKey:       "timer",
+		ticker:         timing.NewScheduler(),
+	}
+	m.state.Remaining = m.durations[Work]
+	m.updateFn, m.updateCh = notifier.New()
+	l.Register(m, "outputFunc", "ticker")
+	// Default output is the phase and the remaining time. Left click starts
+	// or pauses the timer, right click skips to the next phase, and middle
+	// click resets the timer.
+	m.Output(func(i Info) bar.Output {
+		paused := ""
+		if !i.Running {
+			paused = " " + i18n.Sprintf("(paused)")
+		}
+		return outputs.Textf("%s %s%s", i.Phase, Format(i.Remaining), paused).OnClick(click.Map{}.
+			Left(i.Toggle).
+			Right(i.Skip).
+			Middle(i.Reset).
+			Handle)
+	})
+	return m
+}
+
+// Format formats a duration as minutes and seconds, e.g. "24:59".
+func Format(d time.Duration) string {
+	secs := int(d / time.Second)
+	return fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+}
+
+// Durations sets the duration of work sessions, short breaks, and long
+// breaks. Changes apply from the next phase, unless the timer has not been
+// started yet.
+func (m *Module) Durations(work, shortBreak, longBreak time.Duration) *Module {
+	m.update(func(s *state) {
+		fresh := !s.Running && s.Remaining == m.durations[s.Phase]
+		m.durations = [3]time.Duration{work, shortBreak, longBreak}
+		if fresh {
+			s.Remaining = m.durations[s.Phase]
+		}
+	})
+	return m
+}
+
+// LongBreakEvery sets the number of work sessions before a long break.
+func (m *Module) LongBreakEvery(sessions int) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.longBreakEvery = sessions
+	return m
+}
+
+// AutoStart automatically starts the next phase when the current one ends,
+// instead of waiting for it to be started manually.
+func (m *Module) AutoStart(autoStart bool) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.autoStart = autoStart
+	return m
+}
+
+// StateKey sets the key that the timer state is saved under, e.g. to keep the
+// state of multiple timers separate. An empty key disables persistence.
+func (m *Module) StateKey(key string) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stateKey = key
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// store keeps the timer state, under $XDG_STATE_HOME/barista/pomodoro.
+var store = persist.New("pomodoro")
+
+// notify can be replaced in tests.
+var notify = func(summary, body string) error {
+	return exec.Command("notify-send", summary, body).Run()
+}
+
+// update applies a change to the timer state, saves it, and updates the
+// output if the module has been started.
+func (m *Module) update(fn func(*state)) {
+	m.mu.Lock()
+	fn(&m.state)
+	m.save()
+	started := m.loaded
+	m.mu.Unlock()
+	if started {
+		m.updateFn()
+	}
+}
+
+// advance moves to the next phase. If the current phase was completed (as
+// opposed to skipped), a completed work session is counted.
+func (m *Module) advance(s *state, completed bool) {
+	next := Work
+	if s.Phase == Work {
+		if completed {
+			s.Completed++
+		}
+		next = ShortBreak
+		if m.longBreakEvery > 0 && completed && s.Completed%m.longBreakEvery == 0 {
+			next = LongBreak
+		}
+	}
+	*s = state{Phase: next, Remaining: m.durations[next], Completed: s.Completed}
+	if completed && m.autoStart {
+		s.start()
+	}
+}
+
+// checkEnd advances to the next phase if the current one has ended, and
+// returns the ended phase.
+func (m *Module) checkEnd(s *state) (Phase, bool) {
+	if !s.Running || timing.Now().Before(s.End) {
+		return 0, false
+	}
+	ended := s.Phase
+	m.advance(s, true)
+	m.save()
+	return ended, true
+}
+
+func (m *Module) save() {
+	if m.stateKey == "" || !m.loaded {
+		return
+	}
+	if err := store.Save(m.stateKey, m.state); err != nil {
+		l.Log("Failed to save pomodoro state: %v", err)
+	}
+}
+
+func (m *Module) load() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.loaded {
+		return
+	}
+	m.loaded = true
+	s := state{}
+	if m.stateKey == "" || !store.Load(m.stateKey, &s) {
+		return
+	}
+	// A phase that ended while the bar was not running has already been
+	// missed, so just move on without a notification.
+	for s.Running && !timing.Now().Before(s.End) {
+		m.advance(&s, true)
+		if !m.autoStart {
+			break
+		}
+	}
+	m.state = s
+}
+
+func notifyEnd(ended Phase, next Phase) {
+	title := "Time for a break"
+	if ended.IsBreak() {
+		title = "Back to work"
+	}
+	if err := notify("Pomodoro", fmt.Sprintf("%s, %s is next", title, next)); err != nil {
+		l.Log("Failed to send notification: %v", err)
+	}
+}
+
+func (m *Module) info() Info {
+	i := Info{
+		Phase:     m.state.Phase,
+		Running:   m.state.Running,
+		Completed: m.state.Completed,
+		Remaining: m.state.Remaining,
+		m:         m,
+	}
+	if i.Running {
+		i.Remaining = m.state.End.Sub(timing.Now())
+	}
+	// Round up, so that the timer shows 25:00 at the start, and 00:00 only
+	// when the phase has ended.
+	if rem := i.Remaining % time.Second; rem > 0 {
+		i.Remaining += time.Second - rem
+	}
+	return i
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	m.load()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	defer m.ticker.Stop()
+	wasRunning := false
+	for {
+		m.mu.Lock()
+		ended, hasEnded := m.checkEnd(&m.state)
+		info := m.info()
+		m.mu.Unlock()
+		if hasEnded {
+			notifyEnd(ended, info.Phase)
+		}
+		if info.Running != wasRunning {
+			if info.Running {
+				m.ticker.Every(time.Second)
+			} else {
+				m.ticker.Stop()
+			}
+			wasRunning = info.Running
+		}
+		s.Output(outputFunc(info))
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.ticker.C:
+		case <-m.updateCh:
+		}
+	}
+}
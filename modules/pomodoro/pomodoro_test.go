@@ -0,0 +1,219 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pomodoro
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	notifications   []string
+	notificationsMu sync.Mutex
+)
+
+func init() {
+	notify = func(summary, body string) error {
+		notificationsMu.Lock()
+		defer notificationsMu.Unlock()
+		notifications = append(notifications, summary+": "+body)
+		return nil
+	}
+}
+
+func takeNotifications() []string {
+	notificationsMu.Lock()
+	defer notificationsMu.Unlock()
+	n := notifications
+	notifications = nil
+	return n
+}
+
+// tempState stores state in a temporary directory, and returns a function that
+// restores the original state directory.
+func tempState(t *testing.T) func() {
+	tmpDir, err := ioutil.TempDir("", "pomodoro")
+	require.NoError(t, err)
+	oldState, hadState := os.LookupEnv("XDG_STATE_HOME")
+	os.Setenv("XDG_STATE_HOME", tmpDir)
+	return func() {
+		if hadState {
+			os.Setenv("XDG_STATE_HOME", oldState)
+		} else {
+			os.Unsetenv("XDG_STATE_HOME")
+		}
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func testOutput(i Info) bar.Output {
+	return outputs.Textf("%s %s %v %d", i.Phase, Format(i.Remaining), i.Running, i.Completed).
+		OnClick(click.Map{}.Left(i.Toggle).Right(i.Skip).Middle(i.Reset).Handle)
+}
+
+func readState(t *testing.T) state {
+	s := state{}
+	require.True(t, store.Load("timer", &s), "state is saved")
+	return s
+}
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, "25:00", Format(25*time.Minute))
+	require.Equal(t, "04:09", Format(4*time.Minute+9*time.Second))
+	require.Equal(t, "90:00", Format(90*time.Minute))
+	require.Equal(t, "Long break", LongBreak.String())
+	require.True(t, ShortBreak.IsBreak())
+	require.False(t, Work.IsBreak())
+}
+
+func TestPomodoro(t *testing.T) {
+	defer tempState(t)()
+	takeNotifications()
+	testBar.New(t)
+	m := New().
+		Durations(3*time.Second, time.Second, 2*time.Second).
+		LongBreakEvery(2).
+		Output(testOutput)
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"Work 00:03 false 0"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on start click").AssertText([]string{"Work 00:03 true 0"})
+	require.True(t, readState(t).Running, "state is saved")
+
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"Work 00:02 true 0"})
+	testBar.Tick()
+	out = testBar.NextOutput("on tick")
+	out.AssertText([]string{"Work 00:01 true 0"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on pause")
+	out.AssertText([]string{"Work 00:01 false 0"})
+	require.Equal(t, time.Second, readState(t).Remaining)
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on resume").AssertText([]string{"Work 00:01 true 0"})
+	testBar.Tick()
+	out = testBar.NextOutput("on work end")
+	out.AssertText([]string{"Short break 00:01 false 1"})
+	require.Equal(t, []string{"Pomodoro: Time for a break, Short break is next"},
+		takeNotifications())
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on start").AssertText([]string{"Short break 00:01 true 1"})
+	testBar.Tick()
+	out = testBar.NextOutput("on break end")
+	out.AssertText([]string{"Work 00:03 false 1"})
+	require.Equal(t, []string{"Pomodoro: Back to work, Work is next"},
+		takeNotifications())
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on start").Expect("on start")
+	for i := 0; i < 2; i++ {
+		testBar.Tick()
+		testBar.NextOutput("on tick").Expect("on tick")
+	}
+	testBar.Tick()
+	out = testBar.NextOutput("on work end")
+	out.AssertText([]string{"Long break 00:02 false 2"})
+	require.Equal(t, []string{"Pomodoro: Time for a break, Long break is next"},
+		takeNotifications())
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	out = testBar.NextOutput("on skip")
+	out.AssertText([]string{"Work 00:03 false 2"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	out = testBar.NextOutput("on skip")
+	out.AssertText([]string{"Short break 00:01 false 2"}, "skipped work is not counted")
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	testBar.NextOutput("on reset").AssertText([]string{"Work 00:03 false 0"})
+	require.Equal(t, state{Phase: Work, Remaining: 3 * time.Second}, readState(t))
+	require.Empty(t, takeNotifications())
+}
+
+func TestPersistence(t *testing.T) {
+	defer tempState(t)()
+	takeNotifications()
+	testBar.New(t)
+
+	require.NoError(t, store.Save("timer", state{
+		Phase:     Work,
+		Running:   true,
+		End:       timing.Now().Add(90 * time.Second),
+		Completed: 3,
+	}))
+	testBar.Run(New().Output(testOutput))
+	testBar.NextOutput("on start").AssertText([]string{"Work 01:30 true 3"},
+		"running timer is restored")
+
+	require.NoError(t, store.Save("other", state{
+		Phase:   ShortBreak,
+		Running: true,
+		End:     timing.Now().Add(-time.Hour),
+	}))
+	testBar.New(t)
+	testBar.Run(New().StateKey("other").Output(testOutput))
+	testBar.NextOutput("on start").AssertText([]string{"Work 25:00 false 0"},
+		"ended phase is skipped")
+	require.Empty(t, takeNotifications())
+
+	require.NoError(t, ioutil.WriteFile(store.Path("timer"), []byte("not json"), 0600))
+	testBar.New(t)
+	testBar.Run(New().Output(testOutput))
+	testBar.NextOutput("on start").AssertText([]string{"Work 25:00 false 0"},
+		"invalid state is ignored")
+
+	testBar.New(t)
+	testBar.Run(New().StateKey("").Output(testOutput))
+	out := testBar.NextOutput("on start")
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{"Work 25:00 true 0"})
+	data, _ := ioutil.ReadFile(store.Path("timer"))
+	require.Equal(t, "not json", string(data), "state is not saved")
+}
+
+func TestDefaultOutput(t *testing.T) {
+	defer tempState(t)()
+	takeNotifications()
+	testBar.New(t)
+	m := New().Durations(2*time.Second, time.Second, time.Minute).AutoStart(true)
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"Work 00:02 (paused)"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{"Work 00:02"})
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"Work 00:01"})
+	testBar.Tick()
+	testBar.NextOutput("on work end").AssertText([]string{"Short break 00:01"},
+		"next phase is started automatically")
+	require.Len(t, takeNotifications(), 1)
+}
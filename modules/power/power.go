@@ -0,0 +1,360 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package power provides an i3bar module with buttons to lock the screen,
+suspend, hibernate, reboot, or power off the machine. The actions are performed
+using systemd-logind, so the usual polkit rules apply and no extra privileges
+are needed for a local session.
+
+By default, each action is shown as a separate segment, and reboot and power off
+require a second click to confirm. The actions can also be shown in a menu
+instead (see Menu), using the launcher configured in barista.run/base/actions/menu.
+
+Locking the screen asks logind to lock the session, which requires a screen
+locker that listens for lock requests, such as xss-lock or swayidle.
+*/
+package power // import "barista.run/modules/power"
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/actions"
+	"barista.run/base/actions/menu"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Action is a power management action.
+type Action int
+
+// The supported power management actions.
+const (
+	Lock Action = iota
+	Suspend
+	Hibernate
+	Reboot
+	PowerOff
+)
+
+var allActions = []Action{Lock, Suspend, Hibernate, Reboot, PowerOff}
+
+// String returns a label for the action, such as "Power off".
+func (a Action) String() string {
+	switch a {
+	case Lock:
+		return "Lock"
+	case Suspend:
+		return "Suspend"
+	case Hibernate:
+		return "Hibernate"
+	case Reboot:
+		return "Reboot"
+	case PowerOff:
+		return "Power off"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// logind method names for the manager actions.
+var methods = map[Action]string{
+	Suspend:   "Suspend",
+	Hibernate: "Hibernate",
+	Reboot:    "Reboot",
+	PowerOff:  "PowerOff",
+}
+
+var busType = dbus.System
+
+func manager() *dbus.PropertiesWatcher {
+	return dbus.WatchProperties(busType,
+		"org.freedesktop.login1",
+		"/org/freedesktop/login1",
+		"org.freedesktop.login1.Manager")
+}
+
+// Run performs the action immediately, without confirmation. Polkit may ask
+// the user for authentication, e.g. if other users are logged in.
+func (a Action) Run() error {
+	if a == Lock {
+		// "auto" refers to the session of the calling process, or the
+		// user's display session if it is not part of a session.
+		w := dbus.WatchProperties(busType,
+			"org.freedesktop.login1",
+			"/org/freedesktop/login1/session/auto",
+			"org.freedesktop.login1.Session")
+		defer w.Unsubscribe()
+		_, err := w.Call("Lock")
+		return err
+	}
+	method, ok := methods[a]
+	if !ok {
+		return fmt.Errorf("unknown power action %v", a)
+	}
+	w := manager()
+	defer w.Unsubscribe()
+	// The argument allows polkit to interactively authenticate the user.
+	_, err := w.Call(method, true)
+	return err
+}
+
+// Available returns false if logind reports that the action is not supported
+// or not permitted, for example hibernation without a swap partition. If
+// logind cannot be queried, the action is assumed to be available, and any
+// error will be reported when it is run.
+func (a Action) Available() bool {
+	method, ok := methods[a]
+	if !ok {
+		return true
+	}
+	w := manager()
+	defer w.Unsubscribe()
+	res, err := w.Call("Can" + method)
+	if err != nil || len(res) != 1 {
+		return true
+	}
+	// One of "yes", "no", "challenge" (needs authentication), or "na".
+	can, _ := res[0].(string)
+	return can != "no" && can != "na"
+}
+
+// Info represents a single action shown on the bar.
+type Info struct {
+	Action Action
+	// Confirming is true after the action has been clicked once, if the
+	// action requires confirmation. Clicking it again performs the action.
+	Confirming bool
+}
+
+// How long to wait for the second click on actions that need confirmation.
+const confirmTimeout = 5 * time.Second
+
+type config struct {
+	actions []Action
+	confirm map[Action]bool
+	menu    bar.Output
+}
+
+// Module represents a power menu bar module.
+type Module struct {
+	config     value.Value // of config
+	outputFunc value.Value // of func(Info) bar.Output
+
+	confirms map[Action]*confirmation
+	changed  notifier.Source
+}
+
+// confirmation tracks the click that armed a confirmation, so that errors can
+// be reported against it.
+type confirmation struct {
+	*click.Confirmation
+	mu    sync.Mutex
+	event bar.Event
+}
+
+// New creates a power module with the given actions, which are shown in the
+// given order. If no actions are given, lock, suspend, reboot, and power off
+// are shown. Actions that logind reports as unavailable are not shown.
+func New(actions ...Action) *Module {
+	if len(actions) == 0 {
+		actions = []Action{Lock, Suspend, Reboot, PowerOff}
+	}
+	m := &Module{confirms: map[Action]*confirmation{}}
+	l.Register(m, "config", "outputFunc")
+	m.config.Set(config{actions: actions})
+	for _, a := range allActions {
+		a := a
+		c := &confirmation{}
+		c.Confirmation = click.Confirm(func() {
+			c.mu.Lock()
+			e := c.event
+			c.mu.Unlock()
+			run(a, e)
+		}, confirmTimeout)
+		m.confirms[a] = c
+		sub, _ := c.Subscribe()
+		go m.notifyOnChange(sub)
+	}
+	m.Confirm(Reboot, PowerOff)
+	// Default output is the label of each action, with a question mark while
+	// waiting for confirmation.
+	m.Output(func(i Info) bar.Output {
+		if i.Confirming {
+			return outputs.Textf("%s?", i.Action).Urgent(true)
+		}
+		return outputs.Text(i.Action.String())
+	})
+	return m
+}
+
+func (m *Module) notifyOnChange(sub <-chan struct{}) {
+	for range sub {
+		m.changed.Notify()
+	}
+}
+
+func (m *Module) updateConfig(update func(*config)) *Module {
+	c := m.config.Get().(config)
+	update(&c)
+	m.config.Set(c)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function
+// for each action. The click handler of the output is replaced by one that
+// performs (or confirms) the action on left click.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Confirm sets the actions that require a second click (or a second menu
+// selection) to be performed. By default, reboot and power off need
+// confirmation. Calling Confirm with no actions disables confirmation.
+func (m *Module) Confirm(actions ...Action) *Module {
+	confirm := map[Action]bool{}
+	for _, a := range actions {
+		confirm[a] = true
+	}
+	for a, c := range m.confirms {
+		if !confirm[a] {
+			c.Cancel()
+		}
+	}
+	return m.updateConfig(func(c *config) { c.confirm = confirm })
+}
+
+// Menu shows the given output instead of the individual actions, and shows a
+// menu of the actions when it is clicked. Actions that require confirmation
+// show a second menu to confirm them. Calling Menu with a nil output shows the
+// actions as separate segments again.
+func (m *Module) Menu(out bar.Output) *Module {
+	return m.updateConfig(func(c *config) { c.menu = out })
+}
+
+// run performs the action, reporting any errors against the click event.
+func run(a Action, e bar.Event) {
+	if err := a.Run(); err != nil {
+		actions.Report(fmt.Errorf("%s failed: %v", strings.ToLower(a.String()), err), e)
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	available := map[Action]bool{}
+	for _, a := range allActions {
+		available[a] = a.Available()
+	}
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	cfg := m.config.Get().(config)
+	nextConfig, doneConfig := m.config.Subscribe()
+	defer doneConfig()
+	changed, doneChanged := m.changed.Subscribe()
+	defer doneChanged()
+	for {
+		var acts []Action
+		for _, a := range cfg.actions {
+			if available[a] {
+				acts = append(acts, a)
+			}
+		}
+		if cfg.menu != nil {
+			s.Output(withClick(cfg.menu, m.menuClick(acts, cfg.confirm)))
+		} else {
+			s.Output(m.output(acts, cfg.confirm, outputFunc))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextConfig:
+			cfg = m.config.Get().(config)
+		case <-changed:
+		}
+	}
+}
+
+func (m *Module) output(acts []Action, confirm map[Action]bool, outputFunc func(Info) bar.Output) bar.Output {
+	out := outputs.Group()
+	for _, a := range acts {
+		c := m.confirms[a]
+		i := Info{Action: a, Confirming: confirm[a] && c.Pending()}
+		if o := outputFunc(i); o != nil {
+			out.Append(withClick(o, m.click(a, confirm[a])))
+		}
+	}
+	return out
+}
+
+func (m *Module) click(a Action, confirm bool) func(bar.Event) {
+	if !confirm {
+		return func(e bar.Event) {
+			if e.Button == bar.ButtonLeft {
+				run(a, e)
+			}
+		}
+	}
+	c := m.confirms[a]
+	return func(e bar.Event) {
+		c.mu.Lock()
+		c.event = e
+		c.mu.Unlock()
+		c.Click(e)
+	}
+}
+
+func (m *Module) menuClick(acts []Action, confirm map[Action]bool) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button != bar.ButtonLeft {
+			return
+		}
+		var items []menu.Item
+		for _, a := range acts {
+			a := a
+			item := menu.Item{Label: a.String(), Action: func() { run(a, e) }}
+			if confirm[a] {
+				item.Action = func() {
+					err := menu.Show(fmt.Sprintf("%s?", a),
+						menu.Item{Label: "Yes", Action: func() { run(a, e) }},
+						menu.Item{Label: "No"})
+					if err != nil {
+						actions.Report(err, e)
+					}
+				}
+			}
+			items = append(items, item)
+		}
+		if err := menu.Show("Power", items...); err != nil {
+			actions.Report(err, e)
+		}
+	}
+}
+
+// withClick returns a copy of the output with the click handler replaced.
+func withClick(o bar.Output, fn func(bar.Event)) bar.Output {
+	var out bar.Segments
+	for _, seg := range o.Segments() {
+		out = append(out, seg.Clone().OnClick(fn))
+	}
+	return out
+}
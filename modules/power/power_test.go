@@ -0,0 +1,204 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package power
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/actions"
+	"barista.run/base/actions/menu"
+	"barista.run/base/watchers/dbus"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLogind struct {
+	mgr   *dbus.TestBusObject
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeLogind) record(method string) func(...interface{}) ([]interface{}, error) {
+	return func(args ...interface{}) ([]interface{}, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if method != "Lock" && (len(args) != 1 || args[0] != true) {
+			return nil, errors.New("interactive must be true")
+		}
+		f.calls = append(f.calls, method)
+		return nil, nil
+	}
+}
+
+func (f *fakeLogind) can(method, result string) {
+	f.mgr.On("Can"+method, func(...interface{}) ([]interface{}, error) {
+		return []interface{}{result}, nil
+	})
+}
+
+func (f *fakeLogind) takeCalls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := f.calls
+	f.calls = nil
+	return calls
+}
+
+func setupLogind() *fakeLogind {
+	busType = dbus.Test
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService("org.freedesktop.login1")
+	f := &fakeLogind{
+		mgr: srv.Object("/org/freedesktop/login1", "org.freedesktop.login1.Manager"),
+	}
+	session := srv.Object("/org/freedesktop/login1/session/auto",
+		"org.freedesktop.login1.Session")
+	session.On("Lock", f.record("Lock"))
+	for _, m := range methods {
+		f.mgr.On(m, f.record(m))
+		f.can(m, "yes")
+	}
+	return f
+}
+
+func TestActions(t *testing.T) {
+	logind := setupLogind()
+	logind.can("Hibernate", "na")
+	logind.can("Reboot", "challenge")
+	testBar.New(t)
+	p := New(Lock, Suspend, Hibernate, Reboot, PowerOff)
+	testBar.Run(p)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"Lock", "Suspend", "Reboot", "Power off"},
+		"unavailable actions are hidden")
+
+	out.At(0).LeftClick()
+	out.At(1).Click(bar.Event{Button: bar.ButtonRight})
+	out.At(1).LeftClick()
+	require.Equal(t, []string{"Lock", "Suspend"}, logind.takeCalls())
+
+	out.At(2).LeftClick()
+	out = testBar.NextOutput("on first click")
+	out.AssertText([]string{"Lock", "Suspend", "Reboot?", "Power off"})
+	require.Empty(t, logind.takeCalls(), "waits for confirmation")
+
+	out.At(2).LeftClick()
+	out = testBar.NextOutput("on second click")
+	out.AssertText([]string{"Lock", "Suspend", "Reboot", "Power off"})
+	require.Equal(t, []string{"Reboot"}, logind.takeCalls())
+
+	out.At(3).LeftClick()
+	out = testBar.NextOutput("on first click")
+	out.AssertText([]string{"Lock", "Suspend", "Reboot", "Power off?"})
+	out.At(3).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput("on cancel").AssertText(
+		[]string{"Lock", "Suspend", "Reboot", "Power off"})
+	require.Empty(t, logind.takeCalls())
+
+	out.At(3).LeftClick()
+	testBar.NextOutput("on first click").At(3).AssertText("Power off?")
+	timing.NextTick()
+	testBar.NextOutput("on timeout").At(3).AssertText("Power off")
+	require.Empty(t, logind.takeCalls())
+
+	p.Confirm()
+	out = testBar.NextOutput("on confirmation change")
+	out.At(3).LeftClick()
+	require.Equal(t, []string{"PowerOff"}, logind.takeCalls(),
+		"no confirmation needed")
+	testBar.AssertNoOutput("on click without confirmation")
+}
+
+func TestErrors(t *testing.T) {
+	logind := setupLogind()
+	logind.mgr.On("Suspend", func(...interface{}) ([]interface{}, error) {
+		return nil, errors.New("access denied")
+	})
+	logind.mgr.On("CanPowerOff", func(...interface{}) ([]interface{}, error) {
+		return nil, errors.New("something went wrong")
+	})
+	errs := make(chan bar.ErrorEvent, 1)
+	actions.SetErrorHandler(func(e bar.ErrorEvent) { errs <- e })
+	defer actions.SetErrorHandler(nil)
+
+	testBar.New(t)
+	testBar.Run(New())
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"Lock", "Suspend", "Reboot", "Power off"},
+		"shown if availability cannot be checked")
+
+	out.At(1).Click(bar.Event{Button: bar.ButtonLeft, X: 42})
+	select {
+	case e := <-errs:
+		require.Contains(t, e.Error.Error(), "suspend failed")
+		require.Contains(t, e.Error.Error(), "access denied")
+		require.Equal(t, 42, e.X)
+	case <-time.After(time.Second):
+		require.Fail(t, "error not reported")
+	}
+}
+
+func TestMenu(t *testing.T) {
+	logind := setupLogind()
+	var mu sync.Mutex
+	var prompts []string
+	choices := map[string]string{}
+	menu.SetLauncher(func(prompt string) *exec.Cmd {
+		mu.Lock()
+		defer mu.Unlock()
+		prompts = append(prompts, prompt)
+		return exec.Command("echo", choices[prompt])
+	})
+	defer menu.SetLauncher(nil)
+	var out bar.Segments
+	choose := func(c map[string]string) []string {
+		mu.Lock()
+		choices = c
+		prompts = nil
+		mu.Unlock()
+		out[0].Click(bar.Event{Button: bar.ButtonLeft})
+		mu.Lock()
+		defer mu.Unlock()
+		return prompts
+	}
+
+	testBar.New(t)
+	p := New(Lock, Reboot).Menu(bar.TextSegment("⏻"))
+	testBar.Run(p)
+	o := testBar.NextOutput("on start")
+	o.AssertText([]string{"⏻"})
+	out = bar.Segments{o.At(0).Segment()}
+
+	require.Equal(t, []string{"Power"}, choose(map[string]string{"Power": "Lock"}))
+	require.Equal(t, []string{"Lock"}, logind.takeCalls())
+
+	require.Equal(t, []string{"Power", "Reboot?"},
+		choose(map[string]string{"Power": "Reboot", "Reboot?": "No"}))
+	require.Empty(t, logind.takeCalls())
+
+	choose(map[string]string{"Power": "Reboot", "Reboot?": "Yes"})
+	require.Equal(t, []string{"Reboot"}, logind.takeCalls())
+
+	p.Menu(nil)
+	testBar.NextOutput("on menu removal").AssertText([]string{"Lock", "Reboot"})
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privacy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"barista.run/timing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Directories used to find video devices and the processes using them.
+// They can be replaced in tests.
+var (
+	devDir  = "/dev"
+	procDir = "/proc"
+)
+
+// scanInterval is how often processes are checked for open video devices.
+// fsnotify does not report devices being opened or closed, so only devices
+// being added or removed are watched.
+var scanInterval = 2 * time.Second
+
+func isVideoDevice(name string) bool {
+	return strings.HasPrefix(name, "video")
+}
+
+func watchCamera(updates chan<- []string, done <-chan struct{}) error {
+	// Keep using the same directories even if they are replaced in tests.
+	devDir, procDir := devDir, procDir
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := w.Add(devDir); err != nil {
+		return err
+	}
+	sch := timing.NewScheduler().Every(scanInterval)
+	defer sch.Stop()
+
+	apps := cameraApps(devDir, procDir)
+	send(updates, apps, done)
+	for {
+		select {
+		case ev := <-w.Events:
+			if !isVideoDevice(filepath.Base(ev.Name)) {
+				continue
+			}
+		case err := <-w.Errors:
+			return err
+		case <-sch.C:
+		case <-done:
+			return nil
+		}
+		if newApps := cameraApps(devDir, procDir); !equal(apps, newApps) {
+			apps = newApps
+			send(updates, apps, done)
+		}
+	}
+}
+
+// cameraApps returns the names of processes that have a video device open.
+// Processes that cannot be inspected (e.g. those of other users) are ignored.
+func cameraApps(devDir, procDir string) []string {
+	pids, _ := ioutil.ReadDir(procDir)
+	names := map[string]bool{}
+	for _, pid := range pids {
+		if !pid.IsDir() || strings.Trim(pid.Name(), "0123456789") != "" {
+			continue
+		}
+		fdDir := filepath.Join(procDir, pid.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || filepath.Dir(target) != devDir {
+				continue
+			}
+			if !isVideoDevice(filepath.Base(target)) {
+				continue
+			}
+			comm, err := ioutil.ReadFile(filepath.Join(procDir, pid.Name(), "comm"))
+			if err != nil {
+				continue
+			}
+			names[strings.TrimSpace(string(comm))] = true
+			break
+		}
+	}
+	var apps []string
+	for n := range names {
+		apps = append(apps, n)
+	}
+	sort.Strings(apps)
+	return apps
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privacy
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// pactl runs pactl with the given arguments and returns its output.
+// It can be replaced in tests.
+var pactl = func(args ...string) (string, error) {
+	cmd := exec.Command("pactl", args...)
+	// The output of pactl list is localised.
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// subscribe starts `pactl subscribe` and returns its output, which is a
+// line for each event on the server. Closing the reader stops pactl.
+// It can be replaced in tests.
+var subscribe = func() (io.ReadCloser, error) {
+	cmd := exec.Command("pactl", "subscribe")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process{out, cmd}, nil
+}
+
+type process struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (p *process) Close() error {
+	p.cmd.Process.Kill()
+	p.ReadCloser.Close()
+	return p.cmd.Wait()
+}
+
+func watchMicrophone(updates chan<- []string, done <-chan struct{}) error {
+	events, err := subscribe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-done
+		events.Close()
+	}()
+	apps, err := recordingApps()
+	if err != nil {
+		return err
+	}
+	send(updates, apps, done)
+	s := bufio.NewScanner(events)
+	for s.Scan() {
+		// e.g. "Event 'new' on source-output #42"
+		if !strings.Contains(s.Text(), " source-output ") {
+			continue
+		}
+		newApps, err := recordingApps()
+		if err != nil {
+			return err
+		}
+		if !equal(apps, newApps) {
+			apps = newApps
+			send(updates, apps, done)
+		}
+	}
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return errors.New("pactl subscribe exited")
+}
+
+// recordingApps returns the names of applications recording from a source
+// that is not a monitor of a sink.
+func recordingApps() ([]string, error) {
+	sources, err := pactl("list", "short", "sources")
+	if err != nil {
+		return nil, err
+	}
+	// e.g. "1	alsa_output.pci-0000_00_1f.3.analog-stereo.monitor	..."
+	monitors := map[string]bool{}
+	for _, line := range strings.Split(sources, "\n") {
+		f := strings.Fields(line)
+		if len(f) >= 2 && strings.HasSuffix(f[1], ".monitor") {
+			monitors[f[0]] = true
+		}
+	}
+	outputs, err := pactl("list", "source-outputs")
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	var id, source, name, binary string
+	flush := func() {
+		if id != "" && !monitors[source] {
+			switch {
+			case name != "":
+				names[name] = true
+			case binary != "":
+				names[binary] = true
+			default:
+				names[id] = true
+			}
+		}
+		id, source, name, binary = "", "", "", ""
+	}
+	for _, line := range strings.Split(outputs, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Source Output #"):
+			flush()
+			id = line
+		case strings.HasPrefix(line, "Source: "):
+			source = strings.TrimPrefix(line, "Source: ")
+		case strings.HasPrefix(line, "application.name = "):
+			name = unquote(strings.TrimPrefix(line, "application.name = "))
+		case strings.HasPrefix(line, "application.process.binary = "):
+			binary = unquote(strings.TrimPrefix(line, "application.process.binary = "))
+		}
+	}
+	flush()
+	var apps []string
+	for n := range names {
+		apps = append(apps, n)
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
+
+func unquote(s string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(s, `"`), `"`)
+}
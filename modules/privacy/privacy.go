@@ -0,0 +1,189 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package privacy provides an i3bar module that shows when the microphone or a
+webcam is in use.
+
+Microphone use is detected by subscribing to PulseAudio events using pactl,
+which also works with PipeWire's PulseAudio server. Recording from a monitor
+source (i.e. capturing audio output) is not counted as microphone use.
+
+Webcam use is detected by scanning /proc every few seconds to find the
+processes holding a /dev/video* device open, and whenever a video device is
+added or removed.
+
+Microphone use does not require polling, so the module reacts as soon as
+recording starts.
+*/
+package privacy // import "barista.run/modules/privacy"
+
+import (
+	"strings"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Info represents the applications using the microphone and webcam.
+type Info struct {
+	// Microphone contains the names of applications recording audio.
+	Microphone []string
+	// Camera contains the names of processes that have a video device open.
+	Camera []string
+}
+
+// MicrophoneInUse returns true if any application is recording audio.
+func (i Info) MicrophoneInUse() bool {
+	return len(i.Microphone) > 0
+}
+
+// CameraInUse returns true if any process has a video device open.
+func (i Info) CameraInUse() bool {
+	return len(i.Camera) > 0
+}
+
+// InUse returns true if either the microphone or a webcam is in use.
+func (i Info) InUse() bool {
+	return i.MicrophoneInUse() || i.CameraInUse()
+}
+
+// Module represents a privacy indicator bar module.
+type Module struct {
+	microphone bool
+	camera     bool
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(microphone, camera bool) *Module {
+	m := &Module{microphone: microphone, camera: camera}
+	l.Register(m, "outputFunc")
+	// Default output is an urgent indicator of the devices in use,
+	// and nothing at all when they are not.
+	m.Output(func(i Info) bar.Output {
+		var devices []string
+		if i.MicrophoneInUse() {
+			devices = append(devices, "mic")
+		}
+		if i.CameraInUse() {
+			devices = append(devices, "cam")
+		}
+		if len(devices) == 0 {
+			return nil
+		}
+		return outputs.Text("● " + strings.Join(devices, " ")).Urgent(true)
+	})
+	return m
+}
+
+// New creates a module that shows when the microphone or a webcam is in use.
+func New() *Module {
+	return newModule(true, true)
+}
+
+// Microphone creates a module that only shows when the microphone is in use.
+func Microphone() *Module {
+	return newModule(true, false)
+}
+
+// Camera creates a module that only shows when a webcam is in use.
+func Camera() *Module {
+	return newModule(false, true)
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// watchFunc sends the list of applications using a device on the updates
+// channel, initially and every time it changes, until done is closed.
+type watchFunc func(updates chan<- []string, done <-chan struct{}) error
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	done := make(chan struct{})
+	defer close(done)
+	errs := make(chan error, 2)
+	start := func(watch watchFunc) <-chan []string {
+		updates := make(chan []string)
+		go func() { errs <- watch(updates, done) }()
+		return updates
+	}
+
+	var micUpdates, camUpdates <-chan []string
+	// Wait for the initial state of each device before the first output,
+	// to avoid briefly showing a device as not in use.
+	pending := 0
+	if m.microphone {
+		micUpdates = start(watchMicrophone)
+		pending++
+	}
+	if m.camera {
+		camUpdates = start(watchCamera)
+		pending++
+	}
+	micStarted, camStarted := false, false
+
+	info := Info{}
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, doneSub := m.outputFunc.Subscribe()
+	defer doneSub()
+
+	for {
+		select {
+		case info.Microphone = <-micUpdates:
+			if !micStarted {
+				micStarted = true
+				pending--
+			}
+		case info.Camera = <-camUpdates:
+			if !camStarted {
+				camStarted = true
+				pending--
+			}
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case err := <-errs:
+			s.Error(err)
+			return
+		}
+		if pending == 0 {
+			s.Output(outputFunc(info))
+		}
+	}
+}
+
+// send sends apps on the updates channel, unless done is closed first.
+func send(updates chan<- []string, apps []string, done <-chan struct{}) {
+	select {
+	case updates <- apps:
+	case <-done:
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+const sources = `0	alsa_output.pci-0000_00_1f.3.analog-stereo.monitor	module-alsa-card.c	s16le 2ch 44100Hz	SUSPENDED
+1	alsa_input.pci-0000_00_1f.3.analog-stereo	module-alsa-card.c	s16le 2ch 44100Hz	RUNNING
+`
+
+const sourceOutputs = `Source Output #42
+	Driver: protocol-native.c
+	Owner Module: 10
+	Client: 84
+	Source: 1
+	Sample Specification: float32le 1ch 48000Hz
+	Properties:
+		media.name = "RecordStream"
+		application.name = "Firefox"
+		application.process.binary = "firefox"
+
+Source Output #43
+	Driver: protocol-native.c
+	Client: 85
+	Source: 0
+	Properties:
+		application.name = "OBS"
+
+Source Output #44
+	Driver: protocol-native.c
+	Client: 86
+	Source: 1
+	Properties:
+		application.process.binary = "arecord"
+`
+
+var (
+	pactlMu  sync.Mutex
+	pactlOut = map[string]string{}
+	events   *io.PipeWriter
+)
+
+func setPactl(args, out string) {
+	pactlMu.Lock()
+	defer pactlMu.Unlock()
+	pactlOut[args] = out
+}
+
+func init() {
+	pactl = func(args ...string) (string, error) {
+		pactlMu.Lock()
+		defer pactlMu.Unlock()
+		out, ok := pactlOut[strings.Join(args, " ")]
+		if !ok {
+			return "", errors.New("unexpected command")
+		}
+		return out, nil
+	}
+	subscribe = func() (io.ReadCloser, error) {
+		var r *io.PipeReader
+		pactlMu.Lock()
+		defer pactlMu.Unlock()
+		r, events = io.Pipe()
+		return r, nil
+	}
+}
+
+func sendEvent(line string) {
+	pactlMu.Lock()
+	w := events
+	pactlMu.Unlock()
+	fmt.Fprintln(w, line)
+}
+
+func TestRecordingApps(t *testing.T) {
+	setPactl("list short sources", sources)
+	setPactl("list source-outputs", sourceOutputs)
+	apps, err := recordingApps()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Firefox", "arecord"}, apps,
+		"uses binary as fallback, ignores monitor sources")
+
+	setPactl("list source-outputs", "")
+	apps, err = recordingApps()
+	require.NoError(t, err)
+	require.Empty(t, apps)
+}
+
+func TestMicrophone(t *testing.T) {
+	setPactl("list short sources", sources)
+	setPactl("list source-outputs", "")
+	testBar.New(t)
+	testBar.Run(Microphone())
+	testBar.NextOutput("on start").AssertEmpty()
+
+	setPactl("list source-outputs", sourceOutputs)
+	sendEvent("Event 'change' on sink #1")
+	testBar.AssertNoOutput("on unrelated event")
+	sendEvent("Event 'new' on source-output #42")
+	out := testBar.NextOutput("on new source output")
+	out.AssertText([]string{"● mic"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	sendEvent("Event 'change' on source-output #42")
+	testBar.AssertNoOutput("when apps are unchanged")
+
+	setPactl("list source-outputs", "")
+	sendEvent("Event 'remove' on source-output #42")
+	testBar.NextOutput("on removed source output").AssertEmpty()
+
+	events.CloseWithError(errors.New("foo"))
+	testBar.NextOutput("on pactl exit").AssertError()
+}
+
+// makeDirs creates temporary device and process directories, and returns
+// the directory that contains them.
+func makeDirs(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "privacy")
+	require.NoError(t, err)
+	devDir = filepath.Join(dir, "dev")
+	procDir = filepath.Join(dir, "proc")
+	require.NoError(t, os.MkdirAll(devDir, 0755))
+	require.NoError(t, os.MkdirAll(procDir, 0755))
+	return dir
+}
+
+func addProcess(t *testing.T, pid, comm string, fds ...string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(procDir, pid, "fd"), 0755))
+	require.NoError(t, ioutil.WriteFile(
+		filepath.Join(procDir, pid, "comm"), []byte(comm+"\n"), 0644))
+	for i, target := range fds {
+		require.NoError(t, os.Symlink(target,
+			filepath.Join(procDir, pid, "fd", fmt.Sprintf("%d", i))))
+	}
+}
+
+func TestCamera(t *testing.T) {
+	defer os.RemoveAll(makeDirs(t))
+	video0 := filepath.Join(devDir, "video0")
+	require.NoError(t, ioutil.WriteFile(video0, nil, 0644))
+	addProcess(t, "100", "bash", "/dev/null", filepath.Join(devDir, "null"))
+	addProcess(t, "self", "ignored", video0)
+
+	testBar.New(t)
+	testBar.Run(Camera().Output(func(i Info) bar.Output {
+		return outputs.Textf("%v", i.Camera)
+	}))
+	testBar.NextOutput("on start").AssertText([]string{"[]"})
+
+	addProcess(t, "200", "cheese", "/dev/null", video0)
+	testBar.Tick()
+	testBar.NextOutput("on open").AssertText([]string{"[cheese]"})
+
+	video1 := filepath.Join(devDir, "video1")
+	require.NoError(t, ioutil.WriteFile(video1, nil, 0644))
+	testBar.AssertNoOutput("on new device")
+	addProcess(t, "300", "zoom", video1)
+	testBar.Tick()
+	testBar.NextOutput("on open of new device").AssertText([]string{"[cheese zoom]"})
+
+	require.NoError(t, os.RemoveAll(filepath.Join(procDir, "300")))
+	require.NoError(t, os.Remove(video1))
+	testBar.NextOutput("on device removed").AssertText([]string{"[cheese]"})
+
+	require.NoError(t, os.RemoveAll(filepath.Join(procDir, "200")))
+	testBar.Tick()
+	testBar.NextOutput("on close").AssertText([]string{"[]"})
+	testBar.Tick()
+	testBar.AssertNoOutput("without changes")
+}
+
+func TestDefaultOutput(t *testing.T) {
+	defer os.RemoveAll(makeDirs(t))
+	video0 := filepath.Join(devDir, "video0")
+	require.NoError(t, ioutil.WriteFile(video0, nil, 0644))
+	setPactl("list short sources", sources)
+	setPactl("list source-outputs", "")
+
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput("on start").AssertEmpty()
+
+	addProcess(t, "200", "cheese", video0)
+	testBar.Tick()
+	out := testBar.NextOutput("on camera use")
+	out.AssertText([]string{"● cam"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	setPactl("list source-outputs", sourceOutputs)
+	sendEvent("Event 'new' on source-output #42")
+	testBar.NextOutput("on mic use").AssertText([]string{"● mic cam"})
+
+	devDir = filepath.Join(devDir, "missing")
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput("on error").AssertError()
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package reboot provides an i3bar module that indicates when a reboot is
+required, for example after a kernel upgrade.
+
+Two mechanisms are used to detect this:
+
+On Debian and Ubuntu, package maintainer scripts create
+/var/run/reboot-required (and list the packages responsible in
+/var/run/reboot-required.pkgs).
+
+On other distributions (e.g. Arch and Fedora), the running kernel is compared
+against the installed kernels in /usr/lib/modules. A reboot is required if the
+module directory for the running kernel has been removed (as pacman does), or
+if a newer kernel has been installed alongside it (as dnf does).
+*/
+package reboot // import "barista.run/modules/reboot"
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/spf13/afero"
+)
+
+// Info represents whether a reboot is required, and why.
+type Info struct {
+	// Required is true if the system needs to be rebooted.
+	Required bool
+	// Packages that requested the reboot, if known.
+	Packages []string
+	// RunningKernel is the release of the currently running kernel.
+	RunningKernel string
+	// InstalledKernel is the release of the newest installed kernel, if it
+	// differs from the running kernel.
+	InstalledKernel string
+}
+
+// KernelUpdated returns true if a different kernel has been installed since
+// the system was booted.
+func (i Info) KernelUpdated() bool {
+	return i.InstalledKernel != ""
+}
+
+// Module represents a reboot-required bar module.
+type Module struct {
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a module that shows whether a reboot is required.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is an indicator that is only shown when a reboot is
+	// required.
+	m.Output(func(i Info) bar.Output {
+		if !i.Required {
+			return nil
+		}
+		return outputs.Text("⟳ reboot")
+	})
+	m.RefreshInterval(5 * time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info := getInfo()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info = getInfo()
+		}
+	}
+}
+
+var fs = afero.NewOsFs()
+
+const (
+	rebootRequiredFile = "/var/run/reboot-required"
+	modulesDir         = "/usr/lib/modules"
+)
+
+func getInfo() Info {
+	i := Info{}
+	if _, err := fs.Stat(rebootRequiredFile); err == nil {
+		i.Required = true
+		if pkgs, err := afero.ReadFile(fs, rebootRequiredFile+".pkgs"); err == nil {
+			i.Packages = strings.Fields(string(pkgs))
+		}
+	}
+	release, err := afero.ReadFile(fs, "/proc/sys/kernel/osrelease")
+	if err != nil {
+		return i
+	}
+	i.RunningKernel = strings.TrimSpace(string(release))
+	if newest := newestKernel(i.RunningKernel); newest != "" {
+		i.Required = true
+		i.InstalledKernel = newest
+	}
+	return i
+}
+
+// newestKernel returns the most recently installed kernel release if it is
+// not the running kernel, and an empty string otherwise.
+func newestKernel(running string) string {
+	entries, err := afero.ReadDir(fs, modulesDir)
+	if err != nil || len(entries) == 0 {
+		return ""
+	}
+	var newest os.FileInfo
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if newest == nil || e.ModTime().After(newest.ModTime()) {
+			newest = e
+		}
+	}
+	if newest == nil || newest.Name() == running {
+		return ""
+	}
+	current, err := fs.Stat(filepath.Join(modulesDir, running))
+	if err != nil || newest.ModTime().After(current.ModTime()) {
+		return newest.Name()
+	}
+	return ""
+}
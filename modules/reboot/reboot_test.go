@@ -0,0 +1,95 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reboot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2018, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+func installKernel(t *testing.T, release string, age time.Duration) {
+	dir := filepath.Join(modulesDir, release)
+	require.NoError(t, fs.MkdirAll(dir, 0755))
+	mtime := start.Add(-age)
+	require.NoError(t, fs.Chtimes(dir, mtime, mtime))
+}
+
+func TestDebian(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	require.Equal(t, Info{}, getInfo(), "nothing to check")
+
+	afero.WriteFile(fs, rebootRequiredFile, nil, 0644)
+	require.Equal(t, Info{Required: true}, getInfo())
+
+	afero.WriteFile(fs, rebootRequiredFile+".pkgs", []byte("libc6\nlinux-base\n"), 0644)
+	require.Equal(t, Info{Required: true, Packages: []string{"libc6", "linux-base"}},
+		getInfo())
+}
+
+func TestKernel(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	afero.WriteFile(fs, "/proc/sys/kernel/osrelease", []byte("6.1.2-arch1-1\n"), 0444)
+	require.Equal(t, Info{RunningKernel: "6.1.2-arch1-1"}, getInfo(),
+		"no modules directory")
+
+	installKernel(t, "6.1.2-arch1-1", time.Hour)
+	require.Equal(t, Info{RunningKernel: "6.1.2-arch1-1"}, getInfo(),
+		"running kernel is installed")
+
+	afero.WriteFile(fs, filepath.Join(modulesDir, "not-a-kernel"), nil, 0644)
+	installKernel(t, "6.0.9-arch1-1", 2*time.Hour)
+	require.False(t, getInfo().Required, "older kernel installed")
+
+	require.NoError(t, fs.RemoveAll(filepath.Join(modulesDir, "6.1.2-arch1-1")))
+	installKernel(t, "6.1.3-arch1-1", time.Minute)
+	require.Equal(t, Info{
+		Required:        true,
+		RunningKernel:   "6.1.2-arch1-1",
+		InstalledKernel: "6.1.3-arch1-1",
+	}, getInfo(), "running kernel replaced")
+
+	installKernel(t, "6.1.2-arch1-1", time.Hour)
+	info := getInfo()
+	require.True(t, info.Required, "newer kernel alongside running kernel")
+	require.True(t, info.KernelUpdated())
+	require.Equal(t, "6.1.3-arch1-1", info.InstalledKernel)
+}
+
+func TestModule(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	m := New()
+	testBar.Run(m)
+	testBar.NextOutput().AssertEmpty("no reboot required")
+
+	afero.WriteFile(fs, rebootRequiredFile, nil, 0644)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"⟳ reboot"}, "on tick")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %v", i.Required, i.KernelUpdated())
+	})
+	testBar.NextOutput().AssertText([]string{"true false"}, "on output change")
+}
@@ -0,0 +1,254 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rss
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// feedSource polls feeds directly.
+type feedSource struct {
+	mu    sync.Mutex
+	feeds []*feedState
+}
+
+// feedState holds the cached contents of a feed, along with the validators
+// used for conditional requests.
+type feedState struct {
+	url          string
+	etag         string
+	lastModified string
+	fetched      bool
+	title        string
+	link         string
+	items        []Item
+	ids          []string
+	// read holds the IDs of items that have been marked as read, or that
+	// were already in the feed when it was first fetched.
+	read map[string]bool
+}
+
+func newFeedSource(urls []string) *feedSource {
+	s := &feedSource{}
+	for _, u := range urls {
+		s.feeds = append(s.feeds, &feedState{url: u, read: map[string]bool{}})
+	}
+	return s
+}
+
+func (s *feedSource) fetch() ([]Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feeds := []Feed{}
+	for _, f := range s.feeds {
+		if err := f.update(); err != nil {
+			return nil, err
+		}
+		feed := Feed{Title: f.title, URL: f.url, Link: f.link}
+		for idx, item := range f.items {
+			if !f.read[f.ids[idx]] {
+				feed.Items = append(feed.Items, item)
+			}
+		}
+		feed.Unread = len(feed.Items)
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+func (s *feedSource) markRead() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.feeds {
+		f.read = map[string]bool{}
+		for _, id := range f.ids {
+			f.read[id] = true
+		}
+	}
+}
+
+func (s *feedSource) readerURL() string {
+	return ""
+}
+
+// update fetches the feed, unless it has not been modified since the last
+// fetch.
+func (f *feedState) update() error {
+	req, err := http.NewRequest("GET", f.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "barista (+https://barista.run)")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+	if f.etag != "" {
+		req.Header.Set("If-None-Match", f.etag)
+	}
+	if f.lastModified != "" {
+		req.Header.Set("If-Modified-Since", f.lastModified)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified && f.fetched {
+		return nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", f.url, res.Status)
+	}
+	parsed, err := parseFeed(res.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %v", f.url, err)
+	}
+	f.etag = res.Header.Get("ETag")
+	f.lastModified = res.Header.Get("Last-Modified")
+	f.title, f.link = parsed.title, parsed.link
+	f.items, f.ids = parsed.items, parsed.ids
+	if !f.fetched {
+		// Items already in the feed when the bar starts are not unread.
+		for _, id := range f.ids {
+			f.read[id] = true
+		}
+		f.fetched = true
+	}
+	return nil
+}
+
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type xmlItem struct {
+	Title string    `xml:"title"`
+	Links []xmlLink `xml:"link"`
+	GUID  string    `xml:"guid"`
+	ID    string    `xml:"id"`
+}
+
+type xmlChannel struct {
+	Title string    `xml:"title"`
+	Links []xmlLink `xml:"link"`
+	Items []xmlItem `xml:"item"`
+}
+
+// xmlFeed is the union of RSS 2.0 (<rss><channel><item>), RSS 1.0
+// (<rdf:RDF><channel/><item>), and Atom (<feed><entry>).
+type xmlFeed struct {
+	XMLName xml.Name
+	Title   string     `xml:"title"`
+	Links   []xmlLink  `xml:"link"`
+	Entries []xmlItem  `xml:"entry"`
+	Channel xmlChannel `xml:"channel"`
+	Items   []xmlItem  `xml:"item"`
+}
+
+type parsedFeed struct {
+	title string
+	link  string
+	items []Item
+	ids   []string
+}
+
+// link returns the first link that points to a web page, skipping Atom links
+// to the feed itself.
+func link(links []xmlLink) string {
+	for _, l := range links {
+		if text := strings.TrimSpace(l.Text); text != "" {
+			return text
+		}
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+func parseFeed(r io.Reader) (parsedFeed, error) {
+	var x xmlFeed
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charsetReader
+	if err := d.Decode(&x); err != nil {
+		return parsedFeed{}, err
+	}
+	var p parsedFeed
+	var items []xmlItem
+	switch x.XMLName.Local {
+	case "feed":
+		p.title, p.link = x.Title, link(x.Links)
+		items = x.Entries
+	case "rss", "RDF":
+		p.title, p.link = x.Channel.Title, link(x.Channel.Links)
+		items = append(x.Channel.Items, x.Items...)
+	default:
+		return p, fmt.Errorf("unknown feed type <%s>", x.XMLName.Local)
+	}
+	p.title = strings.TrimSpace(p.title)
+	for _, i := range items {
+		item := Item{Title: strings.TrimSpace(i.Title), Link: link(i.Links)}
+		id := strings.TrimSpace(i.GUID)
+		if id == "" {
+			id = strings.TrimSpace(i.ID)
+		}
+		if id == "" {
+			id = item.Link + "\x00" + item.Title
+		}
+		p.items = append(p.items, item)
+		p.ids = append(p.ids, id)
+	}
+	return p, nil
+}
+
+// charsetReader supports the most common non-UTF-8 encoding for feeds.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "latin-1", "us-ascii":
+		return &latin1Reader{r: bufio.NewReader(input)}, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8.
+type latin1Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	for len(l.buf) < len(p) {
+		b, err := l.r.ReadByte()
+		if err != nil {
+			if len(l.buf) > 0 {
+				break
+			}
+			return 0, err
+		}
+		var enc [utf8.UTFMax]byte
+		n := utf8.EncodeRune(enc[:], rune(b))
+		l.buf = append(l.buf, enc[:n]...)
+	}
+	n := copy(p, l.buf)
+	l.buf = l.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rss
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// getJSON fetches a URL and decodes the JSON response.
+func getJSON(req *http.Request, result interface{}) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &statusError{res.StatusCode, res.Status}
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+type statusError struct {
+	code   int
+	status string
+}
+
+func (s *statusError) Error() string {
+	return s.status
+}
+
+func sortFeeds(feeds []Feed) {
+	sort.SliceStable(feeds, func(a, b int) bool {
+		return strings.ToLower(feeds[a].Title) < strings.ToLower(feeds[b].Title)
+	})
+}
+
+// miniflux fetches unread counts using the Miniflux API
+// (https://miniflux.app/docs/api.html).
+type miniflux struct {
+	server string
+	token  string
+}
+
+func (m *miniflux) get(path string, result interface{}) error {
+	req, err := http.NewRequest("GET", m.server+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", m.token)
+	if err := getJSON(req, result); err != nil {
+		return fmt.Errorf("miniflux: %v", err)
+	}
+	return nil
+}
+
+func (m *miniflux) fetch() ([]Feed, error) {
+	var counters struct {
+		Unreads map[string]int `json:"unreads"`
+	}
+	if err := m.get("/v1/feeds/counters", &counters); err != nil {
+		return nil, err
+	}
+	var feeds []struct {
+		ID      int64  `json:"id"`
+		Title   string `json:"title"`
+		FeedURL string `json:"feed_url"`
+		SiteURL string `json:"site_url"`
+	}
+	if err := m.get("/v1/feeds", &feeds); err != nil {
+		return nil, err
+	}
+	result := []Feed{}
+	for _, f := range feeds {
+		result = append(result, Feed{
+			Title:  f.Title,
+			URL:    f.FeedURL,
+			Link:   f.SiteURL,
+			Unread: counters.Unreads[strconv.FormatInt(f.ID, 10)],
+		})
+	}
+	sortFeeds(result)
+	return result, nil
+}
+
+func (m *miniflux) markRead() {}
+
+func (m *miniflux) readerURL() string {
+	return m.server + "/unread"
+}
+
+// greader fetches unread counts using the Google Reader API, as implemented by
+// FreshRSS and others.
+type greader struct {
+	server   string
+	api      string
+	username string
+	password string
+	mu       sync.Mutex
+	auth     string
+}
+
+func (g *greader) login() error {
+	res, err := client.PostForm(g.api+"/accounts/ClientLogin", url.Values{
+		"Email":  {g.username},
+		"Passwd": {g.password},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("greader login: %s", res.Status)
+	}
+	s := bufio.NewScanner(res.Body)
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "Auth=") {
+			g.auth = strings.TrimPrefix(s.Text(), "Auth=")
+			return nil
+		}
+	}
+	return errors.New("greader login: no auth token in response")
+}
+
+func (g *greader) get(path string, result interface{}) error {
+	if g.auth == "" {
+		if err := g.login(); err != nil {
+			return err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", g.api+path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "GoogleLogin auth="+g.auth)
+		err = getJSON(req, result)
+		if s, ok := err.(*statusError); ok && s.code == http.StatusUnauthorized && attempt == 0 {
+			// The auth token has expired, log in again.
+			if err := g.login(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("greader: %v", err)
+		}
+		return nil
+	}
+}
+
+func (g *greader) fetch() ([]Feed, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var counts struct {
+		UnreadCounts []struct {
+			ID    string `json:"id"`
+			Count int    `json:"count"`
+		} `json:"unreadcounts"`
+	}
+	if err := g.get("/reader/api/0/unread-count?output=json", &counts); err != nil {
+		return nil, err
+	}
+	var subs struct {
+		Subscriptions []struct {
+			ID      string `json:"id"`
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			HTMLURL string `json:"htmlUrl"`
+		} `json:"subscriptions"`
+	}
+	if err := g.get("/reader/api/0/subscription/list?output=json", &subs); err != nil {
+		return nil, err
+	}
+	unread := map[string]int{}
+	for _, c := range counts.UnreadCounts {
+		unread[c.ID] = c.Count
+	}
+	result := []Feed{}
+	for _, s := range subs.Subscriptions {
+		result = append(result, Feed{
+			Title:  s.Title,
+			URL:    s.URL,
+			Link:   s.HTMLURL,
+			Unread: unread[s.ID],
+		})
+	}
+	sortFeeds(result)
+	return result, nil
+}
+
+func (g *greader) markRead() {}
+
+func (g *greader) readerURL() string {
+	return g.server
+}
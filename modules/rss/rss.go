@@ -0,0 +1,222 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package rss provides an i3bar module that shows the number of unread items in
+RSS and Atom feeds.
+
+Feeds can be polled directly, in which case items are unread if they were
+published after the bar started and have not been marked as read (there is no
+persistent state). Alternatively, the unread counts can be fetched from a
+feed reader: Miniflux, or FreshRSS (or any reader with a Google Reader
+compatible API).
+
+When polling feeds directly, conditional requests are used to avoid fetching
+feeds that have not changed.
+*/
+package rss // import "barista.run/modules/rss"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/httpclient"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Item represents a single unread item in a feed.
+type Item struct {
+	Title string
+	Link  string
+}
+
+// Feed represents the unread items in a single feed.
+type Feed struct {
+	Title string
+	// URL is the URL of the feed itself.
+	URL string
+	// Link is the URL of the website associated with the feed, if known.
+	Link   string
+	Unread int
+	// Items are the unread items, only available when polling feeds directly.
+	Items []Item
+}
+
+// Info represents the unread items across all feeds.
+type Info struct {
+	Feeds []Feed
+	// ReaderURL is the URL to open to read the unread items.
+	ReaderURL string
+	markRead  func()
+}
+
+// Unread returns the total number of unread items.
+func (i Info) Unread() int {
+	t := 0
+	for _, f := range i.Feeds {
+		t += f.Unread
+	}
+	return t
+}
+
+// MarkRead marks all items as read when polling feeds directly. Unread counts
+// from feed readers are only updated when items are read in the reader.
+func (i Info) MarkRead() {
+	i.markRead()
+}
+
+// source provides feeds with unread counts.
+type source interface {
+	fetch() ([]Feed, error)
+	markRead()
+	readerURL() string
+}
+
+// Module represents an RSS bar module.
+type Module struct {
+	source     source
+	readerURL  value.Value // of string
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(s source, interval time.Duration) *Module {
+	m := &Module{source: s, scheduler: timing.NewScheduler().Named("rss")}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "readerURL", "scheduler")
+	m.readerURL.Set("")
+	// Default output is the number of unread items, if any. Left click opens
+	// the reader, and right click marks all items as read.
+	m.Output(func(i Info) bar.Output {
+		if i.Unread() == 0 {
+			return nil
+		}
+		return outputs.Textf("RSS: %d", i.Unread()).
+			OnClick(click.Map{}.
+				LeftE(click.RunLeft("xdg-open", i.ReaderURL)).
+				Right(i.MarkRead).
+				Handle)
+	})
+	m.RefreshInterval(interval)
+	return m
+}
+
+// New creates a module that polls the given feeds directly.
+func New(feedURLs ...string) *Module {
+	return newModule(newFeedSource(feedURLs), 15*time.Minute)
+}
+
+// Miniflux creates a module that shows unread counts from a Miniflux server,
+// e.g. "https://reader.example.com", using an API token.
+func Miniflux(server, token string) *Module {
+	return newModule(&miniflux{server: server, token: token}, 5*time.Minute)
+}
+
+// FreshRSS creates a module that shows unread counts from a FreshRSS server,
+// e.g. "https://rss.example.com", using its Google Reader compatible API. The
+// password is the API password set in the FreshRSS profile settings.
+func FreshRSS(server, username, password string) *Module {
+	return newModule(&greader{
+		server:   server,
+		api:      server + "/api/greader.php",
+		username: username,
+		password: password,
+	}, 5*time.Minute)
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Reader sets the URL of the feed reader, overriding the default (the
+// reader's web interface, or the website of the first feed with unread items).
+func (m *Module) Reader(url string) *Module {
+	m.readerURL.Set(url)
+	return m
+}
+
+var client = httpclient.Client()
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	markRead := func() {
+		m.source.markRead()
+		m.refreshFn()
+	}
+	getInfo := func() (Info, error) {
+		feeds, err := m.source.fetch()
+		if err != nil {
+			return Info{}, err
+		}
+		i := Info{Feeds: feeds, markRead: markRead}
+		i.ReaderURL = m.readerURL.Get().(string)
+		if i.ReaderURL == "" {
+			i.ReaderURL = m.source.readerURL()
+		}
+		if i.ReaderURL == "" {
+			for _, f := range feeds {
+				if f.Unread > 0 && f.Link != "" {
+					i.ReaderURL = f.Link
+					break
+				}
+			}
+		}
+		return i, nil
+	}
+	info, err := getInfo()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextReaderURL, doneReader := m.readerURL.Subscribe()
+	defer doneReader()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextReaderURL:
+			info, err = getInfo()
+		case <-m.scheduler.C:
+			info, err = getInfo()
+		case <-m.refreshCh:
+			if err != nil {
+				s(nil)
+			}
+			info, err = getInfo()
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rss
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFeeds serves feeds, supporting conditional requests.
+type fakeFeeds struct {
+	sync.Mutex
+	atomItems []string
+	rssItems  []string
+	version   int
+	requests  map[string]int
+	notMod    map[string]int
+}
+
+func (f *fakeFeeds) addItems(atom, rss string) {
+	f.Lock()
+	defer f.Unlock()
+	if atom != "" {
+		f.atomItems = append([]string{atom}, f.atomItems...)
+	}
+	if rss != "" {
+		f.rssItems = append([]string{rss}, f.rssItems...)
+	}
+	f.version++
+}
+
+func (f *fakeFeeds) counts(path string) (requests, notModified int) {
+	f.Lock()
+	defer f.Unlock()
+	return f.requests[path], f.notMod[path]
+}
+
+func (f *fakeFeeds) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests[r.URL.Path]++
+	etag := fmt.Sprintf(`"v%d"`, f.version)
+	lastModified := fmt.Sprintf("Thu, 01 Mar 2018 10:%02d:00 GMT", f.version)
+	switch r.URL.Path {
+	case "/atom.xml":
+		if r.Header.Get("If-None-Match") == etag {
+			f.notMod[r.URL.Path]++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom Blog</title>
+  <link rel="self" href="http://example.com/atom.xml"/>
+  <link href="http://example.com/"/>
+  <id>urn:uuid:60a76c80</id>`)
+		for _, i := range f.atomItems {
+			fmt.Fprintf(w, `<entry><title>%s</title><link href="http://example.com/%s"/><id>urn:%s</id></entry>`, i, i, i)
+		}
+		fmt.Fprint(w, `</feed>`)
+	case "/rss.xml":
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			f.notMod[r.URL.Path]++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified)
+		fmt.Fprint(w, `<?xml version="1.0"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>RSS News</title>
+    <atom:link href="http://news.example.com/rss.xml" rel="self" type="application/rss+xml"/>
+    <link>http://news.example.com/</link>`)
+		for _, i := range f.rssItems {
+			fmt.Fprintf(w, `<item><title>%s</title><link>http://news.example.com/%s</link></item>`, i, i)
+		}
+		fmt.Fprint(w, `</channel></rss>`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestParseFeed(t *testing.T) {
+	p, err := parseFeed(strings.NewReader(`<?xml version="1.0" encoding="ISO-8859-1"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">
+  <channel>
+    <title>Caf` + "\xe9" + ` Feed</title>
+    <link>http://cafe.example.com/</link>
+  </channel>
+  <item>
+    <title>Cr` + "\xe8" + `me br` + "\xfb" + `l` + "\xe9" + `e</title>
+    <link>http://cafe.example.com/creme</link>
+  </item>
+</rdf:RDF>`))
+	require.NoError(t, err)
+	require.Equal(t, "Café Feed", p.title)
+	require.Equal(t, "http://cafe.example.com/", p.link)
+	require.Equal(t, []Item{{"Crème brûlée", "http://cafe.example.com/creme"}}, p.items)
+
+	_, err = parseFeed(strings.NewReader(`<html><body>Not a feed</body></html>`))
+	require.Error(t, err)
+	_, err = parseFeed(strings.NewReader(`<?xml version="1.0" encoding="KOI8-R"?><rss/>`))
+	require.Error(t, err)
+	_, err = parseFeed(strings.NewReader(`<rss><channel>`))
+	require.Error(t, err)
+}
+
+func TestFeeds(t *testing.T) {
+	feeds := &fakeFeeds{
+		atomItems: []string{"old-post"},
+		rssItems:  []string{"old-news"},
+		requests:  map[string]int{},
+		notMod:    map[string]int{},
+	}
+	srv := httptest.NewServer(feeds)
+	defer srv.Close()
+
+	testBar.New(t)
+	m := New(srv.URL+"/atom.xml", srv.URL+"/rss.xml").Output(func(i Info) bar.Output {
+		out := outputs.Group(outputs.Textf("%d %s", i.Unread(), i.ReaderURL))
+		for _, f := range i.Feeds {
+			titles := []string{}
+			for _, item := range f.Items {
+				titles = append(titles, item.Title)
+			}
+			out.Append(outputs.Textf("%s:%d:%s", f.Title, f.Unread, strings.Join(titles, ",")))
+		}
+		return out.OnClick(func(bar.Event) { i.MarkRead() })
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText(
+		[]string{"0 ", "Atom Blog:0:", "RSS News:0:"},
+		"existing items are not unread")
+
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText(
+		[]string{"0 ", "Atom Blog:0:", "RSS News:0:"})
+	req, notMod := feeds.counts("/atom.xml")
+	require.Equal(t, 2, req)
+	require.Equal(t, 1, notMod, "uses etag")
+	req, notMod = feeds.counts("/rss.xml")
+	require.Equal(t, 2, req)
+	require.Equal(t, 1, notMod, "uses last-modified")
+
+	feeds.addItems("new-post", "")
+	testBar.Tick()
+	testBar.NextOutput("on new item").AssertText(
+		[]string{"1 http://example.com/", "Atom Blog:1:new-post", "RSS News:0:"})
+
+	feeds.addItems("newer-post", "breaking-news")
+	testBar.Tick()
+	out := testBar.NextOutput("on more items")
+	out.AssertText([]string{
+		"3 http://example.com/",
+		"Atom Blog:2:newer-post,new-post",
+		"RSS News:1:breaking-news",
+	})
+
+	m.Reader("http://reader.example.com")
+	out = testBar.NextOutput("on reader change")
+	out.AssertText([]string{
+		"3 http://reader.example.com",
+		"Atom Blog:2:newer-post,new-post",
+		"RSS News:1:breaking-news",
+	})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	testBar.NextOutput("on mark read").AssertText(
+		[]string{"0 http://reader.example.com", "Atom Blog:0:", "RSS News:0:"})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	feeds := &fakeFeeds{requests: map[string]int{}, notMod: map[string]int{}}
+	srv := httptest.NewServer(feeds)
+	defer srv.Close()
+
+	testBar.New(t)
+	testBar.Run(New(srv.URL + "/rss.xml"))
+	testBar.NextOutput("on start").AssertEmpty()
+
+	feeds.addItems("", "news")
+	testBar.Tick()
+	out := testBar.NextOutput("on new item")
+	out.AssertText([]string{"RSS: 1"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	testBar.NextOutput("on mark read").AssertEmpty()
+}
+
+func TestFeedErrors(t *testing.T) {
+	feeds := &fakeFeeds{requests: map[string]int{}, notMod: map[string]int{}}
+	srv := httptest.NewServer(feeds)
+	defer srv.Close()
+
+	testBar.New(t)
+	testBar.Run(New(srv.URL + "/missing.xml"))
+	testBar.NextOutput("on http error").AssertError()
+
+	testBar.New(t)
+	testBar.Run(New("not a url\x7f"))
+	testBar.NextOutput("on bad url").AssertError()
+}
+
+func TestMiniflux(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/feeds/counters":
+			fmt.Fprint(w, `{"reads": {"1": 10, "2": 5}, "unreads": {"2": 3}}`)
+		case "/v1/feeds":
+			fmt.Fprint(w, `[
+				{"id": 1, "title": "Zebra News", "feed_url": "http://z.example.com/rss", "site_url": "http://z.example.com"},
+				{"id": 2, "title": "alpha blog", "feed_url": "http://a.example.com/atom", "site_url": "http://a.example.com"}
+			]`)
+		}
+	}))
+	defer srv.Close()
+
+	s := &miniflux{server: srv.URL, token: "token"}
+	feeds, err := s.fetch()
+	require.NoError(t, err)
+	require.Equal(t, []Feed{
+		{Title: "alpha blog", URL: "http://a.example.com/atom", Link: "http://a.example.com", Unread: 3},
+		{Title: "Zebra News", URL: "http://z.example.com/rss", Link: "http://z.example.com"},
+	}, feeds)
+	require.Equal(t, srv.URL+"/unread", s.readerURL())
+
+	testBar.New(t)
+	testBar.Run(Miniflux(srv.URL, "token"))
+	testBar.NextOutput("on start").AssertText([]string{"RSS: 3"})
+
+	_, err = (&miniflux{server: srv.URL, token: "wrong"}).fetch()
+	require.Error(t, err)
+}
+
+func TestFreshRSS(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	validToken := "token-1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/api/greader.php/accounts/ClientLogin" {
+			if r.FormValue("Email") != "me" || r.FormValue("Passwd") != "api-password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			logins++
+			fmt.Fprintf(w, "SID=me/1\nLSID=null\nAuth=%s\n", validToken)
+			return
+		}
+		if r.Header.Get("Authorization") != "GoogleLogin auth="+validToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/greader.php/reader/api/0/unread-count":
+			fmt.Fprint(w, `{"max": 150, "unreadcounts": [
+				{"id": "feed/1", "count": 4, "newestItemTimestampUsec": "1519898400000000"},
+				{"id": "user/-/state/com.google/reading-list", "count": 4}
+			]}`)
+		case "/api/greader.php/reader/api/0/subscription/list":
+			fmt.Fprint(w, `{"subscriptions": [
+				{"id": "feed/1", "title": "Blog", "url": "http://blog.example.com/feed", "htmlUrl": "http://blog.example.com"},
+				{"id": "feed/2", "title": "Another", "url": "http://another.example.com/feed", "htmlUrl": "http://another.example.com"}
+			]}`)
+		}
+	}))
+	defer srv.Close()
+
+	m := FreshRSS(srv.URL, "me", "api-password")
+	s := m.source.(*greader)
+	feeds, err := s.fetch()
+	require.NoError(t, err)
+	require.Equal(t, []Feed{
+		{Title: "Another", URL: "http://another.example.com/feed", Link: "http://another.example.com"},
+		{Title: "Blog", URL: "http://blog.example.com/feed", Link: "http://blog.example.com", Unread: 4},
+	}, feeds)
+	require.Equal(t, 1, logins)
+	require.Equal(t, srv.URL, s.readerURL())
+
+	mu.Lock()
+	validToken = "token-2"
+	mu.Unlock()
+	_, err = s.fetch()
+	require.NoError(t, err, "logs in again when the token expires")
+	require.Equal(t, 2, logins)
+
+	_, err = FreshRSS(srv.URL, "me", "wrong").source.fetch()
+	require.Error(t, err, "login failure")
+}
@@ -0,0 +1,252 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package screencast provides an i3bar module that shows when the screen is being
+captured.
+
+Screen casts requested through xdg-desktop-portal (used by browsers, OBS, and
+most video conferencing applications under Wayland) are detected by watching
+the portal's signals on the session bus. A cast is counted when the portal
+responds to a request with a list of streams, and remains active until the
+portal session is closed. Casts that were started before the module are not
+detected.
+
+Screen recorders that capture the screen directly, such as wf-recorder, are
+detected by looking for running processes with a matching name.
+*/
+package screencast // import "barista.run/modules/screencast"
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	godbus "github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+	"github.com/spf13/afero"
+)
+
+// Info represents the active screen casts and recorders.
+type Info struct {
+	// Casts is the number of active screen casts started through
+	// xdg-desktop-portal.
+	Casts int
+	// Recorders contains the names of running screen recorder processes.
+	Recorders []string
+}
+
+// Active returns true if the screen is being captured.
+func (i Info) Active() bool {
+	return i.Casts > 0 || len(i.Recorders) > 0
+}
+
+// Module represents a screencast indicator bar module.
+type Module struct {
+	recorders  value.Value // of []string
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a module that shows when the screen is being captured.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	l.Register(m, "outputFunc", "recorders", "scheduler")
+	m.Recorders("wf-recorder")
+	// Default output is an urgent indicator that is only shown while the
+	// screen is being captured.
+	m.Output(func(i Info) bar.Output {
+		if !i.Active() {
+			return nil
+		}
+		return outputs.Text("● screen").Urgent(true)
+	})
+	// Recorder processes are polled, and portal sessions that are closed by
+	// the client do not emit a signal.
+	m.RefreshInterval(5 * time.Second)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Recorders sets the process names of screen recorders to look for.
+func (m *Module) Recorders(names ...string) *Module {
+	m.recorders.Set(names)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for recorder processes
+// and portal sessions.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+var busType = dbus.Session
+
+const (
+	portalService = "org.freedesktop.portal.Desktop"
+	requestIface  = "org.freedesktop.portal.Request"
+	sessionIface  = "org.freedesktop.portal.Session"
+	requestPath   = "/org/freedesktop/portal/desktop/request"
+	sessionPath   = "/org/freedesktop/portal/desktop/session"
+)
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	conn := busType()
+	defer conn.Close()
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+	busObj := conn.BusObject()
+	for _, sig := range []struct{ iface, member, path string }{
+		{requestIface, "Response", requestPath},
+		{sessionIface, "Closed", sessionPath},
+	} {
+		err := busObj.AddMatchSignal(sig.iface, sig.member,
+			godbus.WithMatchOption("path_namespace", sig.path)).Err
+		if s.Error(err) {
+			return
+		}
+	}
+
+	// Portal paths include the unique name of the client, so casts are
+	// tracked per client, allowing them to be reconciled against the
+	// client's open sessions.
+	casts := map[string]int{}
+	reconcile := func(client string) {
+		obj := conn.Object(portalService, godbus.ObjectPath(sessionPath+"/"+client))
+		if open := sessions(obj); open < casts[client] {
+			casts[client] = open
+		}
+		if casts[client] == 0 {
+			delete(casts, client)
+		}
+	}
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextRecorders, doneRecorders := m.recorders.Subscribe()
+	defer doneRecorders()
+
+	info := Info{Recorders: m.runningRecorders()}
+	for {
+		info.Casts = 0
+		for _, c := range casts {
+			info.Casts += c
+		}
+		s.Output(outputFunc(info))
+		select {
+		case sig := <-signals:
+			switch sig.Name {
+			case requestIface + ".Response":
+				if isCastStarted(sig) {
+					casts[client(sig.Path, requestPath)]++
+				}
+			case sessionIface + ".Closed":
+				// The session may still be exported when the signal is
+				// emitted, in which case the next refresh will catch up.
+				reconcile(client(sig.Path, sessionPath))
+			}
+		case <-m.scheduler.C:
+			for c := range casts {
+				reconcile(c)
+			}
+			info.Recorders = m.runningRecorders()
+		case <-nextRecorders:
+			info.Recorders = m.runningRecorders()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// isCastStarted returns true if the signal is a successful response that
+// includes screen cast streams, which is sent by both the ScreenCast and
+// RemoteDesktop portals when a session is started.
+func isCastStarted(sig *dbus.Signal) bool {
+	if len(sig.Body) < 2 {
+		return false
+	}
+	if code, _ := sig.Body[0].(uint32); code != 0 {
+		return false
+	}
+	results, _ := sig.Body[1].(map[string]godbus.Variant)
+	_, ok := results["streams"]
+	return ok
+}
+
+// client returns the client identifier from a portal request or session path,
+// e.g. /org/freedesktop/portal/desktop/session/1_42/token -> 1_42.
+func client(path godbus.ObjectPath, prefix string) string {
+	rest := strings.TrimPrefix(string(path), prefix+"/")
+	return strings.SplitN(rest, "/", 2)[0]
+}
+
+// sessions returns the number of portal sessions open for a client, given
+// the object at the client's session path.
+func sessions(obj godbus.BusObject) int {
+	var data string
+	err := obj.Call("org.freedesktop.DBus.Introspectable.Introspect", 0).Store(&data)
+	if err != nil {
+		return 0
+	}
+	var node introspect.Node
+	if err := xml.Unmarshal([]byte(data), &node); err != nil {
+		return 0
+	}
+	return len(node.Children)
+}
+
+var fs = afero.NewOsFs()
+
+// runningRecorders returns the names of running processes that match any of
+// the configured recorders.
+func (m *Module) runningRecorders() []string {
+	names := map[string]bool{}
+	for _, n := range m.recorders.Get().([]string) {
+		names[n] = true
+	}
+	comms, _ := afero.Glob(fs, "/proc/[0-9]*/comm")
+	found := map[string]bool{}
+	for _, c := range comms {
+		comm, err := afero.ReadFile(fs, c)
+		if err != nil {
+			continue
+		}
+		if name := strings.TrimSpace(string(comm)); names[name] {
+			found[name] = true
+		}
+	}
+	var running []string
+	for n := range found {
+		running = append(running, n)
+	}
+	sort.Strings(running)
+	return running
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package screencast
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	godbus "github.com/godbus/dbus"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	busType = dbus.Test
+}
+
+type portal struct {
+	srv      *dbus.TestBusService
+	sessions int64 // atomic
+}
+
+func setupPortal() *portal {
+	bus := dbus.SetupTestBus()
+	p := &portal{srv: bus.RegisterService(portalService)}
+	obj := p.srv.Object(sessionPath+"/1_42", "org.freedesktop.DBus.Introspectable")
+	obj.On("Introspect", func(...interface{}) ([]interface{}, error) {
+		var nodes []string
+		for i := int64(0); i < atomic.LoadInt64(&p.sessions); i++ {
+			nodes = append(nodes, fmt.Sprintf(`<node name="s%d"/>`, i))
+		}
+		return []interface{}{"<node>" + strings.Join(nodes, "") + "</node>"}, nil
+	})
+	return p
+}
+
+func (p *portal) respond(code uint32, results map[string]godbus.Variant) {
+	p.srv.Object(requestPath+"/1_42/r1", requestIface).
+		Emit("Response", code, results)
+}
+
+func (p *portal) startCast() {
+	atomic.AddInt64(&p.sessions, 1)
+	p.respond(0, map[string]godbus.Variant{
+		"streams": godbus.MakeVariant([]interface{}{uint32(42)}),
+	})
+}
+
+func (p *portal) closeSession(signal bool) {
+	atomic.AddInt64(&p.sessions, -1)
+	if signal {
+		p.srv.Object(sessionPath+"/1_42/s0", sessionIface).
+			Emit("Closed", map[string]godbus.Variant{})
+	}
+}
+
+func TestPortal(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	p := setupPortal()
+	testBar.New(t)
+	testBar.Run(New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%d %v", i.Casts, i.Recorders)
+	}))
+	testBar.NextOutput("on start").AssertText([]string{"0 []"})
+
+	p.startCast()
+	testBar.NextOutput("on cast").AssertText([]string{"1 []"})
+
+	p.respond(1, map[string]godbus.Variant{
+		"streams": godbus.MakeVariant([]interface{}{}),
+	})
+	testBar.NextOutput("on cancelled request").AssertText([]string{"1 []"})
+	p.respond(0, map[string]godbus.Variant{"uri": godbus.MakeVariant("file:///")})
+	testBar.NextOutput("on other request").AssertText([]string{"1 []"})
+
+	p.startCast()
+	testBar.NextOutput("on second cast").AssertText([]string{"2 []"})
+
+	p.closeSession(true)
+	testBar.NextOutput("on session closed").AssertText([]string{"1 []"})
+
+	p.closeSession(false)
+	testBar.AssertNoOutput("when closed by client")
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"0 []"})
+}
+
+func TestRecorders(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	afero.WriteFile(fs, "/proc/1/comm", []byte("systemd\n"), 0644)
+	afero.WriteFile(fs, "/proc/100/comm", []byte("wf-recorder\n"), 0644)
+	afero.WriteFile(fs, "/proc/self/comm", []byte("barista\n"), 0644)
+	setupPortal()
+
+	testBar.New(t)
+	m := New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%d %v", i.Casts, i.Recorders)
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"0 [wf-recorder]"})
+
+	fs.Remove("/proc/100/comm")
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"0 []"})
+
+	m.Recorders("wf-recorder", "barista", "systemd")
+	testBar.NextOutput("on recorders change").AssertText([]string{"0 [systemd]"})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	p := setupPortal()
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput("on start").AssertEmpty()
+
+	p.startCast()
+	out := testBar.NextOutput("on cast")
+	out.AssertText([]string{"● screen"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	p.closeSession(true)
+	testBar.NextOutput("on close").AssertEmpty()
+}
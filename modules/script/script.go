@@ -0,0 +1,238 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package script provides a module that runs a command printing several values,
+and formats them using a template, so that a single script can provide all
+the values for a segment.
+
+The command prints one value per line, as key=value, e.g.
+
+	#!/bin/sh
+	echo "user=$(whoami)"
+	echo "load=$(cut -d' ' -f1 /proc/loadavg)"
+
+Blank lines and lines starting with # are ignored. Alternatively, if the
+output starts with '{', it is parsed as a JSON object, which allows values
+other than strings.
+
+	script.New("~/bin/status.sh").
+		Template(`{{.user}}: {{printf "%.1f" (num .load)}}`).
+		Every(5 * time.Second)
+
+In addition to the standard template functions, num converts a value to a
+number, for formatting or comparisons.
+*/
+package script // import "barista.run/modules/script"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+	"barista.run/modules/shell"
+	"barista.run/outputs"
+)
+
+// Info holds the values printed by a run of the command.
+type Info map[string]interface{}
+
+// String returns the value for key as a string, or "" if it was not printed.
+func (i Info) String(key string) string {
+	v, ok := i[key]
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// Float returns the value for key as a number, or 0 if it was not printed or
+// is not a number.
+func (i Info) Float(key string) float64 {
+	return num(i[key])
+}
+
+// Module represents a script module.
+type Module struct {
+	shell *shell.Module
+}
+
+// New constructs a module that runs the given command, and shows the values
+// it prints. Values are shown as key=value pairs until an output format or
+// template is set.
+func New(cmd string, args ...string) *Module {
+	m := &Module{shell: shell.New(cmd, args...)}
+	l.Label(m, cmd)
+	l.Register(m, "shell")
+	m.Output(defaultOutput)
+	return m
+}
+
+func defaultOutput(i Info) bar.Output {
+	var keys []string
+	for k := range i {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+i.String(k))
+	}
+	return outputs.Text(strings.Join(pairs, " "))
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	m.shell.Stream(s)
+}
+
+// Output sets the output format.
+func (m *Module) Output(format func(Info) bar.Output) *Module {
+	m.shell.Output(func(out string) bar.Output {
+		info, err := Parse(out)
+		if err != nil {
+			return outputs.Error(err)
+		}
+		return format(info)
+	})
+	return m
+}
+
+var funcs = template.FuncMap{"num": num}
+
+// Template sets the output format to a text/template, executed with the Info
+// from each run. An empty result hides the module.
+func (m *Module) Template(text string) *Module {
+	return m.template(text, false)
+}
+
+// PangoTemplate sets the output format to a text/template that produces pango
+// markup. Values are not escaped, so they should be passed through html if
+// they can contain markup characters.
+func (m *Module) PangoTemplate(text string) *Module {
+	return m.template(text, true)
+}
+
+func (m *Module) template(text string, pango bool) *Module {
+	tmpl, err := template.New("script").Funcs(funcs).Parse(text)
+	if err != nil {
+		return m.Output(func(Info) bar.Output { return outputs.Error(err) })
+	}
+	return m.Output(func(i Info) bar.Output {
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, i); err != nil {
+			return outputs.Error(err)
+		}
+		text := strings.TrimSpace(out.String())
+		if text == "" {
+			return nil
+		}
+		if pango {
+			return bar.PangoSegment(text)
+		}
+		return outputs.Text(text)
+	})
+}
+
+// Every sets the refresh interval for the module. A zero interval stops
+// automatic repeats (but Refresh will still work).
+func (m *Module) Every(interval time.Duration) *Module {
+	m.shell.Every(interval)
+	return m
+}
+
+// Refresh runs the command and updates the output.
+func (m *Module) Refresh() {
+	m.shell.Refresh()
+}
+
+// Timeout sets the maximum time the command can run for. Must be called before
+// the module is streamed.
+func (m *Module) Timeout(timeout time.Duration) *Module {
+	m.shell.Timeout(timeout)
+	return m
+}
+
+// Env adds an environment variable for the command. Must be called before the
+// module is streamed.
+func (m *Module) Env(key, value string) *Module {
+	m.shell.Env(key, value)
+	return m
+}
+
+// Dir sets the working directory for the command. Must be called before the
+// module is streamed.
+func (m *Module) Dir(dir string) *Module {
+	m.shell.Dir(dir)
+	return m
+}
+
+// OnClick sets a command to run when the module is clicked, as in shell.Module.
+// Must be called before the module is streamed.
+func (m *Module) OnClick(cmd string, args ...string) *Module {
+	m.shell.OnClick(cmd, args...)
+	return m
+}
+
+// Parse parses the values printed by a command, either as key=value lines, or
+// as a JSON object if the output starts with '{'.
+func Parse(out string) (Info, error) {
+	out = strings.TrimSpace(out)
+	info := Info{}
+	if strings.HasPrefix(out, "{") {
+		if err := json.Unmarshal([]byte(out), &info); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("expected key=value, got %q", line)
+		}
+		info[strings.TrimSpace(line[:eq])] = strings.TrimSpace(line[eq+1:])
+	}
+	return info, nil
+}
+
+// num converts a value to a number, returning 0 for values that are not
+// numbers.
+func num(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	info, err := Parse(`
+# comment
+user = alice
+load=0.52
+empty=
+eq=a=b
+`)
+	require.NoError(t, err)
+	require.Equal(t, Info{"user": "alice", "load": "0.52", "empty": "", "eq": "a=b"}, info)
+
+	info, err = Parse(`{"user": "bob", "load": 1.5, "up": true, "n": null}`)
+	require.NoError(t, err)
+	require.Equal(t, "bob", info.String("user"))
+	require.Equal(t, "1.5", info.String("load"))
+	require.Equal(t, 1.5, info.Float("load"))
+	require.Equal(t, 1.0, info.Float("up"))
+	require.Equal(t, "", info.String("n"))
+	require.Equal(t, "", info.String("missing"))
+	require.Equal(t, 0.0, info.Float("user"))
+
+	info, err = Parse("")
+	require.NoError(t, err)
+	require.Empty(t, info)
+
+	_, err = Parse("no equals sign")
+	require.Error(t, err)
+	_, err = Parse("=value")
+	require.Error(t, err)
+	_, err = Parse("{not json")
+	require.Error(t, err)
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	m := New("printf", `b=2\na=1\n`).Every(time.Second)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"a=1 b=2"}, "default output")
+
+	m.Template(`{{.a}}, {{printf "%.1f" (num .b)}}`)
+	testBar.NextOutput().AssertText([]string{"1, 2.0"}, "on template change")
+
+	m.Template(`{{if gt (num .a) 5.0}}high{{end}}`)
+	testBar.NextOutput().AssertEmpty("empty template output hides the module")
+
+	m.PangoTemplate(`<b>{{.a}}</b>`)
+	out := testBar.NextOutput()
+	text, isPango := out.At(0).Segment().Content()
+	require.True(t, isPango)
+	require.Equal(t, "<b>1</b>", text)
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%v", i.Float("a")+i.Float("b"))
+	})
+	testBar.NextOutput().AssertText([]string{"3"}, "on output change")
+
+	m.Template(`{{.a`)
+	testBar.NextOutput().AssertError("on invalid template")
+
+	m.Template(`{{.a.b.c}}`)
+	testBar.NextOutput().AssertError("on template execution error")
+
+	m.Template(`{{.a}}`)
+	testBar.NextOutput().AssertText([]string{"1"})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1"}, "on tick")
+}
+
+func TestJSON(t *testing.T) {
+	testBar.New(t)
+	m := New("sh", "-c", `echo "{\"temp\": $T, \"name\": \"$N\"}"`).
+		Env("T", "41.7").Env("N", "cpu").
+		Template(`{{.name}}: {{printf "%.0f" .temp}}°C`)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"cpu: 42°C"})
+}
+
+func TestErrors(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(New("echo", "not key value"))
+	testBar.NextOutput().AssertError("on invalid output")
+
+	testBar.New(t)
+	testBar.Run(New("sh", "-c", "echo a=1; sleep 10").Timeout(10 * time.Millisecond))
+	testBar.NextOutput().AssertError("on timeout")
+}
+
+func TestOnClick(t *testing.T) {
+	testBar.New(t)
+	dir := "/"
+	m := New("sh", "-c", `echo "dir=$PWD"`).Dir(dir).
+		OnClick("true").Template("{{.dir}}")
+	testBar.Run(m)
+	out := testBar.NextOutput()
+	out.AssertText([]string{"/"})
+	out.At(0).LeftClick()
+	testBar.NextOutput().AssertText([]string{"/"}, "refreshes on click")
+	m.Refresh()
+	testBar.NextOutput().AssertText([]string{"/"}, "on refresh")
+}
@@ -17,15 +17,28 @@ Package shell provides modules to display the output of shell commands.
 It supports both long-running commands, where the output is the last line,
 e.g. dmesg or tail -f /var/log/some.log, and repeatedly running commands,
 e.g. whoami, date +%s.
+
+Commands can be given a timeout, environment variables, and a working
+directory. Clicks can run a handler command, which receives the click in its
+environment using the i3blocks names (BLOCK_BUTTON, BLOCK_X, and BLOCK_Y, as
+well as button, x, y, relative_x, relative_y, width, and height), after which
+the module is refreshed.
 */
 package shell // import "barista.run/modules/shell"
 
 import (
+	"bytes"
+	"fmt"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/actions"
 	"barista.run/base/notifier"
 	"barista.run/base/value"
 	"barista.run/outputs"
@@ -36,12 +49,46 @@ import (
 type Module struct {
 	cmd       string
 	args      []string
+	env       []string
+	dir       string
+	timeout   time.Duration
+	onClick   []string
 	outf      value.Value // of func(string) bar.Output
+	failf     value.Value // of func(Failure) bar.Output
 	notifyCh  <-chan struct{}
 	notifyFn  func()
 	scheduler *timing.Scheduler
 }
 
+// Failure describes a command that exited with a non-zero status or was killed
+// after timing out.
+type Failure struct {
+	// Output is the trimmed output of the command.
+	Output string
+	// Stderr is the trimmed error output of the command.
+	Stderr string
+	// ExitCode is the exit status of the command, or -1 if it was killed.
+	ExitCode int
+	// TimedOut is true if the command was killed after the timeout.
+	TimedOut bool
+}
+
+func (f Failure) Error() string {
+	var err string
+	switch {
+	case f.TimedOut:
+		err = "timed out"
+	case f.ExitCode < 0:
+		err = "killed by signal"
+	default:
+		err = fmt.Sprintf("exit status %d", f.ExitCode)
+	}
+	if f.Stderr != "" {
+		err += ": " + f.Stderr
+	}
+	return err
+}
+
 // New constructs a new shell module.
 func New(cmd string, args ...string) *Module {
 	m := &Module{cmd: cmd, args: args}
@@ -50,29 +97,141 @@ func New(cmd string, args ...string) *Module {
 	m.outf.Set(func(text string) bar.Output {
 		return outputs.Text(text)
 	})
+	m.failf.Set((func(Failure) bar.Output)(nil))
 	return m
 }
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	out, err := exec.Command(m.cmd, m.args...).Output()
+	out, err := m.run()
 	outf := m.outf.Get().(func(string) bar.Output)
+	failf := m.failf.Get().(func(Failure) bar.Output)
 	for {
-		if s.Error(err) {
+		if f, ok := err.(Failure); ok && failf != nil {
+			s.Output(m.withClick(failf(f)))
+		} else if s.Error(err) {
 			return
+		} else {
+			s.Output(m.withClick(outf(out)))
 		}
-		s.Output(outf(strings.TrimSpace(string(out))))
 		select {
 		case <-m.outf.Next():
 			outf = m.outf.Get().(func(string) bar.Output)
+		case <-m.failf.Next():
+			failf = m.failf.Get().(func(Failure) bar.Output)
 		case <-m.notifyCh:
-			out, err = exec.Command(m.cmd, m.args...).Output()
+			out, err = m.run()
 		case <-m.scheduler.C:
-			out, err = exec.Command(m.cmd, m.args...).Output()
+			out, err = m.run()
 		}
 	}
 }
 
+// run runs the command, returning its trimmed output. If the command exits with
+// a non-zero status or times out, the error is a Failure.
+func (m *Module) run() (string, error) {
+	cmd := command(m.cmd, m.args, m.env, m.dir)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	var timedOut int32
+	if m.timeout > 0 {
+		timer := time.AfterFunc(m.timeout, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			// Kill the whole process group, since a command run through a
+			// shell might leave children holding stdout open.
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		})
+		defer timer.Stop()
+	}
+	err := cmd.Wait()
+	out := strings.TrimSpace(stdout.String())
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return out, err
+	}
+	f := Failure{
+		Output:   out,
+		Stderr:   strings.TrimSpace(stderr.String()),
+		ExitCode: -1,
+		TimedOut: atomic.LoadInt32(&timedOut) == 1,
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Exited() {
+		f.ExitCode = status.ExitStatus()
+	}
+	return out, f
+}
+
+// command creates a command with the given environment and working directory.
+func command(name string, args, env []string, dir string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	// Prevent SIGUSR for bar pause/resume from propagating to the
+	// child process. Some commands don't play nice with signals.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Dir = dir
+	return cmd
+}
+
+// withClick sends clicks on segments without their own click handlers to the
+// click handler command, if any.
+func (m *Module) withClick(o bar.Output) bar.Output {
+	if o == nil || len(m.onClick) == 0 {
+		return o
+	}
+	var out bar.Segments
+	for _, seg := range o.Segments() {
+		if !seg.HasClick() {
+			seg = seg.Clone().OnClick(m.click)
+		}
+		out = append(out, seg)
+	}
+	return out
+}
+
+func (m *Module) click(e bar.Event) {
+	env := append(append([]string(nil), m.env...), clickEnv(e)...)
+	cmd := command(m.onClick[0], m.onClick[1:], env, m.dir)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		err = fmt.Errorf("%v: %s", err, msg)
+	}
+	if err != nil {
+		actions.Report(err, e)
+	}
+	m.Refresh()
+}
+
+func clickEnv(e bar.Event) []string {
+	vars := []struct {
+		name  string
+		value int
+	}{
+		{"BLOCK_BUTTON", int(e.Button)},
+		{"BLOCK_X", e.ScreenX},
+		{"BLOCK_Y", e.ScreenY},
+		{"button", int(e.Button)},
+		{"x", e.ScreenX},
+		{"y", e.ScreenY},
+		{"relative_x", e.X},
+		{"relative_y", e.Y},
+		{"width", e.Width},
+		{"height", e.Height},
+	}
+	var env []string
+	for _, v := range vars {
+		env = append(env, v.name+"="+strconv.Itoa(v.value))
+	}
+	return env
+}
+
 // Output sets the output format. The format func will be passed the entire
 // trimmed output from the command once it's done executing. To process output
 // by lines, see Tail().
@@ -81,6 +240,44 @@ func (m *Module) Output(format func(string) bar.Output) *Module {
 	return m
 }
 
+// OnFailure sets the output format used when the command exits with a non-zero
+// status or times out. By default, failures are shown as errors, which stop the
+// module until it is restarted.
+func (m *Module) OnFailure(format func(Failure) bar.Output) *Module {
+	m.failf.Set(format)
+	return m
+}
+
+// Timeout sets the maximum time the command can run for, after which it is
+// killed along with any processes it started. Must be called before the module
+// is streamed.
+func (m *Module) Timeout(timeout time.Duration) *Module {
+	m.timeout = timeout
+	return m
+}
+
+// Env adds an environment variable for the command. Must be called before the
+// module is streamed.
+func (m *Module) Env(key, value string) *Module {
+	m.env = append(m.env, key+"="+value)
+	return m
+}
+
+// Dir sets the working directory for the command. Must be called before the
+// module is streamed.
+func (m *Module) Dir(dir string) *Module {
+	m.dir = dir
+	return m
+}
+
+// OnClick sets a command to run when the module is clicked, which receives the
+// click event in its environment. The module is refreshed once the command
+// finishes. Must be called before the module is streamed.
+func (m *Module) OnClick(cmd string, args ...string) *Module {
+	m.onClick = append([]string{cmd}, args...)
+	return m
+}
+
 // Every sets the refresh interval for the module. The command will be executed
 // repeatedly at the given interval, and the output updated. A zero interval
 // stops automatic repeats (but Refresh will still work).
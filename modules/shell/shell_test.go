@@ -15,10 +15,14 @@
 package shell
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/actions"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
@@ -80,3 +84,107 @@ func TestUpdate(t *testing.T) {
 	m.Refresh()
 	testBar.NextOutput("on refresh").AssertText([]string{"*bar*"})
 }
+
+func TestTimeout(t *testing.T) {
+	testBar.New(t)
+	m := New("sh", "-c", "echo partial; sleep 10 & wait").Timeout(50 * time.Millisecond)
+	start := time.Now()
+	testBar.Run(m)
+	errs := testBar.NextOutput().AssertError("on timeout")
+	require.Equal(t, []string{"timed out"}, errs)
+	require.True(t, time.Since(start) < 5*time.Second,
+		"command and its children are killed")
+
+	testBar.New(t)
+	m = New("sh", "-c", "echo partial; sleep 10").
+		Timeout(50 * time.Millisecond).
+		OnFailure(func(f Failure) bar.Output {
+			require.True(t, f.TimedOut)
+			require.Equal(t, -1, f.ExitCode)
+			return outputs.Textf("%s...", f.Output)
+		})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"partial..."}, "on timeout")
+}
+
+func TestEnvAndDir(t *testing.T) {
+	testBar.New(t)
+	dir, err := ioutil.TempDir("", "shell")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	m := New("sh", "-c", `echo "$FOO $(basename "$PWD")"`).
+		Env("FOO", "bar").Dir(dir)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"bar " + filepath.Base(dir)})
+
+	testBar.New(t)
+	tail := Tail("sh", "-c", `echo "$FOO $(basename "$PWD")"`).
+		Env("FOO", "baz").Dir(dir)
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"baz " + filepath.Base(dir)})
+}
+
+func TestFailure(t *testing.T) {
+	testBar.New(t)
+	m := New("sh", "-c", "echo out; echo oops >&2; exit 3")
+	testBar.Run(m)
+	errs := testBar.NextOutput().AssertError("on non-zero exit")
+	require.Equal(t, []string{"exit status 3: oops"}, errs)
+
+	testBar.New(t)
+	m = New("sh", "-c", "echo out; echo oops >&2; exit 3").
+		OnFailure(func(f Failure) bar.Output {
+			return outputs.Textf("%s:%s:%d", f.Output, f.Stderr, f.ExitCode)
+		})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"out:oops:3"}, "with failure handler")
+
+	m.OnFailure(func(f Failure) bar.Output {
+		return outputs.Textf("failed: %v", f)
+	})
+	testBar.NextOutput().AssertText([]string{"failed: exit status 3: oops"},
+		"on failure format change")
+
+	m.OnFailure(nil)
+	testBar.NextOutput().AssertError("on removing failure handler")
+}
+
+func TestOnClick(t *testing.T) {
+	testBar.New(t)
+	dir, err := ioutil.TempDir("", "shell")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	clicks := filepath.Join(dir, "clicks")
+	require.NoError(t, ioutil.WriteFile(clicks, nil, 0644))
+
+	m := New("sh", "-c", "wc -l < clicks").Dir(dir).
+		OnClick("sh", "-c", `echo "$BLOCK_BUTTON $BLOCK_X $relative_x $FOO" >> clicks`).
+		Env("FOO", "bar")
+	testBar.Run(m)
+	out := testBar.NextOutput()
+	out.AssertText([]string{"0"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight, ScreenX: 100, X: 10})
+	testBar.NextOutput("on click").AssertText([]string{"1"}, "refreshes after click")
+	contents, err := ioutil.ReadFile(clicks)
+	require.NoError(t, err)
+	require.Equal(t, "3 100 10 bar\n", string(contents))
+
+	m.Output(func(s string) bar.Output {
+		return outputs.Text(s).OnClick(func(bar.Event) {})
+	})
+	out = testBar.NextOutput("on format change")
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("segments with click handlers are not changed")
+
+	testBar.New(t)
+	var reported []error
+	actions.SetErrorHandler(func(e bar.ErrorEvent) { reported = append(reported, e.Error) })
+	defer actions.SetErrorHandler(nil)
+	m = New("echo", "foo").OnClick("sh", "-c", "echo failed >&2; exit 1")
+	testBar.Run(m)
+	testBar.NextOutput().At(0).LeftClick()
+	testBar.NextOutput("refreshes after failed click").AssertText([]string{"foo"})
+	require.Equal(t, 1, len(reported))
+	require.Equal(t, "exit status 1: failed", reported[0].Error())
+}
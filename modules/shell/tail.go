@@ -16,8 +16,6 @@ package shell
 
 import (
 	"bufio"
-	"os/exec"
-	"syscall"
 
 	"barista.run/bar"
 	"barista.run/base/value"
@@ -29,6 +27,8 @@ import (
 type TailModule struct {
 	cmd  string
 	args []string
+	env  []string
+	dir  string
 	outf value.Value // of func(string) bar.Output
 }
 
@@ -44,13 +44,7 @@ func Tail(cmd string, args ...string) *TailModule {
 
 // Stream starts the module.
 func (m *TailModule) Stream(s bar.Sink) {
-	cmd := exec.Command(m.cmd, m.args...)
-	// Prevent SIGUSR for bar pause/resume from propagating to the
-	// child process. Some commands don't play nice with signals.
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-		Pgid:    0,
-	}
+	cmd := command(m.cmd, m.args, m.env, m.dir)
 	stdout, err := cmd.StdoutPipe()
 	if s.Error(err) {
 		return
@@ -90,3 +84,17 @@ func (m *TailModule) Output(format func(string) bar.Output) *TailModule {
 	m.outf.Set(format)
 	return m
 }
+
+// Env adds an environment variable for the command. Must be called before the
+// module is streamed.
+func (m *TailModule) Env(key, value string) *TailModule {
+	m.env = append(m.env, key+"="+value)
+	return m
+}
+
+// Dir sets the working directory for the command. Must be called before the
+// module is streamed.
+func (m *TailModule) Dir(dir string) *TailModule {
+	m.dir = dir
+	return m
+}
@@ -0,0 +1,360 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package slack provides an i3bar module that shows unread direct messages and
+the do not disturb status for a Slack workspace, and toggles snooze on click.
+
+The module requires a user token (xoxp-...) for a Slack app installed in the
+workspace, which is shown on the app's "OAuth & Permissions" page. The token
+needs the users:read, dnd:read, dnd:write, im:read, and mpim:read scopes.
+
+By default the Web API is polled every few minutes. For immediate updates, an
+app-level token (xapp-...) with the connections:write scope can be given
+using SocketMode, which receives events for the app's subscriptions (e.g.
+message.im and dnd_updated_user) over a websocket.
+*/
+package slack // import "barista.run/modules/slack"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/httpclient"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"golang.org/x/net/websocket"
+)
+
+// Info represents the unread messages and status of the user.
+type Info struct {
+	// Mentions is the number of unread direct messages, which Slack counts
+	// as mentions.
+	Mentions int
+	// Conversations is the number of direct and group messages with
+	// unread messages.
+	Conversations int
+	// Active is true if the user's presence is active, rather than away.
+	Active bool
+	// DND is true if notifications are paused, either by the do not disturb
+	// schedule or by snoozing.
+	DND bool
+	// SnoozeEnd is the end of the current snooze, or zero if not snoozed.
+	SnoozeEnd time.Time
+	m         *Module
+}
+
+// Snoozed returns true if notifications have been snoozed.
+func (i Info) Snoozed() bool {
+	return !i.SnoozeEnd.IsZero()
+}
+
+// ToggleSnooze ends the current snooze, or starts a new one.
+func (i Info) ToggleSnooze() {
+	if i.m == nil {
+		return
+	}
+	var err error
+	if i.Snoozed() {
+		err = i.m.call("dnd.endSnooze", nil, nil)
+	} else {
+		d := i.m.snooze.Get().(time.Duration)
+		params := url.Values{}
+		params.Set("num_minutes", strconv.Itoa(int(d/time.Minute)))
+		err = i.m.call("dnd.setSnooze", params, nil)
+	}
+	if err != nil {
+		l.Log("Failed to toggle snooze: %v", err)
+	}
+	i.m.refreshFn()
+}
+
+// Module represents a Slack bar module.
+type Module struct {
+	token      string
+	appToken   string
+	snooze     value.Value // of time.Duration
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+
+	userID     string
+	socketOnce sync.Once
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+}
+
+// New creates a Slack module using the given user token.
+func New(token string) *Module {
+	m := &Module{token: token, scheduler: timing.NewScheduler().Named("slack")}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "snooze", "scheduler")
+	m.SnoozeFor(time.Hour)
+	// Default output is the number of unread direct messages, with the
+	// status dimmed while snoozed. Clicking toggles snooze.
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Textf("Slack: %d", i.Mentions)
+		switch {
+		case i.DND:
+			out = outputs.Textf("Slack: %d (dnd)", i.Mentions)
+		case i.Mentions > 0:
+			out.Color(colors.Scheme("degraded"))
+		}
+		return out.OnClick(click.Left(i.ToggleSnooze))
+	})
+	m.RefreshInterval(5 * time.Minute)
+	return m
+}
+
+// SocketMode configures the module to refresh when events are received over
+// Socket Mode, using the given app-level token.
+func (m *Module) SocketMode(appToken string) *Module {
+	m.appToken = appToken
+	return m
+}
+
+// SnoozeFor sets the duration of snoozes started by ToggleSnooze.
+func (m *Module) SnoozeFor(d time.Duration) *Module {
+	m.snooze.Set(d)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+var client = httpclient.Client()
+
+// baseURL can be replaced in tests.
+var baseURL = "https://slack.com/api/"
+
+type response struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+func (m *Module) call(method string, params url.Values, out interface{}) error {
+	return callWithToken(m.token, method, params, out)
+}
+
+func callWithToken(token, method string, params url.Values, out interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	req, err := http.NewRequest("POST", baseURL+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: %s: %s", method, res.Status)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var r response
+	if err := json.Unmarshal(body, &r); err != nil {
+		return err
+	}
+	if !r.OK {
+		return fmt.Errorf("slack: %s: %s", method, r.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (m *Module) fetch() (Info, error) {
+	i := Info{m: m}
+	if m.userID == "" {
+		var auth struct {
+			UserID string `json:"user_id"`
+		}
+		if err := m.call("auth.test", nil, &auth); err != nil {
+			return i, err
+		}
+		m.userID = auth.UserID
+	}
+	user := url.Values{"user": {m.userID}}
+
+	var presence struct {
+		Presence string `json:"presence"`
+	}
+	if err := m.call("users.getPresence", user, &presence); err != nil {
+		return i, err
+	}
+	i.Active = presence.Presence == "active"
+
+	var dnd struct {
+		DNDEnabled    bool    `json:"dnd_enabled"`
+		NextStart     float64 `json:"next_dnd_start_ts"`
+		NextEnd       float64 `json:"next_dnd_end_ts"`
+		SnoozeEnabled bool    `json:"snooze_enabled"`
+		SnoozeEnd     float64 `json:"snooze_endtime"`
+	}
+	if err := m.call("dnd.info", user, &dnd); err != nil {
+		return i, err
+	}
+	now := timing.Now()
+	if dnd.SnoozeEnabled {
+		i.SnoozeEnd = time.Unix(int64(dnd.SnoozeEnd), 0)
+	}
+	inSchedule := dnd.DNDEnabled &&
+		!now.Before(time.Unix(int64(dnd.NextStart), 0)) &&
+		now.Before(time.Unix(int64(dnd.NextEnd), 0))
+	i.DND = i.Snoozed() || inSchedule
+
+	var convs struct {
+		Channels []struct {
+			ID string `json:"id"`
+		} `json:"channels"`
+	}
+	err := m.call("users.conversations", url.Values{
+		"types":            {"im,mpim"},
+		"exclude_archived": {"true"},
+		"limit":            {"200"},
+	}, &convs)
+	if err != nil {
+		return i, err
+	}
+	for _, c := range convs.Channels {
+		var info struct {
+			Channel struct {
+				UnreadCount int `json:"unread_count_display"`
+			} `json:"channel"`
+		}
+		if err := m.call("conversations.info", url.Values{"channel": {c.ID}}, &info); err != nil {
+			return i, err
+		}
+		if info.Channel.UnreadCount > 0 {
+			i.Mentions += info.Channel.UnreadCount
+			i.Conversations++
+		}
+	}
+	return i, nil
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	if m.appToken != "" {
+		m.socketOnce.Do(func() { go m.runSocketMode() })
+	}
+	info, err := m.fetch()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.refreshCh:
+			info, err = m.fetch()
+		case <-m.scheduler.C:
+			info, err = m.fetch()
+		}
+	}
+}
+
+// reconnectDelay is the time to wait before reconnecting after a Socket Mode
+// connection fails. It can be reduced in tests.
+var reconnectDelay = 30 * time.Second
+
+func (m *Module) runSocketMode() {
+	for {
+		err := m.socketMode()
+		if err != nil {
+			l.Log("Slack socket mode: %v", err)
+			time.Sleep(reconnectDelay)
+		}
+	}
+}
+
+type envelope struct {
+	EnvelopeID string `json:"envelope_id,omitempty"`
+	Type       string `json:"type,omitempty"`
+}
+
+// socketMode connects to Slack over a websocket, and refreshes the module
+// whenever an event is received. It returns nil when Slack asks the client
+// to reconnect.
+func (m *Module) socketMode() error {
+	var conn struct {
+		URL string `json:"url"`
+	}
+	if err := callWithToken(m.appToken, "apps.connections.open", nil, &conn); err != nil {
+		return err
+	}
+	ws, err := websocket.Dial(conn.URL, "", "https://slack.com")
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	for {
+		var e envelope
+		if err := websocket.JSON.Receive(ws, &e); err != nil {
+			return err
+		}
+		if e.EnvelopeID != "" {
+			// Events must be acknowledged, otherwise they are retried.
+			ack := envelope{EnvelopeID: e.EnvelopeID}
+			if err := websocket.JSON.Send(ws, ack); err != nil {
+				return err
+			}
+		}
+		switch e.Type {
+		case "disconnect":
+			return nil
+		case "events_api":
+			m.refreshFn()
+		}
+	}
+}
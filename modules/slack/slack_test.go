@@ -0,0 +1,265 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// fakeSlack emulates the parts of the Slack Web API used by the module.
+type fakeSlack struct {
+	sync.Mutex
+	presence  string
+	dnd       bool
+	snoozeEnd int64
+	unread    map[string]int
+	calls     []string
+	authFails bool
+	// events are sent over Socket Mode connections, keyed by app token
+	// since connections from earlier tests are never closed.
+	events map[string]chan string
+	acks   chan string
+}
+
+var slack = &fakeSlack{}
+
+func (f *fakeSlack) reset() {
+	f.Lock()
+	defer f.Unlock()
+	f.presence = "active"
+	f.dnd = false
+	f.snoozeEnd = 0
+	f.unread = map[string]int{}
+	f.calls = nil
+	f.authFails = false
+}
+
+func (f *fakeSlack) set(fn func()) {
+	f.Lock()
+	defer f.Unlock()
+	fn()
+}
+
+func (f *fakeSlack) takeCalls() []string {
+	f.Lock()
+	defer f.Unlock()
+	c := f.calls
+	f.calls = nil
+	return c
+}
+
+func (f *fakeSlack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	r.ParseForm()
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	wantToken := "xoxp-token"
+	if method == "apps.connections.open" {
+		wantToken = token
+		if f.events[token] == nil {
+			wantToken = "a registered app token"
+		}
+	}
+	if token != wantToken || (f.authFails && method == "auth.test") {
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "invalid_auth"})
+		return
+	}
+	if method != "auth.test" && method != "apps.connections.open" {
+		call := method
+		for _, p := range []string{"user", "channel", "num_minutes"} {
+			if v := r.Form.Get(p); v != "" {
+				call += " " + p + "=" + v
+			}
+		}
+		f.calls = append(f.calls, call)
+	}
+	res := map[string]interface{}{"ok": true}
+	switch method {
+	case "auth.test":
+		res["user_id"] = "U123"
+	case "users.getPresence":
+		res["presence"] = f.presence
+	case "dnd.info":
+		now := timing.Now().Unix()
+		res["dnd_enabled"] = f.dnd
+		res["next_dnd_start_ts"] = now - 60
+		res["next_dnd_end_ts"] = now + 3600
+		res["snooze_enabled"] = f.snoozeEnd > 0
+		if f.snoozeEnd > 0 {
+			res["snooze_endtime"] = f.snoozeEnd
+		}
+	case "dnd.setSnooze":
+		var mins int64
+		fmt.Sscan(r.Form.Get("num_minutes"), &mins)
+		f.snoozeEnd = timing.Now().Unix() + mins*60
+	case "dnd.endSnooze":
+		f.snoozeEnd = 0
+	case "users.conversations":
+		var channels []map[string]string
+		for _, id := range []string{"D1", "D2", "G1"} {
+			channels = append(channels, map[string]string{"id": id})
+		}
+		res["channels"] = channels
+	case "conversations.info":
+		res["channel"] = map[string]int{"unread_count_display": f.unread[r.Form.Get("channel")]}
+	case "apps.connections.open":
+		res["url"] = "ws://" + r.Host + "/socket?token=" + token
+	default:
+		res = map[string]interface{}{"ok": false, "error": "unknown_method"}
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (f *fakeSlack) socket(ws *websocket.Conn) {
+	f.Lock()
+	events := f.events[ws.Request().URL.Query().Get("token")]
+	f.Unlock()
+	websocket.JSON.Send(ws, map[string]string{"type": "hello"})
+	go func() {
+		for {
+			var ack envelope
+			if err := websocket.JSON.Receive(ws, &ack); err != nil {
+				return
+			}
+			f.acks <- ack.EnvelopeID
+		}
+	}()
+	for e := range events {
+		websocket.Message.Send(ws, e)
+		if strings.Contains(e, `"disconnect"`) {
+			return
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	slack.events = map[string]chan string{}
+	slack.acks = make(chan string, 10)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", slack)
+	mux.Handle("/socket", websocket.Handler(slack.socket))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	baseURL = srv.URL + "/api/"
+	reconnectDelay = 10 * time.Millisecond
+	os.Exit(m.Run())
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	slack.reset()
+	slack.set(func() { slack.unread["D1"] = 2; slack.unread["G1"] = 1 })
+
+	m := New("xoxp-token").Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d %v %v %v", i.Mentions, i.Conversations,
+			i.Active, i.DND, i.Snoozed()).
+			OnClick(func(bar.Event) { i.ToggleSnooze() })
+	})
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"3/2 true false false"})
+	require.Equal(t, []string{
+		"users.getPresence user=U123",
+		"dnd.info user=U123",
+		"users.conversations",
+		"conversations.info channel=D1",
+		"conversations.info channel=D2",
+		"conversations.info channel=G1",
+	}, slack.takeCalls())
+
+	slack.set(func() { slack.presence = "away"; slack.dnd = true; slack.unread = map[string]int{} })
+	testBar.Tick()
+	out = testBar.NextOutput("on refresh")
+	out.AssertText([]string{"0/0 false true false"})
+
+	slack.takeCalls()
+	m.SnoozeFor(30 * time.Minute)
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on snooze")
+	out.AssertText([]string{"0/0 false true true"})
+	require.Equal(t, "dnd.setSnooze num_minutes=30", slack.takeCalls()[0])
+
+	slack.set(func() { slack.dnd = false })
+	out.At(0).LeftClick()
+	testBar.NextOutput("on end snooze").AssertText([]string{"0/0 false false false"})
+	require.Equal(t, "dnd.endSnooze", slack.takeCalls()[0])
+
+	slack.set(func() { slack.authFails = true })
+	testBar.New(t)
+	testBar.Run(New("xoxp-token"))
+	testBar.NextOutput("on auth error").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	slack.reset()
+	testBar.Run(New("xoxp-token"))
+	testBar.NextOutput("on start").AssertText([]string{"Slack: 0"})
+
+	slack.set(func() { slack.unread["D2"] = 4 })
+	testBar.Tick()
+	testBar.NextOutput("with unread").AssertText([]string{"Slack: 4"})
+
+	slack.set(func() { slack.dnd = true })
+	testBar.Tick()
+	testBar.NextOutput("with dnd").AssertText([]string{"Slack: 4 (dnd)"})
+}
+
+var socketTests = 0
+
+func TestSocketMode(t *testing.T) {
+	testBar.New(t)
+	slack.reset()
+	socketTests++
+	appToken := fmt.Sprintf("xapp-%d", socketTests)
+	events := make(chan string)
+	slack.set(func() { slack.events[appToken] = events })
+
+	m := New("xoxp-token").SocketMode(appToken).Output(func(i Info) bar.Output {
+		return outputs.Textf("%d", i.Mentions)
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"0"})
+
+	slack.set(func() { slack.unread["D1"] = 1 })
+	events <- `{"envelope_id": "e1", "type": "events_api",
+		"payload": {"event": {"type": "message", "channel_type": "im"}}}`
+	require.Equal(t, "e1", <-slack.acks)
+	testBar.NextOutput("on event").AssertText([]string{"1"})
+
+	events <- `{"type": "disconnect", "reason": "refresh_requested"}`
+	slack.set(func() { slack.unread["D1"] = 5 })
+	events <- `{"envelope_id": "e2", "type": "events_api",
+		"payload": {"event": {"type": "dnd_updated_user"}}}`
+	require.Equal(t, "e2", <-slack.acks)
+	testBar.NextOutput("after reconnecting").AssertText([]string{"5"})
+}
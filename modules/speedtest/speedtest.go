@@ -0,0 +1,329 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package speedtest provides an i3bar module that measures the download and
+upload bandwidth and latency to a speed test server.
+
+Tests are run when the module starts, on click, and on a (long) schedule.
+Both LibreSpeed servers and the legacy HTTP protocol of Ookla servers are
+supported. Since a test uses a significant amount of bandwidth, the default
+schedule runs a test every six hours.
+*/
+package speedtest // import "barista.run/modules/speedtest"
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/httpclient"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/lucasb-eyer/go-colorful"
+	"github.com/martinlindhe/unit"
+)
+
+// Server represents the endpoints used to measure latency and bandwidth.
+type Server struct {
+	// Ping is requested repeatedly to measure latency.
+	Ping string
+	// Download should respond with a large amount of data.
+	Download string
+	// Upload should accept (and discard) a large POST body.
+	Upload string
+}
+
+// LibreSpeed returns the endpoints of a LibreSpeed server at the given URL,
+// e.g. "https://librespeed.example.com".
+func LibreSpeed(url string) Server {
+	base := strings.TrimSuffix(url, "/") + "/backend/"
+	return Server{
+		Ping:     base + "empty.php",
+		Download: base + "garbage.php?ckSize=100",
+		Upload:   base + "empty.php",
+	}
+}
+
+// Ookla returns the endpoints of an Ookla server using the legacy HTTP
+// protocol, given its host and port, e.g. "speedtest.example.net:8080".
+func Ookla(host string) Server {
+	base := "http://" + host + "/speedtest/"
+	return Server{
+		Ping:     base + "latency.txt",
+		Download: base + "random4000x4000.jpg",
+		Upload:   base + "upload.php",
+	}
+}
+
+// Info represents the result of the last speed test.
+type Info struct {
+	Download unit.Datarate
+	Upload   unit.Datarate
+	Ping     time.Duration
+	// Measured is the time of the last test, or zero if no test has finished.
+	Measured time.Time
+	// Running is true while a test is in progress.
+	Running bool
+	m       *Module
+}
+
+// Age returns the time elapsed since the last test.
+func (i Info) Age() time.Duration {
+	return timing.Now().Sub(i.Measured)
+}
+
+// Run starts a new test, unless one is already running.
+func (i Info) Run() {
+	if i.m != nil {
+		i.m.runFn()
+	}
+}
+
+// Module represents a speed test bar module.
+type Module struct {
+	server     Server
+	scheduler  *timing.Scheduler
+	aging      *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	runFn      func()
+	runCh      <-chan struct{}
+}
+
+// New creates a speed test module using the given server.
+func New(server Server) *Module {
+	m := &Module{
+		server:    server,
+		scheduler: timing.NewScheduler().Named("speedtest"),
+		aging:     timing.NewScheduler(),
+	}
+	m.runFn, m.runCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler", "aging")
+	// Default output is the download/upload speed and latency, fading to grey
+	// over a day. Clicking runs a new test.
+	m.Output(func(i Info) bar.Output {
+		if i.Measured.IsZero() {
+			return outputs.Text("speedtest…").OnClick(click.Left(i.Run))
+		}
+		out := outputs.Textf("↓%.0f ↑%.0f Mb/s %dms",
+			i.Download.MegabitsPerSecond(), i.Upload.MegabitsPerSecond(),
+			i.Ping/time.Millisecond)
+		if i.Running {
+			out = outputs.Textf("↓%.0f ↑%.0f Mb/s …",
+				i.Download.MegabitsPerSecond(), i.Upload.MegabitsPerSecond())
+		}
+		v := 1 - 0.5*float64(i.Age())/float64(24*time.Hour)
+		if v < 0.5 {
+			v = 0.5
+		}
+		return out.Color(colorful.Color{R: v, G: v, B: v}).OnClick(click.Left(i.Run))
+	})
+	m.RefreshInterval(6 * time.Hour)
+	m.aging.Every(15 * time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures how often a test is run.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info := Info{m: m}
+	results := make(chan Info)
+	errs := make(chan error)
+	run := func() {
+		if info.Running {
+			return
+		}
+		info.Running = true
+		go func(i Info) {
+			if err := m.measure(&i); err != nil {
+				errs <- err
+				return
+			}
+			results <- i
+		}(info)
+	}
+	run()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.runCh:
+			run()
+		case <-m.scheduler.C:
+			run()
+		case <-m.aging.C:
+		case info = <-results:
+			info.Running = false
+		case err := <-errs:
+			s.Error(err)
+			return
+		}
+	}
+}
+
+// testDuration is the maximum time spent measuring each direction.
+var testDuration = 10 * time.Second
+
+var client = httpclient.Client()
+
+// measure runs a speed test, updating the given info. Elapsed times are
+// measured using the wall clock, since they must reflect the actual time
+// taken by each transfer.
+func (m *Module) measure(i *Info) error {
+	ping, err := m.ping()
+	if err != nil {
+		return err
+	}
+	down, err := m.download()
+	if err != nil {
+		return err
+	}
+	up, err := m.upload()
+	if err != nil {
+		return err
+	}
+	i.Ping, i.Download, i.Upload = ping, down, up
+	i.Measured = timing.Now()
+	return nil
+}
+
+func checkStatus(res *http.Response) error {
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", res.Request.URL.Path, res.Status)
+	}
+	return nil
+}
+
+func (m *Module) ping() (time.Duration, error) {
+	var best time.Duration
+	for n := 0; n < 5; n++ {
+		start := time.Now()
+		res, err := client.Get(m.server.Ping)
+		if err != nil {
+			return 0, err
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		if err := checkStatus(res); err != nil {
+			return 0, err
+		}
+		if d := time.Since(start); n == 0 || d < best {
+			best = d
+		}
+	}
+	return best, nil
+}
+
+func rate(bytes int64, elapsed time.Duration) unit.Datarate {
+	if elapsed <= 0 {
+		return 0
+	}
+	return unit.Datarate(bytes) * unit.BytePerSecond *
+		unit.Datarate(time.Second) / unit.Datarate(elapsed)
+}
+
+func (m *Module) download() (unit.Datarate, error) {
+	start := time.Now()
+	res, err := client.Get(m.server.Download)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if err := checkStatus(res); err != nil {
+		return 0, err
+	}
+	var total int64
+	buf := make([]byte, 64*1024)
+	for time.Since(start) < testDuration {
+		n, err := res.Body.Read(buf)
+		total += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return rate(total, time.Since(start)), nil
+}
+
+// uploadData is a reader that produces random data until the deadline.
+type uploadData struct {
+	chunk    []byte
+	deadline time.Time
+	total    int64
+}
+
+func (u *uploadData) Read(p []byte) (int, error) {
+	if !time.Now().Before(u.deadline) {
+		return 0, io.EOF
+	}
+	n := copy(p, u.chunk)
+	u.total += int64(n)
+	return n, nil
+}
+
+func (m *Module) upload() (unit.Datarate, error) {
+	chunk := make([]byte, 64*1024)
+	if _, err := rand.Read(chunk); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	data := &uploadData{chunk: chunk, deadline: start.Add(testDuration)}
+	res, err := client.Post(m.server.Upload, "application/octet-stream", data)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	if err := checkStatus(res); err != nil {
+		return 0, err
+	}
+	if data.total == 0 {
+		return 0, errors.New("upload: no data sent")
+	}
+	return rate(data.total, time.Since(start)), nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package speedtest
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	sync.Mutex
+	pings, downloads int
+	uploaded         int64
+	fail             bool
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	fail := f.fail
+	f.Unlock()
+	if fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	switch {
+	case r.URL.Path == "/backend/empty.php" && r.Method == "GET":
+		f.Lock()
+		f.pings++
+		f.Unlock()
+	case r.URL.Path == "/backend/empty.php" && r.Method == "POST":
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		f.Lock()
+		f.uploaded += n
+		f.Unlock()
+	case r.URL.Path == "/backend/garbage.php":
+		if r.URL.Query().Get("ckSize") != "100" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.Lock()
+		f.downloads++
+		f.Unlock()
+		w.Write(make([]byte, 4*1024*1024))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeServer) stats() (int, int, int64) {
+	f.Lock()
+	defer f.Unlock()
+	return f.pings, f.downloads, f.uploaded
+}
+
+func TestServers(t *testing.T) {
+	require.Equal(t, Server{
+		Ping:     "https://speed.example.com/backend/empty.php",
+		Download: "https://speed.example.com/backend/garbage.php?ckSize=100",
+		Upload:   "https://speed.example.com/backend/empty.php",
+	}, LibreSpeed("https://speed.example.com/"))
+	require.Equal(t, Server{
+		Ping:     "http://speedtest.example.net:8080/speedtest/latency.txt",
+		Download: "http://speedtest.example.net:8080/speedtest/random4000x4000.jpg",
+		Upload:   "http://speedtest.example.net:8080/speedtest/upload.php",
+	}, Ookla("speedtest.example.net:8080"))
+}
+
+func TestSpeedtest(t *testing.T) {
+	testBar.New(t)
+	testDuration = 100 * time.Millisecond
+	f := &fakeServer{}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	var info Info
+	m := New(LibreSpeed(srv.URL)).Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%v %v", i.Running, !i.Measured.IsZero()).
+			OnClick(func(bar.Event) { i.Run() })
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"true false"})
+	out := testBar.NextOutput("after test")
+	out.AssertText([]string{"false true"})
+
+	pings, downloads, uploaded := f.stats()
+	require.Equal(t, 5, pings)
+	require.Equal(t, 1, downloads)
+	require.True(t, uploaded > 0)
+	require.True(t, info.Ping > 0)
+	require.True(t, info.Download > 0)
+	require.True(t, info.Upload > 0)
+	require.Equal(t, timing.Now(), info.Measured)
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{"true true"})
+	testBar.NextOutput("after test").AssertText([]string{"false true"})
+	_, downloads, _ = f.stats()
+	require.Equal(t, 2, downloads)
+
+	timing.AdvanceBy(6 * time.Hour)
+	testBar.Drain(time.Second, "on scheduled test").
+		AssertText([]string{"false true"}, "after scheduled test")
+	_, downloads, _ = f.stats()
+	require.Equal(t, 3, downloads)
+	require.Equal(t, timing.Now(), info.Measured)
+
+	f.Lock()
+	f.fail = true
+	f.Unlock()
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{"true true"})
+	errs := testBar.NextOutput("on failure").AssertError()
+	require.Contains(t, errs[0], "503")
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	testDuration = 50 * time.Millisecond
+	srv := httptest.NewServer(&fakeServer{})
+	defer srv.Close()
+
+	testBar.Run(New(LibreSpeed(srv.URL)).RefreshInterval(48 * time.Hour))
+	testBar.NextOutput("on start").AssertText([]string{"speedtest…"})
+	out := testBar.NextOutput("after test")
+	txt, _ := out.At(0).Segment().Content()
+	require.True(t, strings.HasPrefix(txt, "↓"), txt)
+	require.True(t, strings.HasSuffix(txt, "ms"), txt)
+	require.Equal(t, greyness(out.At(0).Segment()), 1.0)
+
+	out.At(0).LeftClick()
+	txt, _ = testBar.NextOutput("while running").At(0).Segment().Content()
+	require.True(t, strings.HasSuffix(txt, "…"), txt)
+	testBar.NextOutput("after test").Expect("after test")
+
+	timing.AdvanceBy(12 * time.Hour)
+	out = testBar.LatestOutput()
+	require.InDelta(t, 0.75, greyness(out.At(0).Segment()), 0.01)
+
+	timing.AdvanceBy(48 * time.Hour)
+	out = testBar.Drain(time.Second, "on scheduled test")
+	require.InDelta(t, 1.0, greyness(out.At(0).Segment()), 0.01)
+}
+
+func greyness(s *bar.Segment) float64 {
+	c, _ := s.GetColor()
+	r, g, b, _ := c.RGBA()
+	if r != g || g != b {
+		return -1
+	}
+	return float64(r) / 0xffff
+}
@@ -0,0 +1,276 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package strava provides an i3bar module that shows the distance and time of
+this week's activities on Strava, against a weekly target.
+
+The module needs a client ID and secret for an API application, which can be
+created at https://www.strava.com/settings/api. Set the "Authorization Callback
+Domain" to localhost, since the code is copied from the redirected URL during
+interactive oauth setup.
+*/
+package strava // import "barista.run/modules/strava"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/oauth"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+	"golang.org/x/oauth2"
+)
+
+// Info represents the totals of this week's activities.
+type Info struct {
+	Activities int
+	Distance   unit.Length
+	// Moving is the time spent moving, excluding pauses.
+	Moving time.Duration
+	// Elevation is the total elevation gain.
+	Elevation unit.Length
+	// Target is the weekly distance target, or zero if none was set.
+	Target unit.Length
+	// TargetTime is the weekly moving time target, or zero if none was set.
+	TargetTime time.Duration
+	// Since is the start of the week.
+	Since time.Time
+}
+
+// Progress returns the fraction of the weekly target completed, based on
+// distance if a distance target is set, otherwise on moving time. It returns
+// 0 if no target is set, and can exceed 1 once the target is reached.
+func (i Info) Progress() float64 {
+	switch {
+	case i.Target > 0:
+		return float64(i.Distance / i.Target)
+	case i.TargetTime > 0:
+		return float64(i.Moving) / float64(i.TargetTime)
+	}
+	return 0
+}
+
+type options struct {
+	target     unit.Length
+	targetTime time.Duration
+	sports     map[string]bool
+	weekStart  time.Weekday
+}
+
+// Module represents a Strava bar module.
+type Module struct {
+	config     *oauth.Config
+	settings   value.Value // of options
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// Endpoint is Strava's OAuth 2.0 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://www.strava.com/oauth/authorize",
+	TokenURL: "https://www.strava.com/oauth/token",
+}
+
+// New creates a Strava module using the given API application credentials.
+func New(clientID, clientSecret string) *Module {
+	config := oauth.Register(&oauth2.Config{
+		Endpoint:     Endpoint,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  "http://localhost",
+		Scopes:       []string{"activity:read_all"},
+	})
+	m := &Module{config: config, scheduler: timing.NewScheduler().Named("strava")}
+	l.Register(m, "outputFunc", "settings", "scheduler")
+	m.settings.Set(options{weekStart: time.Monday})
+	// Default output is this week's distance, and the percentage of the
+	// target if one is set.
+	m.Output(func(i Info) bar.Output {
+		if i.Target == 0 && i.TargetTime == 0 {
+			return outputs.Textf("%.1f km", i.Distance.Kilometers())
+		}
+		return outputs.Textf("%.1f km (%.0f%%)",
+			i.Distance.Kilometers(), i.Progress()*100)
+	})
+	m.RefreshInterval(4 * time.Hour)
+	return m
+}
+
+func (m *Module) update(fn func(*options)) *Module {
+	c := m.settings.Get().(options)
+	fn(&c)
+	m.settings.Set(c)
+	return m
+}
+
+// Target sets a weekly distance target.
+func (m *Module) Target(distance unit.Length) *Module {
+	return m.update(func(c *options) { c.target = distance })
+}
+
+// TargetTime sets a weekly moving time target, which is used for progress
+// when no distance target is set.
+func (m *Module) TargetTime(moving time.Duration) *Module {
+	return m.update(func(c *options) { c.targetTime = moving })
+}
+
+// Sports restricts the totals to activities of the given sport types, e.g.
+// "Run", "TrailRun". By default, all activities are included.
+func (m *Module) Sports(sports ...string) *Module {
+	return m.update(func(c *options) {
+		c.sports = map[string]bool{}
+		for _, s := range sports {
+			c.sports[strings.ToLower(s)] = true
+		}
+	})
+}
+
+// WeekStart sets the first day of the week, Monday by default.
+func (m *Module) WeekStart(day time.Weekday) *Module {
+	return m.update(func(c *options) { c.weekStart = day })
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency for activities.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// for tests, to wrap the client in a transport that redirects requests.
+var wrapForTest func(*http.Client)
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	client, _ := m.config.Client()
+	if wrapForTest != nil {
+		wrapForTest(client)
+	}
+	activities, err := m.fetch(client)
+	settings := m.settings.Get().(options)
+	nextSettings, done := m.settings.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(summarise(activities, settings)))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextSettings:
+			settings = m.settings.Get().(options)
+		case <-m.scheduler.C:
+			activities, err = m.fetch(client)
+		}
+	}
+}
+
+type activity struct {
+	Type      string    `json:"type"`
+	SportType string    `json:"sport_type"`
+	Distance  float64   `json:"distance"`
+	Moving    int64     `json:"moving_time"`
+	Elevation float64   `json:"total_elevation_gain"`
+	StartDate time.Time `json:"start_date"`
+}
+
+// weekStart returns the start of the current week.
+func weekStart(now time.Time, first time.Weekday) time.Time {
+	days := (int(now.Weekday()) - int(first) + 7) % 7
+	y, mo, d := now.AddDate(0, 0, -days).Date()
+	return time.Date(y, mo, d, 0, 0, 0, 0, now.Location())
+}
+
+// fetch fetches the activities since midnight six days ago, which covers
+// the current week whatever its first day.
+func (m *Module) fetch(client *http.Client) ([]activity, error) {
+	now := timing.Now()
+	since := weekStart(now, (now.Weekday()+1)%7)
+	var all []activity
+	for page := 1; ; page++ {
+		params := url.Values{}
+		params.Set("after", strconv.FormatInt(since.Unix(), 10))
+		params.Set("per_page", "100")
+		params.Set("page", strconv.Itoa(page))
+		res, err := client.Get("https://www.strava.com/api/v3/athlete/activities?" +
+			params.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var activities []activity
+		if res.StatusCode == http.StatusOK {
+			err = json.NewDecoder(res.Body).Decode(&activities)
+		} else {
+			err = fmt.Errorf("HTTP Status %d", res.StatusCode)
+		}
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, activities...)
+		if len(activities) < 100 {
+			return all, nil
+		}
+	}
+}
+
+func summarise(activities []activity, c options) Info {
+	i := Info{
+		Target:     c.target,
+		TargetTime: c.targetTime,
+		Since:      weekStart(timing.Now(), c.weekStart),
+	}
+	for _, a := range activities {
+		if a.StartDate.Before(i.Since) {
+			continue
+		}
+		sport := a.SportType
+		if sport == "" {
+			sport = a.Type
+		}
+		if len(c.sports) > 0 && !c.sports[strings.ToLower(sport)] {
+			continue
+		}
+		i.Activities++
+		i.Distance += unit.Length(a.Distance) * unit.Meter
+		i.Elevation += unit.Length(a.Elevation) * unit.Meter
+		i.Moving += time.Duration(a.Moving) * time.Second
+	}
+	return i
+}
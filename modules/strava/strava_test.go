@@ -0,0 +1,160 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strava
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/testing/httpclient"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	mu             sync.Mutex
+	activitiesJSON string
+	lastQuery      string
+)
+
+func respondWith(activities string) {
+	mu.Lock()
+	defer mu.Unlock()
+	activitiesJSON = activities
+}
+
+func query() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return lastQuery
+}
+
+const week = `[
+	{"type": "Walk", "distance": 4000, "moving_time": 3600,
+	 "start_date": "2016-11-19T10:00:00Z"},
+	{"type": "Run", "sport_type": "TrailRun", "distance": 10000, "moving_time": 3000,
+	 "total_elevation_gain": 250, "start_date": "2016-11-21T07:00:00Z"},
+	{"type": "Ride", "sport_type": "Ride", "distance": 30000, "moving_time": 3600,
+	 "total_elevation_gain": 120, "start_date": "2016-11-23T17:30:00Z"},
+	{"type": "Run", "distance": 5000, "moving_time": 1500,
+	 "start_date": "2016-11-25T06:45:00Z"}
+]`
+
+func TestWeekStart(t *testing.T) {
+	// Friday.
+	now := time.Date(2016, time.November, 25, 20, 47, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2016, time.November, 21, 0, 0, 0, 0, time.UTC),
+		weekStart(now, time.Monday))
+	require.Equal(t, time.Date(2016, time.November, 20, 0, 0, 0, 0, time.UTC),
+		weekStart(now, time.Sunday))
+	require.Equal(t, time.Date(2016, time.November, 25, 0, 0, 0, 0, time.UTC),
+		weekStart(now, time.Friday))
+	require.Equal(t, time.Date(2016, time.November, 19, 0, 0, 0, 0, time.UTC),
+		weekStart(now, time.Saturday))
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	respondWith(week)
+	var info Info
+	m := New("clientid", "clientsecret").Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%d: %.1f km in %v", i.Activities,
+			i.Distance.Kilometers(), i.Moving)
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"3: 45.0 km in 2h15m0s"})
+	require.Equal(t, "after=1479513600&page=1&per_page=100", query(),
+		"fetches activities since midnight six days ago")
+	require.InDelta(t, 370, info.Elevation.Meters(), 0.01)
+	require.Equal(t, time.Date(2016, time.November, 21, 0, 0, 0, 0, time.UTC),
+		info.Since)
+
+	m.Sports("run", "TrailRun")
+	testBar.NextOutput("on sports filter").AssertText([]string{"2: 15.0 km in 1h15m0s"})
+
+	m.WeekStart(time.Saturday)
+	testBar.NextOutput("on week start change").AssertText([]string{"2: 15.0 km in 1h15m0s"})
+
+	m.Sports()
+	testBar.NextOutput("on clearing filter").AssertText([]string{"4: 49.0 km in 3h15m0s"})
+
+	respondWith(`[]`)
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"0: 0.0 km in 0s"})
+
+	respondWith(`{"message": "Authorization Error"}`)
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	respondWith(week)
+	m := New("clientid", "clientsecret")
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"45.0 km"})
+
+	m.TargetTime(3 * time.Hour)
+	testBar.NextOutput("on time target").AssertText([]string{"45.0 km (75%)"})
+
+	m.Target(60 * unit.Kilometer)
+	testBar.NextOutput("on distance target").AssertText([]string{"45.0 km (75%)"})
+
+	m.Target(30 * unit.Kilometer)
+	testBar.NextOutput("on target reached").AssertText([]string{"45.0 km (150%)"})
+}
+
+func TestProgress(t *testing.T) {
+	require.Equal(t, 0.0, Info{Distance: unit.Kilometer}.Progress())
+	require.Equal(t, 0.5, Info{Moving: time.Hour, TargetTime: 2 * time.Hour}.Progress())
+	require.Equal(t, 0.25, Info{
+		Distance: 5 * unit.Kilometer, Target: 20 * unit.Kilometer,
+		Moving: time.Hour, TargetTime: time.Hour,
+	}.Progress(), "distance target takes precedence")
+}
+
+func TestMain(m *testing.M) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/athlete/activities", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		lastQuery = r.URL.RawQuery
+		if activitiesJSON[0] != '[' {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		io.WriteString(w, activitiesJSON)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	wrapForTest = func(c *http.Client) {
+		httpclient.FreezeOauthToken(c, "authtoken-placeholder")
+		httpclient.Wrap(c, server.URL)
+	}
+	timing.TestMode()
+
+	os.Exit(m.Run())
+}
@@ -16,10 +16,12 @@
 package sysinfo // import "barista.run/modules/sysinfo"
 
 import (
+	"runtime"
 	"sync"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/cgroup"
 	"barista.run/base/value"
 	l "barista.run/logging"
 	"barista.run/outputs"
@@ -42,6 +44,22 @@ type Info struct {
 	Procs        uint16
 	TotalHighRAM unit.Datasize
 	FreeHighRAM  unit.Datasize
+	// CPUs is the number of CPUs available, which may be fractional if
+	// limited by the cgroup's CPU quota (see CgroupLimits).
+	CPUs float64
+}
+
+// RelativeLoads returns the load averages divided by the number of CPUs
+// available, so that 1.0 means that all available CPUs are fully used.
+func (i Info) RelativeLoads() [3]float64 {
+	if i.CPUs == 0 {
+		return i.Loads
+	}
+	return [3]float64{
+		i.Loads[0] / i.CPUs,
+		i.Loads[1] / i.CPUs,
+		i.Loads[2] / i.CPUs,
+	}
 }
 
 // currentInfo stores the last value read by the updater.
@@ -73,6 +91,31 @@ func RefreshInterval(interval time.Duration) {
 	updater.Every(interval)
 }
 
+var (
+	useCgroup   bool
+	useCgroupMu sync.Mutex
+)
+
+// CgroupLimits configures whether information is shown relative to the limits
+// of the cgroup that barista is running in (e.g. a container, or a systemd
+// slice with limits), instead of the whole machine. If enabled, CPUs is
+// limited by the cgroup's CPU quota, and if the cgroup's memory limit is lower
+// than the total RAM, TotalRAM is set to the limit, and FreeRAM to the memory
+// that can still be used before reaching it.
+func CgroupLimits(enabled bool) {
+	useCgroupMu.Lock()
+	useCgroup = enabled
+	useCgroupMu.Unlock()
+	construct()
+	update()
+}
+
+func cgroupLimits() bool {
+	useCgroupMu.Lock()
+	defer useCgroupMu.Unlock()
+	return useCgroup
+}
+
 // Module represents a bar.Module that displays memory information.
 type Module struct {
 	outputFunc value.Value
@@ -145,9 +188,41 @@ func update() {
 		FreeSwap:     unit.Datasize(sysinfoT.Freeswap) * mult,
 		TotalHighRAM: unit.Datasize(sysinfoT.Totalhigh) * mult,
 		FreeHighRAM:  unit.Datasize(sysinfoT.Freehigh) * mult,
+		CPUs:         float64(numCPU()),
+	}
+	if cgroupLimits() {
+		lim, err := readCgroup()
+		if currentInfo.Error(err) {
+			return
+		}
+		applyLimits(&sysinfo, lim)
 	}
 	currentInfo.Set(sysinfo)
 }
 
-// To allow tests to mock out unix.Sysinfo.
-var sysinfo = unix.Sysinfo
+// applyLimits adjusts the information to the cgroup's limits, if they are
+// lower than the machine's.
+func applyLimits(i *Info, lim cgroup.Limits) {
+	if lim.CPUs > 0 && lim.CPUs < i.CPUs {
+		i.CPUs = lim.CPUs
+	}
+	if lim.MemoryMax == 0 || lim.MemoryMax >= i.TotalRAM {
+		return
+	}
+	free := lim.MemoryMax - lim.MemoryCurrent
+	if free < 0 {
+		free = 0
+	}
+	if free > i.FreeRAM {
+		free = i.FreeRAM
+	}
+	i.TotalRAM = lim.MemoryMax
+	i.FreeRAM = free
+}
+
+// To allow tests to mock out unix.Sysinfo, runtime.NumCPU, and cgroup.Read.
+var (
+	sysinfo    = unix.Sysinfo
+	numCPU     = runtime.NumCPU
+	readCgroup = cgroup.Read
+)
@@ -16,17 +16,20 @@ package sysinfo
 
 import (
 	"errors"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/cgroup"
 	"barista.run/base/value"
 	"barista.run/format"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
 
+	"github.com/martinlindhe/unit"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sys/unix"
 )
@@ -143,3 +146,66 @@ func TestErrors(t *testing.T) {
 	errs = testBar.NextOutput().AssertError("on next tick with error")
 	require.Equal("something else", errs[0], "new error is propagated")
 }
+
+func TestCgroupLimits(t *testing.T) {
+	var lim cgroup.Limits
+	var limErr error
+	var limMu sync.Mutex
+	readCgroup = func() (cgroup.Limits, error) {
+		limMu.Lock()
+		defer limMu.Unlock()
+		return lim, limErr
+	}
+	setLimits := func(l cgroup.Limits, err error) {
+		limMu.Lock()
+		defer limMu.Unlock()
+		lim, limErr = l, err
+	}
+	numCPU = func() int { return 8 }
+	defer func() {
+		readCgroup = cgroup.Read
+		numCPU = runtime.NumCPU
+	}()
+
+	testBar.New(t)
+	resetForTest()
+	shouldReturn(unix.Sysinfo_t{
+		Unit:     1024,
+		Totalram: 4096,
+		Freeram:  1024,
+		Loads:    [3]uint64{65536, 131072, 196608},
+	})
+	update()
+
+	m := New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %.3f %v/%v", i.CPUs, i.RelativeLoads()[2],
+			i.FreeRAM.Kibibytes(), i.TotalRAM.Kibibytes())
+	})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"8 0.375 1024/4096"}, "on start")
+
+	setLimits(cgroup.Limits{
+		CPUs:          1.5,
+		MemoryMax:     2048 * unit.Kibibyte,
+		MemoryCurrent: 1536 * unit.Kibibyte,
+	}, nil)
+	CgroupLimits(true)
+	testBar.NextOutput().AssertText([]string{"1.5 2.000 512/2048"},
+		"relative to cgroup limits")
+
+	setLimits(cgroup.Limits{CPUs: 16, MemoryMax: 8192 * unit.Kibibyte}, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"8 0.375 1024/4096"},
+		"machine limits are used when lower")
+
+	setLimits(cgroup.Limits{}, errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput().AssertError("on cgroup error")
+
+	CgroupLimits(false)
+	testBar.NextOutput().AssertText([]string{"8 0.375 1024/4096"},
+		"when disabled")
+
+	require.Equal(t, [3]float64{1, 2, 3}, Info{Loads: [3]float64{1, 2, 3}}.RelativeLoads(),
+		"without CPU information")
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package tasks provides an i3bar module that shows tasks from a todo list, with
+the number of tasks due today and overdue, and the most important task.
+
+Tasks are fetched using a Provider, implemented by the provider packages:
+taskwarrior for Taskwarrior, and todoist for the Todoist REST API.
+*/
+package tasks // import "barista.run/modules/tasks"
+
+import (
+	"sort"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Priority represents the priority of a task. Providers map their own
+// priorities onto these values.
+type Priority int
+
+// Task priorities, from lowest to highest.
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// Task represents a single pending task.
+type Task struct {
+	Title    string
+	Project  string
+	Priority Priority
+	// Due is when the task is due, or the zero time if it has no due date.
+	Due time.Time
+	// AllDay is true if the task is due on a date rather than at a specific
+	// time, in which case Due is midnight in the local time zone.
+	AllDay bool
+}
+
+// HasDue returns true if the task has a due date.
+func (t Task) HasDue() bool {
+	return !t.Due.IsZero()
+}
+
+// Overdue returns true if the task is past its due date. Tasks due on a date
+// are only overdue once that day is over.
+func (t Task) Overdue() bool {
+	if !t.HasDue() {
+		return false
+	}
+	if t.AllDay {
+		return t.Due.Before(today())
+	}
+	return t.Due.Before(timing.Now())
+}
+
+// DueToday returns true if the task is due later today.
+func (t Task) DueToday() bool {
+	if !t.HasDue() || t.Overdue() {
+		return false
+	}
+	return t.Due.Before(today().AddDate(0, 0, 1))
+}
+
+func today() time.Time {
+	y, m, d := timing.Now().In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// Info represents the pending tasks.
+type Info struct {
+	// Tasks are the pending tasks, ordered by priority (highest first), then
+	// by due date (earliest first, with tasks without a due date last).
+	Tasks []Task
+}
+
+// Overdue returns the number of overdue tasks.
+func (i Info) Overdue() int {
+	count := 0
+	for _, t := range i.Tasks {
+		if t.Overdue() {
+			count++
+		}
+	}
+	return count
+}
+
+// DueToday returns the number of tasks due later today.
+func (i Info) DueToday() int {
+	count := 0
+	for _, t := range i.Tasks {
+		if t.DueToday() {
+			count++
+		}
+	}
+	return count
+}
+
+// Top returns the highest priority task, and false if there are no tasks.
+func (i Info) Top() (Task, bool) {
+	if len(i.Tasks) == 0 {
+		return Task{}, false
+	}
+	return i.Tasks[0], true
+}
+
+// Provider is an interface for task providers, implemented by the various
+// provider packages.
+type Provider interface {
+	// Tasks returns the pending tasks.
+	Tasks() ([]Task, error)
+}
+
+// Module represents a tasks bar module.
+type Module struct {
+	provider   Provider
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a tasks module that shows tasks from the given provider.
+func New(provider Provider) *Module {
+	m := &Module{provider: provider, scheduler: timing.NewScheduler().Named("tasks")}
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the number of overdue and due tasks, along with the
+	// highest priority task, marked urgent if any tasks are overdue.
+	m.Output(func(i Info) bar.Output {
+		top, ok := i.Top()
+		if !ok {
+			return nil
+		}
+		if overdue := i.Overdue(); overdue > 0 {
+			return outputs.Textf("%d overdue, %d today: %s",
+				overdue, i.DueToday(), top.Title).Urgent(true)
+		}
+		if today := i.DueToday(); today > 0 {
+			return outputs.Textf("%d today: %s", today, top.Title)
+		}
+		return outputs.Text(top.Title)
+	})
+	m.RefreshInterval(5 * time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency for tasks.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.fetch()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.fetch()
+		}
+	}
+}
+
+func (m *Module) fetch() (Info, error) {
+	tasks, err := m.provider.Tasks()
+	if err != nil {
+		return Info{}, err
+	}
+	sort.SliceStable(tasks, func(a, b int) bool {
+		ta, tb := tasks[a], tasks[b]
+		if ta.Priority != tb.Priority {
+			return ta.Priority > tb.Priority
+		}
+		if ta.HasDue() != tb.HasDue() {
+			return ta.HasDue()
+		}
+		return ta.Due.Before(tb.Due)
+	})
+	return Info{Tasks: tasks}, nil
+}
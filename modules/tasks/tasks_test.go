@@ -0,0 +1,142 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sync.Mutex
+	tasks []Task
+	err   error
+}
+
+func (t *testProvider) Tasks() ([]Task, error) {
+	t.Lock()
+	defer t.Unlock()
+	return append([]Task(nil), t.tasks...), t.err
+}
+
+func (t *testProvider) set(err error, tasks ...Task) {
+	t.Lock()
+	defer t.Unlock()
+	t.tasks, t.err = tasks, err
+}
+
+func day(offset int) time.Time {
+	y, m, d := timing.Now().Date()
+	return time.Date(y, m, d+offset, 0, 0, 0, 0, time.Local)
+}
+
+func TestTask(t *testing.T) {
+	timing.TestMode()
+	timing.AdvanceTo(time.Date(2018, time.March, 1, 12, 0, 0, 0, time.Local))
+
+	for _, tc := range []struct {
+		desc     string
+		task     Task
+		overdue  bool
+		dueToday bool
+	}{
+		{"no due date", Task{}, false, false},
+		{"due today", Task{Due: day(0), AllDay: true}, false, true},
+		{"due tomorrow", Task{Due: day(1), AllDay: true}, false, false},
+		{"due yesterday", Task{Due: day(-1), AllDay: true}, true, false},
+		{"due this morning", Task{Due: day(0).Add(9 * time.Hour)}, true, false},
+		{"due this evening", Task{Due: day(0).Add(18 * time.Hour)}, false, true},
+		{"due tomorrow morning", Task{Due: day(1).Add(9 * time.Hour)}, false, false},
+	} {
+		require.Equal(t, tc.overdue, tc.task.Overdue(), "Overdue: %s", tc.desc)
+		require.Equal(t, tc.dueToday, tc.task.DueToday(), "DueToday: %s", tc.desc)
+	}
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(time.Date(2018, time.March, 1, 12, 0, 0, 0, time.Local))
+	p := &testProvider{}
+	p.set(nil,
+		Task{Title: "Someday"},
+		Task{Title: "Taxes", Priority: PriorityHigh, Due: day(7), AllDay: true},
+		Task{Title: "Groceries", Due: day(0), AllDay: true},
+		Task{Title: "Review", Priority: PriorityHigh, Due: day(0).Add(15 * time.Hour)},
+	)
+	m := New(p).RefreshInterval(24 * time.Hour).Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, t := range i.Tasks {
+			out.Append(outputs.Text(t.Title))
+		}
+		return out
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText(
+		[]string{"Review", "Taxes", "Groceries", "Someday"},
+		"sorted by priority, then due date")
+
+	outputFunc := func(i Info) bar.Output {
+		top, _ := i.Top()
+		return outputs.Textf("%d/%d %s", i.Overdue(), i.DueToday(), top.Title)
+	}
+	m.Output(outputFunc)
+	testBar.NextOutput("on output change").AssertText([]string{"0/2 Review"})
+
+	timing.AdvanceTo(day(0).Add(16 * time.Hour))
+	m.Output(outputFunc)
+	testBar.NextOutput("after due time").AssertText([]string{"1/1 Review"})
+
+	p.set(errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(time.Date(2018, time.March, 1, 12, 0, 0, 0, time.Local))
+	p := &testProvider{}
+	testBar.Run(New(p))
+	testBar.NextOutput("on start").AssertEmpty("no tasks")
+
+	p.set(nil, Task{Title: "Someday"})
+	testBar.Tick()
+	testBar.NextOutput("no due tasks").AssertText([]string{"Someday"})
+
+	p.set(nil,
+		Task{Title: "Someday"},
+		Task{Title: "Groceries", Priority: PriorityLow, Due: day(0), AllDay: true},
+	)
+	testBar.Tick()
+	testBar.NextOutput("due today").AssertText([]string{"1 today: Groceries"})
+
+	p.set(nil,
+		Task{Title: "Groceries", Due: day(0), AllDay: true},
+		Task{Title: "Taxes", Priority: PriorityMedium, Due: day(-1), AllDay: true},
+	)
+	testBar.Tick()
+	out := testBar.NextOutput("overdue")
+	out.AssertText([]string{"1 overdue, 1 today: Taxes"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+}
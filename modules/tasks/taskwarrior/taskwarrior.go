@@ -0,0 +1,213 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package taskwarrior provides pending tasks from Taskwarrior
+(https://taskwarrior.org).
+
+By default, tasks are read using "task export", which respects the user's
+configuration (e.g. data.location). Alternatively, the pending.data file can be
+read directly, avoiding the cost of running task, which also runs hooks and may
+trigger a sync. Only the file format used by Taskwarrior 2.x is supported.
+*/
+package taskwarrior // import "barista.run/modules/tasks/taskwarrior"
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/modules/tasks"
+
+	"github.com/spf13/afero"
+)
+
+// Provider provides tasks from Taskwarrior.
+type Provider struct {
+	filter  []string
+	dataDir string
+}
+
+// New creates a provider that runs "task export" with the given filter,
+// e.g. "project:work". Only pending tasks are included.
+func New(filter ...string) *Provider {
+	return &Provider{filter: filter}
+}
+
+// DataDir creates a provider that reads pending tasks directly from the
+// Taskwarrior data directory, usually ~/.task.
+func DataDir(dir string) *Provider {
+	return &Provider{dataDir: dir}
+}
+
+// command runs a command and returns its output. It can be replaced in tests.
+var command = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+var fs = afero.NewOsFs()
+
+// Tasks implements tasks.Provider.
+func (p *Provider) Tasks() ([]tasks.Task, error) {
+	if p.dataDir != "" {
+		return p.readData()
+	}
+	args := append([]string{"rc.hooks=off", "status:pending"}, p.filter...)
+	out, err := command("task", append(args, "export")...)
+	if err != nil {
+		return nil, err
+	}
+	var exported []map[string]interface{}
+	if err := json.Unmarshal(out, &exported); err != nil {
+		return nil, err
+	}
+	result := []tasks.Task{}
+	for _, t := range exported {
+		attrs := map[string]string{}
+		for k, v := range t {
+			if s, ok := v.(string); ok {
+				attrs[k] = s
+			}
+		}
+		task, err := makeTask(attrs, parseExportTime)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, task)
+	}
+	return result, nil
+}
+
+// readData reads pending tasks from pending.data, where each line holds the
+// attributes of a task, e.g. [description:"Pay taxes" due:"1520000000"].
+func (p *Provider) readData() ([]tasks.Task, error) {
+	f, err := fs.Open(filepath.Join(p.dataDir, "pending.data"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	result := []tasks.Task{}
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		attrs, err := parseLine(s.Text())
+		if err != nil {
+			return nil, err
+		}
+		if attrs == nil || attrs["status"] != "pending" {
+			continue
+		}
+		task, err := makeTask(attrs, parseEpoch)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, task)
+	}
+	return result, s.Err()
+}
+
+// parseLine parses a line from a Taskwarrior 2.x data file.
+func parseLine(line string) (map[string]string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return nil, fmt.Errorf("taskwarrior: malformed line %q", line)
+	}
+	line = line[1 : len(line)-1]
+	attrs := map[string]string{}
+	for line != "" {
+		colon := strings.Index(line, `:"`)
+		if colon < 0 {
+			return nil, fmt.Errorf("taskwarrior: malformed attribute %q", line)
+		}
+		key := strings.TrimSpace(line[:colon])
+		rest := line[colon+2:]
+		// Values end at the first unescaped quote.
+		end := -1
+		for i := 0; i < len(rest); i++ {
+			if rest[i] == '\\' {
+				i++
+				continue
+			}
+			if rest[i] == '"' {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("taskwarrior: unterminated value for %q", key)
+		}
+		attrs[key] = unescape(rest[:end])
+		line = strings.TrimSpace(rest[end+1:])
+	}
+	return attrs, nil
+}
+
+var unescaper = strings.NewReplacer(
+	`\"`, `"`,
+	`\\`, `\`,
+	"&open;", "[",
+	"&close;", "]",
+	"&dquot;", `"`,
+)
+
+func unescape(value string) string {
+	return unescaper.Replace(value)
+}
+
+func parseEpoch(value string) (time.Time, error) {
+	secs, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
+
+func parseExportTime(value string) (time.Time, error) {
+	return time.Parse("20060102T150405Z", value)
+}
+
+func makeTask(attrs map[string]string, parseTime func(string) (time.Time, error)) (tasks.Task, error) {
+	t := tasks.Task{
+		Title:   attrs["description"],
+		Project: attrs["project"],
+	}
+	switch attrs["priority"] {
+	case "H":
+		t.Priority = tasks.PriorityHigh
+	case "M":
+		t.Priority = tasks.PriorityMedium
+	case "L":
+		t.Priority = tasks.PriorityLow
+	}
+	if due, ok := attrs["due"]; ok {
+		var err error
+		if t.Due, err = parseTime(due); err != nil {
+			return t, err
+		}
+		t.Due = t.Due.In(time.Local)
+		// Taskwarrior stores dates without a time (e.g. due:today) as
+		// local midnight.
+		h, m, s := t.Due.Clock()
+		t.AllDay = h == 0 && m == 0 && s == 0
+	}
+	return t, nil
+}
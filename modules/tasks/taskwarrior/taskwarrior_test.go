@@ -0,0 +1,139 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskwarrior
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/modules/tasks"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+const export = `[
+{"id":1,"description":"Pay taxes","due":"%s","entry":"20180201T093000Z","priority":"H","project":"home","status":"pending","uuid":"5a1a4b7e","urgency":14.2},
+{"id":2,"description":"Review \"design\" doc","due":"%s","entry":"20180201T093000Z","status":"pending","tags":["work"],"uuid":"9c0d2e11","urgency":8.9},
+{"id":3,"description":"Learn Go","entry":"20180201T093000Z","priority":"L","status":"pending","uuid":"f1e2d3c4","urgency":1.8}
+]`
+
+// Taskwarrior exports times in UTC, but dates are local midnight.
+var due = time.Date(2018, time.March, 1, 0, 0, 0, 0, time.Local)
+
+func exportTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func TestExport(t *testing.T) {
+	var args []string
+	command = func(name string, a ...string) ([]byte, error) {
+		args = append([]string{name}, a...)
+		return []byte(fmt.Sprintf(export,
+			exportTime(due), exportTime(due.Add(15*time.Hour)))), nil
+	}
+	result, err := New("project:home").Tasks()
+	require.NoError(t, err)
+	require.Equal(t, "task rc.hooks=off status:pending project:home export",
+		strings.Join(args, " "))
+	require.Equal(t, []tasks.Task{
+		{
+			Title:    "Pay taxes",
+			Project:  "home",
+			Priority: tasks.PriorityHigh,
+			Due:      due,
+			AllDay:   true,
+		},
+		{
+			Title: `Review "design" doc`,
+			Due:   due.Add(15 * time.Hour),
+		},
+		{
+			Title:    "Learn Go",
+			Priority: tasks.PriorityLow,
+		},
+	}, result)
+
+	command = func(string, ...string) ([]byte, error) {
+		return []byte(`not json`), nil
+	}
+	_, err = New().Tasks()
+	require.Error(t, err, "bad json")
+
+	command = func(string, ...string) ([]byte, error) {
+		return []byte(`[{"description":"Bad due","due":"tomorrow"}]`), nil
+	}
+	_, err = New().Tasks()
+	require.Error(t, err, "bad due date")
+
+	command = func(string, ...string) ([]byte, error) {
+		return nil, errors.New("task: command not found")
+	}
+	_, err = New().Tasks()
+	require.Error(t, err, "command error")
+}
+
+func TestDataDir(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	_, err := DataDir("/home/me/.task").Tasks()
+	require.Error(t, err, "missing data file")
+
+	afero.WriteFile(fs, "/home/me/.task/pending.data", []byte(strings.Join([]string{
+		`[description:"Pay taxes" due:"` + fmtEpoch(due) + `" entry:"1517477400" priority:"H" project:"home" status:"pending" uuid:"5a1a4b7e"]`,
+		`[description:"Review &open;draft&close; &dquot;design&dquot; doc" due:"` + fmtEpoch(due.Add(15*time.Hour)) + `" entry:"1517477400" status:"pending" uuid:"9c0d2e11"]`,
+		``,
+		`[description:"Already done" end:"1517477400" entry:"1517477400" status:"completed" uuid:"0b0e7f00"]`,
+		`[description:"Escaped \"quotes\"" entry:"1517477400" priority:"M" status:"pending" uuid:"f1e2d3c4"]`,
+	}, "\n")), 0644)
+
+	result, err := DataDir("/home/me/.task").Tasks()
+	require.NoError(t, err)
+	require.Equal(t, []tasks.Task{
+		{
+			Title:    "Pay taxes",
+			Project:  "home",
+			Priority: tasks.PriorityHigh,
+			Due:      due,
+			AllDay:   true,
+		},
+		{
+			Title: `Review [draft] "design" doc`,
+			Due:   due.Add(15 * time.Hour),
+		},
+		{
+			Title:    `Escaped "quotes"`,
+			Priority: tasks.PriorityMedium,
+		},
+	}, result)
+
+	for _, line := range []string{
+		`description:"No brackets"`,
+		`[description "no colon"]`,
+		`[description:"unterminated]`,
+		`[description:"Bad due" due:"tomorrow" status:"pending"]`,
+	} {
+		afero.WriteFile(fs, "/home/me/.task/pending.data", []byte(line), 0644)
+		_, err = DataDir("/home/me/.task").Tasks()
+		require.Error(t, err, line)
+	}
+}
+
+func fmtEpoch(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
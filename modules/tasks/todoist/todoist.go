@@ -0,0 +1,157 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package todoist provides active tasks from Todoist, using the REST API at
+https://developer.todoist.com/rest/v2.
+
+The API token can be found in the Todoist settings, under Integrations.
+*/
+package todoist // import "barista.run/modules/tasks/todoist"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/tasks"
+)
+
+// Provider provides tasks from Todoist.
+type Provider struct {
+	token  string
+	filter string
+}
+
+// New creates a provider for all active tasks, using the given API token.
+func New(token string) *Provider {
+	return &Provider{token: token}
+}
+
+// Filter restricts tasks to those matching a Todoist filter query,
+// e.g. "today | overdue" or "#Work".
+func (p *Provider) Filter(filter string) *Provider {
+	p.filter = filter
+	return p
+}
+
+var client = httpclient.Client()
+
+// baseURL can be replaced in tests.
+var baseURL = "https://api.todoist.com/rest/v2"
+
+type todoistTask struct {
+	Content   string `json:"content"`
+	ProjectID string `json:"project_id"`
+	// Priority ranges from 1 (normal) to 4 (urgent, shown as p1 in the UI).
+	Priority int `json:"priority"`
+	Due      *struct {
+		Date     string `json:"date"`
+		Datetime string `json:"datetime"`
+	} `json:"due"`
+}
+
+type todoistProject struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (p *Provider) get(path string, query url.Values, result interface{}) error {
+	u := baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("todoist: %s", res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// Tasks implements tasks.Provider.
+func (p *Provider) Tasks() ([]tasks.Task, error) {
+	query := url.Values{}
+	if p.filter != "" {
+		query.Set("filter", p.filter)
+	}
+	var todoistTasks []todoistTask
+	if err := p.get("/tasks", query, &todoistTasks); err != nil {
+		return nil, err
+	}
+	var projects []todoistProject
+	if err := p.get("/projects", nil, &projects); err != nil {
+		return nil, err
+	}
+	projectNames := map[string]string{}
+	for _, pr := range projects {
+		projectNames[pr.ID] = pr.Name
+	}
+	result := []tasks.Task{}
+	for _, t := range todoistTasks {
+		task := tasks.Task{
+			Title:    t.Content,
+			Project:  projectNames[t.ProjectID],
+			Priority: priority(t.Priority),
+		}
+		if t.Due != nil {
+			var err error
+			if task.Due, task.AllDay, err = parseDue(t.Due.Date, t.Due.Datetime); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, task)
+	}
+	return result, nil
+}
+
+func priority(p int) tasks.Priority {
+	switch p {
+	case 4:
+		return tasks.PriorityHigh
+	case 3:
+		return tasks.PriorityMedium
+	case 2:
+		return tasks.PriorityLow
+	default:
+		return tasks.PriorityNone
+	}
+}
+
+// parseDue parses a due date, which only includes a time for tasks due at a
+// specific time. Times without a time zone are "floating" local times.
+func parseDue(date, datetime string) (time.Time, bool, error) {
+	if datetime == "" {
+		t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(datetime, "Z") {
+		t, err := time.Parse(time.RFC3339Nano, datetime)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", datetime, time.Local)
+	return t, false, err
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package todoist
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/modules/tasks"
+
+	"github.com/stretchr/testify/require"
+)
+
+const tasksResponse = `[
+	{
+		"id": "2995104339",
+		"content": "Buy milk",
+		"project_id": "2203306141",
+		"priority": 1,
+		"due": {"date": "2018-03-01", "is_recurring": false, "string": "today"}
+	},
+	{
+		"id": "2995104340",
+		"content": "Submit report",
+		"project_id": "2203306142",
+		"priority": 4,
+		"due": {
+			"date": "2018-03-01",
+			"datetime": "2018-03-01T15:00:00.000000Z",
+			"string": "today at 4pm",
+			"timezone": "Europe/Berlin"
+		}
+	},
+	{
+		"id": "2995104341",
+		"content": "Call mum",
+		"project_id": "2203306141",
+		"priority": 3,
+		"due": {"date": "2018-03-02", "datetime": "2018-03-02T18:30:00", "string": "tomorrow at 6:30pm"}
+	},
+	{
+		"id": "2995104342",
+		"content": "Learn Go",
+		"project_id": "unknown",
+		"priority": 2
+	}
+]`
+
+const projectsResponse = `[
+	{"id": "2203306141", "name": "Inbox"},
+	{"id": "2203306142", "name": "Work"}
+]`
+
+func TestTasks(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.Header.Get("Authorization") != "Bearer my-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/rest/v2/tasks":
+			fmt.Fprint(w, tasksResponse)
+		case "/rest/v2/projects":
+			fmt.Fprint(w, projectsResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	baseURL = server.URL + "/rest/v2"
+
+	result, err := New("my-token").Filter("today | overdue").Tasks()
+	require.NoError(t, err)
+	require.Equal(t, "today | overdue", requests[0].URL.Query().Get("filter"))
+	require.Equal(t, []tasks.Task{
+		{
+			Title:   "Buy milk",
+			Project: "Inbox",
+			Due:     time.Date(2018, time.March, 1, 0, 0, 0, 0, time.Local),
+			AllDay:  true,
+		},
+		{
+			Title:    "Submit report",
+			Project:  "Work",
+			Priority: tasks.PriorityHigh,
+			Due:      time.Date(2018, time.March, 1, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			Title:    "Call mum",
+			Project:  "Inbox",
+			Priority: tasks.PriorityMedium,
+			Due:      time.Date(2018, time.March, 2, 18, 30, 0, 0, time.Local),
+		},
+		{
+			Title:    "Learn Go",
+			Priority: tasks.PriorityLow,
+		},
+	}, result)
+
+	requests = nil
+	_, err = New("my-token").Tasks()
+	require.NoError(t, err)
+	require.Empty(t, requests[0].URL.RawQuery, "no filter")
+
+	_, err = New("wrong-token").Tasks()
+	require.Error(t, err, "unauthorized")
+
+	baseURL = server.URL + "/not-found"
+	_, err = New("my-token").Tasks()
+	require.Error(t, err, "not found")
+}
+
+func TestParseDue(t *testing.T) {
+	_, _, err := parseDue("tomorrow", "")
+	require.Error(t, err)
+	_, _, err = parseDue("2018-03-01", "2018-03-01 15:00")
+	require.Error(t, err)
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package coingecko provides cryptocurrency prices from CoinGecko, using the
+public API at https://www.coingecko.com/en/api.
+
+Symbols are CoinGecko coin IDs, e.g. "bitcoin" or "ethereum", rather than
+ticker symbols.
+*/
+package coingecko // import "barista.run/modules/ticker/coingecko"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"barista.run/httpclient"
+	"barista.run/modules/ticker"
+)
+
+// Provider provides cryptocurrency prices from CoinGecko.
+type Provider struct {
+	currency string
+}
+
+// New creates a provider for prices in US dollars.
+func New() *Provider {
+	return &Provider{currency: "usd"}
+}
+
+// Currency sets the currency for prices, e.g. "eur" or "btc".
+func (p *Provider) Currency(currency string) *Provider {
+	p.currency = strings.ToLower(currency)
+	return p
+}
+
+var client = httpclient.Client()
+
+// baseURL can be replaced in tests.
+var baseURL = "https://api.coingecko.com/api/v3"
+
+// Quotes implements ticker.Provider.
+func (p *Provider) Quotes(symbols []string) ([]ticker.Quote, error) {
+	query := url.Values{
+		"ids":                 {strings.Join(symbols, ",")},
+		"vs_currencies":       {p.currency},
+		"include_24hr_change": {"true"},
+	}
+	res, err := client.Get(baseURL + "/simple/price?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("coingecko: %s", res.Status)
+	}
+	// e.g. {"bitcoin": {"usd": 42000, "usd_24h_change": 2.5}}
+	var prices map[string]map[string]float64
+	if err := json.NewDecoder(res.Body).Decode(&prices); err != nil {
+		return nil, err
+	}
+	quotes := []ticker.Quote{}
+	for _, s := range symbols {
+		price, ok := prices[s]
+		if !ok {
+			continue
+		}
+		q := ticker.Quote{
+			Symbol:        s,
+			Price:         price[p.currency],
+			Currency:      strings.ToUpper(p.currency),
+			ChangePercent: price[p.currency+"_24h_change"],
+		}
+		// Derive the absolute change from the percentage.
+		q.Change = q.Price - q.Price/(1+q.ChangePercent/100)
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
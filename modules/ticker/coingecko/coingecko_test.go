@@ -0,0 +1,61 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coingecko
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"barista.run/modules/ticker"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotes(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/price" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.RawQuery
+		if r.URL.Query().Get("vs_currencies") != "eur" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, `{
+"ethereum": {"eur": 3000, "eur_24h_change": -4},
+"bitcoin": {"eur": 44000, "eur_24h_change": 10}
+}`)
+	}))
+	defer srv.Close()
+	baseURL = srv.URL
+
+	quotes, err := New().Currency("EUR").Quotes([]string{"bitcoin", "dogecoin", "ethereum"})
+	require.NoError(t, err)
+	require.Equal(t,
+		"ids=bitcoin%2Cdogecoin%2Cethereum&include_24hr_change=true&vs_currencies=eur", query)
+	require.Len(t, quotes, 2, "unknown coins are skipped")
+	require.Equal(t, ticker.Quote{
+		Symbol: "bitcoin", Price: 44000, Currency: "EUR", ChangePercent: 10, Change: 4000,
+	}, quotes[0])
+	require.Equal(t, "ethereum", quotes[1].Symbol)
+	require.InDelta(t, -125, quotes[1].Change, 0.001)
+
+	_, err = New().Quotes([]string{"bitcoin"})
+	require.Error(t, err)
+}
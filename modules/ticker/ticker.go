@@ -0,0 +1,233 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package ticker provides an i3bar module that shows prices of stocks or
+cryptocurrencies, rotating through a list of symbols.
+
+Quotes are fetched using a Provider, implemented by the provider packages:
+  - coingecko: cryptocurrency prices from CoinGecko.
+  - yahoo: stock prices from Yahoo Finance.
+*/
+package ticker // import "barista.run/modules/ticker"
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/colors"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Quote represents the current price of a single symbol.
+type Quote struct {
+	Symbol string
+	// Name is a human readable name for the symbol, if available.
+	Name     string
+	Price    float64
+	Currency string
+	// Change is the change in price over the last day (or trading session).
+	Change float64
+	// ChangePercent is the change as a percentage of the previous price.
+	ChangePercent float64
+}
+
+// Up returns true if the price has increased.
+func (q Quote) Up() bool {
+	return q.Change > 0
+}
+
+// Down returns true if the price has decreased.
+func (q Quote) Down() bool {
+	return q.Change < 0
+}
+
+// Arrow returns an arrow indicating the direction of the price change.
+func (q Quote) Arrow() string {
+	switch {
+	case q.Up():
+		return "▲"
+	case q.Down():
+		return "▼"
+	default:
+		return "▶"
+	}
+}
+
+// Info represents the quotes for all symbols, and the currently selected quote.
+type Info struct {
+	Quotes []Quote
+	// Current is the index of the currently selected quote.
+	Current int
+	rotate  func(int)
+}
+
+// Quote returns the currently selected quote.
+func (i Info) Quote() (Quote, bool) {
+	if len(i.Quotes) == 0 {
+		return Quote{}, false
+	}
+	return i.Quotes[i.Current], true
+}
+
+// Next selects the next quote.
+func (i Info) Next() {
+	if i.rotate != nil {
+		i.rotate(1)
+	}
+}
+
+// Previous selects the previous quote.
+func (i Info) Previous() {
+	if i.rotate != nil {
+		i.rotate(-1)
+	}
+}
+
+// Provider is an interface for quote providers, implemented by the various
+// provider packages.
+type Provider interface {
+	// Quotes returns quotes for the given symbols. Unknown symbols may be
+	// omitted.
+	Quotes(symbols []string) ([]Quote, error)
+}
+
+// Module represents a price ticker bar module.
+type Module struct {
+	provider   Provider
+	symbols    []string
+	scheduler  *timing.Scheduler
+	rotator    *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+
+	mu        sync.Mutex
+	current   int
+	refreshFn func()
+	refreshCh <-chan struct{}
+}
+
+// New creates a ticker module that shows quotes for the given symbols, using
+// the given provider.
+func New(provider Provider, symbols ...string) *Module {
+	m := &Module{
+		provider:  provider,
+		symbols:   symbols,
+		scheduler: timing.NewScheduler().Named("ticker"),
+		rotator:   timing.NewScheduler(),
+	}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler", "rotator")
+	// Default output is the current quote with the daily change, coloured
+	// by direction. Clicking selects the next (left) or previous (right)
+	// symbol.
+	m.Output(func(i Info) bar.Output {
+		q, ok := i.Quote()
+		if !ok {
+			return nil
+		}
+		out := outputs.Textf("%s %.2f %s%.1f%%", q.Symbol, q.Price, q.Arrow(), abs(q.ChangePercent))
+		switch {
+		case q.Up():
+			out.Color(colors.Scheme("good"))
+		case q.Down():
+			out.Color(colors.Scheme("bad"))
+		}
+		return out.OnClick(click.Map{}.Left(i.Next).Right(i.Previous).Handle)
+	})
+	m.RefreshInterval(5 * time.Minute)
+	return m
+}
+
+func abs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency for quotes.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// RotateEvery automatically selects the next quote at the given interval.
+func (m *Module) RotateEvery(interval time.Duration) *Module {
+	m.rotator.Every(interval)
+	return m
+}
+
+func (m *Module) rotate(delta int) {
+	m.mu.Lock()
+	m.current += delta
+	m.mu.Unlock()
+	m.refreshFn()
+}
+
+func (m *Module) makeInfo(quotes []Quote) Info {
+	i := Info{Quotes: quotes, rotate: m.rotate}
+	if len(quotes) == 0 {
+		return i
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.current %= len(quotes)
+	if m.current < 0 {
+		m.current += len(quotes)
+	}
+	i.Current = m.current
+	return i
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	quotes, err := m.provider.Quotes(m.symbols)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(m.makeInfo(quotes)))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			quotes, err = m.provider.Quotes(m.symbols)
+		case <-m.rotator.C:
+			m.mu.Lock()
+			m.current++
+			m.mu.Unlock()
+		case <-m.refreshCh:
+		}
+	}
+}
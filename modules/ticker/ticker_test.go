@@ -0,0 +1,139 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ticker
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sync.Mutex
+	quotes  []Quote
+	err     error
+	symbols []string
+}
+
+func (t *testProvider) Quotes(symbols []string) ([]Quote, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.symbols = symbols
+	return t.quotes, t.err
+}
+
+func (t *testProvider) set(err error, quotes ...Quote) {
+	t.Lock()
+	defer t.Unlock()
+	t.quotes, t.err = quotes, err
+}
+
+func TestQuote(t *testing.T) {
+	require.Equal(t, "▲", Quote{Change: 1}.Arrow())
+	require.Equal(t, "▼", Quote{Change: -0.5}.Arrow())
+	require.Equal(t, "▶", Quote{}.Arrow())
+
+	_, ok := Info{}.Quote()
+	require.False(t, ok)
+	require.NotPanics(t, Info{}.Next, "zero value")
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{}
+	p.set(nil, Quote{Symbol: "A", Price: 1}, Quote{Symbol: "B", Price: 2}, Quote{Symbol: "C", Price: 3})
+	m := New(p, "A", "B", "C").Output(func(i Info) bar.Output {
+		q, _ := i.Quote()
+		return outputs.Textf("%d:%s %.0f", i.Current, q.Symbol, q.Price).
+			OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft {
+					i.Next()
+				} else {
+					i.Previous()
+				}
+			})
+	})
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"0:A 1"})
+	require.Equal(t, []string{"A", "B", "C"}, p.symbols)
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on next")
+	out.AssertText([]string{"1:B 2"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	out = testBar.NextOutput("on previous")
+	out.AssertText([]string{"0:A 1"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	out = testBar.NextOutput("on previous")
+	out.AssertText([]string{"2:C 3"}, "wraps around")
+
+	p.set(nil, Quote{Symbol: "A", Price: 10}, Quote{Symbol: "B", Price: 20})
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"0:A 10"},
+		"wraps when symbols are missing")
+
+	p.set(errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
+
+func TestRotation(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{}
+	p.set(nil, Quote{Symbol: "A"}, Quote{Symbol: "B"})
+	m := New(p, "A", "B").RefreshInterval(time.Hour).RotateEvery(10 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		q, _ := i.Quote()
+		return outputs.Text(q.Symbol)
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"A"})
+	testBar.Tick()
+	testBar.NextOutput("on rotate").AssertText([]string{"B"})
+	testBar.Tick()
+	testBar.NextOutput("on rotate").AssertText([]string{"A"})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{}
+	testBar.Run(New(p, "BTC"))
+	testBar.NextOutput("with no quotes").AssertEmpty()
+
+	p.set(nil,
+		Quote{Symbol: "BTC", Price: 42000.5, Change: 1000, ChangePercent: 2.44},
+		Quote{Symbol: "ETH", Price: 3000, Change: -30, ChangePercent: -0.99},
+	)
+	testBar.Tick()
+	out := testBar.NextOutput("on refresh")
+	out.AssertText([]string{"BTC 42000.50 ▲2.4%"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"ETH 3000.00 ▼1.0%"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	testBar.NextOutput("on right click").AssertText([]string{"BTC 42000.50 ▲2.4%"})
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package yahoo provides stock prices from Yahoo Finance, using the unofficial
+chart API that backs finance.yahoo.com.
+
+Symbols are Yahoo Finance ticker symbols, e.g. "GOOG", "VOD.L", or "^GSPC".
+The change is relative to the previous close.
+*/
+package yahoo // import "barista.run/modules/ticker/yahoo"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"barista.run/httpclient"
+	"barista.run/modules/ticker"
+)
+
+// Provider provides stock prices from Yahoo Finance.
+type Provider struct{}
+
+// New creates a provider for stock prices from Yahoo Finance.
+func New() *Provider {
+	return &Provider{}
+}
+
+var client = httpclient.Client()
+
+// baseURL can be replaced in tests.
+var baseURL = "https://query1.finance.yahoo.com"
+
+type chartMeta struct {
+	Symbol             string  `json:"symbol"`
+	Currency           string  `json:"currency"`
+	ShortName          string  `json:"shortName"`
+	RegularMarketPrice float64 `json:"regularMarketPrice"`
+	ChartPreviousClose float64 `json:"chartPreviousClose"`
+	PreviousClose      float64 `json:"previousClose"`
+}
+
+func quote(symbol string) (ticker.Quote, error) {
+	query := url.Values{"range": {"1d"}, "interval": {"1d"}}
+	req, err := http.NewRequest("GET",
+		baseURL+"/v8/finance/chart/"+url.PathEscape(symbol)+"?"+query.Encode(), nil)
+	if err != nil {
+		return ticker.Quote{}, err
+	}
+	// Requests without a user agent are rejected.
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; barista)")
+	res, err := client.Do(req)
+	if err != nil {
+		return ticker.Quote{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ticker.Quote{}, fmt.Errorf("yahoo: %s: %s", symbol, res.Status)
+	}
+	var resp struct {
+		Chart struct {
+			Result []struct {
+				Meta chartMeta `json:"meta"`
+			} `json:"result"`
+		} `json:"chart"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return ticker.Quote{}, err
+	}
+	if len(resp.Chart.Result) == 0 {
+		return ticker.Quote{}, fmt.Errorf("yahoo: no data for %s", symbol)
+	}
+	meta := resp.Chart.Result[0].Meta
+	prev := meta.PreviousClose
+	if prev == 0 {
+		prev = meta.ChartPreviousClose
+	}
+	q := ticker.Quote{
+		Symbol:   meta.Symbol,
+		Name:     meta.ShortName,
+		Price:    meta.RegularMarketPrice,
+		Currency: meta.Currency,
+	}
+	if prev != 0 {
+		q.Change = q.Price - prev
+		q.ChangePercent = q.Change / prev * 100
+	}
+	return q, nil
+}
+
+// Quotes implements ticker.Provider.
+func (p *Provider) Quotes(symbols []string) ([]ticker.Quote, error) {
+	quotes := []ticker.Quote{}
+	for _, s := range symbols {
+		q, err := quote(s)
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
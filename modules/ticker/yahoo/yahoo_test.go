@@ -0,0 +1,65 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yahoo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"barista.run/modules/ticker"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" || r.URL.Query().Get("range") != "1d" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v8/finance/chart/GOOG":
+			io.WriteString(w, `{"chart": {"result": [{"meta": {
+"symbol": "GOOG", "currency": "USD", "shortName": "Alphabet Inc.",
+"regularMarketPrice": 150, "chartPreviousClose": 120, "previousClose": 125
+}}], "error": null}}`)
+		case "/v8/finance/chart/^GSPC":
+			io.WriteString(w, `{"chart": {"result": [{"meta": {
+"symbol": "^GSPC", "currency": "USD", "regularMarketPrice": 4000, "chartPreviousClose": 4100
+}}], "error": null}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"chart": {"result": null, "error": {"code": "Not Found"}}}`)
+		}
+	}))
+	defer srv.Close()
+	baseURL = srv.URL
+
+	quotes, err := New().Quotes([]string{"GOOG", "^GSPC"})
+	require.NoError(t, err)
+	require.Equal(t, ticker.Quote{
+		Symbol: "GOOG", Name: "Alphabet Inc.", Price: 150, Currency: "USD",
+		Change: 25, ChangePercent: 20,
+	}, quotes[0])
+	require.Equal(t, "^GSPC", quotes[1].Symbol)
+	require.Equal(t, -100.0, quotes[1].Change, "falls back to chart previous close")
+	require.InDelta(t, -2.439, quotes[1].ChangePercent, 0.001)
+
+	_, err = New().Quotes([]string{"GOOG", "NOPE"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "NOPE")
+}
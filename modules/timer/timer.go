@@ -0,0 +1,289 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package timer provides an i3bar module for named countdown timers, e.g. for
+tea or the start of a meeting.
+
+Timers can be started programmatically, or by scrolling to set a duration and
+clicking to start it. To avoid updating the bar every second, the remaining
+time is shown in minutes, with seconds only shown in the last minute.
+*/
+package timer // import "barista.run/modules/timer"
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Timer represents a single countdown.
+type Timer struct {
+	Name string
+	End  time.Time
+	m    *Module
+}
+
+// Remaining returns the time left until the timer ends.
+func (t Timer) Remaining() time.Duration {
+	r := t.End.Sub(timing.Now())
+	if r < 0 {
+		return 0
+	}
+	return r
+}
+
+// Done returns true if the timer has ended.
+func (t Timer) Done() bool {
+	return !timing.Now().Before(t.End)
+}
+
+// Cancel removes the timer, whether or not it has ended.
+func (t Timer) Cancel() {
+	if t.m != nil {
+		t.m.Cancel(t.Name)
+	}
+}
+
+// Info represents all timers, and the duration for a new timer.
+type Info struct {
+	// Timers are all active and ended timers, ordered by end time.
+	Timers []Timer
+	// Pending is the duration that will be used for a timer started using
+	// Start, as adjusted by Increase and Decrease.
+	Pending time.Duration
+	m       *Module
+}
+
+// Done returns the timers that have ended.
+func (i Info) Done() []Timer {
+	var done []Timer
+	for _, t := range i.Timers {
+		if t.Done() {
+			done = append(done, t)
+		}
+	}
+	return done
+}
+
+// Increase increases the pending duration by the configured step.
+func (i Info) Increase() {
+	if i.m != nil {
+		i.m.adjust(1)
+	}
+}
+
+// Decrease decreases the pending duration by the configured step.
+func (i Info) Decrease() {
+	if i.m != nil {
+		i.m.adjust(-1)
+	}
+}
+
+// Start starts a timer for the pending duration, and resets the pending
+// duration to zero.
+func (i Info) Start() {
+	if i.m != nil {
+		i.m.startPending()
+	}
+}
+
+// Format formats the remaining time of a timer: in minutes, rounded up, if
+// more than a minute is left (e.g. "5m", "1h20m"), and in seconds otherwise.
+func Format(d time.Duration) string {
+	if d <= time.Minute {
+		return fmt.Sprintf("%ds", int((d+time.Second-1)/time.Second))
+	}
+	mins := int((d + time.Minute - 1) / time.Minute)
+	if mins < 60 {
+		return fmt.Sprintf("%dm", mins)
+	}
+	return fmt.Sprintf("%dh%02dm", mins/60, mins%60)
+}
+
+// Module represents a countdown timer bar module.
+type Module struct {
+	mu      sync.Mutex
+	timers  map[string]time.Time
+	pending time.Duration
+	step    time.Duration
+	count   int
+
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	updateFn   func()
+	updateCh   <-chan struct{}
+}
+
+// New creates a timer module with no timers.
+func New() *Module {
+	m := &Module{
+		timers:    map[string]time.Time{},
+		step:      time.Minute,
+		scheduler: timing.NewScheduler(),
+	}
+	m.updateFn, m.updateCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is a segment for each timer, which is urgent once the
+	// timer has ended, and is cancelled or dismissed on click. The last
+	// segment shows the pending duration, which can be adjusted by
+	// scrolling, and starts a new timer on click.
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, t := range i.Timers {
+			if t.Done() {
+				out.Append(outputs.Textf("%s done", t.Name).
+					Urgent(true).OnClick(click.Left(t.Cancel)))
+			} else {
+				out.Append(outputs.Textf("%s %s", t.Name, Format(t.Remaining())).
+					OnClick(click.Left(t.Cancel)))
+			}
+		}
+		pending := outputs.Text("⏲")
+		if i.Pending > 0 {
+			pending = outputs.Textf("⏲ %s", Format(i.Pending))
+		}
+		out.Append(pending.OnClick(click.Map{}.
+			Left(i.Start).
+			ScrollUp(i.Increase).
+			ScrollDown(i.Decrease).
+			Handle))
+		return out
+	})
+	return m
+}
+
+// Step sets the amount by which scrolling adjusts the pending duration.
+func (m *Module) Step(step time.Duration) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.step = step
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Start starts a named timer for the given duration, replacing any existing
+// timer with the same name.
+func (m *Module) Start(name string, duration time.Duration) {
+	m.mu.Lock()
+	m.timers[name] = timing.Now().Add(duration)
+	m.mu.Unlock()
+	m.updateFn()
+}
+
+// Cancel removes the named timer, if it exists.
+func (m *Module) Cancel(name string) {
+	m.mu.Lock()
+	delete(m.timers, name)
+	m.mu.Unlock()
+	m.updateFn()
+}
+
+func (m *Module) adjust(steps int) {
+	m.mu.Lock()
+	m.pending += time.Duration(steps) * m.step
+	if m.pending < 0 {
+		m.pending = 0
+	}
+	m.mu.Unlock()
+	m.updateFn()
+}
+
+func (m *Module) startPending() {
+	m.mu.Lock()
+	d := m.pending
+	if d <= 0 {
+		m.mu.Unlock()
+		return
+	}
+	m.pending = 0
+	m.count++
+	name := fmt.Sprintf("timer %d", m.count)
+	m.mu.Unlock()
+	m.Start(name, d)
+}
+
+// nextChange returns the time at which the formatted remaining time of a
+// timer with the given end time will next change.
+func nextChange(now, end time.Time) time.Time {
+	r := end.Sub(now)
+	unit := time.Minute
+	if r <= time.Minute {
+		unit = time.Second
+	}
+	// The remaining time is rounded up, so it changes when it crosses the
+	// next multiple of the unit below it.
+	return end.Add(-((r - 1) / unit) * unit)
+}
+
+func (m *Module) info() (Info, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := timing.Now()
+	i := Info{Pending: m.pending, m: m}
+	var next time.Time
+	for name, end := range m.timers {
+		i.Timers = append(i.Timers, Timer{Name: name, End: end, m: m})
+		if !end.After(now) {
+			continue
+		}
+		if n := nextChange(now, end); next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	sort.Slice(i.Timers, func(a, b int) bool {
+		if i.Timers[a].End.Equal(i.Timers[b].End) {
+			return i.Timers[a].Name < i.Timers[b].Name
+		}
+		return i.Timers[a].End.Before(i.Timers[b].End)
+	})
+	return i, next
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	defer m.scheduler.Stop()
+	for {
+		info, next := m.info()
+		if next.IsZero() {
+			m.scheduler.Stop()
+		} else {
+			m.scheduler.At(next)
+		}
+		s.Output(outputFunc(info))
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+		case <-m.updateCh:
+		}
+	}
+}
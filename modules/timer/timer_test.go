@@ -0,0 +1,153 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timer
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "0s"},
+		{300 * time.Millisecond, "1s"},
+		{42 * time.Second, "42s"},
+		{time.Minute, "60s"},
+		{time.Minute + time.Millisecond, "2m"},
+		{5 * time.Minute, "5m"},
+		{59*time.Minute + time.Second, "1h00m"},
+		{80 * time.Minute, "1h20m"},
+	} {
+		require.Equal(t, tc.expected, Format(tc.d), "Format(%v)", tc.d)
+	}
+}
+
+func TestNextChange(t *testing.T) {
+	now := time.Date(2018, 4, 1, 9, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		remaining time.Duration
+		expected  time.Duration
+	}{
+		{5 * time.Minute, time.Minute},
+		{4*time.Minute + 30*time.Second, 30 * time.Second},
+		{90 * time.Second, 30 * time.Second},
+		{time.Minute, time.Second},
+		{1500 * time.Millisecond, 500 * time.Millisecond},
+		{time.Second, time.Second},
+	} {
+		require.Equal(t, now.Add(tc.expected),
+			nextChange(now, now.Add(tc.remaining)), "with %v remaining", tc.remaining)
+	}
+}
+
+func TestTimers(t *testing.T) {
+	testBar.New(t)
+	m := New()
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, t := range i.Timers {
+			out.Append(outputs.Textf("%s:%s:%v", t.Name, Format(t.Remaining()), t.Done()).
+				OnClick(func(bar.Event) { t.Cancel() }))
+		}
+		return out
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertEmpty()
+
+	start := timing.Now()
+	m.Start("tea", 3*time.Minute)
+	testBar.NextOutput("on timer start").AssertText([]string{"tea:3m:false"})
+	m.Start("meeting", 90*time.Second)
+	testBar.NextOutput("on timer start").AssertText(
+		[]string{"meeting:2m:false", "tea:3m:false"}, "sorted by end time")
+
+	testBar.Tick()
+	require.Equal(t, start.Add(30*time.Second), timing.Now())
+	testBar.NextOutput("on tick").AssertText([]string{"meeting:60s:false", "tea:3m:false"})
+
+	testBar.Tick()
+	require.Equal(t, start.Add(31*time.Second), timing.Now(),
+		"updates every second in the final minute")
+	testBar.NextOutput("on tick").AssertText([]string{"meeting:59s:false", "tea:3m:false"})
+
+	m.Cancel("meeting")
+	testBar.NextOutput("on cancel").AssertText([]string{"tea:3m:false"})
+
+	testBar.Tick()
+	require.Equal(t, start.Add(time.Minute), timing.Now(),
+		"only updates every minute otherwise")
+	testBar.NextOutput("on tick").AssertText([]string{"tea:2m:false"})
+
+	timing.AdvanceTo(start.Add(3*time.Minute - time.Second))
+	testBar.LatestOutput().AssertText([]string{"tea:1s:false"})
+	testBar.Tick()
+	out := testBar.NextOutput("on end")
+	out.AssertText([]string{"tea:0s:true"})
+
+	m.Start("tea", time.Hour)
+	out = testBar.NextOutput("on restart")
+	out.AssertText([]string{"tea:1h00m:false"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertEmpty()
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	m := New().Step(5 * time.Minute)
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"⏲"})
+
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("click without duration")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"⏲"}, "pending duration is not negative")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"⏲ 5m"})
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"⏲ 10m"})
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"⏲ 5m"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on start")
+	out.AssertText([]string{"timer 1 5m", "⏲"})
+
+	timing.AdvanceBy(5 * time.Minute)
+	out = testBar.LatestOutput()
+	out.AssertText([]string{"timer 1 done", "⏲"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on dismiss").AssertText([]string{"⏲"})
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package csvfile records time entries in a local CSV file, with one row per
+completed entry: the task, the start and end times (RFC 3339), and the
+duration in seconds. A header row is written when the file is created.
+*/
+package csvfile // import "barista.run/modules/timetrack/csvfile"
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"barista.run/modules/timetrack"
+
+	"github.com/spf13/afero"
+)
+
+// Backend appends completed time entries to a CSV file.
+type Backend struct {
+	path string
+	mu   sync.Mutex
+}
+
+// New creates a backend that appends entries to the CSV file at the given
+// path, which is created if it does not exist.
+func New(path string) *Backend {
+	return &Backend{path: path}
+}
+
+var fs = afero.NewOsFs()
+
+// Start implements timetrack.Backend. Entries are only written when stopped.
+func (b *Backend) Start(timetrack.Entry) error {
+	return nil
+}
+
+// Stop implements timetrack.Backend.
+func (b *Backend) Stop(e timetrack.Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := fs.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+	f, err := fs.OpenFile(b.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if stat, err := f.Stat(); err == nil && stat.Size() == 0 {
+		w.Write([]string{"task", "start", "end", "duration"})
+	}
+	w.Write([]string{
+		e.Task,
+		e.Start.Format(time.RFC3339),
+		e.End.Format(time.RFC3339),
+		strconv.Itoa(int(e.Duration() / time.Second)),
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,52 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csvfile
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/modules/timetrack"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	b := New("/home/user/time/log.csv")
+	start := time.Date(2018, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	require.NoError(t, b.Start(timetrack.Entry{Task: "ignored", Start: start}))
+	_, err := fs.Stat("/home/user/time/log.csv")
+	require.Error(t, err, "nothing is written on start")
+
+	require.NoError(t, b.Stop(timetrack.Entry{
+		Task: "Writing", Start: start, End: start.Add(90 * time.Minute),
+	}))
+	require.NoError(t, b.Stop(timetrack.Entry{
+		Task: "Review, part 2", Start: start.Add(2 * time.Hour), End: start.Add(2*time.Hour + 42*time.Second),
+	}))
+
+	data, err := afero.ReadFile(fs, "/home/user/time/log.csv")
+	require.NoError(t, err)
+	require.Equal(t, `task,start,end,duration
+Writing,2018-04-01T09:00:00Z,2018-04-01T10:30:00Z,5400
+"Review, part 2",2018-04-01T11:00:00Z,2018-04-01T11:00:42Z,42
+`, string(data))
+
+	fs = afero.NewReadOnlyFs(afero.NewMemMapFs())
+	require.Error(t, b.Stop(timetrack.Entry{Task: "x", Start: start, End: start}))
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package timetrack provides an i3bar module that tracks time spent on a task,
+started and stopped by clicking, and shows the elapsed time on the current
+task (or a reminder that nothing is being tracked).
+
+Time entries are reported to one or more Backends, implemented by the backend
+packages:
+  - toggl: Toggl Track, using an API token.
+  - csvfile: a local CSV file.
+*/
+package timetrack // import "barista.run/modules/timetrack"
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/i18n"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Entry represents a single time entry.
+type Entry struct {
+	Task  string
+	Start time.Time
+	// End is zero while the entry is still running.
+	End time.Time
+}
+
+// Duration returns the duration of a stopped entry.
+func (e Entry) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// Backend is an interface for time tracking backends, implemented by the
+// various backend packages.
+type Backend interface {
+	// Start is called when tracking starts for an entry.
+	Start(Entry) error
+	// Stop is called with the completed entry when tracking stops.
+	Stop(Entry) error
+}
+
+// Info represents the current state of the time tracker.
+type Info struct {
+	// Task is the task being tracked, or the task that will be tracked
+	// when started.
+	Task    string
+	Running bool
+	// Since is when tracking started, only set while running.
+	Since time.Time
+	// Err is the error from the last backend that failed to start or stop
+	// an entry, if any. Tracking continues regardless.
+	Err error
+	m   *Module
+}
+
+// Elapsed returns the time spent on the current task, or zero if not running.
+func (i Info) Elapsed() time.Duration {
+	if !i.Running {
+		return 0
+	}
+	return timing.Now().Sub(i.Since)
+}
+
+// Start starts tracking the task.
+func (i Info) Start() {
+	if i.m != nil {
+		i.m.start()
+	}
+}
+
+// Stop stops tracking the current task.
+func (i Info) Stop() {
+	if i.m != nil {
+		i.m.stop()
+	}
+}
+
+// Toggle starts tracking if stopped, and stops tracking if running.
+func (i Info) Toggle() {
+	if i.Running {
+		i.Stop()
+	} else {
+		i.Start()
+	}
+}
+
+// Format formats an elapsed duration as hours and minutes, e.g. "1:05".
+func Format(d time.Duration) string {
+	mins := int(d / time.Minute)
+	return fmt.Sprintf("%d:%02d", mins/60, mins%60)
+}
+
+// Module represents a time tracking bar module.
+type Module struct {
+	backends []Backend
+
+	task    value.Value // of string
+	mu      sync.Mutex
+	current *Entry
+	err     error
+
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	updateFn   func()
+	updateCh   <-chan struct{}
+}
+
+// New creates a time tracking module that reports to the given backends.
+func New(backends ...Backend) *Module {
+	m := &Module{backends: backends, scheduler: timing.NewScheduler().Named("timetrack")}
+	m.updateFn, m.updateCh = notifier.New()
+	l.Register(m, "outputFunc", "task", "scheduler")
+	m.task.Set("")
+	// Default output is the task and elapsed time while tracking, and an
+	// urgent reminder otherwise. Clicking starts or stops tracking.
+	m.Output(func(i Info) bar.Output {
+		if !i.Running {
+			return outputs.Text(i18n.Sprintf("Not tracking")).
+				Urgent(true).
+				OnClick(click.Left(i.Start))
+		}
+		text := Format(i.Elapsed())
+		if i.Task != "" {
+			text = i.Task + " " + text
+		}
+		if i.Err != nil {
+			text += " (not saved)"
+		}
+		return outputs.Text(text).
+			Urgent(i.Err != nil).
+			OnClick(click.Left(i.Stop))
+	})
+	return m
+}
+
+// Task sets the task to track. If a task is already being tracked, it is
+// stopped, and tracking continues with the new task.
+func (m *Module) Task(task string) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.task.Set(task)
+	if m.current != nil && m.current.Task != task {
+		m.stopLocked()
+		m.startLocked()
+	}
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+func (m *Module) start() {
+	m.mu.Lock()
+	defer m.updateFn()
+	defer m.mu.Unlock()
+	if m.current == nil {
+		m.startLocked()
+	}
+}
+
+func (m *Module) stop() {
+	m.mu.Lock()
+	defer m.updateFn()
+	defer m.mu.Unlock()
+	if m.current != nil {
+		m.stopLocked()
+	}
+}
+
+func (m *Module) startLocked() {
+	m.current = &Entry{Task: m.task.Get().(string), Start: timing.Now()}
+	m.err = nil
+	for _, b := range m.backends {
+		if err := b.Start(*m.current); err != nil {
+			l.Log("Failed to start %+v: %v", *m.current, err)
+			m.err = err
+		}
+	}
+}
+
+func (m *Module) stopLocked() {
+	e := *m.current
+	e.End = timing.Now()
+	m.current = nil
+	m.err = nil
+	for _, b := range m.backends {
+		if err := b.Stop(e); err != nil {
+			l.Log("Failed to stop %+v: %v", e, err)
+			m.err = err
+		}
+	}
+}
+
+func (m *Module) info() Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := Info{Task: m.task.Get().(string), Err: m.err, m: m}
+	if m.current != nil {
+		i.Task = m.current.Task
+		i.Running = true
+		i.Since = m.current.Start
+	}
+	return i
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextTask, doneTask := m.task.Subscribe()
+	defer doneTask()
+	defer m.scheduler.Stop()
+	for {
+		info := m.info()
+		if info.Running {
+			// Update when the elapsed minutes change.
+			elapsed := info.Elapsed()
+			m.scheduler.After(time.Minute - elapsed%time.Minute)
+		} else {
+			m.scheduler.Stop()
+		}
+		s.Output(outputFunc(info))
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextTask:
+		case <-m.scheduler.C:
+		case <-m.updateCh:
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timetrack
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testBackend struct {
+	sync.Mutex
+	events []string
+	err    error
+}
+
+func (t *testBackend) Start(e Entry) error {
+	t.Lock()
+	defer t.Unlock()
+	t.events = append(t.events, "start "+e.Task)
+	return t.err
+}
+
+func (t *testBackend) Stop(e Entry) error {
+	t.Lock()
+	defer t.Unlock()
+	t.events = append(t.events, fmt.Sprintf("stop %s %v", e.Task, e.Duration()))
+	return t.err
+}
+
+func (t *testBackend) takeEvents() []string {
+	t.Lock()
+	defer t.Unlock()
+	e := t.events
+	t.events = nil
+	return e
+}
+
+func (t *testBackend) setError(err error) {
+	t.Lock()
+	defer t.Unlock()
+	t.err = err
+}
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, "0:00", Format(59*time.Second))
+	require.Equal(t, "1:05", Format(65*time.Minute+30*time.Second))
+	require.Equal(t, "26:00", Format(26*time.Hour))
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	a, b := &testBackend{}, &testBackend{}
+	m := New(a, b).Task("Writing").Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %v %s %v", i.Task, i.Running, Format(i.Elapsed()), i.Err).
+			OnClick(func(bar.Event) { i.Toggle() })
+	})
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"Writing false 0:00 <nil>"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{"Writing true 0:00 <nil>"})
+	require.Equal(t, []string{"start Writing"}, a.takeEvents())
+	require.Equal(t, []string{"start Writing"}, b.takeEvents())
+
+	timing.AdvanceBy(30 * time.Second)
+	testBar.AssertNoOutput("within a minute")
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"Writing true 0:01 <nil>"})
+
+	m.Task("Reviewing")
+	out = testBar.NextOutput("on task change")
+	out.AssertText([]string{"Reviewing true 0:00 <nil>"})
+	require.Equal(t, []string{"stop Writing 1m0s", "start Reviewing"}, a.takeEvents())
+	b.takeEvents()
+
+	timing.AdvanceBy(5 * time.Minute)
+	testBar.LatestOutput().Expect("on tick")
+
+	b.setError(errors.New("offline"))
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on stop")
+	out.AssertText([]string{"Reviewing false 0:00 offline"})
+	require.Equal(t, []string{"stop Reviewing 5m0s"}, a.takeEvents())
+
+	b.setError(nil)
+	out.At(0).LeftClick()
+	testBar.NextOutput("on start").AssertText([]string{"Reviewing true 0:00 <nil>"},
+		"error is cleared")
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	backend := &testBackend{}
+	m := New(backend)
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"Not tracking"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"0:00"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	m.Task("Tests")
+	testBar.NextOutput("on task change").AssertText([]string{"Tests 0:00"})
+
+	backend.setError(errors.New("foo"))
+	m.Task("Docs")
+	out = testBar.NextOutput("on task change")
+	out.AssertText([]string{"Docs 0:00 (not saved)"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on stop").AssertText([]string{"Not tracking"})
+}
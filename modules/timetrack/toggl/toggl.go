@@ -0,0 +1,172 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package toggl reports time entries to Toggl Track, using the API at
+https://developers.track.toggl.com/docs.
+
+The API token can be found in the Toggl Track profile settings. Entries are
+created in the user's default workspace, unless a workspace is set.
+*/
+package toggl // import "barista.run/modules/timetrack/toggl"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/timetrack"
+)
+
+// Backend creates running time entries in Toggl Track, and stops them.
+type Backend struct {
+	token     string
+	workspace int
+	project   int
+	tags      []string
+
+	mu      sync.Mutex
+	current int
+}
+
+// New creates a backend using the given API token.
+func New(token string) *Backend {
+	return &Backend{token: token}
+}
+
+// Workspace sets the workspace for new time entries.
+func (b *Backend) Workspace(id int) *Backend {
+	b.workspace = id
+	return b
+}
+
+// Project sets the project for new time entries.
+func (b *Backend) Project(id int) *Backend {
+	b.project = id
+	return b
+}
+
+// Tags sets the tags for new time entries.
+func (b *Backend) Tags(tags ...string) *Backend {
+	b.tags = tags
+	return b
+}
+
+var client = httpclient.Client()
+
+// baseURL can be replaced in tests.
+var baseURL = "https://api.track.toggl.com/api/v9"
+
+func (b *Backend) do(method, path string, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(b.token, "api_token")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("toggl: %s", res.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// workspaceID returns the configured workspace, or the user's default
+// workspace, which is fetched once.
+func (b *Backend) workspaceID() (int, error) {
+	if b.workspace != 0 {
+		return b.workspace, nil
+	}
+	var me struct {
+		DefaultWorkspaceID int `json:"default_workspace_id"`
+	}
+	if err := b.do("GET", "/me", nil, &me); err != nil {
+		return 0, err
+	}
+	b.workspace = me.DefaultWorkspaceID
+	return b.workspace, nil
+}
+
+type timeEntry struct {
+	Description string   `json:"description"`
+	Start       string   `json:"start"`
+	Duration    int64    `json:"duration"`
+	WorkspaceID int      `json:"workspace_id"`
+	ProjectID   int      `json:"project_id,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	CreatedWith string   `json:"created_with"`
+}
+
+// Start implements timetrack.Backend.
+func (b *Backend) Start(e timetrack.Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wid, err := b.workspaceID()
+	if err != nil {
+		return err
+	}
+	var created struct {
+		ID int `json:"id"`
+	}
+	err = b.do("POST", fmt.Sprintf("/workspaces/%d/time_entries", wid), timeEntry{
+		Description: e.Task,
+		Start:       e.Start.UTC().Format(time.RFC3339),
+		// A negative duration marks the entry as running.
+		Duration:    -1,
+		WorkspaceID: wid,
+		ProjectID:   b.project,
+		Tags:        b.tags,
+		CreatedWith: "barista",
+	}, &created)
+	if err != nil {
+		return err
+	}
+	b.current = created.ID
+	return nil
+}
+
+// Stop implements timetrack.Backend.
+func (b *Backend) Stop(e timetrack.Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.current == 0 {
+		return fmt.Errorf("toggl: no running entry for %q", e.Task)
+	}
+	id := b.current
+	b.current = 0
+	return b.do("PATCH",
+		fmt.Sprintf("/workspaces/%d/time_entries/%d/stop", b.workspace, id), nil, nil)
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toggl
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/modules/timetrack"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend(t *testing.T) {
+	var requests []string
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "token" || pass != "api_token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /me":
+			io.WriteString(w, `{"id": 1, "default_workspace_id": 42}`)
+		case "POST /workspaces/42/time_entries", "POST /workspaces/7/time_entries":
+			b, _ := ioutil.ReadAll(r.Body)
+			body = string(b)
+			io.WriteString(w, `{"id": 1234}`)
+		case "PATCH /workspaces/42/time_entries/1234/stop":
+			io.WriteString(w, `{"id": 1234}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	baseURL = srv.URL
+
+	start := time.Date(2018, 4, 1, 9, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	e := timetrack.Entry{Task: "Writing", Start: start}
+	b := New("token")
+	require.NoError(t, b.Start(e))
+	require.JSONEq(t, `{
+		"description": "Writing",
+		"start": "2018-04-01T07:00:00Z",
+		"duration": -1,
+		"workspace_id": 42,
+		"created_with": "barista"
+	}`, body)
+
+	e.End = start.Add(time.Hour)
+	require.NoError(t, b.Stop(e))
+	require.Error(t, b.Stop(e), "no running entry")
+	require.Equal(t, []string{
+		"GET /me",
+		"POST /workspaces/42/time_entries",
+		"PATCH /workspaces/42/time_entries/1234/stop",
+	}, requests)
+
+	requests = nil
+	b = New("token").Workspace(7).Project(99).Tags("barista", "oss")
+	require.NoError(t, b.Start(e))
+	require.JSONEq(t, `{
+		"description": "Writing",
+		"start": "2018-04-01T07:00:00Z",
+		"duration": -1,
+		"workspace_id": 7,
+		"project_id": 99,
+		"tags": ["barista", "oss"],
+		"created_with": "barista"
+	}`, body)
+	require.Equal(t, []string{"POST /workspaces/7/time_entries"}, requests)
+	require.Error(t, b.Stop(e), "stop error")
+
+	require.Error(t, New("wrong").Start(e))
+}
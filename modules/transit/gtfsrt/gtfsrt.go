@@ -0,0 +1,265 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package gtfsrt provides departures from a GTFS-Realtime trip updates feed
+(https://gtfs.org/realtime/), as published by many transit agencies.
+
+Stops are identified by their GTFS stop_id, and lines by their route_id,
+since the realtime feed does not include route names or headsigns. Names can
+be set for routes using Route.
+
+Only the parts of the protocol buffer needed for departures are decoded, so
+this package does not depend on the generated GTFS-Realtime bindings.
+*/
+package gtfsrt // import "barista.run/modules/transit/gtfsrt"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/transit"
+)
+
+// Provider provides departures from a GTFS-Realtime feed.
+type Provider struct {
+	url     string
+	headers map[string]string
+	routes  map[string]string
+}
+
+// New creates a provider for the trip updates feed at the given URL.
+func New(url string) *Provider {
+	return &Provider{
+		url:     url,
+		headers: map[string]string{},
+		routes:  map[string]string{},
+	}
+}
+
+// Header adds a header to the request, e.g. for an API key.
+func (p *Provider) Header(key, value string) *Provider {
+	p.headers[key] = value
+	return p
+}
+
+// Route sets the display name for a route_id, e.g. Route("1042", "Bus 42").
+func (p *Provider) Route(id, name string) *Provider {
+	p.routes[id] = name
+	return p
+}
+
+var client = httpclient.Client()
+
+// Departures implements transit.Provider.
+func (p *Provider) Departures(stops []string) ([]transit.Departure, error) {
+	req, err := http.NewRequest("GET", p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gtfsrt: %s", res.Status)
+	}
+	feed, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	updates, err := parseFeed(feed)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, s := range stops {
+		wanted[s] = true
+	}
+	var departures []transit.Departure
+	for _, u := range updates {
+		if !wanted[u.stop] || u.time == 0 {
+			continue
+		}
+		line := p.routes[u.route]
+		if line == "" {
+			line = u.route
+		}
+		departures = append(departures, transit.Departure{
+			Line:     line,
+			Stop:     u.stop,
+			Time:     time.Unix(u.time, 0),
+			Delay:    time.Duration(u.delay) * time.Second,
+			Realtime: true,
+		})
+	}
+	return departures, nil
+}
+
+// stopTimeUpdate is a flattened StopTimeUpdate, with the route of its trip.
+type stopTimeUpdate struct {
+	route string
+	stop  string
+	time  int64
+	delay int64
+	// skipped is true if the vehicle will not stop here.
+	skipped bool
+}
+
+// Field numbers from gtfs-realtime.proto.
+const (
+	feedMessageEntity           = 2
+	feedEntityTripUpdate        = 3
+	tripUpdateTrip              = 1
+	tripUpdateStopTimeUpdate    = 2
+	tripDescriptorRouteID       = 5
+	stopTimeUpdateArrival       = 2
+	stopTimeUpdateDeparture     = 3
+	stopTimeUpdateStopID        = 4
+	stopTimeUpdateRelation      = 5
+	stopTimeEventDelay          = 1
+	stopTimeEventTime           = 2
+	scheduleRelationshipSkipped = 1
+)
+
+func parseFeed(b []byte) ([]stopTimeUpdate, error) {
+	var updates []stopTimeUpdate
+	err := eachField(b, func(num int, v uint64, msg []byte) error {
+		if num != feedMessageEntity {
+			return nil
+		}
+		return eachField(msg, func(num int, v uint64, msg []byte) error {
+			if num != feedEntityTripUpdate {
+				return nil
+			}
+			u, err := parseTripUpdate(msg)
+			updates = append(updates, u...)
+			return err
+		})
+	})
+	return updates, err
+}
+
+func parseTripUpdate(b []byte) ([]stopTimeUpdate, error) {
+	var route string
+	var updates []stopTimeUpdate
+	err := eachField(b, func(num int, v uint64, msg []byte) error {
+		switch num {
+		case tripUpdateTrip:
+			return eachField(msg, func(num int, v uint64, msg []byte) error {
+				if num == tripDescriptorRouteID {
+					route = string(msg)
+				}
+				return nil
+			})
+		case tripUpdateStopTimeUpdate:
+			u, err := parseStopTimeUpdate(msg)
+			if !u.skipped {
+				updates = append(updates, u)
+			}
+			return err
+		}
+		return nil
+	})
+	for i := range updates {
+		updates[i].route = route
+	}
+	return updates, err
+}
+
+func parseStopTimeUpdate(b []byte) (stopTimeUpdate, error) {
+	u := stopTimeUpdate{}
+	hasDeparture := false
+	err := eachField(b, func(num int, v uint64, msg []byte) error {
+		switch num {
+		case stopTimeUpdateStopID:
+			u.stop = string(msg)
+		case stopTimeUpdateRelation:
+			u.skipped = v == scheduleRelationshipSkipped
+		case stopTimeUpdateArrival, stopTimeUpdateDeparture:
+			// Prefer the departure time, but fall back to the arrival time,
+			// which is often the only one given for intermediate stops.
+			if hasDeparture {
+				return nil
+			}
+			hasDeparture = num == stopTimeUpdateDeparture
+			return eachField(msg, func(num int, v uint64, msg []byte) error {
+				switch num {
+				case stopTimeEventTime:
+					u.time = int64(v)
+				case stopTimeEventDelay:
+					// delay is an int32, encoded as a sign-extended varint.
+					u.delay = int64(int32(v))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return u, err
+}
+
+var errMalformed = errors.New("gtfsrt: malformed feed")
+
+// eachField calls fn with each field of the protocol buffer message in b.
+// Varint fields are passed as v, and length-delimited fields as msg.
+// Fixed-width fields are skipped since none are needed for departures.
+func eachField(b []byte, fn func(num int, v uint64, msg []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		num := int(key >> 3)
+		var v uint64
+		var msg []byte
+		switch key & 7 {
+		case 0: // varint
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return errMalformed
+			}
+		case 1: // 64-bit
+			n = 8
+		case 2: // length-delimited
+			l, k := binary.Uvarint(b)
+			if k <= 0 || uint64(len(b)-k) < l {
+				return errMalformed
+			}
+			msg = b[k : k+int(l)]
+			n = k + int(l)
+		case 5: // 32-bit
+			n = 4
+		default:
+			return errMalformed
+		}
+		if len(b) < n {
+			return errMalformed
+		}
+		b = b[n:]
+		if err := fn(num, v, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
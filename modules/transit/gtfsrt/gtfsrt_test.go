@@ -0,0 +1,135 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gtfsrt
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/modules/transit"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Helpers to encode protocol buffer messages for tests.
+
+func appendVarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func varint(num int, v uint64) []byte {
+	b := appendVarint(nil, uint64(num<<3))
+	return appendVarint(b, v)
+}
+
+func message(num int, fields ...[]byte) []byte {
+	var msg []byte
+	for _, f := range fields {
+		msg = append(msg, f...)
+	}
+	b := appendVarint(nil, uint64(num<<3|2))
+	b = appendVarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+func str(num int, s string) []byte {
+	b := appendVarint(nil, uint64(num<<3|2))
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func fixed32(num int) []byte {
+	return append(appendVarint(nil, uint64(num<<3|5)), 0, 0, 0, 0)
+}
+
+func tripUpdate(route string, stops ...[]byte) []byte {
+	fields := [][]byte{message(tripUpdateTrip, str(1, "trip"), str(tripDescriptorRouteID, route))}
+	for _, s := range stops {
+		fields = append(fields, s)
+	}
+	return message(feedMessageEntity, str(1, "entity"),
+		message(feedEntityTripUpdate, fields...))
+}
+
+func stopTime(stop string, fields ...[]byte) []byte {
+	return message(tripUpdateStopTimeUpdate,
+		append([][]byte{varint(1, 3), str(stopTimeUpdateStopID, stop)}, fields...)...)
+}
+
+func event(num int, unix int64, delay int32) []byte {
+	return message(num, varint(stopTimeEventDelay, uint64(delay)),
+		varint(stopTimeEventTime, uint64(unix)), fixed32(4))
+}
+
+var feed = append(append(append(
+	message(1, str(1, "2.0"), varint(3, 1488355200)),
+	tripUpdate("1042",
+		stopTime("A", event(stopTimeUpdateArrival, 1488355300, 0), event(stopTimeUpdateDeparture, 1488355320, -60)),
+		stopTime("B", event(stopTimeUpdateArrival, 1488355500, 120)),
+		stopTime("C", event(stopTimeUpdateDeparture, 1488355800, 0)),
+	)...),
+	tripUpdate("M10",
+		stopTime("B", event(stopTimeUpdateDeparture, 1488355900, 0), varint(stopTimeUpdateRelation, 1)),
+		stopTime("C", varint(stopTimeUpdateRelation, 2)),
+	)...),
+	message(feedMessageEntity, str(1, "vehicle"), message(4, str(1, "x")))...)
+
+func TestDepartures(t *testing.T) {
+	var key string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("X-Api-Key")
+		if r.URL.Path != "/tripupdates.pb" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(feed)
+	}))
+	defer srv.Close()
+
+	p := New(srv.URL+"/tripupdates.pb").Header("X-Api-Key", "secret").Route("1042", "Bus 42")
+	deps, err := p.Departures([]string{"A", "B"})
+	require.NoError(t, err)
+	require.Equal(t, "secret", key)
+	require.Equal(t, []transit.Departure{
+		{Line: "Bus 42", Stop: "A", Time: time.Unix(1488355320, 0), Delay: -time.Minute, Realtime: true},
+		{Line: "Bus 42", Stop: "B", Time: time.Unix(1488355500, 0), Delay: 2 * time.Minute, Realtime: true},
+	}, deps, "skipped stops are excluded")
+
+	deps, err = p.Departures([]string{"C"})
+	require.NoError(t, err)
+	require.Equal(t, []transit.Departure{
+		{Line: "Bus 42", Stop: "C", Time: time.Unix(1488355800, 0), Realtime: true},
+	}, deps, "stops without times are excluded")
+
+	_, err = New(srv.URL + "/other.pb").Departures([]string{"A"})
+	require.Error(t, err)
+}
+
+func TestMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		{0x12},
+		{0x12, 0x05, 0x01},
+		{0x0b},
+		{0x08, 0xff},
+		{0x09, 0x01},
+	} {
+		_, err := parseFeed(b)
+		require.Error(t, err, "% x", b)
+	}
+}
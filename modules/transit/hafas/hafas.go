@@ -0,0 +1,123 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hafas provides departures from an instance of hafas-rest-api
+// (https://github.com/public-transport/hafas-rest-api), which exposes the
+// HAFAS systems used by many European operators, e.g.
+// https://v6.db.transport.rest for Deutsche Bahn.
+package hafas // import "barista.run/modules/transit/hafas"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/transit"
+)
+
+// Provider provides departures from a hafas-rest-api endpoint.
+type Provider struct {
+	endpoint string
+	window   time.Duration
+}
+
+// New creates a provider for the hafas-rest-api instance at the given
+// endpoint.
+func New(endpoint string) *Provider {
+	return &Provider{endpoint: endpoint, window: time.Hour}
+}
+
+// Window sets how far ahead to look for departures.
+func (p *Provider) Window(window time.Duration) *Provider {
+	p.window = window
+	return p
+}
+
+var client = httpclient.Client()
+
+type departure struct {
+	When      *time.Time `json:"when"`
+	Delay     *int       `json:"delay"`
+	Direction string     `json:"direction"`
+	Cancelled bool       `json:"cancelled"`
+	Line      struct {
+		Name string `json:"name"`
+	} `json:"line"`
+}
+
+// Departures implements transit.Provider.
+func (p *Provider) Departures(stops []string) ([]transit.Departure, error) {
+	var departures []transit.Departure
+	for _, stop := range stops {
+		d, err := p.stopDepartures(stop)
+		if err != nil {
+			return nil, err
+		}
+		departures = append(departures, d...)
+	}
+	return departures, nil
+}
+
+func (p *Provider) stopDepartures(stop string) ([]transit.Departure, error) {
+	q := url.Values{}
+	q.Set("duration", fmt.Sprintf("%d", int(p.window/time.Minute)))
+	res, err := client.Get(p.endpoint + "/stops/" + url.PathEscape(stop) +
+		"/departures?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("hafas: %s", res.Status)
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	// Older versions of hafas-rest-api return a bare array of departures.
+	var deps []departure
+	if len(raw) > 0 && raw[0] == '[' {
+		err = json.Unmarshal(raw, &deps)
+	} else {
+		var r struct {
+			Departures []departure `json:"departures"`
+		}
+		err = json.Unmarshal(raw, &r)
+		deps = r.Departures
+	}
+	if err != nil {
+		return nil, err
+	}
+	var departures []transit.Departure
+	for _, d := range deps {
+		if d.Cancelled || d.When == nil {
+			continue
+		}
+		dep := transit.Departure{
+			Line:        d.Line.Name,
+			Destination: d.Direction,
+			Stop:        stop,
+			Time:        *d.When,
+		}
+		if d.Delay != nil {
+			dep.Delay = time.Duration(*d.Delay) * time.Second
+			dep.Realtime = true
+		}
+		departures = append(departures, dep)
+	}
+	return departures, nil
+}
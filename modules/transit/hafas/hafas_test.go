@@ -0,0 +1,89 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hafas
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/modules/transit"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepartures(t *testing.T) {
+	var duration string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		duration = r.URL.Query().Get("duration")
+		switch r.URL.Path {
+		case "/stops/900100003/departures":
+			io.WriteString(w, `{"departures": [
+				{"when": "2017-03-01T09:03:00+01:00", "delay": 60, "direction": "S Ostkreuz",
+				 "line": {"name": "Bus 100"}},
+				{"when": null, "cancelled": true, "direction": "Zoo", "line": {"name": "Bus 200"}},
+				{"when": "2017-03-01T09:10:00+01:00", "delay": null, "direction": "Zoo",
+				 "line": {"name": "Bus 200"}}
+			], "realtimeDataUpdatedAt": 1488355200}`)
+		case "/stops/8011160/departures":
+			io.WriteString(w, `[{"when": "2017-03-01T09:15:00+01:00", "delay": 0,
+				"direction": "Hamburg", "line": {"name": "ICE 1000"}}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	berlin := time.FixedZone("CET", 3600)
+	p := New(srv.URL)
+	deps, err := p.Departures([]string{"900100003", "8011160"})
+	require.NoError(t, err)
+	require.Equal(t, "60", duration)
+	require.Len(t, deps, 3)
+	for idx, expected := range []transit.Departure{
+		{
+			Line:        "Bus 100",
+			Destination: "S Ostkreuz",
+			Stop:        "900100003",
+			Time:        time.Date(2017, time.March, 1, 9, 3, 0, 0, berlin),
+			Delay:       time.Minute,
+			Realtime:    true,
+		},
+		{
+			Line:        "Bus 200",
+			Destination: "Zoo",
+			Stop:        "900100003",
+			Time:        time.Date(2017, time.March, 1, 9, 10, 0, 0, berlin),
+		},
+		{
+			Line:        "ICE 1000",
+			Destination: "Hamburg",
+			Stop:        "8011160",
+			Time:        time.Date(2017, time.March, 1, 9, 15, 0, 0, berlin),
+			Realtime:    true,
+		},
+	} {
+		actual := deps[idx]
+		require.True(t, expected.Time.Equal(actual.Time), "time of %s", expected.Line)
+		actual.Time = expected.Time
+		require.Equal(t, expected, actual)
+	}
+
+	_, err = p.Window(2 * time.Hour).Departures([]string{"unknown"})
+	require.Error(t, err)
+	require.Equal(t, "120", duration)
+}
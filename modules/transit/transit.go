@@ -0,0 +1,210 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package transit provides an i3bar module that shows the next public transit
+departures from one or more stops, e.g. "Bus 42: 3m, 15m".
+
+A walking time can be set to hide departures that cannot be reached in time.
+
+Departures are fetched using a Provider, implemented by the provider
+packages:
+  - gtfsrt: any GTFS-Realtime trip updates feed.
+  - transitland: the Transitland REST API.
+  - hafas: hafas-rest-api endpoints, e.g. v6.db.transport.rest.
+*/
+package transit // import "barista.run/modules/transit"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Departure represents a single departure from a stop.
+type Departure struct {
+	// Line is the name of the line or route, e.g. "Bus 42".
+	Line string
+	// Destination is the headsign of the vehicle, if available.
+	Destination string
+	// Stop is the ID of the stop the vehicle departs from.
+	Stop string
+	// Time is the expected departure time, including any delay.
+	Time time.Time
+	// Delay is the difference between the expected and scheduled time.
+	Delay time.Duration
+	// Realtime is true if the departure time is based on live data.
+	Realtime bool
+}
+
+// Until returns the time until the vehicle departs.
+func (d Departure) Until() time.Duration {
+	return d.Time.Sub(timing.Now())
+}
+
+// Line represents the upcoming departures of a single line.
+type Line struct {
+	Name       string
+	Departures []Departure
+}
+
+// Info represents the upcoming departures that can be caught.
+type Info struct {
+	// Departures are sorted by time, and exclude any departures that
+	// leave before the stop can be reached.
+	Departures []Departure
+}
+
+// Lines returns the departures grouped by line, ordered by the first
+// departure of each line.
+func (i Info) Lines() []Line {
+	var lines []Line
+	idx := map[string]int{}
+	for _, d := range i.Departures {
+		n, ok := idx[d.Line]
+		if !ok {
+			n = len(lines)
+			idx[d.Line] = n
+			lines = append(lines, Line{Name: d.Line})
+		}
+		lines[n].Departures = append(lines[n].Departures, d)
+	}
+	return lines
+}
+
+// Provider is an interface for transit data providers, implemented by the
+// various provider packages.
+type Provider interface {
+	// Departures returns upcoming departures from the given stops. The format
+	// of stop IDs depends on the provider.
+	Departures(stops []string) ([]Departure, error)
+}
+
+// Format formats the time until a departure in minutes, e.g. "3m".
+func Format(d time.Duration) string {
+	if d < time.Minute {
+		return "now"
+	}
+	return fmt.Sprintf("%dm", int(d/time.Minute))
+}
+
+// Module represents a transit departures bar module.
+type Module struct {
+	provider   Provider
+	stops      []string
+	walk       value.Value // of time.Duration
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a module that shows departures from the given stops, using the
+// given provider.
+func New(provider Provider, stops ...string) *Module {
+	m := &Module{
+		provider:  provider,
+		stops:     stops,
+		scheduler: timing.NewScheduler().Named("transit"),
+	}
+	l.Register(m, "outputFunc", "walk", "scheduler")
+	m.walk.Set(time.Duration(0))
+	// Default output is the next two departures of up to three lines,
+	// e.g. "Bus 42: 3m, 15m".
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for n, line := range i.Lines() {
+			if n == 3 {
+				break
+			}
+			var times []string
+			for k, d := range line.Departures {
+				if k == 2 {
+					break
+				}
+				times = append(times, Format(d.Until()))
+			}
+			out.Append(outputs.Textf("%s: %s", line.Name, strings.Join(times, ", ")))
+		}
+		return out
+	})
+	m.RefreshInterval(time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency for departures.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Walk sets the time it takes to reach the stops. Departures sooner than this
+// are not shown.
+func (m *Module) Walk(walk time.Duration) *Module {
+	m.walk.Set(walk)
+	return m
+}
+
+func (m *Module) info(departures []Departure) Info {
+	earliest := timing.Now().Add(m.walk.Get().(time.Duration))
+	i := Info{}
+	for _, d := range departures {
+		if !d.Time.Before(earliest) {
+			i.Departures = append(i.Departures, d)
+		}
+	}
+	sort.SliceStable(i.Departures, func(a, b int) bool {
+		return i.Departures[a].Time.Before(i.Departures[b].Time)
+	})
+	return i
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	departures, err := m.provider.Departures(m.stops)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextWalk, doneWalk := m.walk.Subscribe()
+	defer doneWalk()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(m.info(departures)))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextWalk:
+		case <-m.scheduler.C:
+			departures, err = m.provider.Departures(m.stops)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sync.Mutex
+	departures []Departure
+	err        error
+	stops      []string
+}
+
+func (t *testProvider) Departures(stops []string) ([]Departure, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.stops = stops
+	return t.departures, t.err
+}
+
+func (t *testProvider) set(err error, departures ...Departure) {
+	t.Lock()
+	defer t.Unlock()
+	t.departures, t.err = departures, err
+}
+
+var fixedTime = time.Date(2017, time.March, 1, 8, 0, 0, 0, time.UTC)
+
+func in(line string, mins int) Departure {
+	return Departure{Line: line, Time: fixedTime.Add(time.Duration(mins) * time.Minute)}
+}
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, "now", Format(30*time.Second))
+	require.Equal(t, "3m", Format(3*time.Minute+30*time.Second))
+	require.Equal(t, "90m", Format(90*time.Minute))
+}
+
+func TestLines(t *testing.T) {
+	i := Info{Departures: []Departure{in("42", 1), in("M10", 2), in("42", 5)}}
+	require.Equal(t, []Line{
+		{"42", []Departure{in("42", 1), in("42", 5)}},
+		{"M10", []Departure{in("M10", 2)}},
+	}, i.Lines())
+	require.Empty(t, Info{}.Lines())
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(fixedTime)
+	p := &testProvider{}
+	p.set(nil, in("42", 12), in("42", 2), in("M10", 6), in("42", 25))
+	m := New(p, "stop1", "stop2").Output(func(i Info) bar.Output {
+		var deps []string
+		for _, d := range i.Departures {
+			deps = append(deps, fmt.Sprintf("%s@%s", d.Line, Format(d.Until())))
+		}
+		return outputs.Text(strings.Join(deps, " "))
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"42@2m M10@6m 42@12m 42@25m"})
+	require.Equal(t, []string{"stop1", "stop2"}, p.stops)
+
+	m.Walk(5 * time.Minute)
+	testBar.NextOutput("on walk change").AssertText([]string{"M10@6m 42@12m 42@25m"})
+
+	timing.NextTick()
+	testBar.NextOutput("on refresh").AssertText([]string{"M10@5m 42@11m 42@24m"})
+
+	p.set(errors.New("foo"))
+	timing.NextTick()
+	testBar.NextOutput("on error").AssertError()
+
+	p.set(nil, in("42", 25))
+	timing.NextTick()
+	testBar.NextOutput("after error").AssertText([]string{"42@22m"})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(fixedTime)
+	p := &testProvider{}
+	p.set(nil, in("Bus 42", 3), in("Bus 42", 15), in("Bus 42", 30),
+		in("U8", 4), in("Tram M10", 9), in("Bus 100", 20))
+	testBar.Run(New(p, "stop"))
+	testBar.NextOutput("on start").AssertText([]string{
+		"Bus 42: 3m, 15m", "U8: 4m", "Tram M10: 9m"})
+
+	p.set(nil)
+	timing.NextTick()
+	testBar.NextOutput("without departures").AssertEmpty()
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transitland provides departures from the Transitland REST API
+// (https://www.transit.land/documentation/rest-api/), which aggregates
+// schedules and realtime data for many agencies. Stops are identified by
+// their Onestop ID, or "feed:stop_id".
+package transitland // import "barista.run/modules/transit/transitland"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"barista.run/httpclient"
+	"barista.run/modules/transit"
+)
+
+// Provider provides departures from Transitland.
+type Provider struct {
+	apiKey string
+	window time.Duration
+}
+
+// New creates a provider using the given Transitland API key.
+func New(apiKey string) *Provider {
+	return &Provider{apiKey: apiKey, window: 2 * time.Hour}
+}
+
+// Window sets how far ahead to look for departures.
+func (p *Provider) Window(window time.Duration) *Provider {
+	p.window = window
+	return p
+}
+
+var client = httpclient.Client()
+
+// baseURL can be replaced in tests.
+var baseURL = "https://transit.land/api/v2/rest"
+
+type stopDepartures struct {
+	Stops []struct {
+		StopID     string `json:"stop_id"`
+		Departures []struct {
+			Departure struct {
+				ScheduledUTC *time.Time `json:"scheduled_utc"`
+				EstimatedUTC *time.Time `json:"estimated_utc"`
+				Delay        *int       `json:"delay"`
+			} `json:"departure"`
+			Trip struct {
+				Headsign string `json:"trip_headsign"`
+				Route    struct {
+					ShortName string `json:"route_short_name"`
+					LongName  string `json:"route_long_name"`
+					Type      int    `json:"route_type"`
+				} `json:"route"`
+			} `json:"trip"`
+		} `json:"departures"`
+	} `json:"stops"`
+}
+
+// Route types from the GTFS specification.
+var routeTypes = map[int]string{
+	0:  "Tram",
+	1:  "Metro",
+	2:  "Train",
+	3:  "Bus",
+	4:  "Ferry",
+	5:  "Cable car",
+	6:  "Gondola",
+	7:  "Funicular",
+	11: "Trolleybus",
+	12: "Monorail",
+}
+
+// Departures implements transit.Provider.
+func (p *Provider) Departures(stops []string) ([]transit.Departure, error) {
+	var departures []transit.Departure
+	for _, stop := range stops {
+		d, err := p.stopDepartures(stop)
+		if err != nil {
+			return nil, err
+		}
+		departures = append(departures, d...)
+	}
+	return departures, nil
+}
+
+func (p *Provider) stopDepartures(stop string) ([]transit.Departure, error) {
+	q := url.Values{}
+	q.Set("next", fmt.Sprintf("%d", int(p.window/time.Second)))
+	req, err := http.NewRequest("GET",
+		baseURL+"/stops/"+url.PathEscape(stop)+"/departures?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("apikey", p.apiKey)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transitland: %s", res.Status)
+	}
+	var r stopDepartures
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	var departures []transit.Departure
+	for _, s := range r.Stops {
+		for _, d := range s.Departures {
+			dep := transit.Departure{
+				Line:        lineName(d.Trip.Route.ShortName, d.Trip.Route.LongName, d.Trip.Route.Type),
+				Destination: d.Trip.Headsign,
+				Stop:        stop,
+			}
+			switch {
+			case d.Departure.EstimatedUTC != nil:
+				dep.Time = *d.Departure.EstimatedUTC
+				dep.Realtime = true
+			case d.Departure.ScheduledUTC != nil:
+				dep.Time = *d.Departure.ScheduledUTC
+			default:
+				continue
+			}
+			if d.Departure.Delay != nil {
+				dep.Delay = time.Duration(*d.Departure.Delay) * time.Second
+			}
+			departures = append(departures, dep)
+		}
+	}
+	return departures, nil
+}
+
+func lineName(short, long string, routeType int) string {
+	if short == "" {
+		return long
+	}
+	if mode, ok := routeTypes[routeType]; ok {
+		return mode + " " + short
+	}
+	return short
+}
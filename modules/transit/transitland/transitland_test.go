@@ -0,0 +1,96 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transitland
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/modules/transit"
+
+	"github.com/stretchr/testify/require"
+)
+
+const departuresJSON = `{"stops": [{
+	"stop_id": "s-dr5ru-7thav~w23rdst",
+	"departures": [{
+		"departure": {
+			"scheduled_utc": "2017-03-01T08:05:00Z",
+			"estimated_utc": "2017-03-01T08:07:00Z",
+			"delay": 120
+		},
+		"trip": {"trip_headsign": "Downtown", "route": {"route_short_name": "42", "route_type": 3}}
+	}, {
+		"departure": {"scheduled_utc": "2017-03-01T08:10:00Z", "estimated_utc": null, "delay": null},
+		"trip": {"trip_headsign": "Uptown", "route": {"route_short_name": "", "route_long_name": "Harbour Shuttle", "route_type": 4}}
+	}, {
+		"departure": {"scheduled_utc": null},
+		"trip": {"route": {"route_short_name": "X", "route_type": 99}}
+	}, {
+		"departure": {"scheduled_utc": "2017-03-01T08:20:00Z"},
+		"trip": {"route": {"route_short_name": "X", "route_type": 99}}
+	}]
+}]}`
+
+func TestDepartures(t *testing.T) {
+	var path, key, next string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		key = r.Header.Get("apikey")
+		next = r.URL.Query().Get("next")
+		if r.URL.Path != "/stops/s-dr5ru-7thav~w23rdst/departures" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, departuresJSON)
+	}))
+	defer srv.Close()
+	baseURL = srv.URL
+
+	p := New("secret")
+	deps, err := p.Departures([]string{"s-dr5ru-7thav~w23rdst"})
+	require.NoError(t, err)
+	require.Equal(t, "secret", key)
+	require.Equal(t, "7200", next)
+	require.Equal(t, []transit.Departure{
+		{
+			Line:        "Bus 42",
+			Destination: "Downtown",
+			Stop:        "s-dr5ru-7thav~w23rdst",
+			Time:        time.Date(2017, time.March, 1, 8, 7, 0, 0, time.UTC),
+			Delay:       2 * time.Minute,
+			Realtime:    true,
+		},
+		{
+			Line:        "Harbour Shuttle",
+			Destination: "Uptown",
+			Stop:        "s-dr5ru-7thav~w23rdst",
+			Time:        time.Date(2017, time.March, 1, 8, 10, 0, 0, time.UTC),
+		},
+		{
+			Line: "X",
+			Stop: "s-dr5ru-7thav~w23rdst",
+			Time: time.Date(2017, time.March, 1, 8, 20, 0, 0, time.UTC),
+		},
+	}, deps)
+
+	_, err = p.Window(30 * time.Minute).Departures([]string{"s-dr5ru-7thav~w23rdst", "other"})
+	require.Error(t, err)
+	require.Equal(t, "1800", next)
+	require.Equal(t, "/stops/other/departures", path)
+}
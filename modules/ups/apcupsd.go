@@ -0,0 +1,108 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ups
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+type apcupsd struct {
+	addr string
+}
+
+// Apcupsd returns a backend that queries the network information server of
+// apcupsd at the given address. If the address is empty, localhost:3551 is
+// used.
+func Apcupsd(addr string) Backend {
+	if addr == "" {
+		addr = "localhost:3551"
+	}
+	return &apcupsd{addr}
+}
+
+func (a *apcupsd) Status() (Info, error) {
+	conn, err := net.DialTimeout("tcp", a.addr, timeout)
+	if err != nil {
+		return Info{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	// Both requests and responses are sent as records prefixed with a
+	// 16-bit big-endian length. The response is terminated by an empty record.
+	if err := writeRecord(conn, "status"); err != nil {
+		return Info{}, err
+	}
+	vars := map[string]string{}
+	for {
+		rec, err := readRecord(conn)
+		if err != nil {
+			return Info{}, err
+		}
+		if rec == "" {
+			break
+		}
+		// KEY      : value
+		if colon := strings.Index(rec, ":"); colon >= 0 {
+			vars[strings.TrimSpace(rec[:colon])] = strings.TrimSpace(rec[colon+1:])
+		}
+	}
+	return apcInfo(vars), nil
+}
+
+func writeRecord(w io.Writer, rec string) error {
+	buf := make([]byte, 2+len(rec))
+	binary.BigEndian.PutUint16(buf, uint16(len(rec)))
+	copy(buf[2:], rec)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readRecord(r io.Reader) (string, error) {
+	var size uint16
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func apcInfo(vars map[string]string) Info {
+	i := Info{
+		Model:  vars["MODEL"],
+		Charge: parseFloat(vars["BCHARGE"]),
+		Load:   parseFloat(vars["LOADPCT"]),
+		// TIMELEFT is reported in minutes, e.g. "45.2 Minutes".
+		Runtime: time.Duration(parseFloat(vars["TIMELEFT"]) * float64(time.Minute)),
+	}
+	for _, flag := range strings.Fields(vars["STATUS"]) {
+		switch flag {
+		case "ONBATT":
+			i.OnBattery = true
+		case "LOWBATT":
+			i.LowBattery = true
+		}
+	}
+	// apcupsd does not report charging, so assume the battery is charging if
+	// running on utility power without a full charge.
+	i.Charging = !i.OnBattery && i.Charge < 100
+	return i
+}
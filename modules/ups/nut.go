@@ -0,0 +1,100 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ups
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type nut struct {
+	name string
+	addr string
+}
+
+// NUT returns a backend that queries a Network UPS Tools server. The UPS is
+// identified as upsname[@hostname[:port]], the same format used by upsc.
+// The host defaults to localhost, and the port to 3493.
+func NUT(ups string) Backend {
+	n := &nut{name: ups, addr: "localhost"}
+	if at := strings.Index(ups, "@"); at >= 0 {
+		n.name, n.addr = ups[:at], ups[at+1:]
+	}
+	if _, _, err := net.SplitHostPort(n.addr); err != nil {
+		n.addr = net.JoinHostPort(n.addr, "3493")
+	}
+	return n
+}
+
+func (n *nut) Status() (Info, error) {
+	conn, err := net.DialTimeout("tcp", n.addr, timeout)
+	if err != nil {
+		return Info{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := fmt.Fprintf(conn, "LIST VAR %s\n", n.name); err != nil {
+		return Info{}, err
+	}
+	vars := map[string]string{}
+	s := bufio.NewScanner(conn)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "ERR ") {
+			return Info{}, errors.New("nut: " + strings.TrimPrefix(line, "ERR "))
+		}
+		if strings.HasPrefix(line, "END LIST") {
+			fmt.Fprint(conn, "LOGOUT\n")
+			return nutInfo(vars), nil
+		}
+		// VAR <upsname> <varname> "<value>"
+		f := strings.SplitN(line, " ", 4)
+		if len(f) < 4 || f[0] != "VAR" {
+			continue
+		}
+		if val, err := strconv.Unquote(f[3]); err == nil {
+			vars[f[2]] = val
+		}
+	}
+	if err := s.Err(); err != nil {
+		return Info{}, err
+	}
+	return Info{}, errors.New("nut: unexpected end of response")
+}
+
+func nutInfo(vars map[string]string) Info {
+	i := Info{
+		Model:   strings.TrimSpace(vars["ups.mfr"] + " " + vars["ups.model"]),
+		Charge:  parseFloat(vars["battery.charge"]),
+		Load:    parseFloat(vars["ups.load"]),
+		Runtime: time.Duration(parseFloat(vars["battery.runtime"])) * time.Second,
+	}
+	for _, flag := range strings.Fields(vars["ups.status"]) {
+		switch flag {
+		case "OB":
+			i.OnBattery = true
+		case "LB":
+			i.LowBattery = true
+		case "CHRG":
+			i.Charging = true
+		}
+	}
+	return i
+}
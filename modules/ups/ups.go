@@ -0,0 +1,142 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package ups provides an i3bar module that shows the status of an
+uninterruptible power supply.
+
+Two backends are provided: NUT() speaks the Network UPS Tools protocol to
+upsd, and Apcupsd() speaks the Network Information Server protocol of
+apcupsd. Both work over the network, so the UPS need not be attached to the
+machine running the bar.
+*/
+package ups // import "barista.run/modules/ups"
+
+import (
+	"fmt"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info represents the status of a UPS.
+type Info struct {
+	// Model of the UPS, if reported.
+	Model string
+	// OnBattery is true if the UPS has lost utility power.
+	OnBattery bool
+	// LowBattery is true if the UPS reports that the battery is low, usually
+	// meaning that a shutdown is imminent.
+	LowBattery bool
+	// Charging is true if the battery is being charged.
+	Charging bool
+	// Charge is the battery charge, in percent.
+	Charge float64
+	// Load is the load on the UPS, as a percentage of its capacity.
+	Load float64
+	// Runtime is the estimated time remaining on battery.
+	Runtime time.Duration
+}
+
+// Backend is an interface for UPS monitoring daemons.
+type Backend interface {
+	// Status returns the current status of the UPS.
+	Status() (Info, error)
+}
+
+// Module represents a UPS bar module.
+type Module struct {
+	backend    Backend
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs an instance of the UPS module using the given backend.
+func New(backend Backend) *Module {
+	m := &Module{
+		backend:   backend,
+		scheduler: timing.NewScheduler(),
+	}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the battery charge, with the estimated runtime when
+	// running on battery, which is also marked urgent.
+	m.Output(func(i Info) bar.Output {
+		if !i.OnBattery {
+			return outputs.Textf("UPS %.0f%%", i.Charge)
+		}
+		return outputs.Textf("UPS %.0f%% (%s)", i.Charge,
+			i.Runtime.Truncate(time.Minute)).Urgent(true)
+	})
+	m.RefreshInterval(15 * time.Second)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Refresh fetches the current status of the UPS.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.backend.Status()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = m.backend.Status()
+		case <-m.refreshCh:
+			if err != nil {
+				s(nil)
+			}
+			info, err = m.backend.Status()
+		}
+	}
+}
+
+// timeout bounds the time taken to query the UPS daemon.
+const timeout = 5 * time.Second
+
+// parseFloat parses the number at the start of s, ignoring any units.
+func parseFloat(s string) float64 {
+	var f float64
+	fmt.Sscanf(s, "%f", &f)
+	return f
+}
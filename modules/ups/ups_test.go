@@ -0,0 +1,205 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ups
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serve starts a TCP server that handles each connection using the given
+// function, and returns its address.
+func serve(t *testing.T, handler func(net.Conn)) (addr string, stop func()) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handler(conn)
+			}()
+		}
+	}()
+	return lis.Addr().String(), func() { lis.Close() }
+}
+
+func nutHandler(conn net.Conn) {
+	s := bufio.NewScanner(conn)
+	for s.Scan() {
+		switch s.Text() {
+		case "LIST VAR myups":
+			fmt.Fprint(conn, `BEGIN LIST VAR myups
+VAR myups battery.charge "87"
+VAR myups battery.runtime "2730"
+VAR myups ups.load "23"
+VAR myups ups.mfr "CyberPower"
+VAR myups ups.model "CP1500"
+VAR myups ups.status "OB DISCHRG"
+END LIST VAR myups
+`)
+		case "LIST VAR broken":
+			fmt.Fprint(conn, "BEGIN LIST VAR broken\nVAR broken ups.load \"1\"\n")
+			return
+		case "LOGOUT":
+			fmt.Fprint(conn, "OK Goodbye\n")
+			return
+		default:
+			fmt.Fprint(conn, "ERR UNKNOWN-UPS\n")
+		}
+	}
+}
+
+func TestNUT(t *testing.T) {
+	addr, stop := serve(t, nutHandler)
+	defer stop()
+
+	info, err := NUT("myups@" + addr).Status()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		Model:     "CyberPower CP1500",
+		OnBattery: true,
+		Charge:    87,
+		Load:      23,
+		Runtime:   2730 * time.Second,
+	}, info)
+
+	_, err = NUT("otherups@" + addr).Status()
+	require.EqualError(t, err, "nut: UNKNOWN-UPS")
+
+	_, err = NUT("broken@" + addr).Status()
+	require.Error(t, err, "incomplete response")
+
+	stop()
+	_, err = NUT("myups@" + addr).Status()
+	require.Error(t, err, "server not running")
+
+	require.Equal(t, &nut{"ups", "localhost:3493"}, NUT("ups"))
+	require.Equal(t, &nut{"ups", "nas:3493"}, NUT("ups@nas"))
+	require.Equal(t, &nut{"ups", "nas:1234"}, NUT("ups@nas:1234"))
+
+	require.Equal(t,
+		Info{LowBattery: true, Charging: true},
+		nutInfo(map[string]string{"ups.status": "OL CHRG LB"}))
+}
+
+func apcHandler(status string) func(net.Conn) {
+	return func(conn net.Conn) {
+		req, err := readRecord(conn)
+		if err != nil || req != "status" {
+			return
+		}
+		for _, line := range strings.Split(status, "\n") {
+			writeRecord(conn, line+"\n")
+		}
+		writeRecord(conn, "")
+	}
+}
+
+func TestApcupsd(t *testing.T) {
+	addr, stop := serve(t, apcHandler(`APC      : 001,036,0879
+STATUS   : ONLINE
+MODEL    : Back-UPS ES 700G
+LOADPCT  : 12.0 Percent
+BCHARGE  : 95.0 Percent
+TIMELEFT : 45.5 Minutes`))
+	defer stop()
+
+	info, err := Apcupsd(addr).Status()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		Model:    "Back-UPS ES 700G",
+		Charging: true,
+		Charge:   95,
+		Load:     12,
+		Runtime:  45*time.Minute + 30*time.Second,
+	}, info)
+
+	require.Equal(t,
+		Info{OnBattery: true, LowBattery: true, Charge: 100},
+		apcInfo(map[string]string{"STATUS": "ONBATT LOWBATT", "BCHARGE": "100.0 Percent"}))
+
+	addr, stop = serve(t, func(conn net.Conn) { conn.Write([]byte{0, 10, 'a'}) })
+	defer stop()
+	_, err = Apcupsd(addr).Status()
+	require.Error(t, err, "truncated response")
+
+	require.Equal(t, &apcupsd{"localhost:3551"}, Apcupsd(""))
+}
+
+type testBackend struct {
+	sync.Mutex
+	info Info
+	err  error
+}
+
+func (t *testBackend) Status() (Info, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.info, t.err
+}
+
+func (t *testBackend) set(info Info, err error) {
+	t.Lock()
+	defer t.Unlock()
+	t.info, t.err = info, err
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	b := &testBackend{info: Info{Charge: 100}}
+	m := New(b)
+	testBar.Run(m)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"UPS 100%"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	b.set(Info{OnBattery: true, Charge: 80, Runtime: 20*time.Minute + 10*time.Second}, nil)
+	testBar.Tick()
+	out = testBar.NextOutput("on tick")
+	out.AssertText([]string{"UPS 80% (20m0s)"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "urgent when on battery")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.0f/%v", i.Charge, i.OnBattery)
+	})
+	testBar.NextOutput().AssertText([]string{"80/true"}, "on output change")
+
+	b.set(Info{}, errors.New("connection refused"))
+	m.Refresh()
+	testBar.NextOutput().AssertError("on error")
+
+	b.set(Info{Charge: 81}, nil)
+	m.Refresh()
+	testBar.NextOutput().AssertText([]string{"81/false"}, "on refresh after error")
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package uptime provides an i3bar module that shows how long the system has
+been running, along with the release of the running kernel.
+
+The running kernel is also compared against the installed kernels in
+/usr/lib/modules, in the same way as the reboot module, so that the output can
+indicate when the system is running a kernel that is no longer the newest one.
+*/
+package uptime // import "barista.run/modules/uptime"
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/spf13/afero"
+	"golang.org/x/sys/unix"
+)
+
+// Info represents the uptime and kernel information of the system.
+type Info struct {
+	// Uptime is the time elapsed since the system was booted.
+	Uptime time.Duration
+	// Kernel is the release of the running kernel, e.g. "6.1.2-arch1-1".
+	Kernel string
+	// Version is the build version of the running kernel,
+	// e.g. "#1 SMP PREEMPT_DYNAMIC Sat, 31 Dec 2022 17:40:35 +0000".
+	Version string
+	// InstalledKernel is the release of the newest installed kernel, if it
+	// differs from the running kernel.
+	InstalledKernel string
+}
+
+// Booted returns the time at which the system was booted.
+func (i Info) Booted() time.Time {
+	return timing.Now().Add(-i.Uptime)
+}
+
+// KernelUpdated returns true if a different kernel has been installed since
+// the system was booted.
+func (i Info) KernelUpdated() bool {
+	return i.InstalledKernel != ""
+}
+
+// Days returns the number of whole days the system has been up.
+func (i Info) Days() int {
+	return int(i.Uptime / (24 * time.Hour))
+}
+
+// Short returns a compact representation of the uptime, using at most the
+// two most significant units, e.g. "3d 4h", "5h 12m", or "7m".
+func (i Info) Short() string {
+	d := i.Days()
+	h := int(i.Uptime/time.Hour) % 24
+	m := int(i.Uptime/time.Minute) % 60
+	switch {
+	case d > 0:
+		return fmt.Sprintf("%dd %dh", d, h)
+	case h > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	default:
+		return fmt.Sprintf("%dm", m)
+	}
+}
+
+// Module represents an uptime bar module.
+type Module struct {
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates an uptime module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the uptime, with a marker if the running kernel
+	// has been superseded.
+	m.Output(func(i Info) bar.Output {
+		if i.KernelUpdated() {
+			return outputs.Textf("up %s ⟳", i.Short())
+		}
+		return outputs.Textf("up %s", i.Short())
+	})
+	m.RefreshInterval(time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := getInfo()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			info, err = getInfo()
+		}
+	}
+}
+
+var sysinfo = unix.Sysinfo
+
+var fs = afero.NewOsFs()
+
+const modulesDir = "/usr/lib/modules"
+
+func getInfo() (Info, error) {
+	var sysinfoT unix.Sysinfo_t
+	if err := sysinfo(&sysinfoT); err != nil {
+		return Info{}, err
+	}
+	i := Info{Uptime: time.Duration(sysinfoT.Uptime) * time.Second}
+	release, err := afero.ReadFile(fs, "/proc/sys/kernel/osrelease")
+	if err != nil {
+		return i, err
+	}
+	i.Kernel = strings.TrimSpace(string(release))
+	if version, err := afero.ReadFile(fs, "/proc/sys/kernel/version"); err == nil {
+		i.Version = strings.TrimSpace(string(version))
+	}
+	i.InstalledKernel = newestKernel(i.Kernel)
+	return i, nil
+}
+
+// newestKernel returns the most recently installed kernel release if it is
+// not the running kernel, and an empty string otherwise.
+func newestKernel(running string) string {
+	entries, err := afero.ReadDir(fs, modulesDir)
+	if err != nil || len(entries) == 0 {
+		return ""
+	}
+	var newest os.FileInfo
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if newest == nil || e.ModTime().After(newest.ModTime()) {
+			newest = e
+		}
+	}
+	if newest == nil || newest.Name() == running {
+		return ""
+	}
+	current, err := fs.Stat(filepath.Join(modulesDir, running))
+	if err != nil || newest.ModTime().After(current.ModTime()) {
+		return newest.Name()
+	}
+	return ""
+}
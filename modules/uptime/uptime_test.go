@@ -0,0 +1,146 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uptime
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+var mu sync.Mutex
+var simulatedUptime int64
+var simulatedErr error
+
+func setUptime(uptime time.Duration, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	simulatedUptime, simulatedErr = int64(uptime/time.Second), err
+}
+
+func init() {
+	sysinfo = func(out *unix.Sysinfo_t) error {
+		mu.Lock()
+		defer mu.Unlock()
+		*out = unix.Sysinfo_t{}
+		out.Uptime = simulatedUptime
+		return simulatedErr
+	}
+}
+
+var start = time.Date(2018, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+func setupFs(release string) {
+	fs = afero.NewMemMapFs()
+	afero.WriteFile(fs, "/proc/sys/kernel/osrelease", []byte(release+"\n"), 0444)
+	afero.WriteFile(fs, "/proc/sys/kernel/version",
+		[]byte("#1 SMP PREEMPT_DYNAMIC Sat, 31 Dec 2022 17:40:35 +0000\n"), 0444)
+}
+
+func installKernel(t *testing.T, release string, age time.Duration) {
+	dir := filepath.Join(modulesDir, release)
+	require.NoError(t, fs.MkdirAll(dir, 0755))
+	mtime := start.Add(-age)
+	require.NoError(t, fs.Chtimes(dir, mtime, mtime))
+}
+
+func TestInfo(t *testing.T) {
+	timing.TestMode()
+	for _, tc := range []struct {
+		uptime   time.Duration
+		days     int
+		expected string
+	}{
+		{0, 0, "0m"},
+		{59 * time.Second, 0, "0m"},
+		{7 * time.Minute, 0, "7m"},
+		{5*time.Hour + 12*time.Minute, 0, "5h 12m"},
+		{24 * time.Hour, 1, "1d 0h"},
+		{3*24*time.Hour + 4*time.Hour + 30*time.Minute, 3, "3d 4h"},
+	} {
+		i := Info{Uptime: tc.uptime}
+		require.Equal(t, tc.expected, i.Short(), "Short() of %s", tc.uptime)
+		require.Equal(t, tc.days, i.Days(), "Days() of %s", tc.uptime)
+	}
+	i := Info{Uptime: 2 * time.Hour}
+	require.Equal(t, timing.Now().Add(-2*time.Hour), i.Booted())
+}
+
+func TestKernel(t *testing.T) {
+	setUptime(time.Hour, nil)
+	fs = afero.NewMemMapFs()
+	_, err := getInfo()
+	require.Error(t, err, "without osrelease")
+
+	setupFs("6.1.2-arch1-1")
+	i, err := getInfo()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		Uptime:  time.Hour,
+		Kernel:  "6.1.2-arch1-1",
+		Version: "#1 SMP PREEMPT_DYNAMIC Sat, 31 Dec 2022 17:40:35 +0000",
+	}, i)
+
+	installKernel(t, "6.1.2-arch1-1", time.Hour)
+	installKernel(t, "6.0.9-arch1-1", 2*time.Hour)
+	i, _ = getInfo()
+	require.False(t, i.KernelUpdated(), "older kernel installed")
+
+	installKernel(t, "6.1.3-arch1-1", time.Minute)
+	i, _ = getInfo()
+	require.True(t, i.KernelUpdated(), "newer kernel installed")
+	require.Equal(t, "6.1.3-arch1-1", i.InstalledKernel)
+
+	setUptime(0, errors.New("foo"))
+	_, err = getInfo()
+	require.Error(t, err)
+}
+
+func TestModule(t *testing.T) {
+	setUptime(3*24*time.Hour+4*time.Hour, nil)
+	setupFs("6.1.2-arch1-1")
+	installKernel(t, "6.1.2-arch1-1", time.Hour)
+
+	testBar.New(t)
+	m := New()
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText([]string{"up 3d 4h"})
+
+	setUptime(3*24*time.Hour+5*time.Hour, nil)
+	installKernel(t, "6.1.3-arch1-1", time.Minute)
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"up 3d 5h ⟳"})
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s -> %s", i.Kernel, i.InstalledKernel)
+	})
+	testBar.NextOutput("on output change").
+		AssertText([]string{"6.1.2-arch1-1 -> 6.1.3-arch1-1"})
+
+	setUptime(0, errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertError()
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 
 	"barista.run/base/value"
+	"barista.run/doctor"
 	l "barista.run/logging"
 )
 
@@ -48,11 +49,13 @@ func alsaError(result int32, desc string) error {
 // Mixer constructs an instance of the volume module for a
 // specific card and mixer on that card.
 func Mixer(card, mixer string) *Module {
-	m := createModule(&alsaModule{
+	a := &alsaModule{
 		cardName:  card,
 		mixerName: mixer,
-	})
+	}
+	m := createModule(a)
 	l.Labelf(m, "alsa:%s,%s", card, mixer)
+	doctor.Register(fmt.Sprintf("ALSA mixer %s,%s", card, mixer), a.check)
 	return m
 }
 
@@ -79,6 +82,38 @@ func (c alsaController) setMuted(muted bool) error {
 		"snd_mixer_selem_set_playback_switch_all")
 }
 
+// check opens the mixer once, to check that the card and mixer exist.
+func (m *alsaModule) check() error {
+	var handle *ctyp_snd_mixer_t
+	var sid *ctyp_snd_mixer_selem_id_t
+	if err := alsaError(alsa.snd_mixer_selem_id_malloc(&sid), "snd_mixer_selem_id_malloc"); err != nil {
+		return err
+	}
+	defer alsa.snd_mixer_selem_id_free(sid)
+	alsa.snd_mixer_selem_id_set_index(sid, 0)
+	alsa.snd_mixer_selem_id_set_name(sid, m.mixerName)
+	if err := alsaError(alsa.snd_mixer_open(&handle, 0), "snd_mixer_open"); err != nil {
+		return doctor.Fix(err, "Install ALSA, and check that a sound card is present")
+	}
+	defer alsa.snd_mixer_close(handle)
+	if err := alsaError(alsa.snd_mixer_attach(handle, m.cardName), "snd_mixer_attach"); err != nil {
+		return doctor.Fix(err, "Use a card name listed by 'aplay -L'")
+	}
+	defer alsa.snd_mixer_detach(handle, m.cardName)
+	if err := alsaError(alsa.snd_mixer_load(handle), "snd_mixer_load"); err != nil {
+		return err
+	}
+	defer alsa.snd_mixer_free(handle)
+	if err := alsaError(alsa.snd_mixer_selem_register(handle, nil, nil), "snd_mixer_selem_register"); err != nil {
+		return err
+	}
+	if alsa.snd_mixer_find_selem(handle, sid) == nil {
+		return doctor.Fix(fmt.Errorf("mixer '%s' not found", m.mixerName),
+			"Use a mixer name listed by 'amixer scontrols'")
+	}
+	return nil
+}
+
 // worker waits for signals from alsa and updates the stored volume.
 func (m *alsaModule) worker(s *value.ErrorValue) {
 	// Structs for querying ALSA.
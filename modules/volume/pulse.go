@@ -20,6 +20,7 @@ import (
 	"os"
 
 	"barista.run/base/value"
+	"barista.run/doctor"
 	l "barista.run/logging"
 
 	"github.com/godbus/dbus"
@@ -86,9 +87,19 @@ func Sink(sinkName string) *Module {
 		sinkName = "default"
 	}
 	l.Labelf(m, "pulse:%s", sinkName)
+	doctor.Register("PulseAudio", checkPulseAudio)
 	return m
 }
 
+func checkPulseAudio() error {
+	conn, err := openPulseAudio()
+	if err != nil {
+		return doctor.Fix(err,
+			"Start PulseAudio, and load module-dbus-protocol (e.g. in default.pa)")
+	}
+	return conn.Close()
+}
+
 // DefaultSink creates a PulseAudio volume module that follows the default sink.
 func DefaultSink() *Module {
 	return Sink("")
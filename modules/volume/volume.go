@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/click"
 	"barista.run/base/value"
 	l "barista.run/logging"
 	"barista.run/outputs"
@@ -105,10 +106,15 @@ func (m *Module) Output(outputFunc func(Volume) bar.Output) *Module {
 // Throttle volume updates to once every ~20ms to avoid unexpected behaviour.
 var rateLimiter = rate.NewLimiter(rate.Every(20*time.Millisecond), 1)
 
+// Continuous scrolling increases the volume step, up to 5x.
+var scrollHold = click.NewHold(250*time.Millisecond, 5)
+
 // defaultClickHandler provides a simple example of the click handler capabilities.
-// It toggles mute on left click, and raises/lowers the volume on scroll.
+// It toggles mute on left click, and raises/lowers the volume on scroll, in
+// larger steps when scrolling continuously.
 func defaultClickHandler(v Volume) func(bar.Event) {
 	return func(e bar.Event) {
+		repeats := scrollHold.Repeats(e)
 		if !rateLimiter.Allow() {
 			// Don't update the volume if it was updated <20ms ago.
 			return
@@ -121,6 +127,7 @@ func defaultClickHandler(v Volume) func(bar.Event) {
 		if volStep == 0 {
 			volStep = 1
 		}
+		volStep *= int64(repeats)
 		if e.Button == bar.ScrollUp {
 			v.SetVolume(v.Vol + volStep)
 		}
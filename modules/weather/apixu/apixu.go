@@ -17,11 +17,11 @@ package apixu // import "barista.run/modules/weather/apixu"
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"barista.run/httpclient"
 	"barista.run/modules/weather"
 
 	"github.com/martinlindhe/unit"
@@ -122,7 +122,7 @@ func getCondition(apixuCondition int) weather.Condition {
 
 // GetWeather gets weather information from Apixu.
 func (apixuProvider Provider) GetWeather() (weather.Weather, error) {
-	response, err := http.Get(string(apixuProvider))
+	response, err := httpclient.Client().Get(string(apixuProvider))
 	if err != nil {
 		return weather.Weather{}, err
 	}
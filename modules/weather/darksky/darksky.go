@@ -21,10 +21,10 @@ package darksky // import "barista.run/modules/weather/darksky"
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"net/url"
 	"time"
 
+	"barista.run/httpclient"
 	"barista.run/modules/weather"
 
 	"github.com/martinlindhe/unit"
@@ -112,7 +112,7 @@ func getCondition(icon string) weather.Condition {
 
 // GetWeather gets weather information from DarkSky.
 func (ds Provider) GetWeather() (weather.Weather, error) {
-	response, err := http.Get(string(ds))
+	response, err := httpclient.Client().Get(string(ds))
 	if err != nil {
 		return weather.Weather{}, err
 	}
@@ -136,6 +136,8 @@ func (ds Provider) GetWeather() (weather.Weather, error) {
 			Direction: weather.Direction(d.Currently.WindBearing),
 		},
 		Attribution: "Dark Sky",
+		URL: fmt.Sprintf("https://darksky.net/forecast/%f,%f",
+			d.Latitude, d.Longitude),
 	}
 	if len(d.Daily.Data) >= 1 {
 		w.Sunrise = time.Unix(d.Daily.Data[0].SunriseTime, 0)
@@ -56,6 +56,7 @@ func TestGood(t *testing.T) {
 		Sunset:      time.Unix(1510003982, 0),
 		Updated:     time.Unix(1509993277, 0),
 		Attribution: "Dark Sky",
+		URL:         "https://darksky.net/forecast/42.360100,-71.058900",
 	}, wthr)
 }
 
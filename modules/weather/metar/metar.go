@@ -23,12 +23,12 @@ import (
 	"encoding/xml"
 	"fmt"
 	"math"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
+	"barista.run/httpclient"
 	"barista.run/modules/weather"
 
 	"github.com/martinlindhe/unit"
@@ -296,7 +296,7 @@ func (m metar) getCondition() weather.Condition {
 
 // GetWeather gets weather information from NOAA ADDS.
 func (p *provider) GetWeather() (weather.Weather, error) {
-	response, err := http.Get(p.url)
+	response, err := httpclient.Client().Get(p.url)
 	if err != nil {
 		return weather.Weather{}, err
 	}
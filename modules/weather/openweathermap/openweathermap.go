@@ -20,11 +20,13 @@ package openweathermap // import "barista.run/modules/weather/openweathermap"
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
 	"time"
 
+	"barista.run/doctor"
+	"barista.run/httpclient"
 	"barista.run/modules/weather"
 
 	"github.com/martinlindhe/unit"
@@ -36,6 +38,13 @@ type Config string
 
 // New creates a new OpenWeatherMap API configuration.
 func New(apiKey string) Config {
+	doctor.Register("OpenWeatherMap API key", func() error {
+		if apiKey != "" {
+			return nil
+		}
+		return doctor.Fix(errors.New("API key is not set"),
+			"Sign up for an API key at https://openweathermap.org/api")
+	})
 	return Config(apiKey)
 }
 
@@ -111,6 +120,7 @@ type owmWeather struct {
 		Sunrise int64
 		Sunset  int64
 	}
+	ID   int
 	Name string
 	Dt   int64
 }
@@ -166,7 +176,7 @@ func getCondition(owmCondition int) weather.Condition {
 
 // GetWeather gets weather information from OpenWeatherMap.
 func (owm Provider) GetWeather() (weather.Weather, error) {
-	response, err := http.Get(string(owm))
+	response, err := httpclient.Client().Get(string(owm))
 	if err != nil {
 		return weather.Weather{}, err
 	}
@@ -179,7 +189,7 @@ func (owm Provider) GetWeather() (weather.Weather, error) {
 	if len(o.Weather) < 1 {
 		return weather.Weather{}, fmt.Errorf("Bad response from OWM")
 	}
-	return weather.Weather{
+	w := weather.Weather{
 		Location:    o.Name,
 		Condition:   getCondition(o.Weather[0].ID),
 		Description: o.Weather[0].Description,
@@ -195,5 +205,9 @@ func (owm Provider) GetWeather() (weather.Weather, error) {
 			Direction: weather.Direction(int(o.Wind.Deg)),
 		},
 		Attribution: "OpenWeatherMap",
-	}, nil
+	}
+	if o.ID != 0 {
+		w.URL = fmt.Sprintf("https://openweathermap.org/city/%d", o.ID)
+	}
+	return w, nil
 }
@@ -56,6 +56,7 @@ func TestGood(t *testing.T) {
 		Sunset:      time.Unix(1435650870, 0),
 		Updated:     time.Unix(1435658272, 0),
 		Attribution: "OpenWeatherMap",
+		URL:         "https://openweathermap.org/city/2172797",
 	}, wthr)
 }
 
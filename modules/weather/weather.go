@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/actions"
 	"barista.run/base/notifier"
 	"barista.run/base/value"
 	l "barista.run/logging"
@@ -42,6 +43,8 @@ type Weather struct {
 	Sunset      time.Time
 	Updated     time.Time
 	Attribution string
+	// URL of the provider's page for the location, if available.
+	URL string
 }
 
 // Wind stores the wind speed and direction together.
@@ -101,14 +104,19 @@ type Module struct {
 func New(provider Provider) *Module {
 	m := &Module{
 		provider:  provider,
-		scheduler: timing.NewScheduler(),
+		scheduler: timing.NewScheduler().Named("weather"),
 	}
 	m.refreshFn, m.refreshCh = notifier.New()
 	l.Register(m, "outputFunc", "clickHandler", "scheduler")
-	// Default output is just the temperature and conditions.
+	// Default output is just the temperature and conditions, with a click
+	// to open the provider's page for the location.
 	m.Output(func(w Weather) bar.Output {
-		return outputs.Textf("%.1f℃ %s (%s)",
+		out := outputs.Textf("%.1f℃ %s (%s)",
 			w.Temperature.Celsius(), w.Description, w.Attribution)
+		if w.URL != "" {
+			out.OnClick(actions.OnClickOpen(w.URL))
+		}
+		return out
 	})
 	m.RefreshInterval(10 * time.Minute)
 	return m
@@ -120,7 +128,14 @@ func (m *Module) Output(outputFunc func(Weather) bar.Output) *Module {
 	return m
 }
 
-// RefreshInterval configures the polling frequency.
+// Remove unregisters the module's scheduler when the module is removed from
+// the bar.
+func (m *Module) Remove() {
+	m.scheduler.Unregister()
+}
+
+// RefreshInterval configures the polling frequency. Failed updates are
+// retried sooner at first, backing off to once an hour.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
 	m.scheduler.Every(interval)
 	return m
@@ -134,6 +149,7 @@ func (m *Module) Refresh() {
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	weather, err := m.provider.GetWeather()
+	m.scheduler.Result(err)
 	outputFunc := m.outputFunc.Get().(func(Weather) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
@@ -146,11 +162,13 @@ func (m *Module) Stream(s bar.Sink) {
 			outputFunc = m.outputFunc.Get().(func(Weather) bar.Output)
 		case <-m.scheduler.C:
 			weather, err = m.provider.GetWeather()
+			m.scheduler.Result(err)
 		case <-m.refreshCh:
 			if err != nil {
 				s(nil)
 			}
 			weather, err = m.provider.GetWeather()
+			m.scheduler.Result(err)
 		}
 	}
 }
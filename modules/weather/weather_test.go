@@ -18,12 +18,15 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"barista.run/bar"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
+	"barista.run/timing"
 
 	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
 )
 
 type testProvider struct {
@@ -69,9 +72,17 @@ func TestWeather(t *testing.T) {
 
 	testBar.Tick()
 	testBar.NextOutput().AssertError("on tick with error")
+	errAt := timing.Now()
 
 	testBar.Tick()
 	out := testBar.NextOutput("on tick with error")
+	require.Equal(t, errAt.Add(30*time.Second), timing.Now(),
+		"retries sooner after an error")
+
+	testBar.Tick()
+	testBar.NextOutput("on tick with error").AssertError()
+	require.Equal(t, errAt.Add(90*time.Second), timing.Now(),
+		"backs off on repeated errors")
 
 	p.Lock()
 	p.error = nil
@@ -81,3 +92,11 @@ func TestWeather(t *testing.T) {
 	p.Unlock()
 	testBar.NextOutput().AssertText([]string{"72, by FLDSMDFR"})
 }
+
+func TestRemove(t *testing.T) {
+	testBar.New(t)
+	w := New(&testProvider{})
+	require.Equal(t, w.scheduler, timing.Lookup("weather"), "scheduler is named")
+	w.Remove()
+	require.Nil(t, timing.Lookup("weather"), "unregistered on removal")
+}
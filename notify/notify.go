@@ -0,0 +1,193 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package notify sends desktop notifications using the freedesktop.org
+notifications service (org.freedesktop.Notifications), which is provided by
+notification daemons such as dunst and mako.
+
+In addition to sending one-off notifications, a Trigger can be used from a
+module's output function to notify when a threshold is crossed, e.g.
+
+	lowBattery := notify.When(notify.Notification{
+		Summary: "Battery low",
+		Urgency: notify.Critical,
+	})
+	battery.All().Output(func(i battery.Info) bar.Output {
+		lowBattery.Checkf(i.RemainingPct() < 10, "%d%% remaining", i.RemainingPct())
+		return outputs.Textf("%d%%", i.RemainingPct())
+	})
+
+Triggers only notify when their condition becomes true, and are rate limited,
+so that a condition that keeps changing does not flood the user with
+notifications.
+*/
+package notify // import "barista.run/notify"
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+	"barista.run/timing"
+
+	godbus "github.com/godbus/dbus"
+)
+
+// Urgency is the urgency level of a notification.
+type Urgency byte
+
+// Urgency levels defined by the notifications specification.
+const (
+	Low Urgency = iota
+	Normal
+	Critical
+)
+
+// Notification is a desktop notification.
+type Notification struct {
+	Summary string
+	Body    string
+	// Icon is an icon name from the icon theme, or a file:// URI.
+	Icon    string
+	Urgency Urgency
+	// Timeout is how long the notification is shown for. A zero timeout uses
+	// the notification daemon's default.
+	Timeout time.Duration
+}
+
+// AppName is the application name sent with notifications.
+var AppName = "barista"
+
+const (
+	notifyService = "org.freedesktop.Notifications"
+	notifyObject  = "/org/freedesktop/Notifications"
+	notifyIface   = "org.freedesktop.Notifications"
+)
+
+var busType = dbus.Session
+
+var (
+	watcher   *dbus.PropertiesWatcher
+	watcherMu sync.Mutex
+)
+
+func getWatcher() *dbus.PropertiesWatcher {
+	watcherMu.Lock()
+	defer watcherMu.Unlock()
+	if watcher == nil {
+		watcher = dbus.WatchProperties(busType,
+			notifyService, notifyObject, notifyIface)
+	}
+	return watcher
+}
+
+// Send sends a notification, and returns its id.
+func Send(n Notification) (uint32, error) {
+	return send(n, 0)
+}
+
+// send sends a notification, replacing the notification with the given id
+// if it is non-zero and still being shown.
+func send(n Notification, replaces uint32) (uint32, error) {
+	timeout := int32(-1)
+	if n.Timeout > 0 {
+		timeout = int32(n.Timeout / time.Millisecond)
+	}
+	hints := map[string]godbus.Variant{
+		"urgency": godbus.MakeVariant(byte(n.Urgency)),
+	}
+	res, err := getWatcher().Call("Notify",
+		AppName, replaces, n.Icon, n.Summary, n.Body,
+		[]string{}, hints, timeout)
+	if err != nil {
+		return 0, err
+	}
+	if len(res) > 0 {
+		if id, ok := res[0].(uint32); ok {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+// Trigger sends a notification when a condition becomes true.
+type Trigger struct {
+	notification Notification
+	interval     time.Duration
+
+	mu     sync.Mutex
+	active bool
+	last   time.Time
+	id     uint32
+}
+
+// When creates a trigger that sends the given notification each time its
+// condition becomes true. By default, notifications from the same trigger
+// are sent at most once every 5 minutes.
+func When(n Notification) *Trigger {
+	return &Trigger{notification: n, interval: 5 * time.Minute}
+}
+
+// RateLimit sets the minimum interval between notifications from the trigger.
+func (t *Trigger) RateLimit(interval time.Duration) *Trigger {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interval = interval
+	return t
+}
+
+// Check updates the condition, sending the notification if it has become true.
+// It is intended to be called with each update, e.g. from an output function.
+func (t *Trigger) Check(cond bool) {
+	t.check(cond, t.notification.Body)
+}
+
+// Checkf updates the condition, sending the notification with the body set to
+// the formatted string if the condition has become true.
+func (t *Trigger) Checkf(cond bool, format string, args ...interface{}) {
+	if !cond {
+		t.check(false, "")
+		return
+	}
+	t.check(true, fmt.Sprintf(format, args...))
+}
+
+func (t *Trigger) check(cond bool, body string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	wasActive := t.active
+	t.active = cond
+	if !cond || wasActive {
+		return
+	}
+	now := timing.Now()
+	if !t.last.IsZero() && now.Sub(t.last) < t.interval {
+		l.Fine("%s rate limited", l.ID(t))
+		return
+	}
+	t.last = now
+	n := t.notification
+	n.Body = body
+	// Replace the previous notification from this trigger, if it is still
+	// being shown, to avoid a pile up of similar notifications.
+	id, err := send(n, t.id)
+	if err != nil {
+		l.Log("Failed to send notification %q: %v", n.Summary, err)
+		return
+	}
+	t.id = id
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	"barista.run/timing"
+
+	godbus "github.com/godbus/dbus"
+	"github.com/stretchr/testify/require"
+)
+
+type sent struct {
+	replaces uint32
+	icon     string
+	summary  string
+	body     string
+	urgency  byte
+	timeout  int32
+}
+
+type notificationServer struct {
+	sync.Mutex
+	sent   []sent
+	lastID uint32
+}
+
+func (s *notificationServer) notify(args ...interface{}) ([]interface{}, error) {
+	s.Lock()
+	defer s.Unlock()
+	hints := args[6].(map[string]godbus.Variant)
+	s.sent = append(s.sent, sent{
+		replaces: args[1].(uint32),
+		icon:     args[2].(string),
+		summary:  args[3].(string),
+		body:     args[4].(string),
+		urgency:  hints["urgency"].Value().(byte),
+		timeout:  args[7].(int32),
+	})
+	s.lastID++
+	return []interface{}{s.lastID}, nil
+}
+
+func (s *notificationServer) take() []sent {
+	s.Lock()
+	defer s.Unlock()
+	r := s.sent
+	s.sent = nil
+	return r
+}
+
+func setupServer() *notificationServer {
+	busType = dbus.Test
+	watcherMu.Lock()
+	watcher = nil
+	watcherMu.Unlock()
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService(notifyService)
+	obj := srv.Object(notifyObject, notifyIface)
+	s := &notificationServer{}
+	obj.On("Notify", s.notify)
+	return s
+}
+
+func TestSend(t *testing.T) {
+	s := setupServer()
+
+	id, err := Send(Notification{Summary: "Hello", Body: "World"})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), id)
+	require.Equal(t, []sent{{summary: "Hello", body: "World",
+		urgency: byte(Low), timeout: -1}}, s.take())
+
+	id, err = Send(Notification{
+		Summary: "Disk full",
+		Icon:    "drive-harddisk",
+		Urgency: Critical,
+		Timeout: 3 * time.Second,
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), id)
+	require.Equal(t, []sent{{summary: "Disk full", icon: "drive-harddisk",
+		urgency: byte(Critical), timeout: 3000}}, s.take())
+
+	setupServer()
+	dbus.SetupTestBus()
+	_, err = Send(Notification{Summary: "Hello"})
+	require.Error(t, err, "without a notification service")
+}
+
+func TestTrigger(t *testing.T) {
+	timing.TestMode()
+	s := setupServer()
+
+	tr := When(Notification{Summary: "Battery low", Urgency: Critical})
+	tr.Check(false)
+	require.Empty(t, s.take(), "condition not met")
+
+	tr.Check(true)
+	require.Equal(t, []sent{{summary: "Battery low",
+		urgency: byte(Critical), timeout: -1}}, s.take())
+
+	tr.Check(true)
+	require.Empty(t, s.take(), "only notifies when condition becomes true")
+
+	tr.Check(false)
+	tr.Check(true)
+	require.Empty(t, s.take(), "rate limited")
+
+	tr.Check(false)
+	timing.AdvanceBy(5 * time.Minute)
+	tr.Checkf(true, "%d%% remaining", 5)
+	require.Equal(t, []sent{{replaces: 1, summary: "Battery low",
+		body: "5% remaining", urgency: byte(Critical), timeout: -1}}, s.take(),
+		"replaces previous notification")
+
+	tr.RateLimit(0)
+	tr.Checkf(false, "%d%% remaining", 50)
+	tr.Checkf(true, "%d%% remaining", 4)
+	require.Equal(t, "4% remaining", s.take()[0].body)
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+	"barista.run/outputs"
+
+	"golang.org/x/oauth2"
+)
+
+// DeviceAuth represents a pending authorization using the OAuth 2.0 device
+// authorization grant (RFC 8628). The user visits VerificationURL on any
+// device and enters UserCode, while the bar polls for the token.
+type DeviceAuth struct {
+	// UserCode is the short code that the user needs to enter.
+	UserCode string
+	// VerificationURL is the page where the user needs to enter the code.
+	VerificationURL string
+	// Expiry is the time at which the code expires.
+	Expiry time.Time
+
+	config     *Config
+	deviceCode string
+	interval   time.Duration
+}
+
+// Device flow errors, as defined by RFC 8628.
+var (
+	errAuthorizationPending = errors.New("authorization_pending")
+	errSlowDown             = errors.New("slow_down")
+)
+
+// Default polling interval if the provider does not specify one.
+const defaultDeviceInterval = 5 * time.Second
+
+// for tests.
+var sleep = time.Sleep
+
+// UseDeviceFlow configures the device authorization flow for this config,
+// using the provider's device authorization endpoint. This is useful on
+// headless setups, since the code can be entered on any device, instead of
+// requiring a browser that can reach the bar. When enabled, interactive setup
+// uses the device flow instead of prompting for an authorization code.
+func (c *Config) UseDeviceFlow(deviceAuthURL string) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deviceAuthURL = deviceAuthURL
+	return c
+}
+
+// StartDeviceAuth starts the device authorization flow, returning the code
+// that the user must enter to authorize the bar.
+func (c *Config) StartDeviceAuth() (*DeviceAuth, error) {
+	c.mu.Lock()
+	deviceAuthURL := c.deviceAuthURL
+	c.mu.Unlock()
+	if deviceAuthURL == "" {
+		return nil, errors.New("Device flow not enabled")
+	}
+	var resp struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURI string `json:"verification_uri"`
+		// Some providers (e.g. Google) use verification_url instead.
+		VerificationURL string `json:"verification_url"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+	err := postForm(deviceAuthURL, url.Values{
+		"client_id": {c.config.ClientID},
+		"scope":     {strings.Join(c.config.Scopes, " ")},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	d := &DeviceAuth{
+		UserCode:        resp.UserCode,
+		VerificationURL: resp.VerificationURI,
+		config:          c,
+		deviceCode:      resp.DeviceCode,
+		interval:        time.Duration(resp.Interval) * time.Second,
+	}
+	if d.VerificationURL == "" {
+		d.VerificationURL = resp.VerificationURL
+	}
+	if resp.ExpiresIn > 0 {
+		d.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	if d.interval <= 0 {
+		d.interval = defaultDeviceInterval
+	}
+	return d, nil
+}
+
+// Output returns a bar output that asks the user to enter the code.
+func (d *DeviceAuth) Output() bar.Output {
+	return outputs.Textf("Enter %s at %s", d.UserCode, d.VerificationURL)
+}
+
+// Wait polls for the token until the user has authorized the bar, or the
+// code expires. Once authorized, the token is saved and used by the config.
+func (d *DeviceAuth) Wait() (*oauth2.Token, error) {
+	for {
+		if !d.Expiry.IsZero() && time.Now().After(d.Expiry) {
+			return nil, errors.New("Device code expired")
+		}
+		sleep(d.interval)
+		tok, err := d.poll()
+		switch err {
+		case nil:
+			return tok, d.config.setToken(tok)
+		case errAuthorizationPending:
+			continue
+		case errSlowDown:
+			d.interval += defaultDeviceInterval
+			l.Fine("%s slowing down to %v", l.ID(d.config), d.interval)
+			continue
+		default:
+			return nil, err
+		}
+	}
+}
+
+func (d *DeviceAuth) poll() (*oauth2.Token, error) {
+	var resp struct {
+		Error        string `json:"error"`
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	c := d.config.config
+	err := postForm(c.Endpoint.TokenURL, url.Values{
+		"grant_type":    {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code":   {d.deviceCode},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+	}, &resp)
+	switch {
+	case resp.Error == errAuthorizationPending.Error():
+		return nil, errAuthorizationPending
+	case resp.Error == errSlowDown.Error():
+		return nil, errSlowDown
+	case resp.Error != "":
+		return nil, fmt.Errorf("Device authorization failed: %s", resp.Error)
+	case err != nil:
+		return nil, err
+	}
+	tok := &oauth2.Token{
+		AccessToken:  resp.AccessToken,
+		TokenType:    resp.TokenType,
+		RefreshToken: resp.RefreshToken,
+	}
+	if resp.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// postForm posts the form values to the given url, and decodes the JSON
+// response into out. The response is decoded even on HTTP errors, since
+// the device flow uses error responses to indicate a pending authorization.
+func postForm(endpoint string, values url.Values, out interface{}) error {
+	resp, err := http.PostForm(endpoint, values)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	decodeErr := json.NewDecoder(resp.Body).Decode(out)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP Status %d", resp.StatusCode)
+	}
+	return decodeErr
+}
@@ -0,0 +1,187 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+var deviceURL string
+
+var (
+	// Responses to device token polls, in order. Once exhausted, polls
+	// return an access token.
+	devicePolls   []string
+	devicePollsMu sync.Mutex
+)
+
+func respondToDevicePolls(responses ...string) {
+	devicePollsMu.Lock()
+	defer devicePollsMu.Unlock()
+	devicePolls = responses
+}
+
+func deviceCode(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("client_id") != "ClientID" || r.FormValue("scope") != "a b" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_code":      "devicecode",
+		"user_code":        "ABCD-EFGH",
+		"verification_uri": "https://example.com/device",
+		"expires_in":       1800,
+		"interval":         2,
+	})
+}
+
+func deviceToken(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("device_code") != "devicecode" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	devicePollsMu.Lock()
+	defer devicePollsMu.Unlock()
+	if len(devicePolls) > 0 {
+		resp := devicePolls[0]
+		devicePolls = devicePolls[1:]
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": resp})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  "mocktoken",
+		"token_type":    "bearer",
+		"expires_in":    tokenExpirySeconds,
+		"refresh_token": "mockrefreshtoken",
+	})
+}
+
+func recordSleeps() *[]time.Duration {
+	var sleeps []time.Duration
+	sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return &sleeps
+}
+
+func TestDeviceFlow(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+	sleeps := recordSleeps()
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	})
+	_, err := conf.StartDeviceAuth()
+	require.Error(err, "when device flow is not enabled")
+
+	conf.UseDeviceFlow(deviceURL)
+	d, err := conf.StartDeviceAuth()
+	require.NoError(err)
+	require.Equal("ABCD-EFGH", d.UserCode)
+	require.Equal("https://example.com/device", d.VerificationURL)
+	require.WithinDuration(time.Now().Add(30*time.Minute), d.Expiry, time.Minute)
+	txt, _ := d.Output().Segments()[0].Content()
+	require.Equal("Enter ABCD-EFGH at https://example.com/device", txt)
+
+	respondToDevicePolls("authorization_pending", "slow_down", "authorization_pending")
+	tok, err := d.Wait()
+	require.NoError(err)
+	require.Equal("mocktoken", tok.AccessToken)
+	require.Equal([]time.Duration{
+		2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second,
+	}, *sleeps, "polls at the given interval, slowing down when asked")
+
+	exists, _ := afero.Exists(fs, configFile())
+	require.True(exists, "token saved")
+	client, err := conf.Client()
+	require.NoError(err)
+	resp, _ := client.Get(checkURL)
+	require.Equal(200, resp.StatusCode)
+}
+
+func TestDeviceFlowErrors(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+	recordSleeps()
+
+	conf := Register(&oauth2.Config{
+		Endpoint: testEndpoint,
+		ClientID: "ClientID",
+		Scopes:   []string{"other"},
+	}).UseDeviceFlow(deviceURL)
+	_, err := conf.StartDeviceAuth()
+	require.Error(err, "on HTTP error")
+
+	conf = Register(&oauth2.Config{
+		Endpoint: testEndpoint,
+		ClientID: "ClientID",
+		Scopes:   []string{"a", "b"},
+	}).UseDeviceFlow(deviceURL)
+	d, err := conf.StartDeviceAuth()
+	require.NoError(err)
+	respondToDevicePolls("authorization_pending", "access_denied")
+	_, err = d.Wait()
+	require.Error(err, "when access is denied")
+	require.Contains(err.Error(), "access_denied")
+
+	d.Expiry = time.Now().Add(-time.Second)
+	_, err = d.Wait()
+	require.Error(err, "when code has expired")
+}
+
+func TestDeviceFlowSetup(t *testing.T) {
+	require := require.New(t)
+	mockStdout, _, exitCode := resetForTest()
+	recordSleeps()
+	defer func(args []string) { os.Args = args }(os.Args)
+
+	Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	}).UseDeviceFlow(deviceURL)
+
+	respondToDevicePolls("authorization_pending")
+	os.Args = []string{"arg0", "setup-oauth"}
+	go InteractiveSetup()
+	assertExitCode(t, exitCode, 0)
+	require.Equal(
+		`Updating registered Oauth configurations:
+
+[1 of 1] #pkg#.#testName#
+* Domain: #host#
+* Scopes: a, b
+- Visit https://example.com/device and enter the code ABCD-EFGH
++ Successfully updated token, expires #expiry#
+
+All tokens updated successfully
+`, sanitiseOauthOutput(mockStdout.ReadNow()))
+	exists, _ := afero.Exists(fs, configFile())
+	require.True(exists, "token saved")
+}
@@ -12,7 +12,27 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package oauth provides oauth capabilities to barista and modules.
+/*
+Package oauth provides oauth capabilities to barista and modules.
+
+Modules register their oauth configuration, either using an *oauth2.Config
+from a provider-specific package (Register), or by describing an arbitrary
+provider (RegisterProvider). For example, an out-of-tree module could use:
+
+	var spotify = oauth.RegisterProvider(oauth.Provider{
+		AuthURL:     "https://accounts.spotify.com/authorize",
+		TokenURL:    "https://accounts.spotify.com/api/token",
+		RedirectURL: "http://127.0.0.1:8888/callback",
+		PKCE:        true,
+	}, clientID, clientSecret, "user-read-currently-playing")
+
+	client, err := spotify.Client()
+
+Tokens for all registered configurations are obtained by running the bar with
+"setup-oauth", or from the bar itself using ReauthOutput, and are then
+refreshed and saved automatically. Tokens are stored in encrypted files by
+default (see SetEncryptionKey), or using SetStorage.
+*/
 package oauth // import "barista.run/oauth"
 
 import (
@@ -31,6 +51,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"barista.run/base/notifier"
+	"barista.run/doctor"
+	"barista.run/httpclient"
 	l "barista.run/logging"
 
 	"golang.org/x/oauth2"
@@ -42,13 +65,24 @@ import (
 type Config struct {
 	config   *oauth2.Config
 	filename string
+	// Key for token storage.
+	key string
 	// For more context during interactive auth
 	domain  string
+	account string
 	callers []string
+	// For the device authorization flow, if enabled.
+	deviceAuthURL string
+	// Whether to use PKCE in the authorization code flow.
+	pkce bool
 	// To support automatic saving of refreshed tokens.
 	tokenSource oauth2.TokenSource
 	token       *oauth2.Token
 	mu          sync.Mutex
+	// To support re-authentication from the bar.
+	authErr     error
+	reauth      *reauthState
+	authChanged notifier.Source
 }
 
 // Track all registered configs, so that InteractiveSetup() can work.
@@ -77,18 +111,36 @@ func getConfigDir() string {
 // added to the interactive oauth setup, so modules should usually call this
 // either in init() or in their New() functions.
 func Register(config *oauth2.Config) *Config {
+	return register(config, "", caller())
+}
+
+// RegisterAccount registers an oauth2 configuration for a specific account,
+// allowing the same configuration to be used with multiple accounts (e.g.
+// work and personal), each with its own token. The account is shown during
+// interactive setup and passed to the provider as a login hint.
+func RegisterAccount(config *oauth2.Config, account string) *Config {
+	return register(config, account, caller())
+}
+
+// caller returns the name of the function that called the exported
+// registration function.
+func caller() string {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return "<unknown>"
+	}
+	return runtime.FuncForPC(pc).Name()
+}
+
+func register(config *oauth2.Config, account, caller string) *Config {
 	if atomic.LoadInt32(&setupHasBeenCalled) != 0 {
 		panic("Cannot register after setup has been called!")
 	}
 	providerU, _ := url.Parse(config.Endpoint.AuthURL)
 	c := &Config{
-		config: config,
-		domain: providerU.Hostname(),
-	}
-	caller := "<unknown>"
-	pc, _, _, ok := runtime.Caller(1)
-	if ok {
-		caller = runtime.FuncForPC(pc).Name()
+		config:  config,
+		domain:  providerU.Hostname(),
+		account: account,
 	}
 	hasher := sha256.New224()
 	// Each token will be stored in config dir, in the form $provider_$hash.
@@ -100,9 +152,14 @@ func Register(config *oauth2.Config) *Config {
 	for _, scope := range config.Scopes {
 		io.WriteString(hasher, scope)
 	}
+	// The account is only included if set, so that tokens saved before
+	// accounts were supported continue to be used.
+	if account != "" {
+		io.WriteString(hasher, "account:"+account)
+	}
 	hash := base64.RawURLEncoding.EncodeToString(hasher.Sum(nil))
-	filename := filepath.Join(configDir,
-		fmt.Sprintf("%s_%s.json", c.domain, hash))
+	c.key = fmt.Sprintf("%s_%s", c.domain, hash)
+	filename := filepath.Join(configDir, c.key+".json")
 	// So the final resulting filename will be something like
 	// ~/.config/barista/oauth/accounts.google.com_MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3OA.json
 	c.filename = filename
@@ -116,9 +173,25 @@ func Register(config *oauth2.Config) *Config {
 	c.callers = []string{caller}
 	registeredConfigs = append(registeredConfigs, c)
 	registeredConfigsMap[filename] = c
+	doctor.Register(fmt.Sprintf("OAuth token for %s [%s]",
+		c.description(), commas(config.Scopes)), c.check)
 	return c
 }
 
+// description describes the configuration, for the user.
+func (c *Config) description() string {
+	if c.account != "" {
+		return fmt.Sprintf("%s (%s)", c.domain, c.account)
+	}
+	return c.domain
+}
+
+// check checks that a token has been saved for the configuration.
+func (c *Config) check() error {
+	_, err := getStorage().Load(c.key)
+	return doctor.Fix(err, "Run the bar with setup-oauth")
+}
+
 func (c *Config) addCaller(caller string) {
 	for _, cr := range c.callers {
 		if cr == caller {
@@ -154,7 +227,6 @@ func InteractiveSetup() {
 		osExit(0)
 		return
 	}
-	os.MkdirAll(configDir, 0700)
 	success := true
 	fmt.Fprintln(stdout, "Updating registered Oauth configurations:")
 	for idx, c := range registeredConfigs {
@@ -175,8 +247,12 @@ func commas(s []string) string {
 }
 
 func (c *Config) prompt(index, total int, force bool) bool {
-	fmt.Fprintf(stdout, "\n[%d of %d] %s\n* Domain: %s\n* Scopes: %s\n",
-		index+1, total, commas(c.callers), c.domain, commas(c.config.Scopes))
+	fmt.Fprintf(stdout, "\n[%d of %d] %s\n* Domain: %s\n",
+		index+1, total, commas(c.callers), c.domain)
+	if c.account != "" {
+		fmt.Fprintf(stdout, "* Account: %s\n", c.account)
+	}
+	fmt.Fprintf(stdout, "* Scopes: %s\n", commas(c.config.Scopes))
 
 	err := c.autoUpdateToken()
 	if err == nil {
@@ -200,14 +276,37 @@ func (c *Config) prompt(index, total int, force bool) bool {
 		fmt.Fprintf(stdout, "! Automatic refresh failed\n")
 	}
 
-	authURL := c.config.AuthCodeURL("no-state", oauth2.AccessTypeOffline)
-	fmt.Fprintf(stdout, "- Visit %v and enter the code here:\n> ", authURL)
-	var authCode string
-	if _, err = fmt.Fscan(stdin, &authCode); err == nil {
-		c.token, err = c.config.Exchange(oauth2.NoContext, authCode)
+	if c.deviceAuthURL != "" {
+		return c.promptDevice()
+	}
+
+	authOpts, exchangeOpts, err := c.authCodeOptions()
+	if err == nil {
+		authURL := c.config.AuthCodeURL("no-state", authOpts...)
+		fmt.Fprintf(stdout, "- Visit %v and enter the code here:\n> ", authURL)
+		var authCode string
+		if _, err = fmt.Fscan(stdin, &authCode); err == nil {
+			c.token, err = c.config.Exchange(oauth2.NoContext, authCode, exchangeOpts...)
+		}
+	}
+	if err == nil {
+		err = getStorage().Store(c.key, c.token)
 	}
+	if err != nil {
+		fmt.Fprintf(stdout, "! Failed to update token: %v\n", err)
+		return false
+	}
+	fmt.Fprintf(stdout, "+ Successfully updated token, %s\n",
+		formatExpiry(c.token.Expiry))
+	return true
+}
+
+func (c *Config) promptDevice() bool {
+	d, err := c.StartDeviceAuth()
 	if err == nil {
-		err = storeToken(c.filename, c.token)
+		fmt.Fprintf(stdout, "- Visit %s and enter the code %s\n",
+			d.VerificationURL, d.UserCode)
+		_, err = d.Wait()
 	}
 	if err != nil {
 		fmt.Fprintf(stdout, "! Failed to update token: %v\n", err)
@@ -227,7 +326,7 @@ func formatExpiry(expiry time.Time) string {
 
 func (c *Config) autoUpdateToken() error {
 	var err error
-	c.token, err = loadToken(c.filename)
+	c.token, err = getStorage().Load(c.key)
 	return err
 }
 
@@ -238,6 +337,7 @@ func (c *Config) Token() (*oauth2.Token, error) {
 	defer c.mu.Unlock()
 	if c.tokenSource == nil {
 		if err := c.autoUpdateToken(); err != nil {
+			c.setAuthErr(err)
 			return nil, err
 		}
 		c.tokenSource = c.config.TokenSource(context.Background(), c.token)
@@ -247,16 +347,39 @@ func (c *Config) Token() (*oauth2.Token, error) {
 	}
 	tok, err := c.tokenSource.Token()
 	if err != nil {
+		// The provider rejected the refresh token (e.g. because access was
+		// revoked), so the user needs to authorise barista again.
+		if _, ok := err.(*oauth2.RetrieveError); ok {
+			c.setAuthErr(err)
+		}
 		return nil, err
 	}
 	c.token = tok
-	return tok, storeToken(c.filename, tok)
+	return tok, getStorage().Store(c.key, tok)
+}
+
+// setToken saves a newly obtained token, and uses it for future requests.
+func (c *Config) setToken(tok *oauth2.Token) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = tok
+	c.tokenSource = c.config.TokenSource(context.Background(), tok)
+	c.setAuthErr(nil)
+	return getStorage().Store(c.key, tok)
 }
 
 // Client returns an http client that authorises requests using the previously
-// saved token for this configuration.
+// saved token for this configuration. Requests use the shared transport from
+// barista.run/httpclient.
 func (c *Config) Client() (*http.Client, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return oauth2.NewClient(context.Background(), c), c.autoUpdateToken()
+	err := c.autoUpdateToken()
+	if err != nil {
+		c.setAuthErr(err)
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpclient.Client())
+	client := oauth2.NewClient(ctx, c)
+	client.Timeout = httpclient.Timeout
+	return client, err
 }
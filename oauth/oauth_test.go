@@ -27,6 +27,7 @@ import (
 	"testing"
 	"time"
 
+	"barista.run/doctor"
 	"barista.run/testing/mockio"
 
 	"github.com/spf13/afero"
@@ -76,6 +77,7 @@ func resetForTest() (mockStdout *mockio.Writable, mockStdin *mockio.Readable, ex
 	mockStdout = mockio.Stdout()
 	stdout = mockStdout
 	configDir = "/conf/dir"
+	storage = EncryptedFiles()
 	exitCode = make(chan int, 1)
 	osExit = func(code int) { exitCode <- code }
 	resetKey([]byte("test"))
@@ -550,6 +552,35 @@ func TestOauthLoadNoSavedToken(t *testing.T) {
 	require.Error(err, "when no token is available")
 }
 
+func TestOauthDoctor(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		RedirectURL:  "localhost:1",
+		Scopes:       []string{"doctor"},
+	})
+	check := func() error {
+		for _, r := range doctor.Run() {
+			if r.Name == "OAuth token for "+testHostname()+" [doctor]" {
+				return r.Err
+			}
+		}
+		require.Fail("check not registered")
+		return nil
+	}
+
+	err := check()
+	require.Error(err, "when no token is available")
+	require.Equal("Run the bar with setup-oauth", doctor.FixOf(err))
+
+	require.NoError(storeToken(conf.filename, &oauth2.Token{AccessToken: "mocktoken"}))
+	require.NoError(check(), "with a saved token")
+}
+
 func TestOauthTokenAutoRefresh(t *testing.T) {
 	require := require.New(t)
 	resetForTest()
@@ -669,6 +700,70 @@ All tokens updated successfully
 `, sanitiseOauthOutput(mockStdout.ReadNow()))
 }
 
+func registerAccount(account string) *Config {
+	return RegisterAccount(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		RedirectURL:  "localhost:1",
+		Scopes:       []string{"a", "b"},
+	}, account)
+}
+
+func TestOauthAccounts(t *testing.T) {
+	require := require.New(t)
+	mockStdout, mockStdin, exitCode := resetForTest()
+
+	confA := registerA()
+	confWork := registerAccount("me@work.example.com")
+	confHome := registerAccount("me@home.example.com")
+
+	require.False(confA == confWork, "separate config per account")
+	require.False(confWork == confHome, "separate config per account")
+	require.True(confA == registerAccount(""), "no account is the same as Register")
+	require.True(confWork == registerAccount("me@work.example.com"), "re-uses config")
+
+	os.Args = []string{"arg0", "setup-oauth"}
+	go InteractiveSetup()
+	mockStdin.Write([]byte("authcode\n"))
+	mockStdin.Write([]byte("authcode\n"))
+	mockStdin.Write([]byte("authcode\n"))
+	assertExitCode(t, exitCode, 0)
+
+	out := mockStdout.ReadNow()
+	require.Equal(
+		`Updating registered Oauth configurations:
+
+[1 of 3] #pkg#.registerA, #pkg#.registerAccount
+* Domain: #host#
+* Scopes: a, b
+- Visit #authURL# and enter the code here:
+> + Successfully updated token, expires #expiry#
+
+[2 of 3] #pkg#.registerAccount
+* Domain: #host#
+* Account: me@work.example.com
+* Scopes: a, b
+- Visit #authURL# and enter the code here:
+> + Successfully updated token, expires #expiry#
+
+[3 of 3] #pkg#.registerAccount
+* Domain: #host#
+* Account: me@home.example.com
+* Scopes: a, b
+- Visit #authURL# and enter the code here:
+> + Successfully updated token, expires #expiry#
+
+All tokens updated successfully
+`, sanitiseOauthOutput(out))
+	require.Contains(out, "login_hint=me%40work.example.com")
+
+	entries, _ := afero.ReadDir(fs, "/conf/dir/")
+	require.Equal(3, len(entries), "All tokens saved to separate files")
+	_, err := fs.Stat(configFile())
+	require.NoError(err, "token without account uses existing filename")
+}
+
 func TestOauthRegisterAfterSetup(t *testing.T) {
 	require := require.New(t)
 	mockStdout, _, exitCode := resetForTest()
@@ -699,6 +794,7 @@ func TestMain(m *testing.M) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
 		if r.FormValue("code") == "authcode" {
+			setLastVerifier(r.FormValue("code_verifier"))
 			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
 			w.Write([]byte(strings.Join([]string{
 				"access_token=mocktoken",
@@ -708,6 +804,10 @@ func TestMain(m *testing.M) {
 			}, "&")))
 			return
 		}
+		if r.FormValue("grant_type") == "urn:ietf:params:oauth:grant-type:device_code" {
+			deviceToken(w, r)
+			return
+		}
 		if r.FormValue("refresh_token") == "mockrefreshtoken" {
 			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
 			w.Write([]byte(strings.Join([]string{
@@ -719,6 +819,7 @@ func TestMain(m *testing.M) {
 		}
 		w.WriteHeader(http.StatusUnauthorized)
 	})
+	mux.HandleFunc("/device", deviceCode)
 	mux.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Authorization") != "Bearer mocktoken" {
 			http.Error(w, "Missing Auth Header", http.StatusUnauthorized)
@@ -734,6 +835,7 @@ func TestMain(m *testing.M) {
 		TokenURL: server.URL + "/token",
 	}
 	checkURL = server.URL + "/check"
+	deviceURL = server.URL + "/device"
 
 	os.Exit(m.Run())
 }
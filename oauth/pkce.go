@@ -0,0 +1,65 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"golang.org/x/oauth2"
+)
+
+// Size of the random PKCE code verifier, before encoding. 32 bytes gives
+// a 43 character verifier, the minimum allowed by RFC 7636.
+const pkceVerifierSize = 32
+
+// UsePKCE enables Proof Key for Code Exchange (RFC 7636) in the authorization
+// code flow, using the S256 challenge method. Several providers require or
+// recommend PKCE for native applications, especially without a client secret.
+func (c *Config) UsePKCE() *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pkce = true
+	return c
+}
+
+// authCodeOptions returns the options for the authorization URL and for
+// exchanging the code, including a new PKCE verifier and challenge if enabled.
+func (c *Config) authCodeOptions() (auth, exchange []oauth2.AuthCodeOption, err error) {
+	auth = []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+	if c.account != "" {
+		auth = append(auth, oauth2.SetAuthURLParam("login_hint", c.account))
+	}
+	c.mu.Lock()
+	pkce := c.pkce
+	c.mu.Unlock()
+	if !pkce {
+		return auth, nil, nil
+	}
+	verifierBytes := make([]byte, pkceVerifierSize)
+	if _, err := randRead(verifierBytes); err != nil {
+		return nil, nil, err
+	}
+	verifier := base64.RawURLEncoding.EncodeToString(verifierBytes)
+	challenge := sha256.Sum256([]byte(verifier))
+	auth = append(auth,
+		oauth2.SetAuthURLParam("code_challenge",
+			base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+	exchange = []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("code_verifier", verifier),
+	}
+	return auth, exchange, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+var (
+	lastVerifier   string
+	lastVerifierMu sync.Mutex
+)
+
+func setLastVerifier(v string) {
+	lastVerifierMu.Lock()
+	defer lastVerifierMu.Unlock()
+	lastVerifier = v
+}
+
+func getLastVerifier() string {
+	lastVerifierMu.Lock()
+	defer lastVerifierMu.Unlock()
+	return lastVerifier
+}
+
+// capturePKCE wraps openBrowser to record the PKCE parameters in the
+// authorization URL before simulating the user's response.
+func capturePKCE(t *testing.T) (params url.Values) {
+	params = url.Values{}
+	browserResponse(t, url.Values{"code": {"authcode"}})
+	respond := openBrowser
+	openBrowser = func(authURL string) error {
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+		for _, k := range []string{"code_challenge", "code_challenge_method"} {
+			params.Set(k, u.Query().Get(k))
+		}
+		return respond(authURL)
+	}
+	return params
+}
+
+func TestPKCE(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"pkce"},
+	}).UsePKCE()
+
+	params := capturePKCE(t)
+	require.NoError(conf.Reauth())
+	require.Equal("S256", params.Get("code_challenge_method"))
+
+	verifier := getLastVerifier()
+	require.Len(verifier, 43, "verifier sent on code exchange")
+	sum := sha256.Sum256([]byte(verifier))
+	require.Equal(base64.RawURLEncoding.EncodeToString(sum[:]),
+		params.Get("code_challenge"), "challenge matches verifier")
+}
+
+func TestWithoutPKCE(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"no-pkce"},
+	})
+	setLastVerifier("stale")
+	params := capturePKCE(t)
+	require.NoError(conf.Reauth())
+	require.Empty(params.Get("code_challenge"))
+	require.Empty(params.Get("code_challenge_method"))
+	require.Empty(getLastVerifier(), "no verifier sent")
+}
+
+func TestProviderPKCE(t *testing.T) {
+	resetForTest()
+	conf := RegisterProvider(Provider{
+		AuthURL:  testEndpoint.AuthURL,
+		TokenURL: testEndpoint.TokenURL,
+		PKCE:     true,
+	}, "ClientID", "", "provider-pkce")
+
+	params := capturePKCE(t)
+	require.NoError(t, conf.Reauth())
+	require.Equal(t, "S256", params.Get("code_challenge_method"))
+	require.NotEmpty(t, getLastVerifier())
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"golang.org/x/oauth2"
+)
+
+// Provider describes the endpoints of an OAuth2 provider, which can be found
+// in the provider's API documentation.
+type Provider struct {
+	// AuthURL is the authorization endpoint, where the user grants access.
+	AuthURL string
+	// TokenURL is the token endpoint, used to exchange codes and refresh tokens.
+	TokenURL string
+	// DeviceAuthURL is the device authorization endpoint. It is optional, and
+	// if set, the device flow is used instead of the authorization code flow.
+	DeviceAuthURL string
+	// RedirectURL is the redirect URL registered with the provider, used when
+	// entering the authorization code during interactive setup.
+	RedirectURL string
+	// PKCE enables Proof Key for Code Exchange in the authorization code flow,
+	// which some providers require for clients without a client secret.
+	PKCE bool
+}
+
+// RegisterProvider registers an arbitrary OAuth2 provider with the given
+// client credentials and scopes, and returns a Config that provides an
+// authenticated *http.Client. Tokens are obtained using interactive setup or
+// from the bar, and are stored, refreshed, and saved automatically.
+//
+// Like Register, this should be called in init() or a module's New()
+// function, before Run() is called.
+func RegisterProvider(p Provider, clientID, clientSecret string, scopes ...string) *Config {
+	c := register(&oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.AuthURL,
+			TokenURL: p.TokenURL,
+		},
+		RedirectURL: p.RedirectURL,
+		Scopes:      scopes,
+	}, "", caller())
+	if p.DeviceAuthURL != "" {
+		c.UseDeviceFlow(p.DeviceAuthURL)
+	}
+	if p.PKCE {
+		c.UsePKCE()
+	}
+	return c
+}
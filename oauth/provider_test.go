@@ -0,0 +1,64 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestRegisterProvider(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+
+	conf := RegisterProvider(Provider{
+		AuthURL:     testEndpoint.AuthURL,
+		TokenURL:    testEndpoint.TokenURL,
+		RedirectURL: "localhost:1",
+	}, "ClientID", "not-really-secret", "a", "b")
+	require.Equal(conf, Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	}), "same provider and scopes uses the same token")
+	require.Equal([]string{pkgName + ".TestRegisterProvider"}, conf.callers)
+	require.Empty(conf.deviceAuthURL)
+
+	_, err := conf.Client()
+	require.Error(err, "with no token")
+
+	require.NoError(EncryptedFiles().Store(conf.key, &oauth2.Token{
+		AccessToken:  "expiredtoken",
+		RefreshToken: "mockrefreshtoken",
+		Expiry:       time.Now().Add(-time.Hour),
+	}))
+	client, err := conf.Client()
+	require.NoError(err)
+	resp, err := client.Get(checkURL)
+	require.NoError(err)
+	require.Equal(200, resp.StatusCode, "refreshes and uses token")
+
+	other := RegisterProvider(Provider{
+		AuthURL:       testEndpoint.AuthURL,
+		TokenURL:      testEndpoint.TokenURL,
+		DeviceAuthURL: deviceURL,
+	}, "ClientID", "not-really-secret", "c")
+	require.NotEqual(conf, other, "different scopes use a different token")
+	require.Equal(deviceURL, other.deviceAuthURL)
+}
@@ -0,0 +1,194 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/actions"
+	"barista.run/base/click"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// reauthState tracks an in-progress re-authentication from the bar.
+type reauthState struct {
+	// For the device flow, the code that the user needs to enter.
+	device *DeviceAuth
+}
+
+// How long to wait for the user to complete authorization in the browser.
+var browserTimeout = 5 * time.Minute
+
+// for tests.
+var openBrowser = actions.OpenURL
+
+// setAuthErr records whether the config needs to be authorised again.
+// Must be called with the lock held.
+func (c *Config) setAuthErr(err error) {
+	changed := (err == nil) != (c.authErr == nil)
+	c.authErr = err
+	if changed {
+		c.authChanged.Notify()
+	}
+}
+
+// NeedsAuth returns true if there is no usable token for this config, either
+// because barista was never authorised, or because the provider rejected the
+// saved token (e.g. because access was revoked).
+func (c *Config) NeedsAuth() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.authErr != nil
+}
+
+// AuthChanged returns a channel that is closed on the next change to the
+// authentication state, e.g. when a new token is stored after re-authentication.
+// Modules can use this to refresh their output and resume once authorised.
+func (c *Config) AuthChanged() <-chan struct{} {
+	return c.authChanged.Next()
+}
+
+// ReauthOutput returns an output for modules to show when NeedsAuth is true.
+// Clicking on it starts the authorization flow from the bar, opening the
+// provider's authorization page in a browser, or showing the code to enter if
+// the device flow is enabled. The output changes as the flow progresses, which
+// is signalled by AuthChanged.
+func (c *Config) ReauthOutput() bar.Output {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.reauth == nil:
+		return outputs.Text("Auth needed, click to re-authenticate").
+			OnClick(click.Left(func() { go c.Reauth() }))
+	case c.reauth.device != nil:
+		return c.reauth.device.Output()
+	default:
+		return outputs.Text("Waiting for authentication")
+	}
+}
+
+// Reauth runs the authorization flow from the bar, and stores the new token.
+// It uses the device flow if enabled, otherwise it opens the authorization
+// page in a browser, using a temporary local server to receive the code.
+func (c *Config) Reauth() error {
+	c.mu.Lock()
+	if c.reauth != nil {
+		c.mu.Unlock()
+		return errors.New("Authentication already in progress")
+	}
+	c.reauth = &reauthState{}
+	useDevice := c.deviceAuthURL != ""
+	c.mu.Unlock()
+	c.authChanged.Notify()
+
+	var err error
+	if useDevice {
+		err = c.deviceReauth()
+	} else {
+		err = c.browserReauth()
+	}
+	if err != nil {
+		l.Log("Re-authentication failed for %s: %v", c.key, err)
+	}
+
+	c.mu.Lock()
+	c.reauth = nil
+	c.mu.Unlock()
+	c.authChanged.Notify()
+	return err
+}
+
+func (c *Config) deviceReauth() error {
+	d, err := c.StartDeviceAuth()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.reauth.device = d
+	c.mu.Unlock()
+	c.authChanged.Notify()
+	_, err = d.Wait()
+	return err
+}
+
+func (c *Config) browserReauth() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	conf := *c.config
+	conf.RedirectURL = "http://" + listener.Addr().String()
+
+	nonce := make([]byte, 16)
+	if _, err := randRead(nonce); err != nil {
+		listener.Close()
+		return err
+	}
+	state := base64.RawURLEncoding.EncodeToString(nonce)
+
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("state") != state {
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+		if e := r.FormValue("error"); e != "" {
+			fmt.Fprintf(w, "Authorization failed: %s\n", e)
+			select {
+			case errCh <- fmt.Errorf("Authorization failed: %s", e):
+			default:
+			}
+			return
+		}
+		fmt.Fprintln(w, "Authorization complete, you can close this page.")
+		select {
+		case codeCh <- r.FormValue("code"):
+		default:
+		}
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	authOpts, exchangeOpts, err := c.authCodeOptions()
+	if err != nil {
+		return err
+	}
+	if err := openBrowser(conf.AuthCodeURL(state, authOpts...)); err != nil {
+		return err
+	}
+
+	var code string
+	select {
+	case code = <-codeCh:
+	case err := <-errCh:
+		return err
+	case <-time.After(browserTimeout):
+		return errors.New("Timed out waiting for authorization")
+	}
+	tok, err := conf.Exchange(context.Background(), code, exchangeOpts...)
+	if err != nil {
+		return err
+	}
+	return c.setToken(tok)
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func segmentText(o bar.Output) string {
+	txt, _ := o.Segments()[0].Content()
+	return txt
+}
+
+// browserResponse sets up openBrowser to simulate a user completing the
+// authorization page, redirecting with the given query parameters.
+func browserResponse(t *testing.T, params url.Values) {
+	openBrowser = func(authURL string) error {
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+		redirect := u.Query().Get("redirect_uri")
+		require.True(t, strings.HasPrefix(redirect, "http://127.0.0.1:"),
+			"redirects to local server")
+		go func() {
+			http.Get(redirect + "?state=wrong&code=authcode")
+			params.Set("state", u.Query().Get("state"))
+			http.Get(redirect + "?" + params.Encode())
+		}()
+		return nil
+	}
+}
+
+func TestReauth(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	})
+	require.False(conf.NeedsAuth(), "before any token is loaded")
+	changed := conf.AuthChanged()
+
+	client, err := conf.Client()
+	require.Error(err, "with no token")
+	require.True(conf.NeedsAuth())
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		require.Fail("AuthChanged not signalled")
+	}
+	require.Equal("Auth needed, click to re-authenticate",
+		segmentText(conf.ReauthOutput()))
+
+	browserResponse(t, url.Values{"error": {"access_denied"}})
+	require.Error(conf.Reauth(), "when authorization is denied")
+	require.True(conf.NeedsAuth())
+
+	browserResponse(t, url.Values{"code": {"authcode"}})
+	changed = conf.AuthChanged()
+	require.NoError(conf.Reauth())
+	require.False(conf.NeedsAuth())
+	<-changed
+
+	resp, err := client.Get(checkURL)
+	require.NoError(err)
+	require.Equal(200, resp.StatusCode, "existing client uses new token")
+}
+
+func TestReauthClick(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	})
+	conf.Client()
+	require.True(conf.NeedsAuth())
+
+	opened := make(chan string)
+	proceed := make(chan struct{})
+	openBrowser = func(authURL string) error {
+		opened <- authURL
+		<-proceed
+		return errors.New("no browser")
+	}
+	conf.ReauthOutput().Segments()[0].Click(bar.Event{Button: bar.ButtonLeft})
+	<-opened
+	require.Equal("Waiting for authentication", segmentText(conf.ReauthOutput()))
+	require.Error(conf.Reauth(), "while authentication is in progress")
+
+	changed := conf.AuthChanged()
+	close(proceed)
+	<-changed
+	require.Equal("Auth needed, click to re-authenticate",
+		segmentText(conf.ReauthOutput()), "after failed authentication")
+}
+
+func TestReauthRevoked(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	})
+	require.NoError(storeToken(configFile(), &oauth2.Token{
+		AccessToken:  "expiredtoken",
+		RefreshToken: "revokedtoken",
+		Expiry:       time.Now().Add(-time.Hour),
+	}))
+	client, err := conf.Client()
+	require.NoError(err)
+	require.False(conf.NeedsAuth())
+
+	_, err = client.Get(checkURL)
+	require.Error(err, "when refresh token is revoked")
+	require.True(conf.NeedsAuth())
+}
+
+func TestReauthDevice(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+	polling := make(chan struct{})
+	proceed := make(chan struct{})
+	sleep = func(time.Duration) {
+		polling <- struct{}{}
+		<-proceed
+	}
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	}).UseDeviceFlow(deviceURL)
+	conf.Client()
+	require.True(conf.NeedsAuth())
+
+	respondToDevicePolls()
+	errs := make(chan error)
+	go func() { errs <- conf.Reauth() }()
+	<-polling
+	require.Equal("Enter ABCD-EFGH at https://example.com/device",
+		segmentText(conf.ReauthOutput()), "shows device code")
+	close(proceed)
+	require.NoError(<-errs)
+	require.False(conf.NeedsAuth())
+}
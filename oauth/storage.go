@@ -0,0 +1,134 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	l "barista.run/logging"
+
+	"golang.org/x/oauth2"
+)
+
+// Storage stores oauth tokens. Each registered configuration uses a unique
+// key, which includes the provider's domain.
+type Storage interface {
+	// Load returns the token previously stored under the given key.
+	Load(key string) (*oauth2.Token, error)
+	// Store saves the token under the given key.
+	Store(key string, token *oauth2.Token) error
+}
+
+var (
+	storage   Storage = EncryptedFiles()
+	storageMu sync.Mutex
+)
+
+// SetStorage sets the storage used for oauth tokens. It should be called
+// before any modules are created, and before Run().
+func SetStorage(s Storage) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	storage = s
+}
+
+func getStorage() Storage {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	return storage
+}
+
+type encryptedFiles struct{}
+
+// EncryptedFiles returns a Storage that saves tokens in files under
+// $XDG_CONFIG_HOME/barista/oauth (~/.config if unset), encrypted using keys
+// derived from the key set in SetEncryptionKey. This is the default storage.
+func EncryptedFiles() Storage {
+	return encryptedFiles{}
+}
+
+func (encryptedFiles) filename(key string) string {
+	return filepath.Join(configDir, key+".json")
+}
+
+func (e encryptedFiles) Load(key string) (*oauth2.Token, error) {
+	return loadToken(e.filename(key))
+}
+
+func (e encryptedFiles) Store(key string, token *oauth2.Token) error {
+	if err := fs.MkdirAll(configDir, 0700); err != nil {
+		return err
+	}
+	return storeToken(e.filename(key), token)
+}
+
+type secretService struct{ fallback Storage }
+
+// SecretService returns a Storage that saves tokens using the freedesktop
+// Secret Service (e.g. gnome-keyring or KWallet), through the secret-tool
+// command from libsecret. If the secret service is not available, or does not
+// have a token, the fallback storage is used instead, which also allows
+// tokens to be migrated from the fallback storage. The fallback can be nil.
+func SecretService(fallback Storage) Storage {
+	return secretService{fallback}
+}
+
+// for tests.
+var secretTool = func(stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = stdin
+	return cmd.Output()
+}
+
+func secretAttrs(key string) []string {
+	return []string{"application", "barista", "oauth", key}
+}
+
+func (s secretService) Load(key string) (*oauth2.Token, error) {
+	tok, err := s.lookup(key)
+	if err != nil && s.fallback != nil {
+		l.Fine("secret service lookup for %s failed (%v), using fallback", key, err)
+		return s.fallback.Load(key)
+	}
+	return tok, err
+}
+
+func (s secretService) lookup(key string) (*oauth2.Token, error) {
+	out, err := secretTool(nil, append([]string{"lookup"}, secretAttrs(key)...)...)
+	if err != nil {
+		return nil, err
+	}
+	tok := &oauth2.Token{}
+	if err := json.Unmarshal(out, tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+func (s secretService) Store(key string, token *oauth2.Token) error {
+	data, _ := json.Marshal(token) // no error, input is only strings and time.
+	args := append([]string{"store", "--label=barista oauth: " + key}, secretAttrs(key)...)
+	_, err := secretTool(bytes.NewReader(data), args...)
+	if err == nil || s.fallback == nil {
+		return err
+	}
+	l.Log("Failed to store %s in secret service (%v), using fallback", key, err)
+	return s.fallback.Store(key, token)
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestEncryptedFilesStorage(t *testing.T) {
+	resetForTest()
+	s := EncryptedFiles()
+
+	_, err := s.Load("example.com_abcd")
+	require.Error(t, err, "with no token")
+
+	require.NoError(t, s.Store("example.com_abcd", &oauth2.Token{AccessToken: "foo"}))
+	exists, _ := afero.Exists(fs, "/conf/dir/example.com_abcd.json")
+	require.True(t, exists, "creates config dir and token file")
+
+	tok, err := s.Load("example.com_abcd")
+	require.NoError(t, err)
+	require.Equal(t, "foo", tok.AccessToken)
+}
+
+// fakeSecretTool implements secret-tool lookup/store using a map.
+type fakeSecretTool struct {
+	secrets     map[string]string
+	unavailable bool
+}
+
+func (f *fakeSecretTool) run(stdin io.Reader, args ...string) ([]byte, error) {
+	if f.unavailable {
+		return nil, errors.New("exec: secret-tool: not found")
+	}
+	attrs := strings.Join(args[len(args)-4:], ",")
+	switch args[0] {
+	case "lookup":
+		if secret, ok := f.secrets[attrs]; ok {
+			return []byte(secret), nil
+		}
+		return nil, errors.New("exit status 1")
+	case "store":
+		if !strings.HasPrefix(args[1], "--label=") {
+			return nil, errors.New("missing label")
+		}
+		secret, _ := ioutil.ReadAll(stdin)
+		f.secrets[attrs] = string(secret)
+		return nil, nil
+	}
+	return nil, errors.New("unknown command")
+}
+
+func setupSecretTool() *fakeSecretTool {
+	f := &fakeSecretTool{secrets: map[string]string{}}
+	secretTool = f.run
+	return f
+}
+
+func TestSecretServiceStorage(t *testing.T) {
+	resetForTest()
+	tool := setupSecretTool()
+	s := SecretService(nil)
+
+	_, err := s.Load("example.com_abcd")
+	require.Error(t, err, "with no token")
+
+	require.NoError(t, s.Store("example.com_abcd", &oauth2.Token{AccessToken: "foo"}))
+	require.Contains(t, tool.secrets["application,barista,oauth,example.com_abcd"], `"foo"`)
+	tok, err := s.Load("example.com_abcd")
+	require.NoError(t, err)
+	require.Equal(t, "foo", tok.AccessToken)
+
+	tool.secrets["application,barista,oauth,example.com_abcd"] = "not-json"
+	_, err = s.Load("example.com_abcd")
+	require.Error(t, err, "with invalid token")
+
+	tool.unavailable = true
+	_, err = s.Load("example.com_abcd")
+	require.Error(t, err, "when secret service is unavailable")
+	require.Error(t, s.Store("example.com_abcd", &oauth2.Token{}))
+}
+
+func TestSecretServiceFallback(t *testing.T) {
+	resetForTest()
+	tool := setupSecretTool()
+	files := EncryptedFiles()
+	s := SecretService(files)
+
+	require.NoError(t, files.Store("example.com_abcd", &oauth2.Token{AccessToken: "file"}))
+	tok, err := s.Load("example.com_abcd")
+	require.NoError(t, err)
+	require.Equal(t, "file", tok.AccessToken, "loads existing tokens from fallback")
+
+	require.NoError(t, s.Store("example.com_abcd", &oauth2.Token{AccessToken: "secret"}))
+	tok, err = s.Load("example.com_abcd")
+	require.NoError(t, err)
+	require.Equal(t, "secret", tok.AccessToken, "secret service takes precedence")
+	tok, _ = files.Load("example.com_abcd")
+	require.Equal(t, "file", tok.AccessToken, "fallback unchanged")
+
+	tool.unavailable = true
+	require.NoError(t, s.Store("example.com_efgh", &oauth2.Token{AccessToken: "new"}))
+	tok, err = files.Load("example.com_efgh")
+	require.NoError(t, err)
+	require.Equal(t, "new", tok.AccessToken,
+		"stores in fallback when secret service is unavailable")
+}
+
+func TestSetStorage(t *testing.T) {
+	require := require.New(t)
+	resetForTest()
+	tool := setupSecretTool()
+	SetStorage(SecretService(nil))
+
+	conf := Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     "ClientID",
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	})
+	_, err := conf.Client()
+	require.Error(err, "with no token")
+
+	require.NoError(SecretService(nil).Store(conf.key, &oauth2.Token{
+		AccessToken:  "mocktoken",
+		RefreshToken: "mockrefreshtoken",
+	}))
+	client, err := conf.Client()
+	require.NoError(err)
+	resp, _ := client.Get(checkURL)
+	require.Equal(200, resp.StatusCode)
+	require.Len(tool.secrets, 1)
+
+	exists, _ := afero.Exists(fs, configFile())
+	require.False(exists, "no files used with secret service storage")
+}
@@ -35,8 +35,12 @@ Example usage:
 package icons // import "barista.run/pango/icons"
 
 import (
+	"fmt"
+	"os/exec"
 	"strconv"
+	"strings"
 
+	"barista.run/doctor"
 	"barista.run/pango"
 )
 
@@ -85,9 +89,34 @@ func (p *Provider) Symbol(name, value string) {
 	p.symbols[name] = value
 }
 
-// Font sets the font set on the returned pango nodes.
+// Font sets the font set on the returned pango nodes, and registers a check
+// that the font is installed (see barista.Doctor).
 func (p *Provider) Font(font string) {
 	p.AddStyle(func(n *pango.Node) { n.Font(font) })
+	doctor.Register("Font "+font, func() error { return checkFont(font) })
+}
+
+// fontFamilies returns the installed font families, one or more per line.
+// It can be replaced in tests.
+var fontFamilies = func() (string, error) {
+	out, err := exec.Command("fc-list", ":", "family").Output()
+	return string(out), err
+}
+
+func checkFont(font string) error {
+	families, err := fontFamilies()
+	if err != nil {
+		return doctor.Fix(err, "Install fontconfig (fc-list) to check fonts")
+	}
+	for _, line := range strings.Split(families, "\n") {
+		for _, family := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(family), font) {
+				return nil
+			}
+		}
+	}
+	return doctor.Fix(fmt.Errorf("font '%s' is not installed", font),
+		"Link the ttf from the icon repository into ~/.fonts and run fc-cache")
 }
 
 // AddStyle sets additional styles on all returned pango nodes.
@@ -15,9 +15,11 @@
 package icons
 
 import (
+	"errors"
 	"testing"
 
 	"barista.run/colors"
+	"barista.run/doctor"
 	"barista.run/pango"
 	pangoTesting "barista.run/testing/pango"
 
@@ -76,3 +78,19 @@ func TestIconProvider(t *testing.T) {
 		"Append adds new elements without icon font styling",
 	)
 }
+
+func TestCheckFont(t *testing.T) {
+	fontFamilies = func() (string, error) {
+		return "DejaVu Sans\nMaterial Icons\nFont Awesome 5 Free,Font Awesome 5 Free Solid\n", nil
+	}
+	require.NoError(t, checkFont("Material Icons"))
+	require.NoError(t, checkFont("font awesome 5 free"), "case insensitive")
+	require.NoError(t, checkFont("Font Awesome 5 Free Solid"))
+
+	err := checkFont("Typicons")
+	require.Error(t, err)
+	require.Contains(t, doctor.FixOf(err), "~/.fonts")
+
+	fontFamilies = func() (string, error) { return "", errors.New("no fc-list") }
+	require.EqualError(t, checkFont("Material Icons"), "no fc-list")
+}
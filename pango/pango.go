@@ -150,6 +150,17 @@ func (n *Node) String() string {
 		return html.EscapeString(n.content)
 	}
 	var out bytes.Buffer
+	n.writeTo(&out)
+	return out.String()
+}
+
+// writeTo writes the pango markup for the node and all its children to out,
+// so that nested nodes do not each allocate their own string.
+func (n *Node) writeTo(out *bytes.Buffer) {
+	if n.nodeType == ntText {
+		out.WriteString(html.EscapeString(n.content))
+		return
+	}
 	if n.content != "" {
 		out.WriteString("<")
 		out.WriteString(n.content)
@@ -163,14 +174,13 @@ func (n *Node) String() string {
 		out.WriteString(">")
 	}
 	for _, c := range n.children {
-		out.WriteString(c.String())
+		c.writeTo(out)
 	}
 	if n.content != "" {
 		out.WriteString("</")
 		out.WriteString(n.content)
 		out.WriteString(">")
 	}
-	return out.String()
 }
 
 // Segments implements bar.Output for a single pango Node.
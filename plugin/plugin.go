@@ -0,0 +1,121 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package plugin loads modules from Go plugins, so that custom modules can be
+added to a bar without rebuilding it.
+
+A plugin is a main package built using "go build -buildmode=plugin", which
+exports the entry point:
+
+	func NewModule(config json.RawMessage) bar.Module
+
+where config is the JSON configuration given when loading the plugin (null if
+none), which the plugin can unmarshal into its own configuration type.
+
+Plugins must be built using the same version of Go, and the same versions of
+barista and any other packages they share with the bar, as the bar that loads
+them. Go only supports plugins on some platforms (e.g. Linux and macOS), and
+only with cgo enabled; elsewhere loading a plugin returns an error.
+*/
+package plugin // import "barista.run/plugin"
+
+import (
+	"encoding/json"
+	"fmt"
+	goplugin "plugin"
+	"strings"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+)
+
+// EntryPoint is the name of the function that plugins must export.
+const EntryPoint = "NewModule"
+
+// symbols looks up symbols in a plugin, to allow tests to avoid building real
+// plugins.
+type symbols interface {
+	Lookup(name string) (goplugin.Symbol, error)
+}
+
+var open = func(path string) (symbols, error) {
+	return goplugin.Open(path)
+}
+
+// Load opens the plugin at the given path, and creates a module by calling its
+// entry point with the given configuration.
+func Load(path string, config json.RawMessage) (bar.Module, error) {
+	p, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(EntryPoint)
+	if err != nil {
+		return nil, err
+	}
+	newModule, ok := sym.(func(json.RawMessage) bar.Module)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s has type %T, expected %T",
+			path, EntryPoint, sym, newModule)
+	}
+	if config == nil {
+		config = json.RawMessage("null")
+	}
+	m := newModule(config)
+	if m == nil {
+		return nil, fmt.Errorf("%s: %s returned no module", path, EntryPoint)
+	}
+	l.Label(m, path)
+	return m, nil
+}
+
+// FromArgs loads the plugins given using --plugin in the command line
+// arguments, and returns the modules along with the remaining arguments. Each
+// plugin can be given its configuration after an '=', for example:
+//
+//	mybar --plugin ./weather.so --plugin './counter.so={"start": 5}'
+//
+// Both "--plugin path" and "--plugin=path" are accepted.
+func FromArgs(args []string) (modules []bar.Module, rest []string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--plugin":
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("--plugin requires a path")
+			}
+			i++
+			arg = args[i]
+		case strings.HasPrefix(arg, "--plugin="):
+			arg = strings.TrimPrefix(arg, "--plugin=")
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		path, config := arg, json.RawMessage(nil)
+		if idx := strings.Index(arg, "="); idx >= 0 {
+			path, config = arg[:idx], json.RawMessage(arg[idx+1:])
+			if !json.Valid(config) {
+				return nil, nil, fmt.Errorf("%s: invalid configuration %s", path, config)
+			}
+		}
+		m, err := Load(path, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		modules = append(modules, m)
+	}
+	return modules, rest, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	goplugin "plugin"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/modules/static"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakePlugin map[string]goplugin.Symbol
+
+func (f fakePlugin) Lookup(name string) (goplugin.Symbol, error) {
+	if sym, ok := f[name]; ok {
+		return sym, nil
+	}
+	return nil, errors.New("symbol not found")
+}
+
+var plugins = map[string]fakePlugin{
+	"text.so": {EntryPoint: func(config json.RawMessage) bar.Module {
+		return static.New(outputs.Text(string(config)))
+	}},
+	"nil.so":     {EntryPoint: func(json.RawMessage) bar.Module { return nil }},
+	"wrong.so":   {EntryPoint: func() bar.Module { return nil }},
+	"missing.so": {},
+}
+
+func init() {
+	open = func(path string) (symbols, error) {
+		if p, ok := plugins[path]; ok {
+			return p, nil
+		}
+		return nil, errors.New("plugin not found")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	testBar.New(t)
+	m, err := Load("text.so", json.RawMessage(`{"a": 1}`))
+	require.NoError(t, err)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{`{"a": 1}`})
+
+	testBar.New(t)
+	m, err = Load("text.so", nil)
+	require.NoError(t, err)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"null"}, "without configuration")
+
+	for _, path := range []string{"other.so", "missing.so", "wrong.so", "nil.so"} {
+		_, err = Load(path, nil)
+		require.Error(t, err, path)
+	}
+	_, err = Load("wrong.so", nil)
+	require.Contains(t, err.Error(), "has type func() bar.Module")
+}
+
+func TestFromArgs(t *testing.T) {
+	mods, rest, err := FromArgs([]string{
+		"--plugin", "text.so", "--foo", "--plugin=text.so", "bar",
+		"--plugin", `text.so={"x": "y"}`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"--foo", "bar"}, rest)
+	require.Len(t, mods, 3)
+
+	testBar.New(t)
+	testBar.Run(mods...)
+	testBar.LatestOutput().AssertText([]string{"null", "null", `{"x": "y"}`})
+
+	mods, rest, err = FromArgs([]string{"a", "b"})
+	require.NoError(t, err)
+	require.Empty(t, mods)
+	require.Equal(t, []string{"a", "b"}, rest)
+
+	for _, args := range [][]string{
+		{"--plugin"},
+		{"--plugin", "other.so"},
+		{"--plugin", "text.so={invalid"},
+	} {
+		_, _, err = FromArgs(args)
+		require.Error(t, err, "%v", args)
+	}
+}
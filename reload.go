@@ -0,0 +1,81 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"fmt"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+)
+
+// OnReload sets the function that creates the modules for the bar when it is
+// reloaded, either by calling Reload or by sending the bar a SIGHUP (unless
+// signals are suppressed). This can be used to rebuild the bar from a
+// configuration file without restarting it, and the connection to i3bar is
+// kept across reloads.
+//
+// Modules that are returned again (i.e. the same instance) keep running and
+// keep their state, and new modules are started. Since modules cannot be
+// stopped, modules that are no longer on the bar are hidden and blocked the
+// next time they update, so they must not be returned by later reloads; create
+// a new instance instead. Removed modules that implement bar.RemovableModule
+// are notified, so that they can release shared resources. Reusing unchanged
+// modules is preferable to recreating them on each reload. If the function
+// returns an error, the bar is left unchanged and the error is reported to the
+// error handler. Must be called before Run.
+func OnReload(fn func() ([]bar.Module, error)) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot set reload function after .Run()")
+	}
+	instance.reloadFn = fn
+}
+
+// Reload reloads the bar using the function given to OnReload. Multiple calls
+// before the bar is reloaded only result in a single reload.
+func Reload() {
+	construct()
+	select {
+	case instance.reload <- struct{}{}:
+	default:
+		// A reload is already pending.
+	}
+}
+
+// reloadModules replaces the modules on the bar with the modules returned by
+// the reload function.
+func (b *i3Bar) reloadModules() {
+	if b.reloadFn == nil {
+		l.Log("Reload requested without a reload function")
+		return
+	}
+	l.Log("Reloading bar")
+	modules, err := b.reloadFn()
+	if err != nil {
+		l.Error("reload", "%v", err)
+		// The default error handler blocks until i3-nagbar is closed.
+		go b.errorHandler(bar.ErrorEvent{Error: fmt.Errorf("reload failed: %v", err)})
+		return
+	}
+	b.Lock()
+	b.modules = modules
+	b.Unlock()
+	// Module positions may have changed, so remove any keyboard focus.
+	b.focus = -1
+	b.moduleSet.Replace(modules)
+}
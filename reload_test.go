@@ -0,0 +1,100 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/testing/mockio"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestReload(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	var mu sync.Mutex
+	var modules []bar.Module
+	var reloadErr error
+	reloadWith := func(err error, m ...bar.Module) {
+		mu.Lock()
+		defer mu.Unlock()
+		modules, reloadErr = m, err
+	}
+	OnReload(func() ([]bar.Module, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return modules, reloadErr
+	})
+	errChan := make(chan bar.ErrorEvent, 1)
+	SetErrorHandler(func(e bar.ErrorEvent) { errChan <- e })
+
+	module1 := testModule.New(t)
+	module2 := testModule.New(t)
+	module3 := testModule.New(t)
+	go Run(module1, module2)
+	_, err := mockStdout.ReadUntil('[', time.Second)
+	require.NoError(t, err)
+
+	module1.AssertStarted()
+	module2.AssertStarted()
+	module1.OutputText("a")
+	require.Equal(t, []string{"a"}, readOutputTexts(t, mockStdout))
+	module2.OutputText("b")
+	require.Equal(t, []string{"a", "b"}, readOutputTexts(t, mockStdout))
+
+	reloadWith(nil, module2, module3)
+	Reload()
+	module3.AssertStarted("on reload")
+	require.Equal(t, []string{"b"}, readOutputTexts(t, mockStdout),
+		"removed module is hidden, kept module keeps its output")
+	module3.OutputText("c")
+	require.Equal(t, []string{"b", "c"}, readOutputTexts(t, mockStdout))
+	module1.OutputText("x")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"no updates from removed module")
+
+	reloadWith(errors.New("bad config"), module1)
+	unix.Kill(unix.Getpid(), unix.SIGHUP)
+	select {
+	case e := <-errChan:
+		require.Contains(t, e.Error.Error(), "bad config")
+	case <-time.After(time.Second):
+		require.Fail(t, "should report reload errors")
+	}
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"bar unchanged on reload error")
+
+	module4 := testModule.New(t)
+	reloadWith(nil, module3, module4)
+	unix.Kill(unix.Getpid(), unix.SIGHUP)
+	module4.AssertStarted("on reload")
+	require.Equal(t, []string{"c"}, readOutputTexts(t, mockStdout),
+		"reload on SIGHUP")
+	module4.OutputText("d")
+	require.Equal(t, []string{"c", "d"}, readOutputTexts(t, mockStdout))
+
+	require.Panics(t,
+		func() { OnReload(nil) },
+		"Cannot set reload function after Run")
+}
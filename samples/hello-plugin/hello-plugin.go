@@ -0,0 +1,48 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// hello-plugin is an example of a module that is loaded from a Go plugin.
+// Build it using:
+//
+//	go build -buildmode=plugin -o hello.so
+//
+// and load it using --plugin, e.g.:
+//
+//	sample-bar --plugin './hello.so={"name": "world"}'
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/modules/clock"
+	"barista.run/outputs"
+)
+
+type config struct {
+	Name string `json:"name"`
+}
+
+// NewModule is the entry point for the plugin, see barista.run/plugin.
+func NewModule(raw json.RawMessage) bar.Module {
+	c := config{Name: "barista"}
+	json.Unmarshal(raw, &c)
+	return clock.Local().Output(time.Second, func(now time.Time) bar.Output {
+		return outputs.Textf("Hello, %s! It's %s", c.Name, now.Format("15:04:05"))
+	})
+}
+
+// main is not used when built as a plugin.
+func main() {}
@@ -33,6 +33,7 @@ import (
 	"barista.run/base/click"
 	"barista.run/base/watchers/netlink"
 	"barista.run/colors"
+	"barista.run/config"
 	"barista.run/format"
 	"barista.run/group/modal"
 	"barista.run/modules/battery"
@@ -61,6 +62,7 @@ import (
 	"barista.run/pango/icons/material"
 	"barista.run/pango/icons/mdi"
 	"barista.run/pango/icons/typicons"
+	"barista.run/plugin"
 
 	colorful "github.com/lucasb-eyer/go-colorful"
 	"github.com/martinlindhe/unit"
@@ -279,6 +281,20 @@ func main() {
 		colors.Set("good", colorful.Hcl(120, 1.0, v).Clamped())
 	}
 
+	// Modules from Go plugins given using --plugin are added to the bar,
+	// see barista.run/plugin and samples/hello-plugin.
+	plugins, args, err := plugin.FromArgs(os.Args[1:])
+	if err != nil {
+		panic(err)
+	}
+	os.Args = append(os.Args[:1], args...)
+
+	// Run with --config bar.yaml to build the bar from a configuration file
+	// instead of the modules below. See barista.run/config for the format.
+	if len(os.Args) > 2 && os.Args[1] == "--config" {
+		panic(config.Run(os.Args[2]))
+	}
+
 	if err := setupOauthEncryption(); err != nil {
 		panic(fmt.Sprintf("Could not setup oauth token encryption: %v", err))
 	}
@@ -612,14 +628,15 @@ func main() {
 	mediaSummary, mediaDetail := split.New(media.Auto().Output(mediaFormatFunc), 1)
 
 	ghNotify := github.New("%%GITHUB_CLIENT_ID%%", "%%GITHUB_CLIENT_SECRET%%").
-		Output(func(n github.Notifications) bar.Output {
-			if n.Total() == 0 {
+		Output(func(i github.Info) bar.Output {
+			if i.Total() == 0 {
 				return nil
 			}
 			out := outputs.Group(
 				pango.Icon("fab-github").
 					Concat(spacer).
-					ConcatTextf("%d", n.Total()))
+					ConcatTextf("%d", i.Total()))
+			n := i.Notifications
 			mentions := n["mention"] + n["team_mention"]
 			if mentions > 0 {
 				out.Append(spacer)
@@ -704,5 +721,5 @@ func main() {
 
 	var mm bar.Module
 	mm, mainModalController = mainModal.Build()
-	panic(barista.Run(mm, localtime))
+	panic(barista.Run(append(plugins, mm, localtime)...))
 }
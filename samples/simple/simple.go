@@ -427,14 +427,15 @@ func main() {
 	grp, _ := collapsing.Group(net, temp, freeMem, loadAvg)
 
 	ghNotify := github.New("%%GITHUB_CLIENT_ID%%", "%%GITHUB_CLIENT_SECRET%%").
-		Output(func(n github.Notifications) bar.Output {
-			if n.Total() == 0 {
+		Output(func(i github.Info) bar.Output {
+			if i.Total() == 0 {
 				return nil
 			}
 			out := outputs.Group(
 				pango.Icon("fab-github").
 					Concat(spacer).
-					ConcatTextf("%d", n.Total()))
+					ConcatTextf("%d", i.Total()))
+			n := i.Notifications
 			mentions := n["mention"] + n["team_mention"]
 			if mentions > 0 {
 				out.Append(spacer)
@@ -0,0 +1,252 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package secrets provides API keys and other secrets to modules, so that they
+do not need to be written in the bar's source code. For example:
+
+	openweathermap.New(secrets.Get("owm-api-key"))
+
+Secrets are read from an encrypted file or from an external command, e.g.
+a password manager. By default, they are read from the GPG encrypted file
+$XDG_CONFIG_HOME/barista/secrets.gpg (~/.config if unset), which contains
+one secret per line in the form "key = value". Blank lines and lines starting
+with '#' are ignored.
+
+The sources of secrets can be changed using Use, e.g.
+
+	secrets.Use(secrets.Command("pass", "show", "barista/%s"))
+*/
+package secrets // import "barista.run/secrets"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	l "barista.run/logging"
+)
+
+// Source provides secrets by key.
+type Source interface {
+	// Lookup returns the secret for the given key, or an error if the
+	// secret could not be retrieved.
+	Lookup(key string) (string, error)
+}
+
+// ErrNotFound is returned when a secret does not exist in any source.
+type ErrNotFound string
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("secrets: %q not found", string(e))
+}
+
+var (
+	sources   = []Source{GPGFile(defaultFile("secrets.gpg"))}
+	sourcesMu sync.Mutex
+)
+
+func defaultFile(name string) string {
+	configRoot := os.ExpandEnv("$HOME/.config")
+	if xdgConfig, ok := os.LookupEnv("XDG_CONFIG_HOME"); ok {
+		configRoot = xdgConfig
+	}
+	return filepath.Join(configRoot, "barista", name)
+}
+
+// Use sets the sources of secrets. Each source is tried in order, until one
+// of them has the requested secret. It should be called before any modules
+// are created.
+func Use(s ...Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources = s
+}
+
+// Lookup returns the secret for the given key from the first source that
+// has it, or an error if no source has the secret.
+func Lookup(key string) (string, error) {
+	sourcesMu.Lock()
+	srcs := sources
+	sourcesMu.Unlock()
+	var lastErr error = ErrNotFound(key)
+	for _, s := range srcs {
+		val, err := s.Lookup(key)
+		if err == nil {
+			return val, nil
+		}
+		if _, ok := err.(ErrNotFound); !ok {
+			lastErr = err
+		}
+	}
+	return "", lastErr
+}
+
+// Get returns the secret for the given key, logging any errors and returning
+// an empty string if the secret could not be retrieved. It is intended for use
+// in module constructors, where handling the error is not always possible.
+func Get(key string) string {
+	val, err := Lookup(key)
+	if err != nil {
+		l.Log("Failed to get secret %q: %v", key, err)
+	}
+	return val
+}
+
+// run runs a command and returns its output. It can be replaced in tests.
+var run = func(name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%s: %v: %s", name, err,
+			strings.TrimSpace(stderr.String()))
+	}
+	return out, err
+}
+
+// file is a source that reads secrets from a file after decrypting it using
+// an external command. The file is only decrypted once.
+type file struct {
+	decrypt func() ([]byte, error)
+	once    sync.Once
+	values  map[string]string
+	err     error
+}
+
+func (f *file) Lookup(key string) (string, error) {
+	f.once.Do(func() {
+		var out []byte
+		out, f.err = f.decrypt()
+		if f.err == nil {
+			f.values = parse(out)
+		}
+	})
+	if f.err != nil {
+		return "", f.err
+	}
+	val, ok := f.values[key]
+	if !ok {
+		return "", ErrNotFound(key)
+	}
+	return val, nil
+}
+
+// parse parses the "key = value" lines of a decrypted secrets file.
+func parse(contents []byte) map[string]string {
+	values := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(contents))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:eq])
+		values[key] = strings.TrimSpace(line[eq+1:])
+	}
+	return values
+}
+
+// GPGFile returns a source that reads secrets from a GPG encrypted file,
+// decrypted using gpg (and therefore gpg-agent for any passphrase).
+func GPGFile(path string) Source {
+	return &file{decrypt: func() ([]byte, error) {
+		return run("gpg", "--quiet", "--batch", "--decrypt", path)
+	}}
+}
+
+// AgeFile returns a source that reads secrets from a file encrypted using
+// age (https://age-encryption.org), decrypted using the given identity file.
+func AgeFile(path, identity string) Source {
+	return &file{decrypt: func() ([]byte, error) {
+		return run("age", "--decrypt", "--identity", identity, path)
+	}}
+}
+
+// command is a source that runs an external command for each secret.
+type command struct {
+	name    string
+	args    []string
+	cache   map[string]string
+	cacheMu sync.Mutex
+}
+
+// Command returns a source that runs the given command to get each secret,
+// using the first line of its output. Any "%s" in the arguments is replaced
+// by the key, e.g. Command("pass", "show", "barista/%s"). If no argument
+// contains "%s", the key is added as the last argument. Secrets are cached
+// after the first successful lookup.
+func Command(name string, args ...string) Source {
+	return &command{name: name, args: args, cache: map[string]string{}}
+}
+
+func (c *command) Lookup(key string) (string, error) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if val, ok := c.cache[key]; ok {
+		return val, nil
+	}
+	args := make([]string, len(c.args))
+	hasKey := false
+	for i, a := range c.args {
+		if strings.Contains(a, "%s") {
+			hasKey = true
+			a = strings.Replace(a, "%s", key, -1)
+		}
+		args[i] = a
+	}
+	if !hasKey {
+		args = append(args, key)
+	}
+	out, err := run(c.name, args...)
+	if err != nil {
+		return "", err
+	}
+	val := string(out)
+	if nl := strings.IndexByte(val, '\n'); nl >= 0 {
+		val = val[:nl]
+	}
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return "", ErrNotFound(key)
+	}
+	c.cache[key] = val
+	return val, nil
+}
+
+// Static returns a source that provides secrets from a map, e.g. for tests,
+// or to provide defaults for secrets missing from other sources.
+func Static(values map[string]string) Source {
+	return static(values)
+}
+
+type static map[string]string
+
+func (s static) Lookup(key string) (string, error) {
+	val, ok := s[key]
+	if !ok {
+		return "", ErrNotFound(key)
+	}
+	return val, nil
+}
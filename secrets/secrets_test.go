@@ -0,0 +1,148 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRun struct {
+	sync.Mutex
+	outputs map[string]string
+	calls   []string
+}
+
+func (f *fakeRun) run(name string, args ...string) ([]byte, error) {
+	f.Lock()
+	defer f.Unlock()
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, cmd)
+	out, ok := f.outputs[cmd]
+	if !ok {
+		return nil, errors.New("exit status 1")
+	}
+	return []byte(out), nil
+}
+
+func (f *fakeRun) callCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.calls)
+}
+
+func withFakeRun(outputs map[string]string) *fakeRun {
+	f := &fakeRun{outputs: outputs}
+	run = f.run
+	return f
+}
+
+const secretsFile = `
+# API keys
+owm-api-key = abcdef123
+  spaced   =  value with spaces
+github=gh=token
+invalid line
+`
+
+func TestGPGFile(t *testing.T) {
+	f := withFakeRun(map[string]string{
+		"gpg --quiet --batch --decrypt /secrets.gpg": secretsFile,
+	})
+	Use(GPGFile("/secrets.gpg"))
+
+	require.Equal(t, "abcdef123", Get("owm-api-key"))
+	require.Equal(t, "value with spaces", Get("spaced"))
+	require.Equal(t, "gh=token", Get("github"))
+
+	_, err := Lookup("invalid line")
+	require.Equal(t, ErrNotFound("invalid line"), err)
+	require.Equal(t, "", Get("missing"))
+	require.Equal(t, 1, f.callCount(), "file decrypted only once")
+}
+
+func TestAgeFile(t *testing.T) {
+	withFakeRun(map[string]string{
+		"age --decrypt --identity /key.txt /secrets.age": "key=value",
+	})
+	Use(AgeFile("/secrets.age", "/key.txt"))
+	require.Equal(t, "value", Get("key"))
+
+	Use(AgeFile("/other.age", "/key.txt"))
+	_, err := Lookup("key")
+	require.Error(t, err, "on decryption error")
+	require.IsType(t, errors.New(""), err)
+}
+
+func TestCommand(t *testing.T) {
+	f := withFakeRun(map[string]string{
+		"pass show barista/owm-api-key": "secret\nurl: example.com\n",
+		"pass show barista/empty":       "\n",
+		"getsecret api-key":             "  12345  ",
+	})
+
+	Use(Command("pass", "show", "barista/%s"))
+	require.Equal(t, "secret", Get("owm-api-key"), "uses first line")
+	require.Equal(t, "secret", Get("owm-api-key"))
+	require.Equal(t, 1, f.callCount(), "successful lookups are cached")
+
+	_, err := Lookup("empty")
+	require.Equal(t, ErrNotFound("empty"), err)
+	_, err = Lookup("other")
+	require.EqualError(t, err, "exit status 1")
+
+	Use(Command("getsecret"))
+	require.Equal(t, "12345", Get("api-key"), "key added as last argument")
+}
+
+func TestMultipleSources(t *testing.T) {
+	withFakeRun(map[string]string{
+		"pass a": "from-pass",
+		"gpg --quiet --batch --decrypt /secrets.gpg": "a=from-file\nb=from-file",
+	})
+	Use(
+		Static(map[string]string{"c": "static"}),
+		Command("pass"),
+		GPGFile("/secrets.gpg"),
+	)
+	require.Equal(t, "from-pass", Get("a"))
+	require.Equal(t, "from-file", Get("b"), "falls back on command error")
+	require.Equal(t, "static", Get("c"))
+
+	_, err := Lookup("d")
+	require.EqualError(t, err, "exit status 1",
+		"returns errors from sources over not found")
+
+	Use(Static(nil))
+	_, err = Lookup("d")
+	require.Equal(t, ErrNotFound("d"), err)
+
+	Use()
+	_, err = Lookup("d")
+	require.Equal(t, ErrNotFound("d"), err, "with no sources")
+}
+
+func TestDefaultFile(t *testing.T) {
+	os.Setenv("XDG_CONFIG_HOME", "/xdg/config/")
+	require.Equal(t, "/xdg/config/barista/secrets.gpg", defaultFile("secrets.gpg"))
+	os.Unsetenv("XDG_CONFIG_HOME")
+	require.Equal(t, os.ExpandEnv("$HOME/.config/barista/secrets.gpg"),
+		defaultFile("secrets.gpg"))
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package state provides a persistent key/value store for modules, to keep state
+such as the current module of a cycling group or a running timer across bar
+restarts.
+
+State is stored under $XDG_STATE_HOME/barista (~/.local/state if unset), with
+a separate directory for each namespace and a JSON file for each key:
+
+	timers := state.New("timers")
+	var remaining time.Duration
+	if !timers.Load("tea", &remaining) {
+		remaining = 3 * time.Minute
+	}
+	...
+	timers.Save("tea", remaining)
+*/
+package state // import "barista.run/state"
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	l "barista.run/logging"
+)
+
+// Dir returns the directory that all state is stored under.
+func Dir() string {
+	stateRoot := os.ExpandEnv("$HOME/.local/state")
+	if xdgState, ok := os.LookupEnv("XDG_STATE_HOME"); ok {
+		stateRoot = xdgState
+	}
+	return filepath.Join(stateRoot, "barista")
+}
+
+// Store is a namespaced key/value store.
+type Store struct {
+	namespace string
+}
+
+// New returns the store for the given namespace. Modules should use a
+// namespace specific to the module, e.g. its package name, to avoid
+// conflicting with other modules.
+func New(namespace string) *Store {
+	return &Store{namespace}
+}
+
+// Path returns the file that the value for the given key is stored in.
+func (s *Store) Path(key string) string {
+	return filepath.Join(Dir(), url.PathEscape(s.namespace), url.PathEscape(key)+".json")
+}
+
+// Load loads the saved value for the given key into v, and returns true if a
+// value was loaded.
+func (s *Store) Load(key string, v interface{}) bool {
+	data, err := ioutil.ReadFile(s.Path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			l.Log("Failed to load %s/%s: %v", s.namespace, key, err)
+		}
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		l.Log("Failed to load %s/%s: %v", s.namespace, key, err)
+		return false
+	}
+	return true
+}
+
+// Save saves v as the value for the given key. The value is replaced
+// atomically, so an interrupted save never leaves a partially written value.
+func (s *Store) Save(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFile(s.Path(key), data)
+}
+
+// Delete removes the saved value for the given key, if any.
+func (s *Store) Delete(key string) error {
+	err := os.Remove(s.Path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// writeFile writes data to a temporary file in the same directory, which is
+// then renamed to the destination.
+func writeFile(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
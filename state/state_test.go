@@ -0,0 +1,103 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setStateHome(t *testing.T) (dir string, cleanup func()) {
+	tmpDir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	oldState, hadState := os.LookupEnv("XDG_STATE_HOME")
+	os.Setenv("XDG_STATE_HOME", tmpDir)
+	return tmpDir, func() {
+		if hadState {
+			os.Setenv("XDG_STATE_HOME", oldState)
+		} else {
+			os.Unsetenv("XDG_STATE_HOME")
+		}
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestDir(t *testing.T) {
+	tmpDir, cleanup := setStateHome(t)
+	defer cleanup()
+	require.Equal(t, filepath.Join(tmpDir, "barista"), Dir())
+
+	os.Unsetenv("XDG_STATE_HOME")
+	require.Equal(t, os.ExpandEnv("$HOME/.local/state/barista"), Dir())
+}
+
+func TestStore(t *testing.T) {
+	tmpDir, cleanup := setStateHome(t)
+	defer cleanup()
+
+	type position struct {
+		Index int
+		Name  string
+	}
+
+	s := New("cycling")
+	var p position
+	require.False(t, s.Load("main", &p), "with no saved value")
+
+	require.NoError(t, s.Save("main", position{2, "clock"}))
+	require.True(t, s.Load("main", &p))
+	require.Equal(t, position{2, "clock"}, p)
+	require.Equal(t, filepath.Join(tmpDir, "barista", "cycling", "main.json"), s.Path("main"))
+	data, err := ioutil.ReadFile(s.Path("main"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Index": 2, "Name": "clock"}`, string(data))
+
+	require.NoError(t, s.Save("main", position{3, "date"}))
+	require.True(t, s.Load("main", &p))
+	require.Equal(t, position{3, "date"}, p, "on overwrite")
+	files, _ := ioutil.ReadDir(filepath.Dir(s.Path("main")))
+	require.Len(t, files, 1, "temporary files are removed")
+
+	var other position
+	require.False(t, New("other").Load("main", &other), "namespaces are separate")
+	require.False(t, s.Load("second", &other), "keys are separate")
+
+	require.NoError(t, s.Save("a/b", 1))
+	require.Equal(t, filepath.Join(tmpDir, "barista", "cycling", "a%2Fb.json"), s.Path("a/b"),
+		"keys are escaped")
+
+	require.NoError(t, ioutil.WriteFile(s.Path("main"), []byte("not-json"), 0600))
+	require.False(t, s.Load("main", &p), "with invalid value")
+
+	require.Error(t, s.Save("main", func() {}), "with unencodable value")
+	require.False(t, s.Load("main", &p), "keeps the value on error")
+
+	require.NoError(t, s.Delete("main"))
+	require.NoError(t, s.Delete("main"), "when already deleted")
+	_, err = os.Stat(s.Path("main"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSaveErrors(t *testing.T) {
+	tmpDir, cleanup := setStateHome(t)
+	defer cleanup()
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "barista"), nil, 0600))
+	require.Error(t, New("test").Save("key", 1), "when the directory cannot be created")
+}
@@ -23,6 +23,7 @@ import (
 
 	"barista.run/bar"
 	"barista.run/core"
+	"barista.run/i18n"
 	l "barista.run/logging"
 	"barista.run/oauth"
 	"barista.run/testing/output"
@@ -52,6 +53,8 @@ func New(t require.TestingT) {
 	}
 	instance.Store(b)
 	timing.TestMode()
+	// Tests expect English text, regardless of the locale.
+	i18n.SetLanguage("en")
 	encryptionKeySet.Do(func() {
 		oauth.SetEncryptionKey([]byte(`not-an-encryption-key`))
 	})
@@ -0,0 +1,104 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"time"
+
+	l "barista.run/logging"
+)
+
+// Backoff represents a policy for retrying failed work at increasing delays.
+type Backoff struct {
+	// Initial is the delay after the first failure, which doubles with each
+	// subsequent failure.
+	Initial time.Duration
+	// Max is the maximum delay between retries.
+	Max time.Duration
+}
+
+// DefaultBackoff is the backoff used by schedulers unless configured
+// otherwise: retrying after 30 seconds at first, and at most once an hour.
+var DefaultBackoff = Backoff{Initial: 30 * time.Second, Max: time.Hour}
+
+// Delay returns the delay before the next retry after the given number of
+// consecutive failures.
+func (b Backoff) Delay(failures int) time.Duration {
+	delay := b.Initial
+	for i := 1; i < failures && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// Backoff sets the backoff policy used when reporting failures via Result.
+func (s *Scheduler) Backoff(b Backoff) *Scheduler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backoff = &b
+	return s
+}
+
+// Result reports the result of the work performed when the scheduler last
+// triggered. On error, the next trigger is delayed according to the backoff
+// policy, growing with each consecutive error, which replaces any pending
+// triggers. Once the work succeeds, the repeating schedule (if any) is
+// restored.
+func (s *Scheduler) Result(err error) {
+	if err == nil {
+		s.ResetBackoff()
+		return
+	}
+	s.mu.Lock()
+	s.failures++
+	b := DefaultBackoff
+	if s.backoff != nil {
+		b = *s.backoff
+	}
+	delay := b.Delay(s.failures)
+	s.mu.Unlock()
+	sch := s.getSchedule()
+	every, jitter := sch.every, sch.jitter
+	l.Fine("%s failed (%v), retrying in %v", l.ID(s), err, delay)
+	s.After(delay)
+	// Keep the repeating interval, to be restored when the work succeeds.
+	if every > 0 {
+		sch = s.getSchedule()
+		sch.every, sch.jitter = every, jitter
+		s.setSchedule(sch)
+	}
+}
+
+// ResetBackoff clears any failures reported using Result, restoring the
+// repeating schedule (if any). Modules can call this before retrying on
+// demand, e.g. on click, so that if the retry also fails, the backoff starts
+// again from the initial delay.
+func (s *Scheduler) ResetBackoff() {
+	s.mu.Lock()
+	failures := s.failures
+	s.failures = 0
+	s.mu.Unlock()
+	if failures == 0 {
+		return
+	}
+	sch := s.getSchedule()
+	l.Fine("%s backoff reset after %d failures", l.ID(s), failures)
+	if sch.every > 0 {
+		s.EveryWithJitter(sch.every, sch.jitter)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"barista.run/testing/notifier"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second}
+	var delays []time.Duration
+	for i := 1; i <= 6; i++ {
+		delays = append(delays, b.Delay(i))
+	}
+	require.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second,
+		8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}, delays)
+	require.Equal(t, time.Hour, DefaultBackoff.Delay(100))
+}
+
+func TestSchedulerBackoff(t *testing.T) {
+	TestMode()
+	sch := NewScheduler().Every(10 * time.Minute)
+	start := Now()
+	require.Equal(t, start.Add(10*time.Minute), NextTick())
+	notifier.AssertNotified(t, sch.C)
+
+	sch.Result(nil)
+	require.Equal(t, start.Add(20*time.Minute), NextTick(),
+		"success does not change schedule")
+
+	err := errors.New("foo")
+	start = Now()
+	sch.Result(err)
+	require.Equal(t, start.Add(30*time.Second), NextTick(), "first retry")
+	sch.Result(err)
+	require.Equal(t, start.Add(90*time.Second), NextTick(), "second retry")
+	sch.Result(err)
+	require.Equal(t, start.Add(210*time.Second), NextTick(), "third retry")
+	notifier.AssertNotified(t, sch.C)
+
+	setOnBattery(true)
+	require.Equal(t, start.Add(210*time.Second), Now())
+	sch.Result(nil)
+	require.Equal(t, start.Add(810*time.Second), NextTick(),
+		"repeating schedule restored on success")
+	require.Equal(t, start.Add(1410*time.Second), NextTick())
+
+	sch.Backoff(Backoff{Initial: time.Minute, Max: 3 * time.Minute})
+	start = Now()
+	for i := 0; i < 4; i++ {
+		sch.Result(err)
+		NextTick()
+	}
+	require.Equal(t, start.Add(9*time.Minute), Now(), "custom backoff")
+
+	oneOff := NewScheduler()
+	start = Now()
+	oneOff.Result(err)
+	require.Equal(t, start.Add(30*time.Second), NextTick(), "retry for one-off")
+	oneOff.Result(nil)
+	require.Equal(t, Now(), NextTick(), "not repeating")
+}
+
+func TestResetBackoff(t *testing.T) {
+	TestMode()
+	sch := NewScheduler().Every(10 * time.Minute)
+	err := errors.New("foo")
+	start := Now()
+	sch.Result(err)
+	sch.Result(err)
+	require.Equal(t, start.Add(time.Minute), NextTick(), "backing off")
+
+	start = Now()
+	sch.ResetBackoff()
+	sch.Result(err)
+	require.Equal(t, start.Add(30*time.Second), NextTick(),
+		"backoff starts again after reset")
+
+	start = Now()
+	sch.ResetBackoff()
+	require.Equal(t, start.Add(10*time.Minute), NextTick(),
+		"repeating schedule restored on reset")
+	sch.ResetBackoff()
+	require.Equal(t, start.Add(20*time.Minute), NextTick(),
+		"reset without failures does not change schedule")
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"sync/atomic"
+	"time"
+
+	l "barista.run/logging"
+)
+
+var (
+	// barrierTimeout is how long to wait for triggered schedulers to be
+	// consumed, or zero if the barrier is disabled.
+	barrierTimeout time.Duration
+	// testModeChanges counts changes to test schedulers, to detect when
+	// the code under test has stopped reacting to triggers.
+	testModeChanges uint64 // atomic
+)
+
+// barrierQuietPeriod is how long the test schedulers must stay unchanged
+// before they are considered settled.
+const barrierQuietPeriod = 5 * time.Millisecond
+
+// TestBarrier makes NextTick, AdvanceBy, and AdvanceTo wait until each tick
+// they deliver has been received from the scheduler's channel, and the test
+// schedulers have settled, before delivering any further ticks. Any triggers
+// scheduled in response to a tick are then delivered in order if they are due,
+// before the call returns. This makes tests deterministic when the code under
+// test creates or updates schedulers in other goroutines as a result of a tick.
+//
+// Ticks that are not consumed within the timeout are logged and ignored, to
+// avoid hanging on schedulers that nobody is listening to.
+// A timeout of zero disables the barrier. TestMode() disables the barrier.
+func TestBarrier(timeout time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	barrierTimeout = timeout
+}
+
+// testModeChanged records a change to the test schedulers.
+func testModeChanged() {
+	atomic.AddUint64(&testModeChanges, 1)
+}
+
+// awaitConsumed waits until all fired schedulers have been consumed, and the
+// test schedulers have settled. Returns false if the barrier is not enabled.
+func awaitConsumed(fired []*Scheduler) bool {
+	mu.Lock()
+	timeout := barrierTimeout
+	mu.Unlock()
+	if timeout == 0 {
+		return false
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		changes := atomic.LoadUint64(&testModeChanges)
+		time.Sleep(barrierQuietPeriod)
+		pending := pendingTicks(fired)
+		if pending == 0 && atomic.LoadUint64(&testModeChanges) == changes {
+			return true
+		}
+		if time.Now().After(deadline) {
+			l.Log("%d ticks not consumed after %v", pending, timeout)
+			return true
+		}
+	}
+}
+
+// pendingTicks returns the number of fired schedulers with a tick that has
+// not yet been received. Ticks that are waiting for the bar to resume are not
+// pending, since they cannot be received until then.
+func pendingTicks(fired []*Scheduler) int {
+	pending := 0
+	for _, s := range fired {
+		if atomic.LoadInt32(&s.waiting) == 0 && len(s.C) > 0 {
+			pending++
+		}
+	}
+	return pending
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"barista.run/testing/notifier"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTickBarrier(t *testing.T) {
+	TestMode()
+	TestBarrier(time.Second)
+	start := Now()
+
+	var ticks int32
+	followUps := make(chan *Scheduler, 10)
+	sch := NewScheduler().Every(time.Minute)
+	go func() {
+		for range sch.C {
+			atomic.AddInt32(&ticks, 1)
+			// Simulate some work before scheduling a follow-up.
+			time.Sleep(time.Millisecond)
+			followUps <- NewScheduler().After(10 * time.Second)
+		}
+	}()
+
+	require.Equal(t, start.Add(90*time.Second), AdvanceBy(90*time.Second))
+	require.Equal(t, int32(1), atomic.LoadInt32(&ticks),
+		"tick consumed before AdvanceBy returns")
+	require.Len(t, followUps, 1, "follow-up scheduled before AdvanceBy returns")
+	notifier.AssertNotified(t, (<-followUps).C,
+		"follow-up triggered before AdvanceBy returns")
+
+	require.Equal(t, start.Add(2*time.Minute), NextTick())
+	require.Equal(t, int32(2), atomic.LoadInt32(&ticks))
+	require.Len(t, followUps, 1)
+	require.Equal(t, start.Add(2*time.Minute+10*time.Second), NextTick())
+	notifier.AssertNotified(t, (<-followUps).C)
+	sch.Stop()
+
+	TestBarrier(20 * time.Millisecond)
+	ignored := NewScheduler().Every(time.Minute)
+	begin := time.Now()
+	require.Equal(t, start.Add(5*time.Minute), AdvanceBy(170*time.Second))
+	require.True(t, time.Since(begin) < time.Second,
+		"does not hang on ticks that are not consumed")
+	notifier.AssertNotified(t, ignored.C)
+
+	Pause()
+	begin = time.Now()
+	NextTick()
+	require.True(t, time.Since(begin) < 20*time.Millisecond,
+		"does not wait for ticks while paused")
+	Resume()
+	notifier.AssertNotified(t, ignored.C)
+
+	TestMode()
+	require.Equal(t, time.Duration(0), barrierTimeout, "disabled in test mode")
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"fmt"
+	"sync"
+
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+
+	godbus "github.com/godbus/dbus"
+)
+
+var lockOnce sync.Once
+
+// PauseWhileLocked pauses all schedulers while the session is locked, as
+// reported by logind's Lock and Unlock signals, and triggers all schedulers
+// when the session is unlocked. This avoids polling for updates that nobody
+// will see, e.g. weather or mail, which saves battery and API quota.
+func PauseWhileLocked() {
+	mu.Lock()
+	inTestMode := testMode
+	mu.Unlock()
+	if !inTestMode {
+		lockOnce.Do(func() { go watchLock(dbus.System) })
+	}
+}
+
+// watchLock watches for the logind Lock and Unlock signals of the current
+// session.
+func watchLock(busType dbus.BusType) {
+	defer func() {
+		// See watchSleep.
+		if r := recover(); r != nil {
+			l.Log("Not watching for session lock: %v", r)
+		}
+	}()
+	session, err := sessionPath(busType)
+	if err != nil {
+		l.Log("Not watching for session lock: %v", err)
+		return
+	}
+	dbus.WatchProperties(busType,
+		"org.freedesktop.login1",
+		session,
+		"org.freedesktop.login1.Session",
+	).AddSignalHandler("Lock", func(*dbus.Signal, dbus.Fetcher) map[string]interface{} {
+		setLocked(true)
+		return nil
+	}).AddSignalHandler("Unlock", func(*dbus.Signal, dbus.Fetcher) map[string]interface{} {
+		go setLocked(false)
+		return nil
+	})
+}
+
+// sessionPath returns the object path of the current session. logind sends
+// signals from the session's real path, so the "auto" alias cannot be used to
+// watch for them.
+func sessionPath(busType dbus.BusType) (string, error) {
+	w := dbus.WatchProperties(busType,
+		"org.freedesktop.login1",
+		"/org/freedesktop/login1",
+		"org.freedesktop.login1.Manager")
+	defer w.Unsubscribe()
+	// "auto" refers to the session of the calling process, or the user's
+	// display session if it is not part of a session.
+	res, err := w.Call("GetSession", "auto")
+	if err != nil {
+		return "", err
+	}
+	if len(res) != 1 {
+		return "", fmt.Errorf("logind: unexpected response %v", res)
+	}
+	path, ok := res[0].(godbus.ObjectPath)
+	if !ok {
+		return "", fmt.Errorf("logind: unexpected response %v", res)
+	}
+	return string(path), nil
+}
+
+// setLocked pauses or resumes schedulers when the session lock changes. On
+// unlock, all schedulers are refreshed.
+func setLocked(isLocked bool) {
+	mu.Lock()
+	changed := isLocked != locked
+	locked = isLocked
+	if !locked && !paused {
+		releaseWaiters()
+	}
+	mu.Unlock()
+	if !changed {
+		return
+	}
+	l.Log("Session locked: %v", isLocked)
+	if !isLocked {
+		refreshAll()
+	}
+}
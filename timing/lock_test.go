@@ -0,0 +1,112 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	"barista.run/testing/notifier"
+
+	godbus "github.com/godbus/dbus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocked(t *testing.T) {
+	TestMode()
+	sch := NewScheduler().Every(time.Minute)
+	later := NewScheduler().After(time.Hour)
+	start := Now()
+
+	setLocked(true)
+	require.Equal(t, start.Add(time.Minute), NextTick())
+	require.Equal(t, start.Add(2*time.Minute), NextTick())
+	notifier.AssertNoUpdate(t, sch.C, "while locked")
+
+	setLocked(false)
+	notifier.AssertNotified(t, sch.C, "on unlock")
+	notifier.AssertNoUpdate(t, sch.C, "only once on unlock")
+	notifier.AssertNoUpdate(t, later.C, "pending one-off on unlock")
+
+	Pause()
+	setLocked(true)
+	setLocked(false)
+	notifier.AssertNoUpdate(t, sch.C, "on unlock while paused")
+	Resume()
+	notifier.AssertNotified(t, sch.C, "on resume")
+
+	setLocked(true)
+	Pause()
+	Resume()
+	notifier.AssertNoUpdate(t, sch.C, "on resume while locked")
+	setLocked(false)
+	notifier.AssertNotified(t, sch.C, "on unlock")
+}
+
+func TestWatchLock(t *testing.T) {
+	TestMode()
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService("org.freedesktop.login1")
+	mgr := srv.Object("/org/freedesktop/login1", "org.freedesktop.login1.Manager")
+	mgr.On("GetSession", func(args ...interface{}) ([]interface{}, error) {
+		require.Equal(t, []interface{}{"auto"}, args)
+		return []interface{}{godbus.ObjectPath("/org/freedesktop/login1/session/_32")}, nil
+	})
+	obj := srv.Object("/org/freedesktop/login1/session/_32", "org.freedesktop.login1.Session")
+	auto := srv.Object("/org/freedesktop/login1/session/auto", "org.freedesktop.login1.Session")
+
+	sch := NewScheduler().Every(time.Minute)
+	watchLock(dbus.Test)
+
+	obj.Emit("Lock")
+	// The signal is delivered asynchronously.
+	for start := time.Now(); !isLocked(); {
+		require.True(t, time.Since(start) < time.Second, "locked on signal")
+		time.Sleep(time.Millisecond)
+	}
+	NextTick()
+	notifier.AssertNoUpdate(t, sch.C, "while locked")
+
+	auto.Emit("Unlock")
+	notifier.AssertNoUpdate(t, sch.C, "signal from a different path")
+
+	obj.Emit("Unlock")
+	notifier.AssertNotified(t, sch.C, "on unlock")
+}
+
+func TestWatchLockWithoutSession(t *testing.T) {
+	TestMode()
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService("org.freedesktop.login1")
+	mgr := srv.Object("/org/freedesktop/login1", "org.freedesktop.login1.Manager")
+	mgr.On("GetSession", func(...interface{}) ([]interface{}, error) {
+		return nil, errors.New("no session")
+	})
+	obj := srv.Object("/org/freedesktop/login1/session/auto", "org.freedesktop.login1.Session")
+
+	sch := NewScheduler().Every(time.Minute)
+	watchLock(dbus.Test)
+	obj.Emit("Lock")
+	NextTick()
+	notifier.AssertNotified(t, sch.C, "not locked without a session")
+}
+
+func isLocked() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return locked
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	l "barista.run/logging"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	powerMu    sync.Mutex
+	multiplier = 1.0
+	onBattery  = false
+	powerOnce  sync.Once
+)
+
+// OnBatteryMultiplier stretches the interval of all repeating schedulers by
+// the given factor while the machine is running on battery, to reduce the
+// number of wakeups. The power source is read from sysfs, and schedulers are
+// rescheduled when it changes. Schedulers that need to update promptly can
+// opt out using IgnoreBattery.
+func OnBatteryMultiplier(x float64) {
+	if x < 1 {
+		panic(errors.New("multiplier must be at least 1 for OnBatteryMultiplier"))
+	}
+	powerMu.Lock()
+	multiplier = x
+	powerMu.Unlock()
+	mu.Lock()
+	inTestMode := testMode
+	mu.Unlock()
+	if !inTestMode {
+		powerOnce.Do(func() { go watchPower() })
+	}
+	rescheduleAll()
+}
+
+// IgnoreBattery exempts the scheduler from the on-battery multiplier,
+// e.g. for modules that need to update with low latency.
+func (s *Scheduler) IgnoreBattery() *Scheduler {
+	atomic.StoreInt32(&s.ignoreBattery, 1)
+	s.reschedule()
+	return s
+}
+
+// scaled returns the given interval, multiplied if running on battery.
+func (s *Scheduler) scaled(interval time.Duration) time.Duration {
+	if atomic.LoadInt32(&s.ignoreBattery) == 1 {
+		return interval
+	}
+	powerMu.Lock()
+	defer powerMu.Unlock()
+	if !onBattery {
+		return interval
+	}
+	return time.Duration(float64(interval) * multiplier)
+}
+
+func (s *Scheduler) reschedule() {
+	sch := s.getSchedule()
+	s.mu.Lock()
+	failures := s.failures
+	s.mu.Unlock()
+	// While backing off, the repeating schedule is restored on success.
+	if sch.every > 0 && failures == 0 {
+		s.EveryWithJitter(sch.every, sch.jitter)
+	}
+}
+
+func rescheduleAll() {
+	for _, s := range scheduled() {
+		s.reschedule()
+	}
+}
+
+// setOnBattery updates the power source, rescheduling all repeating
+// schedulers if it changed.
+func setOnBattery(battery bool) {
+	powerMu.Lock()
+	changed := battery != onBattery
+	onBattery = battery
+	powerMu.Unlock()
+	if changed {
+		l.Log("On battery: %v", battery)
+		rescheduleAll()
+	}
+}
+
+func resetPower() {
+	powerMu.Lock()
+	defer powerMu.Unlock()
+	multiplier = 1.0
+	onBattery = false
+}
+
+var fs = afero.NewOsFs()
+
+// powerPollInterval is how often the power source is checked.
+var powerPollInterval = 30 * time.Second
+
+func watchPower() {
+	setOnBattery(readOnBattery())
+	for range time.Tick(powerPollInterval) {
+		setOnBattery(readOnBattery())
+	}
+}
+
+// readOnBattery returns true if the machine has a mains power supply, and none
+// of them are online.
+func readOnBattery() bool {
+	const root = "/sys/class/power_supply"
+	supplies, err := afero.ReadDir(fs, root)
+	if err != nil {
+		return false
+	}
+	hasMains := false
+	for _, s := range supplies {
+		typ, _ := afero.ReadFile(fs, filepath.Join(root, s.Name(), "type"))
+		if strings.TrimSpace(string(typ)) != "Mains" {
+			continue
+		}
+		hasMains = true
+		online, _ := afero.ReadFile(fs, filepath.Join(root, s.Name(), "online"))
+		if strings.TrimSpace(string(online)) == "1" {
+			return false
+		}
+	}
+	return hasMains
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"barista.run/testing/notifier"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnBatteryMultiplier(t *testing.T) {
+	TestMode()
+	sch := NewScheduler().Every(time.Minute)
+	critical := NewScheduler().IgnoreBattery().Every(time.Minute)
+	oneOff := NewScheduler().After(11 * time.Minute)
+
+	OnBatteryMultiplier(3)
+	start := Now()
+	require.Equal(t, start.Add(time.Minute), NextTick(), "when on AC")
+	notifier.AssertNotified(t, sch.C)
+	notifier.AssertNotified(t, critical.C)
+
+	setOnBattery(true)
+	start = Now()
+	require.Equal(t, start.Add(time.Minute), NextTick(), "critical scheduler")
+	notifier.AssertNotified(t, critical.C)
+	notifier.AssertNoUpdate(t, sch.C, "stretched while on battery")
+	require.Equal(t, start.Add(2*time.Minute), NextTick(), "critical scheduler")
+	require.Equal(t, start.Add(3*time.Minute), NextTick(), "stretched scheduler")
+	notifier.AssertNotified(t, sch.C)
+
+	critical.Stop()
+	require.Equal(t, start.Add(6*time.Minute), NextTick(), "stretched scheduler")
+	require.Equal(t, start.Add(9*time.Minute), NextTick(), "stretched scheduler")
+	require.Equal(t, start.Add(10*time.Minute), NextTick(),
+		"one-off triggers are not stretched")
+	notifier.AssertNotified(t, oneOff.C)
+
+	sch.EveryWithJitter(time.Minute, 10*time.Second)
+	// Always choose the shortest interval.
+	randInt63n = func(int64) int64 { return 0 }
+	defer func() { randInt63n = rand.Int63n }()
+	start = Now()
+	setOnBattery(false)
+	require.Equal(t, start.Add(50*time.Second), NextTick(), "back on AC")
+	setOnBattery(true)
+	require.Equal(t, start.Add(50*time.Second+150*time.Second), NextTick(),
+		"jitter is stretched")
+
+	sch.After(time.Hour)
+	setOnBattery(false)
+	require.Equal(t, start.Add(200*time.Second+time.Hour), NextTick(),
+		"not rescheduled after switching to one-off trigger")
+
+	require.Panics(t, func() { OnBatteryMultiplier(0.5) })
+}
+
+func TestReadOnBattery(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	defer func() { fs = afero.NewOsFs() }()
+	require.False(t, readOnBattery(), "without power supplies")
+
+	afero.WriteFile(fs, "/sys/class/power_supply/BAT0/type", []byte("Battery\n"), 0644)
+	require.False(t, readOnBattery(), "without mains power supplies")
+
+	afero.WriteFile(fs, "/sys/class/power_supply/AC/type", []byte("Mains\n"), 0644)
+	afero.WriteFile(fs, "/sys/class/power_supply/AC/online", []byte("0\n"), 0644)
+	require.True(t, readOnBattery(), "when mains is offline")
+
+	afero.WriteFile(fs, "/sys/class/power_supply/USB-C/type", []byte("Mains\n"), 0644)
+	afero.WriteFile(fs, "/sys/class/power_supply/USB-C/online", []byte("1\n"), 0644)
+	require.False(t, readOnBattery(), "when any mains supply is online")
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	l "barista.run/logging"
+)
+
+var (
+	// named tracks all schedulers that have been given a name.
+	named   = map[string]*Scheduler{}
+	namedMu sync.Mutex
+)
+
+// Named registers the scheduler under the given name, so that it can be
+// listed using Schedulers and looked up using Lookup, e.g. to force a refresh
+// from outside the module. If the name is already taken, a numeric suffix is
+// added to make it unique.
+func (s *Scheduler) Named(name string) *Scheduler {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+	unique := name
+	for i := 2; named[unique] != nil; i++ {
+		unique = fmt.Sprintf("%s#%d", name, i)
+	}
+	named[unique] = s
+	s.mu.Lock()
+	s.name = unique
+	s.mu.Unlock()
+	return s
+}
+
+// Unregister removes the scheduler from the registry, so that it is no longer
+// listed by Schedulers or returned by Lookup. Modules that name their
+// scheduler should unregister it when they are removed from the bar (see
+// bar.RemovableModule).
+func (s *Scheduler) Unregister() {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+	s.mu.Lock()
+	name := s.name
+	s.name = ""
+	s.mu.Unlock()
+	if name != "" && named[name] == s {
+		delete(named, name)
+	}
+}
+
+// Name returns the name the scheduler was registered with, or an empty string
+// if it does not have a name.
+func (s *Scheduler) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
+
+// FireNow triggers the scheduler immediately, without affecting any
+// pending triggers. As with all triggers, this will wait for the bar to
+// resume if it is currently paused.
+func (s *Scheduler) FireNow() {
+	l.Fine("%s FireNow", l.ID(s))
+	s.maybeTrigger()
+}
+
+func resetNamed() {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+	named = map[string]*Scheduler{}
+}
+
+// Lookup returns the scheduler registered with the given name, or nil if no
+// such scheduler exists.
+func Lookup(name string) *Scheduler {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+	return named[name]
+}
+
+// SchedulerInfo describes a named scheduler.
+type SchedulerInfo struct {
+	Name string
+	// Next is the time at which the scheduler will next trigger,
+	// or zero if no trigger is pending.
+	Next time.Time
+	// Interval is the interval of a repeating scheduler, or zero for a
+	// one-off trigger.
+	Interval time.Duration
+}
+
+// Schedulers returns information about all named schedulers, ordered by name.
+func Schedulers() []SchedulerInfo {
+	namedMu.Lock()
+	schedulers := make([]*Scheduler, 0, len(named))
+	for _, s := range named {
+		schedulers = append(schedulers, s)
+	}
+	namedMu.Unlock()
+	r := make([]SchedulerInfo, 0, len(schedulers))
+	for _, s := range schedulers {
+		sch := s.getSchedule()
+		info := SchedulerInfo{Name: s.Name(), Next: s.next()}
+		if sch.every > 0 {
+			info.Interval = s.scaled(sch.every)
+		} else if sch.align > 0 {
+			info.Interval = sch.align
+		}
+		r = append(r, info)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Name < r[j].Name })
+	return r
+}
+
+// next returns the time of the next pending trigger, if any.
+func (s *Scheduler) next() time.Time {
+	if s.testModeID > 0 {
+		return s.testModeNext()
+	}
+	sch := s.getSchedule()
+	if sch.align > 0 {
+		// The timer might only be a check of the wall-clock time.
+		return nextAligned(Now(), sch.align, sch.offset)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer == nil || !s.timer.pending() {
+		return time.Time{}
+	}
+	return s.timer.when.In(Now().Location())
+}
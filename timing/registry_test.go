@@ -0,0 +1,104 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/testing/notifier"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFireNow(t *testing.T) {
+	TestMode()
+	sch := NewScheduler().Every(time.Minute)
+	start := Now()
+
+	sch.FireNow()
+	notifier.AssertNotified(t, sch.C, "on FireNow")
+	require.Equal(t, start.Add(time.Minute), NextTick(),
+		"does not affect pending triggers")
+	notifier.AssertNotified(t, sch.C)
+
+	Pause()
+	sch.FireNow()
+	notifier.AssertNoUpdate(t, sch.C, "while paused")
+	Resume()
+	notifier.AssertNotified(t, sch.C, "on resume")
+}
+
+func TestNamedSchedulers(t *testing.T) {
+	TestMode()
+	start := Now()
+	weather := NewScheduler().Named("weather").Every(10 * time.Minute)
+	clock := NewScheduler().Named("clock").EveryAlign(time.Minute, 0)
+	other := NewScheduler().Named("weather").At(start.Add(time.Hour))
+	idle := NewScheduler().Named("idle")
+	NewScheduler().Every(time.Second)
+
+	require.Equal(t, "weather", weather.Name())
+	require.Equal(t, "weather#2", other.Name(), "names are unique")
+	require.Equal(t, []SchedulerInfo{
+		{Name: "clock", Next: start.Add(time.Minute), Interval: time.Minute},
+		{Name: "idle"},
+		{Name: "weather", Next: start.Add(10 * time.Minute), Interval: 10 * time.Minute},
+		{Name: "weather#2", Next: start.Add(time.Hour)},
+	}, Schedulers())
+
+	require.Equal(t, clock, Lookup("clock"))
+	require.Equal(t, idle, Lookup("idle"))
+	require.Nil(t, Lookup("nope"))
+
+	Lookup("weather#2").FireNow()
+	notifier.AssertNotified(t, other.C)
+	notifier.AssertNoUpdate(t, weather.C)
+
+	weather.Unregister()
+	require.Nil(t, Lookup("weather"), "after unregister")
+	require.Empty(t, weather.Name())
+	require.Equal(t, other, Lookup("weather#2"))
+	weather.Unregister()
+	require.Len(t, Schedulers(), 3, "repeated unregister is a no-op")
+	require.Equal(t, "weather", NewScheduler().Named("weather").Name(),
+		"name can be reused")
+
+	TestMode()
+	require.Empty(t, Schedulers(), "cleared in test mode")
+}
+
+func TestNamedSchedulersNext(t *testing.T) {
+	ExitTestMode()
+	defer TestMode()
+	resetNamed()
+	sch := NewScheduler().Named("test")
+	require.True(t, Schedulers()[0].Next.IsZero(), "when not scheduled")
+
+	now := Now()
+	sch.After(time.Hour)
+	require.WithinDuration(t, now.Add(time.Hour), Schedulers()[0].Next, time.Second)
+
+	sch.Every(time.Minute)
+	info := Schedulers()[0]
+	require.WithinDuration(t, now.Add(time.Minute), info.Next, time.Second)
+	require.Equal(t, time.Minute, info.Interval)
+
+	sch.EveryAlign(time.Hour, 0)
+	require.Equal(t, nextAligned(Now(), time.Hour, 0), Schedulers()[0].Next)
+
+	sch.Stop()
+	require.True(t, Schedulers()[0].Next.IsZero(), "when stopped")
+}
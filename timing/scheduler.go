@@ -16,6 +16,7 @@ package timing
 
 import (
 	"errors"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -34,17 +35,32 @@ type Scheduler struct {
 	C <-chan struct{}
 
 	mu      sync.Mutex
-	timer   *time.Timer
-	ticker  *time.Ticker
+	timer   *wheelTimer
 	quitter chan struct{}
 
 	notifyFn func()
 	waiting  int32 // basically bool, but we need atomics.
+	name     string
+
+	// The trigger set on the scheduler, guarded by its own mutex since it is
+	// also read while triggersMu is held in test mode.
+	sched   schedule
+	schedMu sync.Mutex
+
+	// For exempting repeating triggers from the on-battery multiplier.
+	ignoreBattery int32 // atomic bool
+
+	// For backing off after failures.
+	backoff  *Backoff
+	failures int
 
 	// For test mode
 	testModeID uint32
 	startTime  time.Time
 	interval   time.Duration
+	jitter     time.Duration
+	aligned    bool
+	offset     time.Duration
 }
 
 var (
@@ -53,7 +69,9 @@ var (
 	// requiring a reference to each created scheduler.
 	waiters  []chan struct{}
 	paused   = false
+	locked   = false
 	testMode = false
+	spread   = false
 
 	mu sync.Mutex
 )
@@ -68,12 +86,63 @@ func NewScheduler() *Scheduler {
 		triggersMu.Lock()
 		s.testModeID = testModeID
 		triggersMu.Unlock()
+		testModeChanged()
 	}
 	s.notifyFn, s.C = notifier.New()
 	l.Register(s, "C")
 	return s
 }
 
+// schedule records the trigger set on a scheduler, so that it can be
+// recreated when the power source changes or the machine resumes.
+type schedule struct {
+	at            time.Time     // for At/After.
+	every, jitter time.Duration // for Every/EveryWithJitter.
+	align, offset time.Duration // for EveryAlign.
+}
+
+func (s *Scheduler) setSchedule(sch schedule) {
+	s.schedMu.Lock()
+	defer s.schedMu.Unlock()
+	s.sched = sch
+}
+
+func (s *Scheduler) getSchedule() schedule {
+	s.schedMu.Lock()
+	defer s.schedMu.Unlock()
+	return s.sched
+}
+
+// fired clears a one-off trigger once it has fired.
+func (s *Scheduler) fired(when time.Time) {
+	s.schedMu.Lock()
+	defer s.schedMu.Unlock()
+	if s.sched.at.Equal(when) {
+		s.sched.at = time.Time{}
+	}
+}
+
+// scheduled returns all schedulers that have a pending trigger. These are
+// found from the pending timers, so that no additional references are kept
+// to schedulers that will never trigger again.
+func scheduled() []*Scheduler {
+	mu.Lock()
+	inTestMode := testMode
+	mu.Unlock()
+	if !inTestMode {
+		return wheel.owners()
+	}
+	triggersMu.Lock()
+	defer triggersMu.Unlock()
+	r := make([]*Scheduler, 0, len(triggers))
+	for _, t := range triggers {
+		if t.what.testModeID == testModeID {
+			r = append(r, t.what)
+		}
+	}
+	return r
+}
+
 // Pause timing.
 func Pause() {
 	mu.Lock()
@@ -82,10 +151,11 @@ func Pause() {
 }
 
 // await executes the given function when the bar is running.
-// If the bar is paused, it waits for the bar to resume.
+// If the bar is paused (or the session is locked), it waits for the bar
+// to resume.
 func await(fn func()) {
 	mu.Lock()
-	if !paused {
+	if !paused && !locked {
 		mu.Unlock()
 		fn()
 		return
@@ -104,6 +174,14 @@ func Resume() {
 	mu.Lock()
 	defer mu.Unlock()
 	paused = false
+	if !locked {
+		releaseWaiters()
+	}
+}
+
+// releaseWaiters runs all functions waiting for the bar to resume.
+// Must be called with mu held.
+func releaseWaiters() {
 	for _, ch := range waiters {
 		close(ch)
 	}
@@ -120,28 +198,32 @@ func (s *Scheduler) Tick() bool {
 // At sets the scheduler to trigger a specific time.
 // This will replace any pending triggers.
 func (s *Scheduler) At(when time.Time) *Scheduler {
-	if s.testModeID > 0 {
-		return s.testModeAt(when)
-	}
 	l.Fine("%s At(%v)", l.ID(s), when)
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.stop()
-	s.timer = time.AfterFunc(when.Sub(Now()), s.maybeTrigger)
-	return s
+	s.setSchedule(schedule{at: when})
+	return s.at(when)
 }
 
 // After sets the scheduler to trigger after a delay.
 // This will replace any pending triggers.
 func (s *Scheduler) After(delay time.Duration) *Scheduler {
+	l.Fine("%s After(%v)", l.ID(s), delay)
+	when := Now().Add(delay)
+	s.setSchedule(schedule{at: when})
+	return s.at(when)
+}
+
+// at sets the timer for a one-off trigger, without updating the schedule.
+func (s *Scheduler) at(when time.Time) *Scheduler {
 	if s.testModeID > 0 {
-		return s.testModeAfter(delay)
+		return s.testModeAt(when)
 	}
-	l.Fine("%s After(%v)", l.ID(s), delay)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.stop()
-	s.timer = time.AfterFunc(delay, s.maybeTrigger)
+	s.timer = afterFunc(s, when.Sub(Now()), func() {
+		s.fired(when)
+		s.maybeTrigger()
+	})
 	return s
 }
 
@@ -151,6 +233,8 @@ func (s *Scheduler) Every(interval time.Duration) *Scheduler {
 	if interval <= 0 {
 		panic(errors.New("non-positive interval for Scheduler#Every"))
 	}
+	s.setSchedule(schedule{every: interval})
+	interval = s.scaled(interval)
 	if s.testModeID > 0 {
 		return s.testModeEvery(interval)
 	}
@@ -158,31 +242,174 @@ func (s *Scheduler) Every(interval time.Duration) *Scheduler {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.stop()
-	s.quitter = make(chan struct{})
-	s.ticker = time.NewTicker(interval)
-	go func() {
+	quitter := make(chan struct{})
+	s.quitter = quitter
+	next := time.Now().Add(firstTick(interval))
+	var tick func()
+	tick = func() {
+		s.maybeTrigger()
 		s.mu.Lock()
-		ticker := s.ticker
-		quitter := s.quitter
-		s.mu.Unlock()
-		if ticker == nil || quitter == nil {
-			// Scheduler stopped before goroutine was started.
+		defer s.mu.Unlock()
+		if s.quitter != quitter {
 			return
 		}
-		for {
-			select {
-			case <-ticker.C:
-				s.maybeTrigger()
-			case <-quitter:
-				return
-			}
+		// Like time.Ticker, keep ticks at multiples of the interval, and
+		// drop any ticks that were missed.
+		now := time.Now()
+		for !next.After(now) {
+			next = next.Add(interval)
 		}
-	}()
+		s.timer = afterFunc(s, next.Sub(now), tick)
+	}
+	s.timer = afterFunc(s, time.Until(next), tick)
 	return s
 }
 
+// EveryWithJitter sets the scheduler to trigger repeatedly, with each
+// interval randomly lengthened or shortened by up to jitter. This prevents
+// schedulers with the same interval from staying in lock-step, which is
+// useful to avoid bursts of requests to rate-limited APIs.
+// This will replace any pending triggers.
+func (s *Scheduler) EveryWithJitter(interval, jitter time.Duration) *Scheduler {
+	if interval <= 0 {
+		panic(errors.New("non-positive interval for Scheduler#EveryWithJitter"))
+	}
+	if jitter < 0 || jitter >= interval {
+		panic(errors.New("jitter must be in [0, interval) for Scheduler#EveryWithJitter"))
+	}
+	if jitter == 0 {
+		return s.Every(interval)
+	}
+	s.setSchedule(schedule{every: interval, jitter: jitter})
+	interval, jitter = s.scaled(interval), s.scaled(jitter)
+	if s.testModeID > 0 {
+		return s.testModeEveryWithJitter(interval, jitter)
+	}
+	l.Fine("%s EveryWithJitter(%v, %v)", l.ID(s), interval, jitter)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+	quitter := make(chan struct{})
+	s.quitter = quitter
+	var tick func()
+	tick = func() {
+		s.maybeTrigger()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.quitter == quitter {
+			s.timer = afterFunc(s, jittered(interval, jitter), tick)
+		}
+	}
+	first := firstTick(interval)
+	if first == interval {
+		first = jittered(interval, jitter)
+	}
+	s.timer = afterFunc(s, first, tick)
+	return s
+}
+
+// EveryAlign sets the scheduler to trigger at each multiple of interval in
+// wall-clock time (in the machine's local time zone), shifted by offset. For
+// example, EveryAlign(time.Minute, 0) triggers at the start of each minute,
+// and EveryAlign(time.Hour, 30*time.Minute) at half past each hour.
+//
+// Unlike Every, each trigger is computed from the current wall-clock time, so
+// the triggers do not drift, and are kept aligned across changes to the
+// system clock (e.g. NTP adjustments) and suspend/resume cycles.
+// Aligned schedulers are not affected by OnBatteryMultiplier.
+// This will replace any pending triggers.
+func (s *Scheduler) EveryAlign(interval, offset time.Duration) *Scheduler {
+	if interval <= 0 {
+		panic(errors.New("non-positive interval for Scheduler#EveryAlign"))
+	}
+	s.setSchedule(schedule{align: interval, offset: offset})
+	if s.testModeID > 0 {
+		return s.testModeEveryAlign(interval, offset)
+	}
+	l.Fine("%s EveryAlign(%v, %v)", l.ID(s), interval, offset)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+	quitter := make(chan struct{})
+	s.quitter = quitter
+	next := nextAligned(Now(), interval, offset)
+	var check func()
+	check = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.quitter != quitter {
+			return
+		}
+		now := Now()
+		if !now.Before(next) {
+			s.maybeTrigger()
+			next = nextAligned(now, interval, offset)
+		}
+		s.timer = afterFunc(s, alignedWait(now, next), check)
+	}
+	s.timer = afterFunc(s, alignedWait(Now(), next), check)
+	return s
+}
+
+// maxAlignedWait is the longest time that an aligned scheduler will wait
+// before checking the wall-clock time again. Timers do not advance while the
+// machine is suspended, and are not affected by changes to the system clock,
+// so long waits could otherwise miss the aligned time by a wide margin.
+var maxAlignedWait = time.Minute
+
+func alignedWait(now, next time.Time) time.Duration {
+	wait := next.Sub(now)
+	if wait > maxAlignedWait {
+		wait = maxAlignedWait
+	}
+	return wait
+}
+
+// nextAligned returns the first time strictly after now that is a multiple of
+// interval, plus offset, in now's time zone.
+func nextAligned(now time.Time, interval, offset time.Duration) time.Time {
+	_, zoneOffset := now.Zone()
+	zone := time.Duration(zoneOffset) * time.Second
+	local := now.Add(zone)
+	next := local.Truncate(interval).Add(offset % interval)
+	for !next.After(local) {
+		next = next.Add(interval)
+	}
+	return next.Add(-zone)
+}
+
+// SpreadFirstTick controls whether the first tick of repeating schedulers
+// happens at a random point within the first interval, instead of after a
+// full interval. Since most modules are started at the same time, this
+// spreads out their refreshes instead of performing them all at once.
+func SpreadFirstTick(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	spread = enabled
+}
+
+// randInt63n is used for jitter and spreading, and can be replaced in tests.
+var randInt63n = rand.Int63n
+
+// firstTick returns the delay before the first tick of a repeating scheduler.
+func firstTick(interval time.Duration) time.Duration {
+	mu.Lock()
+	spreading := spread
+	mu.Unlock()
+	if !spreading {
+		return interval
+	}
+	return time.Duration(randInt63n(int64(interval))) + 1
+}
+
+// jittered returns the interval randomly adjusted by up to +/- jitter.
+func jittered(interval, jitter time.Duration) time.Duration {
+	return interval - jitter + time.Duration(randInt63n(int64(2*jitter)+1))
+}
+
 // Stop cancels all further triggers for the scheduler.
 func (s *Scheduler) Stop() {
+	s.setSchedule(schedule{})
 	if s.testModeID > 0 {
 		s.testModeStop()
 		return
@@ -209,10 +436,6 @@ func (s *Scheduler) stop() {
 		s.timer.Stop()
 		s.timer = nil
 	}
-	if s.ticker != nil {
-		s.ticker.Stop()
-		s.ticker = nil
-	}
 	if s.quitter != nil {
 		close(s.quitter)
 		s.quitter = nil
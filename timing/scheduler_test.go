@@ -15,6 +15,7 @@
 package timing
 
 import (
+	"math/rand"
 	"testing"
 	"time"
 
@@ -145,3 +146,45 @@ func TestTick(t *testing.T) {
 		now.Add(3*time.Second), <-timeChan,
 		50*time.Millisecond, "Tick waits for expected duration")
 }
+
+func TestJitter(t *testing.T) {
+	ExitTestMode()
+	sch := NewScheduler()
+	start := time.Now()
+	sch.EveryWithJitter(100*time.Millisecond, 50*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		notifier.AssertNotified(t, sch.C, "after jittered interval")
+	}
+	require.True(t, time.Since(start) >= 150*time.Millisecond,
+		"intervals are at least interval - jitter")
+	sch.Stop()
+	notifier.AssertNoUpdate(t, sch.C, "when stopped")
+
+	SpreadFirstTick(true)
+	defer SpreadFirstTick(false)
+	randInt63n = func(int64) int64 { return int64(10 * time.Millisecond) }
+	defer func() { randInt63n = rand.Int63n }()
+	sch.Every(time.Hour)
+	notifier.AssertNotified(t, sch.C, "first tick within first interval")
+	sch.Stop()
+}
+
+func TestEveryAlign(t *testing.T) {
+	ExitTestMode()
+	sch := NewScheduler().EveryAlign(100*time.Millisecond, 0)
+	for i := 0; i < 3; i++ {
+		notifier.AssertNotified(t, sch.C, "at aligned time")
+		now := Now()
+		offset := now.Sub(now.Truncate(100 * time.Millisecond))
+		require.True(t, offset < 20*time.Millisecond,
+			"aligned to interval, off by %v", offset)
+	}
+	sch.Stop()
+	notifier.AssertNoUpdate(t, sch.C, "when stopped")
+
+	defer func(wait time.Duration) { maxAlignedWait = wait }(maxAlignedWait)
+	maxAlignedWait = 10 * time.Millisecond
+	sch.EveryAlign(time.Hour, Now().Sub(Now().Truncate(time.Hour))+50*time.Millisecond)
+	notifier.AssertNotified(t, sch.C, "when waiting in steps")
+	sch.Stop()
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+)
+
+var sleepOnce sync.Once
+
+// RefreshOnResume triggers all schedulers immediately when the machine resumes
+// from suspend, as reported by logind's PrepareForSleep signal. Timers do not
+// advance while the machine is asleep, so without this, schedulers only
+// trigger once their remaining interval has passed after resuming.
+func RefreshOnResume() {
+	mu.Lock()
+	inTestMode := testMode
+	mu.Unlock()
+	if !inTestMode {
+		sleepOnce.Do(func() { go watchSleep(dbus.System) })
+	}
+}
+
+// watchSleep watches for the logind PrepareForSleep signal, and refreshes all
+// schedulers when the machine resumes from suspend.
+func watchSleep(busType dbus.BusType) {
+	defer func() {
+		// dbus panics if the bus is not available, which should not take down
+		// the bar; schedulers will just update at their next trigger instead.
+		if r := recover(); r != nil {
+			l.Log("Not watching for suspend/resume: %v", r)
+		}
+	}()
+	dbus.WatchProperties(busType,
+		"org.freedesktop.login1",
+		"/org/freedesktop/login1",
+		"org.freedesktop.login1.Manager",
+	).AddSignalHandler("PrepareForSleep", func(sig *dbus.Signal, _ dbus.Fetcher) map[string]interface{} {
+		// PrepareForSleep(true) is sent before suspending, and
+		// PrepareForSleep(false) after resuming.
+		if sleeping, ok := sig.Body[0].(bool); ok && !sleeping {
+			go func() {
+				l.Log("Resumed from suspend")
+				refreshAll()
+			}()
+		}
+		return nil
+	})
+}
+
+// refreshAll triggers all schedulers immediately, e.g. after the machine
+// resumes, since anything they display is likely to be out of date.
+func refreshAll() {
+	for _, s := range scheduled() {
+		s.refresh()
+	}
+}
+
+func (s *Scheduler) refresh() {
+	sch := s.getSchedule()
+	switch {
+	case !sch.at.IsZero():
+		if sch.at.After(Now()) {
+			// Timers do not advance while the machine is suspended, so
+			// restart the timer to trigger at the expected time.
+			s.at(sch.at)
+			return
+		}
+		// Missed while suspended, so trigger now, but keep the repeating
+		// interval if the scheduler is backing off.
+		s.Stop()
+		sch.at = time.Time{}
+		s.setSchedule(sch)
+		s.maybeTrigger()
+	case sch.align > 0:
+		s.maybeTrigger()
+		s.EveryAlign(sch.align, sch.offset)
+	case sch.every > 0:
+		// Restart the interval from now, instead of shortly after this trigger.
+		s.maybeTrigger()
+		s.EveryWithJitter(sch.every, sch.jitter)
+	}
+}
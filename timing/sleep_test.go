@@ -0,0 +1,114 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	"barista.run/testing/notifier"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshAll(t *testing.T) {
+	TestMode()
+	done := NewScheduler().After(time.Second)
+	NextTick()
+	notifier.AssertNotified(t, done.C)
+
+	start := Now()
+	rep := NewScheduler().Every(time.Minute)
+	aligned := NewScheduler().EveryAlign(time.Hour, 0)
+	later := NewScheduler().At(start.Add(3 * time.Hour))
+	missed := NewScheduler().After(30 * time.Minute)
+	backingOff := NewScheduler().Every(10 * time.Minute)
+	backingOff.Result(errors.New("foo"))
+
+	// Suspend for two hours, during which no triggers fire.
+	nowInTest.Store(start.Add(2 * time.Hour))
+	refreshAll()
+
+	notifier.AssertNotified(t, rep.C, "repeating")
+	notifier.AssertNotified(t, aligned.C, "aligned")
+	notifier.AssertNotified(t, missed.C, "missed one-off")
+	notifier.AssertNotified(t, backingOff.C, "missed retry")
+	notifier.AssertNoUpdate(t, later.C, "pending one-off")
+	notifier.AssertNoUpdate(t, done.C, "already fired")
+
+	now := Now()
+	require.Equal(t, now.Add(time.Minute), NextTick(),
+		"repeating interval restarted on resume")
+	notifier.AssertNotified(t, rep.C)
+	rep.Stop()
+
+	backingOff.Result(nil)
+	require.Equal(t, Now().Add(10*time.Minute), NextTick(),
+		"repeating interval kept while backing off")
+	notifier.AssertNotified(t, backingOff.C)
+	backingOff.Stop()
+
+	require.Equal(t, time.Date(2016, time.November, 25, 23, 0, 0, 0, time.UTC),
+		NextTick(), "aligned to the hour")
+	notifier.AssertNotified(t, aligned.C)
+	aligned.Stop()
+
+	require.Equal(t, start.Add(3*time.Hour), NextTick(), "pending one-off")
+	notifier.AssertNotified(t, later.C)
+
+	now = Now()
+	require.Equal(t, now, NextTick(), "no more triggers")
+	notifier.AssertNoUpdate(t, missed.C)
+	notifier.AssertNoUpdate(t, done.C)
+}
+
+func TestWatchSleep(t *testing.T) {
+	TestMode()
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService("org.freedesktop.login1")
+	obj := srv.Object("/org/freedesktop/login1", "org.freedesktop.login1.Manager")
+
+	sch := NewScheduler().Every(time.Hour)
+	watchSleep(dbus.Test)
+
+	obj.Emit("PrepareForSleep", true)
+	notifier.AssertNoUpdate(t, sch.C, "on suspend")
+
+	obj.Emit("PrepareForSleep", false)
+	notifier.AssertNotified(t, sch.C, "on resume")
+}
+
+func TestScheduled(t *testing.T) {
+	ExitTestMode()
+	defer TestMode()
+
+	pending := NewScheduler().After(time.Hour)
+	defer pending.Stop()
+	stopped := NewScheduler().Every(time.Hour)
+	stopped.Stop()
+	done := NewScheduler().After(time.Millisecond)
+	notifier.AssertNotified(t, done.C)
+
+	// Other tests may leave real timers running, so only check for the
+	// schedulers created here.
+	all := scheduled()
+	require.Contains(t, all, pending, "pending trigger")
+	require.NotContains(t, all, stopped, "stopped")
+	require.NotContains(t, all, done, "one-off already fired")
+	pending.Stop()
+	require.NotContains(t, scheduled(), pending, "after stop")
+}
@@ -58,6 +58,10 @@ func testNow() time.Time {
 func TestMode() {
 	reset(func() {
 		testMode = true
+		spread = false
+		barrierTimeout = 0
+		resetPower()
+		resetNamed()
 		testModeID++
 		// Set to non-zero time when entering test mode so that any IsZero
 		// checks don't unexpectedly pass.
@@ -85,9 +89,11 @@ func reset(fn func()) {
 	waiters = nil
 	triggers = nil
 	paused = false
+	locked = false
 }
 
 func (s *Scheduler) setNextTrigger(when time.Time) *Scheduler {
+	testModeChanged()
 	newTriggers := triggerList{}
 	triggersMu.Lock()
 	defer triggersMu.Unlock()
@@ -105,31 +111,80 @@ func (s *Scheduler) setNextTrigger(when time.Time) *Scheduler {
 }
 
 func (s *Scheduler) nextRepeatingTick() time.Time {
+	if s.aligned {
+		return nextAligned(Now(), s.interval, s.offset)
+	}
+	if s.jitter > 0 {
+		return Now().Add(jittered(s.interval, s.jitter))
+	}
 	elapsedIntervals := Now().Sub(s.startTime) / s.interval
 	return s.startTime.Add(s.interval * (elapsedIntervals + 1))
 }
 
 func (s *Scheduler) testModeAt(when time.Time) *Scheduler {
 	l.Fine("%s At[Test](%v)", l.ID(s), when)
+	s.clearInterval()
 	return s.setNextTrigger(when)
 }
 
-func (s *Scheduler) testModeAfter(delay time.Duration) *Scheduler {
-	l.Fine("%s After[Test](%v)", l.ID(s), delay)
-	return s.setNextTrigger(Now().Add(delay))
+func (s *Scheduler) clearInterval() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = 0
+	s.jitter = 0
+	s.aligned = false
 }
 
 func (s *Scheduler) testModeEvery(interval time.Duration) *Scheduler {
 	l.Fine("%s Every[Test](%v)", l.ID(s), interval)
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.startTime = Now()
+	s.startTime = Now().Add(firstTick(interval) - interval)
+	s.interval = interval
+	s.jitter = 0
+	s.aligned = false
+	return s.setNextTrigger(s.nextRepeatingTick())
+}
+
+func (s *Scheduler) testModeEveryWithJitter(interval, jitter time.Duration) *Scheduler {
+	l.Fine("%s EveryWithJitter[Test](%v, %v)", l.ID(s), interval, jitter)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.interval = interval
+	s.jitter = jitter
+	s.aligned = false
+	first := firstTick(interval)
+	if first == interval {
+		first = jittered(interval, jitter)
+	}
+	return s.setNextTrigger(Now().Add(first))
+}
+
+func (s *Scheduler) testModeEveryAlign(interval, offset time.Duration) *Scheduler {
+	l.Fine("%s EveryAlign[Test](%v, %v)", l.ID(s), interval, offset)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = interval
+	s.jitter = 0
+	s.aligned = true
+	s.offset = offset
 	return s.setNextTrigger(s.nextRepeatingTick())
 }
 
+func (s *Scheduler) testModeNext() time.Time {
+	triggersMu.Lock()
+	defer triggersMu.Unlock()
+	for _, t := range triggers {
+		if t.what == s {
+			return t.when
+		}
+	}
+	return time.Time{}
+}
+
 func (s *Scheduler) testModeStop() {
 	l.Fine("%s Stop[Test]", l.ID(s))
+	s.clearInterval()
 	s.setNextTrigger(time.Time{})
 }
 
@@ -137,12 +192,13 @@ func (s *Scheduler) testModeStop() {
 // It also advances test time to match.
 func NextTick() time.Time {
 	triggersMu.Lock()
-	defer triggersMu.Unlock()
 	if len(triggers) == 0 {
+		triggersMu.Unlock()
 		return testNow()
 	}
 	when := triggers[0].when
-	return advanceToLocked(when)
+	triggersMu.Unlock()
+	return AdvanceTo(when)
 }
 
 // AdvanceBy increments the test time by the given duration,
@@ -154,21 +210,31 @@ func AdvanceBy(duration time.Duration) time.Time {
 // AdvanceTo increments the test time to the given time,
 // and triggers any schedulers that were scheduled in the meantime.
 func AdvanceTo(newTime time.Time) time.Time {
-	triggersMu.Lock()
-	defer triggersMu.Unlock()
-	return advanceToLocked(newTime)
+	for {
+		now, fired := advanceStep(newTime)
+		if len(fired) == 0 {
+			return now
+		}
+		// With a barrier, keep going until nothing else is due, since the
+		// schedulers that were triggered may have scheduled further triggers.
+		if !awaitConsumed(fired) && !newTime.After(now) {
+			return now
+		}
+	}
 }
 
-func advanceToLocked(newTime time.Time) time.Time {
-	if len(triggers) == 0 {
+// advanceStep triggers all schedulers that are due at the earliest trigger
+// time, if that is no later than newTime, and advances the test time to match.
+// Otherwise it advances the test time to newTime. It returns the new test time
+// and the schedulers that were triggered.
+func advanceStep(newTime time.Time) (time.Time, []*Scheduler) {
+	triggersMu.Lock()
+	defer triggersMu.Unlock()
+	if len(triggers) == 0 || triggers[0].when.After(newTime) {
 		nowInTest.Store(newTime)
-		return newTime
+		return newTime, nil
 	}
 	nextTick := triggers[0].when
-	if nextTick.After(newTime) {
-		nowInTest.Store(newTime)
-		return newTime
-	}
 	now := testNow()
 	if nextTick.After(now) {
 		nowInTest.Store(nextTick)
@@ -176,6 +242,7 @@ func advanceToLocked(newTime time.Time) time.Time {
 		nextTick = now
 	}
 	idx := 0
+	var fired []*Scheduler
 	for i, t := range triggers {
 		if triggers[i].when.After(nextTick) {
 			break
@@ -183,14 +250,14 @@ func advanceToLocked(newTime time.Time) time.Time {
 		if t.what.interval > 0 {
 			t.when = t.what.nextRepeatingTick()
 			triggers = append(triggers, t)
+		} else {
+			t.what.fired(t.when)
 		}
 		idx = i + 1
 		t.what.maybeTrigger()
+		fired = append(fired, t.what)
 	}
 	triggers = triggers[idx:]
 	sort.Sort(triggers)
-	if newTime.After(nextTick) {
-		return advanceToLocked(newTime)
-	}
-	return nextTick
+	return nextTick, fired
 }
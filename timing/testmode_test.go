@@ -15,6 +15,7 @@
 package timing
 
 import (
+	"math/rand"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -296,3 +297,98 @@ func TestTestModeReset(t *testing.T) {
 	notifier.AssertNoUpdate(t, sch1.C, "previous scheduler is not triggered")
 	notifier.AssertNoUpdate(t, sch2.C, "previous scheduler is not triggered")
 }
+
+func TestJitter_TestMode(t *testing.T) {
+	TestMode()
+	defer func() { randInt63n = rand.Int63n }()
+	// Each call returns the next value, cycling through the list.
+	values := []int64{0, 60, 30}
+	randInt63n = func(n int64) int64 {
+		require.Equal(t, int64(60*time.Second)+1, n, "range is +/- jitter")
+		v := values[0]
+		values = append(values[1:], v)
+		return v * int64(time.Second)
+	}
+	sch := NewScheduler().EveryWithJitter(time.Minute, 30*time.Second)
+	start := Now()
+	require.Equal(t, start.Add(30*time.Second), NextTick(), "min jitter")
+	notifier.AssertNotified(t, sch.C)
+	require.Equal(t, start.Add(120*time.Second), NextTick(), "max jitter")
+	notifier.AssertNotified(t, sch.C)
+	require.Equal(t, start.Add(180*time.Second), NextTick(), "no jitter")
+	notifier.AssertNotified(t, sch.C)
+
+	sch.Stop()
+	require.Equal(t, Now(), NextTick(), "when stopped")
+
+	require.Panics(t, func() { sch.EveryWithJitter(time.Minute, time.Minute) })
+	require.Panics(t, func() { sch.EveryWithJitter(time.Minute, -time.Second) })
+	require.Panics(t, func() { sch.EveryWithJitter(0, 0) })
+}
+
+func TestSpreadFirstTick_TestMode(t *testing.T) {
+	TestMode()
+	defer func() { randInt63n = rand.Int63n }()
+	randInt63n = func(n int64) int64 { return n / 4 }
+
+	start := Now()
+	sch := NewScheduler().Every(time.Minute)
+	require.Equal(t, start.Add(time.Minute), NextTick(), "not spread by default")
+	notifier.AssertNotified(t, sch.C)
+
+	SpreadFirstTick(true)
+	start = Now()
+	sch.Every(time.Minute)
+	require.Equal(t, start.Add(15*time.Second+1), NextTick(), "first tick spread")
+	notifier.AssertNotified(t, sch.C)
+	require.Equal(t, start.Add(75*time.Second+1), NextTick(), "then every interval")
+	notifier.AssertNotified(t, sch.C)
+
+	start = Now()
+	sch.EveryWithJitter(time.Minute, 10*time.Second)
+	require.Equal(t, start.Add(15*time.Second+1), NextTick(), "first tick spread")
+	require.Equal(t, start.Add(15*time.Second+1).Add(55*time.Second), NextTick(),
+		"then jittered interval")
+
+	TestMode()
+	start = Now()
+	sch = NewScheduler().Every(time.Minute)
+	require.Equal(t, start.Add(time.Minute), NextTick(), "reset by TestMode")
+}
+
+func TestEveryAlign_TestMode(t *testing.T) {
+	TestMode()
+	// 20:47:00.
+	sch := NewScheduler().EveryAlign(10*time.Minute, 0)
+	require.Equal(t, "20:50:00", NextTick().Format("15:04:05"))
+	notifier.AssertNotified(t, sch.C)
+	require.Equal(t, "21:00:00", NextTick().Format("15:04:05"))
+
+	AdvanceBy(3*time.Minute + 17*time.Second)
+	sch.EveryAlign(time.Hour, 30*time.Minute)
+	require.Equal(t, "21:30:00", NextTick().Format("15:04:05"))
+	require.Equal(t, "22:30:00", NextTick().Format("15:04:05"))
+
+	sch.EveryAlign(time.Second, -100*time.Millisecond)
+	require.Equal(t, "22:30:00.9", NextTick().Format("15:04:05.0"))
+	require.Equal(t, "22:30:01.9", NextTick().Format("15:04:05.0"))
+
+	sch.After(time.Minute)
+	require.Equal(t, "22:31:01.9", NextTick().Format("15:04:05.0"))
+	notifier.AssertNotified(t, sch.C)
+	require.Equal(t, "22:31:01.9", NextTick().Format("15:04:05.0"),
+		"no longer repeating")
+}
+
+func TestNextAligned(t *testing.T) {
+	zone := time.FixedZone("IST", 5*3600+1800)
+	now := time.Date(2018, time.March, 4, 10, 10, 0, 0, zone)
+	require.Equal(t, time.Date(2018, time.March, 4, 11, 0, 0, 0, zone),
+		nextAligned(now, time.Hour, 0), "aligned in local time")
+	require.Equal(t, time.Date(2018, time.March, 4, 11, 0, 0, 0, zone),
+		nextAligned(now.Add(-time.Second), time.Hour, 0))
+	require.Equal(t, time.Date(2018, time.March, 4, 10, 15, 0, 0, zone),
+		nextAligned(now, 15*time.Minute, 0), "strictly after now")
+	require.Equal(t, time.Date(2018, time.March, 5, 6, 0, 0, 0, zone),
+		nextAligned(now, 24*time.Hour, 6*time.Hour))
+}
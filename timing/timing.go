@@ -29,7 +29,16 @@ the module with fresh information:
 	  // update code.
     }
 
-This will automatically suspend processing when the bar is hidden.
+This will automatically suspend processing when the bar is hidden, and
+PauseWhileLocked extends this to while the session is locked.
+RefreshOnResume triggers all schedulers immediately when the machine resumes
+from suspend (as reported by logind), since their timers do not advance while
+the machine is asleep.
+
+The timers of all schedulers are run from a single system timer. To further
+reduce wakeups, e.g. on battery, timers that are due at around the same time
+can be run together using CoalesceWithin, and the effect can be verified using
+Wakeups.
 
 Modules should also use timing.Now() instead of time.Now() to control time
 during tests, as well as correctly track the machine's time zone.
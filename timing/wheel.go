@@ -0,0 +1,210 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// wheel runs the timers of all schedulers from a single system timer, firing
+// timers that are due at around the same time together to reduce the number
+// of times the process is woken up.
+var wheel = &timerWheel{}
+
+// timerWheel is a set of pending timers, ordered by when they are due.
+type timerWheel struct {
+	// Statistics, accessed atomically. Kept first for 64-bit alignment.
+	wakeups uint64
+	run     uint64
+
+	mu      sync.Mutex
+	pending timerHeap
+	timer   *time.Timer
+	// The time at which timer will wake up, if it is running.
+	wakeAt time.Time
+	slack  time.Duration
+}
+
+// wheelTimer is a single timer on the wheel, similar to time.Timer.
+type wheelTimer struct {
+	wheel *timerWheel
+	when  time.Time
+	fn    func()
+	index int // in the heap, -1 if not pending.
+	// The scheduler that set the timer, if any.
+	owner *Scheduler
+}
+
+type timerHeap []*wheelTimer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].when.Before(h[j].when) }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*wheelTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
+
+// WakeupStats holds statistics about the timers run by schedulers, to help
+// verify the effect of CoalesceWithin and OnBatteryMultiplier.
+type WakeupStats struct {
+	// Wakeups is the number of times the process was woken up to run timers.
+	Wakeups uint64
+	// Timers is the number of timers run, which is at least the number of
+	// wakeups, and larger when timers are coalesced.
+	Timers uint64
+}
+
+// Wakeups returns statistics about the timers run so far.
+func Wakeups() WakeupStats {
+	return wheel.stats()
+}
+
+func (w *timerWheel) stats() WakeupStats {
+	return WakeupStats{
+		Wakeups: atomic.LoadUint64(&w.wakeups),
+		Timers:  atomic.LoadUint64(&w.run),
+	}
+}
+
+// CoalesceWithin allows the timers of all schedulers to be delayed by up to
+// the given slack, so that timers due at around the same time can be run
+// together, waking up the process (and CPU) less often. This trades some
+// precision for power, so the slack should be small compared to the shortest
+// scheduler interval, e.g. 100ms for a bar with a clock that updates every
+// second. Timers are never run early.
+func CoalesceWithin(slack time.Duration) {
+	if slack < 0 {
+		panic(errors.New("negative slack for CoalesceWithin"))
+	}
+	wheel.setSlack(slack)
+}
+
+func (w *timerWheel) setSlack(slack time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.slack = slack
+	w.arm()
+}
+
+// afterFunc runs fn on the wheel after the given delay, and returns a timer
+// that can be used to cancel the call.
+func afterFunc(owner *Scheduler, d time.Duration, fn func()) *wheelTimer {
+	return wheel.afterFunc(owner, d, fn)
+}
+
+func (w *timerWheel) afterFunc(owner *Scheduler, d time.Duration, fn func()) *wheelTimer {
+	t := &wheelTimer{wheel: w, when: time.Now().Add(d), fn: fn, owner: owner}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	heap.Push(&w.pending, t)
+	w.arm()
+	return t
+}
+
+// Stop prevents the timer from running, and returns false if it has already
+// been run or stopped.
+func (t *wheelTimer) Stop() bool {
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&w.pending, t.index)
+	w.arm()
+	return true
+}
+
+// owners returns the schedulers of all pending timers.
+func (w *timerWheel) owners() []*Scheduler {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	r := make([]*Scheduler, 0, len(w.pending))
+	for _, t := range w.pending {
+		if t.owner != nil {
+			r = append(r, t.owner)
+		}
+	}
+	return r
+}
+
+func (t *wheelTimer) pending() bool {
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return t.index >= 0
+}
+
+// arm sets the system timer to wake up at the latest time that still runs the
+// earliest pending timer within the slack. Must be called with mu held.
+func (w *timerWheel) arm() {
+	if len(w.pending) == 0 {
+		if w.timer != nil {
+			w.timer.Stop()
+			w.wakeAt = time.Time{}
+		}
+		return
+	}
+	wakeAt := w.pending[0].when.Add(w.slack)
+	if wakeAt.Equal(w.wakeAt) {
+		return
+	}
+	w.wakeAt = wakeAt
+	if w.timer == nil {
+		w.timer = time.AfterFunc(time.Until(wakeAt), w.fire)
+	} else {
+		w.timer.Stop()
+		w.timer.Reset(time.Until(wakeAt))
+	}
+}
+
+// fire runs all timers that are due. Since waking up is delayed by the slack,
+// this includes any timers that became due in the meantime.
+func (w *timerWheel) fire() {
+	w.mu.Lock()
+	now := time.Now()
+	var due []*wheelTimer
+	for len(w.pending) > 0 && !w.pending[0].when.After(now) {
+		due = append(due, heap.Pop(&w.pending).(*wheelTimer))
+	}
+	w.wakeAt = time.Time{}
+	w.arm()
+	w.mu.Unlock()
+	atomic.AddUint64(&w.wakeups, 1)
+	atomic.AddUint64(&w.run, uint64(len(due)))
+	for _, t := range due {
+		t.fn()
+	}
+}
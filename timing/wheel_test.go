@@ -0,0 +1,102 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type firedTimers struct {
+	sync.Mutex
+	at map[string]time.Time
+}
+
+func (f *firedTimers) add(w *timerWheel, name string, d time.Duration) *wheelTimer {
+	when := time.Now().Add(d)
+	return w.afterFunc(nil, d, func() {
+		now := time.Now()
+		f.Lock()
+		defer f.Unlock()
+		if now.Before(when) {
+			panic(name + " fired early")
+		}
+		f.at[name] = now
+	})
+}
+
+func (f *firedTimers) get(name string) (time.Time, bool) {
+	f.Lock()
+	defer f.Unlock()
+	at, ok := f.at[name]
+	return at, ok
+}
+
+func TestWheelWithoutSlack(t *testing.T) {
+	w := &timerWheel{}
+	f := &firedTimers{at: map[string]time.Time{}}
+	f.add(w, "a", 50*time.Millisecond)
+	f.add(w, "b", 150*time.Millisecond)
+	stopped := f.add(w, "c", 100*time.Millisecond)
+	require.True(t, stopped.Stop(), "stopping pending timer")
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok := f.get("a")
+	require.True(t, ok, "first timer")
+	_, ok = f.get("b")
+	require.False(t, ok, "second timer not yet due")
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok = f.get("b")
+	require.True(t, ok, "second timer")
+	_, ok = f.get("c")
+	require.False(t, ok, "stopped timer")
+	require.False(t, stopped.Stop(), "stopping stopped timer")
+
+	require.Equal(t, WakeupStats{Wakeups: 2, Timers: 2}, w.stats())
+}
+
+func TestWheelCoalescing(t *testing.T) {
+	w := &timerWheel{}
+	w.setSlack(200 * time.Millisecond)
+	f := &firedTimers{at: map[string]time.Time{}}
+	f.add(w, "a", 50*time.Millisecond)
+	f.add(w, "b", 100*time.Millisecond)
+	f.add(w, "c", 150*time.Millisecond)
+	f.add(w, "d", 500*time.Millisecond)
+
+	time.Sleep(350 * time.Millisecond)
+	a, ok := f.get("a")
+	require.True(t, ok)
+	for _, name := range []string{"b", "c"} {
+		at, ok := f.get(name)
+		require.True(t, ok, "%s is coalesced", name)
+		require.WithinDuration(t, a, at, 10*time.Millisecond,
+			"%s fired with the first timer", name)
+	}
+	_, ok = f.get("d")
+	require.False(t, ok, "timers beyond the slack are not coalesced")
+	require.Equal(t, WakeupStats{Wakeups: 1, Timers: 3}, w.stats())
+
+	time.Sleep(500 * time.Millisecond)
+	_, ok = f.get("d")
+	require.True(t, ok, "late timer")
+	require.Equal(t, WakeupStats{Wakeups: 2, Timers: 4}, w.stats())
+
+	require.Panics(t, func() { CoalesceWithin(-time.Second) })
+}